@@ -0,0 +1,129 @@
+package apikeys
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	v1 "github.com/back-end-labs/ruok/pkg/api/v1"
+	"github.com/back-end-labs/ruok/pkg/auth"
+	"github.com/back-end-labs/ruok/pkg/config"
+	"github.com/back-end-labs/ruok/pkg/storage"
+	"github.com/gofrs/uuid"
+	"github.com/spf13/cobra"
+)
+
+// Connects to the configured storage and runs fn with it
+func withStorage(fn func(s storage.Storage)) {
+	cfg := config.FromEnvs()
+	if cfg.Kind == config.MEMORY_STORAGE {
+		log.Fatalln("The memory storage keeps nothing between runs, api keys need postgres or sqlite")
+	}
+	s, close := storage.NewStorage(&cfg)
+	defer close()
+	fn(s)
+}
+
+var scopeFlag string
+var tenantFlag string
+
+var createCmd = &cobra.Command{
+	Use:   "create NAME",
+	Short: "Creates an api key and prints it, it can't be read again",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if !auth.IsValidKeyName(args[0]) {
+			log.Fatalf("Invalid name %q, only letters, numbers, '.', '-' and '_' are allowed, up to 63 characters\n", args[0])
+		}
+		scope, err := auth.ParseScope(scopeFlag)
+		if err != nil {
+			log.Fatalf("%s\n", err.Error())
+		}
+		if !auth.IsValidTenant(tenantFlag) {
+			log.Fatalf("Invalid tenant %q, only letters, numbers, '.', '-' and '_' are allowed, up to 63 characters\n", tenantFlag)
+		}
+		withStorage(func(s storage.Storage) {
+			key, created, err := v1.NewAPIKey(s, args[0], scope, tenantFlag)
+			if err != nil {
+				log.Fatalf("couldn't create the api key: %q\n", err.Error())
+			}
+			log.Printf("Created api key %v named %q with the %s scope for tenant %q, keep it safe as it won't be shown again\n", created.Id, created.Name, created.Scope, created.Tenant)
+			fmt.Println(key)
+		})
+	},
+}
+
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Lists api keys, revoked ones included",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		withStorage(func(s storage.Storage) {
+			keys := s.ListAPIKeys()
+			if keys == nil {
+				log.Fatalln("couldn't list the api keys")
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tNAME\tPREFIX\tSCOPE\tTENANT\tCREATED AT\tREVOKED AT")
+			for _, k := range keys {
+				revokedAt := "-"
+				if k.RevokedAt != 0 {
+					revokedAt = formatMillis(k.RevokedAt)
+				}
+				fmt.Fprintf(w, "%v\t%s\t%s\t%s\t%s\t%s\t%s\n", k.Id, k.Name, k.Prefix, k.Scope, k.Tenant, formatMillis(k.CreatedAt), revokedAt)
+			}
+			w.Flush()
+		})
+	},
+}
+
+var revokeCmd = &cobra.Command{
+	Use:   "revoke ID",
+	Short: "Stops accepting an api key right away",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		id, err := uuid.FromString(args[0])
+		if err != nil {
+			log.Fatalf("ID must be the id of a key, instead got %q\n", args[0])
+		}
+		withStorage(func(s storage.Storage) {
+			err := s.RevokeAPIKey(id)
+			if errors.Is(err, storage.ErrNotFound) {
+				log.Fatalf("There is no api key to revoke with id %v\n", id)
+			}
+			if err != nil {
+				log.Fatalf("couldn't revoke the api key: %q\n", err.Error())
+			}
+			log.Printf("Revoked api key %v\n", id)
+		})
+	},
+}
+
+func formatMillis(ms int64) string {
+	return time.UnixMilli(ms).Format("2006-01-02 15:04:05 MST")
+}
+
+var APIKeys = &cobra.Command{
+	Use:   "apikeys",
+	Short: "Manages the keys used to call the http API",
+	Long: `Manages the keys used to call the http API when API_AUTH=keys.
+
+Only a hash of every key is stored, so keys are printed once when they are created.
+Scopes are "read" (list things), "write" (change jobs and maintenance windows) and
+"admin" (manage api keys), each one allows what the previous ones do.
+Callers using a key only see the jobs of its tenant.
+
+With postgres the db user needs the RUOK_JOBS_MANAGER role, schedulers can only check keys.
+`,
+}
+
+func init() {
+	createCmd.Flags().StringVar(&scopeFlag, "scope", string(auth.ReadScope), "read, write or admin")
+	createCmd.Flags().StringVar(&tenantFlag, "tenant", auth.DefaultTenant, "tenant whose jobs the key can see and change")
+	APIKeys.AddCommand(createCmd)
+	APIKeys.AddCommand(listCmd)
+	APIKeys.AddCommand(revokeCmd)
+}
@@ -0,0 +1,204 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	v1 "github.com/back-end-labs/ruok/pkg/api/v1"
+	"github.com/back-end-labs/ruok/pkg/auth"
+	"github.com/back-end-labs/ruok/pkg/config"
+	"github.com/back-end-labs/ruok/pkg/gitops"
+	"github.com/back-end-labs/ruok/pkg/storage"
+	"github.com/spf13/cobra"
+)
+
+// Recorded as the author of the jobs changed from the command line
+var cliCaller = "cli:import"
+
+// Connects to the configured storage and runs fn with the jobs of the tenant
+func withStorage(tenant string, fn func(s storage.APIStorage)) {
+	if !auth.IsValidTenant(tenant) {
+		log.Fatalf("Invalid tenant %q, only letters, numbers, '.', '-' and '_' are allowed, up to 63 characters\n", tenant)
+	}
+	cfg := config.FromEnvs()
+	if cfg.Kind == config.MEMORY_STORAGE {
+		log.Fatalln("The memory storage keeps nothing between runs, jobs need postgres or sqlite")
+	}
+	s, close := storage.NewStorage(&cfg)
+	defer close()
+	fn(s.ForTenant(tenant))
+}
+
+// Files ending in .json are json, the rest yaml
+func formatOf(path string) string {
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return v1.FormatJSON
+	}
+	return v1.FormatYAML
+}
+
+var formatFlag string
+var outputFlag string
+var tenantFlag string
+var dryRunFlag bool
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Writes every job as definitions keyed by their stable name",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if formatFlag != v1.FormatYAML && formatFlag != v1.FormatJSON {
+			log.Fatalf("Invalid format %q, use %s or %s\n", formatFlag, v1.FormatYAML, v1.FormatJSON)
+		}
+		withStorage(tenantFlag, func(s storage.APIStorage) {
+			d, err := v1.ExportDefinitions(s)
+			if err != nil {
+				log.Fatalf("couldn't export the jobs: %q\n", err.Error())
+			}
+			out, err := v1.EncodeDefinitions(d, formatFlag)
+			if err != nil {
+				log.Fatalf("couldn't write the definitions: %q\n", err.Error())
+			}
+			if outputFlag == "" {
+				os.Stdout.Write(out)
+				return
+			}
+			if err := os.WriteFile(outputFlag, out, 0644); err != nil {
+				log.Fatalf("couldn't write %s: %q\n", outputFlag, err.Error())
+			}
+			log.Printf("Exported %d jobs to %s\n", len(d.Jobs), outputFlag)
+		})
+	},
+}
+
+var importCmd = &cobra.Command{
+	Use:   "import FILE",
+	Short: "Creates the jobs of the definitions that are missing and updates the ones that differ",
+	Long: `Creates the jobs of the definitions in FILE that are missing and updates the ones that differ.
+Files ending in .json are read as json, the rest as yaml. "-" reads yaml from the standard input.
+
+What changes is printed before it is applied, --dry-run only prints it.
+Jobs without a definition are left as they are.
+`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var data []byte
+		var err error
+		if args[0] == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(args[0])
+		}
+		if err != nil {
+			log.Fatalf("couldn't read %s: %q\n", args[0], err.Error())
+		}
+		d, err := v1.DecodeDefinitions(data, formatOf(args[0]))
+		if err != nil {
+			log.Fatalf("couldn't read the definitions in %s: %q\n", args[0], err.Error())
+		}
+
+		withStorage(tenantFlag, func(s storage.APIStorage) {
+			plan, fieldErrors, err := v1.PlanImport(s, d)
+			if err != nil {
+				log.Fatalf("couldn't plan the import: %q\n", err.Error())
+			}
+			if len(fieldErrors) > 0 {
+				for _, e := range fieldErrors {
+					fmt.Fprintf(os.Stderr, "%s: %s\n", e.Field, e.Message)
+				}
+				log.Fatalf("Nothing was imported, %d fields are not valid\n", len(fieldErrors))
+			}
+
+			plan.Print(os.Stdout)
+			if dryRunFlag {
+				return
+			}
+			if err := plan.Apply(s, cliCaller); err != nil {
+				for _, change := range plan.Changes {
+					if change.Error != "" {
+						fmt.Fprintf(os.Stderr, "%s: %s\n", change.Key, change.Error)
+					}
+				}
+				log.Fatalf("%d changes couldn't be imported, the rest were\n", plan.Failed())
+			}
+			log.Printf("Created %d and updated %d jobs from %s\n", plan.Count(v1.ChangeCreate), plan.Count(v1.ChangeUpdate), args[0])
+		})
+	},
+}
+
+var dirFlag string
+var intervalFlag time.Duration
+
+var Sync = &cobra.Command{
+	Use:   "sync",
+	Short: "Makes the jobs match a directory of definitions, creating, updating and deleting the ones it owns",
+	Long: `Makes the jobs match the definitions in every yaml and json file of --dir and its subdirectories.
+
+Jobs created by sync get the ` + v1.ManagedLabel + `=` + v1.ManagedBySync + ` label, and only those are updated or deleted
+when their definition changes or goes away. Jobs created any other way are never touched.
+
+What changes is printed before it is applied, --dry-run only prints it.
+With --interval it keeps syncing until it is stopped, otherwise it syncs once.
+Schedulers can do the same with SYNC_DIR.
+`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if dirFlag == "" {
+			log.Fatalln("--dir is required")
+		}
+		withStorage(tenantFlag, func(s storage.APIStorage) {
+			syncer := &gitops.Syncer{Dir: dirFlag, Storage: s, Out: os.Stdout, DryRun: dryRunFlag}
+			if intervalFlag > 0 {
+				ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+				defer stop()
+				syncer.Run(ctx, intervalFlag)
+				return
+			}
+
+			_, err := syncer.Once()
+			var invalid *gitops.InvalidError
+			if errors.As(err, &invalid) {
+				for _, e := range invalid.Errors {
+					fmt.Fprintf(os.Stderr, "%s: %s\n", e.Field, e.Message)
+				}
+				log.Fatalf("Nothing was synced, %d fields are not valid\n", len(invalid.Errors))
+			}
+			if err != nil {
+				log.Fatalf("couldn't sync the jobs: %q\n", err.Error())
+			}
+		})
+	},
+}
+
+var Jobs = &cobra.Command{
+	Use:   "jobs",
+	Short: "Exports and imports the definitions of the jobs",
+	Long: `Exports and imports the definitions of the jobs, so they can be kept in git and moved between instances.
+
+Definitions are keyed by a stable name, kept in the ` + v1.KeyLabel + ` label of every job.
+Jobs that never got one are exported keyed by their id.
+`,
+}
+
+func init() {
+	exportCmd.Flags().StringVar(&formatFlag, "format", v1.FormatYAML, "yaml or json")
+	exportCmd.Flags().StringVarP(&outputFlag, "output", "o", "", "file to write, the standard output by default")
+	importCmd.Flags().BoolVar(&dryRunFlag, "dry-run", false, "only print what would change")
+	Sync.Flags().StringVar(&dirFlag, "dir", "", "directory of the definitions")
+	Sync.Flags().BoolVar(&dryRunFlag, "dry-run", false, "only print what would change")
+	Sync.Flags().DurationVar(&intervalFlag, "interval", 0, "how often to sync, like 1m. Syncs once when it is 0")
+	Sync.Flags().StringVar(&tenantFlag, "tenant", auth.DefaultTenant, "tenant whose jobs are synced")
+	for _, cmd := range []*cobra.Command{exportCmd, importCmd} {
+		cmd.Flags().StringVar(&tenantFlag, "tenant", auth.DefaultTenant, "tenant whose jobs are exported or imported")
+		Jobs.AddCommand(cmd)
+	}
+}
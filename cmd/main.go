@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/back-end-labs/ruok/cmd/apikeys"
+	"github.com/back-end-labs/ruok/cmd/jobs"
 	migrations "github.com/back-end-labs/ruok/cmd/migrate"
 	"github.com/back-end-labs/ruok/cmd/scheduler"
 	"github.com/back-end-labs/ruok/cmd/version"
@@ -34,5 +36,8 @@ func main() {
 	rootCmd.AddCommand(version.VersionCmd)
 	rootCmd.AddCommand(scheduler.StartScheduler)
 	rootCmd.AddCommand(migrations.SetupDB)
+	rootCmd.AddCommand(apikeys.APIKeys)
+	rootCmd.AddCommand(jobs.Jobs)
+	rootCmd.AddCommand(jobs.Sync)
 	execute()
 }
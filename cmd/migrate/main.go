@@ -2,75 +2,94 @@ package migrations
 
 import (
 	"context"
-	_ "embed"
+	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"text/tabwriter"
 
 	"github.com/back-end-labs/ruok/pkg/config"
 	"github.com/back-end-labs/ruok/pkg/storage"
 	"github.com/spf13/cobra"
 )
 
-type migration struct {
-	name string
-	sql  string
-}
-
-//go:embed migrations/2023_12_04_041700_base_schema_n_fn.sql
-var _2023_12_04_041700_base_schema_n_fn string
-
-//go:embed migrations/2023_12_04_041701_base_tables.sql
-var _2023_12_04_041701_base_tables string
-
-//go:embed migrations/2023_12_04_041702_base_roles.sql
-var _2023_12_04_041702_base_roles string
-
-//go:embed migrations/2023_12_04_041703_add_dev_test_user.sql
-var _2023_12_04_041703_add_dev_test_user string
-
+// Development/testing migrations only run outside of production
 func migrationList() []migration {
-	migrations := []migration{}
-	migrations = append(migrations, migration{"_2023_12_04_041700_base_schema_n_fn", _2023_12_04_041700_base_schema_n_fn})
-	migrations = append(migrations, migration{"_2023_12_04_041701_base_tables", _2023_12_04_041701_base_tables})
-	migrations = append(migrations, migration{"_2023_12_04_041702_base_roles", _2023_12_04_041702_base_roles})
-
-	// only if developing/testing
-	if os.Getenv(config.RUOK_ENVIRONMENT) != config.ProdRuokEnvironment {
-		migrations = append(migrations, migration{"_2023_12_04_041703_add_dev_test_user", _2023_12_04_041703_add_dev_test_user})
+	includeDev := os.Getenv(config.RUOK_ENVIRONMENT) != config.ProdRuokEnvironment
+	migrations, err := loadMigrations(embedded, migrationsDir, includeDev)
+	if err != nil {
+		log.Fatalf("couldn't load migrations: %q\n", err.Error())
 	}
-
 	return migrations
 }
 
-func migrate() {
-	log.Println("Starting Migration Process")
+// Connects to the db and runs fn while holding the migrations lock.
+// The sqlite storage creates its tables by itself and the memory one has none, so there is nothing to run.
+func withRunner(fn func(ctx context.Context, r *runner)) {
 	cfg := config.FromEnvs()
-	s, close := storage.NewStorage(&cfg)
+	if cfg.Kind == config.SQLITE_STORAGE {
+		log.Printf("Nothing to do, the sqlite storage creates its tables in %q when ruok starts\n", cfg.SQLitePath)
+		return
+	}
+	if cfg.Kind == config.MEMORY_STORAGE {
+		log.Println("Nothing to do, the memory storage keeps nothing between runs")
+		return
+	}
+	s, close := storage.NewPostgresStorage(&cfg)
 	defer close()
 
 	ctx := context.Background()
-	log.Printf("Starting Transactions\n")
-	tx, err := s.GetClient().Begin(ctx)
+	r, release, err := newRunner(ctx, s.GetClient())
 	if err != nil {
-		log.Fatalf("Could not start transaction to setup db: %q\n", err.Error())
+		log.Fatalf("couldn't start migrating: %q\n", err.Error())
 	}
-	defer tx.Rollback(ctx)
+	defer release()
 
-	migrations := migrationList()
+	fn(ctx, r)
+}
 
-	for i := 0; i < len(migrations); i++ {
-		log.Printf("Starting migration number %d with name:%q\n\n", i, migrations[i].name)
-		_, err := tx.Exec(ctx, migrations[i].sql)
+func migrate() {
+	log.Println("Starting Migration Process")
+	withRunner(func(ctx context.Context, r *runner) {
+		applied, err := r.upAll(ctx, migrationList())
 		if err != nil {
-			log.Fatalf("rolling back, couldn't exec migration: with name: %q. Error: %q\n\n", migrations[i].name, err.Error())
+			log.Printf("Applied %d migrations before failing\n", applied)
+			log.Fatalf("%s\n", err.Error())
 		}
-		log.Printf("Success! Done with migration number %d with name : %q\n\n", i, migrations[i].name)
+		log.Printf("Success! Applied %d migrations\n", applied)
+	})
+}
 
-	}
-	if err := tx.Commit(ctx); err != nil {
-		log.Fatalf("couldn't commit transaction to generate db. error %q.\n", err.Error())
-	}
+func rollBack(n int) {
+	log.Printf("Rolling back %d migrations\n", n)
+	withRunner(func(ctx context.Context, r *runner) {
+		rolledBack, err := r.downN(ctx, migrationList(), n)
+		if err != nil {
+			log.Printf("Rolled back %d migrations before failing\n", rolledBack)
+			log.Fatalf("%s\n", err.Error())
+		}
+		log.Printf("Success! Rolled back %d migrations\n", rolledBack)
+	})
+}
 
+func status() {
+	withRunner(func(ctx context.Context, r *runner) {
+		applied, err := r.applied(ctx)
+		if err != nil {
+			log.Fatalf("%s\n", err.Error())
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+		for _, s := range migrationStatuses(migrationList(), applied) {
+			appliedAt := "-"
+			if s.appliedAt != nil {
+				appliedAt = s.appliedAt.Format("2006-01-02 15:04:05 MST")
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", s.version, s.name, s.status, appliedAt)
+		}
+		w.Flush()
+	})
 }
 
 var SetupDB = &cobra.Command{
@@ -80,9 +99,49 @@ var SetupDB = &cobra.Command{
   * the ruok schema
   * some utility funcions
   * all tables needed
-  * couple roles 
+  * couple roles
+
+Applied migrations are recorded in ruok.schema_migrations, so only pending ones run.
+Without a subcommand it is the same as "setupdb up".
 `,
 	Run: func(cmd *cobra.Command, args []string) {
 		migrate()
 	},
 }
+
+var upCmd = &cobra.Command{
+	Use:   "up",
+	Short: "Applies every pending migration",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		migrate()
+	},
+}
+
+var downCmd = &cobra.Command{
+	Use:   "down N",
+	Short: "Rolls back the last N applied migrations",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n <= 0 {
+			log.Fatalf("N must be a positive number, instead got %q\n", args[0])
+		}
+		rollBack(n)
+	},
+}
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Lists migrations and whether they were applied",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		status()
+	},
+}
+
+func init() {
+	SetupDB.AddCommand(upCmd)
+	SetupDB.AddCommand(downCmd)
+	SetupDB.AddCommand(statusCmd)
+}
@@ -0,0 +1,209 @@
+package migrations
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Migrations are named <version>_<name>.sql, where the version is the creation time (YYYY_MM_DD_HHMMSS).
+// Their down scripts are named <version>_<name>.down.sql, and migrations only meant for
+// development/testing add .dev before the extension.
+//
+//go:embed migrations/*.sql
+var embedded embed.FS
+
+const migrationsDir = "migrations"
+
+var migrationFileName = regexp.MustCompile(`^(\d{4}_\d{2}_\d{2}_\d{6})_(\w+?)(\.dev)?(\.down)?\.sql$`)
+
+type migration struct {
+	version  string
+	name     string
+	up       string
+	down     string
+	checksum string
+	devOnly  bool
+}
+
+// A migration recorded in ruok.schema_migrations
+type appliedMigration struct {
+	version   string
+	name      string
+	checksum  string
+	appliedAt time.Time
+}
+
+func checksum(sql string) string {
+	sum := sha256.Sum256([]byte(sql))
+	return hex.EncodeToString(sum[:])
+}
+
+// Reads every migration of the directory sorted by version.
+// Development only migrations are left out unless includeDev is true.
+func loadMigrations(fsys fs.FS, dir string, includeDev bool) ([]migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not read migrations: %w", err)
+	}
+
+	byVersion := map[string]*migration{}
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		parts := migrationFileName.FindStringSubmatch(e.Name())
+		if parts == nil {
+			return nil, fmt.Errorf("%q is not a valid migration name, expected <YYYY_MM_DD_HHMMSS>_<name>[.dev][.down].sql", e.Name())
+		}
+		version, name, devOnly, isDown := parts[1], parts[2], parts[3] != "", parts[4] != ""
+
+		content, err := fs.ReadFile(fsys, dir+"/"+e.Name())
+		if err != nil {
+			return nil, fmt.Errorf("could not read migration %q: %w", e.Name(), err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &migration{version: version, name: name, devOnly: devOnly}
+			byVersion[version] = m
+		}
+		if m.name != name || m.devOnly != devOnly {
+			return nil, fmt.Errorf("migrations %q and %q share the version %s", m.name, name, version)
+		}
+		if isDown {
+			m.down = string(content)
+		} else {
+			m.up = string(content)
+			m.checksum = checksum(m.up)
+		}
+	}
+
+	migrations := []migration{}
+	for _, m := range byVersion {
+		if m.up == "" {
+			return nil, fmt.Errorf("migration %s_%s has a down script but no up script", m.version, m.name)
+		}
+		if m.devOnly && !includeDev {
+			continue
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].version < migrations[j].version })
+	return migrations, nil
+}
+
+// Fails if an applied migration changed since it was applied
+func verifyChecksums(migrations []migration, applied []appliedMigration) error {
+	byVersion := map[string]migration{}
+	for _, m := range migrations {
+		byVersion[m.version] = m
+	}
+	modified := []string{}
+	for _, a := range applied {
+		m, ok := byVersion[a.version]
+		if ok && m.checksum != a.checksum {
+			modified = append(modified, a.version+"_"+a.name)
+		}
+	}
+	if len(modified) > 0 {
+		return fmt.Errorf("applied migrations were modified: %s", strings.Join(modified, ", "))
+	}
+	return nil
+}
+
+// Migrations that were not applied yet, in the order they should run
+func pendingMigrations(migrations []migration, applied []appliedMigration) []migration {
+	done := map[string]bool{}
+	for _, a := range applied {
+		done[a.version] = true
+	}
+	pending := []migration{}
+	for _, m := range migrations {
+		if !done[m.version] {
+			pending = append(pending, m)
+		}
+	}
+	return pending
+}
+
+// The last n applied migrations, in the order they should be rolled back.
+// Fails if any of them has no down script.
+func migrationsToRollBack(migrations []migration, applied []appliedMigration, n int) ([]migration, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("the amount of migrations to roll back must be positive, instead got %d", n)
+	}
+	byVersion := map[string]migration{}
+	for _, m := range migrations {
+		byVersion[m.version] = m
+	}
+
+	sorted := append([]appliedMigration{}, applied...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].version > sorted[j].version })
+	if n > len(sorted) {
+		n = len(sorted)
+	}
+
+	rollBack := []migration{}
+	for _, a := range sorted[:n] {
+		m, ok := byVersion[a.version]
+		if !ok {
+			return nil, fmt.Errorf("migration %s_%s is applied but its files are missing", a.version, a.name)
+		}
+		if m.down == "" {
+			return nil, fmt.Errorf("migration %s_%s has no down script", m.version, m.name)
+		}
+		rollBack = append(rollBack, m)
+	}
+	return rollBack, nil
+}
+
+// Status of a migration as shown by "setupdb status"
+const (
+	statusApplied  = "applied"
+	statusPending  = "pending"
+	statusModified = "modified"
+	statusMissing  = "missing"
+)
+
+type migrationStatus struct {
+	version   string
+	name      string
+	status    string
+	appliedAt *time.Time
+}
+
+// Every known migration, applied or not, sorted by version
+func migrationStatuses(migrations []migration, applied []appliedMigration) []migrationStatus {
+	byVersion := map[string]appliedMigration{}
+	for _, a := range applied {
+		byVersion[a.version] = a
+	}
+
+	statuses := []migrationStatus{}
+	for _, m := range migrations {
+		s := migrationStatus{version: m.version, name: m.name, status: statusPending}
+		if a, ok := byVersion[m.version]; ok {
+			appliedAt := a.appliedAt
+			s.appliedAt = &appliedAt
+			s.status = statusApplied
+			if a.checksum != m.checksum {
+				s.status = statusModified
+			}
+			delete(byVersion, m.version)
+		}
+		statuses = append(statuses, s)
+	}
+	for _, a := range byVersion {
+		appliedAt := a.appliedAt
+		statuses = append(statuses, migrationStatus{version: a.version, name: a.name, status: statusMissing, appliedAt: &appliedAt})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].version < statuses[j].version })
+	return statuses
+}
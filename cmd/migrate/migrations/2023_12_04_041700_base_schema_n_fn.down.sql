@@ -0,0 +1,3 @@
+-- The ruok schema is kept, it holds the record of applied migrations
+DROP FUNCTION IF EXISTS ruok.get_ssl_conn_version(text);
+DROP FUNCTION IF EXISTS ruok.micro_unix_now();
@@ -0,0 +1,3 @@
+-- Roles are shared by the whole cluster, so "admin" is kept
+DROP TABLE IF EXISTS ruok.job_results;
+DROP TABLE IF EXISTS ruok.jobs;
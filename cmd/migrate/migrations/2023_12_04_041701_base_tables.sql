@@ -1,4 +1,21 @@
-CREATE ROLE admin;  -- Administrator
+-- Administrator
+DO
+$do$
+BEGIN
+   IF EXISTS (
+      SELECT FROM pg_catalog.pg_roles
+      WHERE rolname = 'admin') THEN
+      RAISE NOTICE 'Role "admin" already exists. Skipping.';
+   ELSE
+      BEGIN   -- nested block
+         CREATE ROLE admin;
+      EXCEPTION
+         WHEN duplicate_object THEN
+            RAISE NOTICE 'Role "admin" was just created by a concurrent transaction. Skipping.';
+      END;
+   END IF;
+END
+$do$;
 
 -- Create table for Jobs
 CREATE TABLE IF NOT EXISTS ruok.jobs (
@@ -30,6 +47,7 @@ CREATE TABLE IF NOT EXISTS ruok.jobs (
 );
 
 ALTER TABLE ruok.jobs ENABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS admin_all_jobs ON ruok.jobs;
 CREATE POLICY admin_all_jobs ON ruok.jobs TO admin USING (true) WITH CHECK (true);
 
 
@@ -57,4 +75,5 @@ CREATE TABLE IF NOT EXISTS ruok.job_results (
 );
 
 ALTER TABLE ruok.job_results ENABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS admin_all_job_results ON ruok.jobs;
 CREATE POLICY admin_all_job_results ON ruok.jobs TO admin USING (true) WITH CHECK (true);
@@ -0,0 +1,18 @@
+-- Roles are shared by the whole cluster, so they are kept without access to ruok
+DROP POLICY IF EXISTS scheduler_select_jobs ON ruok.jobs;
+DROP POLICY IF EXISTS scheduler_update_jobs ON ruok.jobs;
+DROP POLICY IF EXISTS scheduler_insert_jobs ON ruok.jobs;
+DROP POLICY IF EXISTS scheduler_insert_job_results ON ruok.job_results;
+DROP POLICY IF EXISTS scheduler_select_job_results ON ruok.job_results;
+
+DROP POLICY IF EXISTS jobs_manager_insert_jobs ON ruok.jobs;
+DROP POLICY IF EXISTS jobs_manager_select_jobs ON ruok.jobs;
+DROP POLICY IF EXISTS jobs_manager_update_jobs ON ruok.jobs;
+DROP POLICY IF EXISTS jobs_manager_select_job_results ON ruok.job_results;
+
+REVOKE ALL ON ruok.jobs FROM RUOK_SCHEDULER_ROLE, RUOK_JOBS_MANAGER;
+REVOKE ALL ON ruok.job_results FROM RUOK_SCHEDULER_ROLE, RUOK_JOBS_MANAGER;
+REVOKE EXECUTE ON FUNCTION ruok.get_ssl_conn_version(text) FROM RUOK_SCHEDULER_ROLE, RUOK_JOBS_MANAGER;
+REVOKE EXECUTE ON FUNCTION ruok.micro_unix_now() FROM RUOK_SCHEDULER_ROLE, RUOK_JOBS_MANAGER;
+REVOKE EXECUTE ON FUNCTION pg_notify(text, text) FROM RUOK_JOBS_MANAGER;
+REVOKE USAGE ON SCHEMA ruok FROM RUOK_SCHEDULER_ROLE;
@@ -0,0 +1,9 @@
+-- Roles are shared by the whole cluster, so they are kept without access to ruok
+DROP POLICY IF EXISTS testing_user_delete_job_results ON ruok.job_results;
+DROP POLICY IF EXISTS testing_user_insert_job_results ON ruok.job_results;
+DROP POLICY IF EXISTS testing_user_delete_jobs ON ruok.jobs;
+DROP POLICY IF EXISTS testing_user_insert_jobs ON ruok.jobs;
+
+REVOKE ALL ON ruok.jobs FROM RUOK_SEED_AND_DROP;
+REVOKE ALL ON ruok.job_results FROM RUOK_SEED_AND_DROP;
+REVOKE USAGE ON SCHEMA ruok FROM RUOK_SEED_AND_DROP;
@@ -0,0 +1 @@
+ALTER TABLE ruok.jobs DROP COLUMN IF EXISTS timezone;
@@ -0,0 +1,2 @@
+-- IANA timezone in which the cron expression of the job is evaluated
+ALTER TABLE ruok.jobs ADD COLUMN IF NOT EXISTS timezone text DEFAULT 'UTC' NOT NULL;
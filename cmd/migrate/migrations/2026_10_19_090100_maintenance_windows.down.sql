@@ -0,0 +1 @@
+DROP TABLE IF EXISTS ruok.maintenance_windows;
@@ -0,0 +1,48 @@
+-- Create table for Maintenance Windows
+CREATE TABLE IF NOT EXISTS ruok.maintenance_windows (
+	id uuid PRIMARY KEY NOT NULL,
+	window_name text NOT NULL,
+	-- skip | mute
+	mode text NOT NULL,
+	-- recurring windows use cron_exp_string + duration_seconds
+	cron_exp_string text,
+	timezone text DEFAULT 'UTC' NOT NULL,
+	duration_seconds int,
+	-- absolute windows use starts_at + ends_at (unix microseconds)
+	starts_at bigint,
+	ends_at bigint,
+	-- empty means every job
+	job_ids uuid[] DEFAULT '{}' NOT NULL,
+	created_at bigint DEFAULT ruok.micro_unix_now() NOT NULL,
+	deleted_at bigint
+);
+
+ALTER TABLE ruok.maintenance_windows ENABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS admin_all_maintenance_windows ON ruok.maintenance_windows;
+CREATE POLICY admin_all_maintenance_windows ON ruok.maintenance_windows TO admin USING (true) WITH CHECK (true);
+
+GRANT SELECT,INSERT,UPDATE ON ruok.maintenance_windows to RUOK_SCHEDULER_ROLE;
+
+DROP POLICY IF EXISTS scheduler_all_maintenance_windows ON ruok.maintenance_windows;
+CREATE POLICY scheduler_all_maintenance_windows ON ruok.maintenance_windows TO RUOK_SCHEDULER_ROLE USING (true) WITH CHECK (true);
+
+GRANT SELECT,INSERT,UPDATE ON ruok.maintenance_windows to RUOK_JOBS_MANAGER;
+
+DROP POLICY IF EXISTS jobs_manager_all_maintenance_windows ON ruok.maintenance_windows;
+CREATE POLICY jobs_manager_all_maintenance_windows ON ruok.maintenance_windows TO RUOK_JOBS_MANAGER USING (true) WITH CHECK (true);
+
+-- Only when the testing role exists (development/testing)
+DO
+$do$
+BEGIN
+   IF EXISTS (
+      SELECT FROM pg_catalog.pg_roles
+      WHERE rolname = 'ruok_seed_and_drop') THEN
+      GRANT INSERT,DELETE ON ruok.maintenance_windows to RUOK_SEED_AND_DROP;
+      DROP POLICY IF EXISTS testing_user_delete_maintenance_windows ON ruok.maintenance_windows;
+      CREATE POLICY testing_user_delete_maintenance_windows ON ruok.maintenance_windows FOR DELETE TO RUOK_SEED_AND_DROP USING (true);
+      DROP POLICY IF EXISTS testing_user_insert_maintenance_windows ON ruok.maintenance_windows;
+      CREATE POLICY testing_user_insert_maintenance_windows ON ruok.maintenance_windows FOR INSERT TO RUOK_SEED_AND_DROP WITH CHECK (true);
+   END IF;
+END
+$do$;
@@ -0,0 +1,2 @@
+DROP POLICY IF EXISTS scheduler_select_released_jobs ON ruok.jobs;
+DROP POLICY IF EXISTS scheduler_release_jobs ON ruok.jobs;
@@ -0,0 +1,14 @@
+-- Paused and deleted jobs are released, so schedulers need to see and update unclaimed jobs in any status
+DROP POLICY IF EXISTS scheduler_select_released_jobs ON ruok.jobs;
+CREATE POLICY scheduler_select_released_jobs ON ruok.jobs FOR SELECT TO RUOK_SCHEDULER_ROLE USING (
+	claimed_by IS null
+);
+
+-- Allows releasing our own jobs (or unclaimed ones) while changing their status
+DROP POLICY IF EXISTS scheduler_release_jobs ON ruok.jobs;
+CREATE POLICY scheduler_release_jobs ON ruok.jobs FOR UPDATE TO RUOK_SCHEDULER_ROLE USING (
+	claimed_by IS null
+	OR claimed_by = current_setting('application_name')
+) WITH CHECK (
+	claimed_by IS null
+);
@@ -0,0 +1,2 @@
+DROP POLICY IF EXISTS scheduler_select_manual_job_results ON ruok.job_results;
+ALTER TABLE ruok.job_results DROP COLUMN IF EXISTS trigger;
@@ -0,0 +1,8 @@
+-- What caused an execution: 'schedule' or 'manual'
+ALTER TABLE ruok.job_results ADD COLUMN IF NOT EXISTS trigger text DEFAULT 'schedule' NOT NULL;
+
+-- Manual runs may be executed by another scheduler, so whoever requested it can wait for its result
+DROP POLICY IF EXISTS scheduler_select_manual_job_results ON ruok.job_results;
+CREATE POLICY scheduler_select_manual_job_results ON ruok.job_results FOR SELECT TO RUOK_SCHEDULER_ROLE USING (
+	trigger = 'manual'
+);
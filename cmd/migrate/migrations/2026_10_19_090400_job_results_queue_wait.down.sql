@@ -0,0 +1 @@
+ALTER TABLE ruok.job_results DROP COLUMN IF EXISTS queue_wait;
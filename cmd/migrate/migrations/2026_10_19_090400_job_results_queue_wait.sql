@@ -0,0 +1,2 @@
+-- Microseconds an execution waited for a free worker before the request was sent
+ALTER TABLE ruok.job_results ADD COLUMN IF NOT EXISTS queue_wait bigint DEFAULT 0 NOT NULL;
@@ -0,0 +1,54 @@
+-- Moves results back into a single table, keeping grants and policies
+DO
+$do$
+DECLARE
+	p record;
+	g record;
+	definition text;
+BEGIN
+	IF (SELECT relkind FROM pg_class WHERE oid = 'ruok.job_results'::regclass) <> 'p' THEN
+		RAISE NOTICE 'ruok.job_results is not partitioned. Skipping.';
+		RETURN;
+	END IF;
+
+	CREATE TABLE ruok.job_results_unpartitioned (LIKE ruok.job_results INCLUDING DEFAULTS INCLUDING CONSTRAINTS);
+	ALTER TABLE ruok.job_results_unpartitioned ADD CONSTRAINT job_results_unpartitioned_pkey PRIMARY KEY (id);
+	ALTER TABLE ruok.job_results_unpartitioned ENABLE ROW LEVEL SECURITY;
+
+	FOR g IN
+		SELECT grantee, privilege_type FROM information_schema.role_table_grants
+		WHERE table_schema = 'ruok' AND table_name = 'job_results'
+		AND grantee <> current_user
+	LOOP
+		EXECUTE format('GRANT %s ON ruok.job_results_unpartitioned TO %I', g.privilege_type, g.grantee);
+	END LOOP;
+
+	FOR p IN
+		SELECT * FROM pg_policies
+		WHERE schemaname = 'ruok' AND tablename = 'job_results'
+	LOOP
+		definition := format(
+			'CREATE POLICY %I ON ruok.job_results_unpartitioned AS %s FOR %s TO %s',
+			p.policyname,
+			p.permissive,
+			p.cmd,
+			(SELECT string_agg(quote_ident(r), ', ') FROM unnest(p.roles) r)
+		);
+		IF p.qual IS NOT NULL THEN
+			definition := definition || format(' USING (%s)', p.qual);
+		END IF;
+		IF p.with_check IS NOT NULL THEN
+			definition := definition || format(' WITH CHECK (%s)', p.with_check);
+		END IF;
+		EXECUTE definition;
+	END LOOP;
+
+	INSERT INTO ruok.job_results_unpartitioned SELECT * FROM ruok.job_results;
+	DROP TABLE ruok.job_results;
+	ALTER TABLE ruok.job_results_unpartitioned RENAME TO job_results;
+	ALTER TABLE ruok.job_results RENAME CONSTRAINT job_results_unpartitioned_pkey TO job_results_pkey;
+END
+$do$;
+
+DROP FUNCTION IF EXISTS ruok.drop_empty_job_results_partitions();
+DROP FUNCTION IF EXISTS ruok.create_job_results_partition(timestamptz);
@@ -0,0 +1,153 @@
+-- Execution results are partitioned by month of execution_time (unix microseconds)
+-- so compacted months can be dropped instead of deleted row by row.
+-- Partitions are named job_results_pYYYYMM and rows outside of them land in job_results_default.
+
+-- Creates the partition holding the month of the given time if it is missing
+CREATE OR REPLACE FUNCTION ruok.create_job_results_partition(at_time timestamptz) RETURNS text AS
+$$
+DECLARE
+	month_start timestamptz := date_trunc('month', at_time, 'UTC');
+	partition_name text := 'job_results_p' || to_char(month_start AT TIME ZONE 'UTC', 'YYYYMM');
+	lower_bound bigint := (EXTRACT(epoch FROM month_start) * 1000000)::bigint;
+	upper_bound bigint := (EXTRACT(epoch FROM month_start + interval '1 month') * 1000000)::bigint;
+	moved boolean := false;
+BEGIN
+	IF to_regclass('ruok.' || partition_name) IS NOT NULL THEN
+		RETURN partition_name;
+	END IF;
+
+	-- rows of this month in the default partition would block the new one
+	IF to_regclass('ruok.job_results_default') IS NOT NULL AND EXISTS (
+		SELECT FROM ruok.job_results_default
+		WHERE execution_time >= lower_bound AND execution_time < upper_bound
+	) THEN
+		CREATE TEMP TABLE job_results_moving ON COMMIT DROP AS
+			SELECT * FROM ruok.job_results_default
+			WHERE execution_time >= lower_bound AND execution_time < upper_bound;
+		DELETE FROM ruok.job_results_default
+			WHERE execution_time >= lower_bound AND execution_time < upper_bound;
+		moved := true;
+	END IF;
+
+	EXECUTE format(
+		'CREATE TABLE ruok.%I PARTITION OF ruok.job_results FOR VALUES FROM (%s) TO (%s)',
+		partition_name, lower_bound, upper_bound
+	);
+
+	IF moved THEN
+		INSERT INTO ruok.job_results SELECT * FROM job_results_moving;
+		DROP TABLE job_results_moving;
+	END IF;
+
+	RETURN partition_name;
+END;
+$$
+LANGUAGE plpgsql SECURITY DEFINER SET search_path = pg_catalog, pg_temp;
+
+REVOKE ALL ON FUNCTION ruok.create_job_results_partition(timestamptz) FROM PUBLIC;
+
+-- Drops past partitions without rows, keeping the current and previous month
+CREATE OR REPLACE FUNCTION ruok.drop_empty_job_results_partitions() RETURNS int AS
+$$
+DECLARE
+	keep_from timestamptz := date_trunc('month', now() - interval '1 month', 'UTC');
+	p record;
+	is_empty boolean;
+	dropped int := 0;
+BEGIN
+	FOR p IN
+		SELECT c.relname FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		WHERE i.inhparent = 'ruok.job_results'::regclass
+		AND c.relname ~ '^job_results_p[0-9]{6}$'
+	LOOP
+		IF to_date(substring(p.relname FROM 14), 'YYYYMM')::timestamp AT TIME ZONE 'UTC' >= keep_from THEN
+			CONTINUE;
+		END IF;
+		EXECUTE format('SELECT NOT EXISTS (SELECT FROM ruok.%I)', p.relname) INTO is_empty;
+		IF is_empty THEN
+			EXECUTE format('DROP TABLE ruok.%I', p.relname);
+			dropped := dropped + 1;
+		END IF;
+	END LOOP;
+	RETURN dropped;
+END;
+$$
+LANGUAGE plpgsql SECURITY DEFINER SET search_path = pg_catalog, pg_temp;
+
+REVOKE ALL ON FUNCTION ruok.drop_empty_job_results_partitions() FROM PUBLIC;
+
+-- Moves existing results into a partitioned table, keeping grants and policies
+DO
+$do$
+DECLARE
+	p record;
+	g record;
+	definition text;
+	oldest timestamptz;
+	m timestamptz;
+BEGIN
+	IF (SELECT relkind FROM pg_class WHERE oid = 'ruok.job_results'::regclass) = 'p' THEN
+		RAISE NOTICE 'ruok.job_results is already partitioned. Skipping.';
+		RETURN;
+	END IF;
+
+	ALTER TABLE ruok.job_results RENAME TO job_results_unpartitioned;
+	ALTER TABLE ruok.job_results_unpartitioned RENAME CONSTRAINT job_results_pkey TO job_results_unpartitioned_pkey;
+
+	CREATE TABLE ruok.job_results (LIKE ruok.job_results_unpartitioned INCLUDING DEFAULTS INCLUDING CONSTRAINTS)
+		PARTITION BY RANGE (execution_time);
+	-- the partition key must be part of the primary key
+	ALTER TABLE ruok.job_results ADD PRIMARY KEY (id, execution_time);
+	CREATE INDEX IF NOT EXISTS job_results_job_id_execution_time_idx ON ruok.job_results (job_id, execution_time);
+	CREATE TABLE ruok.job_results_default PARTITION OF ruok.job_results DEFAULT;
+	ALTER TABLE ruok.job_results ENABLE ROW LEVEL SECURITY;
+
+	FOR g IN
+		SELECT grantee, privilege_type FROM information_schema.role_table_grants
+		WHERE table_schema = 'ruok' AND table_name = 'job_results_unpartitioned'
+		AND grantee <> current_user
+	LOOP
+		EXECUTE format('GRANT %s ON ruok.job_results TO %I', g.privilege_type, g.grantee);
+	END LOOP;
+
+	FOR p IN
+		SELECT * FROM pg_policies
+		WHERE schemaname = 'ruok' AND tablename = 'job_results_unpartitioned'
+	LOOP
+		definition := format(
+			'CREATE POLICY %I ON ruok.job_results AS %s FOR %s TO %s',
+			p.policyname,
+			p.permissive,
+			p.cmd,
+			(SELECT string_agg(quote_ident(r), ', ') FROM unnest(p.roles) r)
+		);
+		IF p.qual IS NOT NULL THEN
+			definition := definition || format(' USING (%s)', p.qual);
+		END IF;
+		IF p.with_check IS NOT NULL THEN
+			definition := definition || format(' WITH CHECK (%s)', p.with_check);
+		END IF;
+		EXECUTE definition;
+	END LOOP;
+
+	-- older rows go to the default partition
+	SELECT greatest(
+		to_timestamp(min(execution_time) / 1000000.0),
+		now() - interval '2 years'
+	) INTO oldest FROM ruok.job_results_unpartitioned;
+
+	FOR m IN
+		SELECT generate_series(
+			date_trunc('month', coalesce(oldest, now()), 'UTC'),
+			now() + interval '1 month',
+			interval '1 month'
+		)
+	LOOP
+		PERFORM ruok.create_job_results_partition(m);
+	END LOOP;
+
+	INSERT INTO ruok.job_results SELECT * FROM ruok.job_results_unpartitioned;
+	DROP TABLE ruok.job_results_unpartitioned;
+END
+$do$;
@@ -0,0 +1,3 @@
+DROP FUNCTION IF EXISTS ruok.compact_job_results(int, int);
+DROP TABLE IF EXISTS ruok.job_results_aggregates;
+ALTER TABLE ruok.jobs DROP COLUMN IF EXISTS results_retention_days;
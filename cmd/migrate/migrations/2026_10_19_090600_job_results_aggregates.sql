@@ -0,0 +1,119 @@
+-- Days execution results of a job are kept before being compacted. NULL uses the global setting, 0 keeps them forever
+ALTER TABLE ruok.jobs ADD COLUMN IF NOT EXISTS results_retention_days int;
+
+-- Compacted execution results
+CREATE TABLE IF NOT EXISTS ruok.job_results_aggregates (
+	job_id uuid NOT NULL,
+	-- hour | day
+	granularity text NOT NULL,
+	-- unix microseconds, buckets are aligned to UTC
+	bucket_start bigint NOT NULL,
+	executions int NOT NULL,
+	failures int NOT NULL,
+	-- microseconds between the execution and the response
+	p50_latency bigint,
+	p95_latency bigint,
+	created_at bigint DEFAULT ruok.micro_unix_now() NOT NULL,
+	PRIMARY KEY (job_id, granularity, bucket_start)
+);
+
+ALTER TABLE ruok.job_results_aggregates ENABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS admin_all_job_results_aggregates ON ruok.job_results_aggregates;
+CREATE POLICY admin_all_job_results_aggregates ON ruok.job_results_aggregates TO admin USING (true) WITH CHECK (true);
+
+GRANT SELECT ON ruok.job_results_aggregates to RUOK_SCHEDULER_ROLE;
+
+-- Same visibility as the jobs themselves
+DROP POLICY IF EXISTS scheduler_select_job_results_aggregates ON ruok.job_results_aggregates;
+CREATE POLICY scheduler_select_job_results_aggregates ON ruok.job_results_aggregates FOR SELECT TO RUOK_SCHEDULER_ROLE USING (
+	EXISTS (SELECT FROM ruok.jobs WHERE jobs.id = job_results_aggregates.job_id)
+);
+
+GRANT SELECT ON ruok.job_results_aggregates to RUOK_JOBS_MANAGER;
+
+DROP POLICY IF EXISTS jobs_manager_select_job_results_aggregates ON ruok.job_results_aggregates;
+CREATE POLICY jobs_manager_select_job_results_aggregates ON ruok.job_results_aggregates FOR SELECT TO RUOK_JOBS_MANAGER USING (
+	EXISTS (SELECT FROM ruok.jobs WHERE jobs.id = job_results_aggregates.job_id)
+);
+
+-- Only when the testing role exists (development/testing)
+DO
+$do$
+BEGIN
+   IF EXISTS (
+      SELECT FROM pg_catalog.pg_roles
+      WHERE rolname = 'ruok_seed_and_drop') THEN
+      GRANT INSERT,DELETE ON ruok.job_results_aggregates to RUOK_SEED_AND_DROP;
+      DROP POLICY IF EXISTS testing_user_delete_job_results_aggregates ON ruok.job_results_aggregates;
+      CREATE POLICY testing_user_delete_job_results_aggregates ON ruok.job_results_aggregates FOR DELETE TO RUOK_SEED_AND_DROP USING (true);
+      DROP POLICY IF EXISTS testing_user_insert_job_results_aggregates ON ruok.job_results_aggregates;
+      CREATE POLICY testing_user_insert_job_results_aggregates ON ruok.job_results_aggregates FOR INSERT TO RUOK_SEED_AND_DROP WITH CHECK (true);
+   END IF;
+END
+$do$;
+
+-- Rolls execution results older than their retention into hourly and daily aggregates and deletes them.
+-- Results are compacted whole UTC days at a time so daily buckets are complete.
+-- Returns how many results were compacted, or -1 if another compaction is running.
+CREATE OR REPLACE FUNCTION ruok.compact_job_results(retention_days int, hourly_retention_days int) RETURNS bigint AS
+$$
+DECLARE
+	compacted bigint := 0;
+BEGIN
+	IF NOT pg_try_advisory_xact_lock(hashtext('ruok.compact_job_results')) THEN
+		RETURN -1;
+	END IF;
+
+	PERFORM ruok.create_job_results_partition(now());
+	PERFORM ruok.create_job_results_partition(now() + interval '1 month');
+
+	CREATE TEMP TABLE expired_job_results ON COMMIT DROP AS
+		SELECT r.id, r.job_id, r.execution_time, r.succeeded,
+			CASE WHEN r.last_response_at >= r.execution_time THEN r.last_response_at - r.execution_time END AS latency
+		FROM ruok.job_results r
+		LEFT JOIN ruok.jobs j ON j.id = r.job_id
+		WHERE coalesce(j.results_retention_days, retention_days) > 0
+		AND r.execution_time < (EXTRACT(epoch FROM date_trunc(
+			'day',
+			now() - make_interval(days => coalesce(j.results_retention_days, retention_days)),
+			'UTC'
+		)) * 1000000)::bigint;
+
+	-- buckets may already exist when late results are compacted, percentiles of the bigger group win
+	INSERT INTO ruok.job_results_aggregates AS a (job_id, granularity, bucket_start, executions, failures, p50_latency, p95_latency)
+	SELECT job_id, g.granularity, execution_time - (execution_time % g.size) AS bucket_start,
+		count(*),
+		count(*) FILTER (WHERE succeeded IS DISTINCT FROM 'ok'),
+		(percentile_cont(0.5) WITHIN GROUP (ORDER BY latency))::bigint,
+		(percentile_cont(0.95) WITHIN GROUP (ORDER BY latency))::bigint
+	FROM expired_job_results
+	CROSS JOIN (VALUES ('hour', 3600000000::bigint), ('day', 86400000000::bigint)) AS g(granularity, size)
+	GROUP BY job_id, g.granularity, bucket_start
+	ON CONFLICT (job_id, granularity, bucket_start) DO UPDATE SET
+		executions = a.executions + excluded.executions,
+		failures = a.failures + excluded.failures,
+		p50_latency = CASE WHEN excluded.executions > a.executions THEN excluded.p50_latency ELSE a.p50_latency END,
+		p95_latency = CASE WHEN excluded.executions > a.executions THEN excluded.p95_latency ELSE a.p95_latency END;
+
+	DELETE FROM ruok.job_results r
+	USING expired_job_results e
+	WHERE r.id = e.id AND r.execution_time = e.execution_time;
+	GET DIAGNOSTICS compacted = ROW_COUNT;
+
+	DROP TABLE expired_job_results;
+
+	IF hourly_retention_days > 0 THEN
+		DELETE FROM ruok.job_results_aggregates
+		WHERE granularity = 'hour'
+		AND bucket_start < (EXTRACT(epoch FROM now() - make_interval(days => hourly_retention_days)) * 1000000)::bigint;
+	END IF;
+
+	PERFORM ruok.drop_empty_job_results_partitions();
+
+	RETURN compacted;
+END;
+$$
+LANGUAGE plpgsql SECURITY DEFINER SET search_path = pg_catalog, pg_temp;
+
+REVOKE ALL ON FUNCTION ruok.compact_job_results(int, int) FROM PUBLIC;
+GRANT EXECUTE ON FUNCTION ruok.compact_job_results(int, int) to RUOK_SCHEDULER_ROLE;
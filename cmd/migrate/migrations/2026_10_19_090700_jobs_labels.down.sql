@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS ruok.jobs_labels_idx;
+ALTER TABLE ruok.jobs DROP COLUMN IF EXISTS labels;
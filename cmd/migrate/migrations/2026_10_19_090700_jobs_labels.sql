@@ -0,0 +1,5 @@
+-- Key/value pairs like {"env": "prod", "team": "payments"} used to select jobs
+ALTER TABLE ruok.jobs ADD COLUMN IF NOT EXISTS labels jsonb DEFAULT '{}' NOT NULL;
+
+-- Selectors use @> and ? which can be answered with this index
+CREATE INDEX IF NOT EXISTS jobs_labels_idx ON ruok.jobs USING gin (labels);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS ruok.job_locations;
+ALTER TABLE ruok.job_results DROP COLUMN IF EXISTS location;
+ALTER TABLE ruok.jobs DROP COLUMN IF EXISTS alert_quorum;
+ALTER TABLE ruok.jobs DROP COLUMN IF EXISTS locations;
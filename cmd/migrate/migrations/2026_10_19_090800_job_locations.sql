@@ -0,0 +1,61 @@
+-- Locations the job runs from, like {eu-west,us-east}. Every one of them runs its own copy, empty runs it once anywhere
+ALTER TABLE ruok.jobs ADD COLUMN IF NOT EXISTS locations text[] DEFAULT '{}' NOT NULL;
+-- How many locations must fail the same round before alerting. NULL alerts on any failure
+ALTER TABLE ruok.jobs ADD COLUMN IF NOT EXISTS alert_quorum int;
+
+-- Location that ran the execution, NULL for jobs without locations
+ALTER TABLE ruok.job_results ADD COLUMN IF NOT EXISTS location text;
+
+-- Copies of the jobs with locations. Their jobs row is never claimed, the scheduler of each location claims a row here instead
+CREATE TABLE IF NOT EXISTS ruok.job_locations (
+	job_id uuid NOT NULL,
+	location text NOT NULL,
+	claimed_by text,
+	-- unix microseconds of the last execution from this location
+	last_execution bigint,
+	-- TRUE while the last execution from this location failed
+	failing boolean DEFAULT false NOT NULL,
+	PRIMARY KEY (job_id, location)
+);
+
+ALTER TABLE ruok.job_locations ENABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS admin_all_job_locations ON ruok.job_locations;
+CREATE POLICY admin_all_job_locations ON ruok.job_locations TO admin USING (true) WITH CHECK (true);
+
+GRANT SELECT,INSERT,UPDATE ON ruok.job_locations to RUOK_SCHEDULER_ROLE;
+
+-- Every scheduler sees every location, the quorum is counted across all of them
+DROP POLICY IF EXISTS scheduler_select_job_locations ON ruok.job_locations;
+CREATE POLICY scheduler_select_job_locations ON ruok.job_locations FOR SELECT TO RUOK_SCHEDULER_ROLE USING (true);
+
+-- Claims are only taken from unclaimed rows, but any scheduler can release them when the job is paused or deleted
+DROP POLICY IF EXISTS scheduler_insert_job_locations ON ruok.job_locations;
+CREATE POLICY scheduler_insert_job_locations ON ruok.job_locations FOR INSERT TO RUOK_SCHEDULER_ROLE WITH CHECK (
+	claimed_by IS NULL OR claimed_by = current_setting('application_name')
+);
+
+DROP POLICY IF EXISTS scheduler_update_job_locations ON ruok.job_locations;
+CREATE POLICY scheduler_update_job_locations ON ruok.job_locations FOR UPDATE TO RUOK_SCHEDULER_ROLE USING (true) WITH CHECK (
+	claimed_by IS NULL OR claimed_by = current_setting('application_name')
+);
+
+GRANT SELECT,INSERT,UPDATE ON ruok.job_locations to RUOK_JOBS_MANAGER;
+
+DROP POLICY IF EXISTS jobs_manager_all_job_locations ON ruok.job_locations;
+CREATE POLICY jobs_manager_all_job_locations ON ruok.job_locations TO RUOK_JOBS_MANAGER USING (true) WITH CHECK (true);
+
+-- Only when the testing role exists (development/testing)
+DO
+$do$
+BEGIN
+   IF EXISTS (
+      SELECT FROM pg_catalog.pg_roles
+      WHERE rolname = 'ruok_seed_and_drop') THEN
+      GRANT INSERT,DELETE ON ruok.job_locations to RUOK_SEED_AND_DROP;
+      DROP POLICY IF EXISTS testing_user_delete_job_locations ON ruok.job_locations;
+      CREATE POLICY testing_user_delete_job_locations ON ruok.job_locations FOR DELETE TO RUOK_SEED_AND_DROP USING (true);
+      DROP POLICY IF EXISTS testing_user_insert_job_locations ON ruok.job_locations;
+      CREATE POLICY testing_user_insert_job_locations ON ruok.job_locations FOR INSERT TO RUOK_SEED_AND_DROP WITH CHECK (true);
+   END IF;
+END
+$do$;
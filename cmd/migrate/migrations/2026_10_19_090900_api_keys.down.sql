@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS ruok.api_keys;
+ALTER TABLE ruok.jobs DROP COLUMN IF EXISTS updated_by;
@@ -0,0 +1,46 @@
+-- Keys used to call the http API. Only a sha256 of the key is kept, the key is shown once when it is created
+CREATE TABLE IF NOT EXISTS ruok.api_keys (
+	id uuid PRIMARY KEY NOT NULL,
+	key_name text NOT NULL,
+	-- start of the key, enough to tell keys apart without storing them
+	prefix text NOT NULL,
+	key_hash text NOT NULL UNIQUE,
+	-- read | write | admin
+	scope text NOT NULL,
+	created_at bigint DEFAULT ruok.micro_unix_now() NOT NULL,
+	revoked_at bigint
+);
+
+-- Who made the last change to the job through the API, like "key:deploys"
+ALTER TABLE ruok.jobs ADD COLUMN IF NOT EXISTS updated_by text;
+
+ALTER TABLE ruok.api_keys ENABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS admin_all_api_keys ON ruok.api_keys;
+CREATE POLICY admin_all_api_keys ON ruok.api_keys TO admin USING (true) WITH CHECK (true);
+
+-- Schedulers serve the API, so they check keys but can't create or revoke them
+GRANT SELECT ON ruok.api_keys to RUOK_SCHEDULER_ROLE;
+
+DROP POLICY IF EXISTS scheduler_select_api_keys ON ruok.api_keys;
+CREATE POLICY scheduler_select_api_keys ON ruok.api_keys FOR SELECT TO RUOK_SCHEDULER_ROLE USING (true);
+
+GRANT SELECT,INSERT,UPDATE ON ruok.api_keys to RUOK_JOBS_MANAGER;
+
+DROP POLICY IF EXISTS jobs_manager_all_api_keys ON ruok.api_keys;
+CREATE POLICY jobs_manager_all_api_keys ON ruok.api_keys TO RUOK_JOBS_MANAGER USING (true) WITH CHECK (true);
+
+-- Only when the testing role exists (development/testing)
+DO
+$do$
+BEGIN
+   IF EXISTS (
+      SELECT FROM pg_catalog.pg_roles
+      WHERE rolname = 'ruok_seed_and_drop') THEN
+      GRANT INSERT,DELETE ON ruok.api_keys to RUOK_SEED_AND_DROP;
+      DROP POLICY IF EXISTS testing_user_delete_api_keys ON ruok.api_keys;
+      CREATE POLICY testing_user_delete_api_keys ON ruok.api_keys FOR DELETE TO RUOK_SEED_AND_DROP USING (true);
+      DROP POLICY IF EXISTS testing_user_insert_api_keys ON ruok.api_keys;
+      CREATE POLICY testing_user_insert_api_keys ON ruok.api_keys FOR INSERT TO RUOK_SEED_AND_DROP WITH CHECK (true);
+   END IF;
+END
+$do$;
@@ -0,0 +1,51 @@
+-- Every change made to a job through the API, with what the job looked like before and after it.
+-- Revisions are numbered from 1 for every job, so any of them can be restored
+CREATE TABLE IF NOT EXISTS ruok.job_audit (
+	id uuid PRIMARY KEY NOT NULL,
+	job_id uuid NOT NULL,
+	revision integer NOT NULL,
+	-- create | update | update alerts | pause | resume | delete | restore
+	action text NOT NULL,
+	-- who made the change, like "key:deploys"
+	actor text,
+	created_at bigint DEFAULT ruok.micro_unix_now() NOT NULL,
+	-- empty when the job was created
+	before jsonb,
+	after jsonb NOT NULL,
+	UNIQUE (job_id, revision)
+);
+
+ALTER TABLE ruok.job_audit ENABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS admin_all_job_audit ON ruok.job_audit;
+CREATE POLICY admin_all_job_audit ON ruok.job_audit TO admin USING (true) WITH CHECK (true);
+
+-- Schedulers serve the API, so they record changes and read the history, but never rewrite it
+GRANT SELECT,INSERT ON ruok.job_audit to RUOK_SCHEDULER_ROLE;
+
+DROP POLICY IF EXISTS scheduler_select_job_audit ON ruok.job_audit;
+CREATE POLICY scheduler_select_job_audit ON ruok.job_audit FOR SELECT TO RUOK_SCHEDULER_ROLE USING (true);
+DROP POLICY IF EXISTS scheduler_insert_job_audit ON ruok.job_audit;
+CREATE POLICY scheduler_insert_job_audit ON ruok.job_audit FOR INSERT TO RUOK_SCHEDULER_ROLE WITH CHECK (true);
+
+GRANT SELECT,INSERT ON ruok.job_audit to RUOK_JOBS_MANAGER;
+
+DROP POLICY IF EXISTS jobs_manager_select_job_audit ON ruok.job_audit;
+CREATE POLICY jobs_manager_select_job_audit ON ruok.job_audit FOR SELECT TO RUOK_JOBS_MANAGER USING (true);
+DROP POLICY IF EXISTS jobs_manager_insert_job_audit ON ruok.job_audit;
+CREATE POLICY jobs_manager_insert_job_audit ON ruok.job_audit FOR INSERT TO RUOK_JOBS_MANAGER WITH CHECK (true);
+
+-- Only when the testing role exists (development/testing)
+DO
+$do$
+BEGIN
+   IF EXISTS (
+      SELECT FROM pg_catalog.pg_roles
+      WHERE rolname = 'ruok_seed_and_drop') THEN
+      GRANT INSERT,DELETE ON ruok.job_audit to RUOK_SEED_AND_DROP;
+      DROP POLICY IF EXISTS testing_user_delete_job_audit ON ruok.job_audit;
+      CREATE POLICY testing_user_delete_job_audit ON ruok.job_audit FOR DELETE TO RUOK_SEED_AND_DROP USING (true);
+      DROP POLICY IF EXISTS testing_user_insert_job_audit ON ruok.job_audit;
+      CREATE POLICY testing_user_insert_job_audit ON ruok.job_audit FOR INSERT TO RUOK_SEED_AND_DROP WITH CHECK (true);
+   END IF;
+END
+$do$;
@@ -0,0 +1,20 @@
+DROP POLICY IF EXISTS tenant_isolation_api_keys ON ruok.api_keys;
+DROP POLICY IF EXISTS tenant_isolation_job_results_aggregates ON ruok.job_results_aggregates;
+DROP POLICY IF EXISTS tenant_isolation_job_audit ON ruok.job_audit;
+DROP POLICY IF EXISTS tenant_isolation_job_locations ON ruok.job_locations;
+DROP POLICY IF EXISTS tenant_isolation_job_results ON ruok.job_results;
+DROP POLICY IF EXISTS tenant_isolation_jobs ON ruok.jobs;
+DROP TRIGGER IF EXISTS set_job_tenant ON ruok.job_results_aggregates;
+DROP TRIGGER IF EXISTS set_job_tenant ON ruok.job_audit;
+DROP TRIGGER IF EXISTS set_job_tenant ON ruok.job_locations;
+DROP TRIGGER IF EXISTS set_job_tenant ON ruok.job_results;
+DROP FUNCTION IF EXISTS ruok.set_job_tenant();
+ALTER TABLE ruok.api_keys DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE ruok.job_results_aggregates DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE ruok.job_audit DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE ruok.job_locations DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE ruok.job_results DROP COLUMN IF EXISTS tenant_id;
+DROP INDEX IF EXISTS ruok.jobs_tenant_id_idx;
+ALTER TABLE ruok.jobs DROP COLUMN IF EXISTS tenant_id;
+DROP FUNCTION IF EXISTS ruok.count_tenant_jobs();
+DROP FUNCTION IF EXISTS ruok.current_tenant();
@@ -0,0 +1,93 @@
+-- Tenant of the current session, set by the API with set_config('ruok.tenant', ...) for the requests of a tenant.
+-- NULL while it's not set, like on the connections schedulers claim and run jobs with, which see every tenant
+CREATE OR REPLACE FUNCTION ruok.current_tenant() RETURNS text AS
+$$
+	SELECT nullif(current_setting('ruok.tenant', true), '');
+$$
+LANGUAGE sql STABLE;
+
+GRANT EXECUTE ON FUNCTION ruok.current_tenant() to RUOK_SCHEDULER_ROLE;
+GRANT EXECUTE ON FUNCTION ruok.current_tenant() to RUOK_JOBS_MANAGER;
+
+-- Tenant the job belongs to, existing jobs go to the default one
+ALTER TABLE ruok.jobs ADD COLUMN IF NOT EXISTS tenant_id text DEFAULT 'default' NOT NULL;
+ALTER TABLE ruok.jobs ALTER COLUMN tenant_id SET DEFAULT coalesce(ruok.current_tenant(), 'default');
+CREATE INDEX IF NOT EXISTS jobs_tenant_id_idx ON ruok.jobs (tenant_id) WHERE deleted_at IS NULL;
+
+-- Rows that belong to a job take the tenant of the job when they are inserted, see ruok.set_job_tenant
+ALTER TABLE ruok.job_results ADD COLUMN IF NOT EXISTS tenant_id text DEFAULT 'default' NOT NULL;
+ALTER TABLE ruok.job_locations ADD COLUMN IF NOT EXISTS tenant_id text DEFAULT 'default' NOT NULL;
+ALTER TABLE ruok.job_audit ADD COLUMN IF NOT EXISTS tenant_id text DEFAULT 'default' NOT NULL;
+ALTER TABLE ruok.job_results_aggregates ADD COLUMN IF NOT EXISTS tenant_id text DEFAULT 'default' NOT NULL;
+
+UPDATE ruok.job_locations l SET tenant_id = j.tenant_id FROM ruok.jobs j WHERE j.id = l.job_id;
+UPDATE ruok.job_audit a SET tenant_id = j.tenant_id FROM ruok.jobs j WHERE j.id = a.job_id;
+UPDATE ruok.job_results_aggregates a SET tenant_id = j.tenant_id FROM ruok.jobs j WHERE j.id = a.job_id;
+
+-- Tenant whose callers use the key
+ALTER TABLE ruok.api_keys ADD COLUMN IF NOT EXISTS tenant_id text DEFAULT 'default' NOT NULL;
+
+-- Runs as its owner, so schedulers writing results of a job see its tenant whatever their policies let them see
+CREATE OR REPLACE FUNCTION ruok.set_job_tenant() RETURNS trigger AS
+$$
+BEGIN
+	NEW.tenant_id := coalesce((SELECT tenant_id FROM ruok.jobs WHERE id = NEW.job_id), NEW.tenant_id);
+	RETURN NEW;
+END;
+$$
+LANGUAGE plpgsql SECURITY DEFINER SET search_path = pg_catalog, pg_temp;
+REVOKE ALL ON FUNCTION ruok.set_job_tenant() FROM PUBLIC;
+
+DROP TRIGGER IF EXISTS set_job_tenant ON ruok.job_results;
+CREATE TRIGGER set_job_tenant BEFORE INSERT ON ruok.job_results FOR EACH ROW EXECUTE FUNCTION ruok.set_job_tenant();
+DROP TRIGGER IF EXISTS set_job_tenant ON ruok.job_locations;
+CREATE TRIGGER set_job_tenant BEFORE INSERT ON ruok.job_locations FOR EACH ROW EXECUTE FUNCTION ruok.set_job_tenant();
+DROP TRIGGER IF EXISTS set_job_tenant ON ruok.job_audit;
+CREATE TRIGGER set_job_tenant BEFORE INSERT ON ruok.job_audit FOR EACH ROW EXECUTE FUNCTION ruok.set_job_tenant();
+DROP TRIGGER IF EXISTS set_job_tenant ON ruok.job_results_aggregates;
+CREATE TRIGGER set_job_tenant BEFORE INSERT ON ruok.job_results_aggregates FOR EACH ROW EXECUTE FUNCTION ruok.set_job_tenant();
+
+-- Restrictive policies are added to the ones every role already has, so sessions of a tenant only see its rows
+DROP POLICY IF EXISTS tenant_isolation_jobs ON ruok.jobs;
+CREATE POLICY tenant_isolation_jobs ON ruok.jobs AS RESTRICTIVE TO RUOK_SCHEDULER_ROLE, RUOK_JOBS_MANAGER
+	USING (ruok.current_tenant() IS NULL OR tenant_id = ruok.current_tenant())
+	WITH CHECK (ruok.current_tenant() IS NULL OR tenant_id = ruok.current_tenant());
+
+DROP POLICY IF EXISTS tenant_isolation_job_results ON ruok.job_results;
+CREATE POLICY tenant_isolation_job_results ON ruok.job_results AS RESTRICTIVE TO RUOK_SCHEDULER_ROLE, RUOK_JOBS_MANAGER
+	USING (ruok.current_tenant() IS NULL OR tenant_id = ruok.current_tenant())
+	WITH CHECK (ruok.current_tenant() IS NULL OR tenant_id = ruok.current_tenant());
+
+DROP POLICY IF EXISTS tenant_isolation_job_locations ON ruok.job_locations;
+CREATE POLICY tenant_isolation_job_locations ON ruok.job_locations AS RESTRICTIVE TO RUOK_SCHEDULER_ROLE, RUOK_JOBS_MANAGER
+	USING (ruok.current_tenant() IS NULL OR tenant_id = ruok.current_tenant())
+	WITH CHECK (ruok.current_tenant() IS NULL OR tenant_id = ruok.current_tenant());
+
+DROP POLICY IF EXISTS tenant_isolation_job_audit ON ruok.job_audit;
+CREATE POLICY tenant_isolation_job_audit ON ruok.job_audit AS RESTRICTIVE TO RUOK_SCHEDULER_ROLE, RUOK_JOBS_MANAGER
+	USING (ruok.current_tenant() IS NULL OR tenant_id = ruok.current_tenant())
+	WITH CHECK (ruok.current_tenant() IS NULL OR tenant_id = ruok.current_tenant());
+
+DROP POLICY IF EXISTS tenant_isolation_job_results_aggregates ON ruok.job_results_aggregates;
+CREATE POLICY tenant_isolation_job_results_aggregates ON ruok.job_results_aggregates AS RESTRICTIVE TO RUOK_SCHEDULER_ROLE, RUOK_JOBS_MANAGER
+	USING (ruok.current_tenant() IS NULL OR tenant_id = ruok.current_tenant())
+	WITH CHECK (ruok.current_tenant() IS NULL OR tenant_id = ruok.current_tenant());
+
+-- Keys are checked before the tenant of the caller is known, so only listing and revoking them is isolated
+DROP POLICY IF EXISTS tenant_isolation_api_keys ON ruok.api_keys;
+CREATE POLICY tenant_isolation_api_keys ON ruok.api_keys AS RESTRICTIVE TO RUOK_SCHEDULER_ROLE, RUOK_JOBS_MANAGER
+	USING (ruok.current_tenant() IS NULL OR tenant_id = ruok.current_tenant())
+	WITH CHECK (ruok.current_tenant() IS NULL OR tenant_id = ruok.current_tenant());
+
+-- Jobs of the tenant of the session that are not deleted, for its quota.
+-- It runs as its owner because schedulers can only see the jobs they claimed
+CREATE OR REPLACE FUNCTION ruok.count_tenant_jobs() RETURNS bigint AS
+$$
+	SELECT count(*) FROM ruok.jobs
+	WHERE deleted_at IS NULL
+	AND (ruok.current_tenant() IS NULL OR tenant_id = ruok.current_tenant());
+$$
+LANGUAGE sql STABLE SECURITY DEFINER SET search_path = pg_catalog, pg_temp;
+REVOKE ALL ON FUNCTION ruok.count_tenant_jobs() FROM PUBLIC;
+GRANT EXECUTE ON FUNCTION ruok.count_tenant_jobs() to RUOK_SCHEDULER_ROLE;
+GRANT EXECUTE ON FUNCTION ruok.count_tenant_jobs() to RUOK_JOBS_MANAGER;
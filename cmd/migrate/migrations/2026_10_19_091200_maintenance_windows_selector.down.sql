@@ -0,0 +1 @@
+ALTER TABLE ruok.maintenance_windows DROP COLUMN IF EXISTS selector;
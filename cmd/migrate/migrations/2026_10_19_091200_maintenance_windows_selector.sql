@@ -0,0 +1,2 @@
+-- Label selector like "zone=eu,team=payments", empty means the window is not scoped by labels
+ALTER TABLE ruok.maintenance_windows ADD COLUMN IF NOT EXISTS selector text DEFAULT '' NOT NULL;
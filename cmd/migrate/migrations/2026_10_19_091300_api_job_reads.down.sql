@@ -0,0 +1,3 @@
+DROP FUNCTION IF EXISTS ruok.manual_run_result(uuid);
+DROP FUNCTION IF EXISTS ruok.job_executions(uuid, uuid, int);
+DROP FUNCTION IF EXISTS ruok.tenant_jobs();
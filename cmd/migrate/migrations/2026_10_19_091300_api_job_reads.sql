@@ -0,0 +1,40 @@
+-- Jobs of the tenant of the session that are not deleted, wherever they are claimed.
+-- The API reads jobs through it because schedulers can only see the jobs they claimed
+CREATE OR REPLACE FUNCTION ruok.tenant_jobs() RETURNS SETOF ruok.jobs AS
+$$
+	SELECT * FROM ruok.jobs
+	WHERE deleted_at IS NULL
+	AND (ruok.current_tenant() IS NULL OR tenant_id = ruok.current_tenant());
+$$
+LANGUAGE sql STABLE SECURITY DEFINER SET search_path = pg_catalog, pg_temp;
+REVOKE ALL ON FUNCTION ruok.tenant_jobs() FROM PUBLIC;
+GRANT EXECUTE ON FUNCTION ruok.tenant_jobs() to RUOK_SCHEDULER_ROLE;
+GRANT EXECUTE ON FUNCTION ruok.tenant_jobs() to RUOK_JOBS_MANAGER;
+
+-- Newest executions of a job of the tenant of the session from every scheduler, older than before_id when it is set
+CREATE OR REPLACE FUNCTION ruok.job_executions(of_job uuid, before_id uuid, max_rows int) RETURNS SETOF ruok.job_results AS
+$$
+	SELECT * FROM ruok.job_results r
+	WHERE r.job_id = of_job AND (before_id IS NULL OR r.id < before_id)
+	AND (ruok.current_tenant() IS NULL OR r.tenant_id = ruok.current_tenant())
+	ORDER BY r.id DESC
+	LIMIT max_rows;
+$$
+LANGUAGE sql STABLE SECURITY DEFINER SET search_path = pg_catalog, pg_temp;
+REVOKE ALL ON FUNCTION ruok.job_executions(uuid, uuid, int) FROM PUBLIC;
+GRANT EXECUTE ON FUNCTION ruok.job_executions(uuid, uuid, int) to RUOK_SCHEDULER_ROLE;
+GRANT EXECUTE ON FUNCTION ruok.job_executions(uuid, uuid, int) to RUOK_JOBS_MANAGER;
+
+-- Result of a manual run of a job of the tenant of the session, whichever scheduler ran it
+CREATE OR REPLACE FUNCTION ruok.manual_run_result(run_id uuid)
+RETURNS TABLE (last_status_code int, last_message varchar, last_response_at bigint) AS
+$$
+	SELECT r.last_status_code, r.last_message, r.last_response_at
+	FROM ruok.job_results r
+	WHERE r.id = run_id AND r.trigger = 'manual'
+	AND (ruok.current_tenant() IS NULL OR r.tenant_id = ruok.current_tenant());
+$$
+LANGUAGE sql STABLE SECURITY DEFINER SET search_path = pg_catalog, pg_temp;
+REVOKE ALL ON FUNCTION ruok.manual_run_result(uuid) FROM PUBLIC;
+GRANT EXECUTE ON FUNCTION ruok.manual_run_result(uuid) to RUOK_SCHEDULER_ROLE;
+GRANT EXECUTE ON FUNCTION ruok.manual_run_result(uuid) to RUOK_JOBS_MANAGER;
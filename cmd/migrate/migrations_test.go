@@ -0,0 +1,129 @@
+package migrations
+
+import (
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testMigrationsFS() fstest.MapFS {
+	return fstest.MapFS{
+		"m/2024_01_01_000000_first.sql":         {Data: []byte("CREATE TABLE a ();")},
+		"m/2024_01_01_000000_first.down.sql":    {Data: []byte("DROP TABLE a;")},
+		"m/2024_01_02_000000_dev_users.dev.sql": {Data: []byte("CREATE ROLE b;")},
+		"m/2024_01_03_000000_third.sql":         {Data: []byte("CREATE TABLE c ();")},
+	}
+}
+
+func applied(ms []migration) []appliedMigration {
+	applied := []appliedMigration{}
+	for _, m := range ms {
+		applied = append(applied, appliedMigration{version: m.version, name: m.name, checksum: m.checksum, appliedAt: time.Now()})
+	}
+	return applied
+}
+
+func TestLoadMigrations(t *testing.T) {
+	ms, err := loadMigrations(testMigrationsFS(), "m", true)
+	assert.NoError(t, err)
+	assert.Len(t, ms, 3)
+	assert.Equal(t, "2024_01_01_000000", ms[0].version)
+	assert.Equal(t, "first", ms[0].name)
+	assert.Equal(t, "DROP TABLE a;", ms[0].down)
+	assert.Equal(t, checksum("CREATE TABLE a ();"), ms[0].checksum)
+	assert.True(t, ms[1].devOnly)
+	assert.Equal(t, "third", ms[2].name)
+	assert.Empty(t, ms[2].down)
+
+	ms, err = loadMigrations(testMigrationsFS(), "m", false)
+	assert.NoError(t, err)
+	assert.Len(t, ms, 2, "development migrations should be left out")
+}
+
+func TestLoadMigrations_BadFiles(t *testing.T) {
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+	}{
+		{"BadName", fstest.MapFS{"m/first.sql": {Data: []byte("")}}},
+		{"DownWithoutUp", fstest.MapFS{"m/2024_01_01_000000_first.down.sql": {Data: []byte("")}}},
+		{"SharedVersion", fstest.MapFS{
+			"m/2024_01_01_000000_first.sql":  {Data: []byte("")},
+			"m/2024_01_01_000000_second.sql": {Data: []byte("")},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadMigrations(tt.files, "m", true)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	ms, err := loadMigrations(embedded, migrationsDir, true)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ms)
+	for _, m := range ms {
+		assert.NotEmpty(t, m.down, "migration %s_%s needs a down script", m.version, m.name)
+	}
+}
+
+func TestPendingMigrations(t *testing.T) {
+	ms, _ := loadMigrations(testMigrationsFS(), "m", true)
+	assert.Len(t, pendingMigrations(ms, nil), 3)
+
+	pending := pendingMigrations(ms, applied(ms[:1]))
+	assert.Len(t, pending, 2)
+	assert.Equal(t, ms[1].version, pending[0].version)
+
+	assert.Empty(t, pendingMigrations(ms, applied(ms)))
+}
+
+func TestVerifyChecksums(t *testing.T) {
+	ms, _ := loadMigrations(testMigrationsFS(), "m", true)
+	done := applied(ms)
+	assert.NoError(t, verifyChecksums(ms, done))
+
+	done[1].checksum = checksum("CREATE ROLE c;")
+	assert.ErrorContains(t, verifyChecksums(ms, done), "2024_01_02_000000_dev_users")
+}
+
+func TestMigrationsToRollBack(t *testing.T) {
+	ms, _ := loadMigrations(testMigrationsFS(), "m", true)
+	ms[1].down = "DROP ROLE b;"
+	ms[2].down = "DROP TABLE c;"
+
+	rollBack, err := migrationsToRollBack(ms, applied(ms[:2]), 5)
+	assert.NoError(t, err)
+	assert.Len(t, rollBack, 2, "can't roll back more than what was applied")
+	assert.Equal(t, ms[1].version, rollBack[0].version, "newest migrations roll back first")
+	assert.Equal(t, ms[0].version, rollBack[1].version)
+
+	_, err = migrationsToRollBack(ms, applied(ms), 0)
+	assert.Error(t, err)
+
+	ms[2].down = ""
+	_, err = migrationsToRollBack(ms, applied(ms), 1)
+	assert.ErrorContains(t, err, "no down script")
+
+	_, err = migrationsToRollBack(ms[:1], applied(ms[:2]), 1)
+	assert.ErrorContains(t, err, "missing")
+}
+
+func TestMigrationStatuses(t *testing.T) {
+	ms, _ := loadMigrations(testMigrationsFS(), "m", true)
+	done := applied(ms[:2])
+	done[1].checksum = "changed"
+	done = append(done, appliedMigration{version: "2023_01_01_000000", name: "gone", checksum: "x", appliedAt: time.Now()})
+
+	statuses := migrationStatuses(ms, done)
+	got := []string{}
+	for _, s := range statuses {
+		got = append(got, s.status)
+	}
+	assert.Equal(t, []string{statusMissing, statusApplied, statusModified, statusPending}, got)
+	assert.Nil(t, statuses[3].appliedAt)
+}
@@ -0,0 +1,157 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Created before any migration runs, so it can't be a migration itself
+var createSchemaMigrationsQuery = `
+CREATE SCHEMA IF NOT EXISTS ruok;
+
+CREATE TABLE IF NOT EXISTS ruok.schema_migrations (
+	version text PRIMARY KEY NOT NULL,
+	name text NOT NULL,
+	checksum text NOT NULL,
+	applied_at timestamptz DEFAULT now() NOT NULL
+);
+`
+
+// Keeps two runners from migrating at the same time
+var migrationsLockId int64 = 7_260_000_037
+
+type runner struct {
+	conn *pgxpool.Conn
+}
+
+// Takes a connection of the pool and holds the migrations lock until release is called
+func newRunner(ctx context.Context, pool *pgxpool.Pool) (*runner, func(), error) {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not get a connection: %w", err)
+	}
+
+	if _, err := conn.Exec(ctx, "SELECT pg_advisory_lock($1)", migrationsLockId); err != nil {
+		conn.Release()
+		return nil, nil, fmt.Errorf("could not take the migrations lock: %w", err)
+	}
+
+	if _, err := conn.Exec(ctx, createSchemaMigrationsQuery); err != nil {
+		conn.Exec(ctx, "SELECT pg_advisory_unlock($1)", migrationsLockId)
+		conn.Release()
+		return nil, nil, fmt.Errorf("could not create ruok.schema_migrations: %w", err)
+	}
+
+	release := func() {
+		conn.Exec(context.Background(), "SELECT pg_advisory_unlock($1)", migrationsLockId)
+		conn.Release()
+	}
+	return &runner{conn: conn}, release, nil
+}
+
+func (r *runner) applied(ctx context.Context) ([]appliedMigration, error) {
+	rows, err := r.conn.Query(ctx, "SELECT version, name, checksum, applied_at FROM ruok.schema_migrations ORDER BY version")
+	if err != nil {
+		return nil, fmt.Errorf("could not read applied migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := []appliedMigration{}
+	for rows.Next() {
+		var a appliedMigration
+		if err := rows.Scan(&a.version, &a.name, &a.checksum, &a.appliedAt); err != nil {
+			return nil, fmt.Errorf("could not read applied migrations: %w", err)
+		}
+		applied = append(applied, a)
+	}
+	return applied, rows.Err()
+}
+
+// Runs the up script and records it in the same transaction
+func (r *runner) up(ctx context.Context, m migration) error {
+	tx, err := r.conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("could not start transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, m.up); err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx,
+		"INSERT INTO ruok.schema_migrations (version, name, checksum) VALUES ($1, $2, $3)",
+		m.version, m.name, m.checksum,
+	)
+	if err != nil {
+		return fmt.Errorf("could not record migration: %w", err)
+	}
+	return tx.Commit(ctx)
+}
+
+// Runs the down script and forgets the migration in the same transaction
+func (r *runner) down(ctx context.Context, m migration) error {
+	tx, err := r.conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("could not start transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, m.down); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, "DELETE FROM ruok.schema_migrations WHERE version = $1", m.version); err != nil {
+		return fmt.Errorf("could not forget migration: %w", err)
+	}
+	return tx.Commit(ctx)
+}
+
+// Applies every pending migration, each one in its own transaction.
+// Stops at the first one failing, keeping the ones applied before it.
+func (r *runner) upAll(ctx context.Context, migrations []migration) (int, error) {
+	applied, err := r.applied(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if err := verifyChecksums(migrations, applied); err != nil {
+		return 0, err
+	}
+
+	pending := pendingMigrations(migrations, applied)
+	for i, m := range pending {
+		log.Printf("Applying migration %s_%s\n", m.version, m.name)
+		start := time.Now()
+		if err := r.up(ctx, m); err != nil {
+			return i, fmt.Errorf("could not apply migration %s_%s: %w", m.version, m.name, err)
+		}
+		log.Printf("Applied migration %s_%s in %s\n", m.version, m.name, time.Since(start))
+	}
+	return len(pending), nil
+}
+
+// Rolls back the last n applied migrations, newest first
+func (r *runner) downN(ctx context.Context, migrations []migration, n int) (int, error) {
+	applied, err := r.applied(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if err := verifyChecksums(migrations, applied); err != nil {
+		return 0, err
+	}
+
+	rollBack, err := migrationsToRollBack(migrations, applied, n)
+	if err != nil {
+		return 0, err
+	}
+	for i, m := range rollBack {
+		log.Printf("Rolling back migration %s_%s\n", m.version, m.name)
+		if err := r.down(ctx, m); err != nil {
+			return i, fmt.Errorf("could not roll back migration %s_%s: %w", m.version, m.name, err)
+		}
+		log.Printf("Rolled back migration %s_%s\n", m.version, m.name)
+	}
+	return len(rollBack), nil
+}
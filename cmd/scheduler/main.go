@@ -17,7 +17,9 @@ import (
 
 	"github.com/back-end-labs/ruok/pkg/alerting"
 	"github.com/back-end-labs/ruok/pkg/api"
+	"github.com/back-end-labs/ruok/pkg/auth"
 	"github.com/back-end-labs/ruok/pkg/config"
+	"github.com/back-end-labs/ruok/pkg/gitops"
 	"github.com/back-end-labs/ruok/pkg/scheduler"
 	"github.com/back-end-labs/ruok/pkg/storage"
 )
@@ -33,6 +35,7 @@ func start() {
 
 	store, close := storage.NewStorage(&cfg)
 	defer close()
+	store.BufferResults(cfg.ResultsBatchSize, cfg.ResultsFlushInterval, cfg.ResultsSpoolFile)
 
 	jobsList := scheduler.NewJobList(int(cfg.MaxJobs))
 
@@ -50,6 +53,15 @@ func start() {
 		}
 	}()
 
+	syncCtx, stopSync := context.WithCancel(context.Background())
+	if cfg.SyncDir != "" {
+		log.Info().Msgf("syncing the jobs of the %s tenant with %s every %v", auth.DefaultTenant, cfg.SyncDir, cfg.SyncInterval)
+		syncStore, closeSync := syncStorage(cfg, store)
+		defer closeSync()
+		syncer := &gitops.Syncer{Dir: cfg.SyncDir, Storage: syncStore.ForTenant(auth.DefaultTenant)}
+		go syncer.Run(syncCtx, cfg.SyncInterval)
+	}
+
 	alertingManager := alerting.CreateAlertManager(cfg.AlertChannels, alerting.RegisteredFn)
 
 	exitStatus := scheduler.NewScheduler(
@@ -59,6 +71,7 @@ func start() {
 	).Start(signalCh)
 
 	log.Info().Msgf("scheduler returned with exit code of %d", exitStatus)
+	stopSync()
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
@@ -85,3 +98,16 @@ var StartScheduler = &cobra.Command{
 		start()
 	},
 }
+
+// Storage sync plans with. With postgres the scheduler only sees the jobs it claimed, so sync can connect
+// as SYNC_DB_USER, a user with the RUOK_JOBS_MANAGER role, instead. Without it, sync refuses to plan
+// while other schedulers hold jobs.
+func syncStorage(cfg config.Configs, store storage.APIStorage) (storage.APIStorage, storage.Closer) {
+	if cfg.Kind != config.POSTGRES_STORAGE || cfg.SyncUser == "" {
+		return store, func() {}
+	}
+	syncCfg := cfg
+	syncCfg.User = cfg.SyncUser
+	syncCfg.Pass = cfg.SyncPass
+	return storage.NewPostgresStorage(&syncCfg)
+}
@@ -193,7 +193,7 @@ func TestCreateMaxJobs_all_http_alerts(t *testing.T) {
 	<-done
 	cfg := config.FromEnvs()
 
-	s, closeDb := storage.NewStorage(&cfg)
+	s, closeDb := storage.NewPostgresStorage(&cfg)
 	defer closeDb()
 
 	rows, err := s.GetClient().Query(
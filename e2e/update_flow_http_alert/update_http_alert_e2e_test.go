@@ -200,7 +200,7 @@ func TestUpdate_flow_http_alert(t *testing.T) {
 	}
 
 	cfg := config.FromEnvs()
-	s, closeDb := storage.NewStorage(&cfg)
+	s, closeDb := storage.NewPostgresStorage(&cfg)
 	defer closeDb()
 
 	rows, err := s.GetClient().Query(
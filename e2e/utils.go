@@ -1,20 +1,19 @@
 package e2e
 
 import (
-	"bytes"
-	"encoding/json"
-	"errors"
 	"fmt"
-	"io"
-	"net/http"
 	"testing"
 
-	v1 "github.com/back-end-labs/ruok/pkg/api/v1"
+	"github.com/back-end-labs/ruok/pkg/client"
 	"github.com/back-end-labs/ruok/pkg/storage"
+	"github.com/gofrs/uuid"
 )
 
 var jobIdQuery = "jobId"
 
+// The scheduler under test
+var ruok = client.New("http://localhost:8080", "")
+
 func MakeTestURL(host string, jobName string) string {
 	return fmt.Sprintf("%s/test?%s=%s", host, jobIdQuery, jobName)
 }
@@ -24,64 +23,28 @@ func MakeAlertUrl(host string, jobName string) string {
 }
 
 func CreateJob(t *testing.T, i storage.CreateJobInput) bool {
-	bytesBody, err := json.Marshal(i)
-	if err != nil {
-		return false
-	}
-	res, err := http.Post("http://localhost:8080/v1/jobs", "", bytes.NewReader(bytesBody))
+	_, err := ruok.CreateJob(i)
 	if err != nil {
-		return false
-	}
-	defer res.Body.Close()
-	if res.StatusCode != http.StatusCreated {
-		response, _ := io.ReadAll(res.Body)
-		t.Logf("%d\n%s\n", res.StatusCode, string(response))
+		t.Log(err)
 	}
-	return res.StatusCode == http.StatusCreated
+	return err == nil
 }
 
 func UpdateJob(t *testing.T, id string, i storage.UpdateJobInput) bool {
-	bytesBody, err := json.Marshal(i)
+	jobId, err := uuid.FromString(id)
 	if err != nil {
+		t.Log(err)
 		return false
 	}
-
-	req, err := http.NewRequest(http.MethodPut, "http://localhost:8080/v1/jobs/"+id, bytes.NewReader(bytesBody))
-
+	_, err = ruok.UpdateJob(jobId, i)
 	if err != nil {
-		return false
-	}
-
-	client := http.Client{}
-
-	res, err := client.Do(req)
-
-	if err != nil {
-		return false
+		t.Log(err)
 	}
-
-	defer res.Body.Close()
-
-	if res.StatusCode != http.StatusAccepted {
-		response, _ := io.ReadAll(res.Body)
-		t.Logf("%d\n%s\n", res.StatusCode, string(response))
-	}
-
-	return res.StatusCode == http.StatusAccepted
+	return err == nil
 }
 
 func ServerUp(t *testing.T) bool {
-	res, err := http.Get("http://localhost:8080/v1/instance")
-	if err != nil {
-		return false
-	}
-	if res.StatusCode != 200 {
-		return false
-	}
-
-	info := &v1.InstanceInfo{}
-	err = json.NewDecoder(res.Body).Decode(info)
-	defer res.Body.Close()
+	info, err := ruok.GetInstanceInfo()
 	if err != nil {
 		return false
 	}
@@ -90,16 +53,7 @@ func ServerUp(t *testing.T) bool {
 }
 
 func ClaimedJobs(t *testing.T) (int, error) {
-	res, err := http.Get("http://localhost:8080/v1/instance")
-	if err != nil {
-		return 0, err
-	}
-	if res.StatusCode != 200 {
-		return 0, errors.New("couldn't get instance info")
-	}
-	info := &v1.InstanceInfo{}
-	err = json.NewDecoder(res.Body).Decode(info)
-	defer res.Body.Close()
+	info, err := ruok.GetInstanceInfo()
 	if err != nil {
 		return 0, err
 	}
@@ -0,0 +1,144 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/back-end-labs/ruok/pkg/job"
+	"github.com/back-end-labs/ruok/pkg/storage"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// Only implements what the aggregates route needs
+type aggregatesStorage struct {
+	storage.APIStorage
+	aggregates  []*job.ResultAggregate
+	missing     bool
+	granularity string
+	from        time.Time
+	to          time.Time
+}
+
+func (as *aggregatesStorage) GetJob(jobId uuid.UUID) (*job.Job, error) {
+	if as.missing {
+		return nil, storage.ErrNotFound
+	}
+	return &job.Job{Id: jobId}, nil
+}
+
+func (as *aggregatesStorage) GetResultAggregates(jobId uuid.UUID, granularity string, from time.Time, to time.Time) []*job.ResultAggregate {
+	as.granularity = granularity
+	as.from = from
+	as.to = to
+	return as.aggregates
+}
+
+func TestListJobResultAggregates(t *testing.T) {
+	jobId, _ := uuid.NewV7()
+	p50 := int64(1500)
+	bucket := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	stored := []*job.ResultAggregate{
+		{BucketStart: bucket, Granularity: storage.GranularityDay, Executions: 10, Failures: 1, P50LatencyMicro: &p50},
+	}
+	base := "/v1/jobs/" + jobId.String() + "/aggregates"
+
+	tests := []struct {
+		name                string
+		path                string
+		storage             *aggregatesStorage
+		expectedStatus      int
+		expectedGranularity string
+	}{
+		{
+			name:                "DefaultsToDailyBuckets",
+			path:                base,
+			storage:             &aggregatesStorage{aggregates: stored},
+			expectedStatus:      http.StatusOK,
+			expectedGranularity: storage.GranularityDay,
+		},
+		{
+			name:                "ShortRangesUseHourlyBuckets",
+			path:                base + "?from=2026-10-01T00:00:00Z&to=2026-10-02T00:00:00Z",
+			storage:             &aggregatesStorage{aggregates: stored},
+			expectedStatus:      http.StatusOK,
+			expectedGranularity: storage.GranularityHour,
+		},
+		{
+			name:                "ExplicitGranularity",
+			path:                base + "?from=2026-10-01T00:00:00Z&to=2026-10-02T00:00:00Z&granularity=day",
+			storage:             &aggregatesStorage{aggregates: stored},
+			expectedStatus:      http.StatusOK,
+			expectedGranularity: storage.GranularityDay,
+		},
+		{
+			name:           "BadGranularity",
+			path:           base + "?granularity=minute",
+			storage:        &aggregatesStorage{},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "BadTime",
+			path:           base + "?from=yesterday",
+			storage:        &aggregatesStorage{},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "FromAfterTo",
+			path:           base + "?from=2026-10-02T00:00:00Z&to=2026-10-01T00:00:00Z",
+			storage:        &aggregatesStorage{},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "TooManyBuckets",
+			path:           base + "?from=2020-01-01T00:00:00Z&to=2026-01-01T00:00:00Z&granularity=hour",
+			storage:        &aggregatesStorage{},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "BadId",
+			path:           "/v1/jobs/not-an-id/aggregates",
+			storage:        &aggregatesStorage{},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "UnknownJob",
+			path:           base,
+			storage:        &aggregatesStorage{aggregates: stored, missing: true},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "StorageError",
+			path:           base,
+			storage:        &aggregatesStorage{},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := openRouter(tt.storage)
+			rr := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", tt.path, nil)
+			router.ServeHTTP(rr, req)
+			assert.Equal(t, tt.expectedStatus, rr.Code, rr.Body.String())
+			if rr.Code != http.StatusOK {
+				return
+			}
+
+			body := &struct {
+				Granularity string                 `json:"granularity"`
+				Aggregates  []*job.ResultAggregate `json:"aggregates"`
+			}{}
+			assert.Nil(t, json.Unmarshal(rr.Body.Bytes(), body))
+			assert.Equal(t, tt.expectedGranularity, body.Granularity)
+			assert.Equal(t, tt.expectedGranularity, tt.storage.granularity)
+			assert.True(t, tt.storage.from.Before(tt.storage.to))
+			assert.Equal(t, stored[0].Executions, body.Aggregates[0].Executions)
+			assert.Equal(t, p50, *body.Aggregates[0].P50LatencyMicro)
+		})
+	}
+}
@@ -6,8 +6,11 @@ import (
 	"io"
 	"net/http"
 	"strings"
+	"time"
 
 	v1 "github.com/back-end-labs/ruok/pkg/api/v1"
+	"github.com/back-end-labs/ruok/pkg/auth"
+	"github.com/back-end-labs/ruok/pkg/config"
 	"github.com/back-end-labs/ruok/pkg/storage"
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog/log"
@@ -16,12 +19,21 @@ import (
 //go:embed static
 var staticFiles embed.FS
 
+// Lets browsers on the configured origins call the API.
+// With "*" any origin can read, only listed origins can make changes too.
 func CORSMiddleware() gin.HandlerFunc {
+	origins := config.CORSAllowedOrigins()
 	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+		if origin := allowedOrigin(origins, c.GetHeader("Origin")); origin == "*" {
+			c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+			c.Writer.Header().Set("Access-Control-Allow-Methods", "OPTIONS, GET")
+		} else if origin != "" {
+			c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
+			c.Writer.Header().Set("Access-Control-Allow-Methods", "OPTIONS, GET, POST, PUT, PATCH, DELETE")
+			c.Writer.Header().Add("Vary", "Origin")
+		}
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
 		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding,  Authorization, accept, origin, Cache-Control")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "OPTIONS, GET")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -32,7 +44,49 @@ func CORSMiddleware() gin.HandlerFunc {
 	}
 }
 
+// Returns what Access-Control-Allow-Origin should be, empty when the origin is not allowed
+func allowedOrigin(allowed []string, origin string) string {
+	for _, o := range allowed {
+		if o == "*" {
+			return "*"
+		}
+		if o == origin {
+			return origin
+		}
+	}
+	return ""
+}
+
 func CreateRouter(apiStorage storage.APIStorage) *gin.Engine {
+	mode := config.APIAuth()
+	if mode != config.OIDC_AUTH {
+		return newRouter(apiStorage, mode, config.OIDC(), nil)
+	}
+	return newRouter(apiStorage, mode, config.OIDC(), newVerifier(config.OIDC()))
+}
+
+// Checks the tokens of the configured identity provider, its keys are fetched when first needed
+func newVerifier(oidc config.OIDCConfig) *auth.Verifier {
+	jwksURL := oidc.JWKSURL
+	if jwksURL == "" {
+		discovered, err := auth.DiscoverJWKSURL(&http.Client{Timeout: 10 * time.Second}, oidc.Issuer)
+		if err != nil {
+			log.Fatal().Err(err).Msgf("Cant continue. Could not find the keys of %s, set %s", oidc.Issuer, config.OIDC_JWKS_URL)
+		}
+		jwksURL = discovered
+	}
+	return &auth.Verifier{
+		Issuer:      oidc.Issuer,
+		Audience:    oidc.Audience,
+		ClockSkew:   oidc.ClockSkew,
+		RolesClaim:  oidc.RolesClaim,
+		Roles:       oidc.Roles,
+		TenantClaim: oidc.TenantClaim,
+		Keys:        auth.NewRemoteKeySet(jwksURL),
+	}
+}
+
+func newRouter(apiStorage storage.APIStorage, authMode string, oidc config.OIDCConfig, tokens v1.TokenVerifier) *gin.Engine {
 
 	r := gin.Default()
 
@@ -40,13 +94,58 @@ func CreateRouter(apiStorage storage.APIStorage) *gin.Engine {
 	apiV1 := r.Group("/v1")
 	{
 		apiV1.Use(CORSMiddleware())
+		// preflight requests are answered by CORSMiddleware
+		apiV1.OPTIONS("/*path", func(c *gin.Context) {})
 		apiV1.GET("/status", v1.Status)
-		apiV1.GET("/health", v1.Health)
-		apiV1.GET("/jobs", v1.ListJobs(apiStorage))
-		apiV1.GET("/jobs/:id", v1.ListJobExecutions(apiStorage))
-		apiV1.POST("/jobs", v1.CreateJob(apiStorage))
-		apiV1.PUT("/jobs/:id", v1.UpdateJob(apiStorage))
-		apiV1.GET("/instance", v1.GetInstanceInfo(apiStorage))
+		apiV1.GET("/health", v1.Health(apiStorage))
+		apiV1.GET("/auth/config", v1.AuthConfig(authMode, oidc))
+		apiV1.GET("/openapi.json", v1.OpenAPI)
+	}
+
+	// Every other route needs a caller with the right scope
+	authenticated := apiV1.Group("", v1.LogMutations(), v1.Authenticate(apiStorage, authMode, tokens))
+
+	read := authenticated.Group("", v1.RequireScope(auth.ReadScope))
+	{
+		read.GET("/jobs", v1.ForCaller(apiStorage, v1.ListJobs))
+		read.GET("/jobs/:id", v1.ForCaller(apiStorage, v1.GetJob))
+		read.GET("/jobs/:id/executions", v1.ForCaller(apiStorage, v1.ListJobExecutions))
+		read.GET("/jobs/:id/aggregates", v1.ForCaller(apiStorage, v1.ListJobResultAggregates))
+		read.GET("/jobs/:id/history", v1.ForCaller(apiStorage, v1.ListJobHistory))
+		read.GET("/instance", v1.GetInstanceInfo(apiStorage))
+		read.GET("/schedules/next", v1.NextExecutions)
+		read.GET("/maintenance", v1.ListMaintenanceWindows(apiStorage))
+		read.GET("/export", v1.ForCaller(apiStorage, v1.Export))
+	}
+
+	write := authenticated.Group("", v1.RequireScope(auth.WriteScope))
+	{
+		write.POST("/jobs", v1.ForCaller(apiStorage, v1.CreateJob))
+		write.PUT("/jobs/:id", v1.ForCaller(apiStorage, v1.UpdateJob))
+		write.PATCH("/jobs/:id", v1.ForCaller(apiStorage, v1.PatchJob))
+		write.DELETE("/jobs/:id", v1.ForCaller(apiStorage, v1.DeleteJob))
+		write.POST("/jobs/pause", v1.ForCaller(apiStorage, v1.PauseJobs))
+		write.POST("/jobs/resume", v1.ForCaller(apiStorage, v1.ResumeJobs))
+		write.PUT("/jobs/alerts", v1.ForCaller(apiStorage, v1.UpdateJobsAlerts))
+		write.POST("/jobs/:id/pause", v1.ForCaller(apiStorage, v1.PauseJob))
+		write.POST("/jobs/:id/resume", v1.ForCaller(apiStorage, v1.ResumeJob))
+		write.POST("/jobs/:id/run", v1.ForCaller(apiStorage, v1.RunJob))
+		write.POST("/jobs/:id/history/:revision/restore", v1.ForCaller(apiStorage, v1.RestoreJob))
+		write.POST("/import", v1.ForCaller(apiStorage, v1.Import))
+	}
+
+	// maintenance windows pause every tenant, so only the default one can change them
+	shared := write.Group("", v1.RequireDefaultTenant())
+	{
+		shared.POST("/maintenance", v1.CreateMaintenanceWindow(apiStorage))
+		shared.DELETE("/maintenance/:id", v1.DeleteMaintenanceWindow(apiStorage))
+	}
+
+	admin := authenticated.Group("", v1.RequireScope(auth.AdminScope))
+	{
+		admin.GET("/apikeys", v1.ForCaller(apiStorage, v1.ListAPIKeys))
+		admin.POST("/apikeys", v1.ForCaller(apiStorage, v1.CreateAPIKey))
+		admin.DELETE("/apikeys/:id", v1.ForCaller(apiStorage, v1.RevokeAPIKey))
 	}
 
 	// For our SPA
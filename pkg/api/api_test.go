@@ -1,10 +1,12 @@
 package api
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
 	"time"
@@ -17,19 +19,50 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+// Only implements what the health route needs
+type healthStorage struct {
+	storage.APIStorage
+	health storage.ListenerHealth
+}
+
+func (hs *healthStorage) ListenerHealth() storage.ListenerHealth {
+	return hs.health
+}
+
 func TestHealthRoute(t *testing.T) {
-	router := CreateRouter(nil)
+	tests := []struct {
+		name           string
+		storage        storage.APIStorage
+		expectedStatus int
+		expectedBody   string
+	}{
+		{"NoStorage", nil, 200, "ok"},
+		{"ListenerNotStarted", &healthStorage{}, 200, "ok"},
+		{"ListenerConnected", &healthStorage{health: storage.ListenerHealth{Started: true, Connected: true}}, 200, "ok"},
+		{"ListenerDisconnected", &healthStorage{health: storage.ListenerHealth{Started: true, LastError: "conn closed"}}, 503, "degraded"},
+	}
 
-	rr := httptest.NewRecorder()
-	req, _ := http.NewRequest("GET", "/v1/health", nil)
-	router.ServeHTTP(rr, req)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := openRouter(tt.storage)
 
-	assert.Equal(t, 200, rr.Code)
-	assert.Equal(t, "OK", rr.Body.String())
+			rr := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/v1/health", nil)
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			body := &struct {
+				Status   string                 `json:"status"`
+				Listener storage.ListenerHealth `json:"listener"`
+			}{}
+			assert.Nil(t, json.Unmarshal(rr.Body.Bytes(), body))
+			assert.Equal(t, tt.expectedBody, body.Status)
+		})
+	}
 }
 
 func TestStatusRoute(t *testing.T) {
-	router := CreateRouter(nil)
+	router := openRouter(nil)
 
 	rr := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", "/v1/status", nil)
@@ -39,84 +72,236 @@ func TestStatusRoute(t *testing.T) {
 	assert.Equal(t, "OK", rr.Body.String())
 }
 
-func TestClaimedJobs_BadParams(t *testing.T) {
-	router := CreateRouter(nil)
+func TestNextExecutionsRoute(t *testing.T) {
+	router := openRouter(nil)
+
+	tests := []struct {
+		query          string
+		expectedStatus int
+		expectedLen    int
+	}{
+		{"cronexp=every%2030s", 200, 5},
+		{"cronexp=every%2030s&n=3", 200, 3},
+		{"cronexp=0%209%20*%20*%20*&timezone=Europe/Madrid&n=2", 200, 2},
+		{"cronexp=at%202020-01-01T00:00:00Z", 200, 0},
+		{"cronexp=every%2030s&n=a1", 400, 0},
+		{"cronexp=every%2030s&n=0", 400, 0},
+		{"cronexp=every%2030s&n=1000", 400, 0},
+		{"cronexp=bad", 400, 0},
+		{"cronexp=every%2030s&timezone=Europe/Atlantis", 400, 0},
+	}
+
+	for _, test := range tests {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/v1/schedules/next?"+test.query, nil)
+		router.ServeHTTP(rr, req)
+		assert.Equal(t, test.expectedStatus, rr.Code, test.query)
+		if test.expectedStatus != 200 {
+			continue
+		}
+		body := &struct {
+			NextExecutions []time.Time `json:"nextExecutions"`
+		}{}
+		assert.Nil(t, json.Unmarshal(rr.Body.Bytes(), body))
+		assert.Len(t, body.NextExecutions, test.expectedLen, test.query)
+	}
+}
+
+func TestListJobs_BadParams(t *testing.T) {
+	router := openRouter(nil)
 
 	queries := []string{
-		"offset=a1",
 		"limit=a1",
-		"limit=0&offset=a1",
-		"limit=a1&offset=0",
-		"limit=a1&offset=a1",
+		"limit=0",
+		"limit=1000",
+		"sort=color",
+		"sort=-",
+		"succeeded=maybe",
+		"selector=-env",
+		"selector=env=prod,",
 	}
 
 	for _, query := range queries {
 		rr := httptest.NewRecorder()
 		req, _ := http.NewRequest("GET", "/v1/jobs?"+query, nil)
 		router.ServeHTTP(rr, req)
-		assert.Equal(t, 400, rr.Code)
+		assert.Equal(t, 400, rr.Code, query)
 	}
 }
 
-func TestClaimedJobs_OKQueries(t *testing.T) {
-	storage.Drop()
-	storage.Seed()
-	defer storage.Drop()
-	cfg := config.FromEnvs()
-	s, close := storage.NewStorage(&cfg)
+type jobsPage struct {
+	Jobs       []*job.Job `json:"jobs"`
+	NextCursor string     `json:"nextCursor"`
+}
+
+func listJobsPage(t *testing.T, router http.Handler, query string) *jobsPage {
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/v1/jobs?"+query, nil)
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, 200, rr.Code, query)
+	body := &jobsPage{}
+	assert.Nil(t, json.Unmarshal(rr.Body.Bytes(), body))
+	return body
+}
+
+func TestListJobs_Pages(t *testing.T) {
+	s, close := storage.NewMemoryStorage()
 	defer close()
-	jobs := s.GetAvailableJobs(10)
+	for i := 0; i < 10; i++ {
+		_, err := s.CreateJob(storage.CreateJobInput{
+			Name:            fmt.Sprintf("job %d", i+1),
+			CronExpString:   "*/5 * * * *",
+			MaxRetries:      1,
+			Endpoint:        "http://localhost:8080/v1/status",
+			HttpMethod:      "GET",
+			SuccessStatuses: []int{200},
+		})
+		assert.NoError(t, err)
+	}
+	jobs := s.GetAvailableJobs(5, nil)
 	jobIds := []uuid.UUID{}
 	for _, j := range jobs {
 		jobIds = append(jobIds, j.Id)
 	}
-	router := CreateRouter(s)
+	router := openRouter(s)
 
 	tests := []struct {
 		query        string
 		expectedJobs int
 	}{
-		{"offset=0", 10},
+		{"", 10},
 		{"limit=10", 10},
-		{"limit=0&offset=10", 0},
-		{"limit=10&offset=5", 5},
-		{"limit=5&offset=5", 5},
-		{"limit=10&offset=10", 0},
+		{"limit=4", 4},
+		{"status=claimed", 5},
+		{"status=paused", 0},
+		{"name=JOB%201", 2},
+		{"name=job%207&sort=-name", 1},
 	}
 
 	for _, test := range tests {
+		body := listJobsPage(t, router, test.query)
+		assert.Len(t, body.Jobs, test.expectedJobs, test.query)
+	}
+
+	for _, j := range listJobsPage(t, router, "status=claimed").Jobs {
+		assert.Contains(t, jobIds, j.Id, "jobs claimed by this scheduler are listed")
+	}
+
+	for _, sort := range []string{"id", "-id", "name", "-createdAt", "lastExecution"} {
+		t.Run(sort, func(t *testing.T) {
+			all := listJobsPage(t, router, "sort="+sort)
+			assert.Empty(t, all.NextCursor, "there is no next page after the last job")
+
+			walked := []*job.Job{}
+			query := "limit=3&sort=" + sort
+			for pages := 0; pages < 10; pages++ {
+				page := listJobsPage(t, router, query)
+				walked = append(walked, page.Jobs...)
+				if page.NextCursor == "" {
+					break
+				}
+				query = "limit=3&sort=" + sort + "&cursor=" + url.QueryEscape(page.NextCursor)
+			}
+			if assert.Len(t, walked, len(all.Jobs)) {
+				for i := range walked {
+					assert.Equal(t, all.Jobs[i].Id, walked[i].Id)
+				}
+			}
+		})
+	}
+
+	byName := listJobsPage(t, router, "sort=-name")
+	assert.Equal(t, "job 9", byName.Jobs[0].Name)
+
+	first := listJobsPage(t, router, "limit=3")
+	for _, query := range []string{"cursor=nope", "sort=name&cursor=" + url.QueryEscape(first.NextCursor)} {
 		rr := httptest.NewRecorder()
-		req, _ := http.NewRequest("GET", "/v1/jobs?"+test.query, nil)
+		req, _ := http.NewRequest("GET", "/v1/jobs?"+query, nil)
 		router.ServeHTTP(rr, req)
-		assert.Equal(t, 200, rr.Code)
-		body := &struct {
-			Jobs []*job.Job `json:"jobs"`
-		}{}
-		err := json.Unmarshal(rr.Body.Bytes(), body)
-		//t.Logf("query is %q, expected is %d, jobLen is %d", test.query, test.expectedJobs, len(body.Jobs))
-		assert.Nil(t, err)
-		assert.Equal(t, test.expectedJobs, len(body.Jobs))
-		for _, jobFromBody := range body.Jobs {
-			assert.Contains(t, jobIds, jobFromBody.Id)
-		}
+		assert.Equal(t, 400, rr.Code, query)
 	}
 }
 
-func TestClaimedJobExecutions_BadParams(t *testing.T) {
-	router := CreateRouter(nil)
+func TestGetJob(t *testing.T) {
+	s, close := storage.NewMemoryStorage()
+	defer close()
+	createLabeledJobs(t, s)
+	router := openRouter(s)
+	j := listJobsPage(t, router, "selector=env%3Ddev").Jobs[0]
+
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/v1/jobs/"+j.Id.String(), nil)
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, 200, rr.Code)
+	body := &struct {
+		Job *job.Job `json:"job"`
+	}{}
+	assert.Nil(t, json.Unmarshal(rr.Body.Bytes(), body))
+	assert.Equal(t, "dev payments", body.Job.Name)
+	assert.Equal(t, map[string]string{"env": "dev", "team": "payments"}, body.Job.Labels)
+
+	assert.NoError(t, s.DeleteJob(j.Id, "tester"))
+	missing, _ := uuid.NewV7()
+	for _, path := range []string{j.Id.String(), missing.String(), j.Id.String() + "/executions"} {
+		rr = httptest.NewRecorder()
+		req, _ = http.NewRequest("GET", "/v1/jobs/"+path, nil)
+		router.ServeHTTP(rr, req)
+		assert.Equal(t, 404, rr.Code, path)
+	}
+}
+
+func TestListJobExecutions_BadParams(t *testing.T) {
+	router := openRouter(nil)
 	queries := []string{
-		"offset=a1",
 		"limit=a1",
-		"limit=0&offset=a1",
-		"limit=a1&offset=0",
-		"limit=a1&offset=a1",
+		"limit=0",
+		"limit=1000",
+		"cursor=a1",
 	}
 	nonExistentJob, _ := uuid.NewV7()
 	for _, query := range queries {
 		rr := httptest.NewRecorder()
-		req, _ := http.NewRequest("GET", fmt.Sprintf("/v1/jobs/%s?%s", nonExistentJob, query), nil)
+		req, _ := http.NewRequest("GET", fmt.Sprintf("/v1/jobs/%s/executions?%s", nonExistentJob, query), nil)
 		router.ServeHTTP(rr, req)
-		assert.Equal(t, 400, rr.Code)
+		assert.Equal(t, 400, rr.Code, query)
+	}
+}
+
+func TestListJobExecutions_Pages(t *testing.T) {
+	s, close := storage.NewMemoryStorage()
+	defer close()
+	createLabeledJobs(t, s)
+	j := s.GetAvailableJobs(1, nil)[0]
+	for i := 0; i < 5; i++ {
+		j.LastExecution = time.Now()
+		assert.NoError(t, s.WriteDone(j))
+	}
+	router := openRouter(s)
+
+	type executionsPage struct {
+		JobResults []*job.JobExecution `json:"jobResults"`
+		NextCursor string              `json:"nextCursor"`
+	}
+	walked := []uuid.UUID{}
+	query := "limit=2"
+	for pages := 0; pages < 5; pages++ {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", fmt.Sprintf("/v1/jobs/%s/executions?%s", j.Id, query), nil)
+		router.ServeHTTP(rr, req)
+		assert.Equal(t, 200, rr.Code)
+		page := &executionsPage{}
+		assert.Nil(t, json.Unmarshal(rr.Body.Bytes(), page))
+		for _, e := range page.JobResults {
+			walked = append(walked, e.Id)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		query = "limit=2&cursor=" + page.NextCursor
+	}
+	assert.Len(t, walked, 5)
+	for i := 1; i < len(walked); i++ {
+		assert.Equal(t, 1, bytes.Compare(walked[i-1].Bytes(), walked[i].Bytes()), "newest first")
 	}
 }
 
@@ -144,7 +329,7 @@ func TestGetInstanceInfo(t *testing.T) {
 		ClaimedJobs: 10,
 		StartedAt:   currentTime.UnixMicro(),
 	}
-	router := CreateRouter(s)
+	router := openRouter(s)
 
 	rr := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", "/v1/instance", nil)
@@ -154,7 +339,6 @@ func TestGetInstanceInfo(t *testing.T) {
 
 	instanceInfo := &v1.InstanceInfo{}
 	err := json.Unmarshal(rr.Body.Bytes(), &instanceInfo)
-	t.Log(s.GetClient().Stat().TotalConns() > 0)
 	assert.NoError(t, err, "Error unmarshaling JSON")
 	assert.Equal(t, "application1", instanceInfo.AppName)
 	assert.Equal(t, true, instanceInfo.DbConnected)
@@ -165,4 +349,5 @@ func TestGetInstanceInfo(t *testing.T) {
 	assert.Equal(t, currentTime.UnixMicro(), instanceInfo.StartedAt)
 	assert.True(t, instanceInfo.UpTimeMicro > 0) // Just ensure it's positive
 	assert.Equal(t, 10000, instanceInfo.MaxJobs)
+	assert.Equal(t, int64(0), instanceInfo.MalformedNotifications)
 }
@@ -0,0 +1,233 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	v1 "github.com/back-end-labs/ruok/pkg/api/v1"
+	"github.com/back-end-labs/ruok/pkg/auth"
+	"github.com/back-end-labs/ruok/pkg/auth/authtest"
+	"github.com/back-end-labs/ruok/pkg/config"
+	"github.com/back-end-labs/ruok/pkg/storage"
+)
+
+func newKey(t *testing.T, s storage.Storage, name string, scope auth.Scope) string {
+	key, _, err := v1.NewAPIKey(s, name, scope, "")
+	if err != nil {
+		t.Fatalf("could not create api key: %q", err.Error())
+	}
+	return key
+}
+
+// Router of an API without authentication, like with API_AUTH=none
+func openRouter(s storage.APIStorage) *gin.Engine {
+	return newRouter(s, config.NO_AUTH, config.OIDCConfig{}, nil)
+}
+
+func request(router http.Handler, method string, path string, key string, body string) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, path, strings.NewReader(body))
+	if key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestAPIKeys_ByDefault(t *testing.T) {
+	s, close := storage.NewMemoryStorage()
+	defer close()
+	if os.Getenv(config.API_AUTH) != "" {
+		t.Skipf("%s is set", config.API_AUTH)
+	}
+	router := CreateRouter(s)
+
+	assert.Equal(t, http.StatusUnauthorized, request(router, "GET", "/v1/jobs", "", "").Code, "keys are needed unless told otherwise")
+	assert.Equal(t, http.StatusUnauthorized, request(router, "POST", "/v1/import", "", "").Code)
+	assert.Equal(t, http.StatusOK, request(router, "GET", "/v1/jobs", newKey(t, s, "dashboards", auth.ReadScope), "").Code)
+}
+
+func TestAPIKeys_Scopes(t *testing.T) {
+	s, close := storage.NewMemoryStorage()
+	defer close()
+	router := newRouter(s, config.KEYS_AUTH, config.OIDCConfig{}, nil)
+
+	reader := newKey(t, s, "dashboards", auth.ReadScope)
+	writer := newKey(t, s, "deploys", auth.WriteScope)
+	admin := newKey(t, s, "ops", auth.AdminScope)
+	unknown, _, _, _ := auth.GenerateKey()
+	jobBody := `{"name": "with keys", "cronexp": "*/5 * * * *", "endpoint": "http://localhost/", "httpmethod": "GET", "successStatuses": [200]}`
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		key            string
+		body           string
+		expectedStatus int
+	}{
+		{"StatusIsPublic", "GET", "/v1/status", "", "", 200},
+		{"HealthIsPublic", "GET", "/v1/health", "", "", 200},
+		{"NoKey", "GET", "/v1/jobs", "", "", 401},
+		{"NotAKey", "GET", "/v1/jobs", "not-a-key", "", 401},
+		{"UnknownKey", "GET", "/v1/jobs", unknown, "", 401},
+		{"ReadCanList", "GET", "/v1/jobs", reader, "", 200},
+		{"ReadCantCreate", "POST", "/v1/jobs", reader, jobBody, 403},
+		{"WriteCanCreate", "POST", "/v1/jobs", writer, jobBody, 201},
+		{"WriteCanList", "GET", "/v1/jobs", writer, "", 200},
+		{"WriteCantManageKeys", "GET", "/v1/apikeys", writer, "", 403},
+		{"AdminCanManageKeys", "GET", "/v1/apikeys", admin, "", 200},
+		{"AdminCanCreate", "POST", "/v1/jobs", admin, jobBody, 201},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := request(router, tt.method, tt.path, tt.key, tt.body)
+			assert.Equal(t, tt.expectedStatus, rr.Code, rr.Body.String())
+			if tt.expectedStatus == 401 {
+				assert.NotEmpty(t, rr.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestAPIKeys_RecordsCaller(t *testing.T) {
+	s, close := storage.NewMemoryStorage()
+	defer close()
+	writer := newKey(t, s, "deploys", auth.WriteScope)
+
+	router := newRouter(s, config.KEYS_AUTH, config.OIDCConfig{}, nil)
+	rr := request(router, "POST", "/v1/jobs", writer,
+		`{"name": "recorded", "cronexp": "*/5 * * * *", "endpoint": "http://localhost/", "httpmethod": "GET", "successStatuses": [200], "updatedBy": "somebody else"}`)
+	assert.Equal(t, 201, rr.Code)
+	jobs := s.GetAvailableJobs(10, nil)
+	if !assert.Len(t, jobs, 1) {
+		t.FailNow()
+	}
+	assert.Equal(t, "key:deploys", s.GetClaimedJobs(10, 0, nil)[0].UpdatedBy, "the caller can't be taken from the body")
+
+	// without authentication everybody is anonymous
+	router = newRouter(s, config.NO_AUTH, config.OIDCConfig{}, nil)
+	path := "/v1/jobs/" + jobs[0].Id.String()
+	assert.Equal(t, 202, request(router, "POST", path+"/pause", "", "").Code)
+	assert.Equal(t, 202, request(router, "POST", path+"/resume", "", "").Code)
+	assert.Equal(t, 409, request(router, "POST", path+"/resume", "", "").Code, "only paused jobs can be resumed")
+	s.GetAvailableJobs(10, nil)
+	assert.Equal(t, auth.Anonymous.Name, s.GetClaimedJobs(10, 0, nil)[0].UpdatedBy)
+}
+
+func TestAPIKeys_Management(t *testing.T) {
+	s, close := storage.NewMemoryStorage()
+	defer close()
+	router := newRouter(s, config.KEYS_AUTH, config.OIDCConfig{}, nil)
+	admin := newKey(t, s, "ops", auth.AdminScope)
+
+	rr := request(router, "POST", "/v1/apikeys", admin, `{"name": "bad name", "scope": "root"}`)
+	assert.Equal(t, 422, rr.Code)
+
+	rr = request(router, "POST", "/v1/apikeys", admin, `{"name": "dashboards", "scope": "read"}`)
+	assert.Equal(t, 201, rr.Code)
+	created := &struct {
+		Key    string       `json:"key"`
+		APIKey *auth.APIKey `json:"apiKey"`
+	}{}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), created))
+	assert.True(t, auth.IsKey(created.Key))
+	assert.Equal(t, auth.ReadScope, created.APIKey.Scope)
+	assert.Equal(t, created.Key[:len(created.APIKey.Prefix)], created.APIKey.Prefix)
+	assert.Equal(t, 200, request(router, "GET", "/v1/jobs", created.Key, "").Code)
+
+	rr = request(router, "GET", "/v1/apikeys", admin, "")
+	assert.Equal(t, 200, rr.Code)
+	assert.NotContains(t, rr.Body.String(), created.Key, "keys are only shown once")
+	listed := &struct {
+		APIKeys []*auth.APIKey `json:"apiKeys"`
+	}{}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), listed))
+	assert.Len(t, listed.APIKeys, 2)
+
+	rr = request(router, "DELETE", "/v1/apikeys/"+created.APIKey.Id.String(), admin, "")
+	assert.Equal(t, 202, rr.Code)
+	assert.Equal(t, 401, request(router, "GET", "/v1/jobs", created.Key, "").Code, "revoked keys stop working right away")
+	assert.Equal(t, 404, request(router, "DELETE", "/v1/apikeys/"+created.APIKey.Id.String(), admin, "").Code)
+}
+
+func TestOIDC_Tokens(t *testing.T) {
+	s, close := storage.NewMemoryStorage()
+	defer close()
+	p := authtest.NewProvider(t)
+	oidc := config.OIDCConfig{Issuer: "https://id.example.com", Audience: "ruok", ClientId: "ruok-ui"}
+	verifier := &auth.Verifier{
+		Issuer:     oidc.Issuer,
+		Audience:   oidc.Audience,
+		ClockSkew:  time.Minute,
+		RolesClaim: "groups",
+		Roles:      map[string]auth.Scope{"ruok-read": auth.ReadScope, "ruok-write": auth.WriteScope},
+		Keys:       p.KeySet(),
+	}
+	router := newRouter(s, config.OIDC_AUTH, oidc, verifier)
+
+	token := func(sub string, exp time.Time, groups ...string) string {
+		return p.Sign(map[string]interface{}{"iss": oidc.Issuer, "aud": oidc.Audience, "sub": sub, "exp": exp.Unix(), "groups": groups})
+	}
+	hour := time.Now().Add(time.Hour)
+	jobBody := `{"name": "with tokens", "cronexp": "*/5 * * * *", "endpoint": "http://localhost/", "httpmethod": "GET", "successStatuses": [200]}`
+
+	rr := request(router, "GET", "/v1/auth/config", "", "")
+	assert.Equal(t, 200, rr.Code)
+	assert.JSONEq(t, `{"mode": "oidc", "issuer": "https://id.example.com", "clientId": "ruok-ui", "audience": "ruok"}`, rr.Body.String())
+
+	assert.Equal(t, 200, request(router, "GET", "/v1/jobs", token("viewer", hour, "ruok-read"), "").Code)
+	assert.Equal(t, 403, request(router, "POST", "/v1/jobs", token("viewer", hour, "ruok-read"), jobBody).Code)
+	assert.Equal(t, 403, request(router, "GET", "/v1/jobs", token("stranger", hour, "other"), "").Code, "valid tokens without a known role can't do anything")
+	assert.Equal(t, 401, request(router, "GET", "/v1/jobs", token("viewer", time.Now().Add(-time.Hour), "ruok-read"), "").Code)
+	assert.Equal(t, 401, request(router, "GET", "/v1/jobs", authtest.NewProvider(t).Sign(map[string]interface{}{"iss": oidc.Issuer, "aud": oidc.Audience, "sub": "forger", "exp": hour.Unix(), "groups": []string{"ruok-write"}}), "").Code)
+
+	rr = request(router, "POST", "/v1/jobs", token("deployer", hour, "ruok-write"), jobBody)
+	assert.Equal(t, 201, rr.Code, rr.Body.String())
+	s.GetAvailableJobs(10, nil)
+	assert.Equal(t, "oidc:deployer", s.GetClaimedJobs(10, 0, nil)[0].UpdatedBy)
+
+	// api keys keep working next to tokens
+	reader := newKey(t, s, "dashboards", auth.ReadScope)
+	assert.Equal(t, 200, request(router, "GET", "/v1/jobs", reader, "").Code)
+
+	// without oidc tokens aren't accepted
+	router = newRouter(s, config.KEYS_AUTH, config.OIDCConfig{}, nil)
+	assert.Equal(t, 401, request(router, "GET", "/v1/jobs", token("viewer", hour, "ruok-read"), "").Code)
+	assert.JSONEq(t, `{"mode": "keys"}`, request(router, "GET", "/v1/auth/config", "", "").Body.String())
+}
+
+func TestCORSMiddleware(t *testing.T) {
+	tests := []struct {
+		name           string
+		allowed        []string
+		origin         string
+		expectedOrigin string
+	}{
+		{"Any", []string{"*"}, "https://elsewhere.example.com", "*"},
+		{"Listed", []string{"http://localhost:5173", "https://ruok.example.com"}, "https://ruok.example.com", "https://ruok.example.com"},
+		{"NotListed", []string{"https://ruok.example.com"}, "https://elsewhere.example.com", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expectedOrigin, allowedOrigin(tt.allowed, tt.origin))
+		})
+	}
+
+	router := newRouter(nil, config.NO_AUTH, config.OIDCConfig{}, nil)
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("OPTIONS", "/v1/jobs", nil)
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, 204, rr.Code)
+	assert.Equal(t, "*", rr.Header().Get("Access-Control-Allow-Origin"))
+}
@@ -8,6 +8,7 @@ import (
 	"os"
 	"testing"
 
+	v1 "github.com/back-end-labs/ruok/pkg/api/v1"
 	"github.com/back-end-labs/ruok/pkg/config"
 	"github.com/back-end-labs/ruok/pkg/storage"
 	"github.com/stretchr/testify/assert"
@@ -65,7 +66,7 @@ func TestCreateJob(t *testing.T) {
 				SuccessStatuses: []int{200},
 			},
 			expectedError:  true,
-			expectedStatus: http.StatusBadRequest,
+			expectedStatus: http.StatusUnprocessableEntity,
 		},
 		{
 			name: "InvalidCronExpression",
@@ -78,7 +79,7 @@ func TestCreateJob(t *testing.T) {
 				SuccessStatuses: []int{200},
 			},
 			expectedError:  true,
-			expectedStatus: http.StatusBadRequest,
+			expectedStatus: http.StatusUnprocessableEntity,
 		},
 		{
 			name: "MissingCronExpression",
@@ -90,7 +91,7 @@ func TestCreateJob(t *testing.T) {
 				SuccessStatuses: []int{200},
 			},
 			expectedError:  true,
-			expectedStatus: http.StatusBadRequest,
+			expectedStatus: http.StatusUnprocessableEntity,
 		},
 		{
 			name: "MissingEndpoint",
@@ -102,7 +103,7 @@ func TestCreateJob(t *testing.T) {
 				SuccessStatuses: []int{200},
 			},
 			expectedError:  true,
-			expectedStatus: http.StatusBadRequest,
+			expectedStatus: http.StatusUnprocessableEntity,
 		},
 		{
 			name: "InvalidEndpointURL",
@@ -115,7 +116,7 @@ func TestCreateJob(t *testing.T) {
 				SuccessStatuses: []int{200},
 			},
 			expectedError:  true,
-			expectedStatus: http.StatusBadRequest,
+			expectedStatus: http.StatusUnprocessableEntity,
 		},
 		{
 			name: "MissingHttpMethod",
@@ -127,7 +128,7 @@ func TestCreateJob(t *testing.T) {
 				SuccessStatuses: []int{200},
 			},
 			expectedError:  true,
-			expectedStatus: http.StatusBadRequest,
+			expectedStatus: http.StatusUnprocessableEntity,
 		},
 		{
 			name: "InvalidMethod",
@@ -140,7 +141,7 @@ func TestCreateJob(t *testing.T) {
 				SuccessStatuses: []int{200},
 			},
 			expectedError:  true,
-			expectedStatus: http.StatusBadRequest,
+			expectedStatus: http.StatusUnprocessableEntity,
 		},
 		{
 			name: "MissingSuccessStatuses",
@@ -152,7 +153,7 @@ func TestCreateJob(t *testing.T) {
 				HttpMethod:    "GET",
 			},
 			expectedError:  true,
-			expectedStatus: http.StatusBadRequest,
+			expectedStatus: http.StatusUnprocessableEntity,
 		},
 		{
 			name: "InvalidStrategy",
@@ -168,7 +169,7 @@ func TestCreateJob(t *testing.T) {
 				AlertEndpoint:   "https://something.com",
 			},
 			expectedError:  true,
-			expectedStatus: http.StatusBadRequest,
+			expectedStatus: http.StatusUnprocessableEntity,
 		},
 		{
 			name: "InvalidAlertEndpoint",
@@ -184,7 +185,7 @@ func TestCreateJob(t *testing.T) {
 				AlertEndpoint:   "https://:8000",
 			},
 			expectedError:  true,
-			expectedStatus: http.StatusBadRequest,
+			expectedStatus: http.StatusUnprocessableEntity,
 		},
 		{
 			name: "InvalidAlertMethod",
@@ -200,7 +201,7 @@ func TestCreateJob(t *testing.T) {
 				AlertEndpoint:   "https://something.com",
 			},
 			expectedError:  true,
-			expectedStatus: http.StatusBadRequest,
+			expectedStatus: http.StatusUnprocessableEntity,
 		},
 		{
 			name: "WithAlertButMissingMinFields",
@@ -215,16 +216,13 @@ func TestCreateJob(t *testing.T) {
 				AlertEndpoint:   "https://something.com",
 			},
 			expectedError:  true,
-			expectedStatus: http.StatusBadRequest,
+			expectedStatus: http.StatusUnprocessableEntity,
 		},
 	}
 
-	cfg := config.FromEnvs()
-	defer storage.Drop()
-	storage.Drop()
-	s, close := storage.NewStorage(&cfg)
+	s, close := storage.NewMemoryStorage()
 	defer close()
-	router := CreateRouter(s)
+	router := openRouter(s)
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 
@@ -241,6 +239,17 @@ func TestCreateJob(t *testing.T) {
 			router.ServeHTTP(w, req)
 
 			assert.Equal(t, tt.expectedStatus, w.Result().StatusCode)
+			if w.Code != http.StatusCreated {
+				return
+			}
+
+			res := v1.SaveJobResponse{}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+			assert.Equal(t, "/v1/jobs/"+res.Id.String(), w.Header().Get("Location"))
+			if assert.NotNil(t, res.Job, "the stored job is returned") {
+				assert.Equal(t, res.Id, res.Job.Id)
+				assert.Equal(t, tt.input.Name, res.Job.Name)
+			}
 		})
 	}
 }
@@ -0,0 +1,237 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+
+	v1 "github.com/back-end-labs/ruok/pkg/api/v1"
+	"github.com/back-end-labs/ruok/pkg/storage"
+)
+
+var monitors = `
+version: 1
+jobs:
+  checkout:
+    name: Checkout
+    cronexp: "*/5 * * * *"
+    endpoint: http://example.com/checkout
+    httpmethod: get
+    successStatuses: [200]
+    alertStrategy: http
+    alertMethod: POST
+    alertEndpoint: http://example.com/alerts
+    labels:
+      team: payments
+  search:
+    name: Search
+    cronexp: "*/10 * * * *"
+    timezone: Europe/Madrid
+    endpoint: http://example.com/search
+    httpmethod: GET
+    successStatuses: [200, 204]
+`
+
+func importRequest(t *testing.T, router http.Handler, query string, contentType string, body string) (int, v1.ImportResponse) {
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/v1/import"+query, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	router.ServeHTTP(rr, req)
+	res := v1.ImportResponse{}
+	if rr.Code == http.StatusOK {
+		assert.Nil(t, json.Unmarshal(rr.Body.Bytes(), &res))
+	}
+	return rr.Code, res
+}
+
+func exportRequest(t *testing.T, router http.Handler, format string) string {
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/v1/export?format="+format, nil)
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	return rr.Body.String()
+}
+
+func TestImport(t *testing.T) {
+	s, close := storage.NewMemoryStorage()
+	defer close()
+	router := openRouter(s)
+
+	code, res := importRequest(t, router, "?dryRun=true", "application/yaml", monitors)
+	assert.Equal(t, http.StatusOK, code)
+	assert.True(t, res.DryRun)
+	assert.Equal(t, 2, res.Created)
+	assert.Equal(t, "checkout", res.Changes[0].Key)
+	assert.Equal(t, v1.ChangeCreate, res.Changes[0].Action)
+	assert.Empty(t, s.GetJobIds(nil), "dry runs change nothing")
+
+	code, res = importRequest(t, router, "", "application/yaml", monitors)
+	assert.Equal(t, http.StatusOK, code)
+	assert.False(t, res.DryRun)
+	assert.Equal(t, 2, res.Created)
+	assert.Len(t, s.GetJobIds(nil), 2)
+
+	code, res = importRequest(t, router, "", "application/yaml", monitors)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 0, res.Created)
+	assert.Equal(t, 2, res.Unchanged, "importing the same definitions again changes nothing")
+	assert.Len(t, s.GetJobIds(nil), 2)
+
+	changed := strings.Replace(monitors, `cronexp: "*/10 * * * *"`, `cronexp: "*/15 * * * *"`, 1)
+	code, res = importRequest(t, router, "", "application/yaml", changed)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 1, res.Updated)
+	update := res.Changes[1]
+	assert.Equal(t, v1.ChangeUpdate, update.Action)
+	if assert.NotNil(t, update.Id) {
+		assert.Equal(t, "*/15 * * * *", s.GetJobUpdates(*update.Id).Cron_exp_string)
+	}
+	assert.Equal(t, []v1.FieldDiff{{Field: "cronexp", From: "*/10 * * * *", To: "*/15 * * * *"}}, update.Diff)
+	assert.Len(t, s.GetJobIds(nil), 2)
+}
+
+func TestImport_Invalid(t *testing.T) {
+	s, close := storage.NewMemoryStorage()
+	defer close()
+	router := openRouter(s)
+
+	code, _ := importRequest(t, router, "", "application/yaml", "version: 1\njobs:\n  a:\n    nme: typo\n")
+	assert.Equal(t, http.StatusBadRequest, code, "unknown fields are not ignored")
+	code, _ = importRequest(t, router, "", "application/json", "not json")
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/v1/import", strings.NewReader(`{"version": 2, "jobs": {"bad key!": {}, "checkout": {"name": "Checkout", "cronexp": "nope"}}}`))
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	p := v1.Problem{}
+	assert.Nil(t, json.Unmarshal(rr.Body.Bytes(), &p))
+	fields := map[string]bool{}
+	for _, e := range p.Errors {
+		fields[e.Field] = true
+	}
+	assert.True(t, fields["version"])
+	assert.True(t, fields["jobs.bad key!"])
+	assert.True(t, fields["jobs.checkout.cronexp"])
+	assert.True(t, fields["jobs.checkout.endpoint"])
+	assert.Empty(t, s.GetJobIds(nil), "nothing is imported when a definition is not valid")
+}
+
+func TestImport_HiddenJobs(t *testing.T) {
+	s, close := storage.NewSQLiteStorage(filepath.Join(t.TempDir(), "ruok.db"))
+	defer close()
+	router := openRouter(s)
+
+	code, _ := importRequest(t, router, "", "application/yaml", monitors)
+	assert.Equal(t, http.StatusOK, code)
+	_, err := s.Db.Exec("UPDATE ruok.jobs SET claimed_by = 'other-scheduler', status = 'claimed'")
+	assert.Nil(t, err)
+
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/v1/import", strings.NewReader(monitors))
+	req.Header.Set("Content-Type", "application/yaml")
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusConflict, rr.Code, "jobs other schedulers claimed are not created again")
+	p := v1.Problem{}
+	assert.Nil(t, json.Unmarshal(rr.Body.Bytes(), &p))
+	assert.Equal(t, "hidden_jobs", p.Code)
+	var count int
+	assert.Nil(t, s.Db.QueryRow("SELECT count(*) FROM ruok.jobs").Scan(&count))
+	assert.Equal(t, 2, count)
+}
+
+// Refuses to create the jobs named "Search"
+type refusingStorage struct {
+	storage.APIStorage
+}
+
+func (rs *refusingStorage) CreateJob(j storage.CreateJobInput) (uuid.UUID, error) {
+	if j.Name == "Search" {
+		return uuid.Nil, errors.New("could not insert into job")
+	}
+	return rs.APIStorage.CreateJob(j)
+}
+
+func TestImport_KeepsWhatWasApplied(t *testing.T) {
+	s, close := storage.NewMemoryStorage()
+	defer close()
+	router := openRouter(&refusingStorage{s})
+
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/v1/import", strings.NewReader(monitors))
+	req.Header.Set("Content-Type", "application/yaml")
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	p := v1.Problem{}
+	assert.Nil(t, json.Unmarshal(rr.Body.Bytes(), &p))
+	if assert.Len(t, p.Changes, 2) {
+		checkout, search := p.Changes[0], p.Changes[1]
+		assert.Equal(t, "checkout", checkout.Key)
+		assert.Empty(t, checkout.Error)
+		if assert.NotNil(t, checkout.Id, "created jobs tell their id") {
+			_, err := s.GetJob(*checkout.Id)
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, "search", search.Key)
+		assert.Contains(t, search.Error, "could not create the job \"search\"")
+		assert.Nil(t, search.Id)
+	}
+	assert.Len(t, s.GetJobIds(nil), 1, "the changes before and after a failed one are applied")
+}
+
+func TestExport(t *testing.T) {
+	s, close := storage.NewMemoryStorage()
+	defer close()
+	router := openRouter(s)
+
+	_, err := s.CreateJob(storage.CreateJobInput{
+		Name:            "Unnamed",
+		CronExpString:   "*/5 * * * *",
+		Endpoint:        "http://example.com",
+		HttpMethod:      "GET",
+		SuccessStatuses: []int{200},
+	})
+	assert.NoError(t, err)
+	code, _ := importRequest(t, router, "", "application/yaml", monitors)
+	assert.Equal(t, http.StatusOK, code)
+
+	d, err := v1.DecodeDefinitions([]byte(exportRequest(t, router, v1.FormatJSON)), v1.FormatJSON)
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, v1.DefinitionsVersion, d.Version)
+	assert.Len(t, d.Jobs, 3)
+	checkout := d.Jobs["checkout"]
+	assert.Equal(t, "GET", checkout.HttpMethod)
+	assert.Equal(t, "http://example.com/alerts", checkout.AlertEndpoint, "alert settings are exported")
+	assert.Equal(t, map[string]string{"team": "payments"}, checkout.Labels, "the key is not one of the labels")
+	id := s.GetJobIds(nil)[0]
+	assert.Contains(t, d.Jobs, id.String(), "jobs without a key are keyed by their id")
+
+	yamlExport := exportRequest(t, router, v1.FormatYAML)
+	assert.Equal(t, yamlExport, exportRequest(t, router, v1.FormatYAML), "exports are stable")
+
+	// what is exported imports as it is
+	code, res := importRequest(t, router, "", "application/yaml", yamlExport)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 3, res.Unchanged)
+
+	other, closeOther := storage.NewMemoryStorage()
+	defer closeOther()
+	code, res = importRequest(t, openRouter(other), "", "application/yaml", yamlExport)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 3, res.Created, "definitions move between instances")
+	assert.Equal(t, yamlExport, exportRequest(t, openRouter(other), v1.FormatYAML))
+
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/v1/export?format=xml", nil)
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
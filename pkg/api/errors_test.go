@@ -0,0 +1,79 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+
+	v1 "github.com/back-end-labs/ruok/pkg/api/v1"
+	"github.com/back-end-labs/ruok/pkg/config"
+	"github.com/back-end-labs/ruok/pkg/storage"
+)
+
+// Every error is a problem telling what went wrong with a code clients can switch on
+func TestProblems(t *testing.T) {
+	s, close := storage.NewMemoryStorage()
+	defer close()
+	_, err := s.CreateJob(storage.CreateJobInput{
+		Name:            "Job 1",
+		CronExpString:   "*/1 * * * *",
+		Endpoint:        "http://example.com",
+		HttpMethod:      "GET",
+		SuccessStatuses: []int{200},
+	})
+	assert.NoError(t, err)
+	jobId := s.GetAvailableJobs(100, nil)[0].Id
+	router := openRouter(s)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+		code   string
+	}{
+		{"BadId", "GET", "/v1/jobs/nope", "", http.StatusBadRequest, v1.CodeBadRequest},
+		{"MissingJob", "GET", "/v1/jobs/" + uuid.Must(uuid.NewV7()).String(), "", http.StatusNotFound, v1.CodeNotFound},
+		{"ResumeNotPaused", "POST", "/v1/jobs/" + jobId.String() + "/resume", "", http.StatusConflict, "wrong_state"},
+		{"InvalidFields", "PATCH", "/v1/jobs/" + jobId.String(), `{"cronexp": "nope", "endpoint": ""}`, http.StatusUnprocessableEntity, v1.CodeValidationFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := request(router, tt.method, tt.path, "", tt.body)
+			assert.Equal(t, tt.status, rr.Code, rr.Body.String())
+			assert.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"))
+
+			p := v1.Problem{}
+			assert.Nil(t, json.Unmarshal(rr.Body.Bytes(), &p))
+			assert.Equal(t, tt.status, p.Status)
+			assert.Equal(t, tt.code, p.Code)
+			assert.Equal(t, "urn:ruok:problem:"+tt.code, p.Type)
+			assert.Equal(t, http.StatusText(tt.status), p.Title)
+			assert.NotEmpty(t, p.Detail)
+		})
+	}
+
+	rr := request(router, "PATCH", "/v1/jobs/"+jobId.String(), "", `{"cronexp": "nope", "endpoint": ""}`)
+	p := v1.Problem{}
+	assert.Nil(t, json.Unmarshal(rr.Body.Bytes(), &p))
+	fields := map[string]string{}
+	for _, e := range p.Errors {
+		fields[e.Field] = e.Code
+	}
+	assert.Equal(t, map[string]string{"cronexp": v1.FieldInvalid, "endpoint": v1.FieldRequired}, fields)
+}
+
+func TestProblems_Auth(t *testing.T) {
+	s, close := storage.NewMemoryStorage()
+	defer close()
+	router := newRouter(s, config.KEYS_AUTH, config.OIDCConfig{}, nil)
+
+	rr := request(router, "GET", "/v1/jobs", "", "")
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"))
+	assert.Contains(t, rr.Body.String(), `"code":"unauthorized"`)
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/back-end-labs/ruok/pkg/job"
+	"github.com/back-end-labs/ruok/pkg/storage"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJobHistory(t *testing.T) {
+	s, close := storage.NewMemoryStorage()
+	defer close()
+	_, err := s.CreateJob(storage.CreateJobInput{
+		Name:            "original",
+		CronExpString:   "*/5 * * * *",
+		MaxRetries:      1,
+		Endpoint:        "http://localhost:8080/v1/status",
+		HttpMethod:      "GET",
+		SuccessStatuses: []int{200},
+	})
+	assert.NoError(t, err)
+	j := s.GetAvailableJobs(1, nil)[0]
+	assert.NoError(t, s.UpdateJob(storage.UpdateJobInput{
+		Id:              j.Id,
+		Name:            "renamed",
+		CronExpString:   "*/5 * * * *",
+		MaxRetries:      1,
+		Endpoint:        "http://localhost:8080/v1/status",
+		HttpMethod:      "GET",
+		SuccessStatuses: []int{200},
+	}))
+	assert.NoError(t, s.DeleteJob(j.Id, ""))
+	router := openRouter(s)
+
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/v1/jobs/"+j.Id.String()+"/history?limit=2", nil)
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	body := &struct {
+		Revisions []*job.Revision `json:"revisions"`
+	}{}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), body))
+	if assert.Len(t, body.Revisions, 2) {
+		assert.Equal(t, job.ActionDelete, body.Revisions[0].Action)
+		assert.Equal(t, job.ActionUpdate, body.Revisions[1].Action)
+		assert.Equal(t, []job.FieldChange{{Field: "name", Before: "original", After: "renamed"}}, body.Revisions[1].Changes)
+	}
+
+	unknown, _ := uuid.NewV7()
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		expectedStatus int
+	}{
+		{"BadId", "GET", "/v1/jobs/not-an-id/history", http.StatusBadRequest},
+		{"BadLimit", "GET", "/v1/jobs/" + j.Id.String() + "/history?limit=0", http.StatusBadRequest},
+		{"BadOffset", "GET", "/v1/jobs/" + j.Id.String() + "/history?offset=-1", http.StatusBadRequest},
+		{"RestoreBadRevision", "POST", "/v1/jobs/" + j.Id.String() + "/history/first/restore", http.StatusBadRequest},
+		{"RestoreUnknownJob", "POST", "/v1/jobs/" + unknown.String() + "/history/1/restore", http.StatusNotFound},
+		{"RestoreUnknownRevision", "POST", "/v1/jobs/" + j.Id.String() + "/history/9/restore", http.StatusNotFound},
+		{"RestoreDeletion", "POST", "/v1/jobs/" + j.Id.String() + "/history/3/restore", http.StatusConflict},
+		{"Restore", "POST", "/v1/jobs/" + j.Id.String() + "/history/1/restore", http.StatusAccepted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			req, _ := http.NewRequest(tt.method, tt.path, nil)
+			router.ServeHTTP(rr, req)
+			assert.Equal(t, tt.expectedStatus, rr.Code, rr.Body.String())
+		})
+	}
+
+	updates := s.GetJobUpdates(j.Id)
+	assert.Equal(t, "original", updates.Job_name)
+	assert.Zero(t, updates.Deleted_at)
+}
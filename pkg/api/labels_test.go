@@ -0,0 +1,174 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/back-end-labs/ruok/pkg/job"
+	"github.com/back-end-labs/ruok/pkg/storage"
+)
+
+func createLabeledJobs(t *testing.T, s storage.Storage) {
+	for _, l := range []map[string]string{
+		{"env": "prod", "team": "payments"},
+		{"env": "prod", "team": "search"},
+		{"env": "dev", "team": "payments"},
+	} {
+		_, err := s.CreateJob(storage.CreateJobInput{
+			Name:            l["env"] + " " + l["team"],
+			CronExpString:   "*/5 * * * *",
+			MaxRetries:      1,
+			Endpoint:        "http://localhost:8080/v1/status",
+			HttpMethod:      "GET",
+			SuccessStatuses: []int{200},
+			Labels:          l,
+		})
+		assert.NoError(t, err)
+	}
+}
+
+func TestListJobs_Selector(t *testing.T) {
+	s, close := storage.NewMemoryStorage()
+	defer close()
+	createLabeledJobs(t, s)
+	assert.Len(t, s.GetAvailableJobs(10, nil), 3)
+	router := openRouter(s)
+
+	tests := []struct {
+		selector     string
+		expectedJobs int
+	}{
+		{"", 3},
+		{"env=prod", 2},
+		{"env=prod,team=payments", 1},
+		{"team!=payments", 1},
+		{"!team", 0},
+	}
+
+	for _, test := range tests {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/v1/jobs?selector="+url.QueryEscape(test.selector), nil)
+		router.ServeHTTP(rr, req)
+		assert.Equal(t, 200, rr.Code)
+		body := &struct {
+			Jobs []*job.Job `json:"jobs"`
+		}{}
+		assert.Nil(t, json.Unmarshal(rr.Body.Bytes(), body))
+		assert.Len(t, body.Jobs, test.expectedJobs, test.selector)
+		for _, j := range body.Jobs {
+			assert.NotEmpty(t, j.Labels)
+		}
+	}
+}
+
+type bulkResponse struct {
+	Selector string      `json:"selector"`
+	Matched  int         `json:"matched"`
+	Changed  []uuid.UUID `json:"changed"`
+	Skipped  []uuid.UUID `json:"skipped"`
+	Failed   []any       `json:"failed"`
+}
+
+func bulkRequest(t *testing.T, router http.Handler, method string, path string, selector string, body string) (int, bulkResponse) {
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, path+"?selector="+url.QueryEscape(selector), strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(rr, req)
+	res := bulkResponse{}
+	if rr.Code == http.StatusOK {
+		assert.Nil(t, json.Unmarshal(rr.Body.Bytes(), &res))
+	}
+	return rr.Code, res
+}
+
+func TestBulkPauseAndResume(t *testing.T) {
+	s, close := storage.NewMemoryStorage()
+	defer close()
+	createLabeledJobs(t, s)
+	router := openRouter(s)
+
+	code, res := bulkRequest(t, router, "POST", "/v1/jobs/pause", "team=payments", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "team=payments", res.Selector)
+	assert.Equal(t, 2, res.Matched)
+	assert.Len(t, res.Changed, 2)
+	for _, id := range res.Changed {
+		assert.Equal(t, "paused", s.GetJobUpdates(id).Status)
+	}
+	assert.Len(t, s.GetAvailableJobs(10, nil), 1, "only the job outside the selector can be claimed")
+
+	code, res = bulkRequest(t, router, "POST", "/v1/jobs/resume", "env=prod", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 2, res.Matched)
+	assert.Len(t, res.Changed, 1)
+	assert.Len(t, res.Skipped, 1, "jobs that are not paused are skipped")
+	assert.Empty(t, res.Failed)
+
+	code, _ = bulkRequest(t, router, "POST", "/v1/jobs/pause", "", "")
+	assert.Equal(t, http.StatusBadRequest, code, "bulk changes need a selector")
+	code, _ = bulkRequest(t, router, "POST", "/v1/jobs/pause", "-env", "")
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, res = bulkRequest(t, router, "POST", "/v1/jobs/pause", "env=staging", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 0, res.Matched)
+}
+
+func TestBulkUpdateAlerts(t *testing.T) {
+	s, close := storage.NewMemoryStorage()
+	defer close()
+	createLabeledJobs(t, s)
+	router := openRouter(s)
+
+	alerts := `{"alertStrategy": "http", "alertMethod": "POST", "alertEndpoint": "http://localhost:8080/alerts"}`
+	code, res := bulkRequest(t, router, "PUT", "/v1/jobs/alerts", "env=prod", alerts)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, res.Changed, 2)
+	for _, id := range res.Changed {
+		updates := s.GetJobUpdates(id)
+		assert.Equal(t, "http", updates.Alert_strategy)
+		assert.Equal(t, "http://localhost:8080/alerts", updates.Alert_endpoint)
+	}
+
+	code, _ = bulkRequest(t, router, "PUT", "/v1/jobs/alerts", "env=prod", `{"alertStrategy": "http"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, code, "alerts must be complete")
+	code, _ = bulkRequest(t, router, "PUT", "/v1/jobs/alerts", "env=prod", `not json`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = bulkRequest(t, router, "PUT", "/v1/jobs/alerts", "", alerts)
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestCreateMaintenanceWindow_Selector(t *testing.T) {
+	s, close := storage.NewMemoryStorage()
+	defer close()
+	router := openRouter(s)
+
+	for _, test := range []struct {
+		selector string
+		status   int
+		expected string
+	}{
+		{" zone = eu , !legacy ", 201, "zone=eu,!legacy"},
+		{"  ", 201, ""},
+		{"zone=eu,=payments", 422, ""},
+	} {
+		rr := httptest.NewRecorder()
+		body := `{"name": "upgrade", "mode": "skip", "cronexp": "0 2 * * *", "durationSeconds": 60, "selector": "` + test.selector + `"}`
+		req, _ := http.NewRequest("POST", "/v1/maintenance", strings.NewReader(body))
+		router.ServeHTTP(rr, req)
+		assert.Equal(t, test.status, rr.Code, test.selector)
+	}
+
+	windows := s.GetMaintenanceWindows()
+	if assert.Len(t, windows, 2) {
+		assert.Equal(t, "zone=eu,!legacy", windows[0].Selector, "selectors are stored the way Parse reads them")
+		assert.Empty(t, windows[1].Selector, "blank selectors don't scope the window")
+	}
+}
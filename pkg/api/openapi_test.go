@@ -0,0 +1,143 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+
+	v1 "github.com/back-end-labs/ruok/pkg/api/v1"
+	"github.com/back-end-labs/ruok/pkg/auth"
+	"github.com/back-end-labs/ruok/pkg/config"
+	"github.com/back-end-labs/ruok/pkg/storage"
+)
+
+// Every route must be in v1.Operations and every operation must be a route
+func TestOpenAPI_MatchesRoutes(t *testing.T) {
+	router := openRouter(nil)
+
+	routes := map[string]bool{}
+	for _, r := range router.Routes() {
+		if !strings.HasPrefix(r.Path, "/v1/") || r.Method == http.MethodOptions {
+			continue
+		}
+		routes[r.Method+" "+r.Path] = true
+	}
+
+	operations := map[string]bool{}
+	ids := map[string]bool{}
+	for _, op := range v1.Operations {
+		operations[op.Method+" /v1"+op.Path] = true
+		assert.False(t, ids[op.Id], "operation id %s is repeated", op.Id)
+		ids[op.Id] = true
+	}
+
+	for route := range routes {
+		assert.True(t, operations[route], "route %s is missing from v1.Operations", route)
+	}
+	for op := range operations {
+		assert.True(t, routes[op], "operation %s is not a route", op)
+	}
+}
+
+// The scope of every operation must be the one the router asks for
+func TestOpenAPI_MatchesScopes(t *testing.T) {
+	s, close := storage.NewMemoryStorage()
+	defer close()
+	router := newRouter(s, config.KEYS_AUTH, config.OIDCConfig{}, nil)
+
+	keys := map[auth.Scope]string{}
+	for _, scope := range []auth.Scope{auth.ReadScope, auth.WriteScope, auth.AdminScope} {
+		keys[scope] = newKey(t, s, string(scope), scope)
+	}
+	// the scope right below each one, which must not be enough
+	below := map[auth.Scope]string{auth.ReadScope: "", auth.WriteScope: keys[auth.ReadScope], auth.AdminScope: keys[auth.WriteScope]}
+
+	for _, op := range v1.Operations {
+		t.Run(op.Id, func(t *testing.T) {
+			path := "/v1" + strings.NewReplacer(":id", uuid.Nil.String(), ":revision", "1").Replace(op.Path)
+
+			if op.Scope == "" {
+				assert.NotEqual(t, 401, request(router, op.Method, path, "", "").Code)
+				return
+			}
+
+			code := request(router, op.Method, path, keys[op.Scope], "{}").Code
+			assert.NotEqual(t, 401, code)
+			assert.NotEqual(t, 403, code)
+
+			code = request(router, op.Method, path, below[op.Scope], "{}").Code
+			assert.Contains(t, []int{401, 403}, code)
+		})
+	}
+}
+
+func TestOpenAPI_Spec(t *testing.T) {
+	router := openRouter(nil)
+
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/v1/openapi.json", nil)
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, 200, rr.Code)
+	spec := map[string]any{}
+	if !assert.Nil(t, json.Unmarshal(rr.Body.Bytes(), &spec)) {
+		t.FailNow()
+	}
+	assert.Equal(t, "3.0.3", spec["openapi"])
+
+	paths := spec["paths"].(map[string]any)
+	for _, op := range v1.Operations {
+		operation, ok := paths[op.OpenAPIPath()].(map[string]any)[strings.ToLower(op.Method)].(map[string]any)
+		if !assert.True(t, ok, "%s %s is missing", op.Method, op.OpenAPIPath()) {
+			continue
+		}
+		assert.Equal(t, op.Id, operation["operationId"])
+
+		// every param in the path must be described
+		declared := map[string]bool{}
+		for _, p := range op.Params {
+			if p.In == "path" {
+				declared[p.Name] = true
+			}
+		}
+		for _, segment := range strings.Split(op.Path, "/") {
+			if name, ok := strings.CutPrefix(segment, ":"); ok {
+				assert.True(t, declared[name], "%s does not describe the %q param", op.Id, name)
+				delete(declared, name)
+			}
+		}
+		assert.Empty(t, declared, "%s describes params that are not in its path", op.Id)
+	}
+
+	// every reference must point to a schema
+	schemas := spec["components"].(map[string]any)["schemas"].(map[string]any)
+	var walk func(v any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			if ref, ok := v["$ref"].(string); ok {
+				name := strings.TrimPrefix(ref, "#/components/schemas/")
+				assert.Contains(t, schemas, name, "%s does not point to a schema", ref)
+			}
+			for _, child := range v {
+				walk(child)
+			}
+		case []any:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	walk(spec)
+
+	// bodies are described with their json names
+	job := schemas["Job"].(map[string]any)["properties"].(map[string]any)
+	assert.Contains(t, job, "cronexp")
+	assert.NotContains(t, job, "CronExp")
+	assert.NotContains(t, job, "Doer")
+}
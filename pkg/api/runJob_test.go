@@ -0,0 +1,112 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/back-end-labs/ruok/pkg/job"
+	"github.com/back-end-labs/ruok/pkg/storage"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// Only implements what the run route needs
+type runStorage struct {
+	storage.APIStorage
+	requestErr error
+	result     *job.ExecutionResult
+	runId      uuid.UUID
+}
+
+func (rs *runStorage) RequestRun(jobId uuid.UUID) (uuid.UUID, error) {
+	return rs.runId, rs.requestErr
+}
+
+func (rs *runStorage) GetRunResult(runId uuid.UUID) *job.ExecutionResult {
+	return rs.result
+}
+
+func TestRunJob(t *testing.T) {
+	jobId, _ := uuid.NewV7()
+	runId, _ := uuid.NewV7()
+
+	tests := []struct {
+		name           string
+		path           string
+		storage        *runStorage
+		expectedStatus int
+		expectResult   bool
+	}{
+		{
+			name:           "NoWait",
+			path:           "/v1/jobs/" + jobId.String() + "/run",
+			storage:        &runStorage{runId: runId},
+			expectedStatus: http.StatusAccepted,
+		},
+		{
+			name:           "Wait",
+			path:           "/v1/jobs/" + jobId.String() + "/run?wait=true",
+			storage:        &runStorage{runId: runId, result: &job.ExecutionResult{Status: 200, Message: "OK"}},
+			expectedStatus: http.StatusOK,
+			expectResult:   true,
+		},
+		{
+			name:           "WaitTimesOut",
+			path:           "/v1/jobs/" + jobId.String() + "/run?wait=true&timeout=1",
+			storage:        &runStorage{runId: runId},
+			expectedStatus: http.StatusAccepted,
+		},
+		{
+			name:           "BadTimeout",
+			path:           "/v1/jobs/" + jobId.String() + "/run?wait=true&timeout=600",
+			storage:        &runStorage{runId: runId},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "BadId",
+			path:           "/v1/jobs/not-an-id/run",
+			storage:        &runStorage{runId: runId},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "NotFound",
+			path:           "/v1/jobs/" + jobId.String() + "/run",
+			storage:        &runStorage{requestErr: storage.ErrNotFound},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "NotClaimed",
+			path:           "/v1/jobs/" + jobId.String() + "/run",
+			storage:        &runStorage{requestErr: storage.ErrNotClaimed},
+			expectedStatus: http.StatusConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := openRouter(tt.storage)
+			rr := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", tt.path, nil)
+			router.ServeHTTP(rr, req)
+			assert.Equal(t, tt.expectedStatus, rr.Code, rr.Body.String())
+			if rr.Code != http.StatusOK && rr.Code != http.StatusAccepted {
+				return
+			}
+
+			body := &struct {
+				RunId  uuid.UUID            `json:"runId"`
+				Result *job.ExecutionResult `json:"result"`
+			}{}
+			assert.Nil(t, json.Unmarshal(rr.Body.Bytes(), body))
+			assert.Equal(t, runId, body.RunId)
+			if tt.expectResult {
+				assert.Equal(t, tt.storage.result.Status, body.Result.Status)
+				assert.Equal(t, tt.storage.result.Message, body.Result.Message)
+			} else {
+				assert.Nil(t, body.Result)
+			}
+		})
+	}
+}
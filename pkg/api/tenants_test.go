@@ -0,0 +1,84 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	v1 "github.com/back-end-labs/ruok/pkg/api/v1"
+	"github.com/back-end-labs/ruok/pkg/auth"
+	"github.com/back-end-labs/ruok/pkg/config"
+	"github.com/back-end-labs/ruok/pkg/job"
+	"github.com/back-end-labs/ruok/pkg/storage"
+)
+
+func newTenantKey(t *testing.T, s storage.Storage, tenant string, scope auth.Scope) string {
+	key, _, err := v1.NewAPIKey(s, tenant, scope, tenant)
+	if err != nil {
+		t.Fatalf("could not create api key: %q", err.Error())
+	}
+	return key
+}
+
+func TestTenants_Isolation(t *testing.T) {
+	s, close := storage.NewMemoryStorage()
+	defer close()
+	router := newRouter(s, config.KEYS_AUTH, config.OIDCConfig{}, nil)
+	acme := newTenantKey(t, s, "acme", auth.AdminScope)
+	globex := newTenantKey(t, s, "globex", auth.AdminScope)
+	defaultAdmin := newKey(t, s, "ops", auth.AdminScope)
+
+	jobBody := `{"name": "acme job", "cronexp": "*/5 * * * *", "endpoint": "http://localhost/", "httpmethod": "GET", "successStatuses": [200]}`
+	assert.Equal(t, 201, request(router, "POST", "/v1/jobs", acme, jobBody).Code)
+	jobs := s.GetAvailableJobs(10, nil)
+	if !assert.Len(t, jobs, 1) {
+		t.FailNow()
+	}
+	path := "/v1/jobs/" + jobs[0].Id.String()
+
+	listed := &struct {
+		Jobs []*job.Job `json:"jobs"`
+	}{}
+	rr := request(router, "GET", "/v1/jobs", acme, "")
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), listed))
+	assert.Len(t, listed.Jobs, 1)
+	rr = request(router, "GET", "/v1/jobs", globex, "")
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), listed))
+	assert.Empty(t, listed.Jobs, "jobs of other tenants are not listed")
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		key            string
+		body           string
+		expectedStatus int
+	}{
+		{"OtherTenantCantPause", "POST", path + "/pause", globex, "", 404},
+		{"OtherTenantCantUpdate", "PUT", path, globex, jobBody, 404},
+		{"OtherTenantCantDelete", "DELETE", path, globex, "", 404},
+		{"OtherTenantCantRestore", "POST", path + "/history/1/restore", globex, "", 404},
+		{"OwnerCanPause", "POST", path + "/pause", acme, "", 202},
+		{"KeysOnlyForOwnTenant", "POST", "/v1/apikeys", acme, `{"name": "sneaky", "scope": "read", "tenant": "globex"}`, 403},
+		{"KeysForOwnTenant", "POST", "/v1/apikeys", acme, `{"name": "dashboards", "scope": "read", "tenant": "acme"}`, 201},
+		{"TenantsCantChangeMaintenance", "POST", "/v1/maintenance", acme, `{}`, 403},
+		{"TenantsCanListMaintenance", "GET", "/v1/maintenance", acme, "", 200},
+		{"DefaultTenantChangesMaintenance", "DELETE", "/v1/maintenance/not-an-id", defaultAdmin, "", 400},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := request(router, tt.method, tt.path, tt.key, tt.body)
+			assert.Equal(t, tt.expectedStatus, rr.Code, rr.Body.String())
+		})
+	}
+
+	keys := &struct {
+		APIKeys []*auth.APIKey `json:"apiKeys"`
+	}{}
+	rr = request(router, "GET", "/v1/apikeys", globex, "")
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), keys))
+	if assert.Len(t, keys.APIKeys, 1, "keys of other tenants are not listed") {
+		assert.Equal(t, "globex", keys.APIKeys[0].Tenant)
+	}
+}
@@ -7,10 +7,13 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 
+	v1 "github.com/back-end-labs/ruok/pkg/api/v1"
 	"github.com/back-end-labs/ruok/pkg/config"
 	"github.com/back-end-labs/ruok/pkg/storage"
+	"github.com/gofrs/uuid"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -65,7 +68,7 @@ func TestUpdateJob(t *testing.T) {
 				SuccessStatuses: []int{200},
 			},
 			expectedError:  true,
-			expectedStatus: http.StatusBadRequest,
+			expectedStatus: http.StatusUnprocessableEntity,
 		},
 		{
 			name: "InvalidCronExpression",
@@ -78,7 +81,7 @@ func TestUpdateJob(t *testing.T) {
 				SuccessStatuses: []int{200},
 			},
 			expectedError:  true,
-			expectedStatus: http.StatusBadRequest,
+			expectedStatus: http.StatusUnprocessableEntity,
 		},
 		{
 			name: "MissingCronExpression",
@@ -90,7 +93,7 @@ func TestUpdateJob(t *testing.T) {
 				SuccessStatuses: []int{200},
 			},
 			expectedError:  true,
-			expectedStatus: http.StatusBadRequest,
+			expectedStatus: http.StatusUnprocessableEntity,
 		},
 		{
 			name: "MissingEndpoint",
@@ -102,7 +105,7 @@ func TestUpdateJob(t *testing.T) {
 				SuccessStatuses: []int{200},
 			},
 			expectedError:  true,
-			expectedStatus: http.StatusBadRequest,
+			expectedStatus: http.StatusUnprocessableEntity,
 		},
 		{
 			name: "InvalidEndpointURL",
@@ -115,7 +118,7 @@ func TestUpdateJob(t *testing.T) {
 				SuccessStatuses: []int{200},
 			},
 			expectedError:  true,
-			expectedStatus: http.StatusBadRequest,
+			expectedStatus: http.StatusUnprocessableEntity,
 		},
 		{
 			name: "MissingHttpMethod",
@@ -127,7 +130,7 @@ func TestUpdateJob(t *testing.T) {
 				SuccessStatuses: []int{200},
 			},
 			expectedError:  true,
-			expectedStatus: http.StatusBadRequest,
+			expectedStatus: http.StatusUnprocessableEntity,
 		},
 		{
 			name: "InvalidMethod",
@@ -140,7 +143,7 @@ func TestUpdateJob(t *testing.T) {
 				SuccessStatuses: []int{200},
 			},
 			expectedError:  true,
-			expectedStatus: http.StatusBadRequest,
+			expectedStatus: http.StatusUnprocessableEntity,
 		},
 		{
 			name: "MissingSuccessStatuses",
@@ -152,7 +155,7 @@ func TestUpdateJob(t *testing.T) {
 				HttpMethod:    "GET",
 			},
 			expectedError:  true,
-			expectedStatus: http.StatusBadRequest,
+			expectedStatus: http.StatusUnprocessableEntity,
 		},
 		{
 			name: "InvalidStrategy",
@@ -168,7 +171,7 @@ func TestUpdateJob(t *testing.T) {
 				AlertEndpoint:   "https://something.com",
 			},
 			expectedError:  true,
-			expectedStatus: http.StatusBadRequest,
+			expectedStatus: http.StatusUnprocessableEntity,
 		},
 		{
 			name: "InvalidAlertEndpoint",
@@ -184,7 +187,7 @@ func TestUpdateJob(t *testing.T) {
 				AlertEndpoint:   ":8000",
 			},
 			expectedError:  true,
-			expectedStatus: http.StatusBadRequest,
+			expectedStatus: http.StatusUnprocessableEntity,
 		},
 		{
 			name: "InvalidAlertMethod",
@@ -200,7 +203,7 @@ func TestUpdateJob(t *testing.T) {
 				AlertEndpoint:   "https://something.com",
 			},
 			expectedError:  true,
-			expectedStatus: http.StatusBadRequest,
+			expectedStatus: http.StatusUnprocessableEntity,
 		},
 		{
 			name: "WithAlertButMissingMinFields",
@@ -215,18 +218,15 @@ func TestUpdateJob(t *testing.T) {
 				AlertEndpoint:   "https://something.com",
 			},
 			expectedError:  true,
-			expectedStatus: http.StatusBadRequest,
+			expectedStatus: http.StatusUnprocessableEntity,
 		},
 	}
 
-	cfg := config.FromEnvs()
-	defer storage.Drop()
-	storage.Drop()
-	s, close := storage.NewStorage(&cfg)
+	s, close := storage.NewMemoryStorage()
 	defer close()
-	router := CreateRouter(s)
+	router := openRouter(s)
 
-	err := s.CreateJob(storage.CreateJobInput{
+	_, err := s.CreateJob(storage.CreateJobInput{
 		CronExpString:   "*/1 * * * *",
 		MaxRetries:      3,
 		Endpoint:        "http://example.com",
@@ -234,7 +234,7 @@ func TestUpdateJob(t *testing.T) {
 		SuccessStatuses: []int{200},
 	})
 	assert.NoError(t, err)
-	j := s.GetAvailableJobs(100)
+	j := s.GetAvailableJobs(100, nil)
 	assert.Len(t, j, 1)
 	jobId := j[0].Id
 
@@ -255,3 +255,64 @@ func TestUpdateJob(t *testing.T) {
 		})
 	}
 }
+
+func TestPatchJob(t *testing.T) {
+	s, close := storage.NewMemoryStorage()
+	defer close()
+	router := openRouter(s)
+
+	_, err := s.CreateJob(storage.CreateJobInput{
+		Name:            "Job 1",
+		CronExpString:   "*/1 * * * *",
+		MaxRetries:      3,
+		Endpoint:        "http://example.com",
+		HttpMethod:      "GET",
+		SuccessStatuses: []int{200},
+		Labels:          map[string]string{"env": "prod", "team": "payments"},
+	})
+	assert.NoError(t, err)
+	jobId := s.GetAvailableJobs(100, nil)[0].Id
+	path := fmt.Sprintf("/v1/jobs/%s", jobId)
+
+	tests := []struct {
+		name           string
+		path           string
+		body           string
+		expectedStatus int
+	}{
+		{"OnlyName", path, `{"name": "Job 2"}`, http.StatusAccepted},
+		{"ReplacesLabels", path, `{"labels": {"env": "dev"}}`, http.StatusAccepted},
+		{"StillValidated", path, `{"cronexp": "invalid-expression"}`, http.StatusUnprocessableEntity},
+		{"NotAnObject", path, `["name"]`, http.StatusBadRequest},
+		{"BadId", "/v1/jobs/nope", `{"name": "Job 3"}`, http.StatusBadRequest},
+		{"MissingJob", "/v1/jobs/" + uuid.Must(uuid.NewV7()).String(), `{"name": "Job 3"}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("PATCH", tt.path, strings.NewReader(tt.body))
+			assert.NoError(t, err)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Result().StatusCode, w.Body.String())
+			if w.Code != http.StatusAccepted {
+				return
+			}
+
+			res := v1.SaveJobResponse{}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+			assert.Equal(t, "job updated", res.Message)
+			if assert.NotNil(t, res.Job, "the updated job is returned") {
+				assert.Equal(t, jobId, res.Job.Id)
+			}
+		})
+	}
+
+	j, err := s.GetJob(jobId)
+	assert.NoError(t, err)
+	assert.Equal(t, "Job 2", j.Name)
+	assert.Equal(t, "*/1 * * * *", j.CronExpString, "fields missing in the body keep their values")
+	assert.Equal(t, 3, j.MaxRetries)
+	assert.Equal(t, map[string]string{"env": "dev"}, j.Labels)
+}
@@ -0,0 +1,109 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/back-end-labs/ruok/pkg/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+)
+
+var granularityLabel string = "granularity"
+var fromLabel string = "from"
+var toLabel string = "to"
+
+// Range used when "from" is not provided
+var defaultAggregatesRange = 30 * 24 * time.Hour
+
+// Longest range served with hourly buckets when no granularity is provided
+var maxAutoHourlyRange = 7 * 24 * time.Hour
+
+// Upper bound for the amount of buckets a single request can ask for
+var maxAggregateBuckets = 2000
+
+// Serves hourly or daily execution stats of a job, for any range including compacted results.
+// "from" and "to" are RFC3339 times.
+func ListJobResultAggregates(s storage.APIStorage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := uuid.FromString(c.Param("id"))
+
+		if err != nil {
+			respondError(c, http.StatusBadRequest, fmt.Sprintf("bad id provided: %s", c.Param("id")))
+			return
+		}
+
+		to := time.Now().UTC()
+		if toQ := c.Query(toLabel); toQ != "" {
+			to, err = time.Parse(time.RFC3339, toQ)
+			if err != nil {
+				respondError(c, http.StatusBadRequest, fmt.Sprintf("%q must be an RFC3339 time, instead got %q", toLabel, toQ))
+				return
+			}
+		}
+
+		from := to.Add(-defaultAggregatesRange)
+		if fromQ := c.Query(fromLabel); fromQ != "" {
+			from, err = time.Parse(time.RFC3339, fromQ)
+			if err != nil {
+				respondError(c, http.StatusBadRequest, fmt.Sprintf("%q must be an RFC3339 time, instead got %q", fromLabel, fromQ))
+				return
+			}
+		}
+
+		if !from.Before(to) {
+			respondError(c, http.StatusBadRequest, fmt.Sprintf("%q must be before %q", fromLabel, toLabel))
+			return
+		}
+
+		granularity := c.Query(granularityLabel)
+		if granularity == "" {
+			granularity = storage.GranularityDay
+			if to.Sub(from) <= maxAutoHourlyRange {
+				granularity = storage.GranularityHour
+			}
+		}
+
+		if !storage.IsValidGranularity(granularity) {
+			respondError(c, http.StatusBadRequest, fmt.Sprintf("%q must be %q or %q", granularityLabel, storage.GranularityHour, storage.GranularityDay))
+			return
+		}
+
+		bucket := time.Hour
+		if granularity == storage.GranularityDay {
+			bucket = 24 * time.Hour
+		}
+		if int(to.Sub(from)/bucket) > maxAggregateBuckets {
+			respondError(c, http.StatusBadRequest, fmt.Sprintf("range is too long for %s buckets, at most %d are allowed", granularity, maxAggregateBuckets))
+			return
+		}
+
+		_, err = s.GetJob(id)
+
+		if errors.Is(err, storage.ErrNotFound) {
+			respondError(c, http.StatusNotFound, fmt.Sprintf("could not find a job with id %v", id))
+			return
+		}
+
+		if err != nil {
+			respondStorageError(c, err, "get the job")
+			return
+		}
+
+		aggregates := s.GetResultAggregates(id, granularity, from, to)
+		if aggregates == nil {
+			respondError(c, http.StatusInternalServerError, "an internal error happened while trying to get the job stats")
+			return
+		}
+
+		c.JSON(http.StatusOK, AggregatesResponse{
+			JobId:       id,
+			Granularity: granularity,
+			From:        from,
+			To:          to,
+			Aggregates:  aggregates,
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/back-end-labs/ruok/pkg/auth"
+	"github.com/back-end-labs/ruok/pkg/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+)
+
+// Lists every key of the tenant, revoked ones included. Keys themselves are never shown again after being created
+func ListAPIKeys(s storage.APIStorage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		keys := s.ListAPIKeys()
+		if keys == nil {
+			respondError(c, http.StatusInternalServerError, "an internal error happened while trying to list api keys")
+			return
+		}
+
+		c.JSON(http.StatusOK, ListAPIKeysResponse{APIKeys: keys})
+	}
+}
+
+// Creates a key and responds with it, this is the only time it can be read
+func CreateAPIKey(s storage.APIStorage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var in CreateAPIKeyRequest
+		if err := c.ShouldBindJSON(&in); err != nil {
+			respondError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		errors := []FieldError{}
+		if !auth.IsValidKeyName(in.Name) {
+			errors = append(errors, FieldError{"name", FieldInvalid, "name must have up to 63 letters, numbers, '.', '-' or '_'"})
+		}
+		scope, err := auth.ParseScope(in.Scope)
+		if err != nil {
+			errors = append(errors, FieldError{"scope", FieldInvalid, err.Error()})
+		}
+		if in.Tenant != "" && !auth.IsValidTenant(in.Tenant) {
+			errors = append(errors, FieldError{"tenant", FieldInvalid, "tenant must have up to 63 letters, numbers, '.', '-' or '_'"})
+		}
+		if len(errors) > 0 {
+			respondInvalid(c, errors)
+			return
+		}
+
+		tenant := Caller(c).Tenant
+		if in.Tenant != "" && tenant != "" && in.Tenant != tenant {
+			respondError(c, http.StatusForbidden, "keys can only be created for your own tenant")
+			return
+		}
+		if in.Tenant != "" {
+			tenant = in.Tenant
+		}
+
+		key, created, err := NewAPIKey(s, in.Name, scope, tenant)
+
+		if err != nil {
+			respondStorageError(c, err, "create a new api key")
+			return
+		}
+
+		c.JSON(http.StatusCreated, CreateAPIKeyResponse{
+			Message: "api key created, keep it safe as it won't be shown again",
+			Key:     key,
+			APIKey:  created,
+		})
+	}
+}
+
+// Stops accepting a key right away
+func RevokeAPIKey(s storage.APIStorage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := uuid.FromString(c.Param("id"))
+
+		if err != nil {
+			respondError(c, http.StatusBadRequest, fmt.Sprintf("bad id provided: %s", c.Param("id")))
+			return
+		}
+
+		err = s.RevokeAPIKey(id)
+
+		if errors.Is(err, storage.ErrNotFound) {
+			respondError(c, http.StatusNotFound, fmt.Sprintf("could not find an api key to revoke with id %v", id))
+			return
+		}
+
+		if err != nil {
+			respondStorageError(c, err, "revoke the api key")
+			return
+		}
+
+		c.JSON(http.StatusAccepted, MessageResponse{Message: "api key revoked"})
+	}
+}
+
+type apiKeyCreator interface {
+	CreateAPIKey(k storage.CreateAPIKeyInput) (*auth.APIKey, error)
+}
+
+// Generates a key and stores its hash, returns the key along with what was stored.
+// Name, scope and tenant must be valid already, an empty tenant is auth.DefaultTenant.
+func NewAPIKey(s apiKeyCreator, name string, scope auth.Scope, tenant string) (string, *auth.APIKey, error) {
+	key, prefix, hash, err := auth.GenerateKey()
+	if err != nil {
+		return "", nil, err
+	}
+	created, err := s.CreateAPIKey(storage.CreateAPIKeyInput{
+		Name:   name,
+		Scope:  scope,
+		Prefix: prefix,
+		Hash:   hash,
+		Tenant: tenant,
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return key, created, nil
+}
@@ -0,0 +1,155 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/back-end-labs/ruok/pkg/auth"
+	"github.com/back-end-labs/ruok/pkg/config"
+	"github.com/back-end-labs/ruok/pkg/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// Key of the gin context where the caller is kept
+var identityKey = "identity"
+
+var bearerPrefix = "Bearer "
+
+type keysStorage interface {
+	GetAPIKey(hash string) (*auth.APIKey, error)
+}
+
+// Checks bearer tokens that aren't api keys, like the ones of an OIDC identity provider
+type TokenVerifier interface {
+	Verify(token string) (auth.Identity, error)
+}
+
+// Finds out who is calling and keeps it for the next handlers, see Caller.
+// Without authentication every caller is auth.Anonymous.
+// With keys, requests must send "Authorization: Bearer <key>" and unknown or revoked keys get a 401.
+// With oidc, the bearer can be an api key or a token checked by "tokens".
+func Authenticate(s keysStorage, mode string, tokens TokenVerifier) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if mode == config.NO_AUTH {
+			c.Set(identityKey, auth.Anonymous)
+			c.Next()
+			return
+		}
+
+		header := c.GetHeader("Authorization")
+		token := strings.TrimSpace(strings.TrimPrefix(header, bearerPrefix))
+		if strings.HasPrefix(header, bearerPrefix) && mode == config.OIDC_AUTH && tokens != nil && auth.IsJWT(token) {
+			authenticateToken(c, tokens, token)
+			return
+		}
+		if !strings.HasPrefix(header, bearerPrefix) || !auth.IsKey(token) {
+			unauthorized(c, "an api key or token must be sent as \"Authorization: Bearer <key>\"")
+			return
+		}
+
+		key, err := s.GetAPIKey(auth.HashKey(token))
+
+		if errors.Is(err, storage.ErrNotFound) {
+			unauthorized(c, "unknown or revoked api key")
+			return
+		}
+
+		if err != nil {
+			respondStorageError(c, err, "check the api key")
+			c.Abort()
+			return
+		}
+
+		c.Set(identityKey, auth.KeyIdentity(key))
+		c.Next()
+	}
+}
+
+func authenticateToken(c *gin.Context, tokens TokenVerifier, token string) {
+	identity, err := tokens.Verify(token)
+
+	if errors.Is(err, auth.ErrInvalidToken) {
+		unauthorized(c, err.Error())
+		return
+	}
+
+	if err != nil {
+		log.Error().Err(err).Msg("could not verify token")
+		respondError(c, http.StatusInternalServerError, "an internal error happened while trying to check the token")
+		c.Abort()
+		return
+	}
+
+	c.Set(identityKey, identity)
+	c.Next()
+}
+
+func unauthorized(c *gin.Context, message string) {
+	c.Header("WWW-Authenticate", `Bearer realm="ruok"`)
+	respondError(c, http.StatusUnauthorized, message)
+	c.Abort()
+}
+
+// Only lets through callers whose scope allows "scope", the rest get a 403.
+// It must run after Authenticate.
+func RequireScope(scope auth.Scope) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		caller := Caller(c)
+		if !caller.Scope.Allows(scope) {
+			respondError(c, http.StatusForbidden, "the "+string(scope)+" scope is needed, "+caller.Name+" has "+string(caller.Scope))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// Logs who made every request that changes something, after it is handled
+func LogMutations() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			return
+		}
+		log.Info().
+			Str("caller", Caller(c).Name).
+			Str("method", c.Request.Method).
+			Str("path", c.Request.URL.Path).
+			Int("status", c.Writer.Status()).
+			Msg("api mutation")
+	}
+}
+
+// Who is calling, nobody (an empty identity without scope) if Authenticate didn't run
+func Caller(c *gin.Context) auth.Identity {
+	if identity, ok := c.Get(identityKey); ok {
+		return identity.(auth.Identity)
+	}
+	return auth.Identity{}
+}
+
+// What the UI needs to log in
+type AuthSettings struct {
+	// none, keys or oidc
+	Mode string `json:"mode"`
+	// Identity provider and client the UI logs in with, only with oidc
+	Issuer   string `json:"issuer,omitempty"`
+	ClientId string `json:"clientId,omitempty"`
+	Audience string `json:"audience,omitempty"`
+}
+
+// Tells the UI how to log in, it is public so the UI can ask before having a token
+func AuthConfig(mode string, oidc config.OIDCConfig) gin.HandlerFunc {
+	settings := AuthSettings{Mode: mode}
+	if mode == config.OIDC_AUTH {
+		settings.Issuer = oidc.Issuer
+		settings.ClientId = oidc.ClientId
+		settings.Audience = oidc.Audience
+	}
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, settings)
+	}
+}
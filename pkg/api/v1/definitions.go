@@ -0,0 +1,586 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+	"gopkg.in/yaml.v3"
+
+	"github.com/back-end-labs/ruok/pkg/job"
+	"github.com/back-end-labs/ruok/pkg/labels"
+	"github.com/back-end-labs/ruok/pkg/storage"
+)
+
+// Label keeping the key of a job in its definitions, so imports find the job again
+const KeyLabel = "ruok/key"
+
+// Label of the jobs owned by a sync, set to ManagedBySync. Only these jobs are changed or deleted by PlanSync
+const ManagedLabel = "ruok/managed-by"
+
+const ManagedBySync = "sync"
+
+// Version of the definitions we write
+const DefinitionsVersion = 1
+
+// Formats definitions are written in
+const (
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+)
+
+// What an import does to a job
+const (
+	ChangeCreate    = "create"
+	ChangeUpdate    = "update"
+	ChangeUnchanged = "unchanged"
+	ChangeDelete    = "delete"
+)
+
+var formatLabel string = "format"
+var dryRunLabel string = "dryRun"
+
+// Reads definitions written in json or yaml. Fields it doesn't know are an error, so typos are not ignored
+func DecodeDefinitions(data []byte, format string) (*Definitions, error) {
+	d := &Definitions{}
+	var err error
+	if format == FormatYAML {
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err = decoder.Decode(d)
+	} else {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(d)
+	}
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("there are no definitions")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Writes definitions with their keys sorted, so exporting the same jobs gives the same document
+func EncodeDefinitions(d *Definitions, format string) ([]byte, error) {
+	if format == FormatYAML {
+		out := &bytes.Buffer{}
+		encoder := yaml.NewEncoder(out)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(d); err != nil {
+			return nil, err
+		}
+		return out.Bytes(), encoder.Close()
+	}
+	return json.MarshalIndent(d, "", "  ")
+}
+
+// Every job the storage lets us see, walking every page
+func allJobs(s storage.APIStorage) ([]*job.Job, error) {
+	jobs := []*job.Job{}
+	in := storage.ListJobsInput{Limit: maxPageSize}
+	for {
+		page, err := s.ListJobs(in)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, page.Jobs...)
+		if page.NextCursor == "" {
+			return jobs, nil
+		}
+		in.Cursor = page.NextCursor
+	}
+}
+
+// The key of a job in its definitions, its id when it was never given one
+func keyOf(j *job.Job) string {
+	if key := j.Labels[KeyLabel]; key != "" {
+		return key
+	}
+	return j.Id.String()
+}
+
+// Jobs that can't be told apart by their key
+func errDuplicateKey(key string, a uuid.UUID, b uuid.UUID) error {
+	return &storage.KindError{
+		Kind: storage.ErrConflict,
+		Code: "duplicate_key",
+		Msg:  fmt.Sprintf("jobs %v and %v have the same key %q, change the %s label of one of them", a, b, key, KeyLabel),
+	}
+}
+
+// Every job by its key. Storages that can't see the jobs claimed by other schedulers return storage.ErrHiddenJobs,
+// otherwise plans would create those jobs again and exports would leave them out.
+func jobsByKey(s storage.APIStorage) (map[string]*job.Job, error) {
+	sees, err := s.SeesEveryJob()
+	if err != nil {
+		return nil, err
+	}
+	if !sees {
+		return nil, storage.ErrHiddenJobs
+	}
+	jobs, err := allJobs(s)
+	if err != nil {
+		return nil, err
+	}
+	byKey := map[string]*job.Job{}
+	for _, j := range jobs {
+		key := keyOf(j)
+		if other, ok := byKey[key]; ok {
+			return nil, errDuplicateKey(key, other.Id, j.Id)
+		}
+		byKey[key] = j
+	}
+	return byKey, nil
+}
+
+// Writes every job we can see as a definition
+func ExportDefinitions(s storage.APIStorage) (*Definitions, error) {
+	byKey, err := jobsByKey(s)
+	if err != nil {
+		return nil, err
+	}
+	d := &Definitions{Version: DefinitionsVersion, Jobs: map[string]JobDefinition{}}
+	for key, j := range byKey {
+		d.Jobs[key] = definitionOf(j)
+	}
+	return d, nil
+}
+
+func definitionOf(j *job.Job) JobDefinition {
+	var jobLabels map[string]string
+	for k, v := range j.Labels {
+		if k == KeyLabel || k == ManagedLabel {
+			continue
+		}
+		if jobLabels == nil {
+			jobLabels = map[string]string{}
+		}
+		jobLabels[k] = v
+	}
+	var alertHeaders map[string]string
+	if len(j.AlertHeaders) > 0 {
+		alertHeaders = j.AlertHeaders
+	}
+	var locations []string
+	if len(j.Locations) > 0 {
+		locations = j.Locations
+	}
+	return JobDefinition{
+		Name:                 j.Name,
+		CronExpString:        j.CronExpString,
+		Timezone:             j.Timezone,
+		MaxRetries:           j.MaxRetries,
+		Endpoint:             j.Endpoint,
+		HttpMethod:           j.HttpMethod,
+		SuccessStatuses:      j.SuccessStatuses,
+		AlertStrategy:        j.AlertStrategy,
+		AlertMethod:          j.AlertMethod,
+		AlertEndpoint:        j.AlertEndpoint,
+		AlertPayload:         j.AlertPayload,
+		AlertHeaders:         alertHeaders,
+		Labels:               jobLabels,
+		Locations:            locations,
+		AlertQuorum:          j.AlertQuorum,
+		ResultsRetentionDays: j.ResultsRetentionDays,
+	}
+}
+
+// The job a definition describes, as ruok stores it
+func (d JobDefinition) updateInput(key string) storage.UpdateJobInput {
+	jobLabels := map[string]string{KeyLabel: key}
+	for k, v := range d.Labels {
+		jobLabels[k] = v
+	}
+	u := storage.UpdateJobInput{
+		Name:                 d.Name,
+		CronExpString:        d.CronExpString,
+		Timezone:             storage.TimezoneOrDefault(d.Timezone),
+		MaxRetries:           d.MaxRetries,
+		Endpoint:             d.Endpoint,
+		HttpMethod:           strings.ToUpper(d.HttpMethod),
+		SuccessStatuses:      d.SuccessStatuses,
+		AlertStrategy:        d.AlertStrategy,
+		AlertMethod:          strings.ToUpper(d.AlertMethod),
+		AlertEndpoint:        d.AlertEndpoint,
+		AlertPayload:         d.AlertPayload,
+		AlertHeaders:         d.AlertHeaders,
+		Labels:               jobLabels,
+		Locations:            d.Locations,
+		AlertQuorum:          d.AlertQuorum,
+		ResultsRetentionDays: d.ResultsRetentionDays,
+	}
+	// alerts are only kept when they have the minimum fields
+	if !storage.HasMinAlertFields(u.AlertStrategy, u.AlertEndpoint, u.AlertMethod) {
+		u.AlertStrategy, u.AlertMethod, u.AlertEndpoint, u.AlertPayload, u.AlertHeaders = "", "", "", "", nil
+	}
+	return u
+}
+
+func createInputOf(u storage.UpdateJobInput) storage.CreateJobInput {
+	return storage.CreateJobInput{
+		Name:                 u.Name,
+		CronExpString:        u.CronExpString,
+		Timezone:             u.Timezone,
+		MaxRetries:           u.MaxRetries,
+		Endpoint:             u.Endpoint,
+		HttpMethod:           u.HttpMethod,
+		SuccessStatuses:      u.SuccessStatuses,
+		AlertStrategy:        u.AlertStrategy,
+		AlertMethod:          u.AlertMethod,
+		AlertEndpoint:        u.AlertEndpoint,
+		AlertPayload:         u.AlertPayload,
+		AlertHeaders:         u.AlertHeaders,
+		Labels:               u.Labels,
+		Locations:            u.Locations,
+		AlertQuorum:          u.AlertQuorum,
+		ResultsRetentionDays: u.ResultsRetentionDays,
+	}
+}
+
+// What the definition of a key has wrong, with the fields named after the key like "jobs.checkout.cronexp"
+func validateDefinition(key string, d JobDefinition) []FieldError {
+	prefix := "jobs." + key + "."
+	if err := labels.Validate(map[string]string{KeyLabel: key}); key == "" || err != nil {
+		return []FieldError{{"jobs." + key, FieldInvalid, "keys must start and end with a letter or a number and only have letters, numbers, '.', '_' or '-', up to 63 characters"}}
+	}
+
+	fieldErrors := []FieldError{}
+	if _, ok := d.Labels[KeyLabel]; ok {
+		fieldErrors = append(fieldErrors, FieldError{prefix + "labels", FieldConflicting, fmt.Sprintf("the %s label is the key of the job, it can't be set", KeyLabel)})
+	}
+	if _, ok := d.Labels[ManagedLabel]; ok {
+		fieldErrors = append(fieldErrors, FieldError{prefix + "labels", FieldConflicting, fmt.Sprintf("the %s label is set by sync, it can't be set", ManagedLabel)})
+	}
+	if d.AlertMethod != "" || d.AlertStrategy != "" || d.AlertEndpoint != "" {
+		fieldErrors = append(fieldErrors, missingAlertFields(d.AlertStrategy, d.AlertEndpoint, d.AlertMethod, "if alerting is set, must provide strategy, endpoint and method")...)
+	}
+	createErrors, _ := validateCreateFields(createInputOf(d.updateInput(key)))
+	fieldErrors = append(fieldErrors, createErrors...)
+
+	for i := range fieldErrors {
+		if !strings.HasPrefix(fieldErrors[i].Field, prefix) {
+			fieldErrors[i].Field = prefix + fieldErrors[i].Field
+		}
+	}
+	return fieldErrors
+}
+
+// What importing definitions does, made by PlanImport and applied by Apply
+type ImportPlan struct {
+	// Sorted by key
+	Changes []JobChange
+	// What the jobs that are created or updated will look like, by key
+	jobs map[string]storage.UpdateJobInput
+}
+
+// How many changes of the plan do the action
+func (p *ImportPlan) Count(action string) int {
+	n := 0
+	for _, c := range p.Changes {
+		if c.Action == action {
+			n++
+		}
+	}
+	return n
+}
+
+// Compares the definitions with the jobs we can see. Jobs are found by their key, so the ones missing are created
+// and the rest updated when they differ. Jobs without a definition are left as they are.
+// Nothing is planned when a definition is not valid, the field errors tell why.
+func PlanImport(s storage.APIStorage, d *Definitions) (*ImportPlan, []FieldError, error) {
+	return plan(s, d, false)
+}
+
+// Like PlanImport, but the definitions are every job sync owns: the jobs it creates are tagged with ManagedLabel
+// and the tagged jobs without a definition are deleted. Jobs that are not tagged are never changed,
+// so definitions with the key of one of them are not valid.
+func PlanSync(s storage.APIStorage, d *Definitions) (*ImportPlan, []FieldError, error) {
+	return plan(s, d, true)
+}
+
+func plan(s storage.APIStorage, d *Definitions, sync bool) (*ImportPlan, []FieldError, error) {
+	fieldErrors := []FieldError{}
+	if d.Version != DefinitionsVersion {
+		fieldErrors = append(fieldErrors, FieldError{"version", FieldInvalid, fmt.Sprintf("only version %d of the definitions can be imported", DefinitionsVersion)})
+	}
+	keys := make([]string, 0, len(d.Jobs))
+	for key, definition := range d.Jobs {
+		keys = append(keys, key)
+		fieldErrors = append(fieldErrors, validateDefinition(key, definition)...)
+	}
+	sort.Strings(keys)
+	if len(fieldErrors) > 0 {
+		sort.SliceStable(fieldErrors, func(i, j int) bool { return fieldErrors[i].Field < fieldErrors[j].Field })
+		return nil, fieldErrors, nil
+	}
+
+	existing, err := jobsByKey(s)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	p := &ImportPlan{Changes: []JobChange{}, jobs: map[string]storage.UpdateJobInput{}}
+	for _, key := range keys {
+		want := d.Jobs[key].updateInput(key)
+		current, ok := existing[key]
+		managed := ok && current.Labels[ManagedLabel] == ManagedBySync
+		if sync || managed {
+			want.Labels[ManagedLabel] = ManagedBySync
+		}
+		if sync && ok && !managed {
+			fieldErrors = append(fieldErrors, FieldError{"jobs." + key, FieldConflicting, fmt.Sprintf("job %v has the key and is not managed by sync, delete it or add the %s=%s label to hand it over", current.Id, ManagedLabel, ManagedBySync)})
+			continue
+		}
+		if !ok {
+			p.Changes = append(p.Changes, JobChange{Key: key, Action: ChangeCreate})
+			p.jobs[key] = want
+			continue
+		}
+
+		id := current.Id
+		want.Id = id
+		// jobs keyed by their id keep going without the label, it would only repeat the id
+		if _, ok := current.Labels[KeyLabel]; !ok && key == id.String() {
+			delete(want.Labels, KeyLabel)
+		}
+		diff := diffJobs(updateInputOf(current), want)
+		if len(diff) == 0 {
+			p.Changes = append(p.Changes, JobChange{Key: key, Action: ChangeUnchanged, Id: &id})
+			continue
+		}
+		p.Changes = append(p.Changes, JobChange{Key: key, Action: ChangeUpdate, Id: &id, Diff: diff})
+		p.jobs[key] = want
+	}
+	if len(fieldErrors) > 0 {
+		return nil, fieldErrors, nil
+	}
+
+	if sync {
+		for key, current := range existing {
+			if _, ok := d.Jobs[key]; !ok && current.Labels[ManagedLabel] == ManagedBySync {
+				id := current.Id
+				p.Changes = append(p.Changes, JobChange{Key: key, Action: ChangeDelete, Id: &id})
+			}
+		}
+		sort.SliceStable(p.Changes, func(i, j int) bool { return p.Changes[i].Key < p.Changes[j].Key })
+	}
+	return p, nil, nil
+}
+
+// Creates, updates and deletes the jobs of the plan on behalf of "by". Changes are applied one at a time
+// and a failed one doesn't stop the rest: its Error tells why and the returned error joins every failure.
+// Created jobs get their Id.
+func (p *ImportPlan) Apply(s storage.APIStorage, by string) error {
+	errs := []error{}
+	for i := range p.Changes {
+		change := &p.Changes[i]
+		var err error
+		switch change.Action {
+		case ChangeCreate:
+			in := createInputOf(p.jobs[change.Key])
+			in.UpdatedBy = by
+			id, createErr := s.CreateJob(in)
+			if createErr != nil {
+				err = fmt.Errorf("could not create the job %q: %w", change.Key, createErr)
+			} else {
+				change.Id = &id
+			}
+		case ChangeUpdate:
+			in := p.jobs[change.Key]
+			in.UpdatedBy = by
+			if updateErr := s.UpdateJob(in); updateErr != nil {
+				err = fmt.Errorf("could not update the job %q: %w", change.Key, updateErr)
+			}
+		case ChangeDelete:
+			if deleteErr := s.DeleteJob(*change.Id, by); deleteErr != nil {
+				err = fmt.Errorf("could not delete the job %q: %w", change.Key, deleteErr)
+			}
+		}
+		if err != nil {
+			change.Error = err.Error()
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// How many changes of the plan could not be applied
+func (p *ImportPlan) Failed() int {
+	n := 0
+	for _, c := range p.Changes {
+		if c.Error != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// Writes what the plan changes, one job a line followed by the fields that change
+func (p *ImportPlan) Print(w io.Writer) {
+	symbols := map[string]string{ChangeCreate: "+", ChangeUpdate: "~", ChangeDelete: "-"}
+	for _, change := range p.Changes {
+		symbol, ok := symbols[change.Action]
+		if !ok {
+			continue
+		}
+		if change.Id != nil {
+			fmt.Fprintf(w, "%s %s %s (%v)\n", symbol, change.Action, change.Key, *change.Id)
+		} else {
+			fmt.Fprintf(w, "%s %s %s\n", symbol, change.Action, change.Key)
+		}
+		for _, diff := range change.Diff {
+			from, _ := json.Marshal(diff.From)
+			to, _ := json.Marshal(diff.To)
+			fmt.Fprintf(w, "    %s: %s -> %s\n", diff.Field, from, to)
+		}
+	}
+	fmt.Fprintf(w, "%d to create, %d to update, %d to delete, %d unchanged\n", p.Count(ChangeCreate), p.Count(ChangeUpdate), p.Count(ChangeDelete), p.Count(ChangeUnchanged))
+}
+
+// Fields that differ between two versions of a job, named like in the API and sorted.
+// Request headers are not stored, so they are left out.
+func diffJobs(from storage.UpdateJobInput, to storage.UpdateJobInput) []FieldDiff {
+	before, after := comparableFields(from), comparableFields(to)
+	fields := []string{}
+	for field := range after {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	diff := []FieldDiff{}
+	for _, field := range fields {
+		if !reflect.DeepEqual(before[field], after[field]) {
+			diff = append(diff, FieldDiff{Field: field, From: before[field], To: after[field]})
+		}
+	}
+	return diff
+}
+
+// The fields of a job in json, with empty maps and lists as null and locations sorted
+// since they are the same job either way
+func comparableFields(u storage.UpdateJobInput) map[string]any {
+	u.Id = uuid.Nil
+	u.Headers = nil
+	u.Locations = append([]string{}, u.Locations...)
+	sort.Strings(u.Locations)
+
+	b, _ := json.Marshal(u)
+	fields := map[string]any{}
+	json.Unmarshal(b, &fields)
+	delete(fields, "id")
+	delete(fields, "headers")
+	for field, value := range fields {
+		switch v := value.(type) {
+		case map[string]any:
+			if len(v) == 0 {
+				fields[field] = nil
+			}
+		case []any:
+			if len(v) == 0 {
+				fields[field] = nil
+			}
+		}
+	}
+	return fields
+}
+
+// Answers with every job the caller can see as definitions, in json unless "format" is "yaml"
+func Export(s storage.APIStorage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		format := c.DefaultQuery(formatLabel, FormatJSON)
+		if format != FormatJSON && format != FormatYAML {
+			respondError(c, http.StatusBadRequest, fmt.Sprintf("%q must be %q or %q", formatLabel, FormatJSON, FormatYAML))
+			return
+		}
+
+		d, err := ExportDefinitions(s)
+		if err != nil {
+			respondStorageError(c, err, "export the jobs")
+			return
+		}
+
+		if format == FormatJSON {
+			c.JSON(http.StatusOK, d)
+			return
+		}
+		out, err := EncodeDefinitions(d, format)
+		if err != nil {
+			respondStorageError(c, err, "export the jobs")
+			return
+		}
+		c.Data(http.StatusOK, "application/yaml", out)
+	}
+}
+
+// Creates the jobs of the definitions in the body that are missing and updates the ones that differ.
+// Bodies sent as yaml are read as yaml, the rest as json. With "dryRun=true" it only answers with the plan.
+func Import(s storage.APIStorage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		dryRun := c.Query(dryRunLabel) == "true"
+
+		format := FormatJSON
+		if strings.Contains(c.ContentType(), "yaml") {
+			format = FormatYAML
+		}
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		d, err := DecodeDefinitions(body, format)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, fmt.Sprintf("the body must be definitions in %s: %v", format, err))
+			return
+		}
+
+		plan, fieldErrors, err := PlanImport(s, d)
+		if err != nil {
+			respondStorageError(c, err, "plan the import")
+			return
+		}
+		if len(fieldErrors) > 0 {
+			respondInvalid(c, fieldErrors)
+			return
+		}
+
+		quota := callerQuota(c)
+		for _, change := range plan.Changes {
+			want, ok := plan.jobs[change.Key]
+			if ok && !checkIntervalQuota(c, quota, want.CronExpString, want.Timezone) {
+				return
+			}
+		}
+		if creates := plan.Count(ChangeCreate); creates > 0 && !checkJobsQuota(c, quota, s, creates) {
+			return
+		}
+
+		if !dryRun {
+			if err := plan.Apply(s, Caller(c).Name); err != nil {
+				// the changes that went through are kept, so the answer tells which ones did
+				p := storageProblem(c, err, "import the jobs")
+				p.Detail = fmt.Sprintf("%d changes could not be applied, the rest were", plan.Failed())
+				p.Changes = plan.Changes
+				respondProblem(c, p)
+				return
+			}
+		}
+
+		c.JSON(http.StatusOK, ImportResponse{
+			DryRun:    dryRun,
+			Changes:   plan.Changes,
+			Created:   plan.Count(ChangeCreate),
+			Updated:   plan.Count(ChangeUpdate),
+			Unchanged: plan.Count(ChangeUnchanged),
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/back-end-labs/ruok/pkg/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+)
+
+// Upper bound for the amount of revisions a single request can ask for
+var maxHistoryLimit = 500
+
+// Lists the changes made to a job, the newest first, with who made them and what changed
+func ListJobHistory(s storage.APIStorage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := uuid.FromString(c.Param("id"))
+
+		if err != nil {
+			respondError(c, http.StatusBadRequest, fmt.Sprintf("bad id provided: %s", c.Param("id")))
+			return
+		}
+
+		limitQ := c.DefaultQuery(limitLabel, "20")
+		limit, err := strconv.Atoi(limitQ)
+		if err != nil || limit < 1 || limit > maxHistoryLimit {
+			respondError(c, http.StatusBadRequest, fmt.Sprintf("%q must be a number between 1 and %d, instead got %q", limitLabel, maxHistoryLimit, limitQ))
+			return
+		}
+
+		offsetQ := c.DefaultQuery(offsetLabel, "0")
+		offset, err := strconv.Atoi(offsetQ)
+		if err != nil || offset < 0 {
+			respondError(c, http.StatusBadRequest, BadQueryError(offsetLabel, offsetQ))
+			return
+		}
+
+		revisions := s.GetJobHistory(id, limit, offset)
+
+		if revisions == nil {
+			respondError(c, http.StatusInternalServerError, "an internal error happened while trying to get the history of the job")
+			return
+		}
+
+		c.JSON(http.StatusOK, HistoryResponse{
+			JobId:     id,
+			Limit:     limit,
+			Offset:    offset,
+			Revisions: revisions,
+		})
+	}
+}
+
+// Makes a job look like it did after one of its revisions. Deleted jobs are brought back too.
+// The schedule of the revision must fit the quota of the tenant, and the storage refuses to bring back
+// deleted jobs when the tenant has as many as its quota allows.
+func RestoreJob(s storage.APIStorage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := uuid.FromString(c.Param("id"))
+
+		if err != nil {
+			respondError(c, http.StatusBadRequest, fmt.Sprintf("bad id provided: %s", c.Param("id")))
+			return
+		}
+
+		revision, err := strconv.Atoi(c.Param("revision"))
+
+		if err != nil || revision < 1 {
+			respondError(c, http.StatusBadRequest, fmt.Sprintf("bad revision provided: %s", c.Param("revision")))
+			return
+		}
+
+		target, err := s.GetJobRevision(id, revision)
+
+		if errors.Is(err, storage.ErrNotFound) {
+			respondError(c, http.StatusNotFound, fmt.Sprintf("could not find revision %d of job %v", revision, id))
+			return
+		}
+
+		if err != nil {
+			respondStorageError(c, err, "restore the job")
+			return
+		}
+
+		if !target.Deleted && !checkIntervalQuota(c, callerQuota(c), target.CronExpString, target.Timezone) {
+			return
+		}
+
+		err = s.RestoreJob(id, revision, Caller(c).Name)
+
+		if errors.Is(err, storage.ErrNotFound) {
+			respondError(c, http.StatusNotFound, fmt.Sprintf("could not find revision %d of job %v", revision, id))
+			return
+		}
+
+		if errors.Is(err, storage.ErrDeletedRevision) {
+			respondProblem(c, newProblem(c, http.StatusConflict, storage.ErrDeletedRevision.Code, fmt.Sprintf("revision %d deleted the job, restore an earlier one", revision)))
+			return
+		}
+
+		if err != nil {
+			respondStorageError(c, err, "restore the job")
+			return
+		}
+
+		c.JSON(http.StatusAccepted, MessageResponse{Message: "job restored"})
+	}
+}
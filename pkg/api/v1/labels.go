@@ -0,0 +1,106 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/back-end-labs/ruok/pkg/labels"
+	"github.com/back-end-labs/ruok/pkg/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+)
+
+var selectorLabel string = "selector"
+
+// Reads the "selector" query param. Writes a 400 and returns FALSE if it's invalid.
+func parseSelector(c *gin.Context) (labels.Selector, bool) {
+	selector, err := labels.Parse(c.Query(selectorLabel))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, fmt.Sprintf("invalid selector: %s", err.Error()))
+		return nil, false
+	}
+	return selector, true
+}
+
+// Pauses every job matching the selector
+func PauseJobs(s storage.APIStorage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		changeSelectedJobs(c, s, "pause", s.PauseJob)
+	}
+}
+
+// Resumes every paused job matching the selector
+func ResumeJobs(s storage.APIStorage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		changeSelectedJobs(c, s, "resume", s.ResumeJob)
+	}
+}
+
+// Sends the alerts of every job matching the selector to the same channel
+func UpdateJobsAlerts(s storage.APIStorage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var a storage.JobAlertsInput
+		if err := c.ShouldBindJSON(&a); err != nil {
+			respondError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		errors, hasErrors := validateAlertFields(a)
+
+		if hasErrors {
+			respondInvalid(c, errors)
+			return
+		}
+
+		changeSelectedJobs(c, s, "update alerts of", func(jobId uuid.UUID, by string) error {
+			a.UpdatedBy = by
+			return s.UpdateJobAlerts(jobId, a)
+		})
+	}
+}
+
+// Applies a change to every job matching the "selector" query param, one at a time.
+// An empty selector would match every job, so it is rejected.
+// Jobs that can't take the change (like resuming a job that is not paused) are skipped.
+func changeSelectedJobs(c *gin.Context, s storage.APIStorage, action string, change func(jobId uuid.UUID, by string) error) {
+	selector, ok := parseSelector(c)
+	if !ok {
+		return
+	}
+
+	if selector.Empty() {
+		respondError(c, http.StatusBadRequest, fmt.Sprintf("must provide a selector to %s jobs in bulk", action))
+		return
+	}
+
+	ids := s.GetJobIds(selector)
+
+	if ids == nil {
+		respondError(c, http.StatusInternalServerError, "an internal error happened while trying to find the selected jobs")
+		return
+	}
+
+	changed := []uuid.UUID{}
+	skipped := []uuid.UUID{}
+	failed := []BulkFailure{}
+	for _, id := range ids {
+		err := change(id, Caller(c).Name)
+		switch {
+		case errors.Is(err, storage.ErrNotFound), errors.Is(err, storage.ErrConflict):
+			skipped = append(skipped, id)
+		case err != nil:
+			failed = append(failed, BulkFailure{id, fmt.Sprintf("could not %s the job", action)})
+		default:
+			changed = append(changed, id)
+		}
+	}
+
+	c.JSON(http.StatusOK, BulkResponse{
+		Selector: selector.String(),
+		Matched:  len(ids),
+		Changed:  changed,
+		Skipped:  skipped,
+		Failed:   failed,
+	})
+}
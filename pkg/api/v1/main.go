@@ -1,12 +1,18 @@
 package v1
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/back-end-labs/ruok/pkg/config"
+	"github.com/back-end-labs/ruok/pkg/cronParser"
+	"github.com/back-end-labs/ruok/pkg/job"
 	"github.com/back-end-labs/ruok/pkg/storage"
 	"github.com/gin-gonic/gin"
 	"github.com/gofrs/uuid"
@@ -14,105 +20,213 @@ import (
 
 var limitLabel string = "limit"
 var offsetLabel string = "offset"
-var jobsLabel string = "jobs"
-var jobResultsLabel string = "jobResults"
-var jobIdLabel string = "jobId"
-var claimedJobsLabel string = "claimedJobs"
-var errorLabel string = "error"
+var cronexpLabel string = "cronexp"
+var timezoneLabel string = "timezone"
+var countLabel string = "n"
+var waitLabel string = "wait"
+var timeoutLabel string = "timeout"
+var statusLabel string = "status"
+var cursorLabel string = "cursor"
+var nextCursorLabel string = "nextCursor"
+var sortLabel string = "sort"
+var nameLabel string = "name"
+var succeededLabel string = "succeeded"
+
+// Upper bound for the amount of items listed in a single page
+var maxPageSize int = 100
+
+// Fields jobs can be sorted by, prefixed with "-" for descending order
+var jobSortFields = []string{storage.SortById, storage.SortByName, storage.SortByCreatedAt, storage.SortByLastExecution}
+
+// Upper bound for the amount of upcoming executions we compute in a single request
+var maxNextExecutions int = 50
+
+// Upper bound, in seconds, for how long a request can wait for a manual run
+var maxRunWait int = 60
+
+// How often we check if a manual run finished
+var runResultPollInterval = time.Millisecond * 250
 
 func Status(c *gin.Context) {
 	c.String(200, "OK")
 }
 
-func Health(c *gin.Context) {
-	c.String(200, "OK")
+type healthStorage interface {
+	ListenerHealth() storage.ListenerHealth
+}
+
+// Reports "ok" unless we lost the connection used to receive notifications
+func Health(s healthStorage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		listener := storage.ListenerHealth{}
+		if s != nil {
+			listener = s.ListenerHealth()
+		}
+
+		if !listener.Healthy() {
+			c.JSON(http.StatusServiceUnavailable, HealthResponse{Status: "degraded", Listener: listener})
+			return
+		}
+
+		c.JSON(http.StatusOK, HealthResponse{Status: "ok", Listener: listener})
+	}
 }
 
 func BadQueryError(query string, value string) string {
 	return fmt.Sprintf("Bad request. %q needs to be an integer, instead got %q\n", query, value)
 }
 
+// Parses the "limit" query, answering 400 when it is not between 1 and maxPageSize
+func parseLimit(c *gin.Context) (int, bool) {
+	limitQ := c.DefaultQuery(limitLabel, "10")
+	limit, err := strconv.Atoi(limitQ)
+	if err != nil {
+		respondError(c, 400, BadQueryError(limitLabel, limitQ))
+		return 0, false
+	}
+	if limit < 1 || limit > maxPageSize {
+		respondError(c, http.StatusBadRequest, fmt.Sprintf("%q must be between 1 and %d", limitLabel, maxPageSize))
+		return 0, false
+	}
+	return limit, true
+}
+
+// Lists the jobs of every scheduler, filtered by status, result of the last execution, name and labels.
+// "sort" takes a field like "createdAt", or "-createdAt" for descending order.
+// Pages are walked passing the "nextCursor" of a page as the "cursor" of the next one.
 func ListJobs(s storage.APIStorage) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		limitQ := c.DefaultQuery(limitLabel, "10")
-		offsetQ := c.DefaultQuery(offsetLabel, "0")
-		limit, err := strconv.Atoi(limitQ)
+		limit, ok := parseLimit(c)
+		if !ok {
+			return
+		}
+
+		selector, ok := parseSelector(c)
+		if !ok {
+			return
+		}
+
+		in := storage.ListJobsInput{
+			Limit:     limit,
+			Cursor:    c.Query(cursorLabel),
+			Status:    c.Query(statusLabel),
+			Succeeded: c.Query(succeededLabel),
+			Name:      c.Query(nameLabel),
+			Selector:  selector,
+		}
+
+		sort := c.DefaultQuery(sortLabel, storage.SortById)
+		in.SortBy = strings.TrimPrefix(sort, "-")
+		in.Descending = strings.HasPrefix(sort, "-")
+		if !storage.IsJobSortField(in.SortBy) {
+			respondError(c, http.StatusBadRequest, fmt.Sprintf("jobs can't be sorted by %q, use one of %s", in.SortBy, strings.Join(jobSortFields, ", ")))
+			return
+		}
+		if in.Succeeded != "" && in.Succeeded != "ok" && in.Succeeded != "error" {
+			respondError(c, http.StatusBadRequest, fmt.Sprintf("%q must be \"ok\" or \"error\"", succeededLabel))
+			return
+		}
+
+		page, err := s.ListJobs(in)
+
+		if errors.Is(err, storage.ErrInvalidCursor) {
+			respondError(c, http.StatusBadRequest, fmt.Sprintf("invalid %q, it must be the %q of a page listed with the same sort", cursorLabel, nextCursorLabel))
+			return
+		}
+
 		if err != nil {
-			c.JSON(400, gin.H{
-				"message": BadQueryError(limitLabel, limitQ),
-			})
+			respondStorageError(c, err, "list the jobs")
 			return
 		}
 
-		offset, err := strconv.Atoi(offsetQ)
+		c.JSON(200, ListJobsResponse{
+			Jobs:       page.Jobs,
+			NextCursor: page.NextCursor,
+			Limit:      limit,
+			Sort:       sort,
+			Selector:   selector.String(),
+		})
+	}
+}
+
+// Gets the definition of a job
+func GetJob(s storage.APIStorage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := uuid.FromString(c.Param("id"))
+
 		if err != nil {
-			c.JSON(400, gin.H{
-				"message": BadQueryError(offsetLabel, offsetQ),
-			})
+			respondError(c, http.StatusBadRequest, fmt.Sprintf("bad id provided: %s", c.Param("id")))
 			return
 		}
 
-		jobslist := s.GetClaimedJobs(limit, offset)
+		j, err := s.GetJob(id)
 
-		c.JSON(200, gin.H{
-			claimedJobsLabel: config.AppStats.CountClaimedJobs(),
-			offsetLabel:      offset,
-			limitLabel:       limit,
-			jobsLabel:        jobslist,
-		})
+		if errors.Is(err, storage.ErrNotFound) {
+			respondError(c, http.StatusNotFound, fmt.Sprintf("could not find a job with id %v", id))
+			return
+		}
 
+		if err != nil {
+			respondStorageError(c, err, "get the job")
+			return
+		}
+
+		c.JSON(http.StatusOK, GetJobResponse{Job: j})
 	}
 }
 
+// Lists the executions of a job from every scheduler, newest first.
+// Pages are walked passing the "nextCursor" of a page as the "cursor" of the next one.
 func ListJobExecutions(s storage.APIStorage) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		limitQ := c.DefaultQuery(limitLabel, "10")
-		offsetQ := c.DefaultQuery(offsetLabel, "0")
 		jobIdParam := c.Param("id")
 
 		jobId, err := uuid.FromString(jobIdParam)
 		if err != nil {
-			c.JSON(404, gin.H{
-				"message": fmt.Sprintf("Could not found jobs with id %v", jobId),
-			})
+			respondError(c, http.StatusBadRequest, fmt.Sprintf("bad id provided: %s", jobIdParam))
 			return
 		}
 
-		limit, err := strconv.Atoi(limitQ)
-		if err != nil {
-			c.JSON(400, gin.H{
-				"message": BadQueryError(limitLabel, limitQ),
-			})
+		limit, ok := parseLimit(c)
+		if !ok {
 			return
 		}
 
-		offset, err := strconv.Atoi(offsetQ)
-		if err != nil {
-			c.JSON(400, gin.H{
-				"message": BadQueryError(offsetLabel, offsetQ),
-			})
-			return
+		before := uuid.Nil
+		if cursor := c.Query(cursorLabel); cursor != "" {
+			before, err = uuid.FromString(cursor)
+			if err != nil {
+				respondError(c, http.StatusBadRequest, fmt.Sprintf("invalid %q, it must be the %q of the previous page", cursorLabel, nextCursorLabel))
+				return
+			}
 		}
 
-		jobExecutionList := s.GetClaimedJobsExecutions(jobId, limit, offset)
+		_, err = s.GetJob(jobId)
 
-		if len(jobExecutionList) == 0 {
-			c.JSON(404, gin.H{
-				limitLabel:      limit,
-				offsetLabel:     offset,
-				jobIdLabel:      jobIdParam,
-				jobResultsLabel: jobExecutionList,
-			})
+		if errors.Is(err, storage.ErrNotFound) {
+			respondError(c, http.StatusNotFound, fmt.Sprintf("could not find a job with id %v", jobId))
 			return
 		}
 
-		c.JSON(200, gin.H{
-			limitLabel:      limit,
-			offsetLabel:     offset,
-			jobIdParam:      jobIdParam,
-			jobResultsLabel: jobExecutionList,
-		})
+		if err != nil {
+			respondStorageError(c, err, "get the job")
+			return
+		}
+
+		// one more than the limit tells if there is a next page
+		jobExecutionList := s.ListJobExecutions(jobId, limit+1, before)
+		nextCursor := ""
+		if len(jobExecutionList) > limit {
+			jobExecutionList = jobExecutionList[:limit]
+			nextCursor = jobExecutionList[limit-1].Id.String()
+		}
 
+		c.JSON(200, ListJobExecutionsResponse{
+			JobId:      jobId,
+			Limit:      limit,
+			JobResults: jobExecutionList,
+			NextCursor: nextCursor,
+		})
 	}
 }
 
@@ -120,13 +234,13 @@ func CreateJob(s storage.APIStorage) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var j storage.CreateJobInput
 		if err := c.ShouldBindJSON(&j); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			respondError(c, http.StatusBadRequest, err.Error())
 			return
 		}
 
 		if j.AlertMethod != "" || j.AlertStrategy != "" || j.AlertEndpoint != "" {
-			if !storage.HasMinAlertFields(j.AlertStrategy, j.AlertEndpoint, j.AlertMethod) {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "if alerting is set, must provide strategy, endpoint and method"})
+			if missing := missingAlertFields(j.AlertStrategy, j.AlertEndpoint, j.AlertMethod, "if alerting is set, must provide strategy, endpoint and method"); len(missing) > 0 {
+				respondInvalid(c, missing)
 				return
 			}
 		}
@@ -134,32 +248,32 @@ func CreateJob(s storage.APIStorage) gin.HandlerFunc {
 		errors, hasErrors := validateCreateFields(j)
 
 		if hasErrors {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error": errors,
-			})
+			respondInvalid(c, errors)
 			return
 		}
 
 		j.HttpMethod = strings.ToUpper(j.HttpMethod)
+		j.UpdatedBy = Caller(c).Name
 
 		if j.AlertMethod != "" && validHttpMethod(j.AlertMethod) {
 			j.AlertMethod = strings.ToUpper(j.AlertMethod)
 
 		}
 
-		err := s.CreateJob(j)
+		quota := callerQuota(c)
+		if !checkIntervalQuota(c, quota, j.CronExpString, j.Timezone) || !checkJobsQuota(c, quota, s, 1) {
+			return
+		}
+
+		id, err := s.CreateJob(j)
 
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "an internal error happened while trying to create a new job",
-			})
+			respondStorageError(c, err, "create a new job")
 			return
 		}
 
-		c.JSON(http.StatusCreated, gin.H{
-			"message": "job created",
-		})
-
+		c.Header("Location", "/v1/jobs/"+id.String())
+		respondSavedJob(c, s, http.StatusCreated, id, "job created")
 	}
 }
 
@@ -170,49 +284,285 @@ func UpdateJob(s storage.APIStorage) gin.HandlerFunc {
 		id, err := uuid.FromString(c.Param("id"))
 
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{errorLabel: fmt.Sprintf("bad id provided: %s", c.Param("id"))})
+			respondError(c, http.StatusBadRequest, fmt.Sprintf("bad id provided: %s", c.Param("id")))
 			return
 		}
 
 		if err := c.ShouldBindJSON(&j); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{errorLabel: err.Error()})
+			respondError(c, http.StatusBadRequest, err.Error())
 			return
 		}
 
 		j.Id = id
+		saveJob(c, s, j)
+	}
+}
 
-		if j.AlertMethod != "" || j.AlertStrategy != "" || j.AlertEndpoint != "" {
-			if !storage.HasMinAlertFields(j.AlertStrategy, j.AlertEndpoint, j.AlertMethod) {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "if alerting is set, must provide strategy, endpoint and method"})
-				return
+// Changes only the fields of the job present in the body, the rest keep their values.
+// Labels, headers and alert headers present in the body replace the ones of the job.
+func PatchJob(s storage.APIStorage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := uuid.FromString(c.Param("id"))
+
+		if err != nil {
+			respondError(c, http.StatusBadRequest, fmt.Sprintf("bad id provided: %s", c.Param("id")))
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		fields := map[string]json.RawMessage{}
+		if err == nil {
+			err = json.Unmarshal(body, &fields)
+		}
+		if err != nil {
+			respondError(c, http.StatusBadRequest, fmt.Sprintf("the body must be a json object: %v", err))
+			return
+		}
+
+		current, err := s.GetJob(id)
+
+		if errors.Is(err, storage.ErrNotFound) {
+			respondError(c, http.StatusNotFound, fmt.Sprintf("could not find a job to update with id %v", id))
+			return
+		}
+
+		if err != nil {
+			respondStorageError(c, err, "get the job")
+			return
+		}
+
+		j := updateInputOf(current)
+		// decoding into a map merges keys, so maps in the body start empty to replace the ones of the job
+		for field, value := range map[string]*map[string]string{"labels": &j.Labels, "headers": &j.Headers, "alertHeaders": &j.AlertHeaders} {
+			if _, ok := fields[field]; ok {
+				*value = nil
 			}
 		}
+		if err := json.Unmarshal(body, &j); err != nil {
+			respondError(c, http.StatusBadRequest, err.Error())
+			return
+		}
 
-		errors, hasErrors := validateUpdateFields(j)
+		j.Id = id
+		saveJob(c, s, j)
+	}
+}
 
-		if hasErrors {
-			c.JSON(http.StatusBadRequest, gin.H{
-				errorLabel: errors,
-			})
+// The update that leaves the job as it is
+func updateInputOf(j *job.Job) storage.UpdateJobInput {
+	return storage.UpdateJobInput{
+		Id:                   j.Id,
+		Name:                 j.Name,
+		CronExpString:        j.CronExpString,
+		Timezone:             j.Timezone,
+		MaxRetries:           j.MaxRetries,
+		Endpoint:             j.Endpoint,
+		HttpMethod:           j.HttpMethod,
+		Headers:              j.Headers,
+		SuccessStatuses:      j.SuccessStatuses,
+		AlertStrategy:        j.AlertStrategy,
+		AlertMethod:          j.AlertMethod,
+		AlertEndpoint:        j.AlertEndpoint,
+		AlertPayload:         j.AlertPayload,
+		AlertHeaders:         j.AlertHeaders,
+		Labels:               j.Labels,
+		Locations:            j.Locations,
+		AlertQuorum:          j.AlertQuorum,
+		ResultsRetentionDays: j.ResultsRetentionDays,
+	}
+}
+
+// Validates the update and applies it on behalf of the caller, shared by PUT and PATCH
+func saveJob(c *gin.Context, s storage.APIStorage, j storage.UpdateJobInput) {
+	j.UpdatedBy = Caller(c).Name
+
+	if j.AlertMethod != "" || j.AlertStrategy != "" || j.AlertEndpoint != "" {
+		if missing := missingAlertFields(j.AlertStrategy, j.AlertEndpoint, j.AlertMethod, "if alerting is set, must provide strategy, endpoint and method"); len(missing) > 0 {
+			respondInvalid(c, missing)
 			return
 		}
+	}
 
-		err = s.UpdateJob(j)
+	fieldErrors, hasErrors := validateUpdateFields(j)
+
+	if hasErrors {
+		respondInvalid(c, fieldErrors)
+		return
+	}
+
+	if !checkIntervalQuota(c, callerQuota(c), j.CronExpString, j.Timezone) {
+		return
+	}
+
+	err := s.UpdateJob(j)
+
+	if errors.Is(err, storage.ErrNotFound) {
+		respondError(c, http.StatusNotFound, fmt.Sprintf("could not find a job to update with id %v", j.Id))
+		return
+	}
+
+	if err != nil {
+		respondStorageError(c, err, "update the job")
+		return
+	}
+
+	respondSavedJob(c, s, http.StatusAccepted, j.Id, "job updated")
+}
+
+// Answers with the job as it was stored. The change is already done, so a job that can't be read back
+// is left out instead of failing the request
+func respondSavedJob(c *gin.Context, s storage.APIStorage, status int, id uuid.UUID, message string) {
+	res := SaveJobResponse{Message: message, Id: id}
+	if stored, err := s.GetJob(id); err == nil {
+		res.Job = stored
+	}
+	c.JSON(status, res)
+}
+
+func PauseJob(s storage.APIStorage) gin.HandlerFunc {
+	return changeJobState(func(jobId uuid.UUID, by string) error { return s.PauseJob(jobId, by) }, "pause", "job paused")
+}
+
+func ResumeJob(s storage.APIStorage) gin.HandlerFunc {
+	return changeJobState(func(jobId uuid.UUID, by string) error { return s.ResumeJob(jobId, by) }, "resume", "job resumed")
+}
+
+func DeleteJob(s storage.APIStorage) gin.HandlerFunc {
+	return changeJobState(func(jobId uuid.UUID, by string) error { return s.DeleteJob(jobId, by) }, "delete", "job deleted")
+}
+
+// Builds a handler that applies a state change to the job in the "id" param on behalf of the caller
+func changeJobState(change func(jobId uuid.UUID, by string) error, action string, message string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := uuid.FromString(c.Param("id"))
 
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				errorLabel: "an internal error happened while trying to create a new job",
-			})
+			respondError(c, http.StatusBadRequest, fmt.Sprintf("bad id provided: %s", c.Param("id")))
 			return
 		}
 
-		c.JSON(http.StatusAccepted, gin.H{
-			"message": "job created",
-		})
+		err = change(id, Caller(c).Name)
+
+		if errors.Is(err, storage.ErrNotFound) {
+			respondError(c, http.StatusNotFound, fmt.Sprintf("could not find a job to %s with id %v", action, id))
+			return
+		}
+
+		if err != nil {
+			respondStorageError(c, err, action+" the job")
+			return
+		}
+
+		c.JSON(http.StatusAccepted, MessageResponse{Message: message})
+	}
+}
 
+// Asks the scheduler owning a job to run it right away.
+// With "wait=true" it waits up to "timeout" seconds for the result.
+func RunJob(s storage.APIStorage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := uuid.FromString(c.Param("id"))
+
+		if err != nil {
+			respondError(c, http.StatusBadRequest, fmt.Sprintf("bad id provided: %s", c.Param("id")))
+			return
+		}
+
+		wait := c.DefaultQuery(waitLabel, "false") == "true"
+		timeoutQ := c.DefaultQuery(timeoutLabel, "30")
+		timeout, err := strconv.Atoi(timeoutQ)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, BadQueryError(timeoutLabel, timeoutQ))
+			return
+		}
+		if timeout < 1 || timeout > maxRunWait {
+			respondError(c, http.StatusBadRequest, fmt.Sprintf("%q must be between 1 and %d", timeoutLabel, maxRunWait))
+			return
+		}
+
+		runId, err := s.RequestRun(id)
+
+		if errors.Is(err, storage.ErrNotFound) {
+			respondError(c, http.StatusNotFound, fmt.Sprintf("could not find a job to run with id %v", id))
+			return
+		}
+
+		if errors.Is(err, storage.ErrNotClaimed) {
+			respondProblem(c, newProblem(c, http.StatusConflict, storage.ErrNotClaimed.Code, fmt.Sprintf("job %v is not claimed by any scheduler yet", id)))
+			return
+		}
+
+		if err != nil {
+			respondStorageError(c, err, "run the job")
+			return
+		}
+
+		if !wait {
+			c.JSON(http.StatusAccepted, RunJobResponse{Message: "run requested", RunId: runId})
+			return
+		}
+
+		deadline := time.NewTimer(time.Duration(timeout) * time.Second)
+		defer deadline.Stop()
+		poll := time.NewTicker(runResultPollInterval)
+		defer poll.Stop()
+
+		for {
+			select {
+			case <-poll.C:
+				result := s.GetRunResult(runId)
+				if result == nil {
+					continue
+				}
+				c.JSON(http.StatusOK, RunJobResponse{RunId: runId, Result: result})
+				return
+
+			case <-deadline.C:
+				c.JSON(http.StatusAccepted, RunJobResponse{Message: "run requested but timed out waiting for its result", RunId: runId})
+				return
+
+			case <-c.Request.Context().Done():
+				return
+			}
+		}
 	}
 }
 
+// Lists the upcoming execution times of a schedule, so clients can preview it before creating a job
+func NextExecutions(c *gin.Context) {
+	cronexp := c.Query(cronexpLabel)
+	timezone := c.Query(timezoneLabel)
+	countQ := c.DefaultQuery(countLabel, "5")
+
+	count, err := strconv.Atoi(countQ)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, BadQueryError(countLabel, countQ))
+		return
+	}
+	if count < 1 || count > maxNextExecutions {
+		respondError(c, http.StatusBadRequest, fmt.Sprintf("%q must be between 1 and %d", countLabel, maxNextExecutions))
+		return
+	}
+
+	expr, err := cronParser.Parse(cronexp)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid cron expression provided")
+		return
+	}
+
+	loc, err := cronParser.LoadLocation(timezone)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid timezone provided")
+		return
+	}
+
+	c.JSON(http.StatusOK, NextExecutionsResponse{
+		CronExpString:  cronexp,
+		Timezone:       loc.String(),
+		NextExecutions: cronParser.NextN(cronParser.InLocation(expr, loc), time.Now(), count),
+	})
+}
+
 type InstanceInfo struct {
 	AppName     string `json:"appName"`
 	DbConnected bool   `json:"dbConnected"`
@@ -223,6 +573,8 @@ type InstanceInfo struct {
 	StartedAt   int64  `json:"startedAtMicro"`
 	UpTimeMicro int64  `json:"upTimeMicro"`
 	MaxJobs     int    `json:"maxJobs"`
+	// Notifications we dropped because we couldn't understand them
+	MalformedNotifications int64 `json:"malformedNotifications"`
 }
 
 type apiStatsStorage interface {
@@ -235,16 +587,24 @@ func GetInstanceInfo(s apiStatsStorage) gin.HandlerFunc {
 		cfg := config.FromEnvs()
 		dbConnected := s.Connected()
 		tlsActive, tlsVersion := s.GetSSLVersion()
+		dbUrl := fmt.Sprintf("%s://-:-@%s:%s/%s", cfg.Protocol, cfg.Host, cfg.Port, cfg.Dbname)
+		switch cfg.Kind {
+		case config.SQLITE_STORAGE:
+			dbUrl = "sqlite://" + cfg.SQLitePath
+		case config.MEMORY_STORAGE:
+			dbUrl = "memory://"
+		}
 		payload := &InstanceInfo{
 			cfg.AppName,
 			dbConnected,
-			fmt.Sprintf("%s://-:-@%s:%s/%s", cfg.Protocol, cfg.Host, cfg.Port, cfg.Dbname),
+			dbUrl,
 			tlsActive,
 			tlsVersion,
 			config.AppStats.ClaimedJobs,
 			config.AppStats.StartedAt,
 			config.AppStats.Uptime(),
 			config.MaxJobs(),
+			config.AppStats.MalformedNotifications(),
 		}
 
 		c.JSON(200, &payload)
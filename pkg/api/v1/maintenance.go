@@ -0,0 +1,79 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/back-end-labs/ruok/pkg/labels"
+	"github.com/back-end-labs/ruok/pkg/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+)
+
+func ListMaintenanceWindows(s storage.APIStorage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		windows := s.GetMaintenanceWindows()
+		if windows == nil {
+			respondError(c, http.StatusInternalServerError, "an internal error happened while trying to list maintenance windows")
+			return
+		}
+
+		c.JSON(http.StatusOK, ListMaintenanceWindowsResponse{MaintenanceWindows: windows})
+	}
+}
+
+func CreateMaintenanceWindow(s storage.APIStorage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var w storage.CreateMaintenanceWindowInput
+		if err := c.ShouldBindJSON(&w); err != nil {
+			respondError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		errors, hasErrors := validateMaintenanceFields(w)
+
+		if hasErrors {
+			respondInvalid(c, errors)
+			return
+		}
+
+		// kept as parsed, so a blank selector doesn't scope the window
+		selector, _ := labels.Parse(w.Selector)
+		w.Selector = selector.String()
+
+		err := s.CreateMaintenanceWindow(w)
+
+		if err != nil {
+			respondStorageError(c, err, "create a new maintenance window")
+			return
+		}
+
+		c.JSON(http.StatusCreated, MessageResponse{Message: "maintenance window created"})
+	}
+}
+
+func DeleteMaintenanceWindow(s storage.APIStorage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := uuid.FromString(c.Param("id"))
+
+		if err != nil {
+			respondError(c, http.StatusBadRequest, fmt.Sprintf("bad id provided: %s", c.Param("id")))
+			return
+		}
+
+		err = s.DeleteMaintenanceWindow(id)
+
+		if errors.Is(err, storage.ErrNotFound) {
+			respondError(c, http.StatusNotFound, fmt.Sprintf("could not find maintenance window with id %v", id))
+			return
+		}
+
+		if err != nil {
+			respondStorageError(c, err, "delete the maintenance window")
+			return
+		}
+
+		c.JSON(http.StatusOK, MessageResponse{Message: "maintenance window deleted"})
+	}
+}
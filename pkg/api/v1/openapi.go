@@ -0,0 +1,353 @@
+package v1
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/back-end-labs/ruok/pkg/auth"
+	"github.com/back-end-labs/ruok/pkg/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+)
+
+// Types a parameter can have
+const (
+	StringParam   = "string"
+	IntegerParam  = "integer"
+	BooleanParam  = "boolean"
+	UUIDParam     = "uuid"
+	DateTimeParam = "date-time"
+)
+
+// A parameter of an operation, taken from the path or the query
+type Param struct {
+	Name string
+	// "path" or "query"
+	In          string
+	Type        string
+	Description string
+}
+
+// A route of the API, what the OpenAPI spec and the client are built from.
+// Request and Response hold a zero value of their bodies, the spec only looks at their types.
+type Operation struct {
+	// Also the name of the method of the client
+	Id     string
+	Method string
+	// Relative to /v1, with gin params like /jobs/:id
+	Path    string
+	Summary string
+	// Empty for public routes
+	Scope   auth.Scope
+	Params  []Param
+	Request any
+	// Status of a successful response
+	Status int
+	// A string for plain text responses
+	Response any
+}
+
+var jobIdParam = Param{"id", "path", UUIDParam, "id of the job"}
+var selectorParam = Param{selectorLabel, "query", StringParam, "labels the jobs must have, like env=prod,team!=core"}
+
+// Every route under /v1, tests make sure it matches the router
+var Operations = []Operation{
+	{Id: "Status", Method: http.MethodGet, Path: "/status", Summary: "Answers OK while the server is up", Status: http.StatusOK, Response: ""},
+	{Id: "Health", Method: http.MethodGet, Path: "/health", Summary: "Reports ok unless the listener lost its connection, answering 503 then", Status: http.StatusOK, Response: HealthResponse{}},
+	{Id: "AuthConfig", Method: http.MethodGet, Path: "/auth/config", Summary: "Tells clients how to log in", Status: http.StatusOK, Response: AuthSettings{}},
+	{Id: "OpenAPI", Method: http.MethodGet, Path: "/openapi.json", Summary: "Serves the OpenAPI 3 spec of the API", Status: http.StatusOK, Response: map[string]any{}},
+	{
+		Id: "ListJobs", Method: http.MethodGet, Path: "/jobs", Scope: auth.ReadScope,
+		Summary: "Lists the jobs of every scheduler, a page at a time",
+		Params: []Param{
+			{limitLabel, "query", IntegerParam, "jobs in the page, 10 by default"},
+			{cursorLabel, "query", StringParam, "nextCursor of the previous page"},
+			{statusLabel, "query", StringParam, "only jobs with this status"},
+			{succeededLabel, "query", StringParam, "only jobs whose last execution was ok or error"},
+			{nameLabel, "query", StringParam, "only jobs whose name contains this, ignoring case"},
+			selectorParam,
+			{sortLabel, "query", StringParam, "id, name, createdAt or lastExecution, prefixed with - for descending order"},
+		},
+		Status: http.StatusOK, Response: ListJobsResponse{},
+	},
+	{Id: "GetJob", Method: http.MethodGet, Path: "/jobs/:id", Scope: auth.ReadScope, Summary: "Gets the definition of a job", Params: []Param{jobIdParam}, Status: http.StatusOK, Response: GetJobResponse{}},
+	{
+		Id: "ListJobExecutions", Method: http.MethodGet, Path: "/jobs/:id/executions", Scope: auth.ReadScope,
+		Summary: "Lists the executions of a job, newest first",
+		Params: []Param{
+			jobIdParam,
+			{limitLabel, "query", IntegerParam, "executions in the page, 10 by default"},
+			{cursorLabel, "query", StringParam, "nextCursor of the previous page"},
+		},
+		Status: http.StatusOK, Response: ListJobExecutionsResponse{},
+	},
+	{
+		Id: "ListJobResultAggregates", Method: http.MethodGet, Path: "/jobs/:id/aggregates", Scope: auth.ReadScope,
+		Summary: "Counts the executions and failures of a job and its latencies, by hour or day",
+		Params: []Param{
+			jobIdParam,
+			{fromLabel, "query", DateTimeParam, "start of the range"},
+			{toLabel, "query", DateTimeParam, "end of the range, now by default"},
+			{granularityLabel, "query", StringParam, "hour or day, picked from the range by default"},
+		},
+		Status: http.StatusOK, Response: AggregatesResponse{},
+	},
+	{
+		Id: "ListJobHistory", Method: http.MethodGet, Path: "/jobs/:id/history", Scope: auth.ReadScope,
+		Summary: "Lists the changes made to a job, newest first",
+		Params: []Param{
+			jobIdParam,
+			{limitLabel, "query", IntegerParam, "revisions in the page, 20 by default"},
+			{offsetLabel, "query", IntegerParam, "revisions to skip"},
+		},
+		Status: http.StatusOK, Response: HistoryResponse{},
+	},
+	{Id: "GetInstanceInfo", Method: http.MethodGet, Path: "/instance", Scope: auth.ReadScope, Summary: "Describes the scheduler answering", Status: http.StatusOK, Response: InstanceInfo{}},
+	{
+		Id: "NextExecutions", Method: http.MethodGet, Path: "/schedules/next", Scope: auth.ReadScope,
+		Summary: "Lists the upcoming execution times of a schedule",
+		Params: []Param{
+			{cronexpLabel, "query", StringParam, "cron expression"},
+			{timezoneLabel, "query", StringParam, "IANA timezone, UTC by default"},
+			{countLabel, "query", IntegerParam, "how many executions, 5 by default"},
+		},
+		Status: http.StatusOK, Response: NextExecutionsResponse{},
+	},
+	{Id: "ListMaintenanceWindows", Method: http.MethodGet, Path: "/maintenance", Scope: auth.ReadScope, Summary: "Lists the maintenance windows", Status: http.StatusOK, Response: ListMaintenanceWindowsResponse{}},
+	{
+		Id: "Export", Method: http.MethodGet, Path: "/export", Scope: auth.ReadScope,
+		Summary: "Writes every job as definitions keyed by their stable name",
+		Params:  []Param{{formatLabel, "query", StringParam, "json or yaml, json by default"}},
+		Status:  http.StatusOK, Response: Definitions{},
+	},
+	{Id: "CreateJob", Method: http.MethodPost, Path: "/jobs", Scope: auth.WriteScope, Summary: "Creates a job", Request: storage.CreateJobInput{}, Status: http.StatusCreated, Response: SaveJobResponse{}},
+	{Id: "UpdateJob", Method: http.MethodPut, Path: "/jobs/:id", Scope: auth.WriteScope, Summary: "Replaces the definition of a job", Params: []Param{jobIdParam}, Request: storage.UpdateJobInput{}, Status: http.StatusAccepted, Response: SaveJobResponse{}},
+	{Id: "PatchJob", Method: http.MethodPatch, Path: "/jobs/:id", Scope: auth.WriteScope, Summary: "Changes only the fields of a job present in the body", Params: []Param{jobIdParam}, Request: PatchJobRequest{}, Status: http.StatusAccepted, Response: SaveJobResponse{}},
+	{Id: "DeleteJob", Method: http.MethodDelete, Path: "/jobs/:id", Scope: auth.WriteScope, Summary: "Deletes a job", Params: []Param{jobIdParam}, Status: http.StatusAccepted, Response: MessageResponse{}},
+	{Id: "PauseJobs", Method: http.MethodPost, Path: "/jobs/pause", Scope: auth.WriteScope, Summary: "Pauses every job matching the selector", Params: []Param{selectorParam}, Status: http.StatusOK, Response: BulkResponse{}},
+	{Id: "ResumeJobs", Method: http.MethodPost, Path: "/jobs/resume", Scope: auth.WriteScope, Summary: "Resumes every paused job matching the selector", Params: []Param{selectorParam}, Status: http.StatusOK, Response: BulkResponse{}},
+	{Id: "UpdateJobsAlerts", Method: http.MethodPut, Path: "/jobs/alerts", Scope: auth.WriteScope, Summary: "Sends the alerts of every job matching the selector to the same channel", Params: []Param{selectorParam}, Request: storage.JobAlertsInput{}, Status: http.StatusOK, Response: BulkResponse{}},
+	{Id: "PauseJob", Method: http.MethodPost, Path: "/jobs/:id/pause", Scope: auth.WriteScope, Summary: "Pauses a job", Params: []Param{jobIdParam}, Status: http.StatusAccepted, Response: MessageResponse{}},
+	{Id: "ResumeJob", Method: http.MethodPost, Path: "/jobs/:id/resume", Scope: auth.WriteScope, Summary: "Resumes a paused job", Params: []Param{jobIdParam}, Status: http.StatusAccepted, Response: MessageResponse{}},
+	{
+		Id: "RunJob", Method: http.MethodPost, Path: "/jobs/:id/run", Scope: auth.WriteScope,
+		Summary: "Runs a job right away, answering 200 with the result when waiting for it",
+		Params: []Param{
+			jobIdParam,
+			{waitLabel, "query", BooleanParam, "wait for the result"},
+			{timeoutLabel, "query", IntegerParam, "seconds to wait for the result, 30 by default"},
+		},
+		Status: http.StatusAccepted, Response: RunJobResponse{},
+	},
+	{
+		Id: "RestoreJob", Method: http.MethodPost, Path: "/jobs/:id/history/:revision/restore", Scope: auth.WriteScope,
+		Summary: "Brings a job back to how it was at a revision",
+		Params:  []Param{jobIdParam, {"revision", "path", IntegerParam, "revision to restore"}},
+		Status:  http.StatusAccepted, Response: MessageResponse{},
+	},
+	{
+		Id: "Import", Method: http.MethodPost, Path: "/import", Scope: auth.WriteScope,
+		Summary: "Creates the jobs of the definitions that are missing and updates the ones that differ, yaml bodies are read as yaml. Failed changes don't undo the rest, the problem lists them all",
+		Params:  []Param{{dryRunLabel, "query", BooleanParam, "only answer with what would change"}},
+		Request: Definitions{}, Status: http.StatusOK, Response: ImportResponse{},
+	},
+	{Id: "CreateMaintenanceWindow", Method: http.MethodPost, Path: "/maintenance", Scope: auth.WriteScope, Summary: "Creates a maintenance window, only for the default tenant", Request: storage.CreateMaintenanceWindowInput{}, Status: http.StatusCreated, Response: MessageResponse{}},
+	{Id: "DeleteMaintenanceWindow", Method: http.MethodDelete, Path: "/maintenance/:id", Scope: auth.WriteScope, Summary: "Deletes a maintenance window, only for the default tenant", Params: []Param{{"id", "path", UUIDParam, "id of the maintenance window"}}, Status: http.StatusOK, Response: MessageResponse{}},
+	{Id: "ListAPIKeys", Method: http.MethodGet, Path: "/apikeys", Scope: auth.AdminScope, Summary: "Lists the api keys, revoked ones included", Status: http.StatusOK, Response: ListAPIKeysResponse{}},
+	{Id: "CreateAPIKey", Method: http.MethodPost, Path: "/apikeys", Scope: auth.AdminScope, Summary: "Creates an api key, the only time it can be read", Request: CreateAPIKeyRequest{}, Status: http.StatusCreated, Response: CreateAPIKeyResponse{}},
+	{Id: "RevokeAPIKey", Method: http.MethodDelete, Path: "/apikeys/:id", Scope: auth.AdminScope, Summary: "Stops accepting an api key right away", Params: []Param{{"id", "path", UUIDParam, "id of the api key"}}, Status: http.StatusAccepted, Response: MessageResponse{}},
+}
+
+var ginParamRegexp = regexp.MustCompile(`:(\w+)`)
+
+// The path as OpenAPI writes it, like /jobs/{id}
+func (op Operation) OpenAPIPath() string {
+	return ginParamRegexp.ReplaceAllString(op.Path, "{$1}")
+}
+
+var spec = sync.OnceValue(func() map[string]any { return Spec(Operations) })
+
+// Serves the OpenAPI 3 spec of the API
+func OpenAPI(c *gin.Context) {
+	c.JSON(http.StatusOK, spec())
+}
+
+// Builds the OpenAPI 3 spec of the operations, the schemas of their bodies are taken from their types
+func Spec(ops []Operation) map[string]any {
+	schemas := &schemaBuilder{schemas: map[string]any{}, names: map[reflect.Type]string{}}
+	paths := map[string]any{}
+
+	for _, op := range ops {
+		methods, ok := paths[op.OpenAPIPath()].(map[string]any)
+		if !ok {
+			methods = map[string]any{}
+			paths[op.OpenAPIPath()] = methods
+		}
+
+		params := []any{}
+		for _, p := range op.Params {
+			params = append(params, map[string]any{
+				"name":        p.Name,
+				"in":          p.In,
+				"required":    p.In == "path",
+				"description": p.Description,
+				"schema":      paramSchema(p.Type),
+			})
+		}
+
+		responses := map[string]any{
+			fmt.Sprint(op.Status): map[string]any{
+				"description": http.StatusText(op.Status),
+				"content":     schemas.content(op.Response),
+			},
+			"default": map[string]any{
+				"description": "Error",
+				"content":     map[string]any{problemContentType: map[string]any{"schema": schemas.schema(reflect.TypeOf(Problem{}))}},
+			},
+		}
+
+		operation := map[string]any{
+			"operationId": op.Id,
+			"summary":     op.Summary,
+			"parameters":  params,
+			"responses":   responses,
+		}
+		if op.Request != nil {
+			operation["requestBody"] = map[string]any{
+				"required": true,
+				"content":  schemas.content(op.Request),
+			}
+		}
+		if op.Scope != "" {
+			operation["security"] = []any{map[string]any{"bearer": []string{}}}
+			operation["description"] = "Needs the " + string(op.Scope) + " scope"
+		}
+		methods[strings.ToLower(op.Method)] = operation
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   "ruok",
+			"version": "v1",
+		},
+		"servers": []any{map[string]any{"url": "/v1"}},
+		"paths":   paths,
+		"components": map[string]any{
+			"schemas": schemas.schemas,
+			"securitySchemes": map[string]any{
+				"bearer": map[string]any{"type": "http", "scheme": "bearer", "description": "an api key or a token of the identity provider"},
+			},
+		},
+	}
+}
+
+func paramSchema(t string) map[string]any {
+	switch t {
+	case UUIDParam, DateTimeParam:
+		return map[string]any{"type": "string", "format": t}
+	default:
+		return map[string]any{"type": t}
+	}
+}
+
+// Adds the schemas of named structs to the components as they are found
+type schemaBuilder struct {
+	schemas map[string]any
+	names   map[reflect.Type]string
+}
+
+func (b *schemaBuilder) content(body any) map[string]any {
+	if _, ok := body.(string); ok {
+		return map[string]any{"text/plain": map[string]any{"schema": map[string]any{"type": "string"}}}
+	}
+	return map[string]any{"application/json": map[string]any{"schema": b.schema(reflect.TypeOf(body))}}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+var uuidType = reflect.TypeOf(uuid.UUID{})
+
+func (b *schemaBuilder) schema(t reflect.Type) map[string]any {
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t == uuidType:
+		return map[string]any{"type": "string", "format": "uuid"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		s := b.schema(t.Elem())
+		if _, ok := s["$ref"]; ok {
+			return map[string]any{"allOf": []any{s}, "nullable": true}
+		}
+		s["nullable"] = true
+		return s
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]any{"type": "integer", "format": "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": b.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": b.schema(t.Elem())}
+	case reflect.Struct:
+		return b.ref(t)
+	default:
+		// interfaces can hold anything
+		return map[string]any{}
+	}
+}
+
+// Points to the schema of a struct, adding it to the components the first time
+func (b *schemaBuilder) ref(t reflect.Type) map[string]any {
+	name, ok := b.names[t]
+	if !ok {
+		name = t.Name()
+		if _, taken := b.schemas[name]; taken {
+			name = path.Base(t.PkgPath()) + name
+		}
+		b.names[t] = name
+		// taken before filling it in, so types that contain themselves end
+		b.schemas[name] = nil
+		properties := map[string]any{}
+		b.properties(t, properties)
+		b.schemas[name] = map[string]any{"type": "object", "properties": properties}
+	}
+	return map[string]any{"$ref": "#/components/schemas/" + name}
+}
+
+// The properties of a struct as encoding/json writes them, fields of embedded structs included
+func (b *schemaBuilder) properties(t reflect.Type, properties map[string]any) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" || !f.IsExported() && !f.Anonymous {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			b.properties(f.Type, properties)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		properties[name] = b.schema(f.Type)
+	}
+}
@@ -0,0 +1,104 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/back-end-labs/ruok/pkg/storage"
+	"github.com/gin-gonic/gin"
+)
+
+var problemContentType = "application/problem+json"
+
+// Codes of the problems, the ones of storage.KindError are answered as they are
+const (
+	CodeBadRequest       = "bad_request"
+	CodeUnauthorized     = "unauthorized"
+	CodeForbidden        = "forbidden"
+	CodeQuotaExceeded    = "quota_exceeded"
+	CodeNotFound         = "not_found"
+	CodeConflict         = "conflict"
+	CodeValidationFailed = "validation_failed"
+	CodeInternal         = "internal_error"
+)
+
+// Codes of what can be wrong with a field
+const (
+	FieldRequired    = "required"
+	FieldInvalid     = "invalid"
+	FieldOutOfRange  = "out_of_range"
+	FieldDuplicate   = "duplicate"
+	FieldConflicting = "conflicting"
+)
+
+// Code of the problems of each status when there is no better one
+var statusCodes = map[int]string{
+	http.StatusBadRequest:          CodeBadRequest,
+	http.StatusUnauthorized:        CodeUnauthorized,
+	http.StatusForbidden:           CodeForbidden,
+	http.StatusNotFound:            CodeNotFound,
+	http.StatusConflict:            CodeConflict,
+	http.StatusUnprocessableEntity: CodeValidationFailed,
+	http.StatusInternalServerError: CodeInternal,
+}
+
+func newProblem(c *gin.Context, status int, code string, detail string) Problem {
+	return Problem{
+		Type:     "urn:ruok:problem:" + code,
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   detail,
+		Code:     code,
+		Instance: c.Request.URL.Path,
+	}
+}
+
+func respondProblem(c *gin.Context, p Problem) {
+	c.Header("Content-Type", problemContentType)
+	c.JSON(p.Status, p)
+}
+
+// Answers with the code of the status
+func respondError(c *gin.Context, status int, detail string) {
+	respondProblem(c, newProblem(c, status, statusCodes[status], detail))
+}
+
+// Answers 422 with what is wrong with each field of the body
+func respondInvalid(c *gin.Context, fieldErrors []FieldError) {
+	p := newProblem(c, http.StatusUnprocessableEntity, CodeValidationFailed, "the body has invalid fields")
+	p.Errors = fieldErrors
+	respondProblem(c, p)
+}
+
+// Answers an error of the storage. Its kind picks the status and storage.KindError says what happened,
+// any other error is a 500 telling what we were trying to do.
+func respondStorageError(c *gin.Context, err error, action string) {
+	respondProblem(c, storageProblem(c, err, action))
+}
+
+// The problem respondStorageError answers with
+func storageProblem(c *gin.Context, err error, action string) Problem {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, storage.ErrConflict):
+		status = http.StatusConflict
+	case errors.Is(err, storage.ErrInvalid):
+		status = http.StatusUnprocessableEntity
+	case errors.Is(err, storage.ErrForbidden):
+		status = http.StatusForbidden
+	}
+
+	p := newProblem(c, status, statusCodes[status], "an internal error happened while trying to "+action)
+	if status == http.StatusNotFound {
+		p.Detail = err.Error()
+	}
+	var kindErr *storage.KindError
+	if errors.As(err, &kindErr) {
+		p.Code = kindErr.Code
+		p.Type = "urn:ruok:problem:" + kindErr.Code
+		p.Detail = kindErr.Msg
+	}
+	return p
+}
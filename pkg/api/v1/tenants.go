@@ -0,0 +1,90 @@
+package v1
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/back-end-labs/ruok/pkg/auth"
+	"github.com/back-end-labs/ruok/pkg/config"
+	"github.com/back-end-labs/ruok/pkg/cronParser"
+	"github.com/back-end-labs/ruok/pkg/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// Executions looked at to find how often a schedule runs
+var quotaExecutions = 50
+
+// Runs the handler with a storage that only sees the jobs of the tenant of the caller,
+// and refuses to add jobs over the quota of the tenant even when requests race each other.
+// Callers without a tenant, like anonymous ones, get the storage as it is.
+func ForCaller(s storage.APIStorage, handler func(storage.APIStorage) gin.HandlerFunc) gin.HandlerFunc {
+	unscoped := handler(s)
+	return func(c *gin.Context) {
+		if tenant := Caller(c).Tenant; tenant != "" {
+			handler(s.ForTenant(tenant).WithMaxJobs(config.TenantQuotaFor(tenant).MaxJobs))(c)
+			return
+		}
+		unscoped(c)
+	}
+}
+
+// Only lets through callers of auth.DefaultTenant or without a tenant, the rest get a 403.
+// Used for what is shared by every tenant, like maintenance windows. It must run after Authenticate.
+func RequireDefaultTenant() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if tenant := Caller(c).Tenant; tenant != "" && tenant != auth.DefaultTenant {
+			respondError(c, http.StatusForbidden, "only the "+auth.DefaultTenant+" tenant can change what every tenant shares, "+Caller(c).Name+" is in "+tenant)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// Limits of the tenant of the caller, callers without a tenant have none
+func callerQuota(c *gin.Context) config.TenantQuota {
+	if tenant := Caller(c).Tenant; tenant != "" {
+		return config.TenantQuotaFor(tenant)
+	}
+	return config.TenantQuota{}
+}
+
+// Responds with 403 and returns FALSE if the schedule runs more often than the quota allows
+func checkIntervalQuota(c *gin.Context, quota config.TenantQuota, cronExpString string, timezone string) bool {
+	if quota.MinInterval == 0 {
+		return true
+	}
+	// the fields are validated already, so errors can't happen
+	expr, err := cronParser.Parse(cronExpString)
+	if err != nil {
+		return true
+	}
+	loc, err := cronParser.LoadLocation(timezone)
+	if err != nil {
+		return true
+	}
+	gap := cronParser.ShortestGap(cronParser.InLocation(expr, loc), time.Now(), quotaExecutions)
+	if gap != 0 && gap < quota.MinInterval {
+		respondProblem(c, newProblem(c, http.StatusForbidden, CodeQuotaExceeded, fmt.Sprintf("tenant %q can't run jobs more often than every %v, this one runs every %v", Caller(c).Tenant, quota.MinInterval, gap)))
+		return false
+	}
+	return true
+}
+
+// Responds with 403 and returns FALSE if adding that many jobs goes over the quota of the tenant
+func checkJobsQuota(c *gin.Context, quota config.TenantQuota, s storage.APIStorage, adding int) bool {
+	if quota.MaxJobs == 0 {
+		return true
+	}
+	count, err := s.CountJobs()
+	if err != nil {
+		respondStorageError(c, err, "check the quota of the tenant")
+		return false
+	}
+	if count+adding > quota.MaxJobs {
+		respondProblem(c, newProblem(c, http.StatusForbidden, CodeQuotaExceeded, fmt.Sprintf("tenant %q can't have more than %d jobs", Caller(c).Tenant, quota.MaxJobs)))
+		return false
+	}
+	return true
+}
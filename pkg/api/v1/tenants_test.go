@@ -0,0 +1,91 @@
+package v1
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/back-end-labs/ruok/pkg/auth"
+	"github.com/back-end-labs/ruok/pkg/config"
+	"github.com/back-end-labs/ruok/pkg/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func tenantContext(tenant string) (*gin.Context, *httptest.ResponseRecorder) {
+	rr := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rr)
+	c.Request = httptest.NewRequest("POST", "/v1/jobs", nil)
+	c.Set(identityKey, auth.Identity{Name: "key:" + tenant, Scope: auth.WriteScope, Tenant: tenant})
+	return c, rr
+}
+
+func TestCheckIntervalQuota(t *testing.T) {
+	quota := config.TenantQuota{MinInterval: 5 * time.Minute}
+	tests := []struct {
+		name    string
+		quota   config.TenantQuota
+		cronexp string
+		pass    bool
+	}{
+		{"NoQuota", config.TenantQuota{}, "every 1s", true},
+		{"AsOftenAsAllowed", quota, "*/5 * * * *", true},
+		{"TooOften", quota, "*/1 * * * *", false},
+		{"TooOftenSometimes", quota, "0,1 9 * * *", false},
+		{"OneShot", quota, "at 2099-01-01T00:00:00Z", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, rr := tenantContext("acme")
+			assert.Equal(t, tt.pass, checkIntervalQuota(c, tt.quota, tt.cronexp, ""))
+			if !tt.pass {
+				assert.Equal(t, http.StatusForbidden, rr.Code)
+				assert.Contains(t, rr.Body.String(), `"code":"quota_exceeded"`)
+			}
+		})
+	}
+}
+
+func TestCheckJobsQuota(t *testing.T) {
+	s, close := storage.NewMemoryStorage()
+	defer close()
+	acme := s.ForTenant("acme")
+	_, err := acme.CreateJob(storage.CreateJobInput{Name: "first", CronExpString: "*/5 * * * *"})
+	assert.NoError(t, err)
+	_, err = s.ForTenant("globex").CreateJob(storage.CreateJobInput{Name: "other", CronExpString: "*/5 * * * *"})
+	assert.NoError(t, err)
+
+	c, _ := tenantContext("acme")
+	assert.True(t, checkJobsQuota(c, config.TenantQuota{MaxJobs: 2}, acme, 1), "jobs of other tenants don't count")
+	assert.True(t, checkJobsQuota(c, config.TenantQuota{}, acme, 1))
+
+	c, rr := tenantContext("acme")
+	assert.False(t, checkJobsQuota(c, config.TenantQuota{MaxJobs: 1}, acme, 1))
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	c, _ = tenantContext("acme")
+	assert.False(t, checkJobsQuota(c, config.TenantQuota{MaxJobs: 2}, acme, 2), "jobs added at once count together")
+}
+
+func TestRestoreJob_JobsQuota(t *testing.T) {
+	s, close := storage.NewMemoryStorage()
+	defer close()
+	acme := s.ForTenant("acme")
+	deleted, err := acme.CreateJob(storage.CreateJobInput{Name: "deleted", CronExpString: "*/5 * * * *"})
+	assert.NoError(t, err)
+	assert.NoError(t, acme.DeleteJob(deleted, ""))
+	_, err = acme.CreateJob(storage.CreateJobInput{Name: "current", CronExpString: "*/5 * * * *"})
+	assert.NoError(t, err)
+
+	c, rr := tenantContext("acme")
+	c.Params = gin.Params{{Key: "id", Value: deleted.String()}, {Key: "revision", Value: "1"}}
+	RestoreJob(acme.WithMaxJobs(1))(c)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"code":"quota_exceeded"`)
+
+	c, rr = tenantContext("acme")
+	c.Params = gin.Params{{Key: "id", Value: deleted.String()}, {Key: "revision", Value: "1"}}
+	RestoreJob(acme.WithMaxJobs(2))(c)
+	assert.Equal(t, http.StatusAccepted, rr.Code)
+}
@@ -0,0 +1,208 @@
+package v1
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+
+	"github.com/back-end-labs/ruok/pkg/auth"
+	"github.com/back-end-labs/ruok/pkg/job"
+	"github.com/back-end-labs/ruok/pkg/maintenance"
+	"github.com/back-end-labs/ruok/pkg/storage"
+)
+
+// Bodies of every request and response of the API. They are what the OpenAPI spec and the client are made of,
+// so handlers answer with them instead of ad hoc maps.
+
+// Answer of every request that failed, problem details (RFC 9457) sent as application/problem+json
+type Problem struct {
+	// "urn:ruok:problem:" followed by the code
+	Type  string `json:"type"`
+	Title string `json:"title"`
+	// Same as the status of the response
+	Status int `json:"status"`
+	// What happened, for people
+	Detail string `json:"detail"`
+	// What happened, for programs. Like "not_found" or "wrong_state"
+	Code string `json:"code"`
+	// Path of the request
+	Instance string `json:"instance"`
+	// What is wrong with each field, only when the body didn't pass validation
+	Errors []FieldError `json:"errors,omitempty"`
+	// What each change of an import did, only when some of them could not be applied
+	Changes []JobChange `json:"changes,omitempty"`
+}
+
+// What is wrong with a field of the body
+type FieldError struct {
+	// Named like in the body, like "cronexp"
+	Field string `json:"field"`
+	// Like "required" or "invalid"
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Answer of changes that have nothing else to tell
+type MessageResponse struct {
+	Message string `json:"message"`
+}
+
+type HealthResponse struct {
+	// "ok" or "degraded"
+	Status   string                 `json:"status"`
+	Listener storage.ListenerHealth `json:"listener"`
+}
+
+type ListJobsResponse struct {
+	Jobs []*job.Job `json:"jobs"`
+	// Cursor of the next page, empty on the last one
+	NextCursor string `json:"nextCursor"`
+	Limit      int    `json:"limit"`
+	Sort       string `json:"sort"`
+	Selector   string `json:"selector"`
+}
+
+type GetJobResponse struct {
+	Job *job.Job `json:"job"`
+}
+
+// Answer of creating or changing a job
+type SaveJobResponse struct {
+	Message string    `json:"message"`
+	Id      uuid.UUID `json:"id"`
+	// The job as it was stored, left out when it could not be read back
+	Job *job.Job `json:"job,omitempty"`
+}
+
+// Fields of a job to change, the ones left out keep their values. Same fields as storage.UpdateJobInput
+type PatchJobRequest map[string]any
+
+type ListJobExecutionsResponse struct {
+	JobId      uuid.UUID           `json:"jobId"`
+	Limit      int                 `json:"limit"`
+	JobResults []*job.JobExecution `json:"jobResults"`
+	// Cursor of the next page, empty on the last one
+	NextCursor string `json:"nextCursor"`
+}
+
+type RunJobResponse struct {
+	// Empty when the result is in the response
+	Message string    `json:"message,omitempty"`
+	RunId   uuid.UUID `json:"runId"`
+	// Only when waiting for the result and it came in time
+	Result *job.ExecutionResult `json:"result,omitempty"`
+}
+
+type NextExecutionsResponse struct {
+	CronExpString  string      `json:"cronexp"`
+	Timezone       string      `json:"timezone"`
+	NextExecutions []time.Time `json:"nextExecutions"`
+}
+
+type AggregatesResponse struct {
+	JobId       uuid.UUID              `json:"jobId"`
+	Granularity string                 `json:"granularity"`
+	From        time.Time              `json:"from"`
+	To          time.Time              `json:"to"`
+	Aggregates  []*job.ResultAggregate `json:"aggregates"`
+}
+
+type HistoryResponse struct {
+	JobId     uuid.UUID       `json:"jobId"`
+	Limit     int             `json:"limit"`
+	Offset    int             `json:"offset"`
+	Revisions []*job.Revision `json:"revisions"`
+}
+
+// A job a bulk change couldn't be applied to
+type BulkFailure struct {
+	Id    uuid.UUID `json:"id"`
+	Error string    `json:"error"`
+}
+
+// What happened to every job a bulk change selected
+type BulkResponse struct {
+	Selector string        `json:"selector"`
+	Matched  int           `json:"matched"`
+	Changed  []uuid.UUID   `json:"changed"`
+	Skipped  []uuid.UUID   `json:"skipped"`
+	Failed   []BulkFailure `json:"failed"`
+}
+
+type ListMaintenanceWindowsResponse struct {
+	MaintenanceWindows []*maintenance.Window `json:"maintenanceWindows"`
+}
+
+type CreateAPIKeyRequest struct {
+	Name  string `json:"name"`
+	Scope string `json:"scope"`
+	// Defaults to the tenant of the caller, only callers without one can pick another
+	Tenant string `json:"tenant"`
+}
+
+type CreateAPIKeyResponse struct {
+	Message string `json:"message"`
+	// The key itself, it is never shown again
+	Key    string       `json:"key"`
+	APIKey *auth.APIKey `json:"apiKey"`
+}
+
+type ListAPIKeysResponse struct {
+	APIKeys []*auth.APIKey `json:"apiKeys"`
+}
+
+// Jobs written so they can be kept in git and imported into any instance of ruok, see ExportDefinitions.
+// Jobs are keyed by a stable name chosen by the user, the ones without it by their id.
+type Definitions struct {
+	// Version of the format, only DefinitionsVersion is read
+	Version int                      `json:"version" yaml:"version"`
+	Jobs    map[string]JobDefinition `json:"jobs" yaml:"jobs"`
+}
+
+// A job without what ruok keeps track of, like its status or its last execution
+type JobDefinition struct {
+	Name            string            `json:"name" yaml:"name"`
+	CronExpString   string            `json:"cronexp" yaml:"cronexp"`
+	Timezone        string            `json:"timezone,omitempty" yaml:"timezone,omitempty"`
+	MaxRetries      int               `json:"maxRetries,omitempty" yaml:"maxRetries,omitempty"`
+	Endpoint        string            `json:"endpoint" yaml:"endpoint"`
+	HttpMethod      string            `json:"httpmethod" yaml:"httpmethod"`
+	SuccessStatuses []int             `json:"successStatuses" yaml:"successStatuses,flow"`
+	AlertStrategy   string            `json:"alertStrategy,omitempty" yaml:"alertStrategy,omitempty"`
+	AlertMethod     string            `json:"alertMethod,omitempty" yaml:"alertMethod,omitempty"`
+	AlertEndpoint   string            `json:"alertEndpoint,omitempty" yaml:"alertEndpoint,omitempty"`
+	AlertPayload    string            `json:"alertPayload,omitempty" yaml:"alertPayload,omitempty"`
+	AlertHeaders    map[string]string `json:"alertHeaders,omitempty" yaml:"alertHeaders,omitempty"`
+	// Every label but the one with the key, KeyLabel
+	Labels               map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	Locations            []string          `json:"locations,omitempty" yaml:"locations,omitempty,flow"`
+	AlertQuorum          int               `json:"alertQuorum,omitempty" yaml:"alertQuorum,omitempty"`
+	ResultsRetentionDays *int              `json:"resultsRetentionDays,omitempty" yaml:"resultsRetentionDays,omitempty"`
+}
+
+// A field an import changes, with the values in json
+type FieldDiff struct {
+	Field string `json:"field"`
+	From  any    `json:"from"`
+	To    any    `json:"to"`
+}
+
+// What an import does to the job of a key, one of the Change actions
+type JobChange struct {
+	Key    string `json:"key"`
+	Action string `json:"action"`
+	// Empty for jobs to be created, set once they are
+	Id   *uuid.UUID  `json:"id,omitempty"`
+	Diff []FieldDiff `json:"diff,omitempty"`
+	// Why the change could not be applied, the job was left as it was
+	Error string `json:"error,omitempty"`
+}
+
+type ImportResponse struct {
+	// TRUE when nothing was changed, only planned
+	DryRun    bool        `json:"dryRun"`
+	Changes   []JobChange `json:"changes"`
+	Created   int         `json:"created"`
+	Updated   int         `json:"updated"`
+	Unchanged int         `json:"unchanged"`
+}
@@ -1,11 +1,15 @@
 package v1
 
 import (
+	"fmt"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/back-end-labs/ruok/pkg/config"
 	"github.com/back-end-labs/ruok/pkg/cronParser"
+	"github.com/back-end-labs/ruok/pkg/labels"
+	"github.com/back-end-labs/ruok/pkg/maintenance"
 	"github.com/back-end-labs/ruok/pkg/storage"
 	"github.com/gofrs/uuid"
 )
@@ -29,60 +33,102 @@ func validUrl(input string) bool {
 	return true
 }
 
-func validateCreateFields(j storage.CreateJobInput) ([]string, bool) {
+func validateCreateFields(j storage.CreateJobInput) ([]FieldError, bool) {
 	hasErrors := false
-	errors := []string{}
+	errors := []FieldError{}
 
 	if j.Name == "" {
 		hasErrors = true
-		errors = append(errors, "must provide a name")
+		errors = append(errors, FieldError{"name", FieldRequired, "must provide a name"})
 	}
 
 	if j.CronExpString == "" {
 		hasErrors = true
-		errors = append(errors, "cron expression string not found")
+		errors = append(errors, FieldError{"cronexp", FieldRequired, "cron expression string not found"})
 
 	} else if cronParser.IsValidExpression(j.CronExpString) {
 		hasErrors = true
-		errors = append(errors, "invalid cron expression provided")
+		errors = append(errors, FieldError{"cronexp", FieldInvalid, "invalid cron expression provided"})
+	}
+
+	if !cronParser.IsValidTimezone(j.Timezone) {
+		hasErrors = true
+		errors = append(errors, FieldError{"timezone", FieldInvalid, "invalid timezone provided"})
+	} else if j.CronExpString != "" && !cronParser.IsValidExpression(j.CronExpString) &&
+		!hasFutureExecutions(j.CronExpString, j.Timezone) {
+		hasErrors = true
+		errors = append(errors, FieldError{"cronexp", FieldOutOfRange, "schedule has no future executions"})
 	}
 
 	if j.Endpoint == "" {
 		hasErrors = true
-		errors = append(errors, "endpoint not found")
+		errors = append(errors, FieldError{"endpoint", FieldRequired, "endpoint not found"})
 
 	} else if !validUrl(j.Endpoint) {
 		hasErrors = true
-		errors = append(errors, "invalid url provided")
+		errors = append(errors, FieldError{"endpoint", FieldInvalid, "invalid url provided"})
 	}
 
 	if j.HttpMethod == "" {
 		hasErrors = true
-		errors = append(errors, "missing http method")
+		errors = append(errors, FieldError{"httpmethod", FieldRequired, "missing http method"})
 	} else if !validHttpMethod(j.HttpMethod) {
 		hasErrors = true
-		errors = append(errors, "invalid http method")
+		errors = append(errors, FieldError{"httpmethod", FieldInvalid, "invalid http method"})
 	}
 
 	if len(j.SuccessStatuses) == 0 {
 		hasErrors = true
-		errors = append(errors, "success statuses not provided")
+		errors = append(errors, FieldError{"successStatuses", FieldRequired, "success statuses not provided"})
 	}
 	if j.AlertStrategy != "" && badAlertStrategy(j.AlertStrategy, config.AlertChannels()) {
 		hasErrors = true
-		errors = append(errors, "invalid strategy provided")
+		errors = append(errors, FieldError{"alertStrategy", FieldInvalid, "invalid strategy provided"})
 	}
 	if j.AlertEndpoint != "" && !validUrl(j.AlertEndpoint) {
 		hasErrors = true
-		errors = append(errors, "invalid alert endpoint provided")
+		errors = append(errors, FieldError{"alertEndpoint", FieldInvalid, "invalid alert endpoint provided"})
 	}
 	if j.AlertMethod != "" && !validHttpMethod(j.AlertMethod) {
 		hasErrors = true
-		errors = append(errors, "invalid alert http method provided")
+		errors = append(errors, FieldError{"alertMethod", FieldInvalid, "invalid alert http method provided"})
+	}
+	if j.ResultsRetentionDays != nil && *j.ResultsRetentionDays < 0 {
+		hasErrors = true
+		errors = append(errors, FieldError{"resultsRetentionDays", FieldOutOfRange, "results retention days can't be negative"})
+	}
+	if err := labels.Validate(j.Labels); err != nil {
+		hasErrors = true
+		errors = append(errors, FieldError{"labels", FieldInvalid, err.Error()})
+	}
+	if locationErrors := validateLocations(j.Locations, j.AlertQuorum); len(locationErrors) > 0 {
+		hasErrors = true
+		errors = append(errors, locationErrors...)
 	}
 	return errors, hasErrors
 }
 
+// Locations must be valid names without repetitions, and the quorum can't ask for more of them than there are
+func validateLocations(locations []string, quorum int) []FieldError {
+	errors := []FieldError{}
+	seen := map[string]bool{}
+	for _, l := range locations {
+		if !config.IsValidLocation(l) {
+			errors = append(errors, FieldError{"locations", FieldInvalid, fmt.Sprintf("invalid location %q, only letters, numbers, '-' and '_' are allowed", l)})
+		} else if seen[l] {
+			errors = append(errors, FieldError{"locations", FieldDuplicate, fmt.Sprintf("location %q is repeated", l)})
+		}
+		seen[l] = true
+	}
+	if quorum < 0 {
+		errors = append(errors, FieldError{"alertQuorum", FieldOutOfRange, "alert quorum can't be negative"})
+	}
+	if quorum > len(locations) {
+		errors = append(errors, FieldError{"alertQuorum", FieldOutOfRange, "alert quorum can't be higher than the number of locations"})
+	}
+	return errors
+}
+
 func badAlertStrategy(ch string, valids []string) bool {
 	for _, v := range config.AlertChannels() {
 		if ch == v {
@@ -93,64 +139,200 @@ func badAlertStrategy(ch string, valids []string) bool {
 
 }
 
+// Returns TRUE if a valid expression will run at least once more (eg: one-shot jobs in the past won't)
+func hasFutureExecutions(cronLine string, timezone string) bool {
+	expr, err := cronParser.Parse(cronLine)
+	if err != nil {
+		return false
+	}
+	loc, err := cronParser.LoadLocation(timezone)
+	if err != nil {
+		return false
+	}
+	return !cronParser.InLocation(expr, loc).Next(time.Now()).IsZero()
+}
+
 var zeroValueUUID = uuid.UUID{}.String()
 
-func validateUpdateFields(j storage.UpdateJobInput) ([]string, bool) {
+func validateUpdateFields(j storage.UpdateJobInput) ([]FieldError, bool) {
 	hasErrors := false
-	errors := []string{}
+	errors := []FieldError{}
 
 	if j.Id.String() == zeroValueUUID {
 		hasErrors = true
-		errors = append(errors, "invalid or missing id")
+		errors = append(errors, FieldError{"id", FieldRequired, "invalid or missing id"})
 	}
 
 	if j.Name == "" {
 		hasErrors = true
-		errors = append(errors, "must provide a name")
+		errors = append(errors, FieldError{"name", FieldRequired, "must provide a name"})
 	}
 
 	if j.CronExpString == "" {
 		hasErrors = true
-		errors = append(errors, "cron expression string not found")
+		errors = append(errors, FieldError{"cronexp", FieldRequired, "cron expression string not found"})
 
 	} else if cronParser.IsValidExpression(j.CronExpString) {
 		hasErrors = true
-		errors = append(errors, "invalid cron expression provided")
+		errors = append(errors, FieldError{"cronexp", FieldInvalid, "invalid cron expression provided"})
+	}
+
+	if !cronParser.IsValidTimezone(j.Timezone) {
+		hasErrors = true
+		errors = append(errors, FieldError{"timezone", FieldInvalid, "invalid timezone provided"})
+	} else if j.CronExpString != "" && !cronParser.IsValidExpression(j.CronExpString) &&
+		!hasFutureExecutions(j.CronExpString, j.Timezone) {
+		hasErrors = true
+		errors = append(errors, FieldError{"cronexp", FieldOutOfRange, "schedule has no future executions"})
 	}
 
 	if j.Endpoint == "" {
 		hasErrors = true
-		errors = append(errors, "endpoint not found")
+		errors = append(errors, FieldError{"endpoint", FieldRequired, "endpoint not found"})
 
 	} else if !validUrl(j.Endpoint) {
 		hasErrors = true
-		errors = append(errors, "invalid url provided")
+		errors = append(errors, FieldError{"endpoint", FieldInvalid, "invalid url provided"})
 	}
 
 	if j.HttpMethod == "" {
 		hasErrors = true
-		errors = append(errors, "missing http method")
+		errors = append(errors, FieldError{"httpmethod", FieldRequired, "missing http method"})
 	} else if !validHttpMethod(j.HttpMethod) {
 		hasErrors = true
-		errors = append(errors, "invalid http method")
+		errors = append(errors, FieldError{"httpmethod", FieldInvalid, "invalid http method"})
 	}
 
 	if len(j.SuccessStatuses) == 0 {
 		hasErrors = true
-		errors = append(errors, "success statuses not provided")
+		errors = append(errors, FieldError{"successStatuses", FieldRequired, "success statuses not provided"})
 	}
 
 	if j.AlertStrategy != "" && badAlertStrategy(j.AlertStrategy, config.AlertChannels()) {
 		hasErrors = true
-		errors = append(errors, "invalid strategy provided")
+		errors = append(errors, FieldError{"alertStrategy", FieldInvalid, "invalid strategy provided"})
 	}
 	if j.AlertEndpoint != "" && !validUrl(j.AlertEndpoint) {
 		hasErrors = true
-		errors = append(errors, "invalid alert endpoint provided")
+		errors = append(errors, FieldError{"alertEndpoint", FieldInvalid, "invalid alert endpoint provided"})
 	}
 	if j.AlertMethod != "" && !validHttpMethod(j.AlertMethod) {
 		hasErrors = true
-		errors = append(errors, "invalid alert http method provided")
+		errors = append(errors, FieldError{"alertMethod", FieldInvalid, "invalid alert http method provided"})
+	}
+	if j.ResultsRetentionDays != nil && *j.ResultsRetentionDays < 0 {
+		hasErrors = true
+		errors = append(errors, FieldError{"resultsRetentionDays", FieldOutOfRange, "results retention days can't be negative"})
+	}
+	if err := labels.Validate(j.Labels); err != nil {
+		hasErrors = true
+		errors = append(errors, FieldError{"labels", FieldInvalid, err.Error()})
+	}
+	if locationErrors := validateLocations(j.Locations, j.AlertQuorum); len(locationErrors) > 0 {
+		hasErrors = true
+		errors = append(errors, locationErrors...)
+	}
+
+	return errors, hasErrors
+}
+
+// Alerts need a strategy, an endpoint and a method, returns an error with "message" for each one that is missing
+func missingAlertFields(strategy string, endpoint string, method string, message string) []FieldError {
+	if storage.HasMinAlertFields(strategy, endpoint, method) {
+		return nil
+	}
+	errors := []FieldError{}
+	fields := []string{"alertStrategy", "alertEndpoint", "alertMethod"}
+	for i, value := range []string{strategy, endpoint, method} {
+		if value == "" {
+			errors = append(errors, FieldError{fields[i], FieldRequired, message})
+		}
+	}
+	return errors
+}
+
+// Alerts changed in bulk must be complete, there is no previous value to fall back to
+func validateAlertFields(a storage.JobAlertsInput) ([]FieldError, bool) {
+	hasErrors := false
+	errors := []FieldError{}
+
+	if missing := missingAlertFields(a.AlertStrategy, a.AlertEndpoint, a.AlertMethod, "must provide strategy, endpoint and method"); len(missing) > 0 {
+		hasErrors = true
+		errors = append(errors, missing...)
+	}
+	if a.AlertStrategy != "" && badAlertStrategy(a.AlertStrategy, config.AlertChannels()) {
+		hasErrors = true
+		errors = append(errors, FieldError{"alertStrategy", FieldInvalid, "invalid strategy provided"})
+	}
+	if a.AlertEndpoint != "" && !validUrl(a.AlertEndpoint) {
+		hasErrors = true
+		errors = append(errors, FieldError{"alertEndpoint", FieldInvalid, "invalid alert endpoint provided"})
+	}
+	if a.AlertMethod != "" && !validHttpMethod(a.AlertMethod) {
+		hasErrors = true
+		errors = append(errors, FieldError{"alertMethod", FieldInvalid, "invalid alert http method provided"})
+	}
+	return errors, hasErrors
+}
+
+func validateMaintenanceFields(w storage.CreateMaintenanceWindowInput) ([]FieldError, bool) {
+	hasErrors := false
+	errors := []FieldError{}
+
+	if w.Name == "" {
+		hasErrors = true
+		errors = append(errors, FieldError{"name", FieldRequired, "must provide a name"})
+	}
+
+	if !maintenance.IsValidMode(w.Mode) {
+		hasErrors = true
+		errors = append(errors, FieldError{"mode", FieldInvalid, fmt.Sprintf("mode must be %q or %q", maintenance.Skip, maintenance.Mute)})
+	}
+
+	if !cronParser.IsValidTimezone(w.Timezone) {
+		hasErrors = true
+		errors = append(errors, FieldError{"timezone", FieldInvalid, "invalid timezone provided"})
+	}
+
+	recurring := w.CronExpString != ""
+	absolute := !w.StartsAt.IsZero() || !w.EndsAt.IsZero()
+
+	if recurring && absolute {
+		hasErrors = true
+		errors = append(errors, FieldError{"cronexp", FieldConflicting, "must provide either a cron expression and a duration or a start and an end, not both"})
+	} else if recurring {
+		if cronParser.IsValidExpression(w.CronExpString) {
+			hasErrors = true
+			errors = append(errors, FieldError{"cronexp", FieldInvalid, "invalid cron expression provided"})
+		}
+		if w.DurationSeconds <= 0 {
+			hasErrors = true
+			errors = append(errors, FieldError{"durationSeconds", FieldOutOfRange, "duration must be a positive number of seconds"})
+		}
+	} else if absolute {
+		if w.StartsAt.IsZero() || w.EndsAt.IsZero() {
+			hasErrors = true
+			errors = append(errors, FieldError{"startsAt", FieldRequired, "must provide both start and end"})
+		} else if !w.EndsAt.After(w.StartsAt) {
+			hasErrors = true
+			errors = append(errors, FieldError{"endsAt", FieldOutOfRange, "end must be after start"})
+		}
+	} else {
+		hasErrors = true
+		errors = append(errors, FieldError{"cronexp", FieldRequired, "must provide either a cron expression and a duration or a start and an end"})
+	}
+
+	for _, id := range w.JobIds {
+		if id.String() == zeroValueUUID {
+			hasErrors = true
+			errors = append(errors, FieldError{"jobIds", FieldInvalid, "invalid job id provided"})
+			break
+		}
+	}
+
+	if _, err := labels.Parse(w.Selector); err != nil {
+		hasErrors = true
+		errors = append(errors, FieldError{"selector", FieldInvalid, err.Error()})
 	}
 
 	return errors, hasErrors
@@ -2,6 +2,7 @@ package v1
 
 import (
 	"testing"
+	"time"
 
 	"github.com/back-end-labs/ruok/pkg/storage"
 	"github.com/gofrs/uuid"
@@ -51,6 +52,7 @@ func TestValidUrl(t *testing.T) {
 
 func TestValidateUpdateFields(t *testing.T) {
 	id1, _ := uuid.NewV7()
+	negativeDays := -1
 
 	tests := []struct {
 		name          string
@@ -224,6 +226,67 @@ func TestValidateUpdateFields(t *testing.T) {
 			expectedError: true,
 			expectedList:  []string{"invalid alert http method provided"},
 		},
+		{
+			name: "LocalTimezone",
+			input: storage.UpdateJobInput{
+				Name:            "Job 1",
+				Id:              id1,
+				CronExpString:   "0 9 * * *",
+				Timezone:        "Local",
+				MaxRetries:      3,
+				Endpoint:        "http://example.com",
+				HttpMethod:      "GET",
+				SuccessStatuses: []int{200},
+			},
+			expectedError: true,
+			expectedList:  []string{"invalid timezone provided"},
+		},
+		{
+			name: "NegativeResultsRetention",
+			input: storage.UpdateJobInput{
+				Name:                 "Job 1",
+				Id:                   id1,
+				CronExpString:        "*/1 * * * *",
+				MaxRetries:           3,
+				Endpoint:             "http://example.com",
+				HttpMethod:           "GET",
+				SuccessStatuses:      []int{200},
+				ResultsRetentionDays: &negativeDays,
+			},
+			expectedError: true,
+			expectedList:  []string{"results retention days can't be negative"},
+		},
+		{
+			name: "InvalidLabels",
+			input: storage.UpdateJobInput{
+				Name:            "Job 1",
+				Id:              id1,
+				CronExpString:   "*/1 * * * *",
+				MaxRetries:      3,
+				Endpoint:        "http://example.com",
+				HttpMethod:      "GET",
+				SuccessStatuses: []int{200},
+				Labels:          map[string]string{"env": "pro d"},
+			},
+			expectedError: true,
+			expectedList:  []string{`invalid label "env": value must start and end with a letter or a number and only have letters, numbers, '.', '_' or '-'`},
+		},
+		{
+			name: "QuorumWithoutEnoughLocations",
+			input: storage.UpdateJobInput{
+				Name:            "Job 1",
+				Id:              id1,
+				CronExpString:   "*/1 * * * *",
+				MaxRetries:      3,
+				Endpoint:        "http://example.com",
+				HttpMethod:      "GET",
+				SuccessStatuses: []int{200},
+				Locations:       []string{"eu-west", "us-east"},
+				AlertQuorum:     3,
+			},
+			expectedError: true,
+			expectedList:  []string{"alert quorum can't be higher than the number of locations"},
+		},
 	}
 
 	for _, tt := range tests {
@@ -231,7 +294,7 @@ func TestValidateUpdateFields(t *testing.T) {
 			errors, hasErrors := validateUpdateFields(tt.input)
 			assert.Equal(t, tt.expectedError, hasErrors, "expected error status does not match")
 			if tt.expectedError {
-				assert.ElementsMatch(t, tt.expectedList, errors, "expected error list does not match")
+				assert.ElementsMatch(t, tt.expectedList, messages(errors), "expected error list does not match")
 			} else {
 				assert.Empty(t, errors, "expected error list to be nil")
 			}
@@ -240,6 +303,8 @@ func TestValidateUpdateFields(t *testing.T) {
 }
 
 func TestValidateCreateFields(t *testing.T) {
+	negativeDays := -1
+	foreverDays := 0
 	tests := []struct {
 		name          string
 		input         storage.CreateJobInput
@@ -388,6 +453,164 @@ func TestValidateCreateFields(t *testing.T) {
 			expectedError: true,
 			expectedList:  []string{"invalid alert http method provided"},
 		},
+		{
+			name: "ValidTimezone",
+			input: storage.CreateJobInput{
+				Name:            "Job 1",
+				CronExpString:   "0 9 * * *",
+				Timezone:        "Europe/Madrid",
+				MaxRetries:      3,
+				Endpoint:        "http://example.com",
+				HttpMethod:      "GET",
+				SuccessStatuses: []int{200},
+			},
+			expectedError: false,
+			expectedList:  nil,
+		},
+		{
+			name: "ValidInterval",
+			input: storage.CreateJobInput{
+				Name:            "Job 1",
+				CronExpString:   "every 30s",
+				MaxRetries:      3,
+				Endpoint:        "http://example.com",
+				HttpMethod:      "GET",
+				SuccessStatuses: []int{200},
+			},
+			expectedError: false,
+			expectedList:  nil,
+		},
+		{
+			name: "OneShotInThePast",
+			input: storage.CreateJobInput{
+				Name:            "Job 1",
+				CronExpString:   "at 2020-01-01T00:00:00Z",
+				MaxRetries:      3,
+				Endpoint:        "http://example.com",
+				HttpMethod:      "GET",
+				SuccessStatuses: []int{200},
+			},
+			expectedError: true,
+			expectedList:  []string{"schedule has no future executions"},
+		},
+		{
+			name: "InvalidTimezone",
+			input: storage.CreateJobInput{
+				Name:            "Job 1",
+				CronExpString:   "0 9 * * *",
+				Timezone:        "Europe/Atlantis",
+				MaxRetries:      3,
+				Endpoint:        "http://example.com",
+				HttpMethod:      "GET",
+				SuccessStatuses: []int{200},
+			},
+			expectedError: true,
+			expectedList:  []string{"invalid timezone provided"},
+		},
+		{
+			name: "LocalTimezone",
+			input: storage.CreateJobInput{
+				Name:            "Job 1",
+				CronExpString:   "0 9 * * *",
+				Timezone:        "Local",
+				MaxRetries:      3,
+				Endpoint:        "http://example.com",
+				HttpMethod:      "GET",
+				SuccessStatuses: []int{200},
+			},
+			expectedError: true,
+			expectedList:  []string{"invalid timezone provided"},
+		},
+		{
+			name: "NegativeResultsRetention",
+			input: storage.CreateJobInput{
+				Name:                 "Job 1",
+				CronExpString:        "*/1 * * * *",
+				MaxRetries:           3,
+				Endpoint:             "http://example.com",
+				HttpMethod:           "GET",
+				SuccessStatuses:      []int{200},
+				ResultsRetentionDays: &negativeDays,
+			},
+			expectedError: true,
+			expectedList:  []string{"results retention days can't be negative"},
+		},
+		{
+			name: "KeepResultsForever",
+			input: storage.CreateJobInput{
+				Name:                 "Job 1",
+				CronExpString:        "*/1 * * * *",
+				MaxRetries:           3,
+				Endpoint:             "http://example.com",
+				HttpMethod:           "GET",
+				SuccessStatuses:      []int{200},
+				ResultsRetentionDays: &foreverDays,
+			},
+			expectedError: false,
+			expectedList:  nil,
+		},
+		{
+			name: "WithLabels",
+			input: storage.CreateJobInput{
+				Name:            "Job 1",
+				CronExpString:   "*/1 * * * *",
+				MaxRetries:      3,
+				Endpoint:        "http://example.com",
+				HttpMethod:      "GET",
+				SuccessStatuses: []int{200},
+				Labels:          map[string]string{"env": "prod", "example.com/team": "payments"},
+			},
+			expectedError: false,
+			expectedList:  nil,
+		},
+		{
+			name: "EmptyLabelKey",
+			input: storage.CreateJobInput{
+				Name:            "Job 1",
+				CronExpString:   "*/1 * * * *",
+				MaxRetries:      3,
+				Endpoint:        "http://example.com",
+				HttpMethod:      "GET",
+				SuccessStatuses: []int{200},
+				Labels:          map[string]string{"": "prod"},
+			},
+			expectedError: true,
+			expectedList:  []string{`invalid label "": key can't be empty`},
+		},
+		{
+			name: "WithLocations",
+			input: storage.CreateJobInput{
+				Name:            "Job 1",
+				CronExpString:   "*/1 * * * *",
+				MaxRetries:      3,
+				Endpoint:        "http://example.com",
+				HttpMethod:      "GET",
+				SuccessStatuses: []int{200},
+				Locations:       []string{"eu-west", "us-east", "ap_south"},
+				AlertQuorum:     2,
+			},
+			expectedError: false,
+			expectedList:  nil,
+		},
+		{
+			name: "InvalidLocations",
+			input: storage.CreateJobInput{
+				Name:            "Job 1",
+				CronExpString:   "*/1 * * * *",
+				MaxRetries:      3,
+				Endpoint:        "http://example.com",
+				HttpMethod:      "GET",
+				SuccessStatuses: []int{200},
+				Locations:       []string{"eu west", "us-east", "us-east"},
+				AlertQuorum:     -1,
+			},
+			expectedError: true,
+			expectedList: []string{
+				`invalid location "eu west", only letters, numbers, '-' and '_' are allowed`,
+				`location "us-east" is repeated`,
+				"alert quorum can't be negative",
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -395,7 +618,7 @@ func TestValidateCreateFields(t *testing.T) {
 			errors, hasErrors := validateCreateFields(tt.input)
 			assert.Equal(t, tt.expectedError, hasErrors, "expected error status does not match")
 			if tt.expectedError {
-				assert.ElementsMatch(t, tt.expectedList, errors, "expected error list does not match")
+				assert.ElementsMatch(t, tt.expectedList, messages(errors), "expected error list does not match")
 			} else {
 				assert.Empty(t, errors, "expected error list to be nil")
 			}
@@ -403,6 +626,46 @@ func TestValidateCreateFields(t *testing.T) {
 	}
 }
 
+func TestValidateAlertFields(t *testing.T) {
+	errors, hasErrors := validateAlertFields(storage.JobAlertsInput{AlertStrategy: "http", AlertMethod: "POST", AlertEndpoint: "http://example.com/alerts"})
+	assert.False(t, hasErrors)
+	assert.Empty(t, errors)
+
+	errors, hasErrors = validateAlertFields(storage.JobAlertsInput{AlertStrategy: "http", AlertMethod: "PATCH"})
+	assert.True(t, hasErrors)
+	assert.ElementsMatch(t, []string{"must provide strategy, endpoint and method", "invalid alert http method provided"}, messages(errors))
+	assert.ElementsMatch(t, []FieldError{
+		{"alertEndpoint", FieldRequired, "must provide strategy, endpoint and method"},
+		{"alertMethod", FieldInvalid, "invalid alert http method provided"},
+	}, errors)
+}
+
+// Every field error must say which field of the body is wrong
+func TestValidateCreateFields_Fields(t *testing.T) {
+	errors, _ := validateCreateFields(storage.CreateJobInput{
+		Name:            "Job 1",
+		CronExpString:   "not a cron",
+		Endpoint:        "http://example.com",
+		HttpMethod:      "GET",
+		SuccessStatuses: []int{200},
+	})
+	assert.Equal(t, []FieldError{{"cronexp", FieldInvalid, "invalid cron expression provided"}}, errors)
+
+	errors, _ = validateCreateFields(storage.CreateJobInput{})
+	for _, e := range errors {
+		assert.NotEmpty(t, e.Field, e.Message)
+		assert.NotEmpty(t, e.Code, e.Message)
+	}
+}
+
+func messages(errors []FieldError) []string {
+	list := []string{}
+	for _, e := range errors {
+		list = append(list, e.Message)
+	}
+	return list
+}
+
 func TestValidHttpMethod(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -443,3 +706,122 @@ func TestValidHttpMethod(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateMaintenanceFields(t *testing.T) {
+	start := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name          string
+		input         storage.CreateMaintenanceWindowInput
+		expectedError bool
+		expectedList  []string
+	}{
+		{
+			name: "ValidRecurring",
+			input: storage.CreateMaintenanceWindowInput{
+				Name:            "nightly deploys",
+				Mode:            "skip",
+				CronExpString:   "0 2 * * *",
+				Timezone:        "Europe/Madrid",
+				DurationSeconds: 1800,
+			},
+			expectedError: false,
+			expectedList:  []string{},
+		},
+		{
+			name: "ValidAbsolute",
+			input: storage.CreateMaintenanceWindowInput{
+				Name:     "db migration",
+				Mode:     "mute",
+				StartsAt: start,
+				EndsAt:   start.Add(time.Hour),
+			},
+			expectedError: false,
+			expectedList:  []string{},
+		},
+		{
+			name: "ValidSelector",
+			input: storage.CreateMaintenanceWindowInput{
+				Name:     "eu upgrade",
+				Mode:     "skip",
+				StartsAt: start,
+				EndsAt:   start.Add(time.Hour),
+				Selector: "zone=eu,!legacy",
+			},
+			expectedError: false,
+			expectedList:  []string{},
+		},
+		{
+			name: "BadSelector",
+			input: storage.CreateMaintenanceWindowInput{
+				Name:     "bad",
+				Mode:     "skip",
+				StartsAt: start,
+				EndsAt:   start.Add(time.Hour),
+				Selector: "zone=eu,=payments",
+			},
+			expectedError: true,
+			expectedList:  []string{`invalid requirement "=payments": key can't be empty`},
+		},
+		{
+			name:          "Empty",
+			input:         storage.CreateMaintenanceWindowInput{},
+			expectedError: true,
+			expectedList: []string{
+				"must provide a name",
+				`mode must be "skip" or "mute"`,
+				"must provide either a cron expression and a duration or a start and an end",
+			},
+		},
+		{
+			name: "BadRecurring",
+			input: storage.CreateMaintenanceWindowInput{
+				Name:          "bad",
+				Mode:          "skip",
+				CronExpString: "not a cron",
+				Timezone:      "Mars/Olympus_Mons",
+			},
+			expectedError: true,
+			expectedList: []string{
+				"invalid timezone provided",
+				"invalid cron expression provided",
+				"duration must be a positive number of seconds",
+			},
+		},
+		{
+			name: "EndBeforeStart",
+			input: storage.CreateMaintenanceWindowInput{
+				Name:     "bad",
+				Mode:     "skip",
+				StartsAt: start,
+				EndsAt:   start.Add(-time.Hour),
+			},
+			expectedError: true,
+			expectedList:  []string{"end must be after start"},
+		},
+		{
+			name: "Both",
+			input: storage.CreateMaintenanceWindowInput{
+				Name:            "bad",
+				Mode:            "mute",
+				CronExpString:   "0 2 * * *",
+				DurationSeconds: 60,
+				StartsAt:        start,
+			},
+			expectedError: true,
+			expectedList:  []string{"must provide either a cron expression and a duration or a start and an end, not both"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs, hasErrors := validateMaintenanceFields(tt.input)
+			assert.Equal(t, tt.expectedError, hasErrors)
+			if tt.expectedList == nil {
+				assert.Empty(t, errs)
+				return
+			}
+			assert.Equal(t, tt.expectedList, messages(errs))
+		})
+	}
+}
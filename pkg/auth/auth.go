@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/gofrs/uuid"
+)
+
+// What a caller can do, every scope allows what the previous ones do
+type Scope string
+
+const (
+	// List jobs, executions, windows and stats
+	ReadScope Scope = "read"
+	// Create, update, pause, resume, delete and run jobs, and manage maintenance windows
+	WriteScope Scope = "write"
+	// Manage API keys
+	AdminScope Scope = "admin"
+)
+
+var scopeRanks = map[Scope]int{ReadScope: 1, WriteScope: 2, AdminScope: 3}
+
+// Scopes in the order they grant permissions
+var Scopes = []Scope{ReadScope, WriteScope, AdminScope}
+
+func ParseScope(s string) (Scope, error) {
+	scope := Scope(strings.ToLower(strings.TrimSpace(s)))
+	if _, ok := scopeRanks[scope]; !ok {
+		return "", fmt.Errorf("unknown scope %q, must be one of %v", s, Scopes)
+	}
+	return scope, nil
+}
+
+// Returns TRUE if callers with this scope can do what "required" needs
+func (s Scope) Allows(required Scope) bool {
+	return scopeRanks[s] > 0 && scopeRanks[s] >= scopeRanks[required]
+}
+
+// Who is making a request
+type Identity struct {
+	// Recorded as the author of the changes, like "key:deploys"
+	Name  string `json:"name"`
+	Scope Scope  `json:"scope"`
+	// Only jobs of this tenant can be seen and changed, empty sees every tenant
+	Tenant string `json:"tenant"`
+}
+
+// Tenant of the jobs created without one, and of the callers that don't say theirs
+var DefaultTenant = "default"
+
+// Callers of an API without authentication can do anything, on every tenant
+var Anonymous = Identity{Name: "anonymous", Scope: AdminScope}
+
+// Identity of the callers using the key
+func KeyIdentity(k *APIKey) Identity {
+	return Identity{Name: "key:" + k.Name, Scope: k.Scope, Tenant: k.Tenant}
+}
+
+// An API key as it is stored, the key itself is only shown when it is created
+type APIKey struct {
+	Id   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+	// The start of the key, enough to tell keys apart
+	Prefix    string `json:"prefix"`
+	Scope     Scope  `json:"scope"`
+	Tenant    string `json:"tenant"`
+	CreatedAt int64  `json:"createdAt"`
+	// Revoked keys are kept so changes made with them can still be traced
+	RevokedAt int64 `json:"revokedAt,omitempty"`
+}
+
+var keyNameRegexp = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,63}$`)
+
+// Key names end up in the identity of their callers, so they are kept short and simple.
+// Only letters, numbers, '.', '-' and '_' are allowed, up to 63 characters
+func IsValidKeyName(name string) bool {
+	return keyNameRegexp.MatchString(name)
+}
+
+// Tenants follow the same rules as key names
+func IsValidTenant(tenant string) bool {
+	return keyNameRegexp.MatchString(tenant)
+}
+
+// Every key starts with it, so leaked keys are easy to search for
+var KeyPrefix = "ruok_"
+
+// Length of the prefix kept to identify a key, KeyPrefix included
+var prefixLength = len(KeyPrefix) + 8
+
+// Bytes of randomness in a key
+var keyBytes = 32
+
+// Creates a new key. Only its hash should be stored.
+func GenerateKey() (key string, prefix string, hash string, err error) {
+	b := make([]byte, keyBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", "", err
+	}
+	key = KeyPrefix + hex.EncodeToString(b)
+	return key, key[:prefixLength], HashKey(key), nil
+}
+
+// Keys are random enough that a fast hash can't be brute forced, so a salt is not needed
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Returns TRUE if the token looks like one of our keys
+func IsKey(token string) bool {
+	return strings.HasPrefix(token, KeyPrefix) && len(token) == len(KeyPrefix)+2*keyBytes
+}
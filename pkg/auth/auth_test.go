@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseScope(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Scope
+		err      bool
+	}{
+		{"read", ReadScope, false},
+		{"Write", WriteScope, false},
+		{" admin ", AdminScope, false},
+		{"", "", true},
+		{"root", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			scope, err := ParseScope(tt.input)
+			assert.Equal(t, tt.err, err != nil)
+			assert.Equal(t, tt.expected, scope)
+		})
+	}
+}
+
+func TestScopeAllows(t *testing.T) {
+	assert.True(t, ReadScope.Allows(ReadScope))
+	assert.False(t, ReadScope.Allows(WriteScope))
+	assert.True(t, WriteScope.Allows(ReadScope))
+	assert.False(t, WriteScope.Allows(AdminScope))
+	assert.True(t, AdminScope.Allows(WriteScope))
+	assert.False(t, Scope("").Allows(ReadScope), "unknown scopes allow nothing")
+	assert.False(t, Scope("root").Allows(ReadScope))
+}
+
+func TestGenerateKey(t *testing.T) {
+	key, prefix, hash, err := GenerateKey()
+	assert.NoError(t, err)
+	assert.True(t, IsKey(key))
+	assert.Equal(t, key[:len(prefix)], prefix)
+	assert.Len(t, prefix, len(KeyPrefix)+8)
+	assert.Equal(t, HashKey(key), hash)
+	assert.NotContains(t, hash, key)
+
+	other, _, otherHash, _ := GenerateKey()
+	assert.NotEqual(t, key, other)
+	assert.NotEqual(t, hash, otherHash)
+}
+
+func TestIsKey(t *testing.T) {
+	key, _, _, _ := GenerateKey()
+	assert.True(t, IsKey(key))
+	assert.False(t, IsKey(key[1:]))
+	assert.False(t, IsKey(key+"0"))
+	assert.False(t, IsKey("eyJhbGciOiJSUzI1NiJ9.e30.sig"))
+}
+
+func TestIsValidKeyName(t *testing.T) {
+	assert.True(t, IsValidKeyName("deploys"))
+	assert.True(t, IsValidKeyName("ci.payments-eu_1"))
+	assert.False(t, IsValidKeyName(""))
+	assert.False(t, IsValidKeyName("with space"))
+	assert.False(t, IsValidKeyName("key:deploys"))
+	assert.False(t, IsValidKeyName(strings.Repeat("k", 64)))
+}
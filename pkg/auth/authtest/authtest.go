@@ -0,0 +1,133 @@
+package authtest
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/back-end-labs/ruok/pkg/auth"
+)
+
+// A local identity provider, so tests can sign tokens without a live one
+type Provider struct {
+	t      *testing.T
+	rsaKey *rsa.PrivateKey
+	ecKey  *ecdsa.PrivateKey
+}
+
+var RSAKeyId = "rsa-key"
+var ECKeyId = "ec-key"
+
+func NewProvider(t *testing.T) *Provider {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("could not generate rsa key: %q", err.Error())
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("could not generate ec key: %q", err.Error())
+	}
+	return &Provider{t: t, rsaKey: rsaKey, ecKey: ecKey}
+}
+
+// The public keys as an identity provider serves them
+func (p *Provider) JWKS() []byte {
+	jwks := auth.JWKS{Keys: []auth.JWK{
+		{
+			Kty: "RSA",
+			Kid: RSAKeyId,
+			Use: "sig",
+			Alg: "RS256",
+			N:   encode(p.rsaKey.N.Bytes()),
+			E:   encode(big.NewInt(int64(p.rsaKey.E)).Bytes()),
+		},
+		{
+			Kty: "EC",
+			Kid: ECKeyId,
+			Use: "sig",
+			Alg: "ES256",
+			Crv: "P-256",
+			X:   encode(p.ecKey.X.FillBytes(make([]byte, 32))),
+			Y:   encode(p.ecKey.Y.FillBytes(make([]byte, 32))),
+		},
+	}}
+	b, _ := json.Marshal(jwks)
+	return b
+}
+
+func (p *Provider) KeySet() auth.KeySet {
+	return p.parse(p.JWKS())
+}
+
+// The public keys without "alg", so they verify any algorithm of their type
+func (p *Provider) KeySetWithoutAlg() auth.KeySet {
+	jwks := auth.JWKS{}
+	json.Unmarshal(p.JWKS(), &jwks)
+	for i := range jwks.Keys {
+		jwks.Keys[i].Alg = ""
+	}
+	b, _ := json.Marshal(jwks)
+	return p.parse(b)
+}
+
+func (p *Provider) parse(jwks []byte) auth.KeySet {
+	keys, err := auth.ParseJWKS(jwks)
+	if err != nil {
+		p.t.Fatalf("could not parse key set: %q", err.Error())
+	}
+	return keys
+}
+
+// Signs the claims with RS256
+func (p *Provider) Sign(claims map[string]interface{}) string {
+	return p.SignWith("RS256", RSAKeyId, claims)
+}
+
+// Hashes of the algorithms tokens can be signed with
+var hashes = map[string]crypto.Hash{"RS256": crypto.SHA256, "RS384": crypto.SHA384, "ES256": crypto.SHA256, "ES384": crypto.SHA384}
+
+// Signs the claims with RS256, RS384, ES256 or ES384, using "kid" in the header.
+// Both ES algorithms sign with the same P-256 key, so ES384 tokens only pass when curves are not checked.
+func (p *Provider) SignWith(alg string, kid string, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := encode(header) + "." + encode(payload)
+	hash, ok := hashes[alg]
+	if !ok {
+		p.t.Fatalf("can't sign with %q", alg)
+	}
+	h := hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+
+	var signature []byte
+	var err error
+	switch alg {
+	case "RS256", "RS384":
+		signature, err = rsa.SignPKCS1v15(rand.Reader, p.rsaKey, hash, digest)
+	case "ES256", "ES384":
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, p.ecKey, digest)
+		if err == nil {
+			signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+		}
+	default:
+		p.t.Fatalf("can't sign with %q", alg)
+	}
+	if err != nil {
+		p.t.Fatalf("could not sign token: %q", err.Error())
+	}
+	return signed + "." + encode(signature)
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
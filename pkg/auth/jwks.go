@@ -0,0 +1,236 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A key of a JSON Web Key Set, only the fields needed to verify signatures
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	// RSA keys
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// EC keys
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// Returns the public key to verify signatures with
+func (k JWK) PublicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modulus of key %q: %w", k.Kid, err)
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil || !e.IsInt64() {
+			return nil, fmt.Errorf("invalid exponent of key %q", k.Kid)
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		curve, ok := curves[k.Crv]
+		if !ok {
+			return nil, fmt.Errorf("unsupported curve %q of key %q", k.Crv, k.Kid)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid x of key %q: %w", k.Kid, err)
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid y of key %q: %w", k.Kid, err)
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("key %q is not on curve %s", k.Kid, k.Crv)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q of key %q", k.Kty, k.Kid)
+}
+
+var curves = map[string]elliptic.Curve{
+	"P-256": elliptic.P256(),
+	"P-384": elliptic.P384(),
+	"P-521": elliptic.P521(),
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errors.New("empty value")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// Public keys by their id
+type KeySet map[string]crypto.PublicKey
+
+// A key that only verifies the tokens signed with Alg, like the JWKs that have "alg"
+type AlgorithmKey struct {
+	crypto.PublicKey
+	Alg string
+}
+
+// Parses a JSON Web Key Set. Keys that aren't for signatures or we can't use are skipped,
+// and the ones with "alg" are kept as AlgorithmKey.
+func ParseJWKS(b []byte) (KeySet, error) {
+	jwks := JWKS{}
+	if err := json.Unmarshal(b, &jwks); err != nil {
+		return nil, fmt.Errorf("invalid key set: %w", err)
+	}
+	keys := KeySet{}
+	for _, k := range jwks.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.PublicKey()
+		if err != nil {
+			continue
+		}
+		if k.Alg != "" {
+			key = AlgorithmKey{PublicKey: key, Alg: k.Alg}
+		}
+		keys[k.Kid] = key
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("the key set has no signing keys we can use")
+	}
+	return keys, nil
+}
+
+// Finds the key that signed a token
+type KeySource interface {
+	Key(kid string) (crypto.PublicKey, error)
+}
+
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// Tokens without "kid" can only be verified when there is a single key
+func (ks KeySet) Key(kid string) (crypto.PublicKey, error) {
+	if key, ok := ks[kid]; ok {
+		return key, nil
+	}
+	if kid == "" && len(ks) == 1 {
+		for _, key := range ks {
+			return key, nil
+		}
+	}
+	return nil, ErrUnknownKey
+}
+
+// Keys served by an identity provider. They are fetched when first needed,
+// and again when a token is signed with an unknown key, at most once every MinRefresh, so rotated keys are picked up.
+// Tokens signed with known keys are checked while the keys are fetched again.
+type RemoteKeySet struct {
+	URL        string
+	Client     *http.Client
+	MinRefresh time.Duration
+
+	mu   sync.Mutex
+	keys KeySet
+	// when the keys were last fetched, or a refresh started
+	fetchedAt time.Time
+}
+
+func NewRemoteKeySet(url string) *RemoteKeySet {
+	return &RemoteKeySet{
+		URL:        url,
+		Client:     &http.Client{Timeout: 10 * time.Second},
+		MinRefresh: time.Minute,
+	}
+}
+
+func (r *RemoteKeySet) Key(kid string) (crypto.PublicKey, error) {
+	r.mu.Lock()
+	known := r.keys
+	if known != nil {
+		key, err := known.Key(kid)
+		if err == nil || time.Since(r.fetchedAt) < r.MinRefresh {
+			r.mu.Unlock()
+			return key, err
+		}
+		// the next unknown keys wait for MinRefresh, even if this refresh fails
+		r.fetchedAt = time.Now()
+	}
+	r.mu.Unlock()
+
+	keys, err := r.fetch()
+	if err != nil && known != nil {
+		// the keys we have still work, the token just wasn't signed with one of them
+		return nil, fmt.Errorf("%w: %v", ErrUnknownKey, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	r.keys = keys
+	r.fetchedAt = time.Now()
+	r.mu.Unlock()
+	return keys.Key(kid)
+}
+
+func (r *RemoteKeySet) fetch() (KeySet, error) {
+	b, err := getJSON(r.Client, r.URL)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch key set: %w", err)
+	}
+	return ParseJWKS(b)
+}
+
+// Looks up the key set url in the discovery document of the issuer
+func DiscoverJWKSURL(client *http.Client, issuer string) (string, error) {
+	b, err := getJSON(client, strings.TrimSuffix(issuer, "/")+"/.well-known/openid-configuration")
+	if err != nil {
+		return "", fmt.Errorf("could not fetch discovery document: %w", err)
+	}
+	discovery := struct {
+		JWKSURI string `json:"jwks_uri"`
+	}{}
+	if err := json.Unmarshal(b, &discovery); err != nil {
+		return "", fmt.Errorf("invalid discovery document: %w", err)
+	}
+	if discovery.JWKSURI == "" {
+		return "", errors.New("the discovery document has no jwks_uri")
+	}
+	return discovery.JWKSURI, nil
+}
+
+// Largest document we read from an identity provider
+var maxDocumentSize int64 = 1 << 20
+
+func getJSON(client *http.Client, url string) ([]byte, error) {
+	res, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %d", url, res.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(res.Body, maxDocumentSize))
+}
@@ -0,0 +1,246 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// Why a token was rejected, the details are in the wrapping error
+var ErrInvalidToken = errors.New("invalid token")
+
+// Checks the bearer tokens of an OIDC identity provider
+type Verifier struct {
+	// Expected "iss"
+	Issuer string
+	// Must be in "aud"
+	Audience string
+	// Tolerance when checking "exp" and "nbf"
+	ClockSkew time.Duration
+	// Claim with the roles of the caller, nested claims are separated by dots like "realm_access.roles"
+	RolesClaim string
+	// Scope granted by each role, callers get the highest of their roles
+	Roles map[string]Scope
+	// Claim with the tenant of the caller, nested like RolesClaim.
+	// Callers without it, or when it's empty, belong to DefaultTenant
+	TenantClaim string
+	Keys        KeySource
+	// Defaults to time.Now
+	Now func() time.Time
+}
+
+// Signing algorithms we accept, "none" and the HMAC ones are never accepted
+var algorithms = map[string]crypto.Hash{
+	"RS256": crypto.SHA256,
+	"RS384": crypto.SHA384,
+	"RS512": crypto.SHA512,
+	"ES256": crypto.SHA256,
+	"ES384": crypto.SHA384,
+	"ES512": crypto.SHA512,
+}
+
+// Curve of the keys each ES algorithm is verified with, see RFC 7518 section 3.4
+var algorithmCurves = map[string]string{
+	"ES256": "P-256",
+	"ES384": "P-384",
+	"ES512": "P-521",
+}
+
+type tokenHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// Returns TRUE if the token looks like a JWT
+func IsJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+// Checks the signature, issuer, audience and lifetime of a token, and returns who it belongs to.
+// Callers without any known role get an identity without scope.
+func (v *Verifier) Verify(token string) (Identity, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Identity{}, fmt.Errorf("%w: it must have 3 parts", ErrInvalidToken)
+	}
+
+	header := tokenHeader{}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return Identity{}, fmt.Errorf("%w: bad header: %v", ErrInvalidToken, err)
+	}
+	hash, ok := algorithms[header.Alg]
+	if !ok {
+		return Identity{}, fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidToken, header.Alg)
+	}
+
+	key, err := v.Keys.Key(header.Kid)
+	if errors.Is(err, ErrUnknownKey) {
+		return Identity{}, fmt.Errorf("%w: unknown key %q", ErrInvalidToken, header.Kid)
+	}
+	if err != nil {
+		return Identity{}, err
+	}
+	if restricted, ok := key.(AlgorithmKey); ok {
+		if restricted.Alg != header.Alg {
+			return Identity{}, fmt.Errorf("%w: key %q is for %s, not %s", ErrInvalidToken, header.Kid, restricted.Alg, header.Alg)
+		}
+		key = restricted.PublicKey
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Identity{}, fmt.Errorf("%w: bad signature encoding", ErrInvalidToken)
+	}
+	if err := verifySignature(header.Alg, hash, key, parts[0]+"."+parts[1], signature); err != nil {
+		return Identity{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	claims := map[string]interface{}{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return Identity{}, fmt.Errorf("%w: bad claims: %v", ErrInvalidToken, err)
+	}
+	if err := v.checkClaims(claims); err != nil {
+		return Identity{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	subject, _ := claims["sub"].(string)
+	tenant := DefaultTenant
+	if v.TenantClaim != "" {
+		if t, ok := nestedClaim(claims, v.TenantClaim).(string); ok && t != "" {
+			tenant = t
+		}
+	}
+	if !IsValidTenant(tenant) {
+		return Identity{}, fmt.Errorf("%w: bad tenant %q", ErrInvalidToken, tenant)
+	}
+	return Identity{Name: "oidc:" + subject, Scope: v.scope(claims), Tenant: tenant}, nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+func verifySignature(alg string, hash crypto.Hash, key crypto.PublicKey, signed string, signature []byte) error {
+	h := hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if !strings.HasPrefix(alg, "RS") {
+			return fmt.Errorf("%s can't be verified with an RSA key", alg)
+		}
+		if err := rsa.VerifyPKCS1v15(k, hash, digest, signature); err != nil {
+			return errors.New("bad signature")
+		}
+		return nil
+	case *ecdsa.PublicKey:
+		if !strings.HasPrefix(alg, "ES") {
+			return fmt.Errorf("%s can't be verified with an EC key", alg)
+		}
+		if curve := k.Curve.Params().Name; curve != algorithmCurves[alg] {
+			return fmt.Errorf("%s can't be verified with a %s key", alg, curve)
+		}
+		// r and s are concatenated, each as long as the curve
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return errors.New("bad signature")
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(k, digest, r, s) {
+			return errors.New("bad signature")
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported key %T", key)
+}
+
+func (v *Verifier) checkClaims(claims map[string]interface{}) error {
+	if iss, _ := claims["iss"].(string); iss != v.Issuer {
+		return fmt.Errorf("unexpected issuer %q", iss)
+	}
+	if !containsString(claims["aud"], v.Audience) {
+		return fmt.Errorf("the audience is not %q", v.Audience)
+	}
+	if sub, _ := claims["sub"].(string); sub == "" {
+		return errors.New("missing subject")
+	}
+
+	now := time.Now()
+	if v.Now != nil {
+		now = v.Now()
+	}
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return errors.New("missing expiration")
+	}
+	if now.Add(-v.ClockSkew).After(time.Unix(int64(exp), 0)) {
+		return errors.New("expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(v.ClockSkew).Before(time.Unix(int64(nbf), 0)) {
+		return errors.New("not valid yet")
+	}
+	return nil
+}
+
+// The highest scope granted by the roles of the caller
+func (v *Verifier) scope(claims map[string]interface{}) Scope {
+	best := Scope("")
+	for _, role := range stringList(nestedClaim(claims, v.RolesClaim)) {
+		if scope, ok := v.Roles[role]; ok && scopeRanks[scope] > scopeRanks[best] {
+			best = scope
+		}
+	}
+	return best
+}
+
+// Value of a claim whose name can have dots, like "realm_access.roles". Nil if it's missing
+func nestedClaim(claims map[string]interface{}, name string) interface{} {
+	var value interface{} = claims
+	for _, part := range strings.Split(name, ".") {
+		nested, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = nested[part]
+	}
+	return value
+}
+
+// Claims can be a single string, like "aud", a list, or space separated values, like "scope"
+func stringList(v interface{}) []string {
+	switch value := v.(type) {
+	case string:
+		return strings.Fields(value)
+	case []interface{}:
+		values := []string{}
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+func containsString(v interface{}, expected string) bool {
+	for _, s := range stringList(v) {
+		if s == expected {
+			return true
+		}
+	}
+	return false
+}
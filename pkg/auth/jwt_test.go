@@ -0,0 +1,243 @@
+package auth_test
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/back-end-labs/ruok/pkg/auth"
+	"github.com/back-end-labs/ruok/pkg/auth/authtest"
+)
+
+var issuer = "https://id.example.com"
+var audience = "ruok"
+
+func newVerifier(keys auth.KeySource) *auth.Verifier {
+	return &auth.Verifier{
+		Issuer:     issuer,
+		Audience:   audience,
+		ClockSkew:  time.Minute,
+		RolesClaim: "roles",
+		Roles:      map[string]auth.Scope{"viewers": auth.ReadScope, "operators": auth.WriteScope, "owners": auth.AdminScope},
+		Keys:       keys,
+	}
+}
+
+func claims(changes map[string]interface{}) map[string]interface{} {
+	c := map[string]interface{}{
+		"iss":   issuer,
+		"aud":   audience,
+		"sub":   "alice",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"iat":   time.Now().Unix(),
+		"roles": []string{"viewers"},
+	}
+	for k, v := range changes {
+		if v == nil {
+			delete(c, k)
+			continue
+		}
+		c[k] = v
+	}
+	return c
+}
+
+func TestVerify(t *testing.T) {
+	p := authtest.NewProvider(t)
+	v := newVerifier(p.KeySet())
+	now := time.Now()
+
+	tests := []struct {
+		name          string
+		token         string
+		expectedScope auth.Scope
+		err           bool
+	}{
+		{"RS256", p.Sign(claims(nil)), auth.ReadScope, false},
+		{"ES256", p.SignWith("ES256", authtest.ECKeyId, claims(nil)), auth.ReadScope, false},
+		{"AudienceList", p.Sign(claims(map[string]interface{}{"aud": []string{"other", audience}})), auth.ReadScope, false},
+		{"HighestRole", p.Sign(claims(map[string]interface{}{"roles": []string{"viewers", "owners", "operators"}})), auth.AdminScope, false},
+		{"SpaceSeparatedRoles", p.Sign(claims(map[string]interface{}{"roles": "unknown operators"})), auth.WriteScope, false},
+		{"NoKnownRole", p.Sign(claims(map[string]interface{}{"roles": []string{"unknown"}})), "", false},
+		{"NoRoles", p.Sign(claims(map[string]interface{}{"roles": nil})), "", false},
+		{"ExpiredWithinSkew", p.Sign(claims(map[string]interface{}{"exp": now.Add(-30 * time.Second).Unix()})), auth.ReadScope, false},
+		{"NotBeforeWithinSkew", p.Sign(claims(map[string]interface{}{"nbf": now.Add(30 * time.Second).Unix()})), auth.ReadScope, false},
+		{"Expired", p.Sign(claims(map[string]interface{}{"exp": now.Add(-2 * time.Minute).Unix()})), "", true},
+		{"NotValidYet", p.Sign(claims(map[string]interface{}{"nbf": now.Add(2 * time.Minute).Unix()})), "", true},
+		{"NoExpiration", p.Sign(claims(map[string]interface{}{"exp": nil})), "", true},
+		{"WrongIssuer", p.Sign(claims(map[string]interface{}{"iss": "https://evil.example.com"})), "", true},
+		{"WrongAudience", p.Sign(claims(map[string]interface{}{"aud": "other"})), "", true},
+		{"NoSubject", p.Sign(claims(map[string]interface{}{"sub": nil})), "", true},
+		{"UnknownKey", p.SignWith("RS256", "rotated", claims(nil)), "", true},
+		{"AlgorithmOfOtherKey", p.SignWith("RS256", authtest.ECKeyId, claims(nil)), "", true},
+		{"NotAToken", "not-a-token", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity, err := v.Verify(tt.token)
+			assert.Equal(t, tt.err, err != nil, err)
+			if err != nil {
+				assert.ErrorIs(t, err, auth.ErrInvalidToken)
+				return
+			}
+			assert.Equal(t, "oidc:alice", identity.Name)
+			assert.Equal(t, tt.expectedScope, identity.Scope)
+		})
+	}
+}
+
+func TestVerify_RejectsTamperedTokens(t *testing.T) {
+	p := authtest.NewProvider(t)
+	v := newVerifier(p.KeySet())
+	parts := strings.Split(p.Sign(claims(nil)), ".")
+
+	admin := base64.RawURLEncoding.EncodeToString([]byte(`{"iss":"` + issuer + `","aud":"ruok","sub":"alice","exp":9999999999,"roles":["owners"]}`))
+	_, err := v.Verify(parts[0] + "." + admin + "." + parts[2])
+	assert.ErrorIs(t, err, auth.ErrInvalidToken)
+
+	none := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`))
+	_, err = v.Verify(none + "." + parts[1] + ".")
+	assert.ErrorIs(t, err, auth.ErrInvalidToken)
+
+	hmac := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","kid":"` + authtest.RSAKeyId + `"}`))
+	_, err = v.Verify(hmac + "." + parts[1] + "." + parts[2])
+	assert.ErrorIs(t, err, auth.ErrInvalidToken)
+}
+
+func TestVerify_KeyAlgorithm(t *testing.T) {
+	p := authtest.NewProvider(t)
+
+	_, err := newVerifier(p.KeySet()).Verify(p.SignWith("RS384", authtest.RSAKeyId, claims(nil)))
+	assert.ErrorIs(t, err, auth.ErrInvalidToken, "the key set says the key is for RS256")
+	_, err = newVerifier(p.KeySetWithoutAlg()).Verify(p.SignWith("RS384", authtest.RSAKeyId, claims(nil)))
+	assert.NoError(t, err, "keys without alg verify any algorithm of their type")
+}
+
+func TestVerify_KeyCurve(t *testing.T) {
+	p := authtest.NewProvider(t)
+	v := newVerifier(p.KeySetWithoutAlg())
+
+	_, err := v.Verify(p.SignWith("ES256", authtest.ECKeyId, claims(nil)))
+	assert.NoError(t, err)
+	_, err = v.Verify(p.SignWith("ES384", authtest.ECKeyId, claims(nil)))
+	assert.ErrorIs(t, err, auth.ErrInvalidToken, "ES384 needs a P-384 key")
+}
+
+func TestVerify_NestedRolesClaim(t *testing.T) {
+	p := authtest.NewProvider(t)
+	v := newVerifier(p.KeySet())
+	v.RolesClaim = "realm_access.roles"
+
+	identity, err := v.Verify(p.Sign(claims(map[string]interface{}{
+		"realm_access": map[string]interface{}{"roles": []string{"operators"}},
+	})))
+	assert.NoError(t, err)
+	assert.Equal(t, auth.WriteScope, identity.Scope)
+}
+
+func TestVerify_TenantClaim(t *testing.T) {
+	p := authtest.NewProvider(t)
+	v := newVerifier(p.KeySet())
+
+	identity, err := v.Verify(p.Sign(claims(map[string]interface{}{"tenant": "payments"})))
+	assert.NoError(t, err)
+	assert.Equal(t, auth.DefaultTenant, identity.Tenant, "the claim is ignored until it's configured")
+
+	v.TenantClaim = "org.tenant"
+	identity, err = v.Verify(p.Sign(claims(map[string]interface{}{
+		"org": map[string]interface{}{"tenant": "payments"},
+	})))
+	assert.NoError(t, err)
+	assert.Equal(t, "payments", identity.Tenant)
+
+	identity, err = v.Verify(p.Sign(claims(nil)))
+	assert.NoError(t, err)
+	assert.Equal(t, auth.DefaultTenant, identity.Tenant)
+
+	_, err = v.Verify(p.Sign(claims(map[string]interface{}{
+		"org": map[string]interface{}{"tenant": "with spaces"},
+	})))
+	assert.ErrorIs(t, err, auth.ErrInvalidToken)
+}
+
+func TestRemoteKeySet(t *testing.T) {
+	p := authtest.NewProvider(t)
+	fetches := atomic.Int32{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			w.Write([]byte(`{"issuer": "` + issuer + `", "jwks_uri": "http://` + r.Host + `/keys"}`))
+		case "/keys":
+			fetches.Add(1)
+			w.Write(p.JWKS())
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	url, err := auth.DiscoverJWKSURL(server.Client(), server.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, server.URL+"/keys", url)
+
+	keys := auth.NewRemoteKeySet(url)
+	v := newVerifier(keys)
+	_, err = v.Verify(p.Sign(claims(nil)))
+	assert.NoError(t, err)
+	_, err = v.Verify(p.SignWith("ES256", authtest.ECKeyId, claims(nil)))
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), fetches.Load(), "keys are cached")
+
+	_, err = v.Verify(p.SignWith("RS256", "rotated", claims(nil)))
+	assert.ErrorIs(t, err, auth.ErrInvalidToken)
+	assert.Equal(t, int32(1), fetches.Load(), "unknown keys don't refetch more than once every MinRefresh")
+
+	keys.MinRefresh = 0
+	_, err = v.Verify(p.SignWith("RS256", "rotated", claims(nil)))
+	assert.ErrorIs(t, err, auth.ErrInvalidToken)
+	assert.Equal(t, int32(2), fetches.Load())
+
+	_, err = auth.DiscoverJWKSURL(server.Client(), server.URL+"/missing")
+	assert.Error(t, err)
+}
+
+func TestRemoteKeySet_Refresh(t *testing.T) {
+	p := authtest.NewProvider(t)
+	fetches := atomic.Int32{}
+	fetching := make(chan struct{})
+	fail := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fetches.Add(1) == 1 {
+			w.Write(p.JWKS())
+			return
+		}
+		fetching <- struct{}{}
+		<-fail
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	keys := auth.NewRemoteKeySet(server.URL)
+	keys.MinRefresh = 0
+	v := newVerifier(keys)
+	_, err := v.Verify(p.Sign(claims(nil)))
+	assert.NoError(t, err)
+
+	rotated := make(chan error)
+	go func() {
+		_, err := v.Verify(p.SignWith("RS256", "rotated", claims(nil)))
+		rotated <- err
+	}()
+	<-fetching
+	_, err = v.Verify(p.Sign(claims(nil)))
+	assert.NoError(t, err, "known keys are checked while the keys are fetched again")
+	close(fail)
+	assert.ErrorIs(t, <-rotated, auth.ErrInvalidToken, "unknown keys are rejected when the refresh fails")
+}
@@ -0,0 +1,112 @@
+// Calls the ruok API. The methods of Client are generated from v1.Operations, one for every route,
+// run "go generate ./pkg/client" after changing them.
+package client
+
+//go:generate go run ./gen
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	v1 "github.com/back-end-labs/ruok/pkg/api/v1"
+)
+
+type Client struct {
+	// Where ruok is served, like http://localhost:8080
+	BaseURL string
+	// An api key or a token of the identity provider, not needed when the API has no authentication
+	Token      string
+	HTTPClient *http.Client
+}
+
+func New(baseURL string, token string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		Token:      token,
+		HTTPClient: &http.Client{Timeout: 90 * time.Second},
+	}
+}
+
+// Answer of a request that didn't get the status it expected
+type APIError struct {
+	Status int
+	// Empty when the answer wasn't a problem, like the ones of proxies
+	Problem v1.Problem
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("ruok answered %d %s", e.Status, http.StatusText(e.Status))
+	if e.Problem.Detail != "" {
+		msg += ": " + e.Problem.Detail
+	}
+	for _, f := range e.Problem.Errors {
+		msg += fmt.Sprintf(", %s: %s", f.Field, f.Message)
+	}
+	for _, change := range e.Problem.Changes {
+		if change.Error != "" {
+			msg += fmt.Sprintf(", %s: %s", change.Key, change.Error)
+		}
+	}
+	return msg
+}
+
+// Sends "in" as the json body of a request to the v1 path and decodes the answer into "out".
+// A *string "out" takes the answer as plain text. Answers with another status than "status" are an *APIError.
+func (c *Client) do(method string, path string, query url.Values, in any, status int, out any) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+
+	target := c.BaseURL + "/v1" + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(method, target, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	answer, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	if res.StatusCode != status {
+		apiErr := &APIError{Status: res.StatusCode}
+		json.Unmarshal(answer, &apiErr.Problem)
+		return apiErr
+	}
+
+	if text, ok := out.(*string); ok {
+		*text = string(answer)
+		return nil
+	}
+	return json.Unmarshal(answer, out)
+}
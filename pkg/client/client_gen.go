@@ -0,0 +1,501 @@
+// Code generated by go run ./gen from v1.Operations. DO NOT EDIT.
+
+package client
+
+import (
+	"net/url"
+	"strconv"
+	"time"
+
+	v1 "github.com/back-end-labs/ruok/pkg/api/v1"
+	"github.com/back-end-labs/ruok/pkg/storage"
+	"github.com/gofrs/uuid"
+)
+
+// Answers OK while the server is up
+func (c *Client) Status() (string, error) {
+	query := url.Values{}
+	var out string
+	err := c.do("GET", "/status", query, nil, 200, &out)
+	return out, err
+}
+
+// Reports ok unless the listener lost its connection, answering 503 then
+func (c *Client) Health() (*v1.HealthResponse, error) {
+	query := url.Values{}
+	out := &v1.HealthResponse{}
+	err := c.do("GET", "/health", query, nil, 200, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Tells clients how to log in
+func (c *Client) AuthConfig() (*v1.AuthSettings, error) {
+	query := url.Values{}
+	out := &v1.AuthSettings{}
+	err := c.do("GET", "/auth/config", query, nil, 200, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Serves the OpenAPI 3 spec of the API
+func (c *Client) OpenAPI() (map[string]any, error) {
+	query := url.Values{}
+	out := map[string]any{}
+	err := c.do("GET", "/openapi.json", query, nil, 200, &out)
+	return out, err
+}
+
+// Query params of ListJobs, the ones left empty are not sent
+type ListJobsParams struct {
+	// jobs in the page, 10 by default
+	Limit int
+	// nextCursor of the previous page
+	Cursor string
+	// only jobs with this status
+	Status string
+	// only jobs whose last execution was ok or error
+	Succeeded string
+	// only jobs whose name contains this, ignoring case
+	Name string
+	// labels the jobs must have, like env=prod,team!=core
+	Selector string
+	// id, name, createdAt or lastExecution, prefixed with - for descending order
+	Sort string
+}
+
+// Lists the jobs of every scheduler, a page at a time
+func (c *Client) ListJobs(params ListJobsParams) (*v1.ListJobsResponse, error) {
+	query := url.Values{}
+	if params.Limit != 0 {
+		query.Set("limit", strconv.Itoa(params.Limit))
+	}
+	if params.Cursor != "" {
+		query.Set("cursor", params.Cursor)
+	}
+	if params.Status != "" {
+		query.Set("status", params.Status)
+	}
+	if params.Succeeded != "" {
+		query.Set("succeeded", params.Succeeded)
+	}
+	if params.Name != "" {
+		query.Set("name", params.Name)
+	}
+	if params.Selector != "" {
+		query.Set("selector", params.Selector)
+	}
+	if params.Sort != "" {
+		query.Set("sort", params.Sort)
+	}
+	out := &v1.ListJobsResponse{}
+	err := c.do("GET", "/jobs", query, nil, 200, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Gets the definition of a job
+func (c *Client) GetJob(id uuid.UUID) (*v1.GetJobResponse, error) {
+	query := url.Values{}
+	out := &v1.GetJobResponse{}
+	err := c.do("GET", "/jobs/"+id.String(), query, nil, 200, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Query params of ListJobExecutions, the ones left empty are not sent
+type ListJobExecutionsParams struct {
+	// executions in the page, 10 by default
+	Limit int
+	// nextCursor of the previous page
+	Cursor string
+}
+
+// Lists the executions of a job, newest first
+func (c *Client) ListJobExecutions(id uuid.UUID, params ListJobExecutionsParams) (*v1.ListJobExecutionsResponse, error) {
+	query := url.Values{}
+	if params.Limit != 0 {
+		query.Set("limit", strconv.Itoa(params.Limit))
+	}
+	if params.Cursor != "" {
+		query.Set("cursor", params.Cursor)
+	}
+	out := &v1.ListJobExecutionsResponse{}
+	err := c.do("GET", "/jobs/"+id.String()+"/executions", query, nil, 200, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Query params of ListJobResultAggregates, the ones left empty are not sent
+type ListJobResultAggregatesParams struct {
+	// start of the range
+	From time.Time
+	// end of the range, now by default
+	To time.Time
+	// hour or day, picked from the range by default
+	Granularity string
+}
+
+// Counts the executions and failures of a job and its latencies, by hour or day
+func (c *Client) ListJobResultAggregates(id uuid.UUID, params ListJobResultAggregatesParams) (*v1.AggregatesResponse, error) {
+	query := url.Values{}
+	if !params.From.IsZero() {
+		query.Set("from", params.From.Format(time.RFC3339))
+	}
+	if !params.To.IsZero() {
+		query.Set("to", params.To.Format(time.RFC3339))
+	}
+	if params.Granularity != "" {
+		query.Set("granularity", params.Granularity)
+	}
+	out := &v1.AggregatesResponse{}
+	err := c.do("GET", "/jobs/"+id.String()+"/aggregates", query, nil, 200, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Query params of ListJobHistory, the ones left empty are not sent
+type ListJobHistoryParams struct {
+	// revisions in the page, 20 by default
+	Limit int
+	// revisions to skip
+	Offset int
+}
+
+// Lists the changes made to a job, newest first
+func (c *Client) ListJobHistory(id uuid.UUID, params ListJobHistoryParams) (*v1.HistoryResponse, error) {
+	query := url.Values{}
+	if params.Limit != 0 {
+		query.Set("limit", strconv.Itoa(params.Limit))
+	}
+	if params.Offset != 0 {
+		query.Set("offset", strconv.Itoa(params.Offset))
+	}
+	out := &v1.HistoryResponse{}
+	err := c.do("GET", "/jobs/"+id.String()+"/history", query, nil, 200, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Describes the scheduler answering
+func (c *Client) GetInstanceInfo() (*v1.InstanceInfo, error) {
+	query := url.Values{}
+	out := &v1.InstanceInfo{}
+	err := c.do("GET", "/instance", query, nil, 200, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Query params of NextExecutions, the ones left empty are not sent
+type NextExecutionsParams struct {
+	// cron expression
+	Cronexp string
+	// IANA timezone, UTC by default
+	Timezone string
+	// how many executions, 5 by default
+	N int
+}
+
+// Lists the upcoming execution times of a schedule
+func (c *Client) NextExecutions(params NextExecutionsParams) (*v1.NextExecutionsResponse, error) {
+	query := url.Values{}
+	if params.Cronexp != "" {
+		query.Set("cronexp", params.Cronexp)
+	}
+	if params.Timezone != "" {
+		query.Set("timezone", params.Timezone)
+	}
+	if params.N != 0 {
+		query.Set("n", strconv.Itoa(params.N))
+	}
+	out := &v1.NextExecutionsResponse{}
+	err := c.do("GET", "/schedules/next", query, nil, 200, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Lists the maintenance windows
+func (c *Client) ListMaintenanceWindows() (*v1.ListMaintenanceWindowsResponse, error) {
+	query := url.Values{}
+	out := &v1.ListMaintenanceWindowsResponse{}
+	err := c.do("GET", "/maintenance", query, nil, 200, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Query params of Export, the ones left empty are not sent
+type ExportParams struct {
+	// json or yaml, json by default
+	Format string
+}
+
+// Writes every job as definitions keyed by their stable name
+func (c *Client) Export(params ExportParams) (*v1.Definitions, error) {
+	query := url.Values{}
+	if params.Format != "" {
+		query.Set("format", params.Format)
+	}
+	out := &v1.Definitions{}
+	err := c.do("GET", "/export", query, nil, 200, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Creates a job
+func (c *Client) CreateJob(body storage.CreateJobInput) (*v1.SaveJobResponse, error) {
+	query := url.Values{}
+	out := &v1.SaveJobResponse{}
+	err := c.do("POST", "/jobs", query, body, 201, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Replaces the definition of a job
+func (c *Client) UpdateJob(id uuid.UUID, body storage.UpdateJobInput) (*v1.SaveJobResponse, error) {
+	query := url.Values{}
+	out := &v1.SaveJobResponse{}
+	err := c.do("PUT", "/jobs/"+id.String(), query, body, 202, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Changes only the fields of a job present in the body
+func (c *Client) PatchJob(id uuid.UUID, body v1.PatchJobRequest) (*v1.SaveJobResponse, error) {
+	query := url.Values{}
+	out := &v1.SaveJobResponse{}
+	err := c.do("PATCH", "/jobs/"+id.String(), query, body, 202, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Deletes a job
+func (c *Client) DeleteJob(id uuid.UUID) (*v1.MessageResponse, error) {
+	query := url.Values{}
+	out := &v1.MessageResponse{}
+	err := c.do("DELETE", "/jobs/"+id.String(), query, nil, 202, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Query params of PauseJobs, the ones left empty are not sent
+type PauseJobsParams struct {
+	// labels the jobs must have, like env=prod,team!=core
+	Selector string
+}
+
+// Pauses every job matching the selector
+func (c *Client) PauseJobs(params PauseJobsParams) (*v1.BulkResponse, error) {
+	query := url.Values{}
+	if params.Selector != "" {
+		query.Set("selector", params.Selector)
+	}
+	out := &v1.BulkResponse{}
+	err := c.do("POST", "/jobs/pause", query, nil, 200, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Query params of ResumeJobs, the ones left empty are not sent
+type ResumeJobsParams struct {
+	// labels the jobs must have, like env=prod,team!=core
+	Selector string
+}
+
+// Resumes every paused job matching the selector
+func (c *Client) ResumeJobs(params ResumeJobsParams) (*v1.BulkResponse, error) {
+	query := url.Values{}
+	if params.Selector != "" {
+		query.Set("selector", params.Selector)
+	}
+	out := &v1.BulkResponse{}
+	err := c.do("POST", "/jobs/resume", query, nil, 200, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Query params of UpdateJobsAlerts, the ones left empty are not sent
+type UpdateJobsAlertsParams struct {
+	// labels the jobs must have, like env=prod,team!=core
+	Selector string
+}
+
+// Sends the alerts of every job matching the selector to the same channel
+func (c *Client) UpdateJobsAlerts(params UpdateJobsAlertsParams, body storage.JobAlertsInput) (*v1.BulkResponse, error) {
+	query := url.Values{}
+	if params.Selector != "" {
+		query.Set("selector", params.Selector)
+	}
+	out := &v1.BulkResponse{}
+	err := c.do("PUT", "/jobs/alerts", query, body, 200, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Pauses a job
+func (c *Client) PauseJob(id uuid.UUID) (*v1.MessageResponse, error) {
+	query := url.Values{}
+	out := &v1.MessageResponse{}
+	err := c.do("POST", "/jobs/"+id.String()+"/pause", query, nil, 202, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Resumes a paused job
+func (c *Client) ResumeJob(id uuid.UUID) (*v1.MessageResponse, error) {
+	query := url.Values{}
+	out := &v1.MessageResponse{}
+	err := c.do("POST", "/jobs/"+id.String()+"/resume", query, nil, 202, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Query params of RunJob, the ones left empty are not sent
+type RunJobParams struct {
+	// wait for the result
+	Wait bool
+	// seconds to wait for the result, 30 by default
+	Timeout int
+}
+
+// Runs a job right away, answering 200 with the result when waiting for it
+func (c *Client) RunJob(id uuid.UUID, params RunJobParams) (*v1.RunJobResponse, error) {
+	query := url.Values{}
+	if params.Wait {
+		query.Set("wait", strconv.FormatBool(params.Wait))
+	}
+	if params.Timeout != 0 {
+		query.Set("timeout", strconv.Itoa(params.Timeout))
+	}
+	out := &v1.RunJobResponse{}
+	err := c.do("POST", "/jobs/"+id.String()+"/run", query, nil, 202, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Brings a job back to how it was at a revision
+func (c *Client) RestoreJob(id uuid.UUID, revision int) (*v1.MessageResponse, error) {
+	query := url.Values{}
+	out := &v1.MessageResponse{}
+	err := c.do("POST", "/jobs/"+id.String()+"/history/"+strconv.Itoa(revision)+"/restore", query, nil, 202, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Query params of Import, the ones left empty are not sent
+type ImportParams struct {
+	// only answer with what would change
+	DryRun bool
+}
+
+// Creates the jobs of the definitions that are missing and updates the ones that differ, yaml bodies are read as yaml. Failed changes don't undo the rest, the problem lists them all
+func (c *Client) Import(params ImportParams, body v1.Definitions) (*v1.ImportResponse, error) {
+	query := url.Values{}
+	if params.DryRun {
+		query.Set("dryRun", strconv.FormatBool(params.DryRun))
+	}
+	out := &v1.ImportResponse{}
+	err := c.do("POST", "/import", query, body, 200, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Creates a maintenance window, only for the default tenant
+func (c *Client) CreateMaintenanceWindow(body storage.CreateMaintenanceWindowInput) (*v1.MessageResponse, error) {
+	query := url.Values{}
+	out := &v1.MessageResponse{}
+	err := c.do("POST", "/maintenance", query, body, 201, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Deletes a maintenance window, only for the default tenant
+func (c *Client) DeleteMaintenanceWindow(id uuid.UUID) (*v1.MessageResponse, error) {
+	query := url.Values{}
+	out := &v1.MessageResponse{}
+	err := c.do("DELETE", "/maintenance/"+id.String(), query, nil, 200, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Lists the api keys, revoked ones included
+func (c *Client) ListAPIKeys() (*v1.ListAPIKeysResponse, error) {
+	query := url.Values{}
+	out := &v1.ListAPIKeysResponse{}
+	err := c.do("GET", "/apikeys", query, nil, 200, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Creates an api key, the only time it can be read
+func (c *Client) CreateAPIKey(body v1.CreateAPIKeyRequest) (*v1.CreateAPIKeyResponse, error) {
+	query := url.Values{}
+	out := &v1.CreateAPIKeyResponse{}
+	err := c.do("POST", "/apikeys", query, body, 201, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Stops accepting an api key right away
+func (c *Client) RevokeAPIKey(id uuid.UUID) (*v1.MessageResponse, error) {
+	query := url.Values{}
+	out := &v1.MessageResponse{}
+	err := c.do("DELETE", "/apikeys/"+id.String(), query, nil, 202, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,81 @@
+package client
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/back-end-labs/ruok/pkg/api"
+	v1 "github.com/back-end-labs/ruok/pkg/api/v1"
+	"github.com/back-end-labs/ruok/pkg/auth"
+	"github.com/back-end-labs/ruok/pkg/storage"
+)
+
+func TestClient(t *testing.T) {
+	s, close := storage.NewMemoryStorage()
+	defer close()
+	key, _, err := v1.NewAPIKey(s, "client", auth.WriteScope, "")
+	assert.Nil(t, err)
+	server := httptest.NewServer(api.CreateRouter(s))
+	defer server.Close()
+	c := New(server.URL, key)
+
+	status, err := c.Status()
+	assert.Nil(t, err)
+	assert.Equal(t, "OK", status)
+
+	created, err := c.CreateJob(storage.CreateJobInput{
+		Name:            "client job",
+		CronExpString:   "*/5 * * * *",
+		Endpoint:        "http://localhost/",
+		HttpMethod:      "GET",
+		SuccessStatuses: []int{200},
+		Labels:          map[string]string{"team": "core"},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "job created", created.Message)
+	if assert.NotNil(t, created.Job) {
+		assert.Equal(t, created.Id, created.Job.Id)
+		assert.Equal(t, "client job", created.Job.Name)
+	}
+
+	page, err := c.ListJobs(ListJobsParams{Limit: 5, Selector: "team=core", Sort: "-name"})
+	if !assert.Nil(t, err) || !assert.Len(t, page.Jobs, 1) {
+		t.FailNow()
+	}
+	assert.Equal(t, 5, page.Limit)
+	assert.Equal(t, "-name", page.Sort)
+	id := page.Jobs[0].Id
+
+	_, err = c.PatchJob(id, map[string]any{"name": "patched job"})
+	assert.Nil(t, err)
+
+	got, err := c.GetJob(id)
+	assert.Nil(t, err)
+	assert.Equal(t, "patched job", got.Job.Name)
+
+	executions, err := c.ListJobExecutions(id, ListJobExecutionsParams{})
+	assert.Nil(t, err)
+	assert.Equal(t, id, executions.JobId)
+	assert.Empty(t, executions.JobResults)
+
+	_, err = c.GetJob(uuid.Must(uuid.NewV7()))
+	apiErr := &APIError{}
+	if assert.True(t, errors.As(err, &apiErr)) {
+		assert.Equal(t, 404, apiErr.Status)
+		assert.Equal(t, v1.CodeNotFound, apiErr.Problem.Code)
+		assert.Contains(t, apiErr.Problem.Detail, "could not find a job")
+	}
+
+	_, err = c.ListJobs(ListJobsParams{Sort: "nope"})
+	if assert.True(t, errors.As(err, &apiErr)) {
+		assert.Equal(t, 400, apiErr.Status)
+	}
+
+	spec, err := c.OpenAPI()
+	assert.Nil(t, err)
+	assert.Equal(t, "3.0.3", spec["openapi"])
+}
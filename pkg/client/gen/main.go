@@ -0,0 +1,210 @@
+// Writes client_gen.go, the methods of the client for every route in v1.Operations.
+// It is run by "go generate ./pkg/client".
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"os"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+
+	v1 "github.com/back-end-labs/ruok/pkg/api/v1"
+)
+
+var output = "client_gen.go"
+
+func main() {
+	src, err := generate(v1.Operations)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not generate the client: %v\n", err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(output, src, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "could not write %s: %v\n", output, err)
+		os.Exit(1)
+	}
+}
+
+// Go types of the params, with how they are written in a url and when they are sent in the query
+var paramTypes = map[string]struct {
+	goType string
+	pkg    string
+	format string
+	isSet  string
+}{
+	v1.StringParam:   {"string", "", "%s", `%s != ""`},
+	v1.IntegerParam:  {"int", "strconv", "strconv.Itoa(%s)", "%s != 0"},
+	v1.BooleanParam:  {"bool", "strconv", "strconv.FormatBool(%s)", "%s"},
+	v1.UUIDParam:     {"uuid.UUID", "github.com/gofrs/uuid", "%s.String()", "%s != uuid.Nil"},
+	v1.DateTimeParam: {"time.Time", "time", "%s.Format(time.RFC3339)", "!%s.IsZero()"},
+}
+
+// Writes the methods of the client, formatted
+func generate(ops []v1.Operation) ([]byte, error) {
+	g := &generator{imports: map[string]bool{"net/url": true}}
+	for _, op := range ops {
+		if err := g.operation(op); err != nil {
+			return nil, err
+		}
+	}
+
+	imports := []string{}
+	for pkg := range g.imports {
+		imports = append(imports, pkg)
+	}
+	sort.Strings(imports)
+
+	src := &bytes.Buffer{}
+	fmt.Fprintln(src, "// Code generated by go run ./gen from v1.Operations. DO NOT EDIT.")
+	fmt.Fprintln(src)
+	fmt.Fprintln(src, "package client")
+	fmt.Fprintln(src)
+	fmt.Fprintln(src, "import (")
+	// the standard library first
+	for _, std := range []bool{true, false} {
+		for _, pkg := range imports {
+			if isStd := !strings.Contains(pkg, "."); isStd != std {
+				continue
+			}
+			if path.Base(pkg) == "v1" {
+				fmt.Fprintf(src, "v1 %q\n", pkg)
+				continue
+			}
+			fmt.Fprintf(src, "%q\n", pkg)
+		}
+		if std {
+			fmt.Fprintln(src)
+		}
+	}
+	fmt.Fprintln(src, ")")
+	src.Write(g.body.Bytes())
+
+	return format.Source(src.Bytes())
+}
+
+type generator struct {
+	body    bytes.Buffer
+	imports map[string]bool
+}
+
+func (g *generator) operation(op v1.Operation) error {
+	w := &g.body
+	args := []string{}
+	query := []v1.Param{}
+	for _, p := range op.Params {
+		t, ok := paramTypes[p.Type]
+		if !ok {
+			return fmt.Errorf("%s: unknown type %q of param %q", op.Id, p.Type, p.Name)
+		}
+		if t.pkg != "" {
+			g.imports[t.pkg] = true
+		}
+		if p.In == "path" {
+			args = append(args, p.Name+" "+t.goType)
+		} else {
+			query = append(query, p)
+		}
+	}
+
+	paramsType := op.Id + "Params"
+	if len(query) > 0 {
+		fmt.Fprintf(w, "\n// Query params of %s, the ones left empty are not sent\n", op.Id)
+		fmt.Fprintf(w, "type %s struct {\n", paramsType)
+		for _, p := range query {
+			fmt.Fprintf(w, "// %s\n%s %s\n", p.Description, fieldName(p.Name), paramTypes[p.Type].goType)
+		}
+		fmt.Fprintln(w, "}")
+		args = append(args, "params "+paramsType)
+	}
+
+	if op.Request != nil {
+		args = append(args, "body "+g.typeName(reflect.TypeOf(op.Request)))
+	}
+
+	result, declare, ret := "", "", ""
+	switch response := op.Response.(type) {
+	case string:
+		result, declare, ret = "string", "var out string", "&out"
+	default:
+		t := reflect.TypeOf(response)
+		if t.Kind() == reflect.Struct {
+			result, declare, ret = "*"+g.typeName(t), "out := &"+g.typeName(t)+"{}", "out"
+		} else {
+			result, declare, ret = g.typeName(t), "out := "+g.typeName(t)+"{}", "&out"
+		}
+	}
+
+	fmt.Fprintf(w, "\n// %s\nfunc (c *Client) %s(%s) (%s, error) {\n", op.Summary, op.Id, strings.Join(args, ", "), result)
+	fmt.Fprintln(w, "query := url.Values{}")
+	for _, p := range query {
+		t := paramTypes[p.Type]
+		field := "params." + fieldName(p.Name)
+		fmt.Fprintf(w, "if %s {\nquery.Set(%q, %s)\n}\n", fmt.Sprintf(t.isSet, field), p.Name, fmt.Sprintf(t.format, field))
+	}
+	fmt.Fprintln(w, declare)
+	in := "nil"
+	if op.Request != nil {
+		in = "body"
+	}
+	fmt.Fprintf(w, "err := c.do(%q, %s, query, %s, %d, %s)\n", op.Method, g.path(op), in, op.Status, ret)
+	if strings.HasPrefix(result, "*") {
+		fmt.Fprintln(w, "if err != nil {\nreturn nil, err\n}\nreturn out, nil\n}")
+	} else {
+		fmt.Fprintln(w, "return out, err\n}")
+	}
+	return nil
+}
+
+// The expression building the path of the operation out of its path params
+func (g *generator) path(op v1.Operation) string {
+	types := map[string]string{}
+	for _, p := range op.Params {
+		types[p.Name] = p.Type
+	}
+	parts := []string{}
+	literal := ""
+	for _, segment := range strings.Split(strings.TrimPrefix(op.Path, "/"), "/") {
+		name, isParam := strings.CutPrefix(segment, ":")
+		if !isParam {
+			literal += "/" + segment
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%q", literal+"/"), fmt.Sprintf(paramTypes[types[name]].format, name))
+		literal = ""
+	}
+	if literal != "" {
+		parts = append(parts, fmt.Sprintf("%q", literal))
+	}
+	return strings.Join(parts, " + ")
+}
+
+// How a type is written in the client, importing its package
+func (g *generator) typeName(t reflect.Type) string {
+	if t.Name() != "" {
+		if t.PkgPath() == "" {
+			return t.Name()
+		}
+		g.imports[t.PkgPath()] = true
+		return path.Base(t.PkgPath()) + "." + t.Name()
+	}
+	switch t.Kind() {
+	case reflect.Map:
+		return "map[" + g.typeName(t.Key()) + "]" + g.typeName(t.Elem())
+	case reflect.Slice:
+		return "[]" + g.typeName(t.Elem())
+	case reflect.Pointer:
+		return "*" + g.typeName(t.Elem())
+	case reflect.Interface:
+		return "any"
+	}
+	return t.String()
+}
+
+// Params are exported fields of the Params struct of their operation
+func fieldName(param string) string {
+	return strings.ToUpper(param[:1]) + param[1:]
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	v1 "github.com/back-end-labs/ruok/pkg/api/v1"
+)
+
+// The client must be generated again whenever the operations change
+func TestClientIsUpToDate(t *testing.T) {
+	src, err := generate(v1.Operations)
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+
+	current, err := os.ReadFile("../" + output)
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+
+	assert.Equal(t, string(src), string(current), "run go generate ./pkg/client")
+}
@@ -1,15 +1,20 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"path"
 	"regexp"
 	"runtime"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/rs/zerolog/log"
+
+	"github.com/back-end-labs/ruok/pkg/auth"
+	"github.com/back-end-labs/ruok/pkg/labels"
 )
 
 // SSL File Names
@@ -21,6 +26,16 @@ var CLIENT_KEY_FILE string = "/client-key.pem"
 var DISABLE_SSL = "disable"
 var REQUIRE_SSL = "require"
 
+// Storage Kinds
+var POSTGRES_STORAGE = "postgres"
+var SQLITE_STORAGE = "sqlite"
+var MEMORY_STORAGE = "memory"
+
+// API Authentication Modes
+var NO_AUTH = "none"
+var KEYS_AUTH = "keys"
+var OIDC_AUTH = "oidc"
+
 // PROD_ENVIRONMENT
 var ProdRuokEnvironment = "production"
 
@@ -41,32 +56,86 @@ var DB_USER string = "DB_USER"
 var DB_HOST string = "DB_HOST"
 var DB_PORT string = "DB_PORT"
 var DB_NAME string = "DB_NAME"
+var SQLITE_PATH string = "SQLITE_PATH"
 var APP_NAME string = "APP_NAME"
 var POLL_INTERVAL_SECONDS string = "POLL_INTERVAL_SECONDS"
 var MAX_JOBS string = "MAX_JOBS"
 var RUOK_ENVIRONMENT = "RUOK_ENVIRONMENT"
 var ALERT_CHANNELS = "ALERT_CHANNELS"
+var EXECUTION_WORKERS = "EXECUTION_WORKERS"
+var MAX_CONCURRENCY_PER_HOST = "MAX_CONCURRENCY_PER_HOST"
+var START_JITTER_SECONDS = "START_JITTER_SECONDS"
+var RESULTS_BATCH_SIZE = "RESULTS_BATCH_SIZE"
+var RESULTS_FLUSH_INTERVAL_SECONDS = "RESULTS_FLUSH_INTERVAL_SECONDS"
+var RESULTS_SPOOL_FILE = "RESULTS_SPOOL_FILE"
+var RESULTS_RETENTION_DAYS = "RESULTS_RETENTION_DAYS"
+var HOURLY_AGGREGATES_RETENTION_DAYS = "HOURLY_AGGREGATES_RETENTION_DAYS"
+var COMPACTION_INTERVAL_SECONDS = "COMPACTION_INTERVAL_SECONDS"
+var CLAIM_SELECTOR = "CLAIM_SELECTOR"
+var LOCATION = "LOCATION"
+var API_AUTH = "API_AUTH"
+var CORS_ALLOWED_ORIGINS = "CORS_ALLOWED_ORIGINS"
+var OIDC_ISSUER = "OIDC_ISSUER"
+var OIDC_AUDIENCE = "OIDC_AUDIENCE"
+var OIDC_JWKS_URL = "OIDC_JWKS_URL"
+var OIDC_CLIENT_ID = "OIDC_CLIENT_ID"
+var OIDC_CLOCK_SKEW_SECONDS = "OIDC_CLOCK_SKEW_SECONDS"
+var OIDC_ROLES_CLAIM = "OIDC_ROLES_CLAIM"
+var OIDC_ROLES = "OIDC_ROLES"
+var OIDC_TENANT_CLAIM = "OIDC_TENANT_CLAIM"
+var TENANT_MAX_JOBS = "TENANT_MAX_JOBS"
+var TENANT_MIN_INTERVAL_SECONDS = "TENANT_MIN_INTERVAL_SECONDS"
+var SYNC_DIR = "SYNC_DIR"
+var SYNC_INTERVAL_SECONDS = "SYNC_INTERVAL_SECONDS"
+var SYNC_DB_USER = "SYNC_DB_USER"
+var SYNC_DB_PASS = "SYNC_DB_PASS"
 
 // Defaults
 var defaultMaxJobs int = 10000
 var defaultPollInterval time.Duration = time.Minute
-var defaultKind string = "postgres"
+var defaultKind string = POSTGRES_STORAGE
 var defaultProtocol string = "postgresql"
 var defaultPass string = "password"
 var defaultUser string = "application1"
 var defaultHost string = "localhost"
 var defaultPort string = "5432"
 var defaultDbname string = "db1"
+var defaultSQLitePath string = "./ruok.db"
 var defaultAppName string = "application1"
 var defaultBaseDir string = "/app"
 var defaultSSLMode string = DISABLE_SSL
 var defaultSSLPass string = "clientpass"
 var defaultRuokEnvironment string = "development"
 var defaultAlertChannels = []string{ALERT_HTTP}
+var defaultExecutionWorkers int = 100
+var defaultMaxConcurrencyPerHost int = 10
+var defaultStartJitter time.Duration = 0
+var defaultResultsBatchSize int = 500
+var defaultResultsFlushInterval time.Duration = time.Second
+var defaultResultsSpoolFile string = "./results.spool"
+var defaultResultsRetentionDays int = 30
+var defaultHourlyAggregatesRetentionDays int = 90
+var defaultCompactionInterval time.Duration = time.Hour
+var defaultAPIAuth string = KEYS_AUTH
+var defaultCORSAllowedOrigins = []string{"*"}
+var defaultOIDCClockSkew time.Duration = time.Minute
+var defaultOIDCRolesClaim string = "roles"
+var defaultOIDCRoles string = "ruok-read=read,ruok-write=write,ruok-admin=admin"
+var defaultSyncInterval time.Duration = time.Minute
 
 type Stats struct {
 	ClaimedJobs int
 	StartedAt   int64
+	// Notifications we couldn't understand, updated from the listener gorutine
+	malformedNotifications atomic.Int64
+}
+
+func (s *Stats) CountMalformedNotification() {
+	s.malformedNotifications.Add(1)
+}
+
+func (s *Stats) MalformedNotifications() int64 {
+	return s.malformedNotifications.Load()
 }
 
 func (s *Stats) Uptime() int64 {
@@ -95,10 +164,92 @@ type Configs struct {
 	PollInterval  time.Duration
 	StartedAt     int64
 	AlertChannels []string
+	// How many executions can happen at the same time
+	ExecutionWorkers int
+	// How many executions can target the same host at the same time. 0 means no limit
+	MaxConcurrencyPerHost int
+	// Upper bound of the delay added to start times, to spread executions scheduled for the same instant
+	StartJitter time.Duration
+	// How many execution results are written to the db at once
+	ResultsBatchSize int
+	// How often buffered execution results are written to the db, even if the batch is not full
+	ResultsFlushInterval time.Duration
+	// Where execution results are kept while the db can't be reached
+	ResultsSpoolFile string
+	// Days execution results are kept before being compacted into aggregates. 0 keeps them forever
+	ResultsRetentionDays int
+	// Days hourly aggregates are kept. Daily ones are kept forever. 0 keeps them forever too
+	HourlyAggregatesRetentionDays int
+	// How often old execution results are compacted
+	CompactionInterval time.Duration
+	// Database file used when Kind is sqlite
+	SQLitePath string
+	// Only jobs with matching labels are claimed. Empty claims every job
+	ClaimSelector labels.Selector
+	// Where the scheduler probes from, like "eu-west". Jobs that require locations are only claimed by schedulers in one of them
+	Location string
+	// How API callers are authenticated, none lets anybody do anything
+	APIAuth string
+	// Origins allowed to call the API from a browser, "*" allows any
+	CORSAllowedOrigins []string
+	// Identity provider whose tokens are accepted when APIAuth is oidc
+	OIDC OIDCConfig
+	// Limits of every tenant, "*" applies to the ones that are not listed
+	TenantQuotas TenantQuotas
+	// Directory of job definitions the scheduler keeps the jobs in sync with. Empty doesn't sync
+	SyncDir string
+	// How often the jobs are synced with SyncDir
+	SyncInterval time.Duration
+	// Postgres user sync connects with, so it sees the jobs of other schedulers. Empty uses User
+	SyncUser string
+	SyncPass string
+}
+
+type OIDCConfig struct {
+	// Expected "iss" of the tokens, the discovery document is looked up under it
+	Issuer string
+	// Expected "aud" of the tokens
+	Audience string
+	// Where the signing keys are, empty takes it from the discovery document
+	JWKSURL string
+	// Client the UI logs in with
+	ClientId string
+	// Tolerance when checking "exp" and "nbf"
+	ClockSkew time.Duration
+	// Claim with the roles of the caller, nested claims are separated by dots like "realm_access.roles"
+	RolesClaim string
+	// Scope granted by each role, callers get the highest of their roles
+	Roles map[string]auth.Scope
+	// Claim with the tenant of the caller, empty puts every caller in auth.DefaultTenant
+	TenantClaim string
+}
+
+// Limits of a tenant, zero means no limit
+type TenantQuota struct {
+	// Jobs that are not deleted
+	MaxJobs int
+	// Shortest time allowed between two executions of a job
+	MinInterval time.Duration
+}
+
+type TenantQuotas map[string]TenantQuota
+
+// Quota of the tenant, the one of "*" if it's not listed
+func (q TenantQuotas) For(tenant string) TenantQuota {
+	if quota, ok := q[tenant]; ok {
+		return quota
+	}
+	return q["*"]
 }
 
 var globalConfigs *Configs = nil
 
+// Forgets the configs read from the environment, so the next read sees the envs as they are now.
+// Only meant for tests that change them with t.Setenv
+func Reset() {
+	globalConfigs = nil
+}
+
 func parseAlertChannels() []string {
 	chanString := getEnvOrDefault(ALERT_CHANNELS, ALERT_HTTP)
 	inputChannels := strings.Split(chanString, ",")
@@ -151,6 +302,20 @@ func ParsePollInterval() time.Duration {
 
 }
 
+// Parses an integer env that can't be lower than "lowest", using the default value if it is missing or invalid
+func parseIntEnv(env string, defaultValue int, lowest int) int {
+	raw := strings.TrimSpace(os.Getenv(env))
+	if raw == "" {
+		return defaultValue
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil || value < lowest {
+		log.Error().Err(err).Msgf("could not parse %s env %q defaulting to %d", env, raw, defaultValue)
+		return defaultValue
+	}
+	return value
+}
+
 func getEnvOrDefault(env string, defaultValue string) string {
 	if os.Getenv(env) != "" {
 		return os.Getenv(env)
@@ -233,6 +398,167 @@ func validateAppNameOrFail() string {
 	return appName
 }
 
+func parseClaimSelectorOrFail() labels.Selector {
+	selector, err := labels.Parse(os.Getenv(CLAIM_SELECTOR))
+	if err != nil {
+		log.Fatal().Err(err).Msgf("Cant continue. Invalid %s %q", CLAIM_SELECTOR, os.Getenv(CLAIM_SELECTOR))
+	}
+	return selector
+}
+
+func validateLocationOrFail() string {
+	location := os.Getenv(LOCATION)
+	if location != "" && !IsValidLocation(location) {
+		log.Fatal().Msgf(
+			"Cant continue. Invalid %s. Only letters, numbers, '-' and '_' are allowed. Submitted location was: %q",
+			LOCATION,
+			location,
+		)
+	}
+	return location
+}
+
+// Callers of an API without authentication are admins of every tenant,
+// so it must be asked for and is never allowed in production
+func parseAPIAuth(mode string, environment string) (string, error) {
+	if mode == "" {
+		mode = defaultAPIAuth
+	}
+	if mode != NO_AUTH && mode != KEYS_AUTH && mode != OIDC_AUTH {
+		return "", fmt.Errorf("%s must be one of [ %s %s %s ], instead got %q", API_AUTH, NO_AUTH, KEYS_AUTH, OIDC_AUTH, mode)
+	}
+	if mode == NO_AUTH && environment == ProdRuokEnvironment {
+		return "", fmt.Errorf("%s can't be %s when %s is %s", API_AUTH, NO_AUTH, RUOK_ENVIRONMENT, ProdRuokEnvironment)
+	}
+	return mode, nil
+}
+
+func validateAPIAuthOrFail() string {
+	mode, err := parseAPIAuth(os.Getenv(API_AUTH), os.Getenv(RUOK_ENVIRONMENT))
+	if err != nil {
+		log.Fatal().Err(err).Msg("Cant continue")
+	}
+	return mode
+}
+
+// Parses "role=scope" pairs separated by commas
+func parseOIDCRoles(raw string) (map[string]auth.Scope, error) {
+	roles := map[string]auth.Scope{}
+	for _, pair := range strings.Split(raw, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		role, scope, found := strings.Cut(pair, "=")
+		role = strings.TrimSpace(role)
+		if !found || role == "" {
+			return nil, fmt.Errorf("%q must look like role=scope", pair)
+		}
+		parsed, err := auth.ParseScope(scope)
+		if err != nil {
+			return nil, err
+		}
+		roles[role] = parsed
+	}
+	return roles, nil
+}
+
+func getOIDCConfigsOrFail(mode string) OIDCConfig {
+	if mode != OIDC_AUTH {
+		return OIDCConfig{}
+	}
+	oidc := OIDCConfig{
+		Issuer:      strings.TrimSuffix(os.Getenv(OIDC_ISSUER), "/"),
+		Audience:    os.Getenv(OIDC_AUDIENCE),
+		JWKSURL:     os.Getenv(OIDC_JWKS_URL),
+		ClientId:    os.Getenv(OIDC_CLIENT_ID),
+		ClockSkew:   time.Second * time.Duration(parseIntEnv(OIDC_CLOCK_SKEW_SECONDS, int(defaultOIDCClockSkew.Seconds()), 0)),
+		RolesClaim:  getEnvOrDefault(OIDC_ROLES_CLAIM, defaultOIDCRolesClaim),
+		TenantClaim: os.Getenv(OIDC_TENANT_CLAIM),
+	}
+	if oidc.Issuer == "" || oidc.Audience == "" {
+		log.Fatal().Msgf("Cant continue. %s and %s are needed when %s is %s", OIDC_ISSUER, OIDC_AUDIENCE, API_AUTH, OIDC_AUTH)
+	}
+	roles, err := parseOIDCRoles(getEnvOrDefault(OIDC_ROLES, defaultOIDCRoles))
+	if err != nil {
+		log.Fatal().Err(err).Msgf("Cant continue. Invalid %s", OIDC_ROLES)
+	}
+	oidc.Roles = roles
+	return oidc
+}
+
+// Parses "tenant=number" pairs separated by commas, "*" is every tenant that is not listed
+func parseTenantLimits(raw string) (map[string]int, error) {
+	limits := map[string]int{}
+	for _, pair := range strings.Split(raw, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		tenant, value, found := strings.Cut(pair, "=")
+		tenant = strings.TrimSpace(tenant)
+		if !found || (tenant != "*" && !auth.IsValidTenant(tenant)) {
+			return nil, fmt.Errorf("%q must look like tenant=number", pair)
+		}
+		limit, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || limit < 0 {
+			return nil, fmt.Errorf("the limit of %q must be a number from 0, instead got %q", tenant, value)
+		}
+		limits[tenant] = limit
+	}
+	return limits, nil
+}
+
+func parseTenantQuotas(maxJobs string, minIntervalSeconds string) (TenantQuotas, error) {
+	jobs, err := parseTenantLimits(maxJobs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", TENANT_MAX_JOBS, err)
+	}
+	intervals, err := parseTenantLimits(minIntervalSeconds)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", TENANT_MIN_INTERVAL_SECONDS, err)
+	}
+	quotas := TenantQuotas{}
+	for tenant, limit := range jobs {
+		quota := quotas[tenant]
+		quota.MaxJobs = limit
+		quotas[tenant] = quota
+	}
+	for tenant, seconds := range intervals {
+		quota := quotas[tenant]
+		quota.MinInterval = time.Duration(seconds) * time.Second
+		quotas[tenant] = quota
+	}
+	return quotas, nil
+}
+
+func parseTenantQuotasOrFail() TenantQuotas {
+	quotas, err := parseTenantQuotas(os.Getenv(TENANT_MAX_JOBS), os.Getenv(TENANT_MIN_INTERVAL_SECONDS))
+	if err != nil {
+		log.Fatal().Err(err).Msg("Cant continue")
+	}
+	return quotas
+}
+
+func parseCORSAllowedOrigins() []string {
+	origins := []string{}
+	for _, origin := range strings.Split(os.Getenv(CORS_ALLOWED_ORIGINS), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	if len(origins) == 0 {
+		return defaultCORSAllowedOrigins
+	}
+	return origins
+}
+
+var locationRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]{1,63}$`)
+
+// Returns TRUE if the location is a valid name, jobs use it too for the locations they require.
+// Only letters, numbers, '-' and '_' are allowed, up to 63 characters
+func IsValidLocation(s string) bool {
+	return locationRegexp.MatchString(s)
+}
+
 func FromEnvs() Configs {
 	if globalConfigs == nil {
 		globalConfigs = &Configs{
@@ -243,12 +569,38 @@ func FromEnvs() Configs {
 			Host:          getEnvOrDefault(DB_HOST, defaultHost),
 			Port:          getEnvOrDefault(DB_PORT, defaultPort),
 			Dbname:        getEnvOrDefault(DB_NAME, defaultDbname),
+			SQLitePath:    getEnvOrDefault(SQLITE_PATH, defaultSQLitePath),
 			AppName:       validateAppNameOrFail(),
 			SSLConfigs:    getSSLConfigs(),
 			MaxJobs:       defaultMaxJobs,
 			PollInterval:  ParsePollInterval(),
 			AlertChannels: parseAlertChannels(),
+
+			ExecutionWorkers:      parseIntEnv(EXECUTION_WORKERS, defaultExecutionWorkers, 1),
+			MaxConcurrencyPerHost: parseIntEnv(MAX_CONCURRENCY_PER_HOST, defaultMaxConcurrencyPerHost, 0),
+			StartJitter:           time.Second * time.Duration(parseIntEnv(START_JITTER_SECONDS, int(defaultStartJitter.Seconds()), 0)),
+
+			ResultsBatchSize:     parseIntEnv(RESULTS_BATCH_SIZE, defaultResultsBatchSize, 1),
+			ResultsFlushInterval: time.Second * time.Duration(parseIntEnv(RESULTS_FLUSH_INTERVAL_SECONDS, int(defaultResultsFlushInterval.Seconds()), 1)),
+			ResultsSpoolFile:     getEnvOrDefault(RESULTS_SPOOL_FILE, defaultResultsSpoolFile),
+
+			ResultsRetentionDays:          parseIntEnv(RESULTS_RETENTION_DAYS, defaultResultsRetentionDays, 0),
+			HourlyAggregatesRetentionDays: parseIntEnv(HOURLY_AGGREGATES_RETENTION_DAYS, defaultHourlyAggregatesRetentionDays, 0),
+			CompactionInterval:            time.Second * time.Duration(parseIntEnv(COMPACTION_INTERVAL_SECONDS, int(defaultCompactionInterval.Seconds()), 1)),
+
+			ClaimSelector: parseClaimSelectorOrFail(),
+			Location:      validateLocationOrFail(),
+
+			APIAuth:            validateAPIAuthOrFail(),
+			CORSAllowedOrigins: parseCORSAllowedOrigins(),
+			TenantQuotas:       parseTenantQuotasOrFail(),
+
+			SyncDir:      getEnvOrDefault(SYNC_DIR, ""),
+			SyncInterval: time.Second * time.Duration(parseIntEnv(SYNC_INTERVAL_SECONDS, int(defaultSyncInterval.Seconds()), 1)),
+			SyncUser:     getEnvOrDefault(SYNC_DB_USER, ""),
+			SyncPass:     getEnvOrDefault(SYNC_DB_PASS, ""),
 		}
+		globalConfigs.OIDC = getOIDCConfigsOrFail(globalConfigs.APIAuth)
 	}
 	return *globalConfigs
 }
@@ -280,3 +632,87 @@ func AlertChannels() []string {
 	}
 	return globalConfigs.AlertChannels
 }
+
+func ExecutionWorkers() int {
+	if globalConfigs == nil {
+		return FromEnvs().ExecutionWorkers
+	}
+	return globalConfigs.ExecutionWorkers
+}
+
+func MaxConcurrencyPerHost() int {
+	if globalConfigs == nil {
+		return FromEnvs().MaxConcurrencyPerHost
+	}
+	return globalConfigs.MaxConcurrencyPerHost
+}
+
+func StartJitter() time.Duration {
+	if globalConfigs == nil {
+		return FromEnvs().StartJitter
+	}
+	return globalConfigs.StartJitter
+}
+
+func ResultsRetentionDays() int {
+	if globalConfigs == nil {
+		return FromEnvs().ResultsRetentionDays
+	}
+	return globalConfigs.ResultsRetentionDays
+}
+
+func HourlyAggregatesRetentionDays() int {
+	if globalConfigs == nil {
+		return FromEnvs().HourlyAggregatesRetentionDays
+	}
+	return globalConfigs.HourlyAggregatesRetentionDays
+}
+
+func CompactionInterval() time.Duration {
+	if globalConfigs == nil {
+		return FromEnvs().CompactionInterval
+	}
+	return globalConfigs.CompactionInterval
+}
+
+func ClaimSelector() labels.Selector {
+	if globalConfigs == nil {
+		return FromEnvs().ClaimSelector
+	}
+	return globalConfigs.ClaimSelector
+}
+
+func Location() string {
+	if globalConfigs == nil {
+		return FromEnvs().Location
+	}
+	return globalConfigs.Location
+}
+
+func APIAuth() string {
+	if globalConfigs == nil {
+		return FromEnvs().APIAuth
+	}
+	return globalConfigs.APIAuth
+}
+
+func CORSAllowedOrigins() []string {
+	if globalConfigs == nil {
+		return FromEnvs().CORSAllowedOrigins
+	}
+	return globalConfigs.CORSAllowedOrigins
+}
+
+func OIDC() OIDCConfig {
+	if globalConfigs == nil {
+		return FromEnvs().OIDC
+	}
+	return globalConfigs.OIDC
+}
+
+func TenantQuotaFor(tenant string) TenantQuota {
+	if globalConfigs == nil {
+		return FromEnvs().TenantQuotas.For(tenant)
+	}
+	return globalConfigs.TenantQuotas.For(tenant)
+}
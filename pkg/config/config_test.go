@@ -2,9 +2,13 @@ package config
 
 import (
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/back-end-labs/ruok/pkg/auth"
 )
 
 func TestFromEnvsReturnsACopy(t *testing.T) {
@@ -243,6 +247,27 @@ func TestIsInvalidAppName(t *testing.T) {
 	}
 }
 
+func TestIsValidLocation(t *testing.T) {
+	testCases := []struct {
+		location string
+		valid    bool
+	}{
+		{"eu-west", true},
+		{"us_east_1", true},
+		{"Madrid2", true},
+		{"", false},
+		{"eu west", false},
+		{"eu/west", false},
+		{"'; DROP TABLE users; --", false},
+		{strings.Repeat("a", 63), true},
+		{strings.Repeat("a", 64), false},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.valid, IsValidLocation(tc.location), "Unexpected result for location: %q", tc.location)
+	}
+}
+
 func TestParseAlertChannels(t *testing.T) {
 	// Store the original environment variable value
 	originalEnv := os.Getenv(ALERT_CHANNELS)
@@ -289,3 +314,111 @@ func TestParseAlertChannels(t *testing.T) {
 		})
 	}
 }
+
+func TestParseCORSAllowedOrigins(t *testing.T) {
+	originalEnv := os.Getenv(CORS_ALLOWED_ORIGINS)
+	defer os.Setenv(CORS_ALLOWED_ORIGINS, originalEnv)
+
+	tests := []struct {
+		name           string
+		envValue       string
+		expectedResult []string
+	}{
+		{"AnyByDefault", "", []string{"*"}},
+		{"OnlyCommas", " , ,", []string{"*"}},
+		{"List", "https://ruok.example.com, http://localhost:5173", []string{"https://ruok.example.com", "http://localhost:5173"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv(CORS_ALLOWED_ORIGINS, tt.envValue)
+			assert.Equal(t, tt.expectedResult, parseCORSAllowedOrigins())
+		})
+	}
+}
+
+func TestParseOIDCRoles(t *testing.T) {
+	tests := []struct {
+		name           string
+		input          string
+		expectedResult map[string]auth.Scope
+		err            bool
+	}{
+		{"Default", defaultOIDCRoles, map[string]auth.Scope{"ruok-read": auth.ReadScope, "ruok-write": auth.WriteScope, "ruok-admin": auth.AdminScope}, false},
+		{"Spaces", " viewers = read , ", map[string]auth.Scope{"viewers": auth.ReadScope}, false},
+		{"Empty", "", map[string]auth.Scope{}, false},
+		{"MissingScope", "viewers", nil, true},
+		{"MissingRole", "=read", nil, true},
+		{"UnknownScope", "viewers=root", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			roles, err := parseOIDCRoles(tt.input)
+			assert.Equal(t, tt.err, err != nil)
+			assert.Equal(t, tt.expectedResult, roles)
+		})
+	}
+}
+
+func TestParseTenantQuotas(t *testing.T) {
+	quotas, err := parseTenantQuotas("payments=100, *=20", "payments=60,search=300")
+	assert.NoError(t, err)
+	assert.Equal(t, TenantQuota{MaxJobs: 100, MinInterval: time.Minute}, quotas.For("payments"))
+	assert.Equal(t, TenantQuota{MinInterval: 5 * time.Minute}, quotas.For("search"), "listed tenants don't take the limits of *")
+	assert.Equal(t, TenantQuota{MaxJobs: 20}, quotas.For("unlisted"))
+
+	quotas, err = parseTenantQuotas("", "")
+	assert.NoError(t, err)
+	assert.Equal(t, TenantQuota{}, quotas.For("anyone"), "no limits by default")
+
+	_, err = parseTenantQuotas("payments", "")
+	assert.Error(t, err)
+	_, err = parseTenantQuotas("payments=-1", "")
+	assert.Error(t, err)
+	_, err = parseTenantQuotas("", "with space=10")
+	assert.Error(t, err)
+}
+
+func TestParseAPIAuth(t *testing.T) {
+	mode, err := parseAPIAuth("", "")
+	assert.NoError(t, err)
+	assert.Equal(t, KEYS_AUTH, mode, "keys are needed unless told otherwise")
+
+	mode, err = parseAPIAuth(NO_AUTH, "development")
+	assert.NoError(t, err)
+	assert.Equal(t, NO_AUTH, mode)
+	mode, err = parseAPIAuth(OIDC_AUTH, ProdRuokEnvironment)
+	assert.NoError(t, err)
+	assert.Equal(t, OIDC_AUTH, mode)
+
+	_, err = parseAPIAuth(NO_AUTH, ProdRuokEnvironment)
+	assert.Error(t, err, "production always authenticates")
+	_, err = parseAPIAuth("basic", "")
+	assert.Error(t, err)
+}
+
+func TestParseIntEnv(t *testing.T) {
+	originalEnv := os.Getenv(EXECUTION_WORKERS)
+	defer os.Setenv(EXECUTION_WORKERS, originalEnv)
+
+	tests := []struct {
+		name           string
+		envValue       string
+		expectedResult int
+	}{
+		{name: "Default", envValue: "", expectedResult: 100},
+		{name: "Custom", envValue: "25", expectedResult: 25},
+		{name: "WithSpaces", envValue: " 7 ", expectedResult: 7},
+		{name: "Invalid", envValue: "many", expectedResult: 100},
+		{name: "BelowLowest", envValue: "0", expectedResult: 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv(EXECUTION_WORKERS, tt.envValue)
+			result := parseIntEnv(EXECUTION_WORKERS, defaultExecutionWorkers, 1)
+			assert.Equal(t, tt.expectedResult, result)
+		})
+	}
+}
@@ -1,22 +1,119 @@
 package cronParser
 
 import (
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/aptible/supercronic/cronexpr"
 )
 
+// Anything able to tell when a job should run next.
+// Next returns the zero time if there are no more executions.
 type CronExpresion interface {
 	Next(time.Time) time.Time
 }
 
 type ParseFn func(cronLine string) (CronExpresion, error)
 
+// Parses any of the supported schedule kinds:
+//
+//   - intervals: "every 30s"
+//
+//   - one-shot timestamps: "at 2024-06-10T09:00:00Z"
+//
+//   - calendar rules: "RRULE:FREQ=DAILY;BYHOUR=9"
+//
+//   - cron expressions: "0 9 * * *"
 func Parse(cronLine string) (CronExpresion, error) {
+	line := strings.TrimSpace(cronLine)
+	switch {
+	case strings.HasPrefix(line, intervalPrefix):
+		return parseInterval(line)
+	case strings.HasPrefix(line, oneShotPrefix):
+		return parseOneShot(line)
+	case strings.HasPrefix(strings.ToUpper(line), rrulePrefix):
+		return parseRRule(line)
+	}
 	return cronexpr.Parse(cronLine)
 }
 
+// Returns TRUE if the expression is INVALID
 func IsValidExpression(cronLine string) bool {
-	_, err := cronexpr.Parse(cronLine)
+	_, err := Parse(cronLine)
 	return err != nil
 }
+
+// Returns up to n upcoming execution times after "from"
+func NextN(expr CronExpresion, from time.Time, n int) []time.Time {
+	times := []time.Time{}
+	next := from
+	for i := 0; i < n; i++ {
+		next = expr.Next(next)
+		if next.IsZero() {
+			break
+		}
+		times = append(times, next)
+	}
+	return times
+}
+
+// Returns the location for an IANA timezone name. An empty name means UTC.
+// "Local" is refused, the same job would run at different times on schedulers in different timezones.
+func LoadLocation(timezone string) (*time.Location, error) {
+	if timezone == "" {
+		return time.UTC, nil
+	}
+	if timezone == "Local" {
+		return nil, fmt.Errorf("timezone %q depends on the scheduler, use an IANA name instead", timezone)
+	}
+	return time.LoadLocation(timezone)
+}
+
+// Returns TRUE if the timezone is a known IANA name (or empty, meaning UTC)
+func IsValidTimezone(timezone string) bool {
+	_, err := LoadLocation(timezone)
+	return err == nil
+}
+
+// An expression whose wall clock fields are evaluated in a given location
+type locatedExpression struct {
+	expr CronExpresion
+	loc  *time.Location
+}
+
+// Evaluates the wrapped expression using the wall clock of the location.
+//
+// DST transitions are handled like this:
+//
+//   - Wall clock times skipped when clocks go forward fire at the first instant after the gap
+//     (eg: "30 2 * * *" fires at 03:30 that day).
+//
+//   - Wall clock times repeated when clocks go back fire only once, never going back in time.
+func (le *locatedExpression) Next(t time.Time) time.Time {
+	next := le.expr.Next(t.In(le.loc))
+	// An ambiguous wall clock time may resolve to an instant that already passed.
+	// Every call moves the wall clock forward, so we will eventually leave the repeated hour.
+	for !next.IsZero() && !next.After(t) {
+		next = le.expr.Next(next)
+	}
+	return next
+}
+
+// Wraps an expression so it is evaluated in the given location instead of the process local time
+func InLocation(expr CronExpresion, loc *time.Location) CronExpresion {
+	return &locatedExpression{expr: expr, loc: loc}
+}
+
+// Shortest time between two of the next n executions after "from".
+// Zero when the expression runs less than twice.
+func ShortestGap(expr CronExpresion, from time.Time, n int) time.Duration {
+	var shortest time.Duration
+	times := NextN(expr, from, n)
+	for i := 1; i < len(times); i++ {
+		if gap := times[i].Sub(times[i-1]); shortest == 0 || gap < shortest {
+			shortest = gap
+		}
+	}
+	return shortest
+}
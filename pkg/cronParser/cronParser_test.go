@@ -0,0 +1,183 @@
+package cronParser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		line        string
+		shouldError bool
+	}{
+		{"* * * * *", false},
+		{"every 30s", false},
+		{"every 1h30m", false},
+		{"every 500ms", true},
+		{"every 1.5s", true},
+		{"every often", true},
+		{"at 2024-06-10T09:00:00Z", false},
+		{"at 2024-06-10T09:00:00+02:00", false},
+		{"at 2024-06-10 09:00", false},
+		{"at tomorrow", true},
+		{"RRULE:FREQ=DAILY;BYHOUR=9;BYMINUTE=30", false},
+		{"rrule:freq=weekly;interval=2;byday=mo,fr", false},
+		{"RRULE:FREQ=MONTHLY;BYMONTHDAY=-1", false},
+		{"RRULE:BYHOUR=9", true},
+		{"RRULE:FREQ=SECONDLY", true},
+		{"RRULE:FREQ=DAILY;COUNT=3", true},
+		{"RRULE:FREQ=DAILY;BYHOUR=24", true},
+		{"RRULE:FREQ=DAILY;INTERVAL=0", true},
+		{"RRULE:FREQ=WEEKLY;BYDAY=XX", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			_, err := Parse(tt.line)
+			assert.Equal(t, tt.shouldError, err != nil, "unexpected result. error=%v", err)
+			assert.Equal(t, tt.shouldError, IsValidExpression(tt.line))
+		})
+	}
+}
+
+func TestNextN(t *testing.T) {
+	from := time.Date(2024, 6, 10, 9, 0, 10, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		line     string
+		n        int
+		expected []time.Time
+	}{
+		{
+			name: "IntervalIsAligned",
+			line: "every 30s",
+			n:    3,
+			expected: []time.Time{
+				time.Date(2024, 6, 10, 9, 0, 30, 0, time.UTC),
+				time.Date(2024, 6, 10, 9, 1, 0, 0, time.UTC),
+				time.Date(2024, 6, 10, 9, 1, 30, 0, time.UTC),
+			},
+		},
+		{
+			name:     "OneShotFiresOnce",
+			line:     "at 2024-06-10T10:00:00Z",
+			n:        3,
+			expected: []time.Time{time.Date(2024, 6, 10, 10, 0, 0, 0, time.UTC)},
+		},
+		{
+			name:     "OneShotInThePast",
+			line:     "at 2024-06-10T09:00:00Z",
+			n:        3,
+			expected: []time.Time{},
+		},
+		{
+			name: "RRuleWeekdays",
+			line: "RRULE:FREQ=WEEKLY;BYDAY=MO,FR;BYHOUR=9;BYMINUTE=30",
+			n:    3,
+			expected: []time.Time{
+				time.Date(2024, 6, 10, 9, 30, 0, 0, time.UTC),
+				time.Date(2024, 6, 14, 9, 30, 0, 0, time.UTC),
+				time.Date(2024, 6, 17, 9, 30, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "RRuleLastDayOfMonth",
+			line: "RRULE:FREQ=MONTHLY;BYMONTHDAY=-1;BYHOUR=23",
+			n:    2,
+			expected: []time.Time{
+				time.Date(2024, 6, 30, 23, 0, 0, 0, time.UTC),
+				time.Date(2024, 7, 31, 23, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "RRuleEveryOtherDay",
+			line: "RRULE:FREQ=DAILY;INTERVAL=2;BYHOUR=8",
+			n:    2,
+			expected: []time.Time{
+				time.Date(2024, 6, 12, 8, 0, 0, 0, time.UTC),
+				time.Date(2024, 6, 14, 8, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "RRuleHourly",
+			line: "RRULE:FREQ=HOURLY;INTERVAL=6;BYMINUTE=15",
+			n:    2,
+			expected: []time.Time{
+				time.Date(2024, 6, 10, 12, 15, 0, 0, time.UTC),
+				time.Date(2024, 6, 10, 18, 15, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "RRuleUntil",
+			line: "RRULE:FREQ=DAILY;BYHOUR=9;UNTIL=20240612T000000Z",
+			n:    5,
+			expected: []time.Time{
+				time.Date(2024, 6, 11, 9, 0, 0, 0, time.UTC),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := Parse(tt.line)
+			assert.NoError(t, err)
+			next := NextN(expr, from, tt.n)
+			assert.Len(t, next, len(tt.expected))
+			for i := range tt.expected {
+				assert.True(t, tt.expected[i].Equal(next[i]), "expected %v, got %v", tt.expected[i], next[i])
+			}
+		})
+	}
+}
+
+func TestOneShotUsesTheJobTimezone(t *testing.T) {
+	madrid, err := time.LoadLocation("Europe/Madrid")
+	if err != nil {
+		t.Skipf("timezone database not available. error=%q", err)
+	}
+	expr, err := Parse("at 2024-06-10 09:00")
+	assert.NoError(t, err)
+
+	next := InLocation(expr, madrid).Next(time.Date(2024, 6, 10, 0, 0, 0, 0, time.UTC))
+	assert.True(t, time.Date(2024, 6, 10, 7, 0, 0, 0, time.UTC).Equal(next), "got %v", next)
+}
+
+func TestLoadLocation(t *testing.T) {
+	loc, err := LoadLocation("")
+	assert.NoError(t, err)
+	assert.Equal(t, time.UTC, loc)
+	_, err = LoadLocation("Local")
+	assert.Error(t, err, "the timezone of the process is not allowed")
+	assert.False(t, IsValidTimezone("Local"))
+	assert.True(t, IsValidTimezone("UTC"))
+}
+
+func TestIsOneShot(t *testing.T) {
+	assert.True(t, IsOneShot("at 2024-06-10T09:00:00Z"))
+	assert.True(t, IsOneShot("  at 2024-06-10 09:00"))
+	assert.False(t, IsOneShot("every 30s"))
+	assert.False(t, IsOneShot("* * * * *"))
+}
+
+func TestShortestGap(t *testing.T) {
+	from := time.Date(2024, 6, 10, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		line     string
+		expected time.Duration
+	}{
+		{"*/5 * * * *", 5 * time.Minute},
+		{"0,1 9 * * *", time.Minute},
+		{"every 30s", 30 * time.Second},
+		{"at 2024-06-11T09:00:00Z", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			expr, err := Parse(tt.line)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, ShortestGap(expr, from, 10))
+		})
+	}
+}
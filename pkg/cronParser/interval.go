@@ -0,0 +1,36 @@
+package cronParser
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const intervalPrefix = "every "
+
+// Fires every fixed amount of time (eg: "every 30s", "every 1h30m").
+//
+// Executions are aligned to multiples of the interval, so the time spent
+// executing a job doesn't make the schedule drift.
+type interval struct {
+	every time.Duration
+}
+
+func (i *interval) Next(t time.Time) time.Time {
+	return t.Truncate(i.every).Add(i.every)
+}
+
+func parseInterval(line string) (CronExpresion, error) {
+	raw := strings.TrimSpace(strings.TrimPrefix(line, intervalPrefix))
+	every, err := time.ParseDuration(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid interval %q: %w", raw, err)
+	}
+	if every < time.Second {
+		return nil, fmt.Errorf("invalid interval %q: must be at least one second", raw)
+	}
+	if every%time.Second != 0 {
+		return nil, fmt.Errorf("invalid interval %q: must be a whole number of seconds", raw)
+	}
+	return &interval{every: every}, nil
+}
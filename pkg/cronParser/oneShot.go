@@ -0,0 +1,56 @@
+package cronParser
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const oneShotPrefix = "at "
+
+// Layouts accepted for one-shot schedules.
+// Timestamps without an offset are read in the timezone of the job.
+var oneShotLayouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+}
+
+// Fires only once (eg: "at 2024-06-10T09:00:00Z", "at 2024-06-10 09:00").
+//
+// After the time has passed Next returns the zero time.
+type oneShot struct {
+	at time.Time
+	// true if the timestamp had no offset and must be read in the location of the job
+	floating bool
+}
+
+func (o *oneShot) Next(t time.Time) time.Time {
+	at := o.at
+	if o.floating {
+		at = time.Date(at.Year(), at.Month(), at.Day(), at.Hour(), at.Minute(), at.Second(), 0, t.Location())
+	}
+	if at.After(t) {
+		return at
+	}
+	return time.Time{}
+}
+
+func parseOneShot(line string) (CronExpresion, error) {
+	raw := strings.TrimSpace(strings.TrimPrefix(line, oneShotPrefix))
+	if at, err := time.Parse(time.RFC3339, raw); err == nil {
+		return &oneShot{at: at}, nil
+	}
+	for _, layout := range oneShotLayouts {
+		if at, err := time.Parse(layout, raw); err == nil {
+			return &oneShot{at: at, floating: true}, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid one-shot timestamp %q, must look like 2006-01-02T15:04:05", raw)
+}
+
+// Returns TRUE if the expression only fires once
+func IsOneShot(line string) bool {
+	return strings.HasPrefix(strings.TrimSpace(line), oneShotPrefix)
+}
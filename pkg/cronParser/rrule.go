@@ -0,0 +1,300 @@
+package cronParser
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const rrulePrefix = "RRULE:"
+
+// How far we look for the next occurrence of a rule. Eight years make sure
+// we find rules that only match on leap days.
+const rruleSearchDays = 8 * 366
+
+const (
+	freqMinutely = "MINUTELY"
+	freqHourly   = "HOURLY"
+	freqDaily    = "DAILY"
+	freqWeekly   = "WEEKLY"
+	freqMonthly  = "MONTHLY"
+	freqYearly   = "YEARLY"
+)
+
+var rruleWeekdays = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+// Weeks are counted from this monday when using INTERVAL with FREQ=WEEKLY
+var firstWeek = time.Date(1969, time.December, 29, 0, 0, 0, 0, time.UTC)
+
+// A calendar based schedule following a subset of RFC 5545 recurrence rules
+// (eg: "RRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR;BYHOUR=9;BYMINUTE=30").
+//
+// Supported parts are FREQ, INTERVAL, UNTIL, BYMONTH, BYMONTHDAY, BYDAY, BYHOUR, BYMINUTE and BYSECOND.
+// As there is no DTSTART, rules behave as if they started on 1970-01-01 00:00:00 in the timezone of the job:
+//
+//   - Missing BYHOUR, BYMINUTE or BYSECOND default to 0 unless FREQ iterates over them.
+//
+//   - FREQ=WEEKLY without BYDAY fires on mondays.
+//
+//   - FREQ=MONTHLY and FREQ=YEARLY without BYMONTHDAY or BYDAY fire on the first day of the month.
+//
+//   - FREQ=YEARLY without BYMONTH fires in january.
+//
+//   - INTERVAL counts periods from 1970 (weeks start on monday).
+type rrule struct {
+	freq       string
+	interval   int
+	until      time.Time
+	byMonth    []int
+	byMonthDay []int
+	byDay      []time.Weekday
+	byHour     []int
+	byMinute   []int
+	bySecond   []int
+}
+
+func (r *rrule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+
+	for i := 0; i < rruleSearchDays; i++ {
+		y, m, d := day.Date()
+		if r.matchesDay(y, m, d) {
+			for _, h := range r.hours(y, m, d) {
+				for _, minute := range r.minutes(y, m, d, h) {
+					for _, s := range r.seconds() {
+						next := time.Date(y, m, d, h, minute, s, 0, loc)
+						if !next.After(t) {
+							continue
+						}
+						if !r.until.IsZero() && next.After(r.until) {
+							return time.Time{}
+						}
+						return next
+					}
+				}
+			}
+		}
+		day = time.Date(y, m, d+1, 0, 0, 0, 0, loc)
+		if !r.until.IsZero() && day.After(r.until) {
+			return time.Time{}
+		}
+	}
+	return time.Time{}
+}
+
+// Number of days since 1970-01-01 for a date, regardless of its location
+func daysSinceEpoch(y int, m time.Month, d int) int {
+	return int(time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Unix() / 86400)
+}
+
+func floorMod(a int, b int) int {
+	return ((a % b) + b) % b
+}
+
+func (r *rrule) matchesDay(y int, m time.Month, d int) bool {
+	date := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+
+	months := r.byMonth
+	if len(months) == 0 && r.freq == freqYearly {
+		months = []int{1}
+	}
+	if len(months) > 0 && !containsInt(int(m), months) {
+		return false
+	}
+
+	monthDays := r.byMonthDay
+	if len(monthDays) == 0 && len(r.byDay) == 0 && (r.freq == freqMonthly || r.freq == freqYearly) {
+		monthDays = []int{1}
+	}
+	if len(monthDays) > 0 {
+		// negative days count from the end of the month
+		daysInMonth := time.Date(y, m+1, 0, 0, 0, 0, 0, time.UTC).Day()
+		if !containsInt(d, monthDays) && !containsInt(d-daysInMonth-1, monthDays) {
+			return false
+		}
+	}
+
+	weekdays := r.byDay
+	if len(weekdays) == 0 && r.freq == freqWeekly {
+		weekdays = []time.Weekday{time.Monday}
+	}
+	if len(weekdays) > 0 && !containsWeekday(date.Weekday(), weekdays) {
+		return false
+	}
+
+	switch r.freq {
+	case freqYearly:
+		return floorMod(y-1970, r.interval) == 0
+	case freqMonthly:
+		return floorMod((y-1970)*12+int(m)-1, r.interval) == 0
+	case freqWeekly:
+		weeks := int(date.Sub(firstWeek).Hours()) / (24 * 7)
+		return floorMod(weeks, r.interval) == 0
+	case freqDaily:
+		return floorMod(daysSinceEpoch(y, m, d), r.interval) == 0
+	}
+	return true
+}
+
+func (r *rrule) hours(y int, m time.Month, d int) []int {
+	if r.freq != freqHourly && r.freq != freqMinutely {
+		return orDefault(r.byHour, []int{0})
+	}
+	candidates := orDefault(r.byHour, sequence(24))
+	if r.freq == freqMinutely {
+		return candidates
+	}
+	hours := []int{}
+	for _, h := range candidates {
+		if floorMod(daysSinceEpoch(y, m, d)*24+h, r.interval) == 0 {
+			hours = append(hours, h)
+		}
+	}
+	return hours
+}
+
+func (r *rrule) minutes(y int, m time.Month, d int, h int) []int {
+	if r.freq != freqMinutely {
+		return orDefault(r.byMinute, []int{0})
+	}
+	minutes := []int{}
+	for _, minute := range orDefault(r.byMinute, sequence(60)) {
+		if floorMod((daysSinceEpoch(y, m, d)*24+h)*60+minute, r.interval) == 0 {
+			minutes = append(minutes, minute)
+		}
+	}
+	return minutes
+}
+
+func (r *rrule) seconds() []int {
+	return orDefault(r.bySecond, []int{0})
+}
+
+func orDefault(values []int, defaultValues []int) []int {
+	if len(values) > 0 {
+		return values
+	}
+	return defaultValues
+}
+
+func sequence(n int) []int {
+	s := make([]int, n)
+	for i := range s {
+		s[i] = i
+	}
+	return s
+}
+
+func containsInt(x int, arr []int) bool {
+	for _, v := range arr {
+		if v == x {
+			return true
+		}
+	}
+	return false
+}
+
+func containsWeekday(x time.Weekday, arr []time.Weekday) bool {
+	for _, v := range arr {
+		if v == x {
+			return true
+		}
+	}
+	return false
+}
+
+func parseIntList(key string, raw string, lowest int, highest int) ([]int, error) {
+	values := []int{}
+	for _, v := range strings.Split(raw, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil || n < lowest || n > highest || n == 0 && lowest < 0 {
+			return nil, fmt.Errorf("invalid %s value %q", key, v)
+		}
+		values = append(values, n)
+	}
+	sort.Ints(values)
+	return values, nil
+}
+
+func parseUntil(raw string) (time.Time, error) {
+	for _, layout := range []string{"20060102T150405Z", "20060102T150405", "20060102"} {
+		if until, err := time.Parse(layout, raw); err == nil {
+			return until, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid UNTIL value %q", raw)
+}
+
+func parseRRule(line string) (CronExpresion, error) {
+	raw := strings.TrimSpace(line)[len(rrulePrefix):]
+	r := &rrule{interval: 1}
+
+	for _, part := range strings.Split(raw, ";") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid rule part %q", part)
+		}
+		key = strings.ToUpper(strings.TrimSpace(key))
+		value = strings.ToUpper(strings.TrimSpace(value))
+
+		var err error
+		switch key {
+		case "FREQ":
+			switch value {
+			case freqMinutely, freqHourly, freqDaily, freqWeekly, freqMonthly, freqYearly:
+				r.freq = value
+			default:
+				return nil, fmt.Errorf("unsupported FREQ %q", value)
+			}
+		case "INTERVAL":
+			r.interval, err = strconv.Atoi(value)
+			if err != nil || r.interval < 1 {
+				return nil, fmt.Errorf("invalid INTERVAL %q", value)
+			}
+		case "UNTIL":
+			r.until, err = parseUntil(value)
+		case "BYMONTH":
+			r.byMonth, err = parseIntList(key, value, 1, 12)
+		case "BYMONTHDAY":
+			r.byMonthDay, err = parseIntList(key, value, -31, 31)
+		case "BYHOUR":
+			r.byHour, err = parseIntList(key, value, 0, 23)
+		case "BYMINUTE":
+			r.byMinute, err = parseIntList(key, value, 0, 59)
+		case "BYSECOND":
+			r.bySecond, err = parseIntList(key, value, 0, 59)
+		case "BYDAY":
+			for _, v := range strings.Split(value, ",") {
+				weekday, ok := rruleWeekdays[strings.TrimSpace(v)]
+				if !ok {
+					return nil, fmt.Errorf("invalid BYDAY value %q", v)
+				}
+				r.byDay = append(r.byDay, weekday)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported rule part %q", key)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if r.freq == "" {
+		return nil, fmt.Errorf("rule %q must have a FREQ", raw)
+	}
+	return r, nil
+}
@@ -0,0 +1,168 @@
+// Keeps the jobs in sync with a directory of definitions, like the ones "ruok jobs export" writes.
+// The jobs it creates are tagged with v1.ManagedLabel, the ones without the label are never changed.
+package gitops
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	v1 "github.com/back-end-labs/ruok/pkg/api/v1"
+	"github.com/back-end-labs/ruok/pkg/storage"
+)
+
+// Recorded as the author of the changes made by a sync
+var caller = "sync"
+
+// Extensions of the files read, with the format they are in
+var formats = map[string]string{".yaml": v1.FormatYAML, ".yml": v1.FormatYAML, ".json": v1.FormatJSON}
+
+// Reads the definitions of every yaml and json file in dir and its subdirectories as a single document.
+// Hidden files and directories, like .git, are skipped. A directory without definitions is an error,
+// so a wrong path doesn't delete every job; a file with "jobs: {}" does.
+func ReadDir(dir string) (*v1.Definitions, error) {
+	d := &v1.Definitions{Version: v1.DefinitionsVersion, Jobs: map[string]v1.JobDefinition{}}
+	// file every key was read from
+	sources := map[string]string{}
+	files := 0
+
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != dir && strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		format, ok := formats[strings.ToLower(filepath.Ext(path))]
+		if entry.IsDir() || !ok {
+			return nil
+		}
+
+		files++
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		file, err := v1.DecodeDefinitions(data, format)
+		if err != nil {
+			return fmt.Errorf("could not read %s: %w", path, err)
+		}
+		if file.Version != v1.DefinitionsVersion {
+			return fmt.Errorf("could not read %s: only version %d of the definitions can be synced", path, v1.DefinitionsVersion)
+		}
+		for key, definition := range file.Jobs {
+			if other, ok := sources[key]; ok {
+				return fmt.Errorf("the key %q is defined in %s and %s", key, other, path)
+			}
+			sources[key] = path
+			d.Jobs[key] = definition
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if files == 0 {
+		return nil, fmt.Errorf("there are no definition files in %s", dir)
+	}
+	return d, nil
+}
+
+// Definitions that can't be synced, nothing was changed
+type InvalidError struct {
+	Errors []v1.FieldError
+}
+
+func (e *InvalidError) Error() string {
+	fields := make([]string, 0, len(e.Errors))
+	for _, f := range e.Errors {
+		fields = append(fields, f.Field+": "+f.Message)
+	}
+	return fmt.Sprintf("%d fields are not valid, %s", len(e.Errors), strings.Join(fields, "; "))
+}
+
+type Syncer struct {
+	Dir     string
+	Storage storage.APIStorage
+	// Plans are written here before they are applied, they are logged when nil
+	Out io.Writer
+	// Only plan, never apply
+	DryRun bool
+}
+
+// Makes the jobs match the definitions in the directory once, returning what was planned
+func (s *Syncer) Once() (*v1.ImportPlan, error) {
+	return s.sync(false)
+}
+
+// Syncs every interval until the context is done. Errors are logged and the next sync tries again,
+// plans without changes are not reported.
+func (s *Syncer) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.sync(true); err != nil {
+			log.Error().Err(err).Msgf("could not sync the jobs with %s, will try again later", s.Dir)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Syncer) sync(quiet bool) (*v1.ImportPlan, error) {
+	d, err := ReadDir(s.Dir)
+	if err != nil {
+		return nil, err
+	}
+	plan, fieldErrors, err := v1.PlanSync(s.Storage, d)
+	if err != nil {
+		return nil, err
+	}
+	if len(fieldErrors) > 0 {
+		return nil, &InvalidError{fieldErrors}
+	}
+
+	changes := len(plan.Changes) - plan.Count(v1.ChangeUnchanged)
+	if changes > 0 || !quiet {
+		s.report(plan)
+	}
+	if s.DryRun || changes == 0 {
+		return plan, nil
+	}
+	if err := plan.Apply(s.Storage, caller); err != nil {
+		log.Error().Msgf("%d of %d changes could not be synced with %s, the rest were", plan.Failed(), changes, s.Dir)
+		return plan, err
+	}
+	log.Info().Msgf("synced %d jobs with %s", changes, s.Dir)
+	return plan, nil
+}
+
+func (s *Syncer) report(plan *v1.ImportPlan) {
+	if s.Out != nil {
+		plan.Print(s.Out)
+		return
+	}
+	for _, change := range plan.Changes {
+		if change.Action == v1.ChangeUnchanged {
+			continue
+		}
+		fields := make([]string, 0, len(change.Diff))
+		for _, diff := range change.Diff {
+			fields = append(fields, diff.Field)
+		}
+		log.Info().Str("key", change.Key).Str("action", change.Action).Strs("fields", fields).Msg("sync plan")
+	}
+}
@@ -0,0 +1,162 @@
+package gitops
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	v1 "github.com/back-end-labs/ruok/pkg/api/v1"
+	"github.com/back-end-labs/ruok/pkg/labels"
+	"github.com/back-end-labs/ruok/pkg/storage"
+)
+
+var checkout = `
+version: 1
+jobs:
+  checkout:
+    name: Checkout
+    cronexp: "*/5 * * * *"
+    endpoint: http://example.com/checkout
+    httpmethod: GET
+    successStatuses: [200]
+`
+
+var search = `{"version": 1, "jobs": {"search": {"name": "Search", "cronexp": "*/10 * * * *", "endpoint": "http://example.com/search", "httpmethod": "GET", "successStatuses": [200]}}}`
+
+func writeFile(t *testing.T, path string, content string) {
+	assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+	assert.Nil(t, os.WriteFile(path, []byte(content), 0644))
+}
+
+func managedIds(t *testing.T, s storage.APIStorage) int {
+	selector, err := labels.Parse(v1.ManagedLabel + "=" + v1.ManagedBySync)
+	assert.Nil(t, err)
+	return len(s.GetJobIds(selector))
+}
+
+func TestReadDir(t *testing.T) {
+	dir := t.TempDir()
+	_, err := ReadDir(dir)
+	assert.ErrorContains(t, err, "there are no definition files", "an empty directory doesn't delete every job")
+
+	writeFile(t, filepath.Join(dir, "checkout.yaml"), checkout)
+	writeFile(t, filepath.Join(dir, "more", "search.json"), search)
+	writeFile(t, filepath.Join(dir, ".git", "old.yaml"), "not: definitions")
+	writeFile(t, filepath.Join(dir, "README.md"), "# monitors")
+	d, err := ReadDir(dir)
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	assert.Len(t, d.Jobs, 2)
+	assert.Equal(t, "Search", d.Jobs["search"].Name)
+
+	writeFile(t, filepath.Join(dir, "copy.yml"), checkout)
+	_, err = ReadDir(dir)
+	assert.ErrorContains(t, err, `the key "checkout" is defined in`)
+	assert.Nil(t, os.Remove(filepath.Join(dir, "copy.yml")))
+
+	writeFile(t, filepath.Join(dir, "old.yaml"), "version: 2\njobs: {}\n")
+	_, err = ReadDir(dir)
+	assert.ErrorContains(t, err, "only version 1")
+}
+
+func TestSync(t *testing.T) {
+	s, close := storage.NewMemoryStorage()
+	defer close()
+	manual, err := s.CreateJob(storage.CreateJobInput{
+		Name:            "Manual",
+		CronExpString:   "*/5 * * * *",
+		Endpoint:        "http://example.com",
+		HttpMethod:      "GET",
+		SuccessStatuses: []int{200},
+	})
+	assert.NoError(t, err)
+
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "checkout.yaml"), checkout)
+	writeFile(t, filepath.Join(dir, "search.json"), search)
+	out := &bytes.Buffer{}
+	syncer := &Syncer{Dir: dir, Storage: s, Out: out, DryRun: true}
+
+	plan, err := syncer.Once()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, plan.Count(v1.ChangeCreate))
+	assert.Contains(t, out.String(), "2 to create, 0 to update, 0 to delete, 0 unchanged")
+	assert.Len(t, s.GetJobIds(nil), 1, "dry runs change nothing")
+
+	syncer.DryRun = false
+	_, err = syncer.Once()
+	assert.Nil(t, err)
+	assert.Len(t, s.GetJobIds(nil), 3)
+	assert.Equal(t, 2, managedIds(t, s), "the jobs sync creates are tagged")
+
+	plan, err = syncer.Once()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, plan.Count(v1.ChangeUnchanged))
+
+	writeFile(t, filepath.Join(dir, "search.json"), strings.Replace(search, "*/10", "*/15", 1))
+	assert.Nil(t, os.Remove(filepath.Join(dir, "checkout.yaml")))
+	writeFile(t, filepath.Join(dir, "empty.yaml"), "version: 1\njobs: {}\n")
+	plan, err = syncer.Once()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, plan.Count(v1.ChangeUpdate))
+	assert.Equal(t, 1, plan.Count(v1.ChangeDelete))
+	assert.Equal(t, 1, managedIds(t, s), "jobs whose definition is gone are deleted")
+	assert.Equal(t, "deleted", s.GetJobUpdates(*plan.Changes[0].Id).Status, "deletes are soft")
+	assert.Equal(t, "*/15 * * * *", s.GetJobUpdates(*plan.Changes[1].Id).Cron_exp_string)
+
+	ids := s.GetJobIds(nil)
+	assert.Contains(t, ids, manual, "jobs sync doesn't own are never deleted")
+	assert.Equal(t, "*/5 * * * *", s.GetJobUpdates(manual).Cron_exp_string)
+}
+
+func TestSync_Invalid(t *testing.T) {
+	s, close := storage.NewMemoryStorage()
+	defer close()
+	_, err := s.CreateJob(storage.CreateJobInput{
+		Name:            "Manual",
+		CronExpString:   "*/5 * * * *",
+		Endpoint:        "http://example.com",
+		HttpMethod:      "GET",
+		SuccessStatuses: []int{200},
+		Labels:          map[string]string{v1.KeyLabel: "checkout"},
+	})
+	assert.NoError(t, err)
+
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "checkout.yaml"), checkout)
+	writeFile(t, filepath.Join(dir, "search.json"), search)
+	_, err = (&Syncer{Dir: dir, Storage: s}).Once()
+	invalid := &InvalidError{}
+	if assert.True(t, errors.As(err, &invalid)) {
+		assert.Equal(t, "jobs.checkout", invalid.Errors[0].Field, "jobs created by hand are not taken over")
+	}
+	assert.Len(t, s.GetJobIds(nil), 1, "nothing is synced when a definition is not valid")
+}
+
+func TestSync_HiddenJobs(t *testing.T) {
+	s, close := storage.NewSQLiteStorage(filepath.Join(t.TempDir(), "ruok.db"))
+	defer close()
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "checkout.yaml"), checkout)
+	syncer := &Syncer{Dir: dir, Storage: s}
+	_, err := syncer.Once()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, managedIds(t, s))
+
+	// the way schedulers see the jobs another one claimed, they are not there
+	_, err = s.Db.Exec("UPDATE ruok.jobs SET claimed_by = 'other-scheduler', status = 'claimed'")
+	assert.Nil(t, err)
+	assert.Empty(t, s.GetJobIds(nil))
+
+	_, err = syncer.Once()
+	assert.ErrorIs(t, err, storage.ErrHiddenJobs)
+	var count int
+	assert.Nil(t, s.Db.QueryRow("SELECT count(*) FROM ruok.jobs").Scan(&count))
+	assert.Equal(t, 1, count, "jobs other schedulers claimed are not created again")
+}
@@ -0,0 +1,100 @@
+package job
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+
+	"github.com/gofrs/uuid"
+)
+
+// What was done to a job, recorded in its history
+const (
+	ActionCreate  = "create"
+	ActionUpdate  = "update"
+	ActionAlerts  = "update alerts"
+	ActionPause   = "pause"
+	ActionResume  = "resume"
+	ActionDelete  = "delete"
+	ActionRestore = "restore"
+)
+
+// What a job looks like at some point, enough to restore it
+type Snapshot struct {
+	Name                 string            `json:"name"`
+	CronExpString        string            `json:"cronexp"`
+	Timezone             string            `json:"timezone"`
+	MaxRetries           int               `json:"maxRetries"`
+	Endpoint             string            `json:"endpoint"`
+	HttpMethod           string            `json:"httpmethod"`
+	SuccessStatuses      []int             `json:"successStatuses"`
+	AlertStrategy        string            `json:"alertStrategy"`
+	AlertMethod          string            `json:"alertMethod"`
+	AlertEndpoint        string            `json:"alertEndpoint"`
+	AlertPayload         string            `json:"alertPayload"`
+	AlertHeaders         map[string]string `json:"alertHeaders"`
+	Labels               map[string]string `json:"labels"`
+	Locations            []string          `json:"locations"`
+	AlertQuorum          int               `json:"alertQuorum"`
+	ResultsRetentionDays *int              `json:"resultsRetentionDays"`
+	Paused               bool              `json:"paused"`
+	Deleted              bool              `json:"deleted"`
+}
+
+// A change to a job, revisions are numbered from 1 for every job
+type Revision struct {
+	JobId    uuid.UUID `json:"jobId"`
+	Revision int       `json:"revision"`
+	Action   string    `json:"action"`
+	// Who made the change, like "key:deploys"
+	Actor     string `json:"actor"`
+	CreatedAt int64  `json:"createdAt"`
+	// Empty when the job was created
+	Before  *Snapshot     `json:"before"`
+	After   *Snapshot     `json:"after"`
+	Changes []FieldChange `json:"changes"`
+}
+
+// A field whose value changed, named like in the API
+type FieldChange struct {
+	Field  string      `json:"field"`
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// Returns the fields that differ between two snapshots sorted by name, every field of "after" when "before" is nil
+func Diff(before *Snapshot, after *Snapshot) []FieldChange {
+	b := snapshotFields(before)
+	a := snapshotFields(after)
+
+	fields := []string{}
+	for field := range a {
+		fields = append(fields, field)
+	}
+	for field := range b {
+		if _, ok := a[field]; !ok {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+
+	changes := []FieldChange{}
+	for _, field := range fields {
+		if before != nil && reflect.DeepEqual(b[field], a[field]) {
+			continue
+		}
+		changes = append(changes, FieldChange{Field: field, Before: b[field], After: a[field]})
+	}
+	return changes
+}
+
+// The snapshot as the API shows it, so the fields are named and compared like in the JSON
+func snapshotFields(s *Snapshot) map[string]interface{} {
+	fields := map[string]interface{}{}
+	if s == nil {
+		return fields
+	}
+	b, _ := json.Marshal(s)
+	json.Unmarshal(b, &fields)
+	return fields
+}
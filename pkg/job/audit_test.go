@@ -0,0 +1,37 @@
+package job
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	days := 7
+	before := &Snapshot{
+		Name:            "job",
+		CronExpString:   "*/1 * * * *",
+		SuccessStatuses: []int{200},
+		Labels:          map[string]string{"team": "a"},
+	}
+	after := *before
+	after.Name = "renamed"
+	after.Labels = map[string]string{"team": "b"}
+	after.ResultsRetentionDays = &days
+	after.Paused = true
+
+	assert.Equal(t, []FieldChange{
+		{Field: "labels", Before: map[string]interface{}{"team": "a"}, After: map[string]interface{}{"team": "b"}},
+		{Field: "name", Before: "job", After: "renamed"},
+		{Field: "paused", Before: false, After: true},
+		{Field: "resultsRetentionDays", Before: nil, After: float64(7)},
+	}, Diff(before, &after))
+
+	assert.Empty(t, Diff(before, before))
+
+	created := Diff(nil, before)
+	assert.Len(t, created, len(snapshotFields(before)), "every field changes when there is nothing before")
+	for _, c := range created {
+		assert.Nil(t, c.Before)
+	}
+}
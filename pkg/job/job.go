@@ -1,12 +1,14 @@
 package job
 
 import (
+	"hash/fnv"
 	"time"
 
 	"github.com/gofrs/uuid"
 
 	"github.com/back-end-labs/ruok/pkg/alerting/models"
 	"github.com/back-end-labs/ruok/pkg/cronParser"
+	"github.com/back-end-labs/ruok/pkg/maintenance"
 	"github.com/rs/zerolog/log"
 )
 
@@ -19,6 +21,12 @@ type Doer interface {
 	Log()
 }
 
+// Triggers recorded along with an execution result
+const (
+	TriggerSchedule = "schedule"
+	TriggerManual   = "manual"
+)
+
 func Contains(x int, arr []int) bool {
 	var i int
 	for i = 0; i < len(arr); i++ {
@@ -33,12 +41,15 @@ type Handlers struct {
 	ExecuteFn   func(*Job) ExecutionResult
 	OnErrorFn   func(*Job)
 	OnSuccessFn func(*Job)
+	// Returns the maintenance mode that applies to the job right now. Optional.
+	MaintenanceFn func(*Job) string
 }
 type Job struct {
 	Id              uuid.UUID                `json:"id"`
 	Name            string                   `json:"name"`
 	CronExp         cronParser.CronExpresion `json:"-"`
 	CronExpString   string                   `json:"cronexp"`
+	Timezone        string                   `json:"timezone"`
 	LastExecution   time.Time                `json:"lastExecution"`
 	ShouldExecuteAt time.Time                `json:"shouldExecuteAt"`
 	LastResponseAt  time.Time                `json:"lastResponseAt"`
@@ -53,14 +64,36 @@ type Job struct {
 	Status          string                   `json:"status"`
 	ClaimedBy       string                   `json:"claimedBy"`
 	CreatedAt       int                      `json:"createdAt"`
+	UpdatedAt       int64                    `json:"updatedAt"`
 	AlertStrategy   string                   `json:"alertStrategy"`
 	AlertMethod     string                   `json:"alertMethod"`
 	AlertEndpoint   string                   `json:"alertEndpoint"`
 	AlertPayload    string                   `json:"alertPayload"`
 	AlertHeaders    map[string]string        `json:"alertHeaders"`
-	TLSClientCert   string                   `json:"-"`
-	Scheduled       bool                     `json:"-"`
-	AbortChannel    chan struct{}            `json:"-"`
+	Labels          map[string]string        `json:"labels"`
+	// Locations the job runs from, every one of them runs its own copy. Empty runs it once anywhere
+	Locations []string `json:"locations"`
+	// How many locations must fail the same round before alerting, see Quorum
+	AlertQuorum int `json:"alertQuorum"`
+	// Days results are kept before being compacted, nil uses the global setting
+	ResultsRetentionDays *int `json:"resultsRetentionDays,omitempty"`
+	// Location this copy of the job runs from, empty for jobs without locations
+	Location string `json:"location,omitempty"`
+	// Who made the last change through the API, like "key:deploys"
+	UpdatedBy     string        `json:"updatedBy,omitempty"`
+	TLSClientCert string        `json:"-"`
+	Scheduled     bool          `json:"-"`
+	AbortChannel  chan struct{} `json:"-"`
+	// TRUE while the last execution happened inside a "mute" maintenance window
+	Muted bool `json:"-"`
+	// What caused the execution, empty for scheduled ones. See TriggerManual.
+	Trigger string `json:"-"`
+	// Id of the execution result of a manual run, so callers can find it
+	RunId uuid.UUID `json:"-"`
+	// How long the last execution waited for a free worker
+	LastQueueWait time.Duration `json:"-"`
+	// Upper bound of the offset added to every scheduled time to spread executions
+	StartJitter time.Duration `json:"-"`
 
 	Doer     `json:"-"`
 	Handlers Handlers `json:"-"`
@@ -83,10 +116,48 @@ type JobExecution struct {
 	Succeeded       string            `json:"succeeded"`
 	Status          string            `json:"status"`
 	ClaimedBy       string            `json:"claimedBy"`
+	Trigger         string            `json:"trigger"`
+	Location        string            `json:"location,omitempty"`
+	QueueWaitMicro  int64             `json:"queueWaitMicro"`
 	CreatedAt       int               `json:"createdAt"`
 	DeletedAt       int               `json:"deletedAt,omitempty"`
 }
 
+// Execution results of a job compacted into an hourly or daily bucket
+type ResultAggregate struct {
+	BucketStart     time.Time `json:"bucketStart"`
+	Granularity     string    `json:"granularity"`
+	Executions      int       `json:"executions"`
+	Failures        int       `json:"failures"`
+	P50LatencyMicro *int64    `json:"p50LatencyMicro"`
+	P95LatencyMicro *int64    `json:"p95LatencyMicro"`
+}
+
+// Rounds of jobs that won't run again, like one-shots, are assumed to last this long
+var oneShotRound = time.Minute
+
+// How many locations must fail the same round before alerting, at least one
+func (j *Job) Quorum() int {
+	if j.AlertQuorum < 1 {
+		return 1
+	}
+	return j.AlertQuorum
+}
+
+// Start of the round of executions the last one belongs to.
+// Every location runs the job at about the same time, so results of other locations
+// after this point are about the same round. Half the time between executions leaves room for skew.
+func (j *Job) RoundStart() time.Time {
+	var next time.Time
+	if j.CronExp != nil {
+		next = j.CronExp.Next(j.LastExecution)
+	}
+	if next.IsZero() {
+		return j.LastExecution.Add(-oneShotRound)
+	}
+	return j.LastExecution.Add(-next.Sub(j.LastExecution) / 2)
+}
+
 func (j *Job) IsSuccess(x int) bool {
 	return Contains(x, j.SuccessStatuses)
 }
@@ -97,13 +168,36 @@ func (j *Job) InitExpression(parseFn cronParser.ParseFn) error {
 		log.Error().Err(err).Msgf("error while parsing expression for job %v", j.Id)
 		return err
 	}
-	j.CronExp = expr
+	loc, err := cronParser.LoadLocation(j.Timezone)
+	if err != nil {
+		log.Error().Err(err).Msgf("error while loading timezone %q for job %v", j.Timezone, j.Id)
+		return err
+	}
+	j.CronExp = cronParser.InLocation(expr, loc)
 	return nil
 }
 
+// Returns when the job should run next, or false if it won't run again
+func (j *Job) NextExecution(now time.Time) (time.Time, bool) {
+	nextExecution := j.CronExp.Next(now)
+	if nextExecution.IsZero() {
+		if !cronParser.IsOneShot(j.CronExpString) {
+			return time.Time{}, false
+		}
+		// The time of a one-shot job passed before we could run it
+		nextExecution = now
+	}
+	return nextExecution.Add(j.jitterOffset(nextExecution)), true
+}
+
+// Waits for the next execution on its own timer. The scheduler keeps all jobs in a single timer queue instead.
 func (j *Job) Schedule(notifier chan uuid.UUID) string {
 	now := time.Now()
-	nextExecution := j.CronExp.Next(now)
+	nextExecution, ok := j.NextExecution(now)
+	if !ok {
+		log.Info().Msgf("job %v has no more executions", j.Id)
+		return "exhausted"
+	}
 	log.Info().Msgf("next execution of job %v will be at %q", j.Id, nextExecution.String())
 	timer := time.After(nextExecution.Sub(now))
 	select {
@@ -111,30 +205,79 @@ func (j *Job) Schedule(notifier chan uuid.UUID) string {
 		return "aborted"
 
 	case executionTime := <-timer:
-		result := j.Execute()
-		j.LastResponseAt = result.ResponseTime
-		j.LastExecution = executionTime
-		j.LastMessage = result.Message
-		j.LastStatusCode = result.Status
-		if j.IsSuccess(result.Status) {
-			j.Succeeded = "ok"
-			j.OnSuccess()
-		} else {
-			j.Succeeded = "error"
-			j.OnError()
-		}
-		notifier <- j.Id
+		j.Fire(executionTime, notifier)
 	}
 
 	return "re-schedule"
 
 }
 
+// Runs the job unless a skip maintenance window is active, then lets the notifier know it is done
+func (j *Job) Fire(executionTime time.Time, notifier chan uuid.UUID) {
+	mode := j.MaintenanceMode()
+	if mode == maintenance.Skip {
+		log.Info().Msgf("skipping execution of job %v because of a maintenance window", j.Id)
+		notifier <- j.Id
+		return
+	}
+	j.Muted = mode == maintenance.Mute
+	j.run(executionTime)
+	notifier <- j.Id
+}
+
+// A stable offset derived from the job id, lower than StartJitter and than the time between two executions
+func (j *Job) jitterOffset(next time.Time) time.Duration {
+	if j.StartJitter <= 0 {
+		return 0
+	}
+	limit := j.StartJitter
+	if following := j.CronExp.Next(next); !following.IsZero() && following.Sub(next) < limit {
+		limit = following.Sub(next)
+	}
+	if limit <= 0 {
+		return 0
+	}
+	h := fnv.New64a()
+	h.Write(j.Id.Bytes())
+	return time.Duration(h.Sum64() % uint64(limit))
+}
+
+// Executes the job and triggers the success or error handlers
+func (j *Job) run(executionTime time.Time) ExecutionResult {
+	result := j.Execute()
+	j.LastResponseAt = result.ResponseTime
+	j.LastExecution = executionTime
+	j.LastMessage = result.Message
+	j.LastStatusCode = result.Status
+	j.LastQueueWait = result.QueueWait
+	if j.IsSuccess(result.Status) {
+		j.Succeeded = "ok"
+		j.OnSuccess()
+	} else {
+		j.Succeeded = "error"
+		j.OnError()
+	}
+	return result
+}
+
+// Executes a copy of the job right away, leaving its schedule untouched.
+// Skip maintenance windows are ignored because the run was explicitly requested.
+// Nothing else may change the job while it is copied, the scheduler calls it on a copy of its own.
+func (j *Job) RunNow(runId uuid.UUID) ExecutionResult {
+	manual := *j
+	manual.Trigger = TriggerManual
+	manual.RunId = runId
+	manual.Muted = j.MaintenanceMode() == maintenance.Mute
+	return manual.run(time.Now())
+}
+
 type ExecutionResult struct {
 	Status         int       `json:"status"`
 	Message        string    `json:"message"`
 	ResponseTime   time.Time `json:"responseTime"`
 	SchedulerError string    `json:"schedulerError"`
+	// Time spent waiting for a free worker before the request was sent
+	QueueWait time.Duration `json:"-"`
 }
 
 func (j *Job) Execute() ExecutionResult {
@@ -145,6 +288,29 @@ func (j *Job) OnError() {
 	j.Handlers.OnErrorFn(j)
 }
 
+func (j *Job) MaintenanceMode() string {
+	if j.Handlers.MaintenanceFn == nil {
+		return maintenance.None
+	}
+	return j.Handlers.MaintenanceFn(j)
+}
+
+// The status recorded along with an execution result
+func (j *Job) ResultStatus() string {
+	if j.Muted {
+		return "maintenance"
+	}
+	return j.Status
+}
+
+// The trigger recorded along with an execution result
+func (j *Job) ResultTrigger() string {
+	if j.Trigger == "" {
+		return TriggerSchedule
+	}
+	return j.Trigger
+}
+
 func (j *Job) OnSuccess() {
 	j.Handlers.OnSuccessFn(j)
 }
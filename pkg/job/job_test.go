@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/back-end-labs/ruok/pkg/cronParser"
+	"github.com/back-end-labs/ruok/pkg/maintenance"
 	"github.com/gofrs/uuid"
 	"github.com/stretchr/testify/assert"
 )
@@ -206,3 +207,193 @@ func TestAbortJob(t *testing.T) {
 	wg.Wait()
 	assert.False(t, executorTriggered)
 }
+
+func TestInitExpressionWithTimezone(t *testing.T) {
+	madrid, err := time.LoadLocation("Europe/Madrid")
+	if err != nil {
+		t.Skipf("timezone database not available. error=%q", err)
+	}
+
+	tests := []struct {
+		name     string
+		cronexp  string
+		timezone string
+		from     time.Time
+		expected time.Time
+	}{
+		{
+			name:     "DefaultsToUTC",
+			cronexp:  "0 9 * * *",
+			timezone: "",
+			from:     time.Date(2024, 6, 10, 10, 0, 0, 0, time.UTC),
+			expected: time.Date(2024, 6, 11, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "WallClockOfTheTimezone",
+			cronexp:  "0 9 * * *",
+			timezone: "Europe/Madrid",
+			from:     time.Date(2024, 6, 10, 6, 0, 0, 0, time.UTC),
+			expected: time.Date(2024, 6, 10, 9, 0, 0, 0, madrid),
+		},
+		{
+			name:     "SkippedTimeFiresAfterTheGap",
+			cronexp:  "30 2 * * *",
+			timezone: "Europe/Madrid",
+			from:     time.Date(2024, 3, 31, 0, 0, 0, 0, madrid),
+			expected: time.Date(2024, 3, 31, 3, 30, 0, 0, madrid),
+		},
+		{
+			name:     "RepeatedTimeFiresOnce",
+			cronexp:  "*/15 * * * *",
+			timezone: "Europe/Madrid",
+			// 02:10 CEST, the first time the wall clock goes through 02:10 that day
+			from:     time.Date(2024, 10, 27, 0, 10, 0, 0, time.UTC),
+			expected: time.Date(2024, 10, 27, 1, 15, 0, 0, time.UTC),
+		},
+		{
+			name:     "RepeatedTimeNeverGoesBack",
+			cronexp:  "*/15 * * * *",
+			timezone: "Europe/Madrid",
+			// 02:50 CET, after the wall clock went through 02:00-03:00 twice
+			from:     time.Date(2024, 10, 27, 1, 50, 0, 0, time.UTC),
+			expected: time.Date(2024, 10, 27, 2, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j := Job{CronExpString: tt.cronexp, Timezone: tt.timezone}
+			err := j.InitExpression(cronParser.Parse)
+			assert.NoError(t, err)
+			next := j.CronExp.Next(tt.from)
+			assert.True(t, next.After(tt.from), "next execution should be after %v, got %v", tt.from, next)
+			assert.True(t, tt.expected.Equal(next), "expected %v, got %v", tt.expected, next)
+		})
+	}
+
+	t.Run("InvalidTimezone", func(t *testing.T) {
+		j := Job{CronExpString: "* * * * *", Timezone: "Mars/Olympus_Mons"}
+		assert.Error(t, j.InitExpression(cronParser.Parse))
+	})
+}
+
+func TestScheduleDuringMaintenance(t *testing.T) {
+	tests := []struct {
+		name                 string
+		mode                 string
+		shouldExecute        bool
+		expectedResultStatus string
+	}{
+		{"NotInMaintenance", maintenance.None, true, "claimed"},
+		{"Skip", maintenance.Skip, false, ""},
+		{"Mute", maintenance.Mute, true, "maintenance"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, _ := uuid.NewV7()
+			executorTriggered := false
+			ch := make(chan uuid.UUID)
+			j := &Job{
+				Id:              id,
+				Status:          "claimed",
+				Scheduled:       true,
+				AbortChannel:    make(chan struct{}),
+				SuccessStatuses: []int{200},
+				Handlers: Handlers{
+					OnErrorFn:   func(j *Job) {},
+					OnSuccessFn: func(j *Job) {},
+					ExecuteFn: func(j *Job) ExecutionResult {
+						executorTriggered = true
+						return ExecutionResult{Status: 200, Message: "200"}
+					},
+					MaintenanceFn: func(j *Job) string { return tt.mode },
+				},
+			}
+			j.InitExpression(scheduleRightNowFn)
+
+			wg := sync.WaitGroup{}
+			wg.Add(1)
+			go func() {
+				status := j.Schedule(ch)
+				assert.Equal(t, "re-schedule", status)
+				wg.Done()
+			}()
+			assert.Equal(t, id, <-ch)
+			wg.Wait()
+
+			assert.Equal(t, tt.shouldExecute, executorTriggered)
+			if tt.shouldExecute {
+				assert.Equal(t, tt.expectedResultStatus, j.ResultStatus())
+			}
+		})
+	}
+}
+
+func TestRunNow(t *testing.T) {
+	id, _ := uuid.NewV7()
+	runId, _ := uuid.NewV7()
+	var handled *Job
+
+	j := &Job{
+		Id:              id,
+		SuccessStatuses: []int{200},
+		Handlers: Handlers{
+			OnErrorFn:   func(j *Job) { handled = j },
+			OnSuccessFn: func(j *Job) { handled = j },
+			ExecuteFn: func(j *Job) ExecutionResult {
+				return ExecutionResult{Status: 200, Message: "OK"}
+			},
+			MaintenanceFn: func(j *Job) string { return maintenance.Skip },
+		},
+	}
+
+	result := j.RunNow(runId)
+
+	assert.Equal(t, 200, result.Status)
+	assert.NotNil(t, handled, "manual runs should ignore skip windows")
+	assert.Equal(t, TriggerManual, handled.ResultTrigger())
+	assert.Equal(t, runId, handled.RunId)
+	assert.Equal(t, "ok", handled.Succeeded)
+
+	// the scheduled job is left untouched
+	assert.Equal(t, TriggerSchedule, j.ResultTrigger())
+	assert.Equal(t, uuid.Nil, j.RunId)
+	assert.True(t, j.LastExecution.IsZero())
+	assert.Equal(t, "", j.Succeeded)
+}
+
+func TestJitterOffset(t *testing.T) {
+	j := Job{Id: uuid.Must(uuid.NewV7()), CronExpString: "*/5 * * * *"}
+	assert.NoError(t, j.InitExpression(cronParser.Parse))
+	next := j.CronExp.Next(time.Now())
+
+	assert.Equal(t, time.Duration(0), j.jitterOffset(next), "no jitter configured")
+
+	j.StartJitter = time.Minute
+	offset := j.jitterOffset(next)
+	assert.GreaterOrEqual(t, offset, time.Duration(0))
+	assert.Less(t, offset, time.Minute)
+	assert.Equal(t, offset, j.jitterOffset(next), "offset should be stable for the same job")
+
+	j.StartJitter = time.Hour
+	assert.Less(t, j.jitterOffset(next), 5*time.Minute, "offset should not reach the following execution")
+}
+
+func TestQuorum(t *testing.T) {
+	assert.Equal(t, 1, (&Job{}).Quorum())
+	assert.Equal(t, 1, (&Job{AlertQuorum: -1}).Quorum())
+	assert.Equal(t, 2, (&Job{AlertQuorum: 2}).Quorum())
+}
+
+func TestRoundStart(t *testing.T) {
+	executedAt := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+
+	j := &Job{CronExpString: "*/10 * * * *", LastExecution: executedAt}
+	assert.NoError(t, j.InitExpression(cronParser.Parse))
+	assert.Equal(t, executedAt.Add(-5*time.Minute), j.RoundStart())
+
+	oneShot := &Job{CronExpString: "at 2020-01-01T00:00:00Z", LastExecution: executedAt}
+	assert.NoError(t, oneShot.InitExpression(cronParser.Parse))
+	assert.Equal(t, executedAt.Add(-oneShotRound), oneShot.RoundStart())
+}
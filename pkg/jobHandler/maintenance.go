@@ -0,0 +1,14 @@
+package jobhandler
+
+import (
+	"time"
+
+	"github.com/back-end-labs/ruok/pkg/job"
+	"github.com/back-end-labs/ruok/pkg/maintenance"
+)
+
+func MaintenanceHandler(c *maintenance.Calendar) func(j *job.Job) string {
+	return func(j *job.Job) string {
+		return c.ModeFor(j.Id, j.Labels, time.Now())
+	}
+}
@@ -8,7 +8,9 @@ import (
 
 func OnErrorHandler(s storage.SchedulerStorage, am *alerting.AlertManager) func(j *job.Job) {
 	return func(j *job.Job) {
-		_, _ = am.SendAlert(j.AlertingInput())
+		if reachedQuorum(s, j, true) && !j.Muted {
+			_, _ = am.SendAlert(j.AlertingInput())
+		}
 		s.WriteDone(j)
 	}
 }
@@ -8,6 +8,8 @@ import (
 func OnSuccessHandler(s storage.SchedulerStorage) func(j *job.Job) {
 	// we can hook mor functionalities here if we want
 	return func(j *job.Job) {
+		// so the location doesn't count as failing anymore
+		reachedQuorum(s, j, false)
 		s.WriteDone(j)
 	}
 }
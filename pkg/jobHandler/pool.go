@@ -0,0 +1,34 @@
+package jobhandler
+
+import (
+	"net/url"
+	"time"
+
+	"github.com/back-end-labs/ruok/pkg/job"
+	"github.com/back-end-labs/ruok/pkg/pool"
+)
+
+// Runs executions through the pool, so only a bounded amount of them happen at the same time
+func PooledExecutor(p *pool.Pool, execute func(*job.Job) job.ExecutionResult) func(*job.Job) job.ExecutionResult {
+	return func(j *job.Job) job.ExecutionResult {
+		var result job.ExecutionResult
+		var host string
+		if u, err := url.Parse(j.Endpoint); err == nil {
+			host = u.Host
+		}
+		done := make(chan struct{})
+		submitted := p.Submit(host, func(wait time.Duration) {
+			defer close(done)
+			result = execute(j)
+			result.QueueWait = wait
+		})
+		if !submitted {
+			return job.ExecutionResult{
+				ResponseTime:   time.Now(),
+				SchedulerError: "execution pool stopped before running the job",
+			}
+		}
+		<-done
+		return result
+	}
+}
@@ -0,0 +1,30 @@
+package jobhandler
+
+import (
+	"github.com/rs/zerolog/log"
+
+	"github.com/back-end-labs/ruok/pkg/job"
+	"github.com/back-end-labs/ruok/pkg/storage"
+)
+
+// Records the result of an execution from a location and tells if it should alert.
+// Only the failure that makes the failing locations of the round reach the quorum alerts,
+// so a probe with network issues doesn't page anyone and an outage pages once per round.
+// Jobs without locations and manual runs alert on every failure.
+func reachedQuorum(s storage.SchedulerStorage, j *job.Job, failed bool) bool {
+	if j.Location == "" || j.Trigger == job.TriggerManual {
+		return failed
+	}
+	failing, err := s.RecordLocationResult(storage.LocationResult{
+		JobId:      j.Id,
+		Location:   j.Location,
+		Failed:     failed,
+		ExecutedAt: j.LastExecution,
+	}, j.RoundStart())
+	if err != nil {
+		// better to alert from every location than to miss an outage
+		log.Error().Err(err).Msgf("could not check the alert quorum of job %v, alerting anyway", j.Id)
+		return failed
+	}
+	return failed && failing == j.Quorum()
+}
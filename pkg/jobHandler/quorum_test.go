@@ -0,0 +1,51 @@
+package jobhandler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/back-end-labs/ruok/pkg/cronParser"
+	"github.com/back-end-labs/ruok/pkg/job"
+	"github.com/back-end-labs/ruok/pkg/storage"
+)
+
+func TestReachedQuorum(t *testing.T) {
+	s, closeStorage := storage.NewMemoryStorage()
+	defer closeStorage()
+	_, err := s.CreateJob(storage.CreateJobInput{
+		Name:          "located job",
+		CronExpString: "*/10 * * * *",
+		Locations:     []string{"madrid", "paris", "tokyo"},
+		AlertQuorum:   2,
+	})
+	assert.NoError(t, err)
+	jobId := s.GetJobIds(nil)[0]
+
+	probe := func(location string, executedAt time.Time) *job.Job {
+		j := &job.Job{Id: jobId, CronExpString: "*/10 * * * *", Location: location, AlertQuorum: 2, LastExecution: executedAt}
+		assert.NoError(t, j.InitExpression(cronParser.Parse))
+		return j
+	}
+	round := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+
+	// a single location failing is a problem of the probe
+	assert.False(t, reachedQuorum(s, probe("madrid", round), true))
+	assert.False(t, reachedQuorum(s, probe("paris", round.Add(time.Second)), false))
+	// the failure reaching the quorum alerts, the ones after it don't alert again
+	assert.True(t, reachedQuorum(s, probe("tokyo", round.Add(2*time.Second)), true))
+	assert.False(t, reachedQuorum(s, probe("paris", round.Add(3*time.Second)), true))
+
+	// failures of the previous round don't count
+	next := round.Add(10 * time.Minute)
+	assert.False(t, reachedQuorum(s, probe("madrid", next), true))
+	assert.True(t, reachedQuorum(s, probe("paris", next.Add(time.Second)), true))
+
+	// manual runs and jobs without locations alert on every failure
+	manual := probe("madrid", next)
+	manual.Trigger = job.TriggerManual
+	assert.True(t, reachedQuorum(s, manual, true))
+	assert.True(t, reachedQuorum(s, &job.Job{Id: jobId}, true))
+	assert.False(t, reachedQuorum(s, &job.Job{Id: jobId}, false))
+}
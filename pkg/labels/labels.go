@@ -0,0 +1,167 @@
+package labels
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Operators of a requirement
+const (
+	Equals    = "="
+	NotEquals = "!="
+	Exists    = "exists"
+	NotExists = "!exists"
+)
+
+// Upper bounds for keys and values, like kubernetes labels
+var maxKeyLength = 63
+var maxValueLength = 63
+
+// How many labels a single job can have
+var MaxLabels = 64
+
+var keyPattern = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9._/-]*[A-Za-z0-9])?$`)
+var valuePattern = regexp.MustCompile(`^([A-Za-z0-9]([A-Za-z0-9._-]*[A-Za-z0-9])?)?$`)
+
+// A single condition on the labels of a job, Value is empty for Exists and NotExists
+type Requirement struct {
+	Key      string `json:"key"`
+	Operator string `json:"operator"`
+	Value    string `json:"value,omitempty"`
+}
+
+func (r Requirement) Matches(labels map[string]string) bool {
+	value, ok := labels[r.Key]
+	switch r.Operator {
+	case Equals:
+		return ok && value == r.Value
+	case NotEquals:
+		return !ok || value != r.Value
+	case Exists:
+		return ok
+	case NotExists:
+		return !ok
+	}
+	return false
+}
+
+func (r Requirement) String() string {
+	switch r.Operator {
+	case Exists:
+		return r.Key
+	case NotExists:
+		return "!" + r.Key
+	}
+	return r.Key + r.Operator + r.Value
+}
+
+// Requirements a job must meet all at once. An empty (or nil) selector matches every job.
+type Selector []Requirement
+
+// Parses selectors like "env=prod,team!=payments,zone,!legacy".
+// "key=value" (or "key==value") and "key!=value" compare the value, "key" and "!key" check that the label is there or not.
+func Parse(selector string) (Selector, error) {
+	s := Selector{}
+	if strings.TrimSpace(selector) == "" {
+		return s, nil
+	}
+	for _, part := range strings.Split(selector, ",") {
+		part = strings.TrimSpace(part)
+		var r Requirement
+		switch {
+		case strings.Contains(part, "!="):
+			key, value, _ := strings.Cut(part, "!=")
+			r = Requirement{Key: key, Operator: NotEquals, Value: value}
+		case strings.Contains(part, "=="):
+			key, value, _ := strings.Cut(part, "==")
+			r = Requirement{Key: key, Operator: Equals, Value: value}
+		case strings.Contains(part, "="):
+			key, value, _ := strings.Cut(part, "=")
+			r = Requirement{Key: key, Operator: Equals, Value: value}
+		case strings.HasPrefix(part, "!"):
+			r = Requirement{Key: strings.TrimPrefix(part, "!"), Operator: NotExists}
+		default:
+			r = Requirement{Key: part, Operator: Exists}
+		}
+		r.Key = strings.TrimSpace(r.Key)
+		r.Value = strings.TrimSpace(r.Value)
+		if err := validKey(r.Key); err != nil {
+			return nil, fmt.Errorf("invalid requirement %q: %w", part, err)
+		}
+		if err := validValue(r.Value); err != nil {
+			return nil, fmt.Errorf("invalid requirement %q: %w", part, err)
+		}
+		s = append(s, r)
+	}
+	return s, nil
+}
+
+// TRUE if the labels meet every requirement
+func (s Selector) Matches(labels map[string]string) bool {
+	for _, r := range s {
+		if !r.Matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s Selector) Empty() bool {
+	return len(s) == 0
+}
+
+// The selector in the format Parse reads
+func (s Selector) String() string {
+	parts := make([]string, len(s))
+	for i, r := range s {
+		parts[i] = r.String()
+	}
+	return strings.Join(parts, ",")
+}
+
+// Returns the first problem found with the labels of a job, sorted by key so errors are stable
+func Validate(labels map[string]string) error {
+	if len(labels) > MaxLabels {
+		return fmt.Errorf("a job can't have more than %d labels", MaxLabels)
+	}
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := validKey(key); err != nil {
+			return fmt.Errorf("invalid label %q: %w", key, err)
+		}
+		if err := validValue(labels[key]); err != nil {
+			return fmt.Errorf("invalid label %q: %w", key, err)
+		}
+	}
+	return nil
+}
+
+func validKey(key string) error {
+	if key == "" {
+		return errors.New("key can't be empty")
+	}
+	if len(key) > maxKeyLength {
+		return fmt.Errorf("key can't be longer than %d characters", maxKeyLength)
+	}
+	if !keyPattern.MatchString(key) {
+		return errors.New("key must start and end with a letter or a number and only have letters, numbers, '.', '_', '-' or '/'")
+	}
+	return nil
+}
+
+func validValue(value string) error {
+	if len(value) > maxValueLength {
+		return fmt.Errorf("value can't be longer than %d characters", maxValueLength)
+	}
+	if !valuePattern.MatchString(value) {
+		return errors.New("value must start and end with a letter or a number and only have letters, numbers, '.', '_' or '-'")
+	}
+	return nil
+}
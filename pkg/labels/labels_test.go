@@ -0,0 +1,101 @@
+package labels
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		selector string
+		expected Selector
+		err      bool
+	}{
+		{"", Selector{}, false},
+		{"  ", Selector{}, false},
+		{"env=prod", Selector{{"env", Equals, "prod"}}, false},
+		{"env==prod", Selector{{"env", Equals, "prod"}}, false},
+		{"env!=prod", Selector{{"env", NotEquals, "prod"}}, false},
+		{"zone", Selector{{"zone", Exists, ""}}, false},
+		{"!legacy", Selector{{"legacy", NotExists, ""}}, false},
+		{"env = prod, team!=payments ,zone,!legacy", Selector{
+			{"env", Equals, "prod"},
+			{"team", NotEquals, "payments"},
+			{"zone", Exists, ""},
+			{"legacy", NotExists, ""},
+		}, false},
+		{"env=", Selector{{"env", Equals, ""}}, false},
+		{"example.com/zone=eu-west-1", Selector{{"example.com/zone", Equals, "eu-west-1"}}, false},
+		{"=prod", nil, true},
+		{"env=prod,", nil, true},
+		{"env=pr od", nil, true},
+		{"-env=prod", nil, true},
+		{"env=prod!", nil, true},
+		{strings.Repeat("k", 64) + "=v", nil, true},
+	}
+
+	for _, tt := range tests {
+		s, err := Parse(tt.selector)
+		if tt.err {
+			assert.Error(t, err, tt.selector)
+			continue
+		}
+		assert.NoError(t, err, tt.selector)
+		assert.Equal(t, tt.expected, s, tt.selector)
+	}
+}
+
+func TestSelector_Matches(t *testing.T) {
+	labels := map[string]string{"env": "prod", "team": "payments"}
+	tests := []struct {
+		selector string
+		matches  bool
+	}{
+		{"", true},
+		{"env=prod", true},
+		{"env=dev", false},
+		{"env!=dev", true},
+		{"zone!=eu", true},
+		{"team", true},
+		{"zone", false},
+		{"!zone", true},
+		{"!team", false},
+		{"env=prod,team=payments", true},
+		{"env=prod,team=search", false},
+	}
+
+	for _, tt := range tests {
+		s, err := Parse(tt.selector)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.matches, s.Matches(labels), tt.selector)
+	}
+	assert.True(t, Selector(nil).Matches(nil))
+	assert.False(t, Selector{{"env", Equals, "prod"}}.Matches(nil))
+}
+
+func TestSelector_String(t *testing.T) {
+	s, err := Parse("env==prod, team!=payments,zone,!legacy")
+	assert.NoError(t, err)
+	assert.Equal(t, "env=prod,team!=payments,zone,!legacy", s.String())
+
+	again, err := Parse(s.String())
+	assert.NoError(t, err)
+	assert.Equal(t, s, again)
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Validate(nil))
+	assert.NoError(t, Validate(map[string]string{"env": "prod", "example.com/team": "payments", "empty": ""}))
+	assert.Error(t, Validate(map[string]string{"": "prod"}))
+	assert.Error(t, Validate(map[string]string{"env": "has spaces"}))
+	assert.Error(t, Validate(map[string]string{"env": strings.Repeat("v", 64)}))
+
+	tooMany := map[string]string{}
+	for i := 0; i <= MaxLabels; i++ {
+		tooMany[fmt.Sprintf("key%d", i)] = "v"
+	}
+	assert.Error(t, Validate(tooMany))
+}
@@ -0,0 +1,154 @@
+package maintenance
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/rs/zerolog/log"
+
+	"github.com/back-end-labs/ruok/pkg/cronParser"
+	"github.com/back-end-labs/ruok/pkg/labels"
+)
+
+// Maintenance modes
+const (
+	// Not in maintenance, run and alert as usual
+	None = ""
+	// Do not execute the job at all
+	Skip = "skip"
+	// Execute the job but do not send alerts
+	Mute = "mute"
+)
+
+// A period of time in which jobs shouldn't be checked (Skip) or shouldn't alert (Mute).
+//
+// Recurring windows use a cron expression plus a duration, the rest use an absolute range.
+// Windows without job ids nor selector apply to every job, the rest apply to the jobs listed and the jobs
+// whose labels match the selector.
+type Window struct {
+	Id              uuid.UUID                `json:"id"`
+	Name            string                   `json:"name"`
+	Mode            string                   `json:"mode"`
+	CronExpString   string                   `json:"cronexp,omitempty"`
+	CronExp         cronParser.CronExpresion `json:"-"`
+	Timezone        string                   `json:"timezone,omitempty"`
+	DurationSeconds int                      `json:"durationSeconds,omitempty"`
+	StartsAt        time.Time                `json:"startsAt"`
+	EndsAt          time.Time                `json:"endsAt"`
+	JobIds          []uuid.UUID              `json:"jobIds"`
+	Selector        string                   `json:"selector,omitempty"`
+	LabelSelector   labels.Selector          `json:"-"`
+	CreatedAt       int                      `json:"createdAt"`
+}
+
+func (w *Window) IsRecurring() bool {
+	return w.CronExpString != ""
+}
+
+// Parses the cron expression of recurring windows in their own timezone
+func (w *Window) InitExpression(parseFn cronParser.ParseFn) error {
+	if !w.IsRecurring() {
+		return nil
+	}
+	expr, err := parseFn(w.CronExpString)
+	if err != nil {
+		return err
+	}
+	loc, err := cronParser.LoadLocation(w.Timezone)
+	if err != nil {
+		return err
+	}
+	w.CronExp = cronParser.InLocation(expr, loc)
+	return nil
+}
+
+// Parses the label selector of scoped windows
+func (w *Window) InitSelector() error {
+	selector, err := labels.Parse(w.Selector)
+	if err != nil {
+		return err
+	}
+	w.LabelSelector = selector
+	return nil
+}
+
+// Returns TRUE if "t" falls within the window
+func (w *Window) Active(t time.Time) bool {
+	if !w.IsRecurring() {
+		return !t.Before(w.StartsAt) && t.Before(w.EndsAt)
+	}
+	if w.CronExp == nil || w.DurationSeconds <= 0 {
+		return false
+	}
+	// The window is open if it started during the last "duration"
+	start := w.CronExp.Next(t.Add(-time.Duration(w.DurationSeconds) * time.Second))
+	return !start.IsZero() && !start.After(t)
+}
+
+// Returns TRUE if the window affects the job
+func (w *Window) Applies(jobId uuid.UUID, jobLabels map[string]string) bool {
+	if len(w.JobIds) == 0 && w.Selector == "" {
+		return true
+	}
+	for _, id := range w.JobIds {
+		if id == jobId {
+			return true
+		}
+	}
+	// a selector that was not parsed matches nothing rather than every job
+	return w.Selector != "" && w.LabelSelector != nil && w.LabelSelector.Matches(jobLabels)
+}
+
+// Holds the maintenance windows known by the scheduler so jobs can check them before running
+type Calendar struct {
+	lock    *sync.RWMutex
+	windows []*Window
+}
+
+func NewCalendar() *Calendar {
+	return &Calendar{lock: &sync.RWMutex{}, windows: []*Window{}}
+}
+
+// Replaces the known windows. Windows with invalid expressions or selectors are skipped.
+func (c *Calendar) Set(windows []*Window) {
+	valid := []*Window{}
+	for _, w := range windows {
+		if err := w.InitExpression(cronParser.Parse); err != nil {
+			log.Error().Err(err).Msgf("skipping maintenance window %v because we couldn't init cron expression %q", w.Id, w.CronExpString)
+			continue
+		}
+		if err := w.InitSelector(); err != nil {
+			log.Error().Err(err).Msgf("skipping maintenance window %v because we couldn't parse selector %q", w.Id, w.Selector)
+			continue
+		}
+		valid = append(valid, w)
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.windows = valid
+}
+
+// Returns the maintenance mode for a job at a given time. Skip wins over Mute when windows overlap.
+func (c *Calendar) ModeFor(jobId uuid.UUID, jobLabels map[string]string, t time.Time) string {
+	if c == nil {
+		return None
+	}
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	mode := None
+	for _, w := range c.windows {
+		if !w.Applies(jobId, jobLabels) || !w.Active(t) {
+			continue
+		}
+		if w.Mode == Skip {
+			return Skip
+		}
+		mode = Mute
+	}
+	return mode
+}
+
+func IsValidMode(mode string) bool {
+	return mode == Skip || mode == Mute
+}
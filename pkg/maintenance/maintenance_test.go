@@ -0,0 +1,102 @@
+package maintenance
+
+import (
+	"testing"
+	"time"
+
+	"github.com/back-end-labs/ruok/pkg/cronParser"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestActive(t *testing.T) {
+	start := time.Date(2024, 6, 10, 2, 0, 0, 0, time.UTC)
+
+	absolute := &Window{Mode: Skip, StartsAt: start, EndsAt: start.Add(time.Hour)}
+	recurring := &Window{Mode: Skip, CronExpString: "0 2 * * *", DurationSeconds: 3600}
+	assert.NoError(t, recurring.InitExpression(cronParser.Parse))
+
+	tests := []struct {
+		name     string
+		t        time.Time
+		expected bool
+	}{
+		{"BeforeStart", start.Add(-time.Second), false},
+		{"AtStart", start, true},
+		{"Inside", start.Add(30 * time.Minute), true},
+		{"AtEnd", start.Add(time.Hour), false},
+		{"NextDayInside", start.Add(24*time.Hour + time.Minute), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, recurring.Active(tt.t), "recurring window")
+			if tt.name != "NextDayInside" {
+				assert.Equal(t, tt.expected, absolute.Active(tt.t), "absolute window")
+			}
+		})
+	}
+
+	assert.False(t, absolute.Active(start.Add(24*time.Hour+time.Minute)))
+}
+
+func TestActiveInTimezone(t *testing.T) {
+	w := &Window{Mode: Mute, CronExpString: "0 2 * * *", Timezone: "Europe/Madrid", DurationSeconds: 1800}
+	if err := w.InitExpression(cronParser.Parse); err != nil {
+		t.Skipf("timezone database not available. error=%q", err)
+	}
+	// 02:00 CEST is 00:00 UTC
+	assert.True(t, w.Active(time.Date(2024, 6, 10, 0, 10, 0, 0, time.UTC)))
+	assert.False(t, w.Active(time.Date(2024, 6, 10, 2, 10, 0, 0, time.UTC)))
+}
+
+func TestModeFor(t *testing.T) {
+	job1, _ := uuid.NewV7()
+	job2, _ := uuid.NewV7()
+	now := time.Now()
+
+	c := NewCalendar()
+	assert.Equal(t, None, c.ModeFor(job1, nil, now))
+
+	c.Set([]*Window{
+		{Mode: Mute, StartsAt: now.Add(-time.Minute), EndsAt: now.Add(time.Minute)},
+		{Mode: Skip, StartsAt: now.Add(-time.Minute), EndsAt: now.Add(time.Minute), JobIds: []uuid.UUID{job2}},
+		{Mode: Skip, CronExpString: "not a cron", DurationSeconds: 60},
+	})
+
+	assert.Equal(t, Mute, c.ModeFor(job1, nil, now))
+	assert.Equal(t, Skip, c.ModeFor(job2, nil, now))
+	assert.Equal(t, None, c.ModeFor(job1, nil, now.Add(time.Hour)))
+
+	var nilCalendar *Calendar
+	assert.Equal(t, None, nilCalendar.ModeFor(job1, nil, now))
+}
+
+func TestModeFor_Selector(t *testing.T) {
+	job1, _ := uuid.NewV7()
+	job2, _ := uuid.NewV7()
+	now := time.Now()
+	eu := map[string]string{"zone": "eu"}
+
+	c := NewCalendar()
+	c.Set([]*Window{
+		{Mode: Skip, StartsAt: now.Add(-time.Minute), EndsAt: now.Add(time.Minute), Selector: "zone=eu"},
+		{Mode: Mute, StartsAt: now.Add(-time.Minute), EndsAt: now.Add(time.Minute), Selector: "team=payments", JobIds: []uuid.UUID{job2}},
+		{Mode: Skip, StartsAt: now.Add(-time.Minute), EndsAt: now.Add(time.Minute), Selector: "bad key=eu"},
+	})
+
+	assert.Equal(t, Skip, c.ModeFor(job1, eu, now))
+	assert.Equal(t, None, c.ModeFor(job1, map[string]string{"zone": "us"}, now), "windows with a selector don't apply to every job")
+	assert.Equal(t, Mute, c.ModeFor(job1, map[string]string{"team": "payments"}, now))
+	assert.Equal(t, Mute, c.ModeFor(job2, nil, now), "listed jobs are affected whatever their labels")
+	assert.Equal(t, None, c.ModeFor(job1, nil, now))
+	assert.Len(t, c.windows, 2, "windows with invalid selectors are skipped")
+}
+
+func TestApplies_SelectorNotParsed(t *testing.T) {
+	job, _ := uuid.NewV7()
+	w := &Window{Mode: Skip, Selector: "zone=eu"}
+	assert.False(t, w.Applies(job, map[string]string{"zone": "eu"}), "the selector is parsed by InitSelector")
+	assert.NoError(t, w.InitSelector())
+	assert.True(t, w.Applies(job, map[string]string{"zone": "eu"}))
+}
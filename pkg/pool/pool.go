@@ -0,0 +1,105 @@
+package pool
+
+import (
+	"sync"
+	"time"
+)
+
+// A fixed set of workers running tasks, with an optional limit on how many
+// tasks can target the same host at the same time.
+type Pool struct {
+	tasks   chan task
+	perHost int
+	lock    sync.Mutex
+	hosts   map[string]chan struct{}
+	quit    chan struct{}
+	wg      sync.WaitGroup
+	stop    sync.Once
+}
+
+type task struct {
+	host     string
+	queuedAt time.Time
+	fn       func(wait time.Duration)
+}
+
+// Creates a pool with the given amount of workers.
+// A perHost value of 0 means there is no limit per host.
+func New(workers int, perHost int) *Pool {
+	if workers < 1 {
+		workers = 1
+	}
+	p := &Pool{
+		tasks:   make(chan task),
+		perHost: perHost,
+		hosts:   map[string]chan struct{}{},
+		quit:    make(chan struct{}),
+	}
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+	return p
+}
+
+func (p *Pool) work() {
+	defer p.wg.Done()
+	for {
+		select {
+		case <-p.quit:
+			return
+		case t := <-p.tasks:
+			t.fn(time.Since(t.queuedAt))
+			p.releaseHost(t.host)
+		}
+	}
+}
+
+func (p *Pool) hostSlots(host string) chan struct{} {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	slots, ok := p.hosts[host]
+	if !ok {
+		slots = make(chan struct{}, p.perHost)
+		p.hosts[host] = slots
+	}
+	return slots
+}
+
+func (p *Pool) releaseHost(host string) {
+	if p.perHost < 1 {
+		return
+	}
+	<-p.hostSlots(host)
+}
+
+// Blocks until a worker takes the task and a slot for the host is free.
+// fn receives how long the task waited in the queue.
+// Returns false if the pool was stopped before the task could be taken.
+func (p *Pool) Submit(host string, fn func(wait time.Duration)) bool {
+	t := task{host: host, queuedAt: time.Now(), fn: fn}
+	if p.perHost > 0 {
+		select {
+		case <-p.quit:
+			return false
+		case p.hostSlots(host) <- struct{}{}:
+		}
+	}
+	select {
+	case <-p.quit:
+		if p.perHost > 0 {
+			p.releaseHost(host)
+		}
+		return false
+	case p.tasks <- t:
+		return true
+	}
+}
+
+// Stops taking new tasks and waits for the running ones to finish
+func (p *Pool) Stop() {
+	p.stop.Do(func() {
+		close(p.quit)
+	})
+	p.wg.Wait()
+}
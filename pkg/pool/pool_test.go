@@ -0,0 +1,90 @@
+package pool
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Submits n tasks and returns the highest amount of them running at the same time
+func peakConcurrency(p *Pool, hosts []string, n int) int64 {
+	var running, peak atomic.Int64
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		host := hosts[i%len(hosts)]
+		go func() {
+			defer wg.Done()
+			p.Submit(host, func(time.Duration) {
+				now := running.Add(1)
+				for {
+					old := peak.Load()
+					if now <= old || peak.CompareAndSwap(old, now) {
+						break
+					}
+				}
+				time.Sleep(20 * time.Millisecond)
+				running.Add(-1)
+			})
+		}()
+	}
+	wg.Wait()
+	return peak.Load()
+}
+
+func TestPool_LimitsWorkers(t *testing.T) {
+	p := New(3, 0)
+	defer p.Stop()
+	peak := peakConcurrency(p, []string{"a", "b", "c", "d"}, 20)
+	if peak > 3 {
+		t.Errorf("expected at most 3 tasks running at once, got %d", peak)
+	}
+}
+
+func TestPool_LimitsPerHost(t *testing.T) {
+	p := New(10, 2)
+	defer p.Stop()
+	peak := peakConcurrency(p, []string{"same-host"}, 12)
+	if peak > 2 {
+		t.Errorf("expected at most 2 tasks for the same host, got %d", peak)
+	}
+}
+
+func TestPool_ReportsQueueWait(t *testing.T) {
+	p := New(1, 0)
+	defer p.Stop()
+	release := make(chan struct{})
+	started := make(chan struct{})
+	go p.Submit("a", func(time.Duration) {
+		close(started)
+		<-release
+	})
+	<-started
+
+	waits := make(chan time.Duration, 1)
+	done := make(chan struct{})
+	go func() {
+		p.Submit("a", func(wait time.Duration) { waits <- wait })
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	<-done
+
+	if wait := <-waits; wait < 40*time.Millisecond {
+		t.Errorf("expected the second task to wait at least 40ms, got %v", wait)
+	}
+}
+
+func TestPool_SubmitAfterStop(t *testing.T) {
+	p := New(2, 1)
+	p.Stop()
+	ran := false
+	if p.Submit("a", func(time.Duration) { ran = true }) {
+		t.Error("expected submit to fail after the pool was stopped")
+	}
+	if ran {
+		t.Error("task should not run after the pool was stopped")
+	}
+}
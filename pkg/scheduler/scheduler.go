@@ -19,6 +19,9 @@ import (
 	"github.com/back-end-labs/ruok/pkg/cronParser"
 	jobs "github.com/back-end-labs/ruok/pkg/job"
 	jobhandler "github.com/back-end-labs/ruok/pkg/jobHandler"
+	"github.com/back-end-labs/ruok/pkg/labels"
+	"github.com/back-end-labs/ruok/pkg/maintenance"
+	"github.com/back-end-labs/ruok/pkg/pool"
 	"github.com/back-end-labs/ruok/pkg/storage"
 )
 
@@ -46,15 +49,34 @@ type Scheduler struct {
 	parser       cronParser.ParseFn
 	notifier     chan uuid.UUID
 	alertManager *alerting.AlertManager
-	off          bool
+	calendar     *maintenance.Calendar
+	pool         *pool.Pool
+	timers       *timers
+	// Copies of the jobs running right now, guarded by the list lock
+	inFlight map[uuid.UUID]*jobs.Job
+	// Only jobs with matching labels are claimed
+	selector labels.Selector
+	off      bool
 }
 
 func NewScheduler(s storage.SchedulerStorage, am *alerting.AlertManager, jobList *JobsList) *Scheduler {
-	return &Scheduler{l: jobList, storage: s, parser: cronParser.Parse, alertManager: am, off: true}
+	sched := &Scheduler{
+		l:            jobList,
+		storage:      s,
+		parser:       cronParser.Parse,
+		alertManager: am,
+		calendar:     maintenance.NewCalendar(),
+		pool:         pool.New(config.ExecutionWorkers(), config.MaxConcurrencyPerHost()),
+		selector:     config.ClaimSelector(),
+		inFlight:     make(map[uuid.UUID]*jobs.Job),
+		off:          true,
+	}
+	sched.timers = newTimers(sched.fire)
+	return sched
 }
 
 // make sure calling context already has the sched.l.lock locked
-func (sched *Scheduler) initJobList(j []*jobs.Job, notifier chan uuid.UUID) {
+func (sched *Scheduler) initJobList(j []*jobs.Job) {
 	for _, job := range j {
 		err := job.InitExpression(sched.parser)
 		if err != nil {
@@ -62,18 +84,19 @@ func (sched *Scheduler) initJobList(j []*jobs.Job, notifier chan uuid.UUID) {
 			log.Info().Msgf("skipping job %v because we couldn't init cron expression %q", job.Id, job.CronExpString)
 			continue
 		}
-		job.AbortChannel = make(chan struct{})
-		job.Handlers.ExecuteFn = jobhandler.HTTPExecutor
+		job.Handlers.ExecuteFn = jobhandler.PooledExecutor(sched.pool, jobhandler.HTTPExecutor)
+		job.StartJitter = config.StartJitter()
 		job.Handlers.OnSuccessFn = jobhandler.OnSuccessHandler(sched.storage)
 		job.Handlers.OnErrorFn = jobhandler.OnErrorHandler(sched.storage, sched.alertManager)
+		job.Handlers.MaintenanceFn = jobhandler.MaintenanceHandler(sched.calendar)
 		sched.l.list[job.Id] = job
-		go job.Schedule(notifier)
 		job.Scheduled = true
 		config.AppStats.ClaimedJobs++
+		sched.schedule(job)
 	}
 }
 
-func (sched *Scheduler) checkForNewJobs(notifier chan uuid.UUID) {
+func (sched *Scheduler) checkForNewJobs() {
 	sched.l.lock.Lock()
 	defer sched.l.lock.Unlock()
 	freeSpace := config.MaxJobs() - len(sched.l.list)
@@ -81,8 +104,18 @@ func (sched *Scheduler) checkForNewJobs(notifier chan uuid.UUID) {
 		log.Info().Msg("There is no more space for new jobs")
 		return
 	}
-	j := sched.storage.GetAvailableJobs(freeSpace)
-	sched.initJobList(j, notifier)
+	j := sched.storage.GetAvailableJobs(freeSpace, sched.selector)
+	sched.initJobList(j)
+}
+
+// Gets the latest maintenance windows so jobs can check them before running
+func (sched *Scheduler) refreshMaintenanceWindows() {
+	windows := sched.storage.GetMaintenanceWindows()
+	if windows == nil {
+		log.Error().Msg("could not get maintenance windows, keeping the ones we had")
+		return
+	}
+	sched.calendar.Set(windows)
 }
 
 func (sched *Scheduler) Drain() error {
@@ -91,7 +124,7 @@ func (sched *Scheduler) Drain() error {
 	defer sched.l.lock.Unlock()
 	for _, v := range sched.l.list {
 		v.Scheduled = false
-		close(v.AbortChannel)
+		sched.timers.Cancel(v.Id)
 		releaseList = append(releaseList, v)
 	}
 	err := sched.storage.ReleaseAll(releaseList)
@@ -105,9 +138,11 @@ func (sched *Scheduler) Drain() error {
 func (sched *Scheduler) DumpToFile(w io.Writer) error {
 
 	releaseList := []jobs.Job{}
+	sched.l.lock.Lock()
 	for _, v := range sched.l.list {
 		releaseList = append(releaseList, *v)
 	}
+	sched.l.lock.Unlock()
 
 	err := json.NewEncoder(w).Encode(
 		&struct {
@@ -125,9 +160,10 @@ func (sched *Scheduler) DumpToFile(w io.Writer) error {
 
 func (sched *Scheduler) Start(signalsCh chan os.Signal) int {
 	sched.off = false
+	sched.refreshMaintenanceWindows()
 	log.Info().Msg("about to get available jobs to start working :)")
 
-	j := sched.storage.GetAvailableJobs(sched.l.AvailableSpace())
+	j := sched.storage.GetAvailableJobs(sched.l.AvailableSpace(), sched.selector)
 	log.Info().Msgf("got %d jobs", len(j))
 
 	sched.notifier = make(chan uuid.UUID, len(sched.l.list))
@@ -135,13 +171,18 @@ func (sched *Scheduler) Start(signalsCh chan os.Signal) int {
 	log.Info().Msg("About to init all jobs")
 
 	sched.l.lock.Lock()
-	sched.initJobList(j, sched.notifier)
+	sched.initJobList(j)
 	sched.l.lock.Unlock()
 
+	log.Info().Msg("About to start the timers and compaction loops")
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	go sched.timers.Run(backgroundCtx)
+	go sched.runCompaction(backgroundCtx)
+
 	log.Info().Msg("About to spawn 'listen for job updates' gorutine")
-	updatedJobsNotificationsch := make(chan uuid.UUID, 100)
+	notificationsCh := make(chan storage.Notification, 100)
 	updatesListenerCtx, cancelUpdateListener := context.WithCancel(context.Background())
-	sched.storage.ListenForChanges(updatedJobsNotificationsch, updatesListenerCtx)
+	sched.storage.ListenForChanges(notificationsCh, updatesListenerCtx)
 
 	log.Info().Msgf("starting new ticker for poller: %f seconds\n", config.PollingInterval().Seconds())
 
@@ -153,21 +194,27 @@ mainloop:
 		select {
 		case <-pollSignal.C:
 			log.Info().Msg("Tick! time for polling")
-			sched.checkForNewJobs(sched.notifier)
+			sched.refreshMaintenanceWindows()
+			sched.checkForNewJobs()
 
 		case doneJobId := <-sched.notifier:
 			sched.reschedule(doneJobId)
 
-		case updatedJobId := <-updatedJobsNotificationsch:
-			log.Info().Msgf("received signal to re-schedule job %d", updatedJobId)
-			sched.refreshJob(updatedJobId)
+		case notification, ok := <-notificationsCh:
+			if !ok {
+				// a closed channel would be ready forever
+				log.Error().Msg("stopped receiving notifications, relying on polling only")
+				notificationsCh = nil
+				continue
+			}
+			sched.dispatch(notification)
 
 		case <-signalsCh:
 			if sched.off {
 				log.Info().Msg("we are already shutting down")
 			}
 			// TODO: if we couldn't release we should push an alert to some channel
-			exitcode = sched.shutDown(pollSignal, cancelUpdateListener, signalsCh)
+			exitcode = sched.shutDown(pollSignal, stopBackground, cancelUpdateListener, signalsCh)
 			break mainloop
 		}
 	}
@@ -189,13 +236,14 @@ drainloop:
 	return exitcode
 }
 
-// 1. Closes the poll signal, triggers the cancel for the notifications listener, closes the job done notifier.
+// 1. Closes the poll signal, stops the timers and compaction loops, triggers the cancel for the notifications listener, closes the job done notifier.
 //
 // 2. Sends a message to the db to unlisten.
 //
 // 3. Releases all the jobs to the db or write them down to a file if  the db doesn't respond.
 func (sched *Scheduler) shutDown(
 	pollSignal *time.Ticker,
+	stopBackground context.CancelFunc,
 	cancelUpdateListener context.CancelFunc,
 	signalsCh chan os.Signal,
 ) int {
@@ -204,6 +252,9 @@ func (sched *Scheduler) shutDown(
 	log.Info().Msg("about to stop polling ticker")
 	pollSignal.Stop()
 
+	log.Info().Msg("About to stop the timers and compaction loops")
+	stopBackground()
+
 	log.Info().Msg("About to stop listening for changes")
 	sched.storage.StopListeningForChanges()
 
@@ -228,10 +279,12 @@ func (sched *Scheduler) shutDown(
 			return 1
 		}
 		log.Info().Msg("Dumped all jobs into a file")
+		sched.stopPool()
 		return 1
 
 	}
 	log.Info().Msg("Drain operation succeeded")
+	sched.stopPool()
 	return 0
 }
 
@@ -242,38 +295,262 @@ func (sched *Scheduler) reschedule(doneJobId uuid.UUID) {
 	sched.l.lock.Lock()
 	defer sched.l.lock.Unlock()
 	log.Info().Msgf("job %v done!", doneJobId)
+	execution := sched.inFlight[doneJobId]
+	delete(sched.inFlight, doneJobId)
 	job, ok := sched.l.list[doneJobId]
 	if !ok {
 		log.Error().Msgf("jod %v marked as done but can't reschedule because it is not on our job list", doneJobId)
 		return
 	}
+	if execution != nil {
+		keepOutcome(job, execution)
+	}
+	if cronParser.IsOneShot(job.CronExpString) {
+		sched.complete(job)
+		return
+	}
 	log.Info().Msgf("rescheduling job %v", doneJobId)
-	go job.Schedule(sched.notifier)
+	sched.schedule(job)
 }
 
-func (sched *Scheduler) refreshJob(jobId uuid.UUID) {
+// Sets the timer for the next execution of the job.
+// Jobs without more executions, like RRULEs past their UNTIL, are completed so they don't keep their slot.
+//
+// make sure calling context already has the sched.l.lock locked
+func (sched *Scheduler) schedule(job *jobs.Job) {
+	next, ok := job.NextExecution(time.Now())
+	if !ok {
+		log.Info().Msgf("job %v has no more executions", job.Id)
+		sched.timers.Cancel(job.Id)
+		sched.complete(job)
+		return
+	}
+	log.Info().Msgf("next execution of job %v will be at %q", job.Id, next.String())
+	sched.timers.Set(job.Id, next)
+}
+
+// Called by the timers loop when a job is due.
+// The execution runs on its own so a slow one doesn't delay the rest, and on a copy of the job
+// taken under our lock so refreshes and manual runs don't race with the results it writes.
+// reschedule copies the outcome back once it is done. A job still running skips the run.
+func (sched *Scheduler) fire(jobId uuid.UUID) {
+	sched.l.lock.Lock()
+	job, ok := sched.l.list[jobId]
+	if !ok {
+		sched.l.lock.Unlock()
+		log.Error().Msgf("timer of job %v fired but it is not on our job list", jobId)
+		return
+	}
+	if _, running := sched.inFlight[jobId]; running {
+		sched.l.lock.Unlock()
+		log.Info().Msgf("skipping execution of job %v because the previous one is still running", jobId)
+		return
+	}
+	execution := *job
+	sched.inFlight[jobId] = &execution
+	sched.l.lock.Unlock()
+	go execution.Fire(time.Now(), sched.notifier)
+}
+
+// Copies the outcome of an execution to the job on our list
+//
+// make sure calling context already has the sched.l.lock locked
+func keepOutcome(job *jobs.Job, execution *jobs.Job) {
+	job.LastExecution = execution.LastExecution
+	job.LastResponseAt = execution.LastResponseAt
+	job.LastMessage = execution.LastMessage
+	job.LastStatusCode = execution.LastStatusCode
+	job.LastQueueWait = execution.LastQueueWait
+	job.Succeeded = execution.Succeeded
+	job.Muted = execution.Muted
+}
+
+// Drops a job that won't run again from our list and marks it as completed.
+//
+// make sure calling context already has the sched.l.lock locked
+func (sched *Scheduler) complete(job *jobs.Job) {
+	log.Info().Msgf("job %v will not run again, marking it as completed", job.Id)
+	job.Scheduled = false
+	delete(sched.l.list, job.Id)
+	config.AppStats.ClaimedJobs--
+	err := sched.storage.CompleteJob(job.Id)
+	if err != nil {
+		log.Error().Err(err).Msgf("could not mark job %v as completed", job.Id)
+	}
+}
+
+// Acts on a notification received over our channel
+func (sched *Scheduler) dispatch(n storage.Notification) {
+	log.Info().Msgf("received %q event for job %v", n.Event, n.JobId)
+	switch n.Event {
+	case storage.EventUpdated:
+		sched.refreshJob(n.JobId)
+	case storage.EventPaused, storage.EventDeleted:
+		sched.dropJob(n.JobId)
+	case storage.EventRun:
+		sched.runNow(n.JobId, n.RunId)
+	case storage.EventRelease:
+		sched.release(n.JobId)
+	case storage.EventResync:
+		sched.resync()
+	default:
+		log.Error().Msgf("don't know how to handle %q events", n.Event)
+	}
+}
+
+// Compares the last update of our jobs with the db and refreshes the ones that changed.
+// Used after the listener reconnects, as we may have missed some notifications.
+func (sched *Scheduler) resync() {
+	if sched.off {
+		return
+	}
+	sched.l.lock.Lock()
+	known := make(map[uuid.UUID]int64, len(sched.l.list))
+	ids := make([]uuid.UUID, 0, len(sched.l.list))
+	for id, j := range sched.l.list {
+		known[id] = j.UpdatedAt
+		ids = append(ids, id)
+	}
+	sched.l.lock.Unlock()
+
+	latest := sched.storage.GetJobsUpdatedAt(ids)
+	if latest == nil {
+		log.Error().Msg("could not resync jobs, keeping the ones we have")
+		return
+	}
+
+	log.Info().Msgf("resyncing %d jobs", len(ids))
+	for id, updatedAt := range known {
+		latestUpdatedAt, ok := latest[id]
+		if !ok {
+			// someone else claimed it or it is gone
+			sched.dropJob(id)
+			continue
+		}
+		if latestUpdatedAt != updatedAt {
+			sched.refreshJob(id)
+		}
+	}
+	// resumed jobs may be waiting too
+	sched.checkForNewJobs()
+}
+
+// Runs a job outside its schedule without waiting for it
+func (sched *Scheduler) runNow(jobId uuid.UUID, runId uuid.UUID) {
 	if sched.off {
 		return
 	}
-	// Lock for a simple check
 	sched.l.lock.Lock()
 	j, ok := sched.l.list[jobId]
+	if !ok {
+		sched.l.lock.Unlock()
+		log.Error().Msgf("couldn't run job %v because it is not on our job list", jobId)
+		return
+	}
+	// copied under our lock, like scheduled executions
+	manual := *j
 	sched.l.lock.Unlock()
+	go manual.RunNow(runId)
+}
+
+// Drops a job that was paused or deleted
+func (sched *Scheduler) dropJob(jobId uuid.UUID) {
+	if sched.off {
+		return
+	}
+	sched.l.lock.Lock()
+	defer sched.l.lock.Unlock()
+	j, ok := sched.l.list[jobId]
 	if !ok {
-		log.Error().Msgf("couldn't find and update job %d in our list", jobId)
+		log.Error().Msgf("couldn't drop job %v because it is not on our job list", jobId)
 		return
 	}
+	sched.drop(j)
+}
+
+// Stops a job and gives it back so other schedulers can claim it
+func (sched *Scheduler) release(jobId uuid.UUID) {
+	if sched.off {
+		return
+	}
+	sched.l.lock.Lock()
+	defer sched.l.lock.Unlock()
+	j, ok := sched.l.list[jobId]
+	if !ok {
+		log.Error().Msgf("couldn't release job %v because it is not on our job list", jobId)
+		return
+	}
+	err := sched.storage.ReleaseAll([]*jobs.Job{j})
+	if err != nil {
+		log.Error().Err(err).Msgf("could not release job %v, keeping it", jobId)
+		return
+	}
+	sched.drop(j)
+}
+
+// Stops a job and removes it from our list.
+// The job should be already released in the db.
+//
+// make sure calling context already has the sched.l.lock locked
+func (sched *Scheduler) drop(job *jobs.Job) {
+	log.Info().Msgf("dropping job %v", job.Id)
+	job.Scheduled = false
+	sched.timers.Cancel(job.Id)
+	delete(sched.l.list, job.Id)
+	config.AppStats.ClaimedJobs--
+}
+
+func (sched *Scheduler) refreshJob(jobId uuid.UUID) {
+	if sched.off {
+		return
+	}
+	// Lock for a simple check
+	sched.l.lock.Lock()
+	j, ok := sched.l.list[jobId]
+	sched.l.lock.Unlock()
 	updates := sched.storage.GetJobUpdates(jobId)
 	if updates == nil {
 		log.Error().Msg("Received empty updates. Keeping the old job.")
 		return
 	}
+	if !ok {
+		// Resumed jobs are released, so we try to claim them right away
+		if updates.Status == "pending to be claimed" {
+			log.Info().Msgf("job %v is available to be claimed, checking for new jobs", jobId)
+			sched.checkForNewJobs()
+			return
+		}
+		log.Error().Msgf("couldn't find and update job %d in our list", jobId)
+		return
+	}
 
 	// lock for the update
 	sched.l.lock.Lock()
 	defer sched.l.lock.Unlock()
+	if updates.Status == "paused" || updates.Deleted_at != 0 {
+		sched.drop(j)
+		return
+	}
+	if !sched.selector.Matches(updates.Labels) {
+		log.Info().Msgf("labels of job %v don't match our claim selector %q anymore, releasing it", jobId, sched.selector.String())
+		if err := sched.storage.ReleaseAll([]*jobs.Job{j}); err != nil {
+			log.Error().Err(err).Msgf("could not release job %v, keeping it", jobId)
+		} else {
+			sched.drop(j)
+			return
+		}
+	}
+	if !runsFrom(updates.Locations, j.Location) {
+		log.Info().Msgf("job %v doesn't run from our location %q anymore, releasing it", jobId, j.Location)
+		if err := sched.storage.ReleaseAll([]*jobs.Job{j}); err != nil {
+			log.Error().Err(err).Msgf("could not release job %v, keeping it", jobId)
+		} else {
+			sched.drop(j)
+			return
+		}
+	}
 	j.Scheduled = false
-	j.AbortChannel <- struct{}{}
+	sched.timers.Cancel(j.Id)
 	j.Endpoint = updates.Endpoint
 	j.HttpMethod = updates.Httpmethod
 	j.MaxRetries = updates.Max_retries
@@ -281,17 +558,76 @@ func (sched *Scheduler) refreshJob(jobId uuid.UUID) {
 	j.AlertStrategy = updates.Alert_strategy
 	j.AlertEndpoint = updates.Alert_endpoint
 	j.AlertMethod = updates.Alert_method
-	if j.CronExpString != updates.Cron_exp_string {
-		oldExpr := j.CronExpString
+	j.UpdatedAt = updates.Updated_at
+	j.Labels = updates.Labels
+	j.Locations = updates.Locations
+	j.AlertQuorum = updates.Alert_quorum
+	if j.CronExpString != updates.Cron_exp_string || j.Timezone != updates.Timezone {
+		oldExpr, oldTimezone := j.CronExpString, j.Timezone
 		j.CronExpString = updates.Cron_exp_string
+		j.Timezone = updates.Timezone
 		err := j.InitExpression(sched.parser)
 		if err != nil {
-			log.Error().Err(err).Msgf("could not init expression for job %d due to invalid expression", jobId)
+			log.Error().Err(err).Msgf("could not init expression for job %d due to invalid expression or timezone", jobId)
 			j.CronExpString = oldExpr
+			j.Timezone = oldTimezone
 			j.InitExpression(sched.parser)
 		}
 	}
 	j.Scheduled = true
-	go j.Schedule(sched.notifier)
+	if _, running := sched.inFlight[jobId]; running {
+		// reschedule arms it with the updates once the execution is done
+		return
+	}
+	sched.schedule(j)
 
 }
+
+// TRUE if a job claimed from "location" still belongs there. Jobs claimed without a location
+// must not require any, and the ones claimed for a location must keep requiring it.
+func runsFrom(locations []string, location string) bool {
+	if location == "" {
+		return len(locations) == 0
+	}
+	for _, l := range locations {
+		if l == location {
+			return true
+		}
+	}
+	return false
+}
+
+// Waits for running executions and writes their results
+func (sched *Scheduler) stopPool() {
+	log.Info().Msg("About to stop the execution pool")
+	sched.pool.Stop()
+	log.Info().Msg("About to flush buffered results")
+	if err := sched.storage.FlushResults(); err != nil {
+		log.Error().Err(err).Msg("could not flush buffered results, they were spooled")
+	}
+}
+
+// Compacts old execution results until the context is done
+func (sched *Scheduler) runCompaction(ctx context.Context) {
+	ticker := time.NewTicker(config.CompactionInterval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			sched.compactResults()
+		}
+	}
+}
+
+// Rolls results older than their retention into aggregates.
+// Runs even if results are kept forever because it also creates upcoming partitions.
+func (sched *Scheduler) compactResults() {
+	compacted, err := sched.storage.CompactResults(config.ResultsRetentionDays(), config.HourlyAggregatesRetentionDays())
+	if err != nil {
+		log.Error().Err(err).Msg("could not compact job results, will try again later")
+		return
+	}
+	log.Info().Msgf("compacted %d job results", compacted)
+}
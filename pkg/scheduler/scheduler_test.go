@@ -11,9 +11,10 @@ import (
 	"github.com/back-end-labs/ruok/pkg/alerting/models"
 	"github.com/back-end-labs/ruok/pkg/config"
 	"github.com/back-end-labs/ruok/pkg/job"
+	"github.com/back-end-labs/ruok/pkg/labels"
+	"github.com/back-end-labs/ruok/pkg/maintenance"
 	"github.com/back-end-labs/ruok/pkg/storage"
 	"github.com/gofrs/uuid"
-	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/rs/zerolog/log"
 	"github.com/stretchr/testify/assert"
 )
@@ -79,12 +80,12 @@ func TestScheduler_DumpToFile(t *testing.T) {
 }
 
 type mockStorage struct {
-	JobUpdatesCh chan uuid.UUID
+	JobUpdatesCh chan storage.Notification
 }
 
 func NewMockStorage() *mockStorage {
 	return &mockStorage{
-		JobUpdatesCh: make(chan uuid.UUID, 1),
+		JobUpdatesCh: make(chan storage.Notification, 1),
 	}
 }
 
@@ -102,7 +103,7 @@ var id8, _ = uuid.NewV7()
 var id9, _ = uuid.NewV7()
 var id10, _ = uuid.NewV7()
 
-func (ms *mockStorage) GetAvailableJobs(space int) []*job.Job {
+func (ms *mockStorage) GetAvailableJobs(space int, selector labels.Selector) []*job.Job {
 
 	gotAvailableJobs = true
 	return []*job.Job{
@@ -126,10 +127,34 @@ func (ms *mockStorage) ReleaseAll(jobs []*job.Job) error {
 	return nil
 }
 
-func (ms *mockStorage) GetClient() *pgxpool.Pool {
+var completedJobs []uuid.UUID
+
+func (ms *mockStorage) CompleteJob(jobId uuid.UUID) error {
+	completedJobs = append(completedJobs, jobId)
 	return nil
 }
 
+func (ms *mockStorage) GetMaintenanceWindows() []*maintenance.Window {
+	return []*maintenance.Window{}
+}
+
+// Lets tests return specific update times, jobs not in the map are reported as not updated
+var jobsUpdatedAt = map[uuid.UUID]int64{}
+
+// Jobs the mock pretends it can't see anymore
+var hiddenJobs = map[uuid.UUID]bool{}
+
+func (ms *mockStorage) GetJobsUpdatedAt(jobIds []uuid.UUID) map[uuid.UUID]int64 {
+	updatedAt := map[uuid.UUID]int64{}
+	for _, id := range jobIds {
+		if hiddenJobs[id] {
+			continue
+		}
+		updatedAt[id] = jobsUpdatedAt[id]
+	}
+	return updatedAt
+}
+
 func (ms *mockStorage) RegisterSelf() {
 
 }
@@ -138,14 +163,28 @@ func (ms *mockStorage) WriteDone(j *job.Job) error {
 	return nil
 }
 
-func (ms *mockStorage) GetClaimedJobs(limit int, offset int) []*job.Job {
+func (ms *mockStorage) BufferResults(size int, interval time.Duration, spool string) {}
+
+func (ms *mockStorage) RecordLocationResult(r storage.LocationResult, since time.Time) (int, error) {
+	return 0, nil
+}
+
+func (ms *mockStorage) FlushResults() error {
+	return nil
+}
+
+func (ms *mockStorage) CompactResults(retentionDays int, hourlyRetentionDays int) (int64, error) {
+	return 0, nil
+}
+
+func (ms *mockStorage) GetClaimedJobs(limit int, offset int, selector labels.Selector) []*job.Job {
 	return nil
 }
 
 func (ms *mockStorage) GetClaimedJobsExecutions(jobId uuid.UUID, limit int, offset int) []*job.JobExecution {
 	return nil
 }
-func (ms *mockStorage) ListenForChanges(jobIDUpdatedCh chan uuid.UUID, ctx context.Context) {
+func (ms *mockStorage) ListenForChanges(notificationsCh chan storage.Notification, ctx context.Context) {
 	// Simulate sending updates to the provided channel
 	go func() {
 		for {
@@ -153,14 +192,20 @@ func (ms *mockStorage) ListenForChanges(jobIDUpdatedCh chan uuid.UUID, ctx conte
 			case <-ctx.Done():
 				log.Info().Msg("done listening for notifications")
 				return
-			case jobID := <-ms.JobUpdatesCh:
-				jobIDUpdatedCh <- jobID
+			case n := <-ms.JobUpdatesCh:
+				notificationsCh <- n
 			}
 		}
 	}()
 }
 
+// Lets tests return specific updates for a job
+var jobUpdatesOverrides = map[uuid.UUID]*storage.JobUpdates{}
+
 func (ms *mockStorage) GetJobUpdates(jobId uuid.UUID) *storage.JobUpdates {
+	if updates, ok := jobUpdatesOverrides[jobId]; ok {
+		return updates
+	}
 	return &storage.JobUpdates{
 		Cron_exp_string:  "*/5 * * * *",
 		Endpoint:         "/updated",
@@ -195,9 +240,7 @@ func TestScheduler_Start_HappyPath(t *testing.T) {
 
 	releasedJobs = []*job.Job{}
 	mockedJobList = NewJobList(config.MaxJobs())
-	mockedStorage := &mockStorage{
-		JobUpdatesCh: make(chan uuid.UUID, 1),
-	}
+	mockedStorage := NewMockStorage()
 
 	sched := NewScheduler(mockedStorage, mockAlertingManager, mockedJobList)
 
@@ -222,7 +265,7 @@ func TestScheduler_Start_HappyPath(t *testing.T) {
 	sched.l.lock.Unlock()
 
 	updatedJobID := id1
-	mockedStorage.JobUpdatesCh <- updatedJobID
+	mockedStorage.JobUpdatesCh <- storage.NewNotification(storage.EventUpdated, updatedJobID)
 	time.Sleep(10 * time.Millisecond)
 
 	sched.l.lock.Lock()
@@ -267,3 +310,347 @@ func TestScheduler_Start_HappyPath(t *testing.T) {
 	}
 
 }
+
+func TestScheduler_RescheduleCompletesOneShotJobs(t *testing.T) {
+	completedJobs = []uuid.UUID{}
+	oneShotId, _ := uuid.NewV7()
+	cronId, _ := uuid.NewV7()
+
+	sched := NewScheduler(NewMockStorage(), nil, NewJobList(config.MaxJobs()))
+	sched.off = false
+	sched.notifier = make(chan uuid.UUID, 1)
+	sched.l.list[oneShotId] = &job.Job{Id: oneShotId, CronExpString: "at 2020-01-01T00:00:00Z", Scheduled: true}
+	sched.l.list[cronId] = &job.Job{Id: cronId, CronExpString: "10 * * * *"}
+	sched.l.list[cronId].InitExpression(sched.parser)
+
+	sched.reschedule(oneShotId)
+
+	_, ok := sched.l.list[oneShotId]
+	assert.False(t, ok, "one-shot job should be dropped from the list")
+	assert.Contains(t, completedJobs, oneShotId)
+
+	sched.reschedule(cronId)
+	_, ok = sched.l.list[cronId]
+	assert.True(t, ok, "cron job should be kept in the list")
+	assert.NotContains(t, completedJobs, cronId)
+	assert.Equal(t, 1, sched.timers.Len(), "cron job should have a timer for its next execution")
+}
+
+func TestScheduler_CompletesJobsWithoutMoreExecutions(t *testing.T) {
+	completedJobs = []uuid.UUID{}
+	exhaustedId, _ := uuid.NewV7()
+	missedId, _ := uuid.NewV7()
+
+	sched := NewScheduler(NewMockStorage(), nil, NewJobList(config.MaxJobs()))
+	sched.off = false
+	sched.notifier = make(chan uuid.UUID, 1)
+	claimed := config.AppStats.ClaimedJobs
+	sched.l.lock.Lock()
+	sched.initJobList([]*job.Job{
+		{Id: exhaustedId, CronExpString: "RRULE:FREQ=DAILY;UNTIL=20200101T000000Z"},
+		{Id: missedId, CronExpString: "at 2020-01-01T00:00:00Z"},
+	})
+	sched.l.lock.Unlock()
+
+	_, ok := sched.l.list[exhaustedId]
+	assert.False(t, ok, "a rrule past its UNTIL should not keep its slot")
+	assert.Contains(t, completedJobs, exhaustedId)
+	_, ok = sched.timers.byId[exhaustedId]
+	assert.False(t, ok)
+
+	// a one-shot whose time passed while nobody had it claimed runs right away, once
+	timer, ok := sched.timers.byId[missedId]
+	if assert.True(t, ok) {
+		assert.False(t, timer.at.After(time.Now()))
+	}
+	sched.reschedule(missedId)
+	_, ok = sched.l.list[missedId]
+	assert.False(t, ok, "a one-shot that ran late should not keep its slot")
+	assert.Contains(t, completedJobs, missedId)
+	assert.Equal(t, claimed, config.AppStats.ClaimedJobs)
+}
+
+// Run with -race: manual runs copy the job while scheduled executions write their results
+func TestScheduler_RunNowDuringFire(t *testing.T) {
+	id, _ := uuid.NewV7()
+	runId, _ := uuid.NewV7()
+	sched := NewScheduler(NewMockStorage(), nil, NewJobList(config.MaxJobs()))
+	sched.off = false
+	sched.notifier = make(chan uuid.UUID, 1)
+	manualRuns := make(chan *job.Job, 1)
+	j := &job.Job{
+		Id:              id,
+		CronExpString:   "10 * * * *",
+		SuccessStatuses: []int{200},
+		Handlers: job.Handlers{
+			ExecuteFn: func(j *job.Job) job.ExecutionResult {
+				return job.ExecutionResult{Status: 200, Message: "OK", ResponseTime: time.Now()}
+			},
+			OnSuccessFn: func(j *job.Job) {
+				if j.Trigger == job.TriggerManual {
+					manualRuns <- j
+				}
+			},
+			OnErrorFn: func(j *job.Job) {},
+		},
+	}
+	assert.NoError(t, j.InitExpression(sched.parser))
+	sched.l.list[id] = j
+
+	for i := 0; i < 50; i++ {
+		requestedAt := time.Now()
+		sched.fire(id)
+		sched.runNow(id, runId)
+		manual := <-manualRuns
+		done := <-sched.notifier
+		assert.Equal(t, id, done)
+		sched.reschedule(done)
+		assert.Equal(t, runId, manual.RunId)
+		assert.False(t, manual.LastExecution.Before(requestedAt), "rounds of manual runs start when they run")
+	}
+	assert.Equal(t, "", sched.l.list[id].Trigger, "the scheduled job is left untouched")
+}
+
+func TestScheduler_FireKeepsTheOutcomeAndDoesNotOverlap(t *testing.T) {
+	id, _ := uuid.NewV7()
+	sched := NewScheduler(NewMockStorage(), nil, NewJobList(config.MaxJobs()))
+	sched.off = false
+	sched.notifier = make(chan uuid.UUID, 1)
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	j := &job.Job{
+		Id:              id,
+		CronExpString:   "10 * * * *",
+		SuccessStatuses: []int{200},
+		Handlers: job.Handlers{
+			ExecuteFn: func(j *job.Job) job.ExecutionResult {
+				started <- struct{}{}
+				<-release
+				return job.ExecutionResult{Status: 500, Message: "boom", ResponseTime: time.Now()}
+			},
+			OnSuccessFn: func(j *job.Job) {},
+			OnErrorFn:   func(j *job.Job) {},
+		},
+	}
+	assert.NoError(t, j.InitExpression(sched.parser))
+	sched.l.list[id] = j
+
+	sched.fire(id)
+	<-started
+	sched.fire(id)
+	sched.refreshJob(id)
+	assert.Equal(t, 0, sched.timers.Len(), "a running job should not be armed again")
+
+	close(release)
+	sched.reschedule(<-sched.notifier)
+	assert.Len(t, started, 0, "the second run should be skipped")
+	assert.Equal(t, 1, sched.timers.Len())
+	assert.Equal(t, "error", sched.l.list[id].Succeeded)
+	assert.Equal(t, "boom", sched.l.list[id].LastMessage)
+	assert.Equal(t, 500, sched.l.list[id].LastStatusCode)
+	assert.False(t, sched.l.list[id].LastExecution.IsZero())
+	assert.Equal(t, "*/5 * * * *", sched.l.list[id].CronExpString, "updates received while running are kept")
+}
+
+func TestScheduler_RefreshJobDropsPausedAndDeletedJobs(t *testing.T) {
+	pausedId, _ := uuid.NewV7()
+	deletedId, _ := uuid.NewV7()
+	jobUpdatesOverrides[pausedId] = &storage.JobUpdates{Status: "paused"}
+	jobUpdatesOverrides[deletedId] = &storage.JobUpdates{Status: "deleted", Deleted_at: time.Now().UnixMilli()}
+	defer delete(jobUpdatesOverrides, pausedId)
+	defer delete(jobUpdatesOverrides, deletedId)
+
+	sched := NewScheduler(NewMockStorage(), nil, NewJobList(config.MaxJobs()))
+	sched.off = false
+	sched.notifier = make(chan uuid.UUID, 1)
+	for _, id := range []uuid.UUID{pausedId, deletedId} {
+		sched.l.list[id] = &job.Job{Id: id, CronExpString: "10 * * * *", Scheduled: true}
+	}
+
+	sched.refreshJob(pausedId)
+	sched.refreshJob(deletedId)
+
+	assert.Empty(t, sched.l.list)
+	assert.Equal(t, 0, sched.timers.Len(), "dropped jobs should not keep their timers")
+}
+
+func TestScheduler_RefreshJobReleasesJobsOutsideTheSelector(t *testing.T) {
+	movedId, _ := uuid.NewV7()
+	keptId, _ := uuid.NewV7()
+	jobUpdatesOverrides[movedId] = &storage.JobUpdates{Cron_exp_string: "10 * * * *", Labels: map[string]string{"zone": "us"}}
+	jobUpdatesOverrides[keptId] = &storage.JobUpdates{Cron_exp_string: "10 * * * *", Labels: map[string]string{"zone": "eu", "team": "payments"}}
+	defer delete(jobUpdatesOverrides, movedId)
+	defer delete(jobUpdatesOverrides, keptId)
+	releasedJobs = []*job.Job{}
+
+	sched := NewScheduler(NewMockStorage(), nil, NewJobList(config.MaxJobs()))
+	sched.off = false
+	sched.notifier = make(chan uuid.UUID, 1)
+	sched.selector, _ = labels.Parse("zone=eu")
+	for _, id := range []uuid.UUID{movedId, keptId} {
+		sched.l.list[id] = &job.Job{Id: id, CronExpString: "10 * * * *", Scheduled: true}
+		sched.l.list[id].InitExpression(sched.parser)
+	}
+
+	sched.refreshJob(movedId)
+	sched.refreshJob(keptId)
+
+	assert.Len(t, sched.l.list, 1)
+	assert.Len(t, releasedJobs, 1)
+	assert.Equal(t, movedId, releasedJobs[0].Id)
+	assert.Equal(t, "payments", sched.l.list[keptId].Labels["team"])
+}
+
+func TestScheduler_RefreshJobReleasesJobsOfOtherLocations(t *testing.T) {
+	movedId, _ := uuid.NewV7()
+	locatedId, _ := uuid.NewV7()
+	keptId, _ := uuid.NewV7()
+	// one location was removed and the other job started requiring locations
+	jobUpdatesOverrides[movedId] = &storage.JobUpdates{Cron_exp_string: "10 * * * *", Locations: []string{"paris"}}
+	jobUpdatesOverrides[locatedId] = &storage.JobUpdates{Cron_exp_string: "10 * * * *", Locations: []string{"madrid"}}
+	jobUpdatesOverrides[keptId] = &storage.JobUpdates{Cron_exp_string: "10 * * * *", Locations: []string{"madrid", "paris"}, Alert_quorum: 2}
+	defer delete(jobUpdatesOverrides, movedId)
+	defer delete(jobUpdatesOverrides, locatedId)
+	defer delete(jobUpdatesOverrides, keptId)
+	releasedJobs = []*job.Job{}
+
+	sched := NewScheduler(NewMockStorage(), nil, NewJobList(config.MaxJobs()))
+	sched.off = false
+	sched.notifier = make(chan uuid.UUID, 1)
+	sched.l.list[movedId] = &job.Job{Id: movedId, CronExpString: "10 * * * *", Location: "madrid", Scheduled: true}
+	sched.l.list[locatedId] = &job.Job{Id: locatedId, CronExpString: "10 * * * *", Scheduled: true}
+	sched.l.list[keptId] = &job.Job{Id: keptId, CronExpString: "10 * * * *", Location: "madrid", Scheduled: true}
+	for _, j := range sched.l.list {
+		j.InitExpression(sched.parser)
+	}
+
+	sched.refreshJob(movedId)
+	if assert.Len(t, releasedJobs, 1) {
+		assert.Equal(t, movedId, releasedJobs[0].Id)
+	}
+	sched.refreshJob(locatedId)
+	if assert.Len(t, releasedJobs, 1) {
+		assert.Equal(t, locatedId, releasedJobs[0].Id)
+	}
+	sched.refreshJob(keptId)
+
+	assert.Len(t, sched.l.list, 1)
+	assert.Equal(t, 2, sched.l.list[keptId].AlertQuorum)
+}
+
+func TestScheduler_RefreshJobClaimsResumedJobs(t *testing.T) {
+	resumedId, _ := uuid.NewV7()
+	jobUpdatesOverrides[resumedId] = &storage.JobUpdates{Status: "pending to be claimed"}
+	defer delete(jobUpdatesOverrides, resumedId)
+
+	sched := NewScheduler(NewMockStorage(), nil, NewJobList(config.MaxJobs()))
+	sched.off = false
+	sched.notifier = make(chan uuid.UUID, 1)
+	gotAvailableJobs = false
+
+	sched.refreshJob(resumedId)
+
+	assert.True(t, gotAvailableJobs, "should check for new jobs when one is resumed")
+	assert.NotEmpty(t, sched.l.list)
+	assert.Equal(t, len(sched.l.list), sched.timers.Len())
+}
+
+func TestScheduler_Dispatch(t *testing.T) {
+	pausedId, _ := uuid.NewV7()
+	deletedId, _ := uuid.NewV7()
+	releasedId, _ := uuid.NewV7()
+	keptId, _ := uuid.NewV7()
+	releasedJobs = []*job.Job{}
+
+	sched := NewScheduler(NewMockStorage(), nil, NewJobList(config.MaxJobs()))
+	sched.off = false
+	sched.notifier = make(chan uuid.UUID, 1)
+	for _, id := range []uuid.UUID{pausedId, deletedId, releasedId, keptId} {
+		sched.l.list[id] = &job.Job{Id: id, CronExpString: "10 * * * *", Scheduled: true}
+	}
+
+	sched.dispatch(storage.NewNotification(storage.EventPaused, pausedId))
+	sched.dispatch(storage.NewNotification(storage.EventDeleted, deletedId))
+	sched.dispatch(storage.NewNotification(storage.EventRelease, releasedId))
+	sched.dispatch(storage.Notification{Version: storage.NotificationVersion, Event: "unknown", JobId: keptId})
+
+	assert.Len(t, sched.l.list, 1)
+	_, ok := sched.l.list[keptId]
+	assert.True(t, ok)
+	assert.Len(t, releasedJobs, 1)
+	assert.Equal(t, releasedId, releasedJobs[0].Id)
+}
+
+func TestScheduler_Resync(t *testing.T) {
+	unchangedId, _ := uuid.NewV7()
+	updatedId, _ := uuid.NewV7()
+	goneId, _ := uuid.NewV7()
+	jobsUpdatedAt[updatedId] = 2
+	hiddenJobs[goneId] = true
+	defer delete(jobsUpdatedAt, updatedId)
+	defer delete(hiddenJobs, goneId)
+
+	sched := NewScheduler(NewMockStorage(), nil, NewJobList(config.MaxJobs()))
+	sched.off = false
+	sched.notifier = make(chan uuid.UUID, 1)
+	for _, id := range []uuid.UUID{unchangedId, updatedId, goneId} {
+		j := &job.Job{Id: id, CronExpString: "10 * * * *", Endpoint: "/old", Scheduled: true, UpdatedAt: 1}
+		j.InitExpression(sched.parser)
+		sched.l.list[id] = j
+		sched.schedule(j)
+	}
+	jobsUpdatedAt[unchangedId] = 1
+	defer delete(jobsUpdatedAt, unchangedId)
+	gotAvailableJobs = false
+
+	sched.resync()
+
+	_, ok := sched.l.list[goneId]
+	assert.False(t, ok, "jobs we can't see anymore should be dropped")
+	assert.Equal(t, "/old", sched.l.list[unchangedId].Endpoint)
+	assert.Equal(t, "/updated", sched.l.list[updatedId].Endpoint)
+	assert.True(t, gotAvailableJobs, "should check for jobs resumed while we were not listening")
+	_, ok = sched.timers.byId[goneId]
+	assert.False(t, ok, "dropped jobs should not keep their timers")
+	_, ok = sched.timers.byId[updatedId]
+	assert.True(t, ok, "refreshed jobs should be scheduled again")
+}
+
+func TestScheduler_PauseAndResumeWithMemoryStorage(t *testing.T) {
+	s, close := storage.NewMemoryStorage()
+	defer close()
+	_, err := s.CreateJob(storage.CreateJobInput{
+		Name:            "memory job",
+		CronExpString:   "10 * * * *",
+		Endpoint:        "http://localhost/",
+		HttpMethod:      "GET",
+		SuccessStatuses: []int{200},
+	})
+	assert.NoError(t, err)
+
+	notifications := make(chan storage.Notification, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.ListenForChanges(notifications, ctx)
+
+	sched := NewScheduler(s, nil, NewJobList(config.MaxJobs()))
+	sched.off = false
+	sched.notifier = make(chan uuid.UUID, 1)
+	sched.checkForNewJobs()
+	claimed := s.GetClaimedJobs(10, 0, nil)
+	assert.Len(t, claimed, 1)
+	assert.Len(t, sched.l.list, 1)
+	jobId := claimed[0].Id
+
+	assert.NoError(t, s.PauseJob(jobId, "tester"))
+	sched.dispatch(<-notifications)
+	assert.Empty(t, sched.l.list, "paused jobs should be dropped")
+	assert.Len(t, s.GetClaimedJobs(10, 0, nil), 0)
+
+	assert.NoError(t, s.ResumeJob(jobId, "tester"))
+	sched.dispatch(<-notifications)
+	_, ok := sched.l.list[jobId]
+	assert.True(t, ok, "resumed jobs should be claimed again")
+	assert.Len(t, s.GetClaimedJobs(10, 0, nil), 1)
+	assert.Equal(t, 1, sched.timers.Len())
+}
@@ -0,0 +1,153 @@
+package scheduler
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+type timer struct {
+	id    uuid.UUID
+	at    time.Time
+	index int
+}
+
+// Min-heap of timers ordered by the time they fire
+type timerHeap []*timer
+
+func (h timerHeap) Len() int { return len(h) }
+
+func (h timerHeap) Less(i, j int) bool { return h[i].at.Before(h[j].at) }
+
+func (h timerHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *timerHeap) Push(x any) {
+	t := x.(*timer)
+	t.index = len(*h)
+	*h = append(*h, t)
+}
+
+func (h *timerHeap) Pop() any {
+	old := *h
+	n := len(old)
+	t := old[n-1]
+	old[n-1] = nil
+	t.index = -1
+	*h = old[:n-1]
+	return t
+}
+
+// A single loop firing the timers of all jobs.
+// Setting and cancelling a timer are O(log n).
+type timers struct {
+	lock  sync.Mutex
+	heap  timerHeap
+	byId  map[uuid.UUID]*timer
+	wake  chan struct{}
+	fire  func(id uuid.UUID)
+	clock func() time.Time
+}
+
+func newTimers(fire func(id uuid.UUID)) *timers {
+	return &timers{
+		byId:  map[uuid.UUID]*timer{},
+		wake:  make(chan struct{}, 1),
+		fire:  fire,
+		clock: time.Now,
+	}
+}
+
+// Sets when the timer of a job fires, replacing the previous one if there was any
+func (ts *timers) Set(id uuid.UUID, at time.Time) {
+	ts.lock.Lock()
+	if t, ok := ts.byId[id]; ok {
+		t.at = at
+		heap.Fix(&ts.heap, t.index)
+	} else {
+		t := &timer{id: id, at: at}
+		ts.byId[id] = t
+		heap.Push(&ts.heap, t)
+	}
+	ts.lock.Unlock()
+	ts.notify()
+}
+
+// Removes the timer of a job. Returns false if there was none.
+func (ts *timers) Cancel(id uuid.UUID) bool {
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+	t, ok := ts.byId[id]
+	if !ok {
+		return false
+	}
+	heap.Remove(&ts.heap, t.index)
+	delete(ts.byId, id)
+	return true
+}
+
+func (ts *timers) Len() int {
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+	return len(ts.heap)
+}
+
+// Lets the loop know the earliest timer may have changed
+func (ts *timers) notify() {
+	select {
+	case ts.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Removes the timers that are due and returns them along with how long to wait for the next one
+func (ts *timers) due() ([]*timer, time.Duration, bool) {
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+	now := ts.clock()
+	fired := []*timer{}
+	for len(ts.heap) > 0 && !ts.heap[0].at.After(now) {
+		t := heap.Pop(&ts.heap).(*timer)
+		delete(ts.byId, t.id)
+		fired = append(fired, t)
+	}
+	if len(ts.heap) == 0 {
+		return fired, 0, false
+	}
+	return fired, ts.heap[0].at.Sub(now), true
+}
+
+// Fires timers as they are due until the context is done
+func (ts *timers) Run(ctx context.Context) {
+	sleep := time.NewTimer(time.Hour)
+	defer sleep.Stop()
+	for {
+		fired, wait, pending := ts.due()
+		for _, t := range fired {
+			ts.fire(t.id)
+		}
+		if !sleep.Stop() {
+			select {
+			case <-sleep.C:
+			default:
+			}
+		}
+		var sleepCh <-chan time.Time
+		if pending {
+			sleep.Reset(wait)
+			sleepCh = sleep.C
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ts.wake:
+		case <-sleepCh:
+		}
+	}
+}
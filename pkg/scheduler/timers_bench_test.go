@@ -0,0 +1,122 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/rs/zerolog"
+
+	"github.com/back-end-labs/ruok/pkg/cronParser"
+	"github.com/back-end-labs/ruok/pkg/job"
+)
+
+var benchSizes = []int{1000, 10000}
+
+// Always returns the same instant
+type fixedExpression struct{ at time.Time }
+
+func (f fixedExpression) Next(time.Time) time.Time { return f.at }
+
+// Jobs log every time they are scheduled, which would dominate the results
+func quietLogs(b *testing.B) {
+	level := zerolog.GlobalLevel()
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	b.Cleanup(func() { zerolog.SetGlobalLevel(level) })
+}
+
+func benchJobs(n int, at time.Time) []*job.Job {
+	jobs := make([]*job.Job, n)
+	for i, id := range newIds(n) {
+		jobs[i] = &job.Job{
+			Id:           id,
+			CronExp:      cronParser.CronExpresion(fixedExpression{at}),
+			AbortChannel: make(chan struct{}),
+			Handlers: job.Handlers{
+				ExecuteFn:   func(*job.Job) job.ExecutionResult { return job.ExecutionResult{} },
+				OnSuccessFn: func(*job.Job) {},
+				OnErrorFn:   func(*job.Job) {},
+			},
+		}
+	}
+	return jobs
+}
+
+// Schedules n jobs far in the future and cancels them, like an update of every job would
+func BenchmarkScheduleAndCancel(b *testing.B) {
+	quietLogs(b)
+	for _, n := range benchSizes {
+		b.Run(fmt.Sprintf("goroutines/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				jobs := benchJobs(n, time.Now().Add(time.Hour))
+				notifier := make(chan uuid.UUID)
+				var wg sync.WaitGroup
+				wg.Add(n)
+				for _, j := range jobs {
+					go func(j *job.Job) {
+						defer wg.Done()
+						j.Schedule(notifier)
+					}(j)
+				}
+				for _, j := range jobs {
+					close(j.AbortChannel)
+				}
+				wg.Wait()
+			}
+		})
+		b.Run(fmt.Sprintf("heap/%d", n), func(b *testing.B) {
+			ts := newTimers(func(uuid.UUID) {})
+			jobs := benchJobs(n, time.Now().Add(time.Hour))
+			for i := 0; i < b.N; i++ {
+				for _, j := range jobs {
+					next, _ := j.NextExecution(time.Now())
+					ts.Set(j.Id, next)
+				}
+				for _, j := range jobs {
+					ts.Cancel(j.Id)
+				}
+			}
+		})
+	}
+}
+
+// Schedules n jobs for the same instant and waits until all of them ran
+func BenchmarkFireAll(b *testing.B) {
+	quietLogs(b)
+	for _, n := range benchSizes {
+		b.Run(fmt.Sprintf("goroutines/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				jobs := benchJobs(n, time.Now())
+				notifier := make(chan uuid.UUID, n)
+				for _, j := range jobs {
+					go j.Schedule(notifier)
+				}
+				for range jobs {
+					<-notifier
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("heap/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				jobs := benchJobs(n, time.Now())
+				byId := make(map[uuid.UUID]*job.Job, n)
+				notifier := make(chan uuid.UUID, n)
+				ts := newTimers(func(id uuid.UUID) { go byId[id].Fire(time.Now(), notifier) })
+				for _, j := range jobs {
+					byId[j.Id] = j
+					next, _ := j.NextExecution(time.Now())
+					ts.Set(j.Id, next)
+				}
+				ctx, cancel := context.WithCancel(context.Background())
+				go ts.Run(ctx)
+				for range jobs {
+					<-notifier
+				}
+				cancel()
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func newIds(n int) []uuid.UUID {
+	ids := make([]uuid.UUID, n)
+	for i := range ids {
+		ids[i], _ = uuid.NewV7()
+	}
+	return ids
+}
+
+func TestTimers_FireInOrder(t *testing.T) {
+	fired := make(chan uuid.UUID, 3)
+	ts := newTimers(func(id uuid.UUID) { fired <- id })
+	ids := newIds(3)
+	now := time.Now()
+	ts.Set(ids[0], now.Add(30*time.Millisecond))
+	ts.Set(ids[1], now.Add(10*time.Millisecond))
+	ts.Set(ids[2], now.Add(20*time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go ts.Run(ctx)
+
+	assert.Equal(t, ids[1], <-fired)
+	assert.Equal(t, ids[2], <-fired)
+	assert.Equal(t, ids[0], <-fired)
+	assert.Equal(t, 0, ts.Len())
+}
+
+func TestTimers_SetReplacesTheTimer(t *testing.T) {
+	fired := make(chan uuid.UUID, 2)
+	ts := newTimers(func(id uuid.UUID) { fired <- id })
+	ids := newIds(2)
+	now := time.Now()
+	ts.Set(ids[0], now.Add(time.Hour))
+	ts.Set(ids[1], now.Add(20*time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go ts.Run(ctx)
+
+	// an update while the loop is already sleeping
+	ts.Set(ids[0], now.Add(5*time.Millisecond))
+
+	assert.Equal(t, ids[0], <-fired)
+	assert.Equal(t, ids[1], <-fired)
+}
+
+func TestTimers_Cancel(t *testing.T) {
+	fired := make(chan uuid.UUID, 2)
+	ts := newTimers(func(id uuid.UUID) { fired <- id })
+	ids := newIds(2)
+	now := time.Now()
+	ts.Set(ids[0], now.Add(10*time.Millisecond))
+	ts.Set(ids[1], now.Add(20*time.Millisecond))
+
+	assert.True(t, ts.Cancel(ids[0]))
+	assert.False(t, ts.Cancel(ids[0]), "cancelling twice should report there was no timer")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go ts.Run(ctx)
+
+	assert.Equal(t, ids[1], <-fired)
+	select {
+	case id := <-fired:
+		t.Errorf("cancelled timer of job %v fired", id)
+	case <-time.After(30 * time.Millisecond):
+	}
+}
+
+func TestTimers_StopsWithTheContext(t *testing.T) {
+	fired := make(chan uuid.UUID, 1)
+	ts := newTimers(func(id uuid.UUID) { fired <- id })
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		ts.Run(ctx)
+		close(done)
+	}()
+	cancel()
+	<-done
+
+	ts.Set(newIds(1)[0], time.Now())
+	select {
+	case <-fired:
+		t.Error("timers should not fire after the loop stopped")
+	case <-time.After(20 * time.Millisecond):
+	}
+}
@@ -21,7 +21,13 @@ func (sqls *SQLStorage) ReleaseAll(j []*job.Job) error {
 	defer tx.Rollback(ctx)
 
 	for i := 0; i < len(j); i++ {
-		_, err := tx.Exec(ctx, "UPDATE ruok.jobs SET claimed_by = NULL, status = $1 WHERE id = $2", "pending to be claimed", j[i].Id)
+		var err error
+		if j[i].Location != "" {
+			// only our location, the jobs row of located jobs is never claimed
+			_, err = tx.Exec(ctx, "UPDATE ruok.job_locations SET claimed_by = NULL WHERE job_id = $1 AND location = $2", j[i].Id, j[i].Location)
+		} else {
+			_, err = tx.Exec(ctx, "UPDATE ruok.jobs SET claimed_by = NULL, status = $1 WHERE id = $2", "pending to be claimed", j[i].Id)
+		}
 		if err != nil {
 			log.Error().Err(err).Msgf("There was a problem while trying to exec release of job with id of %v", j[i].Id)
 			return errors.New("could not update jobs")
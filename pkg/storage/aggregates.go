@@ -0,0 +1,276 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/rs/zerolog/log"
+
+	"github.com/back-end-labs/ruok/pkg/job"
+)
+
+// Granularities of compacted execution results
+const (
+	GranularityHour = "hour"
+	GranularityDay  = "day"
+)
+
+var granularitySizes = map[string]time.Duration{
+	GranularityHour: time.Hour,
+	GranularityDay:  24 * time.Hour,
+}
+
+func IsValidGranularity(granularity string) bool {
+	_, ok := granularitySizes[granularity]
+	return ok
+}
+
+// Rolls results older than their retention into aggregates.
+// Returns how many results were compacted, 0 if another scheduler is compacting them.
+func (sqls *SQLStorage) CompactResults(retentionDays int, hourlyRetentionDays int) (int64, error) {
+	var compacted int64
+	err := sqls.Db.QueryRow(
+		sqls.tenantContext(),
+		"SELECT ruok.compact_job_results($1, $2)",
+		retentionDays,
+		hourlyRetentionDays,
+	).Scan(&compacted)
+	if err != nil {
+		log.Error().Err(err).Msg("could not compact job results")
+		return 0, errors.New("could not compact job results")
+	}
+	if compacted < 0 {
+		log.Info().Msg("job results are being compacted by someone else")
+		return 0, nil
+	}
+	return compacted, nil
+}
+
+// Compacted aggregates are merged with the ones computed from results not compacted yet,
+// so long ranges are served the same way no matter how old they are.
+// Nothing is returned when the job is not visible to the session, like in other tenants
+var getResultAggregatesQuery = `
+SELECT bucket_start, sum(executions), sum(failures), max(p50_latency), max(p95_latency) FROM (
+	SELECT bucket_start, executions, failures, p50_latency, p95_latency
+	FROM ruok.job_results_aggregates
+	WHERE job_id = $1 AND granularity = $2 AND bucket_start >= $3 AND bucket_start < $4
+	UNION ALL
+	SELECT execution_time - (execution_time % $5) AS bucket_start,
+		count(*) AS executions,
+		count(*) FILTER (WHERE succeeded IS DISTINCT FROM 'ok') AS failures,
+		(percentile_cont(0.5) WITHIN GROUP (ORDER BY last_response_at - execution_time)
+			FILTER (WHERE last_response_at >= execution_time))::bigint AS p50_latency,
+		(percentile_cont(0.95) WITHIN GROUP (ORDER BY last_response_at - execution_time)
+			FILTER (WHERE last_response_at >= execution_time))::bigint AS p95_latency
+	FROM ruok.job_results
+	WHERE job_id = $1 AND execution_time >= $3 AND execution_time < $4
+	GROUP BY 1
+) buckets
+WHERE EXISTS (SELECT FROM ruok.jobs WHERE jobs.id = $1)
+GROUP BY bucket_start
+ORDER BY bucket_start;
+`
+
+// Lists the aggregated results of a job between two times, aligned to the granularity.
+// Returns nil if something goes wrong.
+func (sqls *SQLStorage) GetResultAggregates(jobId uuid.UUID, granularity string, from time.Time, to time.Time) []*job.ResultAggregate {
+	size, ok := granularitySizes[granularity]
+	if !ok {
+		log.Error().Msgf("unknown granularity %q", granularity)
+		return nil
+	}
+	from = from.UTC().Truncate(size)
+	rows, err := sqls.Db.Query(
+		sqls.tenantContext(),
+		getResultAggregatesQuery,
+		jobId,
+		granularity,
+		from.UnixMicro(),
+		to.UnixMicro(),
+		size.Microseconds(),
+	)
+	if err != nil {
+		log.Error().Err(err).Msgf("could not get result aggregates of job %v", jobId)
+		return nil
+	}
+	defer rows.Close()
+
+	aggregates := []*job.ResultAggregate{}
+	for rows.Next() {
+		var bucketStart int64
+		var executions, failures int
+		var p50, p95 sql.NullInt64
+		err = rows.Scan(&bucketStart, &executions, &failures, &p50, &p95)
+		if err != nil {
+			log.Error().Err(err).Msg("could not scan result aggregates row")
+			return nil
+		}
+		a := &job.ResultAggregate{
+			BucketStart: time.UnixMicro(bucketStart).UTC(),
+			Granularity: granularity,
+			Executions:  executions,
+			Failures:    failures,
+		}
+		if p50.Valid {
+			a.P50LatencyMicro = &p50.Int64
+		}
+		if p95.Valid {
+			a.P95LatencyMicro = &p95.Int64
+		}
+		aggregates = append(aggregates, a)
+	}
+	if rows.Err() != nil {
+		log.Error().Err(rows.Err()).Msgf("could not read result aggregates of job %v", jobId)
+		return nil
+	}
+	return aggregates
+}
+
+// A result being rolled into an aggregate, latency is nil when the response time is unknown
+type compactedResult struct {
+	id            string
+	jobId         string
+	executionTime int64
+	failed        bool
+	latency       *int64
+}
+
+func newCompactedResult(id string, jobId string, executionTime int64, succeeded string, responseAt int64) compactedResult {
+	r := compactedResult{id: id, jobId: jobId, executionTime: executionTime, failed: succeeded != "ok"}
+	if responseAt >= executionTime {
+		latency := responseAt - executionTime
+		r.latency = &latency
+	}
+	return r
+}
+
+// Results are compacted whole UTC days at a time so daily buckets are complete
+func compactionCutoff(now time.Time, retentionDays int) int64 {
+	return now.UTC().AddDate(0, 0, -retentionDays).Truncate(24 * time.Hour).UnixMicro()
+}
+
+type aggregateKey struct {
+	jobId       string
+	granularity string
+	bucketStart int64
+}
+
+type aggregateBucket struct {
+	executions int
+	failures   int
+	latencies  []int64
+}
+
+func (b *aggregateBucket) add(r compactedResult) {
+	b.executions++
+	if r.failed {
+		b.failures++
+	}
+	if r.latency != nil {
+		b.latencies = append(b.latencies, *r.latency)
+	}
+}
+
+// Groups results in the buckets of every granularity
+func bucketResults(results []compactedResult) map[aggregateKey]*aggregateBucket {
+	buckets := map[aggregateKey]*aggregateBucket{}
+	for _, r := range results {
+		for granularity, size := range granularitySizes {
+			key := aggregateKey{r.jobId, granularity, r.executionTime - r.executionTime%size.Microseconds()}
+			b, ok := buckets[key]
+			if !ok {
+				b = &aggregateBucket{}
+				buckets[key] = b
+			}
+			b.add(r)
+		}
+	}
+	return buckets
+}
+
+// Continuous percentile with linear interpolation, like percentile_cont in postgres.
+// Returns nil without values.
+func percentile(values []int64, p float64) *int64 {
+	if len(values) == 0 {
+		return nil
+	}
+	sorted := append([]int64{}, values...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	pos := p * float64(len(sorted)-1)
+	lower := int(math.Floor(pos))
+	upper := int(math.Ceil(pos))
+	value := float64(sorted[lower]) + (pos-float64(lower))*float64(sorted[upper]-sorted[lower])
+	result := int64(math.Round(value))
+	return &result
+}
+
+// Merges compacted aggregates with the ones computed from results not compacted yet,
+// like getResultAggregatesQuery does for storages that can't run it
+type aggregatesMerge struct {
+	granularity string
+	size        time.Duration
+	byBucket    map[int64]*job.ResultAggregate
+	live        map[int64]*aggregateBucket
+}
+
+func newAggregatesMerge(granularity string, size time.Duration) *aggregatesMerge {
+	return &aggregatesMerge{
+		granularity: granularity,
+		size:        size,
+		byBucket:    map[int64]*job.ResultAggregate{},
+		live:        map[int64]*aggregateBucket{},
+	}
+}
+
+func (m *aggregatesMerge) bucket(start int64) *job.ResultAggregate {
+	a, ok := m.byBucket[start]
+	if !ok {
+		a = &job.ResultAggregate{BucketStart: time.UnixMicro(start).UTC(), Granularity: m.granularity}
+		m.byBucket[start] = a
+	}
+	return a
+}
+
+// like max() in the postgres query, the biggest percentile of both sources wins
+func keepMax(current **int64, value *int64) {
+	if value != nil && (*current == nil || *value > **current) {
+		v := *value
+		*current = &v
+	}
+}
+
+func (m *aggregatesMerge) addCompacted(start int64, executions int, failures int, p50 *int64, p95 *int64) {
+	a := m.bucket(start)
+	a.Executions += executions
+	a.Failures += failures
+	keepMax(&a.P50LatencyMicro, p50)
+	keepMax(&a.P95LatencyMicro, p95)
+}
+
+func (m *aggregatesMerge) addResult(r compactedResult) {
+	start := r.executionTime - r.executionTime%m.size.Microseconds()
+	b, ok := m.live[start]
+	if !ok {
+		b = &aggregateBucket{}
+		m.live[start] = b
+	}
+	b.add(r)
+}
+
+// Returns the aggregates sorted by bucket
+func (m *aggregatesMerge) list() []*job.ResultAggregate {
+	for start, b := range m.live {
+		m.addCompacted(start, b.executions, b.failures, percentile(b.latencies, 0.5), percentile(b.latencies, 0.95))
+	}
+	m.live = map[int64]*aggregateBucket{}
+	aggregates := []*job.ResultAggregate{}
+	for _, a := range m.byBucket {
+		aggregates = append(aggregates, a)
+	}
+	sort.Slice(aggregates, func(i, j int) bool { return aggregates[i].BucketStart.Before(aggregates[j].BucketStart) })
+	return aggregates
+}
@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/back-end-labs/ruok/pkg/job"
+	"github.com/gofrs/uuid"
+)
+
+func TestCompactResults(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, s *testStorage) {
+		id, _ := uuid.NewV7()
+		err := s.exec(seedOneJobQuery(id))
+		if err != nil {
+			t.Errorf("couldn't seed due to the following error: %q", err.Error())
+		}
+
+		day := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -40)
+		old := makeJobStruct(id, day.Add(time.Hour))
+		old.Succeeded = "ok"
+		failed := makeJobStruct(id, day.Add(2*time.Hour))
+		failed.LastStatusCode = 500
+		failed.Succeeded = "error"
+		recent := makeJobStruct(id, time.Now())
+		recent.Succeeded = "ok"
+		for _, j := range []*job.Job{&old, &failed, &recent} {
+			if err := s.WriteDone(j); err != nil {
+				t.Errorf("writing a job result shouldn't error. error=%q\n", err.Error())
+			}
+		}
+
+		compacted, err := s.CompactResults(30, 0)
+		if err != nil {
+			t.Errorf("compacting shouldn't error. error=%q\n", err.Error())
+		}
+		if compacted != 2 {
+			t.Errorf("expected 2 results to be compacted, got %d", compacted)
+		}
+
+		var count int
+		s.queryRow("SELECT count(*) FROM ruok.job_results WHERE job_id = $1", id).Scan(&count)
+		if count != 1 {
+			t.Errorf("only the recent result should be kept, found %d", count)
+		}
+
+		aggregates := s.GetResultAggregates(id, GranularityDay, day, time.Now().Add(time.Hour))
+		if len(aggregates) != 2 {
+			t.Fatalf("expected a compacted and a live bucket, got %d", len(aggregates))
+		}
+		if !aggregates[0].BucketStart.Equal(day) || aggregates[0].Executions != 2 || aggregates[0].Failures != 1 {
+			t.Errorf("unexpected compacted bucket %+v", aggregates[0])
+		}
+		if aggregates[1].Executions != 1 || aggregates[1].Failures != 0 {
+			t.Errorf("unexpected live bucket %+v", aggregates[1])
+		}
+
+		hourly := s.GetResultAggregates(id, GranularityHour, day, day.Add(24*time.Hour))
+		if len(hourly) != 2 {
+			t.Errorf("expected 2 hourly buckets, got %d", len(hourly))
+		}
+	})
+}
+
+func TestPercentile(t *testing.T) {
+	if percentile(nil, 0.5) != nil {
+		t.Error("expected no percentile without values")
+	}
+	// same results as percentile_cont in postgres
+	values := []int64{40, 10, 30, 20}
+	if p := *percentile(values, 0.5); p != 25 {
+		t.Errorf("expected p50 to be 25, got %d", p)
+	}
+	if p := *percentile(values, 0.95); p != 39 {
+		t.Errorf("expected p95 to be 39, got %d", p)
+	}
+	if p := *percentile([]int64{7}, 0.95); p != 7 {
+		t.Errorf("expected the only value, got %d", p)
+	}
+}
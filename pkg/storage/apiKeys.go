@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/gofrs/uuid"
+	pgxuuid "github.com/jackc/pgx-gofrs-uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+
+	"github.com/back-end-labs/ruok/pkg/auth"
+)
+
+// Only the hash of the key is stored, see auth.GenerateKey
+type CreateAPIKeyInput struct {
+	Name   string
+	Scope  auth.Scope
+	Prefix string
+	Hash   string
+	// Tenant of the callers using the key, auth.DefaultTenant when empty
+	Tenant string
+}
+
+var createAPIKeyQuery = `
+INSERT INTO ruok.api_keys (id, key_name, prefix, key_hash, scope, tenant_id)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING created_at;
+`
+
+var getAPIKeyQuery = `
+SELECT id, key_name, prefix, scope, tenant_id, created_at
+FROM ruok.api_keys
+WHERE key_hash = $1 AND revoked_at IS NULL;
+`
+
+var listAPIKeysQuery = `
+SELECT id, key_name, prefix, scope, tenant_id, created_at, revoked_at
+FROM ruok.api_keys
+WHERE $1 = '' OR tenant_id = $1
+ORDER BY id ASC;
+`
+
+var revokeAPIKeyQuery = "UPDATE ruok.api_keys SET revoked_at = ruok.micro_unix_now() WHERE id = $1 AND revoked_at IS NULL AND ($2 = '' OR tenant_id = $2)"
+
+func (sqls *SQLStorage) CreateAPIKey(k CreateAPIKeyInput) (*auth.APIKey, error) {
+	id, err := uuid.NewV7()
+	if err != nil {
+		log.Error().Err(err).Msg("could not create uuidv7 for new api key")
+		return nil, err
+	}
+
+	key := &auth.APIKey{Id: id, Name: k.Name, Prefix: k.Prefix, Scope: k.Scope, Tenant: tenantOrDefault(k.Tenant)}
+	err = sqls.Db.QueryRow(sqls.tenantContext(), createAPIKeyQuery,
+		id,
+		k.Name,
+		k.Prefix,
+		k.Hash,
+		k.Scope,
+		key.Tenant,
+	).Scan(&key.CreatedAt)
+
+	if err != nil {
+		log.Error().Err(err).Msg("could not insert into api_keys")
+		return nil, errors.New("could not insert into api_keys")
+	}
+	return key, nil
+}
+
+// Finds the key with the hash, revoked keys are not found
+func (sqls *SQLStorage) GetAPIKey(hash string) (*auth.APIKey, error) {
+	var Id pgxuuid.UUID
+	key := &auth.APIKey{}
+	err := sqls.Db.QueryRow(sqls.tenantContext(), getAPIKeyQuery, hash).Scan(
+		&Id,
+		&key.Name,
+		&key.Prefix,
+		&key.Scope,
+		&key.Tenant,
+		&key.CreatedAt,
+	)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+
+	if err != nil {
+		log.Error().Err(err).Msg("could not query for api key")
+		return nil, errors.New("could not query for api key")
+	}
+	key.Id = uuid.UUID(Id)
+	return key, nil
+}
+
+// Lists every key of the tenant, revoked ones included
+func (sqls *SQLStorage) ListAPIKeys() []*auth.APIKey {
+	rows, err := sqls.Db.Query(sqls.tenantContext(), listAPIKeysQuery, sqls.tenant)
+	if err != nil {
+		log.Error().Err(err).Msg("could not query for api keys")
+		return nil
+	}
+	defer rows.Close()
+
+	keys := []*auth.APIKey{}
+	for rows.Next() {
+		var Id pgxuuid.UUID
+		var RevokedAt sql.NullInt64
+		key := &auth.APIKey{}
+
+		err = rows.Scan(&Id, &key.Name, &key.Prefix, &key.Scope, &key.Tenant, &key.CreatedAt, &RevokedAt)
+		if err != nil {
+			log.Error().Err(err).Msg("could not scan api keys row")
+			continue
+		}
+		key.Id = uuid.UUID(Id)
+		key.RevokedAt = RevokedAt.Int64
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// Stops accepting the key, it is kept so changes made with it can be traced
+func (sqls *SQLStorage) RevokeAPIKey(id uuid.UUID) error {
+	tag, err := sqls.Db.Exec(sqls.tenantContext(), revokeAPIKeyQuery, id, sqls.tenant)
+	if err != nil {
+		log.Error().Err(err).Msgf("could not revoke api key %v", id)
+		return errors.New("could not revoke api key")
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
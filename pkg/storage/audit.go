@@ -0,0 +1,379 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/gofrs/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+
+	"github.com/back-end-labs/ruok/pkg/config"
+	"github.com/back-end-labs/ruok/pkg/job"
+)
+
+// Returned when restoring a revision that deleted the job, restore an earlier one instead
+var ErrDeletedRevision = &KindError{ErrConflict, "deleted_revision", "the revision deleted the job"}
+
+// Columns a job.Snapshot is made of, the same on every sql storage
+var snapshotColumns = `
+	job_name,
+	cron_exp_string,
+	timezone,
+	max_retries,
+	endpoint,
+	httpmethod,
+	success_statuses,
+	alert_strategy,
+	alert_method,
+	alert_endpoint,
+	alert_payload,
+	alert_headers_string,
+	labels,
+	locations,
+	alert_quorum,
+	results_retention_days,
+	status,
+	deleted_at`
+
+var jobSnapshotQuery = `SELECT ` + snapshotColumns + ` FROM ruok.jobs WHERE id = $1 FOR UPDATE;`
+
+// Revisions are numbered per job, the job row is locked while they are written so numbers don't repeat
+var recordRevisionQuery = `
+INSERT INTO ruok.job_audit (id, job_id, revision, action, actor, before, after)
+SELECT $1, $2, coalesce(max(revision), 0) + 1, $3, $4, $5, $6
+FROM ruok.job_audit
+WHERE job_id = $2;
+`
+
+var jobHistoryQuery = `
+SELECT job_id, revision, action, actor, created_at, before, after
+FROM ruok.job_audit
+WHERE job_id = $1
+ORDER BY revision DESC
+LIMIT $2
+OFFSET $3;
+`
+
+var jobRevisionQuery = `SELECT after FROM ruok.job_audit WHERE job_id = $1 AND revision = $2;`
+
+// Everything nullable in a snapshot row, so both sql storages can build it the same way
+type snapshotRow struct {
+	maxRetries           sql.NullInt64
+	alertStrategy        sql.NullString
+	alertMethod          sql.NullString
+	alertEndpoint        sql.NullString
+	alertPayload         sql.NullString
+	alertHeaders         sql.NullString
+	labels               string
+	alertQuorum          sql.NullInt32
+	resultsRetentionDays sql.NullInt32
+	status               sql.NullString
+	deletedAt            sql.NullInt64
+}
+
+func (r snapshotRow) fill(s *job.Snapshot) *job.Snapshot {
+	s.MaxRetries = int(r.maxRetries.Int64)
+	s.AlertStrategy = r.alertStrategy.String
+	s.AlertMethod = r.alertMethod.String
+	s.AlertEndpoint = r.alertEndpoint.String
+	s.AlertPayload = r.alertPayload.String
+	s.AlertHeaders = map[string]string{}
+	if r.alertHeaders.Valid && r.alertHeaders.String != "" {
+		if err := json.Unmarshal([]byte(r.alertHeaders.String), &s.AlertHeaders); err != nil {
+			log.Error().Err(err).Msg("could not unmarshal alert headers of job snapshot")
+		}
+	}
+	s.Labels = parseLabels(r.labels)
+	s.AlertQuorum = int(r.alertQuorum.Int32)
+	if r.resultsRetentionDays.Valid {
+		days := int(r.resultsRetentionDays.Int32)
+		s.ResultsRetentionDays = &days
+	}
+	s.Paused = r.status.String == "paused"
+	s.Deleted = r.deletedAt.Valid
+	if s.SuccessStatuses == nil {
+		s.SuccessStatuses = []int{}
+	}
+	if s.Locations == nil {
+		s.Locations = []string{}
+	}
+	return s
+}
+
+// What the job should look like once restored
+func restoreInput(jobId uuid.UUID, s *job.Snapshot, by string) UpdateJobInput {
+	return UpdateJobInput{
+		Id:                   jobId,
+		Name:                 s.Name,
+		CronExpString:        s.CronExpString,
+		Timezone:             s.Timezone,
+		MaxRetries:           s.MaxRetries,
+		Endpoint:             s.Endpoint,
+		HttpMethod:           s.HttpMethod,
+		SuccessStatuses:      s.SuccessStatuses,
+		AlertStrategy:        s.AlertStrategy,
+		AlertMethod:          s.AlertMethod,
+		AlertEndpoint:        s.AlertEndpoint,
+		AlertPayload:         s.AlertPayload,
+		AlertHeaders:         s.AlertHeaders,
+		Labels:               s.Labels,
+		Locations:            s.Locations,
+		AlertQuorum:          s.AlertQuorum,
+		ResultsRetentionDays: s.ResultsRetentionDays,
+		UpdatedBy:            by,
+	}
+}
+
+func snapshotJSON(s *job.Snapshot) []byte {
+	if s == nil {
+		return nil
+	}
+	b, _ := json.Marshal(s)
+	return b
+}
+
+func parseSnapshot(b []byte) *job.Snapshot {
+	if len(b) == 0 {
+		return nil
+	}
+	s := &job.Snapshot{}
+	if err := json.Unmarshal(b, s); err != nil {
+		log.Error().Err(err).Msg("could not unmarshal job snapshot")
+		return nil
+	}
+	return s
+}
+
+// Locks the job and returns what it looks like, ErrNotFound if it doesn't exist
+func jobSnapshot(ctx context.Context, tx pgx.Tx, jobId uuid.UUID) (*job.Snapshot, error) {
+	s := &job.Snapshot{}
+	r := snapshotRow{}
+	err := tx.QueryRow(ctx, jobSnapshotQuery, jobId).Scan(
+		&s.Name,
+		&s.CronExpString,
+		&s.Timezone,
+		&r.maxRetries,
+		&s.Endpoint,
+		&s.HttpMethod,
+		&s.SuccessStatuses,
+		&r.alertStrategy,
+		&r.alertMethod,
+		&r.alertEndpoint,
+		&r.alertPayload,
+		&r.alertHeaders,
+		&r.labels,
+		&s.Locations,
+		&r.alertQuorum,
+		&r.resultsRetentionDays,
+		&r.status,
+		&r.deletedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r.fill(s), nil
+}
+
+// Adds a revision to the history of the job with what it looks like now
+func recordRevision(ctx context.Context, tx pgx.Tx, jobId uuid.UUID, action string, by string, before *job.Snapshot) error {
+	after, err := jobSnapshot(ctx, tx, jobId)
+	if err != nil {
+		return err
+	}
+	id, err := uuid.NewV7()
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, recordRevisionQuery, id, jobId, action, nullString(by), snapshotJSON(before), snapshotJSON(after))
+	return err
+}
+
+// Changes made to a job, the newest first
+func (sqls *SQLStorage) GetJobHistory(jobId uuid.UUID, limit int, offset int) []*job.Revision {
+	rows, err := sqls.Db.Query(sqls.tenantContext(), jobHistoryQuery, jobId, limit, offset)
+	if err != nil {
+		log.Error().Err(err).Msgf("could not query history of job %v", jobId)
+		return nil
+	}
+	defer rows.Close()
+
+	revisions := []*job.Revision{}
+	for rows.Next() {
+		r := &job.Revision{}
+		var actor sql.NullString
+		var before, after []byte
+		if err := rows.Scan(&r.JobId, &r.Revision, &r.Action, &actor, &r.CreatedAt, &before, &after); err != nil {
+			log.Error().Err(err).Msg("could not scan job history row")
+			continue
+		}
+		r.Actor = actor.String
+		r.Before = parseSnapshot(before)
+		r.After = parseSnapshot(after)
+		r.Changes = job.Diff(r.Before, r.After)
+		revisions = append(revisions, r)
+	}
+	return revisions
+}
+
+// What the job looked like after "revision"
+func (sqls *SQLStorage) GetJobRevision(jobId uuid.UUID, revision int) (*job.Snapshot, error) {
+	var raw []byte
+	err := sqls.Db.QueryRow(sqls.tenantContext(), jobRevisionQuery, jobId, revision).Scan(&raw)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		log.Error().Err(err).Msgf("could not get revision %d of job %v", revision, jobId)
+		return nil, errors.New("could not get the revision of the job")
+	}
+	target := parseSnapshot(raw)
+	if target == nil {
+		return nil, ErrNotFound
+	}
+	return target, nil
+}
+
+var restoreJobQuery = `
+WITH target AS (
+	SELECT id, claimed_by FROM ruok.jobs
+	WHERE id = $19
+	FOR UPDATE
+)
+UPDATE ruok.jobs SET
+	job_name = $1,
+	cron_exp_string = $2,
+	endpoint = $3,
+	httpmethod = $4,
+	max_retries = $5,
+	success_statuses = $6,
+	status = $7,
+	alert_strategy = $8,
+	alert_endpoint = $9,
+	alert_method = $10,
+	alert_headers_string = $11,
+	alert_payload = $12,
+	timezone = $13,
+	results_retention_days = $14,
+	labels = $15,
+	locations = $16,
+	alert_quorum = $17,
+	updated_by = $18,
+	claimed_by = NULL,
+	deleted_at = NULL,
+	updated_at = ruok.micro_unix_now()
+FROM target
+WHERE ruok.jobs.id = target.id
+RETURNING target.claimed_by;
+`
+
+// Makes the job look like it did after "revision", deleted jobs are brought back too.
+// The restore is another revision, so it can be undone.
+func (sqls *SQLStorage) RestoreJob(jobId uuid.UUID, revision int, by string) error {
+	ctx := sqls.tenantContext()
+	tx, err := sqls.Db.Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("could not start transaction to restore job")
+		return errors.New("could not restore job")
+	}
+	defer tx.Rollback(ctx)
+
+	before, err := jobSnapshot(ctx, tx, jobId)
+	if errors.Is(err, ErrNotFound) {
+		return ErrNotFound
+	}
+	if err != nil {
+		log.Error().Err(err).Msgf("could not get job %v to restore it", jobId)
+		return errors.New("could not restore job")
+	}
+
+	var raw []byte
+	err = tx.QueryRow(ctx, jobRevisionQuery, jobId, revision).Scan(&raw)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		log.Error().Err(err).Msgf("could not get revision %d of job %v", revision, jobId)
+		return errors.New("could not restore job")
+	}
+	target := parseSnapshot(raw)
+	if target == nil {
+		return ErrNotFound
+	}
+	if target.Deleted {
+		return ErrDeletedRevision
+	}
+	if before.Deleted {
+		if err := sqls.checkMaxJobs(ctx, tx); err != nil {
+			if errors.Is(err, ErrQuotaExceeded) {
+				return err
+			}
+			log.Error().Err(err).Msg("could not check the quota of the tenant")
+			return errors.New("could not restore job")
+		}
+	}
+
+	status := "pending to be claimed"
+	if target.Paused {
+		status = "paused"
+	}
+	j := restoreInput(jobId, target, by)
+	var owner sql.NullString
+	err = tx.QueryRow(ctx, restoreJobQuery,
+		j.Name,
+		j.CronExpString,
+		j.Endpoint,
+		j.HttpMethod,
+		j.MaxRetries,
+		j.SuccessStatuses,
+		status,
+		nullString(j.AlertStrategy),
+		nullString(j.AlertEndpoint),
+		nullString(j.AlertMethod),
+		nullJSON(j.AlertHeaders),
+		nullString(j.AlertPayload),
+		TimezoneOrDefault(j.Timezone),
+		j.ResultsRetentionDays,
+		labelsJSON(j.Labels),
+		locationsOrEmpty(j.Locations),
+		nullQuorum(j.AlertQuorum),
+		nullString(by),
+		jobId,
+	).Scan(&owner)
+	if err != nil {
+		log.Error().Err(err).Msgf("could not restore job %v", jobId)
+		return errors.New("could not restore job")
+	}
+
+	if err := recordRevision(ctx, tx, jobId, job.ActionRestore, by, before); err != nil {
+		log.Error().Err(err).Msgf("could not record restore of job %v", jobId)
+		return errors.New("could not restore job")
+	}
+
+	channel := config.AppName()
+	if owner.Valid {
+		channel = owner.String
+	}
+	owners, err := releaseLocations(ctx, tx, jobId)
+	if err != nil {
+		log.Error().Err(err).Msgf("could not release locations to restore job %v", jobId)
+		return errors.New("could not restore job")
+	}
+
+	err = notifyAll(ctx, tx, append([]string{channel}, owners...), NewNotification(EventUpdated, jobId))
+	if err != nil {
+		log.Error().Err(err).Msgf("could not notify restored job %v", jobId)
+		return errors.New("could not notify restored job")
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Error().Err(err).Msg("could not commit transaction to restore job")
+		return errors.New("could not commit transaction to restore job")
+	}
+	return nil
+}
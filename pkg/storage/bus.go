@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"context"
+	"sync"
+
+	"github.com/back-end-labs/ruok/pkg/config"
+	"github.com/rs/zerolog/log"
+)
+
+// How many payloads a subscriber can fall behind before new ones are dropped
+var busSubscriberBuffer = 1024
+
+// Delivers notification payloads between storages of the same process, like LISTEN/NOTIFY does.
+// Payloads are only delivered to subscribers of the channel at the time they are published.
+type bus struct {
+	lock        sync.Mutex
+	subscribers map[string]map[*subscription]struct{}
+}
+
+type subscription struct {
+	channel  string
+	payloads chan string
+}
+
+func newBus() *bus {
+	return &bus{subscribers: map[string]map[*subscription]struct{}{}}
+}
+
+func (b *bus) subscribe(channel string) *subscription {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	sub := &subscription{channel: channel, payloads: make(chan string, busSubscriberBuffer)}
+	if b.subscribers[channel] == nil {
+		b.subscribers[channel] = map[*subscription]struct{}{}
+	}
+	b.subscribers[channel][sub] = struct{}{}
+	return sub
+}
+
+// Closes the payloads of the subscription, it is safe to call more than once
+func (b *bus) unsubscribe(sub *subscription) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if _, ok := b.subscribers[sub.channel][sub]; !ok {
+		return
+	}
+	delete(b.subscribers[sub.channel], sub)
+	close(sub.payloads)
+}
+
+// Unsubscribes everybody listening on "channel"
+func (b *bus) unsubscribeAll(channel string) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for sub := range b.subscribers[channel] {
+		close(sub.payloads)
+	}
+	delete(b.subscribers, channel)
+}
+
+// Never blocks, subscribers that fell behind miss the payload
+func (b *bus) publish(channel string, payload string) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for sub := range b.subscribers[channel] {
+		select {
+		case sub.payloads <- payload:
+		default:
+			log.Warn().Msgf("dropping notification %q, the %q subscriber fell behind", payload, channel)
+		}
+	}
+}
+
+// Subscribes to our channel and sends notifications over "notificationsCh" from a gorutine.
+//
+// Malformed notifications are counted and dropped. The bus can't lose the subscription,
+// so the channel is closed when "ctx" is done, we receive a release or we stop listening.
+func listenOnBus(b *bus, listener *listenerState, notificationsCh chan Notification, ctx context.Context) {
+	sub := b.subscribe(config.AppName())
+	listener.connected(false)
+
+	go func() {
+		defer close(notificationsCh)
+		defer b.unsubscribe(sub)
+		for {
+			select {
+			case <-ctx.Done():
+				log.Info().Msgf("done listening for notifications. msg: %q", ctx.Err().Error())
+				return
+			case payload, ok := <-sub.payloads:
+				if !ok {
+					log.Info().Msg("done listening for notifications")
+					return
+				}
+				if deliverPayload(payload, notificationsCh) {
+					return
+				}
+			}
+		}
+	}()
+}
+
+// Storages opening the same sqlite file share a bus, so the api and the scheduler
+// see each other's notifications even when they don't share the storage
+var buses = struct {
+	lock  sync.Mutex
+	byKey map[string]*sharedBus
+}{byKey: map[string]*sharedBus{}}
+
+type sharedBus struct {
+	bus  *bus
+	refs int
+}
+
+// Returns the bus of "key" and a func to release it, it is forgotten when nobody uses it
+func acquireBus(key string) (*bus, func()) {
+	buses.lock.Lock()
+	defer buses.lock.Unlock()
+	shared, ok := buses.byKey[key]
+	if !ok {
+		shared = &sharedBus{bus: newBus()}
+		buses.byKey[key] = shared
+	}
+	shared.refs++
+	released := false
+	return shared.bus, func() {
+		buses.lock.Lock()
+		defer buses.lock.Unlock()
+		if released {
+			return
+		}
+		released = true
+		shared.refs--
+		if shared.refs == 0 {
+			delete(buses.byKey, key)
+		}
+	}
+}
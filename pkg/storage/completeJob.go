@@ -0,0 +1,20 @@
+package storage
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gofrs/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// Marks a job that won't run again (like one-shot jobs) as completed, so nobody claims it again
+func (sqls *SQLStorage) CompleteJob(jobId uuid.UUID) error {
+	ctx := context.Background()
+	_, err := sqls.Db.Exec(ctx, "UPDATE ruok.jobs SET status = 'completed' WHERE id = $1", jobId)
+	if err != nil {
+		log.Error().Err(err).Msgf("could not mark job %v as completed", jobId)
+		return errors.New("could not update jobs")
+	}
+	return nil
+}
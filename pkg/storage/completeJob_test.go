@@ -0,0 +1,29 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompleteJob(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, s *testStorage) {
+		s.seed()
+
+		joblist := s.GetAvailableJobs(100, nil)
+		assert.Len(t, joblist, 10)
+
+		err := s.CompleteJob(joblist[0].Id)
+		assert.NoError(t, err)
+
+		var status string
+		err = s.queryRow("select status from ruok.jobs where id = $1", joblist[0].Id).Scan(&status)
+		assert.NoError(t, err)
+		assert.Equal(t, "completed", status)
+
+		// completed jobs can't be claimed again
+		err = s.ReleaseAll(joblist[1:])
+		assert.NoError(t, err)
+		assert.Len(t, s.GetAvailableJobs(100, nil), 9)
+	})
+}
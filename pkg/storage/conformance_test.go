@@ -0,0 +1,53 @@
+package storage_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/back-end-labs/ruok/pkg/config"
+	"github.com/back-end-labs/ruok/pkg/storage"
+	"github.com/back-end-labs/ruok/pkg/storage/storagetest"
+)
+
+// Kinds checked by the conformance suite, the memory storage needs nothing so it always runs.
+// TEST_STORAGE_KINDS=sqlite runs them without a postgres server.
+func conformanceKinds() []string {
+	kinds := []string{config.POSTGRES_STORAGE, config.SQLITE_STORAGE}
+	if env := os.Getenv("TEST_STORAGE_KINDS"); env != "" {
+		kinds = strings.Split(env, ",")
+	}
+	for _, kind := range kinds {
+		if kind == config.MEMORY_STORAGE {
+			return kinds
+		}
+	}
+	return append(kinds, config.MEMORY_STORAGE)
+}
+
+func TestConformance(t *testing.T) {
+	// the suite runs as a scheduler with a location, so jobs with and without locations are covered
+	t.Setenv(config.LOCATION, "conformance")
+	config.Reset()
+	t.Cleanup(config.Reset)
+	for _, kind := range conformanceKinds() {
+		t.Run(kind, func(t *testing.T) {
+			storagetest.Run(t, func(t *testing.T) storage.Storage {
+				cfg := config.FromEnvs()
+				cfg.Kind = kind
+				cfg.SQLitePath = filepath.Join(t.TempDir(), "ruok.db")
+				if kind == config.POSTGRES_STORAGE {
+					storage.Drop()
+					t.Cleanup(storage.Drop)
+				}
+				s, close := storage.NewStorage(&cfg)
+				t.Cleanup(func() {
+					s.FlushResults()
+					close()
+				})
+				return s
+			})
+		})
+	}
+}
@@ -1,13 +1,14 @@
 package storage
 
 import (
-	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
 
 	"github.com/gofrs/uuid"
 	"github.com/rs/zerolog/log"
+
+	"github.com/back-end-labs/ruok/pkg/job"
 )
 
 var createJobWithNoAlerts = `
@@ -19,8 +20,14 @@ INSERT INTO ruok.jobs (
 	httpmethod,
 	max_retries,
 	success_statuses,
-	status
-) VALUES ($1, $2, $3, $4, $5, $6, $7, $8);
+	status,
+	timezone,
+	results_retention_days,
+	labels,
+	locations,
+	alert_quorum,
+	updated_by
+) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14);
 `
 
 var createJobWithAlerts = `
@@ -37,13 +44,20 @@ INSERT INTO ruok.jobs (
 	alert_endpoint,
 	alert_method,
 	alert_headers_string,
-	alert_payload
-) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13);
+	alert_payload,
+	timezone,
+	results_retention_days,
+	labels,
+	locations,
+	alert_quorum,
+	updated_by
+) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19);
 `
 
 type CreateJobInput struct {
 	Name            string            `json:"name"`
 	CronExpString   string            `json:"cronexp"`
+	Timezone        string            `json:"timezone"`
 	MaxRetries      int               `json:"maxRetries"`
 	Endpoint        string            `json:"endpoint"`
 	HttpMethod      string            `json:"httpmethod"`
@@ -54,22 +68,39 @@ type CreateJobInput struct {
 	AlertEndpoint   string            `json:"alertEndpoint"`
 	AlertPayload    string            `json:"alertPayload"`
 	AlertHeaders    map[string]string `json:"alertHeaders"`
+	Labels          map[string]string `json:"labels"`
+	// Every location runs its own copy of the job, empty runs it once anywhere
+	Locations []string `json:"locations"`
+	// How many locations must fail the same round before alerting. 0 alerts on any failure
+	AlertQuorum int `json:"alertQuorum"`
+	// Days results are kept before being compacted. Empty uses the global setting, 0 keeps them forever
+	ResultsRetentionDays *int `json:"resultsRetentionDays"`
+	// Who is creating the job, taken from the caller and never from the body
+	UpdatedBy string `json:"-"`
 }
 
-func (sqls *SQLStorage) CreateJob(j CreateJobInput) error {
+func (sqls *SQLStorage) CreateJob(j CreateJobInput) (uuid.UUID, error) {
 	id, err := uuid.NewV7()
 	if err != nil {
 		log.Error().Err(err).Msg("could not create uuidv7 for new job")
-		return err
+		return uuid.Nil, err
 	}
 
-	ctx := context.Background()
+	ctx := sqls.tenantContext()
 	tx, err := sqls.Db.Begin(ctx)
 	defer tx.Rollback(ctx)
 
 	if err != nil {
 		log.Error().Err(err).Msg("could not start transaction to create job")
-		return errors.New("could not insert into jobs")
+		return uuid.Nil, errors.New("could not insert into jobs")
+	}
+
+	if err := sqls.checkMaxJobs(ctx, tx); err != nil {
+		if errors.Is(err, ErrQuotaExceeded) {
+			return uuid.Nil, err
+		}
+		log.Error().Err(err).Msg("could not check the quota of the tenant")
+		return uuid.Nil, errors.New("could not insert into jobs")
 	}
 
 	if HasMinAlertFields(j.AlertStrategy, j.AlertEndpoint, j.AlertMethod) {
@@ -103,6 +134,12 @@ func (sqls *SQLStorage) CreateJob(j CreateJobInput) error {
 			j.AlertMethod,
 			alertHeadersString,
 			alertPayload,
+			TimezoneOrDefault(j.Timezone),
+			j.ResultsRetentionDays,
+			labelsJSON(j.Labels),
+			locationsOrEmpty(j.Locations),
+			nullQuorum(j.AlertQuorum),
+			nullString(j.UpdatedBy),
 		)
 	} else {
 		_, err = tx.Exec(ctx, createJobWithNoAlerts,
@@ -114,20 +151,31 @@ func (sqls *SQLStorage) CreateJob(j CreateJobInput) error {
 			j.MaxRetries,
 			j.SuccessStatuses,
 			"pending to be claimed",
+			TimezoneOrDefault(j.Timezone),
+			j.ResultsRetentionDays,
+			labelsJSON(j.Labels),
+			locationsOrEmpty(j.Locations),
+			nullQuorum(j.AlertQuorum),
+			nullString(j.UpdatedBy),
 		)
 
 	}
 
 	if err != nil {
 		log.Error().Err(err).Msg("could not insert into jobs")
-		return errors.New("could not insert into job")
+		return uuid.Nil, errors.New("could not insert into job")
+	}
+
+	if err := recordRevision(ctx, tx, id, job.ActionCreate, j.UpdatedBy, nil); err != nil {
+		log.Error().Err(err).Msg("could not record created job")
+		return uuid.Nil, errors.New("could not insert into job")
 	}
 
 	err = tx.Commit(ctx)
 
 	if err != nil {
 		log.Error().Err(err).Msg("could not commit transaction to insert into job")
-		return errors.New("could not commit transaction into job")
+		return uuid.Nil, errors.New("could not commit transaction into job")
 	}
-	return nil
+	return id, nil
 }
@@ -3,13 +3,11 @@ package storage
 import (
 	"testing"
 
-	"github.com/back-end-labs/ruok/pkg/config"
 	"github.com/back-end-labs/ruok/pkg/job"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestCreateJob(t *testing.T) {
-	defer Drop()
 	tests := []struct {
 		name       string
 		job        CreateJobInput
@@ -35,6 +33,7 @@ func TestCreateJob(t *testing.T) {
 				assert.Equal(t, 3, j.MaxRetries)
 				assert.Equal(t, "claimed", j.Status)
 				assert.ElementsMatch(t, []int{200}, j.SuccessStatuses)
+				assert.Equal(t, "UTC", j.Timezone)
 			},
 		},
 		{
@@ -42,6 +41,7 @@ func TestCreateJob(t *testing.T) {
 			job: CreateJobInput{
 				Name:            "testing job with alerts",
 				CronExpString:   "*/1 * * * *",
+				Timezone:        "Europe/Madrid",
 				Endpoint:        "/test",
 				HttpMethod:      "GET",
 				MaxRetries:      3,
@@ -65,34 +65,33 @@ func TestCreateJob(t *testing.T) {
 				assert.Equal(t, "test@example.com", j.AlertEndpoint)
 				assert.Equal(t, "POST", j.AlertMethod)
 				assert.Equal(t, "alert payload", j.AlertPayload)
+				assert.Equal(t, "Europe/Madrid", j.Timezone)
 				assert.Equal(t, map[string]string{"Content-Type": "application/json"}, j.AlertHeaders)
 			},
 		},
 	}
 
-	cfg := config.FromEnvs()
-	s, close := NewStorage(&cfg)
-	defer close()
+	forEachStorage(t, func(t *testing.T, s *testStorage) {
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				s.drop()
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			Drop()
+				_, err := s.CreateJob(tt.job)
 
-			err := s.CreateJob(tt.job)
+				if tt.expectErr {
+					assert.Error(t, err, "expected an error, but got none")
+				} else {
+					assert.NoError(t, err, "expected no error, but got one")
+				}
 
-			if tt.expectErr {
-				assert.Error(t, err, "expected an error, but got none")
-			} else {
-				assert.NoError(t, err, "expected no error, but got one")
-			}
+				createdJobs := s.GetAvailableJobs(100, nil)
 
-			createdJobs := s.GetAvailableJobs(100)
+				assert.Len(t, createdJobs, 1)
 
-			assert.Len(t, createdJobs, 1)
-
-			if tt.assertFunc != nil {
-				tt.assertFunc(t, createdJobs[0])
-			}
-		})
-	}
+				if tt.assertFunc != nil {
+					tt.assertFunc(t, createdJobs[0])
+				}
+			})
+		}
+	})
 }
@@ -0,0 +1,39 @@
+package storage
+
+import "errors"
+
+// Kinds of errors callers can do something about, see KindError. ErrNotFound is one of them too
+var (
+	// The resource exists but its state doesn't allow the change
+	ErrConflict = errors.New("conflict")
+	// The input can't be used, like a cursor made for another sort
+	ErrInvalid = errors.New("invalid input")
+	// The caller is not allowed to make the change, like going over a quota
+	ErrForbidden = errors.New("forbidden")
+)
+
+// An error of a kind, so errors.Is(err, ErrConflict) tells callers how to handle it
+// while the code and the message say what happened
+type KindError struct {
+	Kind error
+	// Short and stable, like "not_claimed"
+	Code string
+	Msg  string
+}
+
+func (e *KindError) Error() string {
+	return e.Msg
+}
+
+func (e *KindError) Unwrap() error {
+	return e.Kind
+}
+
+// Returned when a job can't take a change in its current state, like resuming a job that is not paused
+var ErrWrongState = &KindError{ErrConflict, "wrong_state", "the job can't take the change in its current state"}
+
+// Returned when planning changes with a storage that can't see the jobs claimed by other schedulers, see SeesEveryJob
+var ErrHiddenJobs = &KindError{ErrConflict, "hidden_jobs", "jobs claimed by other schedulers are hidden from this database user, use one with the RUOK_JOBS_MANAGER role"}
+
+// Returned when creating or restoring a job goes over the jobs the tenant can have, see WithMaxJobs
+var ErrQuotaExceeded = &KindError{ErrForbidden, "quota_exceeded", "the tenant already has as many jobs as its quota allows"}
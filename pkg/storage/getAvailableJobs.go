@@ -12,10 +12,23 @@ import (
 
 	"github.com/back-end-labs/ruok/pkg/config"
 	"github.com/back-end-labs/ruok/pkg/job"
+	"github.com/back-end-labs/ruok/pkg/labels"
 )
 
-// Gets pending to be claimed jobs from the db and returns a list of all jobs that could be claimed
-func (sqls *SQLStorage) GetAvailableJobs(limit int) []*job.Job {
+// Claims of a location happen while holding the lock of the jobs row, so nobody else claims it meanwhile
+var claimJobLocationQuery = `
+INSERT INTO ruok.job_locations (job_id, location, claimed_by) VALUES ($1, $2, $3)
+ON CONFLICT (job_id, location) DO UPDATE SET claimed_by = excluded.claimed_by
+WHERE ruok.job_locations.claimed_by IS NULL;
+`
+
+// Gets pending to be claimed jobs from the db and returns a list of all jobs that could be claimed.
+// Only jobs matching the selector are claimed, an empty selector claims any job.
+//
+// Jobs with locations are claimed per location: our location is claimed in ruok.job_locations
+// and their jobs row stays unclaimed, so the schedulers of the other locations can claim theirs.
+// Schedulers without a location never claim them.
+func (sqls *SQLStorage) GetAvailableJobs(limit int, selector labels.Selector) []*job.Job {
 	ctx := context.Background()
 	tx, err := sqls.Db.Begin(ctx)
 	if err != nil {
@@ -23,6 +36,7 @@ func (sqls *SQLStorage) GetAvailableJobs(limit int) []*job.Job {
 		return nil
 	}
 	defer tx.Rollback(ctx)
+	matches, args := pgSelector(selector, 3)
 	rows, err := tx.Query(ctx, `
 SELECT
 	id,
@@ -44,11 +58,24 @@ SELECT
 	alert_endpoint,
 	alert_method,
 	alert_headers_string,
-	alert_payload
+	alert_payload,
+	timezone,
+	updated_at,
+	labels,
+	locations,
+	alert_quorum,
+	CASE WHEN cardinality(locations) = 0 THEN '' ELSE $2 END AS location
  FROM ruok.jobs 
- WHERE status = 'pending to be claimed' 
+ WHERE status = 'pending to be claimed' AND `+matches+`
+ AND (
+	cardinality(locations) = 0
+	OR ($2 <> '' AND $2 = ANY(locations) AND NOT EXISTS (
+		SELECT FROM ruok.job_locations l
+		WHERE l.job_id = jobs.id AND l.location = $2 AND l.claimed_by IS NOT NULL
+	))
+ )
  FOR UPDATE SKIP LOCKED
- LIMIT  $1;`, limit)
+ LIMIT  $1;`, append([]any{limit, config.Location()}, args...)...)
 
 	if err != nil {
 		log.Error().Err(err).Msg("could not query rows to get available jobs")
@@ -79,6 +106,12 @@ SELECT
 		var AlertMethod sql.NullString
 		var AlertHeadersString sql.NullString
 		var AlertPayload sql.NullString
+		var Timezone string
+		var UpdatedAt sql.NullInt64
+		var Labels string
+		var Locations []string
+		var AlertQuorum sql.NullInt32
+		var Location string
 
 		err = rows.Scan(
 			&Id,
@@ -101,6 +134,12 @@ SELECT
 			&AlertMethod,
 			&AlertHeadersString,
 			&AlertPayload,
+			&Timezone,
+			&UpdatedAt,
+			&Labels,
+			&Locations,
+			&AlertQuorum,
+			&Location,
 		)
 		if err != nil {
 			log.Error().Err(err).Msg("could not scan available jobs row")
@@ -144,6 +183,7 @@ SELECT
 			Id:              uuid.UUID(Id),
 			Name:            Name,
 			CronExpString:   CronExpString,
+			Timezone:        Timezone,
 			Endpoint:        Endpoint,
 			HttpMethod:      HttpMethod,
 			MaxRetries:      MaxRetries,
@@ -159,11 +199,16 @@ SELECT
 			Status:          "claimed",
 			Handlers:        job.Handlers{},
 			CreatedAt:       CreatedAt,
+			UpdatedAt:       UpdatedAt.Int64,
 			AlertStrategy:   AlertStrategy.String,
 			AlertEndpoint:   AlertEndpoint.String,
 			AlertMethod:     AlertMethod.String,
 			AlertHeaders:    AlertHeaders,
 			AlertPayload:    AlertPayload.String,
+			Labels:          parseLabels(Labels),
+			Locations:       Locations,
+			AlertQuorum:     int(AlertQuorum.Int32),
+			Location:        Location,
 		}
 
 		jobsList = append(jobsList, j)
@@ -173,7 +218,9 @@ SELECT
 
 	for i := 0; i < len(jobsList); i++ {
 
-		if jobsList[i].Status == "claimed" {
+		if jobsList[i].Status == "claimed" && jobsList[i].Location != "" {
+			_, err = tx.Exec(ctx, claimJobLocationQuery, jobsList[i].Id, jobsList[i].Location, jobsList[i].ClaimedBy)
+		} else if jobsList[i].Status == "claimed" {
 			_, err = tx.Exec(
 				ctx,
 				"UPDATE ruok.jobs SET claimed_by = $1, status = 'claimed' WHERE id = $2",
@@ -1,21 +1,21 @@
 package storage
 
 import (
-	"context"
 	"database/sql"
 	"encoding/json"
 	"time"
 
 	"github.com/back-end-labs/ruok/pkg/config"
 	"github.com/back-end-labs/ruok/pkg/job"
+	"github.com/back-end-labs/ruok/pkg/labels"
 	"github.com/gofrs/uuid"
 	pgxuuid "github.com/jackc/pgx-gofrs-uuid"
 	"github.com/rs/zerolog/log"
 )
 
-// Gets get jobs claimed by this instance
-func (sqls *SQLStorage) GetClaimedJobs(limit int, offset int) []*job.Job {
-	ctx := context.Background()
+// Gets get jobs claimed by this instance that match the selector, along with the ones we claimed a location of
+func (sqls *SQLStorage) GetClaimedJobs(limit int, offset int, selector labels.Selector) []*job.Job {
+	ctx := sqls.tenantContext()
 	tx, err := sqls.Db.Begin(ctx)
 
 	if err != nil {
@@ -25,6 +25,7 @@ func (sqls *SQLStorage) GetClaimedJobs(limit int, offset int) []*job.Job {
 
 	defer tx.Rollback(ctx)
 
+	matches, args := pgSelector(selector, 4)
 	rows, err := tx.Query(ctx, `
 SELECT 
 	id,
@@ -41,13 +42,26 @@ SELECT
 	headers_string,
 	success_statuses,
 	created_at,
-	succeeded
+	succeeded,
+	timezone,
+	labels,
+	locations,
+	alert_quorum,
+	updated_by,
+	coalesce((
+		SELECT l.location FROM ruok.job_locations l
+		WHERE l.job_id = jobs.id AND l.claimed_by = $1
+		ORDER BY l.location LIMIT 1
+	), '') AS location
  FROM ruok.jobs 
- WHERE claimed_by = $1 
+ WHERE (
+	claimed_by = $1
+	OR EXISTS (SELECT FROM ruok.job_locations l WHERE l.job_id = jobs.id AND l.claimed_by = $1)
+ ) AND `+matches+`
  ORDER BY id ASC 
  LIMIT  $2
  OFFSET $3;
- `, config.AppName(), limit, offset)
+ `, append([]any{config.AppName(), limit, offset}, args...)...)
 
 	if err != nil {
 		log.Error().Err(err).Msg("could not query for claimed jobs")
@@ -73,6 +87,12 @@ SELECT
 		var SuccessStatuses []int
 		var CreatedAt int
 		var Succeeded sql.NullString
+		var Timezone string
+		var Labels string
+		var Locations []string
+		var AlertQuorum sql.NullInt32
+		var UpdatedBy sql.NullString
+		var Location string
 
 		err = rows.Scan(
 			&Id,
@@ -90,6 +110,12 @@ SELECT
 			&SuccessStatuses,
 			&CreatedAt,
 			&Succeeded,
+			&Timezone,
+			&Labels,
+			&Locations,
+			&AlertQuorum,
+			&UpdatedBy,
+			&Location,
 		)
 		if err != nil {
 			log.Error().Err(err).Msg("could not scan claimed jobs row")
@@ -108,6 +134,7 @@ SELECT
 			Id:              uuid.UUID(Id),
 			Name:            Name,
 			CronExpString:   CronExpString,
+			Timezone:        Timezone,
 			Endpoint:        Endpoint,
 			HttpMethod:      HttpMethod,
 			MaxRetries:      MaxRetries,
@@ -122,6 +149,11 @@ SELECT
 			Handlers:        job.Handlers{},
 			CreatedAt:       CreatedAt,
 			Succeeded:       Succeeded.String,
+			Labels:          parseLabels(Labels),
+			Locations:       Locations,
+			AlertQuorum:     int(AlertQuorum.Int32),
+			Location:        Location,
+			UpdatedBy:       UpdatedBy.String,
 		}
 
 		jobsList = append(jobsList, j)
@@ -1,13 +1,13 @@
 package storage
 
 import (
-	"context"
 	"database/sql"
 
 	"time"
 
 	"github.com/gofrs/uuid"
 	pgxuuid "github.com/jackc/pgx-gofrs-uuid"
+	"github.com/jackc/pgx/v5"
 
 	"github.com/rs/zerolog/log"
 
@@ -17,7 +17,7 @@ import (
 
 // Gets get jobs claimed by this instance
 func (sqls *SQLStorage) GetClaimedJobsExecutions(jobId uuid.UUID, limit int, offset int) []*job.JobExecution {
-	ctx := context.Background()
+	ctx := sqls.tenantContext()
 	tx, err := sqls.Db.Begin(ctx)
 
 	if err != nil {
@@ -42,7 +42,11 @@ SELECT
 	last_status_code,
 	success_statuses,
 	created_at,
-	succeeded
+	succeeded,
+	trigger,
+	queue_wait,
+	location,
+	claimed_by
  FROM ruok.job_results 
  WHERE claimed_by = $1 AND job_id = $2
  ORDER BY id DESC
@@ -56,6 +60,59 @@ SELECT
 
 	}
 
+	jobResultsList := scanPgExecutions(rows)
+
+	rows.Close()
+
+	err = tx.Commit(ctx)
+
+	if err != nil {
+		log.Error().Err(err).Msg("could not commit 'get claimed job executions' transaction")
+		return nil
+	}
+
+	return jobResultsList
+}
+
+// Gets the executions of a job from every scheduler, newest first.
+// The page starts after the execution "before", or from the newest one when it is uuid.Nil.
+func (sqls *SQLStorage) ListJobExecutions(jobId uuid.UUID, limit int, before uuid.UUID) []*job.JobExecution {
+	var after *uuid.UUID
+	if before != uuid.Nil {
+		after = &before
+	}
+	rows, err := sqls.Db.Query(sqls.tenantContext(), `
+SELECT
+	id,
+	job_id,
+	cron_exp_string,
+	endpoint,
+	httpmethod,
+	max_retries,
+	execution_time,
+	should_execute_at,
+	last_response_at,
+	last_message,
+	last_status_code,
+	success_statuses,
+	created_at,
+	succeeded,
+	trigger,
+	queue_wait,
+	location,
+	claimed_by
+ FROM ruok.job_executions($1, $3::uuid, $2)
+ ORDER BY id DESC;
+ `, jobId, limit, after)
+	if err != nil {
+		log.Error().Err(err).Msg("could not query for job executions")
+		return nil
+	}
+	defer rows.Close()
+	return scanPgExecutions(rows)
+}
+
+func scanPgExecutions(rows pgx.Rows) []*job.JobExecution {
 	jobResultsList := []*job.JobExecution{}
 
 	for rows.Next() {
@@ -73,8 +130,12 @@ SELECT
 		var SuccessStatuses []int
 		var CreatedAt int
 		var Succeeded sql.NullString
+		var Trigger string
+		var QueueWait int64
+		var Location sql.NullString
+		var ClaimedBy sql.NullString
 
-		err = rows.Scan(
+		err := rows.Scan(
 			&Id,
 			&JobId,
 			&CronExpString,
@@ -89,9 +150,13 @@ SELECT
 			&SuccessStatuses,
 			&CreatedAt,
 			&Succeeded,
+			&Trigger,
+			&QueueWait,
+			&Location,
+			&ClaimedBy,
 		)
 		if err != nil {
-			log.Error().Err(err).Msg("could not scan claimed job executions row")
+			log.Error().Err(err).Msg("could not scan job executions row")
 		}
 
 		j := &job.JobExecution{
@@ -106,22 +171,15 @@ SELECT
 			LastMessage:     LastMessage.String,
 			LastStatusCode:  int(LastStatusCode.Int32),
 			SuccessStatuses: SuccessStatuses,
-			ClaimedBy:       config.AppName(),
+			ClaimedBy:       ClaimedBy.String,
 			CreatedAt:       CreatedAt,
 			Succeeded:       Succeeded.String,
+			Trigger:         Trigger,
+			QueueWaitMicro:  QueueWait,
+			Location:        Location.String,
 		}
 
 		jobResultsList = append(jobResultsList, j)
 	}
-
-	rows.Close()
-
-	err = tx.Commit(ctx)
-
-	if err != nil {
-		log.Error().Err(err).Msg("could not commit 'get claimed job executions' transaction")
-		return nil
-	}
-
 	return jobResultsList
 }
@@ -0,0 +1,39 @@
+package storage
+
+import (
+	"github.com/gofrs/uuid"
+	pgxuuid "github.com/jackc/pgx-gofrs-uuid"
+	"github.com/rs/zerolog/log"
+
+	"github.com/back-end-labs/ruok/pkg/labels"
+)
+
+// Gets the ids of the jobs we can see that match the selector, deleted jobs are left out.
+// Returns nil on errors.
+func (sqls *SQLStorage) GetJobIds(selector labels.Selector) []uuid.UUID {
+	matches, args := pgSelector(selector, 1)
+	rows, err := sqls.Db.Query(sqls.tenantContext(),
+		"SELECT id FROM ruok.jobs WHERE deleted_at IS NULL AND "+matches+" ORDER BY id ASC",
+		args...,
+	)
+	if err != nil {
+		log.Error().Err(err).Msg("could not query for job ids")
+		return nil
+	}
+	defer rows.Close()
+
+	ids := []uuid.UUID{}
+	for rows.Next() {
+		var id pgxuuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			log.Error().Err(err).Msg("could not scan job id row")
+			return nil
+		}
+		ids = append(ids, uuid.UUID(id))
+	}
+	if rows.Err() != nil {
+		log.Error().Err(rows.Err()).Msg("could not read job id rows")
+		return nil
+	}
+	return ids
+}
@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/back-end-labs/ruok/pkg/config"
+	"github.com/back-end-labs/ruok/pkg/job"
+	"github.com/gofrs/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+)
+
+// Every state change releases the job so its owner drops it and no other scheduler claims it.
+// The old owner is returned so we know who should be notified.
+var pauseJobQuery = `
+WITH target AS (
+	SELECT id, claimed_by FROM ruok.jobs
+	WHERE id = $1 AND deleted_at IS NULL AND status <> 'completed'
+	FOR UPDATE
+)
+UPDATE ruok.jobs SET
+	status = 'paused',
+	claimed_by = NULL,
+	updated_by = $2,
+	updated_at = ruok.micro_unix_now()
+FROM target
+WHERE ruok.jobs.id = target.id
+RETURNING target.claimed_by;
+`
+
+var resumeJobQuery = `
+WITH target AS (
+	SELECT id, claimed_by FROM ruok.jobs
+	WHERE id = $1 AND deleted_at IS NULL AND status = 'paused'
+	FOR UPDATE
+)
+UPDATE ruok.jobs SET
+	status = 'pending to be claimed',
+	claimed_by = NULL,
+	updated_by = $2,
+	updated_at = ruok.micro_unix_now()
+FROM target
+WHERE ruok.jobs.id = target.id
+RETURNING target.claimed_by;
+`
+
+var deleteJobQuery = `
+WITH target AS (
+	SELECT id, claimed_by FROM ruok.jobs
+	WHERE id = $1 AND deleted_at IS NULL
+	FOR UPDATE
+)
+UPDATE ruok.jobs SET
+	status = 'deleted',
+	claimed_by = NULL,
+	updated_by = $2,
+	updated_at = ruok.micro_unix_now(),
+	deleted_at = ruok.micro_unix_now()
+FROM target
+WHERE ruok.jobs.id = target.id
+RETURNING target.claimed_by;
+`
+
+// Stops scheduling a job until it is resumed, "by" is who asked for it
+func (sqls *SQLStorage) PauseJob(jobId uuid.UUID, by string) error {
+	return sqls.changeJobState(jobId, by, pauseJobQuery, job.ActionPause, EventPaused)
+}
+
+// Makes a paused job available to be claimed again
+func (sqls *SQLStorage) ResumeJob(jobId uuid.UUID, by string) error {
+	return sqls.changeJobState(jobId, by, resumeJobQuery, job.ActionResume, EventUpdated)
+}
+
+// Soft deletes a job
+func (sqls *SQLStorage) DeleteJob(jobId uuid.UUID, by string) error {
+	return sqls.changeJobState(jobId, by, deleteJobQuery, job.ActionDelete, EventDeleted)
+}
+
+// Runs a state change query and notifies the scheduler owning the job.
+// Unclaimed jobs are notified to our own channel, so resumed jobs are claimed right away.
+// Jobs with locations are released from every location and their schedulers notified too.
+// "action" is recorded in the history of the job.
+func (sqls *SQLStorage) changeJobState(jobId uuid.UUID, by string, query string, action string, event string) error {
+	ctx := sqls.tenantContext()
+	tx, err := sqls.Db.Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msgf("could not start transaction to %s job", action)
+		return errors.New("could not " + action + " job")
+	}
+	defer tx.Rollback(ctx)
+
+	before, err := jobSnapshot(ctx, tx, jobId)
+	if errors.Is(err, ErrNotFound) {
+		return ErrNotFound
+	}
+	if err != nil {
+		log.Error().Err(err).Msgf("could not get job %v to %s it", jobId, action)
+		return errors.New("could not " + action + " job")
+	}
+
+	var owner sql.NullString
+	err = tx.QueryRow(ctx, query, jobId, nullString(by)).Scan(&owner)
+
+	if errors.Is(err, pgx.ErrNoRows) && before.Deleted {
+		return ErrNotFound
+	}
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrWrongState
+	}
+
+	if err != nil {
+		log.Error().Err(err).Msgf("could not %s job %v", action, jobId)
+		return errors.New("could not " + action + " job")
+	}
+
+	if err := recordRevision(ctx, tx, jobId, action, by, before); err != nil {
+		log.Error().Err(err).Msgf("could not record %s of job %v", action, jobId)
+		return errors.New("could not " + action + " job")
+	}
+
+	channel := config.AppName()
+	if owner.Valid {
+		channel = owner.String
+	}
+
+	owners, err := releaseLocations(ctx, tx, jobId)
+
+	if err != nil {
+		log.Error().Err(err).Msgf("could not release locations to %s job %v", action, jobId)
+		return errors.New("could not " + action + " job")
+	}
+
+	err = notifyAll(ctx, tx, append([]string{channel}, owners...), NewNotification(event, jobId))
+
+	if err != nil {
+		log.Error().Err(err).Msgf("could not notify %s of job %v", action, jobId)
+		return errors.New("could not notify " + action + " of job")
+	}
+
+	err = tx.Commit(ctx)
+
+	if err != nil {
+		log.Error().Err(err).Msgf("could not commit transaction to %s job", action)
+		return errors.New("could not commit transaction to " + action + " job")
+	}
+	return nil
+}
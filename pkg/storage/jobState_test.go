@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPauseResumeAndDeleteJob(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, s *testStorage) {
+		s.seed()
+		joblist := s.GetAvailableJobs(100, nil)
+		assert.Len(t, joblist, 10)
+		claimed, unclaimed := joblist[0].Id, joblist[1].Id
+		assert.NoError(t, s.ReleaseAll(joblist[1:]))
+
+		getState := func(id uuid.UUID) (string, bool, bool) {
+			var status string
+			var claimedBy *string
+			var deletedAt *int64
+			err := s.queryRow("select status, claimed_by, deleted_at from ruok.jobs where id = $1", id).Scan(&status, &claimedBy, &deletedAt)
+			assert.NoError(t, err)
+			return status, claimedBy != nil, deletedAt != nil
+		}
+
+		// pausing releases the job so nobody claims it
+		assert.NoError(t, s.PauseJob(claimed, "tester"))
+		status, isClaimed, _ := getState(claimed)
+		assert.Equal(t, "paused", status)
+		assert.False(t, isClaimed)
+		assert.Len(t, s.GetAvailableJobs(100, nil), 9)
+		assert.NoError(t, s.ReleaseAll(joblist[1:]))
+
+		// only paused jobs can be resumed
+		assert.ErrorIs(t, s.ResumeJob(unclaimed, "tester"), ErrWrongState)
+		assert.ErrorIs(t, s.ResumeJob(unclaimed, "tester"), ErrConflict)
+		assert.NoError(t, s.ResumeJob(claimed, "tester"))
+		status, _, _ = getState(claimed)
+		assert.Equal(t, "pending to be claimed", status)
+
+		assert.NoError(t, s.DeleteJob(unclaimed, "tester"))
+		status, _, isDeleted := getState(unclaimed)
+		assert.Equal(t, "deleted", status)
+		assert.True(t, isDeleted)
+		assert.ErrorIs(t, s.DeleteJob(unclaimed, "tester"), ErrNotFound)
+		assert.ErrorIs(t, s.PauseJob(unclaimed, "tester"), ErrNotFound)
+
+		unknown, _ := uuid.NewV7()
+		assert.ErrorIs(t, s.PauseJob(unknown, "tester"), ErrNotFound)
+
+		assert.Len(t, s.GetAvailableJobs(100, nil), 9)
+	})
+}
@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/back-end-labs/ruok/pkg/labels"
+)
+
+// Builds the conditions of a selector on the labels column, its params are numbered from "next".
+// Empty selectors match every job.
+func pgSelector(s labels.Selector, next int) (string, []any) {
+	conditions := []string{"TRUE"}
+	args := []any{}
+	for _, r := range s {
+		switch r.Operator {
+		case labels.Equals, labels.NotEquals:
+			// @> can use the gin index of the column
+			contains, _ := json.Marshal(map[string]string{r.Key: r.Value})
+			condition := fmt.Sprintf("labels @> $%d::jsonb", next)
+			if r.Operator == labels.NotEquals {
+				condition = "NOT " + condition
+			}
+			conditions = append(conditions, condition)
+			args = append(args, string(contains))
+		case labels.Exists:
+			conditions = append(conditions, fmt.Sprintf("labels ? $%d", next))
+			args = append(args, r.Key)
+		case labels.NotExists:
+			conditions = append(conditions, fmt.Sprintf("NOT labels ? $%d", next))
+			args = append(args, r.Key)
+		}
+		next++
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+// Same as pgSelector for the labels of sqlite, which are kept as a json object in a text column
+func sqliteSelector(s labels.Selector, next int) (string, []any) {
+	conditions := []string{"TRUE"}
+	args := []any{}
+	for _, r := range s {
+		// keys can't have quotes, so they are safe to use in a json path
+		path := fmt.Sprintf(`$."%s"`, r.Key)
+		switch r.Operator {
+		case labels.Equals:
+			conditions = append(conditions, fmt.Sprintf("json_extract(labels, $%d) = $%d", next, next+1))
+			args = append(args, path, r.Value)
+			next += 2
+		case labels.NotEquals:
+			conditions = append(conditions, fmt.Sprintf("coalesce(json_extract(labels, $%d) <> $%d, TRUE)", next, next+1))
+			args = append(args, path, r.Value)
+			next += 2
+		case labels.Exists:
+			conditions = append(conditions, fmt.Sprintf("json_type(labels, $%d) IS NOT NULL", next))
+			args = append(args, path)
+			next++
+		case labels.NotExists:
+			conditions = append(conditions, fmt.Sprintf("json_type(labels, $%d) IS NULL", next))
+			args = append(args, path)
+			next++
+		}
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+// Labels as a json object, jobs without labels have an empty one
+func labelsJSON(l map[string]string) string {
+	if len(l) == 0 {
+		return "{}"
+	}
+	b, err := json.Marshal(l)
+	if err != nil {
+		log.Error().Err(err).Msg("could not convert labels to json string")
+		return "{}"
+	}
+	return string(b)
+}
+
+// Reads the labels column, jobs always get a map even if it's empty
+func parseLabels(s string) map[string]string {
+	l := map[string]string{}
+	if s == "" {
+		return l
+	}
+	if err := json.Unmarshal([]byte(s), &l); err != nil {
+		log.Error().Err(err).Msg("could not unmarshal labels")
+	}
+	return l
+}
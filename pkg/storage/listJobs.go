@@ -0,0 +1,398 @@
+package storage
+
+import (
+	"cmp"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+	pgxuuid "github.com/jackc/pgx-gofrs-uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+
+	"github.com/back-end-labs/ruok/pkg/job"
+	"github.com/back-end-labs/ruok/pkg/labels"
+)
+
+// Fields jobs can be sorted by, named like in the API
+const (
+	SortById            = "id"
+	SortByName          = "name"
+	SortByCreatedAt     = "createdAt"
+	SortByLastExecution = "lastExecution"
+)
+
+// Column every sort field orders by. Names sort ignoring case and jobs that never ran sort as if they ran at 0
+var jobSortColumns = map[string]string{
+	SortById:            "id",
+	SortByName:          "lower(job_name)",
+	SortByCreatedAt:     "created_at",
+	SortByLastExecution: "coalesce(last_execution, 0)",
+}
+
+// TRUE if jobs can be sorted by the field
+func IsJobSortField(field string) bool {
+	_, ok := jobSortColumns[field]
+	return ok
+}
+
+// Returned when a cursor can't be read or was made for another sort
+var ErrInvalidCursor = &KindError{ErrInvalid, "invalid_cursor", "invalid cursor"}
+
+type ListJobsInput struct {
+	// Max amount of jobs in the page
+	Limit int
+	// Where the page starts, the NextCursor of the previous page. Empty starts from the first job
+	Cursor string
+	// Only jobs with this status, like "paused"
+	Status string
+	// Only jobs whose last execution had this result, "ok" or "error"
+	Succeeded string
+	// Only jobs whose name contains it, ignoring case
+	Name     string
+	Selector labels.Selector
+	// One of the SortBy fields, SortById when empty
+	SortBy     string
+	Descending bool
+}
+
+// A page of jobs and where the next one starts, empty on the last page
+type JobsPage struct {
+	Jobs       []*job.Job
+	NextCursor string
+}
+
+// Position of the last job of a page. It is sent as base64 json so clients treat it as opaque
+type jobsCursor struct {
+	SortBy     string    `json:"s"`
+	Descending bool      `json:"d,omitempty"`
+	Text       string    `json:"t,omitempty"`
+	Number     int64     `json:"n,omitempty"`
+	Id         uuid.UUID `json:"id"`
+}
+
+func (in ListJobsInput) sortBy() string {
+	if in.SortBy == "" {
+		return SortById
+	}
+	return in.SortBy
+}
+
+// Position of the job in the sort of the input
+func (in ListJobsInput) sortKey(j *job.Job) jobsCursor {
+	c := jobsCursor{SortBy: in.sortBy(), Descending: in.Descending, Id: j.Id}
+	switch c.SortBy {
+	case SortByName:
+		c.Text = strings.ToLower(j.Name)
+	case SortByCreatedAt:
+		c.Number = int64(j.CreatedAt)
+	case SortByLastExecution:
+		c.Number = j.LastExecution.UnixMicro()
+	}
+	return c
+}
+
+// Cursor of the page that starts after "j"
+func (in ListJobsInput) cursorAfter(j *job.Job) string {
+	raw, _ := json.Marshal(in.sortKey(j))
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// Reads the cursor of the input, nil when the page starts from the first job
+func (in ListJobsInput) cursor() (*jobsCursor, error) {
+	if in.Cursor == "" {
+		return nil, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(in.Cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	c := &jobsCursor{}
+	if err := json.Unmarshal(raw, c); err != nil || c.SortBy != in.sortBy() || c.Descending != in.Descending {
+		return nil, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// Builds the conditions and order of a ListJobs query for the sql storages, params are numbered from "next".
+// "selector" builds the conditions of the labels and "contains" matches part of the name, with %d for its param.
+// Deleted jobs are left out.
+func listJobsQuery(in ListJobsInput, next int, selector func(labels.Selector, int) (string, []any), contains string) (string, []any, error) {
+	c, err := in.cursor()
+	if err != nil {
+		return "", nil, err
+	}
+	sortBy := in.sortBy()
+	column, ok := jobSortColumns[sortBy]
+	if !ok {
+		return "", nil, fmt.Errorf("jobs can't be sorted by %q", sortBy)
+	}
+
+	conditions := []string{"deleted_at IS NULL"}
+	args := []any{}
+	param := func(value any) string {
+		args = append(args, value)
+		next++
+		return fmt.Sprintf("$%d", next-1)
+	}
+	if in.Status != "" {
+		conditions = append(conditions, "status = "+param(in.Status))
+	}
+	if in.Succeeded != "" {
+		conditions = append(conditions, "succeeded = "+param(in.Succeeded))
+	}
+	if in.Name != "" {
+		args = append(args, in.Name)
+		conditions = append(conditions, fmt.Sprintf(contains, next))
+		next++
+	}
+	matches, selectorArgs := selector(in.Selector, next)
+	conditions = append(conditions, matches)
+	args = append(args, selectorArgs...)
+	next += len(selectorArgs)
+
+	direction, after := "ASC", ">"
+	if in.Descending {
+		direction, after = "DESC", "<"
+	}
+	if c != nil {
+		id := param(c.Id.String())
+		switch sortBy {
+		case SortById:
+			conditions = append(conditions, fmt.Sprintf("id %s %s", after, id))
+		case SortByName:
+			value := param(c.Text)
+			conditions = append(conditions, fmt.Sprintf("(%[1]s %[2]s %[3]s OR (%[1]s = %[3]s AND id %[2]s %[4]s))", column, after, value, id))
+		default:
+			value := param(c.Number)
+			conditions = append(conditions, fmt.Sprintf("(%[1]s %[2]s %[3]s OR (%[1]s = %[3]s AND id %[2]s %[4]s))", column, after, value, id))
+		}
+	}
+
+	// one more than the limit tells if there is a next page
+	query := fmt.Sprintf(" WHERE %s ORDER BY %s %s, id %s LIMIT %s",
+		strings.Join(conditions, " AND "), column, direction, direction, param(in.Limit+1))
+	return query, args, nil
+}
+
+// Cuts the extra job listJobsQuery asks for and makes the cursor of the next page out of it
+func newJobsPage(in ListJobsInput, jobs []*job.Job) *JobsPage {
+	if len(jobs) <= in.Limit {
+		return &JobsPage{Jobs: jobs}
+	}
+	jobs = jobs[:in.Limit]
+	return &JobsPage{Jobs: jobs, NextCursor: in.cursorAfter(jobs[len(jobs)-1])}
+}
+
+// Columns a job definition is made of, the same on every sql storage
+var jobColumns = `
+	id,
+	job_name,
+	cron_exp_string,
+	timezone,
+	endpoint,
+	httpmethod,
+	max_retries,
+	headers_string,
+	success_statuses,
+	alert_strategy,
+	alert_endpoint,
+	alert_method,
+	alert_headers_string,
+	alert_payload,
+	labels,
+	locations,
+	alert_quorum,
+	results_retention_days,
+	status,
+	succeeded,
+	claimed_by,
+	last_execution,
+	should_execute_at,
+	last_response_at,
+	last_message,
+	last_status_code,
+	created_at,
+	updated_at,
+	updated_by`
+
+// Everything nullable in a row of jobColumns, so both sql storages can build the job the same way
+type jobRow struct {
+	maxRetries           sql.NullInt64
+	headers              sql.NullString
+	alertStrategy        sql.NullString
+	alertEndpoint        sql.NullString
+	alertMethod          sql.NullString
+	alertHeaders         sql.NullString
+	alertPayload         sql.NullString
+	labels               string
+	alertQuorum          sql.NullInt32
+	resultsRetentionDays sql.NullInt32
+	status               sql.NullString
+	succeeded            sql.NullString
+	claimedBy            sql.NullString
+	lastExecution        sql.NullInt64
+	shouldExecuteAt      sql.NullInt64
+	lastResponseAt       sql.NullInt64
+	lastMessage          sql.NullString
+	lastStatusCode       sql.NullInt32
+	updatedAt            sql.NullInt64
+	updatedBy            sql.NullString
+}
+
+// Targets of jobColumns, "id", "successStatuses" and "locations" depend on the storage
+func (r *jobRow) targets(j *job.Job, id any, successStatuses any, locations any) []any {
+	return []any{
+		id,
+		&j.Name,
+		&j.CronExpString,
+		&j.Timezone,
+		&j.Endpoint,
+		&j.HttpMethod,
+		&r.maxRetries,
+		&r.headers,
+		successStatuses,
+		&r.alertStrategy,
+		&r.alertEndpoint,
+		&r.alertMethod,
+		&r.alertHeaders,
+		&r.alertPayload,
+		&r.labels,
+		locations,
+		&r.alertQuorum,
+		&r.resultsRetentionDays,
+		&r.status,
+		&r.succeeded,
+		&r.claimedBy,
+		&r.lastExecution,
+		&r.shouldExecuteAt,
+		&r.lastResponseAt,
+		&r.lastMessage,
+		&r.lastStatusCode,
+		&j.CreatedAt,
+		&r.updatedAt,
+		&r.updatedBy,
+	}
+}
+
+func (r *jobRow) fill(j *job.Job) *job.Job {
+	j.MaxRetries = int(r.maxRetries.Int64)
+	j.Headers = map[string]string{}
+	if r.headers.Valid && r.headers.String != "" {
+		if err := json.Unmarshal([]byte(r.headers.String), &j.Headers); err != nil {
+			log.Error().Err(err).Msgf("could not unmarshal headers of job %v", j.Id)
+		}
+	}
+	j.AlertStrategy = r.alertStrategy.String
+	j.AlertEndpoint = r.alertEndpoint.String
+	j.AlertMethod = r.alertMethod.String
+	j.AlertHeaders = map[string]string{}
+	if r.alertHeaders.Valid && r.alertHeaders.String != "" {
+		if err := json.Unmarshal([]byte(r.alertHeaders.String), &j.AlertHeaders); err != nil {
+			log.Error().Err(err).Msgf("could not unmarshal alert headers of job %v", j.Id)
+		}
+	}
+	j.AlertPayload = r.alertPayload.String
+	j.Labels = parseLabels(r.labels)
+	j.AlertQuorum = int(r.alertQuorum.Int32)
+	if r.resultsRetentionDays.Valid {
+		days := int(r.resultsRetentionDays.Int32)
+		j.ResultsRetentionDays = &days
+	}
+	j.Status = r.status.String
+	j.Succeeded = r.succeeded.String
+	j.ClaimedBy = r.claimedBy.String
+	j.LastExecution = time.UnixMicro(r.lastExecution.Int64)
+	j.ShouldExecuteAt = time.UnixMicro(r.shouldExecuteAt.Int64)
+	j.LastResponseAt = time.UnixMicro(r.lastResponseAt.Int64)
+	j.LastMessage = r.lastMessage.String
+	j.LastStatusCode = int(r.lastStatusCode.Int32)
+	j.UpdatedAt = r.updatedAt.Int64
+	j.UpdatedBy = r.updatedBy.String
+	j.Handlers = job.Handlers{}
+	if j.SuccessStatuses == nil {
+		j.SuccessStatuses = []int{}
+	}
+	if j.Locations == nil {
+		j.Locations = []string{}
+	}
+	return j
+}
+
+func scanPgJob(row pgx.Row) (*job.Job, error) {
+	var r jobRow
+	var id pgxuuid.UUID
+	j := &job.Job{}
+	if err := row.Scan(r.targets(j, &id, &j.SuccessStatuses, &j.Locations)...); err != nil {
+		return nil, err
+	}
+	j.Id = uuid.UUID(id)
+	return r.fill(j), nil
+}
+
+// Gets the definition of a job, wherever it is claimed. Deleted jobs are not found.
+// Jobs are read through ruok.tenant_jobs() so schedulers see the ones other schedulers claimed too.
+func (sqls *SQLStorage) GetJob(jobId uuid.UUID) (*job.Job, error) {
+	j, err := scanPgJob(sqls.Db.QueryRow(sqls.tenantContext(),
+		"SELECT "+jobColumns+" FROM ruok.tenant_jobs() jobs WHERE id = $1",
+		jobId,
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		log.Error().Err(err).Msgf("could not get job %v", jobId)
+		return nil, errors.New("could not get job")
+	}
+	return j, nil
+}
+
+// Lists the jobs of every scheduler that match the filters, a page at a time.
+// Like GetJob, it doesn't depend on the jobs row level security lets the db user see.
+func (sqls *SQLStorage) ListJobs(in ListJobsInput) (*JobsPage, error) {
+	where, args, err := listJobsQuery(in, 1, pgSelector, "strpos(lower(job_name), lower($%d)) > 0")
+	if err != nil {
+		return nil, err
+	}
+	rows, err := sqls.Db.Query(sqls.tenantContext(), "SELECT "+jobColumns+" FROM ruok.tenant_jobs() jobs"+where, args...)
+	if err != nil {
+		log.Error().Err(err).Msg("could not query for jobs")
+		return nil, errors.New("could not list jobs")
+	}
+	defer rows.Close()
+
+	jobs := []*job.Job{}
+	for rows.Next() {
+		j, err := scanPgJob(rows)
+		if err != nil {
+			log.Error().Err(err).Msg("could not scan jobs row")
+			return nil, errors.New("could not list jobs")
+		}
+		jobs = append(jobs, j)
+	}
+	if rows.Err() != nil {
+		log.Error().Err(rows.Err()).Msg("could not read jobs rows")
+		return nil, errors.New("could not list jobs")
+	}
+	return newJobsPage(in, jobs), nil
+}
+
+// Negative when "a" goes before "b" in the sort of the input, for the storages that sort in go
+func (in ListJobsInput) compare(a jobsCursor, b jobsCursor) int {
+	result := strings.Compare(a.Text, b.Text)
+	if result == 0 {
+		result = cmp.Compare(a.Number, b.Number)
+	}
+	if result == 0 {
+		result = strings.Compare(a.Id.String(), b.Id.String())
+	}
+	if in.Descending {
+		return -result
+	}
+	return result
+}
@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"sync"
+	"time"
+
+	"github.com/back-end-labs/ruok/pkg/config"
+	"github.com/gofrs/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// Bounds for the time we wait before trying to listen again after losing the connection
+var listenerMinBackoff = time.Millisecond * 500
+var listenerMaxBackoff = time.Second * 30
+
+// State of the connection used to receive notifications
+type ListenerHealth struct {
+	// FALSE until ListenForChanges is called
+	Started   bool `json:"started"`
+	Connected bool `json:"connected"`
+	// How many times we had to listen again after losing the connection
+	Reconnects int    `json:"reconnects"`
+	LastError  string `json:"lastError,omitempty"`
+	// Unix micro of the last time the listener connected or disconnected
+	Since int64 `json:"since"`
+}
+
+// TRUE if the listener is connected or was never started
+func (lh ListenerHealth) Healthy() bool {
+	return !lh.Started || lh.Connected
+}
+
+type listenerState struct {
+	lock   *sync.RWMutex
+	health ListenerHealth
+}
+
+func newListenerState() *listenerState {
+	return &listenerState{lock: &sync.RWMutex{}}
+}
+
+func (ls *listenerState) connected(reconnecting bool) {
+	ls.lock.Lock()
+	defer ls.lock.Unlock()
+	ls.health.Started = true
+	ls.health.Connected = true
+	ls.health.Since = time.Now().UnixMicro()
+	if reconnecting {
+		ls.health.Reconnects++
+	}
+}
+
+func (ls *listenerState) disconnected(err error) {
+	ls.lock.Lock()
+	defer ls.lock.Unlock()
+	ls.health.Started = true
+	ls.health.Connected = false
+	ls.health.Since = time.Now().UnixMicro()
+	if err != nil {
+		ls.health.LastError = err.Error()
+	}
+}
+
+func (ls *listenerState) get() ListenerHealth {
+	ls.lock.RLock()
+	defer ls.lock.RUnlock()
+	return ls.health
+}
+
+// Doubles the backoff up to listenerMaxBackoff
+func nextBackoff(current time.Duration) time.Duration {
+	if current < listenerMinBackoff {
+		return listenerMinBackoff
+	}
+	next := current * 2
+	if next > listenerMaxBackoff {
+		return listenerMaxBackoff
+	}
+	return next
+}
+
+// Returns the state of the connection used to receive notifications
+func (sqls *SQLStorage) ListenerHealth() ListenerHealth {
+	if sqls.listener == nil {
+		return ListenerHealth{}
+	}
+	return sqls.listener.get()
+}
+
+// Parses a payload received on our channel and sends it over "notificationsCh".
+// Malformed payloads are counted and dropped. Returns TRUE if it was a release for the whole listener.
+func deliverPayload(payload string, notificationsCh chan Notification) bool {
+	n, err := ParseNotification(payload)
+	if err != nil {
+		config.AppStats.CountMalformedNotification()
+		log.Warn().Err(err).Msgf("dropping malformed notification %q", payload)
+		return false
+	}
+	if n.Event == EventRelease && n.JobId == uuid.Nil {
+		log.Info().Msg("Received release, done!")
+		return true
+	}
+	notificationsCh <- n
+	return false
+}
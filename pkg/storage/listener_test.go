@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNextBackoff(t *testing.T) {
+	backoff := time.Duration(0)
+	expected := []time.Duration{
+		listenerMinBackoff,
+		listenerMinBackoff * 2,
+		listenerMinBackoff * 4,
+	}
+	for _, e := range expected {
+		backoff = nextBackoff(backoff)
+		assert.Equal(t, e, backoff)
+	}
+	assert.Equal(t, listenerMaxBackoff, nextBackoff(listenerMaxBackoff))
+	assert.Equal(t, listenerMaxBackoff, nextBackoff(listenerMaxBackoff-time.Millisecond))
+}
+
+func TestListenerHealth(t *testing.T) {
+	s := &SQLStorage{}
+	assert.True(t, s.ListenerHealth().Healthy(), "listeners that never started should be healthy")
+
+	s.listener = newListenerState()
+	s.listener.connected(false)
+	h := s.ListenerHealth()
+	assert.True(t, h.Healthy())
+	assert.Equal(t, 0, h.Reconnects)
+
+	s.listener.disconnected(errors.New("conn closed"))
+	h = s.ListenerHealth()
+	assert.False(t, h.Healthy())
+	assert.Equal(t, "conn closed", h.LastError)
+
+	s.listener.connected(true)
+	h = s.ListenerHealth()
+	assert.True(t, h.Healthy())
+	assert.Equal(t, 1, h.Reconnects)
+	assert.Equal(t, "conn closed", h.LastError, "the last error should be kept")
+}
@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+)
+
+// Result of an execution from one of the locations of a job
+type LocationResult struct {
+	JobId      uuid.UUID
+	Location   string
+	Failed     bool
+	ExecutedAt time.Time
+}
+
+// Jobs without locations keep an empty array, so the column is never NULL
+func locationsOrEmpty(l []string) []string {
+	if l == nil {
+		return []string{}
+	}
+	return l
+}
+
+// Jobs alerting on any failure keep a NULL quorum
+func nullQuorum(q int) sql.NullInt32 {
+	return sql.NullInt32{Int32: int32(q), Valid: q > 0}
+}
+
+// Locks every location of the job, so results of the same round are counted one after the other
+var lockJobLocationsQuery = `
+SELECT location FROM ruok.job_locations WHERE job_id = $1 FOR UPDATE
+`
+
+var recordLocationResultQuery = `
+INSERT INTO ruok.job_locations (job_id, location, last_execution, failing)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (job_id, location) DO UPDATE SET
+	last_execution = excluded.last_execution,
+	failing = excluded.failing;
+`
+
+// Locations the job doesn't require anymore are left out
+var countFailingLocationsQuery = `
+SELECT count(*) FROM ruok.job_locations l
+JOIN ruok.jobs j ON j.id = l.job_id
+WHERE l.job_id = $1 AND l.failing AND l.last_execution >= $2 AND l.location = ANY(j.locations);
+`
+
+// Keeps the result of a location and returns how many locations of the job failed their last execution since "since".
+// Results of the same job are recorded one at a time, so only one of them sees the count reaching the quorum.
+func (sqls *SQLStorage) RecordLocationResult(r LocationResult, since time.Time) (int, error) {
+	ctx := context.Background()
+	tx, err := sqls.Db.Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("could not start transaction to record location result")
+		return 0, errors.New("could not record location result")
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, lockJobLocationsQuery, r.JobId)
+	if err != nil {
+		log.Error().Err(err).Msgf("could not lock locations of job %v", r.JobId)
+		return 0, errors.New("could not record location result")
+	}
+
+	_, err = tx.Exec(ctx, recordLocationResultQuery, r.JobId, r.Location, r.ExecutedAt.UnixMicro(), r.Failed)
+	if err != nil {
+		log.Error().Err(err).Msgf("could not record result of job %v from %q", r.JobId, r.Location)
+		return 0, errors.New("could not record location result")
+	}
+
+	var failing int
+	err = tx.QueryRow(ctx, countFailingLocationsQuery, r.JobId, since.UnixMicro()).Scan(&failing)
+	if err != nil {
+		log.Error().Err(err).Msgf("could not count failing locations of job %v", r.JobId)
+		return 0, errors.New("could not count failing locations")
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("could not commit transaction to record location result")
+		return 0, errors.New("could not commit transaction to record location result")
+	}
+	return failing, nil
+}
+
+// Releases every location of the job and returns who claimed them, so they can be notified
+var releaseJobLocationsQuery = `
+WITH target AS (
+	SELECT job_id, location, claimed_by FROM ruok.job_locations
+	WHERE job_id = $1 AND claimed_by IS NOT NULL
+	FOR UPDATE
+)
+UPDATE ruok.job_locations SET claimed_by = NULL
+FROM target
+WHERE ruok.job_locations.job_id = target.job_id AND ruok.job_locations.location = target.location
+RETURNING target.claimed_by;
+`
+
+var locationOwnersQuery = `
+SELECT DISTINCT claimed_by FROM ruok.job_locations
+WHERE job_id = $1 AND claimed_by IS NOT NULL
+ORDER BY claimed_by;
+`
+
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// Schedulers that claimed a location of the job
+func locationOwners(ctx context.Context, db querier, jobId uuid.UUID) ([]string, error) {
+	return scanOwners(db.Query(ctx, locationOwnersQuery, jobId))
+}
+
+// Releases the locations of the job, returns the schedulers that had them
+func releaseLocations(ctx context.Context, db querier, jobId uuid.UUID) ([]string, error) {
+	return scanOwners(db.Query(ctx, releaseJobLocationsQuery, jobId))
+}
+
+func scanOwners(rows pgx.Rows, err error) ([]string, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	owners := []string{}
+	for rows.Next() {
+		var owner string
+		if err := rows.Scan(&owner); err != nil {
+			return nil, err
+		}
+		owners = append(owners, owner)
+	}
+	return owners, rows.Err()
+}
+
+// Notifies every scheduler in "channels" once
+func notifyAll(ctx context.Context, db execer, channels []string, n Notification) error {
+	notified := map[string]bool{}
+	for _, channel := range channels {
+		if notified[channel] {
+			continue
+		}
+		notified[channel] = true
+		if err := notify(ctx, db, channel, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/gofrs/uuid"
+	pgxuuid "github.com/jackc/pgx-gofrs-uuid"
+	"github.com/rs/zerolog/log"
+
+	"github.com/back-end-labs/ruok/pkg/maintenance"
+)
+
+type CreateMaintenanceWindowInput struct {
+	Name            string      `json:"name"`
+	Mode            string      `json:"mode"`
+	CronExpString   string      `json:"cronexp"`
+	Timezone        string      `json:"timezone"`
+	DurationSeconds int         `json:"durationSeconds"`
+	StartsAt        time.Time   `json:"startsAt"`
+	EndsAt          time.Time   `json:"endsAt"`
+	JobIds          []uuid.UUID `json:"jobIds"`
+	Selector        string      `json:"selector"`
+}
+
+var createMaintenanceWindowQuery = `
+INSERT INTO ruok.maintenance_windows (
+	id,
+	window_name,
+	mode,
+	cron_exp_string,
+	timezone,
+	duration_seconds,
+	starts_at,
+	ends_at,
+	job_ids,
+	selector
+) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10);
+`
+
+func (sqls *SQLStorage) CreateMaintenanceWindow(w CreateMaintenanceWindowInput) error {
+	id, err := uuid.NewV7()
+	if err != nil {
+		log.Error().Err(err).Msg("could not create uuidv7 for new maintenance window")
+		return err
+	}
+
+	var cronExpString sql.NullString
+	var durationSeconds sql.NullInt32
+	var startsAt sql.NullInt64
+	var endsAt sql.NullInt64
+
+	if w.CronExpString != "" {
+		cronExpString = sql.NullString{String: w.CronExpString, Valid: true}
+		durationSeconds = sql.NullInt32{Int32: int32(w.DurationSeconds), Valid: true}
+	} else {
+		startsAt = sql.NullInt64{Int64: w.StartsAt.UnixMicro(), Valid: true}
+		endsAt = sql.NullInt64{Int64: w.EndsAt.UnixMicro(), Valid: true}
+	}
+
+	jobIds := w.JobIds
+	if jobIds == nil {
+		jobIds = []uuid.UUID{}
+	}
+
+	_, err = sqls.Db.Exec(context.Background(), createMaintenanceWindowQuery,
+		id,
+		w.Name,
+		w.Mode,
+		cronExpString,
+		TimezoneOrDefault(w.Timezone),
+		durationSeconds,
+		startsAt,
+		endsAt,
+		jobIds,
+		w.Selector,
+	)
+
+	if err != nil {
+		log.Error().Err(err).Msg("could not insert into maintenance_windows")
+		return errors.New("could not insert into maintenance_windows")
+	}
+	return nil
+}
+
+// Lists the maintenance windows that are not deleted and could still be active
+func (sqls *SQLStorage) GetMaintenanceWindows() []*maintenance.Window {
+	ctx := context.Background()
+	rows, err := sqls.Db.Query(ctx, `
+SELECT
+	id,
+	window_name,
+	mode,
+	cron_exp_string,
+	timezone,
+	duration_seconds,
+	starts_at,
+	ends_at,
+	job_ids,
+	selector,
+	created_at
+ FROM ruok.maintenance_windows
+ WHERE deleted_at IS NULL
+ AND (cron_exp_string IS NOT NULL OR ends_at > $1)
+ ORDER BY id ASC;
+ `, time.Now().UnixMicro())
+
+	if err != nil {
+		log.Error().Err(err).Msg("could not query for maintenance windows")
+		return nil
+	}
+	defer rows.Close()
+
+	windows := []*maintenance.Window{}
+
+	for rows.Next() {
+		var Id pgxuuid.UUID
+		var Name string
+		var Mode string
+		var CronExpString sql.NullString
+		var Timezone string
+		var DurationSeconds sql.NullInt32
+		var StartsAt sql.NullInt64
+		var EndsAt sql.NullInt64
+		var JobIds []pgxuuid.UUID
+		var Selector string
+		var CreatedAt int
+
+		err = rows.Scan(
+			&Id,
+			&Name,
+			&Mode,
+			&CronExpString,
+			&Timezone,
+			&DurationSeconds,
+			&StartsAt,
+			&EndsAt,
+			&JobIds,
+			&Selector,
+			&CreatedAt,
+		)
+		if err != nil {
+			log.Error().Err(err).Msg("could not scan maintenance windows row")
+			continue
+		}
+
+		w := &maintenance.Window{
+			Id:              uuid.UUID(Id),
+			Name:            Name,
+			Mode:            Mode,
+			CronExpString:   CronExpString.String,
+			Timezone:        Timezone,
+			DurationSeconds: int(DurationSeconds.Int32),
+			JobIds:          []uuid.UUID{},
+			Selector:        Selector,
+			CreatedAt:       CreatedAt,
+		}
+		if StartsAt.Valid {
+			w.StartsAt = time.UnixMicro(StartsAt.Int64)
+		}
+		if EndsAt.Valid {
+			w.EndsAt = time.UnixMicro(EndsAt.Int64)
+		}
+		for _, jobId := range JobIds {
+			w.JobIds = append(w.JobIds, uuid.UUID(jobId))
+		}
+
+		windows = append(windows, w)
+	}
+
+	return windows
+}
+
+// Soft deletes a maintenance window
+func (sqls *SQLStorage) DeleteMaintenanceWindow(id uuid.UUID) error {
+	tag, err := sqls.Db.Exec(
+		context.Background(),
+		"UPDATE ruok.maintenance_windows SET deleted_at = ruok.micro_unix_now() WHERE id = $1 AND deleted_at IS NULL",
+		id,
+	)
+	if err != nil {
+		log.Error().Err(err).Msgf("could not delete maintenance window %v", id)
+		return errors.New("could not delete maintenance window")
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
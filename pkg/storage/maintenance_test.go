@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaintenanceWindows(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, s *testStorage) {
+		jobId, _ := uuid.NewV7()
+		now := time.Now()
+
+		inputs := []CreateMaintenanceWindowInput{
+			{Name: "nightly", Mode: "skip", CronExpString: "0 2 * * *", Timezone: "Europe/Madrid", DurationSeconds: 1800, Selector: "zone=eu"},
+			{Name: "migration", Mode: "mute", StartsAt: now, EndsAt: now.Add(time.Hour), JobIds: []uuid.UUID{jobId}},
+			{Name: "already over", Mode: "mute", StartsAt: now.Add(-2 * time.Hour), EndsAt: now.Add(-time.Hour)},
+		}
+		for _, in := range inputs {
+			assert.NoError(t, s.CreateMaintenanceWindow(in))
+		}
+
+		windows := s.GetMaintenanceWindows()
+		assert.Len(t, windows, 2)
+		assert.Equal(t, "nightly", windows[0].Name)
+		assert.Equal(t, 1800, windows[0].DurationSeconds)
+		assert.True(t, windows[0].IsRecurring())
+		assert.Equal(t, "zone=eu", windows[0].Selector)
+		assert.Equal(t, "migration", windows[1].Name)
+		assert.Equal(t, []uuid.UUID{jobId}, windows[1].JobIds)
+		assert.Empty(t, windows[1].Selector)
+		assert.Equal(t, now.UnixMicro(), windows[1].StartsAt.UnixMicro())
+
+		assert.NoError(t, s.DeleteMaintenanceWindow(windows[0].Id))
+		assert.ErrorIs(t, s.DeleteMaintenanceWindow(windows[0].Id), ErrNotFound)
+		assert.Len(t, s.GetMaintenanceWindows(), 1)
+	})
+}
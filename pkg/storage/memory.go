@@ -0,0 +1,242 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gofrs/uuid"
+	"github.com/rs/zerolog/log"
+
+	"github.com/back-end-labs/ruok/pkg/config"
+	"github.com/back-end-labs/ruok/pkg/job"
+	"github.com/back-end-labs/ruok/pkg/maintenance"
+)
+
+var errMemoryClosed = errors.New("memory storage is closed")
+
+// Storage kept in the memory of the process, meant for tests and for embedding ruok in another program.
+//
+// It behaves like the postgres storage, including claims, notifications and the execution history,
+// but nothing survives a restart and only the scheduler of this process can use it.
+// Every method is safe to call from several goroutines.
+type MemoryStorage struct {
+	*memoryState
+	// Only the jobs of this tenant are seen, every tenant when empty. See ForTenant
+	tenant string
+	// Jobs the tenant can have at most, 0 has no limit. See WithMaxJobs
+	maxJobs int
+}
+
+// What every view of a MemoryStorage shares
+type memoryState struct {
+	lock       sync.Mutex
+	jobs       map[uuid.UUID]*memoryJob
+	results    map[uuid.UUID]*memoryResult
+	aggregates map[aggregateKey]*memoryAggregate
+	windows    []*memoryWindow
+	locations  map[locationKey]*memoryLocation
+	apiKeys    []*memoryAPIKey
+	revisions  map[uuid.UUID][]*job.Revision
+	bus        *bus
+	closed     atomic.Bool
+	listener   *listenerState
+	buffer     *resultBuffer
+}
+
+// A row of ruok.jobs, claimedBy is empty while nobody claims it
+type memoryJob struct {
+	id                   uuid.UUID
+	name                 string
+	cronExpString        string
+	endpoint             string
+	httpMethod           string
+	maxRetries           int
+	lastExecution        int64
+	shouldExecuteAt      int64
+	lastResponseAt       int64
+	lastMessage          string
+	lastStatusCode       int
+	successStatuses      []int
+	tlsClientCert        string
+	status               string
+	claimedBy            string
+	succeeded            string
+	alertStrategy        string
+	alertEndpoint        string
+	alertMethod          string
+	alertHeaders         map[string]string
+	alertPayload         string
+	timezone             string
+	resultsRetentionDays *int
+	labels               map[string]string
+	locations            []string
+	alertQuorum          int
+	updatedBy            string
+	tenant               string
+	createdAt            int64
+	updatedAt            int64
+	deletedAt            int64
+}
+
+// A row of ruok.job_results
+type memoryResult struct {
+	resultRow
+	createdAt int64
+}
+
+// A row of ruok.job_results_aggregates
+type memoryAggregate struct {
+	executions int
+	failures   int
+	p50        *int64
+	p95        *int64
+}
+
+// A row of ruok.maintenance_windows
+type memoryWindow struct {
+	maintenance.Window
+	deletedAt int64
+}
+
+// Creates an empty storage
+func NewMemoryStorage() (*MemoryStorage, Closer) {
+	s := &MemoryStorage{memoryState: &memoryState{
+		jobs:       map[uuid.UUID]*memoryJob{},
+		results:    map[uuid.UUID]*memoryResult{},
+		aggregates: map[aggregateKey]*memoryAggregate{},
+		windows:    []*memoryWindow{},
+		locations:  map[locationKey]*memoryLocation{},
+		revisions:  map[uuid.UUID][]*job.Revision{},
+		bus:        newBus(),
+		listener:   newListenerState(),
+	}}
+	return s, func() {
+		s.closed.Store(true)
+		s.bus.unsubscribeAll(config.AppName())
+	}
+}
+
+func (s *MemoryStorage) Connected() bool {
+	return !s.closed.Load()
+}
+
+// Should register the url, name of the application and so on in the db
+func (s *MemoryStorage) RegisterSelf() {}
+
+// Nothing leaves the process, so there is nothing to encrypt
+func (s *MemoryStorage) GetSSLVersion() (bool, string) {
+	return false, ""
+}
+
+// Returns the state of the subscription used to receive notifications
+func (s *MemoryStorage) ListenerHealth() ListenerHealth {
+	return s.listener.get()
+}
+
+// Subscribes to our channel of the bus, see listenOnBus
+func (s *MemoryStorage) ListenForChanges(notificationsCh chan Notification, ctx context.Context) {
+	listenOnBus(s.bus, s.listener, notificationsCh, ctx)
+}
+
+func (s *MemoryStorage) StopListeningForChanges() error {
+	if s.closed.Load() {
+		log.Error().Err(errMemoryClosed).Msg("could not stop listening")
+		return errMemoryClosed
+	}
+	s.bus.unsubscribeAll(config.AppName())
+	return nil
+}
+
+// Publishes a notification to whoever listens on "channel", call it after releasing the lock
+func (s *MemoryStorage) notify(channel string, n Notification) error {
+	payload, err := n.Payload()
+	if err != nil {
+		return err
+	}
+	s.bus.publish(channel, payload)
+	return nil
+}
+
+// Jobs sorted by id, like the ORDER BY id of the other storages. Call it holding the lock.
+func (s *MemoryStorage) sortedJobs() []*memoryJob {
+	jobs := make([]*memoryJob, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		jobs = append(jobs, j)
+	}
+	sort.Slice(jobs, func(i, k int) bool { return bytes.Compare(jobs[i].id.Bytes(), jobs[k].id.Bytes()) < 0 })
+	return jobs
+}
+
+// Jobs are only visible to the scheduler owning them or while unclaimed,
+// like the row level security policies of postgres do
+func (j *memoryJob) visible() bool {
+	return j.claimedBy == "" || j.claimedBy == config.AppName()
+}
+
+// Returns a view of the storage that only sees and changes the jobs of the tenant, see SQLStorage.ForTenant
+func (s *MemoryStorage) ForTenant(tenant string) APIStorage {
+	return &MemoryStorage{memoryState: s.memoryState, tenant: tenant, maxJobs: s.maxJobs}
+}
+
+// Returns a view of the storage that refuses to create or bring back jobs once its tenant has "max" of them,
+// see SQLStorage.WithMaxJobs
+func (s *MemoryStorage) WithMaxJobs(max int) APIStorage {
+	return &MemoryStorage{memoryState: s.memoryState, tenant: s.tenant, maxJobs: max}
+}
+
+// Returns ErrQuotaExceeded if adding a job goes over maxJobs. Call it holding the lock
+func (s *MemoryStorage) checkMaxJobs() error {
+	if s.maxJobs == 0 {
+		return nil
+	}
+	count := 0
+	for _, mj := range s.jobs {
+		if mj.deletedAt == 0 && s.ownsJob(mj) {
+			count++
+		}
+	}
+	if count >= s.maxJobs {
+		return ErrQuotaExceeded
+	}
+	return nil
+}
+
+// TRUE if the job belongs to the tenant of the storage, or it sees every tenant
+func (s *MemoryStorage) ownsJob(j *memoryJob) bool {
+	return s.tenant == "" || j.tenant == s.tenant
+}
+
+// Same as ownsJob for rows that point to a job, like results. Call it holding the lock
+func (s *MemoryStorage) ownsJobId(jobId uuid.UUID) bool {
+	mj, ok := s.jobs[jobId]
+	return s.tenant == "" || (ok && s.ownsJob(mj))
+}
+
+// FALSE when jobs of the tenant are hidden from the storage because another scheduler claimed them, see visible
+func (s *MemoryStorage) SeesEveryJob() (bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, mj := range s.jobs {
+		if mj.deletedAt == 0 && !mj.visible() && s.ownsJob(mj) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Jobs of the tenant that are not deleted
+func (s *MemoryStorage) CountJobs() (int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	count := 0
+	for _, mj := range s.jobs {
+		if mj.deletedAt == 0 && s.ownsJob(mj) {
+			count++
+		}
+	}
+	return count, nil
+}
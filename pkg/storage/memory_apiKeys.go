@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/rs/zerolog/log"
+
+	"github.com/back-end-labs/ruok/pkg/auth"
+)
+
+// A row of ruok.api_keys
+type memoryAPIKey struct {
+	auth.APIKey
+	hash string
+}
+
+func (s *MemoryStorage) CreateAPIKey(k CreateAPIKeyInput) (*auth.APIKey, error) {
+	id, err := uuid.NewV7()
+	if err != nil {
+		log.Error().Err(err).Msg("could not create uuidv7 for new api key")
+		return nil, err
+	}
+
+	mk := &memoryAPIKey{
+		APIKey: auth.APIKey{
+			Id:        id,
+			Name:      k.Name,
+			Prefix:    k.Prefix,
+			Scope:     k.Scope,
+			Tenant:    tenantOrDefault(k.Tenant),
+			CreatedAt: time.Now().UnixMilli(),
+		},
+		hash: k.Hash,
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.apiKeys = append(s.apiKeys, mk)
+	key := mk.APIKey
+	return &key, nil
+}
+
+// Finds the key with the hash, revoked keys are not found
+func (s *MemoryStorage) GetAPIKey(hash string) (*auth.APIKey, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, mk := range s.apiKeys {
+		if mk.hash == hash && mk.RevokedAt == 0 {
+			key := mk.APIKey
+			return &key, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// Lists every key of the tenant, revoked ones included
+func (s *MemoryStorage) ListAPIKeys() []*auth.APIKey {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	keys := []*auth.APIKey{}
+	for _, mk := range s.apiKeys {
+		if s.tenant != "" && mk.Tenant != s.tenant {
+			continue
+		}
+		key := mk.APIKey
+		keys = append(keys, &key)
+	}
+	return keys
+}
+
+// Stops accepting the key, it is kept so changes made with it can be traced
+func (s *MemoryStorage) RevokeAPIKey(id uuid.UUID) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, mk := range s.apiKeys {
+		if mk.Id == id && mk.RevokedAt == 0 && (s.tenant == "" || mk.Tenant == s.tenant) {
+			mk.RevokedAt = time.Now().UnixMilli()
+			return nil
+		}
+	}
+	return ErrNotFound
+}
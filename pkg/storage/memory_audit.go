@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/rs/zerolog/log"
+
+	"github.com/back-end-labs/ruok/pkg/config"
+	"github.com/back-end-labs/ruok/pkg/job"
+)
+
+// What the job looks like now, the lock must be held
+func (mj *memoryJob) snapshot() *job.Snapshot {
+	s := &job.Snapshot{
+		Name:            mj.name,
+		CronExpString:   mj.cronExpString,
+		Timezone:        mj.timezone,
+		MaxRetries:      mj.maxRetries,
+		Endpoint:        mj.endpoint,
+		HttpMethod:      mj.httpMethod,
+		SuccessStatuses: append([]int{}, mj.successStatuses...),
+		AlertStrategy:   mj.alertStrategy,
+		AlertMethod:     mj.alertMethod,
+		AlertEndpoint:   mj.alertEndpoint,
+		AlertPayload:    mj.alertPayload,
+		AlertHeaders:    copyHeaders(mj.alertHeaders),
+		Labels:          copyHeaders(mj.labels),
+		Locations:       append([]string{}, mj.locations...),
+		AlertQuorum:     mj.alertQuorum,
+		Paused:          mj.status == "paused",
+		Deleted:         mj.deletedAt != 0,
+	}
+	if mj.resultsRetentionDays != nil {
+		days := *mj.resultsRetentionDays
+		s.ResultsRetentionDays = &days
+	}
+	return s
+}
+
+// Adds a revision to the history of the job with what it looks like now, the lock must be held
+func (s *MemoryStorage) recordRevision(mj *memoryJob, action string, by string, before *job.Snapshot) {
+	history := s.revisions[mj.id]
+	s.revisions[mj.id] = append(history, &job.Revision{
+		JobId:     mj.id,
+		Revision:  len(history) + 1,
+		Action:    action,
+		Actor:     by,
+		CreatedAt: time.Now().UnixMilli(),
+		Before:    before,
+		After:     mj.snapshot(),
+	})
+}
+
+// Changes made to a job, the newest first
+func (s *MemoryStorage) GetJobHistory(jobId uuid.UUID, limit int, offset int) []*job.Revision {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	history := s.revisions[jobId]
+	revisions := []*job.Revision{}
+	if mj, ok := s.jobs[jobId]; !ok || !s.ownsJob(mj) {
+		return revisions
+	}
+	for i := len(history) - 1 - offset; i >= 0 && len(revisions) < limit; i-- {
+		r := *history[i]
+		r.Changes = job.Diff(r.Before, r.After)
+		revisions = append(revisions, &r)
+	}
+	return revisions
+}
+
+// What the job looked like after "revision"
+func (s *MemoryStorage) GetJobRevision(jobId uuid.UUID, revision int) (*job.Snapshot, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	mj, ok := s.jobs[jobId]
+	history := s.revisions[jobId]
+	if !ok || !s.ownsJob(mj) || revision < 1 || revision > len(history) {
+		return nil, ErrNotFound
+	}
+	target := *history[revision-1].After
+	return &target, nil
+}
+
+// Makes the job look like it did after "revision", see SQLStorage.RestoreJob
+func (s *MemoryStorage) RestoreJob(jobId uuid.UUID, revision int, by string) error {
+	s.lock.Lock()
+	mj, ok := s.jobs[jobId]
+	history := s.revisions[jobId]
+	if !ok || !s.ownsJob(mj) || revision < 1 || revision > len(history) {
+		s.lock.Unlock()
+		return ErrNotFound
+	}
+	target := history[revision-1].After
+	if target.Deleted {
+		s.lock.Unlock()
+		return ErrDeletedRevision
+	}
+	if mj.deletedAt != 0 {
+		if err := s.checkMaxJobs(); err != nil {
+			s.lock.Unlock()
+			return err
+		}
+	}
+
+	before := mj.snapshot()
+	mj.name = target.Name
+	mj.cronExpString = target.CronExpString
+	mj.endpoint = target.Endpoint
+	mj.httpMethod = target.HttpMethod
+	mj.maxRetries = target.MaxRetries
+	mj.successStatuses = append([]int{}, target.SuccessStatuses...)
+	mj.status = "pending to be claimed"
+	if target.Paused {
+		mj.status = "paused"
+	}
+	mj.alertStrategy = target.AlertStrategy
+	mj.alertEndpoint = target.AlertEndpoint
+	mj.alertMethod = target.AlertMethod
+	mj.alertHeaders = copyHeaders(target.AlertHeaders)
+	mj.alertPayload = target.AlertPayload
+	mj.timezone = TimezoneOrDefault(target.Timezone)
+	mj.resultsRetentionDays = nil
+	if target.ResultsRetentionDays != nil {
+		days := *target.ResultsRetentionDays
+		mj.resultsRetentionDays = &days
+	}
+	mj.labels = copyHeaders(target.Labels)
+	mj.locations = append([]string{}, target.Locations...)
+	mj.alertQuorum = target.AlertQuorum
+	mj.updatedBy = by
+	mj.updatedAt = time.Now().UnixMilli()
+	mj.deletedAt = 0
+	channel := config.AppName()
+	if mj.claimedBy != "" {
+		channel = mj.claimedBy
+	}
+	mj.claimedBy = ""
+	s.recordRevision(mj, job.ActionRestore, by, before)
+	channels := append([]string{channel}, s.releaseLocations(jobId)...)
+	s.lock.Unlock()
+
+	if err := s.notifyAll(channels, NewNotification(EventUpdated, jobId)); err != nil {
+		log.Error().Err(err).Msgf("could not notify restored job %v", jobId)
+		return errors.New("could not notify restored job")
+	}
+	return nil
+}
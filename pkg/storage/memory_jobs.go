@@ -0,0 +1,527 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/rs/zerolog/log"
+
+	"github.com/back-end-labs/ruok/pkg/config"
+	"github.com/back-end-labs/ruok/pkg/job"
+	"github.com/back-end-labs/ruok/pkg/labels"
+)
+
+func copyHeaders(headers map[string]string) map[string]string {
+	copied := make(map[string]string, len(headers))
+	for k, v := range headers {
+		copied[k] = v
+	}
+	return copied
+}
+
+// Gets pending to be claimed jobs matching the selector and claims them.
+// Jobs with locations are claimed per location, see SQLStorage.GetAvailableJobs.
+func (s *MemoryStorage) GetAvailableJobs(limit int, selector labels.Selector) []*job.Job {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	jobsList := []*job.Job{}
+	for _, mj := range s.sortedJobs() {
+		if len(jobsList) >= limit {
+			break
+		}
+		if mj.status != "pending to be claimed" || !selector.Matches(mj.labels) {
+			continue
+		}
+		location := ""
+		if len(mj.locations) > 0 {
+			location = s.claimableLocation(mj)
+			if location == "" {
+				continue
+			}
+			key := locationKey{mj.id, location}
+			if _, ok := s.locations[key]; !ok {
+				s.locations[key] = &memoryLocation{}
+			}
+			s.locations[key].claimedBy = config.AppName()
+		} else {
+			mj.status = "claimed"
+			mj.claimedBy = config.AppName()
+		}
+		jobsList = append(jobsList, &job.Job{
+			Id:              mj.id,
+			Name:            mj.name,
+			CronExpString:   mj.cronExpString,
+			Endpoint:        mj.endpoint,
+			HttpMethod:      mj.httpMethod,
+			MaxRetries:      mj.maxRetries,
+			LastExecution:   time.UnixMicro(mj.lastExecution),
+			ShouldExecuteAt: time.UnixMicro(mj.shouldExecuteAt),
+			LastResponseAt:  time.UnixMicro(mj.lastResponseAt),
+			LastMessage:     mj.lastMessage,
+			LastStatusCode:  mj.lastStatusCode,
+			Headers:         map[string]string{},
+			SuccessStatuses: append([]int{}, mj.successStatuses...),
+			TLSClientCert:   mj.tlsClientCert,
+			CreatedAt:       int(mj.createdAt),
+			UpdatedAt:       mj.updatedAt,
+			AlertStrategy:   mj.alertStrategy,
+			AlertEndpoint:   mj.alertEndpoint,
+			AlertMethod:     mj.alertMethod,
+			AlertHeaders:    copyHeaders(mj.alertHeaders),
+			AlertPayload:    mj.alertPayload,
+			Timezone:        mj.timezone,
+			Labels:          copyHeaders(mj.labels),
+			Locations:       append([]string{}, mj.locations...),
+			AlertQuorum:     mj.alertQuorum,
+			Location:        location,
+			ClaimedBy:       config.AppName(),
+			Status:          "claimed",
+			Handlers:        job.Handlers{},
+		})
+	}
+	return jobsList
+}
+
+// Gets jobs claimed by this instance that match the selector, along with the ones we claimed a location of
+func (s *MemoryStorage) GetClaimedJobs(limit int, offset int, selector labels.Selector) []*job.Job {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	jobsList := []*job.Job{}
+	for _, mj := range s.sortedJobs() {
+		location := s.claimedLocation(mj.id)
+		if (mj.claimedBy != config.AppName() && location == "") || !selector.Matches(mj.labels) || !s.ownsJob(mj) {
+			continue
+		}
+		if offset > 0 {
+			offset--
+			continue
+		}
+		if len(jobsList) >= limit {
+			break
+		}
+		jobsList = append(jobsList, &job.Job{
+			Id:              mj.id,
+			Name:            mj.name,
+			CronExpString:   mj.cronExpString,
+			Endpoint:        mj.endpoint,
+			HttpMethod:      mj.httpMethod,
+			MaxRetries:      mj.maxRetries,
+			LastExecution:   time.UnixMicro(mj.lastExecution),
+			ShouldExecuteAt: time.UnixMicro(mj.shouldExecuteAt),
+			LastResponseAt:  time.UnixMicro(mj.lastResponseAt),
+			LastMessage:     mj.lastMessage,
+			LastStatusCode:  mj.lastStatusCode,
+			Headers:         map[string]string{},
+			SuccessStatuses: append([]int{}, mj.successStatuses...),
+			CreatedAt:       int(mj.createdAt),
+			Succeeded:       mj.succeeded,
+			Timezone:        mj.timezone,
+			Labels:          copyHeaders(mj.labels),
+			Locations:       append([]string{}, mj.locations...),
+			AlertQuorum:     mj.alertQuorum,
+			Location:        location,
+			UpdatedBy:       mj.updatedBy,
+			ClaimedBy:       config.AppName(),
+			Handlers:        job.Handlers{},
+		})
+	}
+	return jobsList
+}
+
+func (s *MemoryStorage) CreateJob(j CreateJobInput) (uuid.UUID, error) {
+	id, err := uuid.NewV7()
+	if err != nil {
+		log.Error().Err(err).Msg("could not create uuidv7 for new job")
+		return uuid.Nil, err
+	}
+
+	mj := &memoryJob{
+		id:                   id,
+		name:                 j.Name,
+		cronExpString:        j.CronExpString,
+		endpoint:             j.Endpoint,
+		httpMethod:           j.HttpMethod,
+		maxRetries:           j.MaxRetries,
+		successStatuses:      append([]int{}, j.SuccessStatuses...),
+		status:               "pending to be claimed",
+		timezone:             TimezoneOrDefault(j.Timezone),
+		resultsRetentionDays: j.ResultsRetentionDays,
+		labels:               copyHeaders(j.Labels),
+		locations:            append([]string{}, j.Locations...),
+		alertQuorum:          j.AlertQuorum,
+		updatedBy:            j.UpdatedBy,
+		tenant:               tenantOrDefault(s.tenant),
+		createdAt:            time.Now().UnixMilli(),
+	}
+	// alerts are only kept when they have the minimum fields, like the postgres storage does
+	if HasMinAlertFields(j.AlertStrategy, j.AlertEndpoint, j.AlertMethod) {
+		mj.alertStrategy = j.AlertStrategy
+		mj.alertEndpoint = j.AlertEndpoint
+		mj.alertMethod = j.AlertMethod
+		mj.alertHeaders = copyHeaders(j.AlertHeaders)
+		mj.alertPayload = j.AlertPayload
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err := s.checkMaxJobs(); err != nil {
+		return uuid.Nil, err
+	}
+	s.jobs[id] = mj
+	s.recordRevision(mj, job.ActionCreate, j.UpdatedBy, nil)
+	return id, nil
+}
+
+func (s *MemoryStorage) UpdateJob(j UpdateJobInput) error {
+	s.lock.Lock()
+	mj, ok := s.jobs[j.Id]
+	if !ok || mj.deletedAt != 0 || !s.ownsJob(mj) {
+		s.lock.Unlock()
+		return ErrNotFound
+	}
+	before := mj.snapshot()
+	mj.name = j.Name
+	mj.cronExpString = j.CronExpString
+	mj.endpoint = j.Endpoint
+	mj.httpMethod = j.HttpMethod
+	mj.maxRetries = j.MaxRetries
+	mj.successStatuses = append([]int{}, j.SuccessStatuses...)
+	if mj.status != "paused" {
+		mj.status = "pending to be claimed"
+	}
+	mj.alertStrategy = j.AlertStrategy
+	mj.alertEndpoint = j.AlertEndpoint
+	mj.alertMethod = j.AlertMethod
+	mj.alertHeaders = copyHeaders(j.AlertHeaders)
+	mj.alertPayload = j.AlertPayload
+	mj.timezone = TimezoneOrDefault(j.Timezone)
+	mj.resultsRetentionDays = j.ResultsRetentionDays
+	mj.labels = copyHeaders(j.Labels)
+	mj.locations = append([]string{}, j.Locations...)
+	mj.alertQuorum = j.AlertQuorum
+	mj.updatedBy = j.UpdatedBy
+	mj.updatedAt = time.Now().UnixMilli()
+	s.recordRevision(mj, job.ActionUpdate, j.UpdatedBy, before)
+	// schedulers running a location of the job have to refresh it too
+	channels := append([]string{config.AppName()}, s.locationOwners(j.Id)...)
+	s.lock.Unlock()
+
+	if err := s.notifyAll(channels, NewNotification(EventUpdated, j.Id)); err != nil {
+		log.Error().Err(err).Msg("could not notify updated job")
+		return errors.New("could not notify updated job")
+	}
+	return nil
+}
+
+// Stops scheduling a job until it is resumed, "by" is who asked for it
+func (s *MemoryStorage) PauseJob(jobId uuid.UUID, by string) error {
+	return s.changeJobState(jobId, by, job.ActionPause, EventPaused, func(mj *memoryJob) bool {
+		if mj.status == "completed" {
+			return false
+		}
+		mj.status = "paused"
+		return true
+	})
+}
+
+// Makes a paused job available to be claimed again
+func (s *MemoryStorage) ResumeJob(jobId uuid.UUID, by string) error {
+	return s.changeJobState(jobId, by, job.ActionResume, EventUpdated, func(mj *memoryJob) bool {
+		if mj.status != "paused" {
+			return false
+		}
+		mj.status = "pending to be claimed"
+		return true
+	})
+}
+
+// Soft deletes a job
+func (s *MemoryStorage) DeleteJob(jobId uuid.UUID, by string) error {
+	return s.changeJobState(jobId, by, job.ActionDelete, EventDeleted, func(mj *memoryJob) bool {
+		mj.status = "deleted"
+		mj.deletedAt = time.Now().UnixMilli()
+		return true
+	})
+}
+
+// Releases the job while changing its state and notifies the scheduler that owned it.
+// Unclaimed jobs are notified to our own channel, so resumed jobs are claimed right away.
+// Jobs with locations are released from every location and their schedulers notified too.
+// "change" returns FALSE when the job can't take the change, which is ErrWrongState. "action" is recorded in the history of the job.
+func (s *MemoryStorage) changeJobState(jobId uuid.UUID, by string, action string, event string, change func(mj *memoryJob) bool) error {
+	s.lock.Lock()
+	mj, ok := s.jobs[jobId]
+	if !ok || mj.deletedAt != 0 || !s.ownsJob(mj) {
+		s.lock.Unlock()
+		return ErrNotFound
+	}
+	before := mj.snapshot()
+	if !change(mj) {
+		s.lock.Unlock()
+		return ErrWrongState
+	}
+	channel := config.AppName()
+	if mj.claimedBy != "" {
+		channel = mj.claimedBy
+	}
+	mj.claimedBy = ""
+	mj.updatedBy = by
+	mj.updatedAt = time.Now().UnixMilli()
+	s.recordRevision(mj, action, by, before)
+	channels := append([]string{channel}, s.releaseLocations(jobId)...)
+	s.lock.Unlock()
+
+	if err := s.notifyAll(channels, NewNotification(event, jobId)); err != nil {
+		log.Error().Err(err).Msgf("could not notify %s of job %v", action, jobId)
+		return errors.New("could not notify " + action + " of job")
+	}
+	return nil
+}
+
+// Marks a job that won't run again (like one-shot jobs) as completed, so nobody claims it again
+func (s *MemoryStorage) CompleteJob(jobId uuid.UUID) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if mj, ok := s.jobs[jobId]; ok {
+		mj.status = "completed"
+	}
+	return nil
+}
+
+// Makes the jobs available to be claimed again
+func (s *MemoryStorage) ReleaseAll(j []*job.Job) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, released := range j {
+		if released.Location != "" {
+			// only our location, the job itself is never claimed
+			if l, ok := s.locations[locationKey{released.Id, released.Location}]; ok {
+				l.claimedBy = ""
+			}
+			continue
+		}
+		if mj, ok := s.jobs[released.Id]; ok {
+			mj.claimedBy = ""
+			mj.status = "pending to be claimed"
+		}
+	}
+	return nil
+}
+
+// Returns nil when the job doesn't exist or is claimed by another scheduler
+func (s *MemoryStorage) GetJobUpdates(jobId uuid.UUID) *JobUpdates {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	mj, ok := s.jobs[jobId]
+	if !ok || !mj.visible() {
+		log.Error().Msgf("could not get updates for job %v", jobId)
+		return nil
+	}
+	return &JobUpdates{
+		Job_name:         mj.name,
+		Cron_exp_string:  mj.cronExpString,
+		Endpoint:         mj.endpoint,
+		Httpmethod:       mj.httpMethod,
+		Max_retries:      mj.maxRetries,
+		Success_statuses: append([]int{}, mj.successStatuses...),
+		Tls_client_cert:  mj.tlsClientCert,
+		Alert_strategy:   mj.alertStrategy,
+		Alert_endpoint:   mj.alertEndpoint,
+		Alert_method:     mj.alertMethod,
+		Timezone:         mj.timezone,
+		Status:           mj.status,
+		Updated_at:       mj.updatedAt,
+		Deleted_at:       mj.deletedAt,
+		Labels:           copyHeaders(mj.labels),
+		Locations:        append([]string{}, mj.locations...),
+		Alert_quorum:     mj.alertQuorum,
+	}
+}
+
+// Gets the last update time of the given jobs, jobs we can't see anymore are not in the result
+func (s *MemoryStorage) GetJobsUpdatedAt(jobIds []uuid.UUID) map[uuid.UUID]int64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	updatedAt := map[uuid.UUID]int64{}
+	for _, id := range jobIds {
+		if mj, ok := s.jobs[id]; ok && mj.visible() {
+			updatedAt[id] = mj.updatedAt
+		}
+	}
+	return updatedAt
+}
+
+// Notifies the scheduler owning the job so it runs it outside its schedule.
+// Returns the id the execution result will have.
+func (s *MemoryStorage) RequestRun(jobId uuid.UUID) (uuid.UUID, error) {
+	runId, err := uuid.NewV7()
+	if err != nil {
+		log.Error().Err(err).Msg("could not create uuidv7 for manual run")
+		return uuid.Nil, err
+	}
+
+	s.lock.Lock()
+	mj, ok := s.jobs[jobId]
+	var owner string
+	if ok && mj.deletedAt == 0 && s.ownsJob(mj) {
+		owner = mj.claimedBy
+		// jobs with locations run from the first claimed location
+		if owners := s.locationOwners(jobId); owner == "" && len(owners) > 0 {
+			owner = owners[0]
+		}
+	} else {
+		ok = false
+	}
+	s.lock.Unlock()
+	if !ok {
+		return uuid.Nil, ErrNotFound
+	}
+	if owner == "" {
+		return uuid.Nil, ErrNotClaimed
+	}
+
+	n := NewNotification(EventRun, jobId)
+	n.RunId = runId
+	if err := s.notify(owner, n); err != nil {
+		log.Error().Err(err).Msgf("could not notify run of job %v", jobId)
+		return uuid.Nil, errors.New("could not notify run")
+	}
+	return runId, nil
+}
+
+// Gets the ids of the jobs we can see that match the selector, deleted jobs are left out
+func (s *MemoryStorage) GetJobIds(selector labels.Selector) []uuid.UUID {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	ids := []uuid.UUID{}
+	for _, mj := range s.sortedJobs() {
+		if mj.deletedAt == 0 && mj.visible() && s.ownsJob(mj) && selector.Matches(mj.labels) {
+			ids = append(ids, mj.id)
+		}
+	}
+	return ids
+}
+
+// Changes where the alerts of a job are sent and lets its owner know
+func (s *MemoryStorage) UpdateJobAlerts(jobId uuid.UUID, a JobAlertsInput) error {
+	s.lock.Lock()
+	mj, ok := s.jobs[jobId]
+	if !ok || mj.deletedAt != 0 || !mj.visible() || !s.ownsJob(mj) {
+		s.lock.Unlock()
+		return ErrNotFound
+	}
+	before := mj.snapshot()
+	mj.alertStrategy = a.AlertStrategy
+	mj.alertEndpoint = a.AlertEndpoint
+	mj.alertMethod = a.AlertMethod
+	mj.alertHeaders = copyHeaders(a.AlertHeaders)
+	mj.alertPayload = a.AlertPayload
+	mj.updatedBy = a.UpdatedBy
+	mj.updatedAt = time.Now().UnixMilli()
+	s.recordRevision(mj, job.ActionAlerts, a.UpdatedBy, before)
+	channel := config.AppName()
+	if mj.claimedBy != "" {
+		channel = mj.claimedBy
+	}
+	channels := append([]string{channel}, s.locationOwners(jobId)...)
+	s.lock.Unlock()
+
+	if err := s.notifyAll(channels, NewNotification(EventUpdated, jobId)); err != nil {
+		log.Error().Err(err).Msgf("could not notify updated alerts of job %v", jobId)
+		return errors.New("could not notify updated job alerts")
+	}
+	return nil
+}
+
+// The whole definition of the job, like the jobColumns of the sql storages
+func (mj *memoryJob) definition() *job.Job {
+	var retentionDays *int
+	if mj.resultsRetentionDays != nil {
+		days := *mj.resultsRetentionDays
+		retentionDays = &days
+	}
+	return &job.Job{
+		Id:                   mj.id,
+		Name:                 mj.name,
+		CronExpString:        mj.cronExpString,
+		Timezone:             mj.timezone,
+		Endpoint:             mj.endpoint,
+		HttpMethod:           mj.httpMethod,
+		MaxRetries:           mj.maxRetries,
+		Headers:              map[string]string{},
+		SuccessStatuses:      append([]int{}, mj.successStatuses...),
+		AlertStrategy:        mj.alertStrategy,
+		AlertEndpoint:        mj.alertEndpoint,
+		AlertMethod:          mj.alertMethod,
+		AlertHeaders:         copyHeaders(mj.alertHeaders),
+		AlertPayload:         mj.alertPayload,
+		Labels:               copyHeaders(mj.labels),
+		Locations:            append([]string{}, mj.locations...),
+		AlertQuorum:          mj.alertQuorum,
+		ResultsRetentionDays: retentionDays,
+		Status:               mj.status,
+		Succeeded:            mj.succeeded,
+		ClaimedBy:            mj.claimedBy,
+		LastExecution:        time.UnixMicro(mj.lastExecution),
+		ShouldExecuteAt:      time.UnixMicro(mj.shouldExecuteAt),
+		LastResponseAt:       time.UnixMicro(mj.lastResponseAt),
+		LastMessage:          mj.lastMessage,
+		LastStatusCode:       mj.lastStatusCode,
+		CreatedAt:            int(mj.createdAt),
+		UpdatedAt:            mj.updatedAt,
+		UpdatedBy:            mj.updatedBy,
+		Handlers:             job.Handlers{},
+	}
+}
+
+// Gets the definition of a job, wherever it is claimed. Deleted jobs are not found.
+func (s *MemoryStorage) GetJob(jobId uuid.UUID) (*job.Job, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	mj, ok := s.jobs[jobId]
+	if !ok || mj.deletedAt != 0 || !s.ownsJob(mj) {
+		return nil, ErrNotFound
+	}
+	return mj.definition(), nil
+}
+
+// Lists the jobs of every scheduler that match the filters, a page at a time
+func (s *MemoryStorage) ListJobs(in ListJobsInput) (*JobsPage, error) {
+	c, err := in.cursor()
+	if err != nil {
+		return nil, err
+	}
+	if !IsJobSortField(in.sortBy()) {
+		return nil, fmt.Errorf("jobs can't be sorted by %q", in.sortBy())
+	}
+
+	s.lock.Lock()
+	jobs := []*job.Job{}
+	for _, mj := range s.jobs {
+		if mj.deletedAt != 0 || !s.ownsJob(mj) || !in.Selector.Matches(mj.labels) ||
+			(in.Status != "" && mj.status != in.Status) ||
+			(in.Succeeded != "" && mj.succeeded != in.Succeeded) ||
+			!strings.Contains(strings.ToLower(mj.name), strings.ToLower(in.Name)) {
+			continue
+		}
+		jobs = append(jobs, mj.definition())
+	}
+	s.lock.Unlock()
+
+	sort.Slice(jobs, func(i, k int) bool { return in.compare(in.sortKey(jobs[i]), in.sortKey(jobs[k])) < 0 })
+	if c != nil {
+		from := sort.Search(len(jobs), func(i int) bool { return in.compare(in.sortKey(jobs[i]), *c) > 0 })
+		jobs = jobs[from:]
+	}
+	if len(jobs) > in.Limit+1 {
+		jobs = jobs[:in.Limit+1]
+	}
+	return newJobsPage(in, jobs), nil
+}
@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"sort"
+	"time"
+
+	"github.com/gofrs/uuid"
+
+	"github.com/back-end-labs/ruok/pkg/config"
+)
+
+// Key of a row of ruok.job_locations
+type locationKey struct {
+	jobId    uuid.UUID
+	location string
+}
+
+// A row of ruok.job_locations, claimedBy is empty while nobody claims it
+type memoryLocation struct {
+	claimedBy     string
+	lastExecution int64
+	failing       bool
+}
+
+func hasLocation(locations []string, location string) bool {
+	for _, l := range locations {
+		if l == location {
+			return true
+		}
+	}
+	return false
+}
+
+// Location we can claim of a job without claims in its jobs row, empty if there is none.
+// Call it holding the lock.
+func (s *MemoryStorage) claimableLocation(mj *memoryJob) string {
+	location := config.Location()
+	if location == "" || !hasLocation(mj.locations, location) {
+		return ""
+	}
+	if l, ok := s.locations[locationKey{mj.id, location}]; ok && l.claimedBy != "" {
+		return ""
+	}
+	return location
+}
+
+// Location of the job we claimed, empty if we didn't claim any. Call it holding the lock.
+func (s *MemoryStorage) claimedLocation(jobId uuid.UUID) string {
+	claimed := []string{}
+	for key, l := range s.locations {
+		if key.jobId == jobId && l.claimedBy == config.AppName() {
+			claimed = append(claimed, key.location)
+		}
+	}
+	if len(claimed) == 0 {
+		return ""
+	}
+	sort.Strings(claimed)
+	return claimed[0]
+}
+
+// Schedulers that claimed a location of the job, sorted by location. Call it holding the lock.
+func (s *MemoryStorage) locationOwners(jobId uuid.UUID) []string {
+	locations := []string{}
+	for key, l := range s.locations {
+		if key.jobId == jobId && l.claimedBy != "" {
+			locations = append(locations, key.location)
+		}
+	}
+	sort.Strings(locations)
+	owners := make([]string, len(locations))
+	for i, location := range locations {
+		owners[i] = s.locations[locationKey{jobId, location}].claimedBy
+	}
+	return owners
+}
+
+// Releases the locations of the job, returns the schedulers that had them. Call it holding the lock.
+func (s *MemoryStorage) releaseLocations(jobId uuid.UUID) []string {
+	owners := s.locationOwners(jobId)
+	for key, l := range s.locations {
+		if key.jobId == jobId {
+			l.claimedBy = ""
+		}
+	}
+	return owners
+}
+
+// Notifies every scheduler in "channels" once, call it after releasing the lock
+func (s *MemoryStorage) notifyAll(channels []string, n Notification) error {
+	notified := map[string]bool{}
+	for _, channel := range channels {
+		if notified[channel] {
+			continue
+		}
+		notified[channel] = true
+		if err := s.notify(channel, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Keeps the result of a location and returns how many locations of the job failed their last execution since "since"
+func (s *MemoryStorage) RecordLocationResult(r LocationResult, since time.Time) (int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	key := locationKey{r.JobId, r.Location}
+	l, ok := s.locations[key]
+	if !ok {
+		l = &memoryLocation{}
+		s.locations[key] = l
+	}
+	l.lastExecution = r.ExecutedAt.UnixMicro()
+	l.failing = r.Failed
+
+	mj, ok := s.jobs[r.JobId]
+	if !ok {
+		return 0, nil
+	}
+	failing := 0
+	for key, l := range s.locations {
+		if key.jobId == r.JobId && l.failing && l.lastExecution >= since.UnixMicro() && hasLocation(mj.locations, key.location) {
+			failing++
+		}
+	}
+	return failing, nil
+}
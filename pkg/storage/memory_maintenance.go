@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/rs/zerolog/log"
+
+	"github.com/back-end-labs/ruok/pkg/maintenance"
+)
+
+func (s *MemoryStorage) CreateMaintenanceWindow(w CreateMaintenanceWindowInput) error {
+	id, err := uuid.NewV7()
+	if err != nil {
+		log.Error().Err(err).Msg("could not create uuidv7 for new maintenance window")
+		return err
+	}
+
+	window := &memoryWindow{Window: maintenance.Window{
+		Id:        id,
+		Name:      w.Name,
+		Mode:      w.Mode,
+		Timezone:  TimezoneOrDefault(w.Timezone),
+		JobIds:    append([]uuid.UUID{}, w.JobIds...),
+		Selector:  w.Selector,
+		CreatedAt: int(time.Now().UnixMilli()),
+	}}
+	// times are kept with the precision of the db
+	if w.CronExpString != "" {
+		window.CronExpString = w.CronExpString
+		window.DurationSeconds = w.DurationSeconds
+	} else {
+		window.StartsAt = time.UnixMicro(w.StartsAt.UnixMicro())
+		window.EndsAt = time.UnixMicro(w.EndsAt.UnixMicro())
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.windows = append(s.windows, window)
+	return nil
+}
+
+// Lists the maintenance windows that are not deleted and could still be active
+func (s *MemoryStorage) GetMaintenanceWindows() []*maintenance.Window {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := time.Now()
+	windows := []*maintenance.Window{}
+	for _, mw := range s.windows {
+		if mw.deletedAt != 0 || (!mw.IsRecurring() && !mw.EndsAt.After(now)) {
+			continue
+		}
+		w := mw.Window
+		w.JobIds = append([]uuid.UUID{}, mw.JobIds...)
+		windows = append(windows, &w)
+	}
+	return windows
+}
+
+// Soft deletes a maintenance window
+func (s *MemoryStorage) DeleteMaintenanceWindow(id uuid.UUID) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, mw := range s.windows {
+		if mw.Id == id && mw.deletedAt == 0 {
+			mw.deletedAt = time.Now().UnixMilli()
+			return nil
+		}
+	}
+	return ErrNotFound
+}
@@ -0,0 +1,239 @@
+package storage
+
+import (
+	"bytes"
+	"sort"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/rs/zerolog/log"
+
+	"github.com/back-end-labs/ruok/pkg/config"
+	"github.com/back-end-labs/ruok/pkg/job"
+)
+
+// Keeps an execution result.
+// When results are buffered it only queues it, see BufferResults.
+func (s *MemoryStorage) WriteDone(j *job.Job) error {
+	row, err := newResultRow(j)
+	if err != nil {
+		return err
+	}
+	if s.buffer != nil && s.buffer.add(row) {
+		return nil
+	}
+	return s.writeResults([]resultRow{row})
+}
+
+// Keeps the results and updates the last execution of their jobs.
+// Results already kept are ignored, so spooled ones can be retried safely.
+func (s *MemoryStorage) writeResults(rows []resultRow) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	createdAt := time.Now().UnixMilli()
+	latest := map[uuid.UUID]resultRow{}
+	for _, r := range rows {
+		if previous, ok := latest[r.JobId]; !ok || previous.ExecutionTime <= r.ExecutionTime {
+			latest[r.JobId] = r
+		}
+		if _, ok := s.results[r.Id]; ok {
+			continue
+		}
+		r.SuccessStatuses = append([]int{}, r.SuccessStatuses...)
+		s.results[r.Id] = &memoryResult{resultRow: r, createdAt: createdAt}
+	}
+
+	// only the latest execution of each job matters for the jobs table
+	for _, r := range latest {
+		mj, ok := s.jobs[r.JobId]
+		if !ok {
+			continue
+		}
+		mj.lastExecution = r.ExecutionTime
+		mj.shouldExecuteAt = r.ShouldExecuteAt
+		mj.lastResponseAt = r.LastResponseAt
+		mj.lastMessage = r.LastMessage
+		mj.lastStatusCode = r.LastStatusCode
+		mj.succeeded = r.Succeeded
+	}
+	return nil
+}
+
+// Starts buffering execution results, see config.Configs.ResultsBatchSize
+func (s *MemoryStorage) BufferResults(size int, interval time.Duration, spool string) {
+	if s.buffer != nil {
+		return
+	}
+	s.buffer = newResultBuffer(size, interval, spool, s.writeResults)
+}
+
+// Keeps buffered results and stops buffering, later results are kept right away
+func (s *MemoryStorage) FlushResults() error {
+	if s.buffer == nil {
+		return nil
+	}
+	return s.buffer.close()
+}
+
+// Gets the executions of a job claimed by this instance, newest first
+func (s *MemoryStorage) GetClaimedJobsExecutions(jobId uuid.UUID, limit int, offset int) []*job.JobExecution {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	results := []*memoryResult{}
+	if !s.ownsJobId(jobId) {
+		return []*job.JobExecution{}
+	}
+	for _, r := range s.results {
+		if r.ClaimedBy == config.AppName() && r.JobId == jobId {
+			results = append(results, r)
+		}
+	}
+	sort.Slice(results, func(i, k int) bool { return bytes.Compare(results[i].Id.Bytes(), results[k].Id.Bytes()) > 0 })
+
+	if offset > len(results) {
+		offset = len(results)
+	}
+	return memoryExecutions(results[offset:], limit)
+}
+
+// Gets the executions of a job from every scheduler, newest first.
+// The page starts after the execution "before", or from the newest one when it is uuid.Nil.
+func (s *MemoryStorage) ListJobExecutions(jobId uuid.UUID, limit int, before uuid.UUID) []*job.JobExecution {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	results := []*memoryResult{}
+	if !s.ownsJobId(jobId) {
+		return []*job.JobExecution{}
+	}
+	for _, r := range s.results {
+		if r.JobId == jobId && (before == uuid.Nil || bytes.Compare(r.Id.Bytes(), before.Bytes()) < 0) {
+			results = append(results, r)
+		}
+	}
+	sort.Slice(results, func(i, k int) bool { return bytes.Compare(results[i].Id.Bytes(), results[k].Id.Bytes()) > 0 })
+	return memoryExecutions(results, limit)
+}
+
+// The first "limit" results as executions
+func memoryExecutions(results []*memoryResult, limit int) []*job.JobExecution {
+	executions := []*job.JobExecution{}
+	for i := 0; i < len(results) && len(executions) < limit; i++ {
+		r := results[i]
+		executions = append(executions, &job.JobExecution{
+			Id:              r.Id,
+			JobId:           r.JobId,
+			CronExpString:   r.CronExpString,
+			Endpoint:        r.Endpoint,
+			HttpMethod:      r.HttpMethod,
+			LastExecution:   time.UnixMicro(r.ExecutionTime),
+			ShouldExecuteAt: time.UnixMicro(r.ShouldExecuteAt),
+			LastResponseAt:  time.UnixMicro(r.LastResponseAt),
+			LastMessage:     r.LastMessage,
+			LastStatusCode:  r.LastStatusCode,
+			SuccessStatuses: append([]int{}, r.SuccessStatuses...),
+			CreatedAt:       int(r.createdAt),
+			Succeeded:       r.Succeeded,
+			Trigger:         r.Trigger,
+			QueueWaitMicro:  r.QueueWait,
+			ClaimedBy:       r.ClaimedBy,
+			Location:        r.Location,
+		})
+	}
+	return executions
+}
+
+// Gets the result of a manual run. Returns nil if it isn't there yet.
+func (s *MemoryStorage) GetRunResult(runId uuid.UUID) *job.ExecutionResult {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	r, ok := s.results[runId]
+	if !ok || r.Trigger != job.TriggerManual || !s.ownsJobId(r.JobId) {
+		return nil
+	}
+	return &job.ExecutionResult{
+		Status:       r.LastStatusCode,
+		Message:      r.LastMessage,
+		ResponseTime: time.UnixMicro(r.LastResponseAt),
+	}
+}
+
+// Rolls results older than their retention into aggregates, like ruok.compact_job_results() does
+func (s *MemoryStorage) CompactResults(retentionDays int, hourlyRetentionDays int) (int64, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := time.Now()
+	expired := []compactedResult{}
+	for id, r := range s.results {
+		retention := retentionDays
+		if mj, ok := s.jobs[r.JobId]; ok && mj.resultsRetentionDays != nil {
+			retention = *mj.resultsRetentionDays
+		}
+		if retention <= 0 || r.ExecutionTime >= compactionCutoff(now, retention) {
+			continue
+		}
+		expired = append(expired, newCompactedResult(id.String(), r.JobId.String(), r.ExecutionTime, r.Succeeded, r.LastResponseAt))
+		delete(s.results, id)
+	}
+
+	// buckets may already exist when late results are compacted, percentiles of the bigger group win
+	for key, b := range bucketResults(expired) {
+		a, ok := s.aggregates[key]
+		if !ok {
+			a = &memoryAggregate{}
+			s.aggregates[key] = a
+		}
+		if b.executions > a.executions {
+			a.p50 = percentile(b.latencies, 0.5)
+			a.p95 = percentile(b.latencies, 0.95)
+		}
+		a.executions += b.executions
+		a.failures += b.failures
+	}
+
+	if hourlyRetentionDays > 0 {
+		cutoff := now.AddDate(0, 0, -hourlyRetentionDays).UnixMicro()
+		for key := range s.aggregates {
+			if key.granularity == GranularityHour && key.bucketStart < cutoff {
+				delete(s.aggregates, key)
+			}
+		}
+	}
+	return int64(len(expired)), nil
+}
+
+// Lists the aggregated results of a job between two times, aligned to the granularity.
+// Compacted aggregates are merged with the ones computed from results not compacted yet.
+// Returns nil if something goes wrong.
+func (s *MemoryStorage) GetResultAggregates(jobId uuid.UUID, granularity string, from time.Time, to time.Time) []*job.ResultAggregate {
+	size, ok := granularitySizes[granularity]
+	if !ok {
+		log.Error().Msgf("unknown granularity %q", granularity)
+		return nil
+	}
+	from = from.UTC().Truncate(size)
+	merge := newAggregatesMerge(granularity, size)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if !s.ownsJobId(jobId) {
+		return merge.list()
+	}
+	for key, a := range s.aggregates {
+		if key.jobId != jobId.String() || key.granularity != granularity {
+			continue
+		}
+		if key.bucketStart >= from.UnixMicro() && key.bucketStart < to.UnixMicro() {
+			merge.addCompacted(key.bucketStart, a.executions, a.failures, a.p50, a.p95)
+		}
+	}
+	for _, r := range s.results {
+		if r.JobId == jobId && r.ExecutionTime >= from.UnixMicro() && r.ExecutionTime < to.UnixMicro() {
+			merge.addResult(newCompactedResult("", "", r.ExecutionTime, r.Succeeded, r.LastResponseAt))
+		}
+	}
+	return merge.list()
+}
@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/gofrs/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Version of the notifications we send and understand
+const NotificationVersion = 1
+
+// Events sent over the LISTEN/NOTIFY channel of a scheduler
+const (
+	// The job changed, the scheduler should get its updates (resumed jobs are updates too)
+	EventUpdated = "updated"
+	// The job was soft deleted and should be dropped
+	EventDeleted = "deleted"
+	// The job was paused and should be dropped
+	EventPaused = "paused"
+	// The job should run right away, see RequestRun
+	EventRun = "run"
+	// The job should be released so other schedulers can claim it.
+	// Without a job id it stops the listener, as bare "release" payloads used to do.
+	EventRelease = "release"
+	// Sent by the listener itself after reconnecting, as we may have missed notifications.
+	// It is never accepted from the channel.
+	EventResync = "resync"
+)
+
+var ErrMalformedNotification = errors.New("malformed notification")
+
+// A message for the scheduler listening on a channel, sent as JSON.
+// Eg: {"v":1,"event":"updated","jobId":"018f0c2c-6a8e-7b5e-9a39-1f0b7b0f0e1a"}
+type Notification struct {
+	Version int       `json:"v"`
+	Event   string    `json:"event"`
+	JobId   uuid.UUID `json:"jobId"`
+	// Only for EventRun, the id the execution result will have
+	RunId uuid.UUID `json:"runId,omitempty"`
+}
+
+func NewNotification(event string, jobId uuid.UUID) Notification {
+	return Notification{Version: NotificationVersion, Event: event, JobId: jobId}
+}
+
+func (n Notification) Validate() error {
+	if n.Version != NotificationVersion {
+		return fmt.Errorf("%w: unsupported version %d", ErrMalformedNotification, n.Version)
+	}
+	switch n.Event {
+	case EventUpdated, EventDeleted, EventPaused:
+	case EventRun:
+		if n.RunId == uuid.Nil {
+			return fmt.Errorf("%w: %q events need a run id", ErrMalformedNotification, n.Event)
+		}
+	case EventRelease:
+		return nil
+	default:
+		return fmt.Errorf("%w: unknown event %q", ErrMalformedNotification, n.Event)
+	}
+	if n.JobId == uuid.Nil {
+		return fmt.Errorf("%w: %q events need a job id", ErrMalformedNotification, n.Event)
+	}
+	return nil
+}
+
+func (n Notification) Payload() (string, error) {
+	if err := n.Validate(); err != nil {
+		return "", err
+	}
+	b, err := json.Marshal(n)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// Parses and validates a notification payload.
+//
+// Payloads from before the JSON protocol are still understood: a bare job id is an update,
+// and "release" stops the listener.
+func ParseNotification(payload string) (Notification, error) {
+	if payload == EventRelease {
+		return Notification{Version: NotificationVersion, Event: EventRelease}, nil
+	}
+	if id, err := uuid.FromString(payload); err == nil {
+		return NewNotification(EventUpdated, id), nil
+	}
+
+	var n Notification
+	if err := json.Unmarshal([]byte(payload), &n); err != nil {
+		return Notification{}, fmt.Errorf("%w: %s", ErrMalformedNotification, err.Error())
+	}
+	if err := n.Validate(); err != nil {
+		return Notification{}, err
+	}
+	return n, nil
+}
+
+// Anything able to run a query, like a pool or a transaction
+type execer interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+}
+
+// Sends a notification to the scheduler listening on "channel"
+func notify(ctx context.Context, db execer, channel string, n Notification) error {
+	payload, err := n.Payload()
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(ctx, "select pg_notify($1, $2)", channel, payload)
+	return err
+}
@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseNotification(t *testing.T) {
+	jobId, _ := uuid.NewV7()
+	runId, _ := uuid.NewV7()
+
+	tests := []struct {
+		name        string
+		payload     string
+		expected    Notification
+		shouldError bool
+	}{
+		{
+			name:     "Updated",
+			payload:  `{"v":1,"event":"updated","jobId":"` + jobId.String() + `"}`,
+			expected: NewNotification(EventUpdated, jobId),
+		},
+		{
+			name:     "Run",
+			payload:  `{"v":1,"event":"run","jobId":"` + jobId.String() + `","runId":"` + runId.String() + `"}`,
+			expected: Notification{Version: 1, Event: EventRun, JobId: jobId, RunId: runId},
+		},
+		{
+			name:     "ReleaseWithoutJob",
+			payload:  `{"v":1,"event":"release"}`,
+			expected: Notification{Version: 1, Event: EventRelease},
+		},
+		{
+			name:     "LegacyJobId",
+			payload:  jobId.String(),
+			expected: NewNotification(EventUpdated, jobId),
+		},
+		{
+			name:     "LegacyRelease",
+			payload:  "release",
+			expected: Notification{Version: 1, Event: EventRelease},
+		},
+		{name: "NotJSON", payload: "hello", shouldError: true},
+		{name: "UnknownVersion", payload: `{"v":2,"event":"updated","jobId":"` + jobId.String() + `"}`, shouldError: true},
+		{name: "MissingVersion", payload: `{"event":"updated","jobId":"` + jobId.String() + `"}`, shouldError: true},
+		{name: "UnknownEvent", payload: `{"v":1,"event":"explode","jobId":"` + jobId.String() + `"}`, shouldError: true},
+		{name: "MissingJobId", payload: `{"v":1,"event":"paused"}`, shouldError: true},
+		{name: "RunWithoutRunId", payload: `{"v":1,"event":"run","jobId":"` + jobId.String() + `"}`, shouldError: true},
+		{name: "BadJobId", payload: `{"v":1,"event":"deleted","jobId":"nope"}`, shouldError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := ParseNotification(tt.payload)
+			if tt.shouldError {
+				assert.ErrorIs(t, err, ErrMalformedNotification)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, n)
+		})
+	}
+}
+
+func TestNotificationPayloadRoundTrip(t *testing.T) {
+	jobId, _ := uuid.NewV7()
+	n := NewNotification(EventDeleted, jobId)
+
+	payload, err := n.Payload()
+	assert.NoError(t, err)
+	parsed, err := ParseNotification(payload)
+	assert.NoError(t, err)
+	assert.Equal(t, n, parsed)
+
+	_, err = Notification{Version: NotificationVersion, Event: "nope", JobId: jobId}.Payload()
+	assert.ErrorIs(t, err, ErrMalformedNotification)
+}
@@ -0,0 +1,295 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/back-end-labs/ruok/pkg/job"
+)
+
+// Failed writes of a spooled result, while the db takes the others, before it is moved to the dead-letter file
+var maxSpoolAttempts = 5
+
+// Wrapped by the errors of writes the db answered and refused, unlike the ones that could not reach it.
+// Only those count as failed writes of a spooled result.
+var errResultsRefused = errors.New("the db refused the results")
+
+// A result waiting in the spool file
+type spooledRow struct {
+	resultRow
+	Attempts int `json:"attempts,omitempty"`
+}
+
+// Keeps execution results in memory and writes them in batches,
+// when the batch is full or the interval passes, whatever happens first.
+// Results that can't be written are appended to a spool file and retried on the next flush,
+// and the ones the db keeps refusing end up in a dead-letter file next to it.
+type resultBuffer struct {
+	lock     sync.Mutex
+	rows     []resultRow
+	closed   bool
+	size     int
+	interval time.Duration
+	spool    string
+	write    func([]resultRow) error
+	// only one flush at a time, so rows and the spool are written in order
+	flushing sync.Mutex
+	full     chan struct{}
+	quit     chan struct{}
+	done     chan struct{}
+}
+
+func newResultBuffer(size int, interval time.Duration, spool string, write func([]resultRow) error) *resultBuffer {
+	if size < 1 {
+		size = 1
+	}
+	b := &resultBuffer{
+		rows:     make([]resultRow, 0, size),
+		size:     size,
+		interval: interval,
+		spool:    spool,
+		write:    write,
+		full:     make(chan struct{}, 1),
+		quit:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go b.run()
+	return b
+}
+
+// Queues a result. Returns false once the buffer was closed, so the caller writes it by itself.
+func (b *resultBuffer) add(r resultRow) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.closed {
+		return false
+	}
+	b.rows = append(b.rows, r)
+	// someone may be waiting for the result of a manual run
+	if len(b.rows) >= b.size || r.Trigger == job.TriggerManual {
+		select {
+		case b.full <- struct{}{}:
+		default:
+		}
+	}
+	return true
+}
+
+func (b *resultBuffer) run() {
+	defer close(b.done)
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.quit:
+			return
+		case <-ticker.C:
+		case <-b.full:
+		}
+		b.flush()
+	}
+}
+
+// Writes spooled and buffered results. If the db can't take the buffered ones they are spooled.
+// Spooled results are written apart, so the ones the db refuses don't hold back the rest.
+func (b *resultBuffer) flush() error {
+	b.flushing.Lock()
+	defer b.flushing.Unlock()
+
+	b.lock.Lock()
+	rows := b.rows
+	b.rows = make([]resultRow, 0, b.size)
+	b.lock.Unlock()
+
+	spooled, err := readSpool(b.spool)
+	if err != nil {
+		log.Error().Err(err).Msgf("could not read results spool file %q", b.spool)
+	}
+	if len(spooled) == 0 && len(rows) == 0 {
+		return nil
+	}
+
+	var spooledErr error
+	retried := false
+	if len(spooled) > 0 {
+		spooledErr = b.write(spooledResults(spooled))
+		if spooledErr == nil {
+			log.Info().Msgf("wrote %d spooled results", len(spooled))
+			if err := os.Remove(b.spool); err != nil {
+				log.Error().Err(err).Msgf("could not remove results spool file %q, its results may be written twice", b.spool)
+			}
+		} else if errors.Is(spooledErr, errResultsRefused) {
+			// the db answered, so some of the spooled ones are the problem whether there are new rows or not
+			log.Error().Err(spooledErr).Msgf("could not write %d spooled results, writing them one by one", len(spooled))
+			spooledErr = b.retrySpooled(spooled)
+			retried = true
+		}
+	}
+
+	if len(rows) > 0 {
+		if err := b.write(rows); err != nil {
+			log.Error().Err(err).Msgf("could not write %d results, spooling them into %q", len(rows), b.spool)
+			if spoolErr := appendSpool(b.spool, newSpooledRows(rows)); spoolErr != nil {
+				log.Error().Err(spoolErr).Msg("could not spool results, keeping them in memory")
+				b.lock.Lock()
+				b.rows = append(rows, b.rows...)
+				b.lock.Unlock()
+				return errors.Join(err, spoolErr)
+			}
+			return err
+		}
+		if spooledErr != nil && !retried {
+			// the db takes results, so some of the spooled ones are the problem
+			log.Error().Err(spooledErr).Msgf("could not write %d spooled results, writing them one by one", len(spooled))
+			return b.retrySpooled(spooled)
+		}
+	}
+	return spooledErr
+}
+
+// Writes the spooled results one by one. The ones the db refuses maxSpoolAttempts times
+// are moved to the dead-letter file, the rest are kept for the next flush.
+func (b *resultBuffer) retrySpooled(spooled []spooledRow) error {
+	kept := []spooledRow{}
+	dead := []spooledRow{}
+	for _, r := range spooled {
+		err := b.write([]resultRow{r.resultRow})
+		if err == nil {
+			continue
+		}
+		if errors.Is(err, errResultsRefused) {
+			r.Attempts++
+		}
+		if r.Attempts < maxSpoolAttempts {
+			kept = append(kept, r)
+			continue
+		}
+		log.Error().Err(err).Msgf("result %v of job %v could not be written %d times, moving it to %q", r.Id, r.JobId, r.Attempts, b.deadLetter())
+		dead = append(dead, r)
+	}
+	if err := appendSpool(b.deadLetter(), dead); err != nil {
+		log.Error().Err(err).Msgf("could not write dead-letter file %q, keeping its results in the spool", b.deadLetter())
+		kept = append(kept, dead...)
+	}
+	return rewriteSpool(b.spool, kept)
+}
+
+// Results the db keeps refusing are left here, to be looked at by someone
+func (b *resultBuffer) deadLetter() string {
+	return b.spool + ".dead"
+}
+
+// Stops the periodic flush and writes what is left.
+// Results added after closing are written right away by the caller.
+func (b *resultBuffer) close() error {
+	b.lock.Lock()
+	if b.closed {
+		b.lock.Unlock()
+		return nil
+	}
+	b.closed = true
+	b.lock.Unlock()
+	close(b.quit)
+	<-b.done
+	return b.flush()
+}
+
+func newSpooledRows(rows []resultRow) []spooledRow {
+	spooled := make([]spooledRow, len(rows))
+	for i, r := range rows {
+		spooled[i] = spooledRow{resultRow: r}
+	}
+	return spooled
+}
+
+func spooledResults(spooled []spooledRow) []resultRow {
+	rows := make([]resultRow, len(spooled))
+	for i, r := range spooled {
+		rows[i] = r.resultRow
+	}
+	return rows
+}
+
+func readSpool(path string) ([]spooledRow, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	rows := []spooledRow{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var r spooledRow
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			log.Error().Err(err).Msg("skipping malformed line of results spool file")
+			continue
+		}
+		rows = append(rows, r)
+	}
+	return rows, scanner.Err()
+}
+
+func appendSpool(path string, rows []spooledRow) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, r := range rows {
+		if err := enc.Encode(r); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Replaces the spool with rows, removing it when there are none
+func rewriteSpool(path string, rows []spooledRow) error {
+	if len(rows) == 0 {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	tmp := path + ".tmp"
+	os.Remove(tmp)
+	if err := appendSpool(tmp, rows); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Starts buffering execution results, see config.Configs.ResultsBatchSize
+func (sqls *SQLStorage) BufferResults(size int, interval time.Duration, spool string) {
+	if sqls.results != nil {
+		return
+	}
+	sqls.results = newResultBuffer(size, interval, spool, sqls.writeResults)
+}
+
+// Writes buffered results and stops buffering, later results are written right away
+func (sqls *SQLStorage) FlushResults() error {
+	if sqls.results == nil {
+		return nil
+	}
+	return sqls.results.close()
+}
@@ -0,0 +1,228 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/back-end-labs/ruok/pkg/job"
+)
+
+// Collects written rows and fails while down is true, or when a batch has a refused row
+type fakeResultsWriter struct {
+	lock    sync.Mutex
+	down    bool
+	refused map[uuid.UUID]bool
+	batches [][]resultRow
+	written chan struct{}
+}
+
+func newFakeResultsWriter() *fakeResultsWriter {
+	return &fakeResultsWriter{refused: map[uuid.UUID]bool{}, written: make(chan struct{}, 100)}
+}
+
+func (w *fakeResultsWriter) write(rows []resultRow) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.down {
+		return errors.New("db is down")
+	}
+	for _, r := range rows {
+		if w.refused[r.Id] {
+			return fmt.Errorf("the db refuses the row: %w", errResultsRefused)
+		}
+	}
+	w.batches = append(w.batches, rows)
+	w.written <- struct{}{}
+	return nil
+}
+
+func (w *fakeResultsWriter) setDown(down bool) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.down = down
+}
+
+func (w *fakeResultsWriter) rows() []resultRow {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	rows := []resultRow{}
+	for _, b := range w.batches {
+		rows = append(rows, b...)
+	}
+	return rows
+}
+
+func makeResultRows(n int, trigger string) []resultRow {
+	rows := make([]resultRow, n)
+	for i := range rows {
+		id, _ := uuid.NewV7()
+		rows[i] = resultRow{Id: id, JobId: id, Trigger: trigger}
+	}
+	return rows
+}
+
+func waitForWrite(t *testing.T, w *fakeResultsWriter) {
+	select {
+	case <-w.written:
+	case <-time.After(time.Second):
+		t.Fatal("results were not written")
+	}
+}
+
+func TestResultBuffer_FlushesWhenFull(t *testing.T) {
+	w := newFakeResultsWriter()
+	b := newResultBuffer(3, time.Hour, filepath.Join(t.TempDir(), "spool"), w.write)
+	defer b.close()
+
+	for _, r := range makeResultRows(3, job.TriggerSchedule) {
+		assert.True(t, b.add(r))
+	}
+	waitForWrite(t, w)
+	assert.Len(t, w.rows(), 3)
+}
+
+func TestResultBuffer_FlushesOnInterval(t *testing.T) {
+	w := newFakeResultsWriter()
+	b := newResultBuffer(100, 20*time.Millisecond, filepath.Join(t.TempDir(), "spool"), w.write)
+	defer b.close()
+
+	b.add(makeResultRows(1, job.TriggerSchedule)[0])
+	waitForWrite(t, w)
+	assert.Len(t, w.rows(), 1)
+}
+
+func TestResultBuffer_FlushesManualRunsRightAway(t *testing.T) {
+	w := newFakeResultsWriter()
+	b := newResultBuffer(100, time.Hour, filepath.Join(t.TempDir(), "spool"), w.write)
+	defer b.close()
+
+	b.add(makeResultRows(1, job.TriggerManual)[0])
+	waitForWrite(t, w)
+	assert.Len(t, w.rows(), 1)
+}
+
+func TestResultBuffer_CloseFlushesWhatIsLeft(t *testing.T) {
+	w := newFakeResultsWriter()
+	b := newResultBuffer(100, time.Hour, filepath.Join(t.TempDir(), "spool"), w.write)
+
+	for _, r := range makeResultRows(5, job.TriggerSchedule) {
+		b.add(r)
+	}
+	assert.NoError(t, b.close())
+	assert.Len(t, w.rows(), 5)
+	assert.False(t, b.add(makeResultRows(1, job.TriggerSchedule)[0]), "a closed buffer should not take results")
+	assert.NoError(t, b.close(), "closing twice should be harmless")
+}
+
+func TestResultBuffer_SpoolsWhileTheDbIsDown(t *testing.T) {
+	spool := filepath.Join(t.TempDir(), "spool")
+	w := newFakeResultsWriter()
+	w.setDown(true)
+	b := newResultBuffer(100, time.Hour, spool, w.write)
+
+	lost := makeResultRows(2, job.TriggerSchedule)
+	for _, r := range lost {
+		b.add(r)
+	}
+	assert.Error(t, b.flush())
+	spooled, err := readSpool(spool)
+	assert.NoError(t, err)
+	assert.Equal(t, lost, spooledResults(spooled))
+
+	w.setDown(false)
+	fresh := makeResultRows(1, job.TriggerSchedule)
+	b.add(fresh[0])
+	assert.NoError(t, b.close())
+
+	assert.Equal(t, append(lost, fresh...), w.rows(), "spooled results should be written before the new ones")
+	spooled, err = readSpool(spool)
+	assert.NoError(t, err)
+	assert.Empty(t, spooled, "the spool file should be removed once its results are written")
+}
+
+func TestResultBuffer_MovesRefusedRowsToTheDeadLetterFile(t *testing.T) {
+	spool := filepath.Join(t.TempDir(), "spool")
+	w := newFakeResultsWriter()
+	w.setDown(true)
+	b := newResultBuffer(100, time.Hour, spool, w.write)
+	defer b.close()
+
+	spooled := makeResultRows(3, job.TriggerSchedule)
+	refused := spooled[1]
+	w.refused[refused.Id] = true
+	for _, r := range spooled {
+		b.add(r)
+	}
+	assert.Error(t, b.flush())
+	for i := 0; i < maxSpoolAttempts; i++ {
+		b.add(makeResultRows(1, job.TriggerSchedule)[0])
+		assert.Error(t, b.flush())
+	}
+	left, err := readSpool(spool)
+	assert.NoError(t, err)
+	assert.Len(t, left, 3+maxSpoolAttempts)
+	for _, r := range left {
+		assert.Equal(t, 0, r.Attempts, "attempts are not counted while the db is down")
+	}
+
+	w.setDown(false)
+	b.add(makeResultRows(1, job.TriggerSchedule)[0])
+	assert.NoError(t, b.flush())
+	assert.Len(t, w.rows(), 2+maxSpoolAttempts+1, "the refused row doesn't hold back the rest")
+	left, err = readSpool(spool)
+	assert.NoError(t, err)
+	if assert.Len(t, left, 1) {
+		assert.Equal(t, refused, left[0].resultRow)
+		assert.Equal(t, 1, left[0].Attempts)
+	}
+
+	for i := 1; i < maxSpoolAttempts; i++ {
+		b.add(makeResultRows(1, job.TriggerSchedule)[0])
+		assert.NoError(t, b.flush())
+	}
+	left, err = readSpool(spool)
+	assert.NoError(t, err)
+	assert.Empty(t, left, "the spool doesn't grow with rows the db never takes")
+	dead, err := readSpool(b.deadLetter())
+	assert.NoError(t, err)
+	if assert.Len(t, dead, 1) {
+		assert.Equal(t, refused, dead[0].resultRow)
+		assert.Equal(t, maxSpoolAttempts, dead[0].Attempts)
+	}
+	assert.Len(t, w.rows(), 2+maxSpoolAttempts+maxSpoolAttempts)
+}
+
+func TestResultBuffer_MovesRefusedRowsWithoutNewOnes(t *testing.T) {
+	spool := filepath.Join(t.TempDir(), "spool")
+	w := newFakeResultsWriter()
+	b := newResultBuffer(100, time.Hour, spool, w.write)
+	defer b.close()
+
+	spooled := newSpooledRows(makeResultRows(2, job.TriggerSchedule))
+	poison := spooled[0].resultRow
+	w.refused[poison.Id] = true
+	assert.NoError(t, appendSpool(spool, spooled))
+
+	// an idle instance has nothing new to write, the spool is still retried row by row
+	assert.NoError(t, b.flush())
+	assert.Equal(t, []resultRow{spooled[1].resultRow}, w.rows(), "the poison row doesn't hold back the rest")
+	for i := 1; i < maxSpoolAttempts; i++ {
+		assert.NoError(t, b.flush())
+	}
+	left, err := readSpool(spool)
+	assert.NoError(t, err)
+	assert.Empty(t, left)
+	dead, err := readSpool(b.deadLetter())
+	assert.NoError(t, err)
+	if assert.Len(t, dead, 1) {
+		assert.Equal(t, poison, dead[0].resultRow)
+		assert.Equal(t, maxSpoolAttempts, dead[0].Attempts)
+	}
+}
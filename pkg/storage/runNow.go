@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/back-end-labs/ruok/pkg/job"
+	"github.com/gofrs/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+)
+
+// Returned when a job can't be run because no scheduler owns it
+var ErrNotClaimed = &KindError{ErrConflict, "not_claimed", "job is not claimed by any scheduler"}
+
+var firstLocationOwnerQuery = `
+SELECT claimed_by FROM ruok.job_locations
+WHERE job_id = $1 AND claimed_by IS NOT NULL
+ORDER BY location
+LIMIT 1;
+`
+
+// Notifies the scheduler owning the job so it runs it outside its schedule, even when another scheduler claimed it.
+// Jobs with locations run from the first claimed location, sorted by name.
+// Returns the id the execution result will have.
+func (sqls *SQLStorage) RequestRun(jobId uuid.UUID) (uuid.UUID, error) {
+	runId, err := uuid.NewV7()
+	if err != nil {
+		log.Error().Err(err).Msg("could not create uuidv7 for manual run")
+		return uuid.Nil, err
+	}
+
+	ctx := sqls.tenantContext()
+	var owner sql.NullString
+	err = sqls.Db.QueryRow(ctx,
+		"SELECT claimed_by FROM ruok.tenant_jobs() jobs WHERE id = $1",
+		jobId,
+	).Scan(&owner)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return uuid.Nil, ErrNotFound
+	}
+
+	if err != nil {
+		log.Error().Err(err).Msgf("could not get owner of job %v", jobId)
+		return uuid.Nil, errors.New("could not request run")
+	}
+
+	if !owner.Valid {
+		err = sqls.Db.QueryRow(ctx, firstLocationOwnerQuery, jobId).Scan(&owner)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			log.Error().Err(err).Msgf("could not get locations of job %v", jobId)
+			return uuid.Nil, errors.New("could not request run")
+		}
+	}
+
+	if !owner.Valid {
+		return uuid.Nil, ErrNotClaimed
+	}
+
+	n := NewNotification(EventRun, jobId)
+	n.RunId = runId
+	err = notify(ctx, sqls.Db, owner.String, n)
+
+	if err != nil {
+		log.Error().Err(err).Msgf("could not notify run of job %v", jobId)
+		return uuid.Nil, errors.New("could not notify run")
+	}
+	return runId, nil
+}
+
+// Gets the result of a manual run, whichever scheduler ran it. Returns nil if it isn't there yet.
+func (sqls *SQLStorage) GetRunResult(runId uuid.UUID) *job.ExecutionResult {
+	var status sql.NullInt32
+	var message sql.NullString
+	var responseAt sql.NullInt64
+
+	err := sqls.Db.QueryRow(sqls.tenantContext(),
+		"SELECT last_status_code, last_message, last_response_at FROM ruok.manual_run_result($1)",
+		runId,
+	).Scan(&status, &message, &responseAt)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+
+	if err != nil {
+		log.Error().Err(err).Msgf("could not get result of run %v", runId)
+		return nil
+	}
+
+	return &job.ExecutionResult{
+		Status:       int(status.Int32),
+		Message:      message.String,
+		ResponseTime: time.UnixMicro(responseAt.Int64),
+	}
+}
@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/back-end-labs/ruok/pkg/job"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestRun(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, s *testStorage) {
+		s.seed()
+		joblist := s.GetAvailableJobs(1, nil)
+		assert.Len(t, joblist, 1)
+		j := joblist[0]
+
+		runId, err := s.RequestRun(j.Id)
+		assert.NoError(t, err)
+		assert.NotEqual(t, uuid.Nil, runId)
+		assert.Nil(t, s.GetRunResult(runId))
+
+		j.RunId = runId
+		j.Trigger = job.TriggerManual
+		j.LastExecution = time.Now()
+		j.LastResponseAt = time.Now()
+		j.LastStatusCode = 200
+		j.LastMessage = "OK"
+		j.Succeeded = "ok"
+		assert.NoError(t, s.WriteDone(j))
+
+		result := s.GetRunResult(runId)
+		assert.NotNil(t, result)
+		assert.Equal(t, 200, result.Status)
+		assert.Equal(t, "OK", result.Message)
+
+		executions := s.GetClaimedJobsExecutions(j.Id, 10, 0)
+		assert.Len(t, executions, 1)
+		assert.Equal(t, job.TriggerManual, executions[0].Trigger)
+
+		unknown, _ := uuid.NewV7()
+		_, err = s.RequestRun(unknown)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+func TestReadsJobsClaimedByOthers(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, s *testStorage) {
+		jobId, _ := uuid.NewV7()
+		runId, _ := uuid.NewV7()
+		ctx := context.Background()
+		assert.NoError(t, s.admin.execRaw(ctx, fmt.Sprintf(`
+INSERT INTO ruok.jobs (id, job_name, cron_exp_string, endpoint, httpmethod, max_retries, success_statuses, status, claimed_by)
+VALUES ('%s', 'claimed elsewhere', '*/5 * * * *', 'http://localhost/', 'GET', 1, '{200}', 'claimed', 'other-scheduler')`, jobId)))
+		assert.NoError(t, s.admin.execRaw(ctx, fmt.Sprintf(`
+INSERT INTO ruok.job_results (id, job_id, job_name, cron_exp_string, endpoint, httpmethod, execution_time, should_execute_at,
+	last_response_at, last_message, last_status_code, success_statuses, succeeded, status, claimed_by, trigger)
+VALUES ('%s', '%s', 'claimed elsewhere', '*/5 * * * *', 'http://localhost/', 'GET', 1, 1, 2, 'OK', 200, '{200}', 'ok', 'claimed', 'other-scheduler', 'manual')`,
+			runId, jobId)))
+
+		j, err := s.GetJob(jobId)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "other-scheduler", j.ClaimedBy)
+		}
+		page, err := s.ListJobs(ListJobsInput{Limit: 10})
+		if assert.NoError(t, err) {
+			assert.Len(t, page.Jobs, 1)
+		}
+		assert.Len(t, s.ListJobExecutions(jobId, 10, uuid.Nil), 1)
+		_, err = s.RequestRun(jobId)
+		assert.NoError(t, err, "the scheduler that claimed it is asked to run it")
+		if result := s.GetRunResult(runId); assert.NotNil(t, result) {
+			assert.Equal(t, 200, result.Status)
+		}
+	})
+}
@@ -0,0 +1,265 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	_ "embed"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync/atomic"
+
+	"github.com/gofrs/uuid"
+	"github.com/rs/zerolog/log"
+	"modernc.org/sqlite"
+
+	"github.com/back-end-labs/ruok/pkg/config"
+)
+
+// Schema of the sqlite storage, it mirrors the postgres tables created by the migrations.
+// It is applied every time the storage starts, bump sqliteSchemaVersion when changing it.
+//
+//go:embed sqlite_schema.sql
+var sqliteSchema string
+
+const sqliteSchemaVersion = 6
+
+// Statements that bring files created by older versions up to date, keyed by the version they upgrade to.
+// New files get the whole schema and skip them.
+var sqliteUpgrades = map[int]string{
+	2: "ALTER TABLE ruok.jobs ADD COLUMN labels text DEFAULT '{}' NOT NULL;",
+	3: `
+ALTER TABLE ruok.jobs ADD COLUMN locations text DEFAULT '{}' NOT NULL;
+ALTER TABLE ruok.jobs ADD COLUMN alert_quorum integer;
+ALTER TABLE ruok.job_results ADD COLUMN location text;
+`,
+	4: "ALTER TABLE ruok.jobs ADD COLUMN updated_by text;",
+	// files older than 4 got api_keys from the schema, with tenant_id already, so the table is rebuilt instead of altered
+	5: `
+ALTER TABLE ruok.jobs ADD COLUMN tenant_id text DEFAULT 'default' NOT NULL;
+CREATE TABLE ruok.api_keys_v5 (
+	id text PRIMARY KEY NOT NULL,
+	key_name text NOT NULL,
+	prefix text NOT NULL,
+	key_hash text NOT NULL UNIQUE,
+	scope text NOT NULL,
+	created_at integer DEFAULT (CAST(unixepoch('subsec') * 1000 AS integer)) NOT NULL,
+	revoked_at integer,
+	tenant_id text DEFAULT 'default' NOT NULL
+);
+INSERT INTO ruok.api_keys_v5 (id, key_name, prefix, key_hash, scope, created_at, revoked_at)
+SELECT id, key_name, prefix, key_hash, scope, created_at, revoked_at FROM ruok.api_keys;
+DROP TABLE ruok.api_keys;
+ALTER TABLE ruok.api_keys_v5 RENAME TO api_keys;
+`,
+	6: "ALTER TABLE ruok.maintenance_windows ADD COLUMN selector text DEFAULT '' NOT NULL;",
+}
+
+// Storage kept in a single sqlite file, meant for a single scheduler.
+//
+// The file is attached as "ruok" to every connection, so queries use the same table names as postgres.
+// Notifications go through an in-process bus instead of LISTEN/NOTIFY, so
+// several schedulers can't share the file.
+type SQLiteStorage struct {
+	Db       *sql.DB
+	path     string
+	bus      *bus
+	closed   *atomic.Bool
+	listener *listenerState
+	results  *resultBuffer
+	// Only the jobs of the tenant are seen and changed, all of them when empty, see ForTenant
+	tenant string
+	// Jobs the tenant can have at most, 0 has no limit. See WithMaxJobs
+	maxJobs int
+}
+
+// Opens every connection with an in memory main database and the file attached as "ruok".
+// Transactions take the write lock right away, so concurrent writers wait for it instead of failing.
+type sqliteConnector struct {
+	driver *sqlite.Driver
+	path   string
+}
+
+func (c *sqliteConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.driver.Open(":memory:?_txlock=immediate&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, err
+	}
+	execer, ok := conn.(driver.ExecerContext)
+	if !ok {
+		conn.Close()
+		return nil, errors.New("sqlite connection can't exec queries")
+	}
+	_, err = execer.ExecContext(ctx, "ATTACH DATABASE $1 AS ruok", []driver.NamedValue{{Ordinal: 1, Value: c.path}})
+	if err == nil {
+		_, err = execer.ExecContext(ctx, "PRAGMA ruok.journal_mode = WAL", nil)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("could not attach %q: %w", c.path, err)
+	}
+	return conn, nil
+}
+
+func (c *sqliteConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// Opens the sqlite file at "path", creating it and its tables if needed
+func NewSQLiteStorage(path string) (*SQLiteStorage, Closer) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		log.Fatal().Err(err).Msgf("invalid sqlite path %q", path)
+	}
+	db := sql.OpenDB(&sqliteConnector{driver: &sqlite.Driver{}, path: abs})
+
+	if err := migrateSQLite(db); err != nil {
+		db.Close()
+		log.Fatal().Err(err).Msgf("could not create the sqlite schema in %q, aborting.", abs)
+	}
+
+	b, releaseBus := acquireBus(abs)
+	s := &SQLiteStorage{Db: db, path: abs, bus: b, closed: &atomic.Bool{}, listener: newListenerState()}
+	return s, func() {
+		s.closed.Store(true)
+		releaseBus()
+		db.Close()
+	}
+}
+
+func migrateSQLite(db *sql.DB) error {
+	ctx := context.Background()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var version int
+	if err := tx.QueryRowContext(ctx, "PRAGMA ruok.user_version").Scan(&version); err != nil {
+		return err
+	}
+	if version > sqliteSchemaVersion {
+		return fmt.Errorf("the file has schema version %d but this ruok only knows up to %d", version, sqliteSchemaVersion)
+	}
+	if _, err := tx.ExecContext(ctx, sqliteSchema); err != nil {
+		return err
+	}
+	for v := version + 1; version > 0 && v <= sqliteSchemaVersion; v++ {
+		if _, err := tx.ExecContext(ctx, sqliteUpgrades[v]); err != nil {
+			return fmt.Errorf("could not upgrade the schema to version %d: %w", v, err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("PRAGMA ruok.user_version = %d", sqliteSchemaVersion)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStorage) Connected() bool {
+	return !s.closed.Load() && s.Db.PingContext(context.Background()) == nil
+}
+
+// Should register the url, name of the application and so on in the db
+func (s *SQLiteStorage) RegisterSelf() {}
+
+// Connections to a local file are never encrypted
+func (s *SQLiteStorage) GetSSLVersion() (bool, string) {
+	return false, ""
+}
+
+// Returns the state of the subscription used to receive notifications
+func (s *SQLiteStorage) ListenerHealth() ListenerHealth {
+	return s.listener.get()
+}
+
+func (s *SQLiteStorage) execRaw(ctx context.Context, query string, args ...any) error {
+	_, err := s.Db.ExecContext(ctx, query, args...)
+	return err
+}
+
+// Returns a storage that only sees and changes the jobs of the tenant, see SQLStorage.ForTenant
+func (s *SQLiteStorage) ForTenant(tenant string) APIStorage {
+	scoped := *s
+	scoped.tenant = tenant
+	return &scoped
+}
+
+// Returns ErrNotFound when the job belongs to another tenant than the one of the storage,
+// the same way postgres hides the rows of other tenants
+func (s *SQLiteStorage) checkTenant(ctx context.Context, db sqliteQuerier, jobId uuid.UUID) error {
+	if s.tenant == "" {
+		return nil
+	}
+	rows, err := db.QueryContext(ctx, "SELECT 1 FROM ruok.jobs WHERE id = $1 AND tenant_id = $2", jobId, s.tenant)
+	if err == nil {
+		defer rows.Close()
+		if rows.Next() {
+			return nil
+		}
+		err = rows.Err()
+	}
+	if err != nil {
+		log.Error().Err(err).Msgf("could not check the tenant of job %v", jobId)
+		return errors.New("could not check the tenant of the job")
+	}
+	return ErrNotFound
+}
+
+// Returns a storage that refuses to create or bring back jobs once its tenant has "max" of them, see SQLStorage.WithMaxJobs
+func (s *SQLiteStorage) WithMaxJobs(max int) APIStorage {
+	limited := *s
+	limited.maxJobs = max
+	return &limited
+}
+
+// Returns ErrQuotaExceeded if adding a job goes over maxJobs. Transactions take the write lock when they
+// begin, so nobody else adds jobs until this one ends.
+func (s *SQLiteStorage) checkMaxJobs(ctx context.Context, tx *sql.Tx) error {
+	if s.maxJobs == 0 {
+		return nil
+	}
+	var count int
+	err := tx.QueryRowContext(ctx,
+		"SELECT count(*) FROM ruok.jobs WHERE deleted_at IS NULL AND ($1 = '' OR tenant_id = $1)",
+		s.tenant,
+	).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count >= s.maxJobs {
+		return ErrQuotaExceeded
+	}
+	return nil
+}
+
+// FALSE when jobs of the tenant are claimed by another scheduler, which the queries hide like
+// the policies of RUOK_SCHEDULER_ROLE do. A single scheduler uses the file, so it only happens when it's shared.
+func (s *SQLiteStorage) SeesEveryJob() (bool, error) {
+	var hidden int
+	err := s.Db.QueryRowContext(context.Background(),
+		"SELECT count(*) FROM ruok.jobs WHERE deleted_at IS NULL AND claimed_by IS NOT NULL AND claimed_by != $1 AND ($2 = '' OR tenant_id = $2)",
+		config.AppName(),
+		s.tenant,
+	).Scan(&hidden)
+	if err != nil {
+		log.Error().Err(err).Msg("could not count the jobs hidden from the storage")
+		return false, err
+	}
+	return hidden == 0, nil
+}
+
+// Jobs of the tenant that are not deleted
+func (s *SQLiteStorage) CountJobs() (int, error) {
+	var count int
+	err := s.Db.QueryRowContext(context.Background(),
+		"SELECT count(*) FROM ruok.jobs WHERE deleted_at IS NULL AND ($1 = '' OR tenant_id = $1)",
+		s.tenant,
+	).Scan(&count)
+	if err != nil {
+		log.Error().Err(err).Msg("could not count the jobs of the tenant")
+		return 0, err
+	}
+	return count, nil
+}
@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/gofrs/uuid"
+	"github.com/rs/zerolog/log"
+
+	"github.com/back-end-labs/ruok/pkg/auth"
+)
+
+func (s *SQLiteStorage) CreateAPIKey(k CreateAPIKeyInput) (*auth.APIKey, error) {
+	id, err := uuid.NewV7()
+	if err != nil {
+		log.Error().Err(err).Msg("could not create uuidv7 for new api key")
+		return nil, err
+	}
+
+	key := &auth.APIKey{Id: id, Name: k.Name, Prefix: k.Prefix, Scope: k.Scope, Tenant: tenantOrDefault(k.Tenant)}
+	err = s.Db.QueryRowContext(context.Background(), createAPIKeyQuery,
+		id,
+		k.Name,
+		k.Prefix,
+		k.Hash,
+		k.Scope,
+		key.Tenant,
+	).Scan(&key.CreatedAt)
+
+	if err != nil {
+		log.Error().Err(err).Msg("could not insert into api_keys")
+		return nil, errors.New("could not insert into api_keys")
+	}
+	return key, nil
+}
+
+// Finds the key with the hash, revoked keys are not found
+func (s *SQLiteStorage) GetAPIKey(hash string) (*auth.APIKey, error) {
+	key := &auth.APIKey{}
+	err := s.Db.QueryRowContext(context.Background(), getAPIKeyQuery, hash).Scan(
+		&key.Id,
+		&key.Name,
+		&key.Prefix,
+		&key.Scope,
+		&key.Tenant,
+		&key.CreatedAt,
+	)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+
+	if err != nil {
+		log.Error().Err(err).Msg("could not query for api key")
+		return nil, errors.New("could not query for api key")
+	}
+	return key, nil
+}
+
+// Lists every key of the tenant, revoked ones included
+func (s *SQLiteStorage) ListAPIKeys() []*auth.APIKey {
+	rows, err := s.Db.QueryContext(context.Background(), listAPIKeysQuery, s.tenant)
+	if err != nil {
+		log.Error().Err(err).Msg("could not query for api keys")
+		return nil
+	}
+	defer rows.Close()
+
+	keys := []*auth.APIKey{}
+	for rows.Next() {
+		var RevokedAt sql.NullInt64
+		key := &auth.APIKey{}
+
+		err = rows.Scan(&key.Id, &key.Name, &key.Prefix, &key.Scope, &key.Tenant, &key.CreatedAt, &RevokedAt)
+		if err != nil {
+			log.Error().Err(err).Msg("could not scan api keys row")
+			continue
+		}
+		key.RevokedAt = RevokedAt.Int64
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// Stops accepting the key, it is kept so changes made with it can be traced
+func (s *SQLiteStorage) RevokeAPIKey(id uuid.UUID) error {
+	res, err := s.Db.ExecContext(
+		context.Background(),
+		"UPDATE ruok.api_keys SET revoked_at = $1 WHERE id = $2 AND revoked_at IS NULL AND ($3 = '' OR tenant_id = $3)",
+		sqliteNow(),
+		id,
+		s.tenant,
+	)
+	if err != nil {
+		log.Error().Err(err).Msgf("could not revoke api key %v", id)
+		return errors.New("could not revoke api key")
+	}
+	if affected, err := res.RowsAffected(); err == nil && affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gofrs/uuid"
+)
+
+// Postgres arrays are kept in sqlite as their text literal, like '{200,201}'.
+// JSON arrays are understood too, for rows written by hand.
+
+type intArray []int
+
+func (a intArray) Value() (driver.Value, error) {
+	parts := make([]string, len(a))
+	for i, n := range a {
+		parts[i] = strconv.Itoa(n)
+	}
+	return "{" + strings.Join(parts, ",") + "}", nil
+}
+
+func (a *intArray) Scan(src any) error {
+	elems, err := arrayElements(src)
+	if err != nil {
+		return err
+	}
+	ints := intArray{}
+	for _, e := range elems {
+		n, err := strconv.Atoi(e)
+		if err != nil {
+			return fmt.Errorf("invalid int array element %q: %w", e, err)
+		}
+		ints = append(ints, n)
+	}
+	*a = ints
+	return nil
+}
+
+type uuidArray []uuid.UUID
+
+func (a uuidArray) Value() (driver.Value, error) {
+	parts := make([]string, len(a))
+	for i, id := range a {
+		parts[i] = id.String()
+	}
+	return "{" + strings.Join(parts, ",") + "}", nil
+}
+
+func (a *uuidArray) Scan(src any) error {
+	elems, err := arrayElements(src)
+	if err != nil {
+		return err
+	}
+	ids := uuidArray{}
+	for _, e := range elems {
+		id, err := uuid.FromString(e)
+		if err != nil {
+			return fmt.Errorf("invalid uuid array element %q: %w", e, err)
+		}
+		ids = append(ids, id)
+	}
+	*a = ids
+	return nil
+}
+
+// Elements can't have commas, quotes or braces, like the names of locations
+type textArray []string
+
+func (a textArray) Value() (driver.Value, error) {
+	return "{" + strings.Join(a, ",") + "}", nil
+}
+
+func (a *textArray) Scan(src any) error {
+	elems, err := arrayElements(src)
+	if err != nil {
+		return err
+	}
+	*a = append(textArray{}, elems...)
+	return nil
+}
+
+func arrayElements(src any) ([]string, error) {
+	var literal string
+	switch v := src.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		literal = v
+	case []byte:
+		literal = string(v)
+	default:
+		return nil, fmt.Errorf("can't scan %T into an array", src)
+	}
+	literal = strings.TrimSpace(literal)
+
+	if strings.HasPrefix(literal, "[") {
+		var raw []any
+		if err := json.Unmarshal([]byte(literal), &raw); err != nil {
+			return nil, err
+		}
+		elems := make([]string, len(raw))
+		for i, r := range raw {
+			elems[i] = fmt.Sprint(r)
+		}
+		return elems, nil
+	}
+
+	if !strings.HasPrefix(literal, "{") || !strings.HasSuffix(literal, "}") {
+		return nil, fmt.Errorf("invalid array literal %q", literal)
+	}
+	literal = strings.TrimSpace(literal[1 : len(literal)-1])
+	if literal == "" {
+		return []string{}, nil
+	}
+	elems := strings.Split(literal, ",")
+	for i, e := range elems {
+		elems[i] = strings.Trim(strings.TrimSpace(e), `"`)
+	}
+	return elems, nil
+}
@@ -0,0 +1,278 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/gofrs/uuid"
+	"github.com/rs/zerolog/log"
+
+	"github.com/back-end-labs/ruok/pkg/config"
+	"github.com/back-end-labs/ruok/pkg/job"
+)
+
+// Returns what the job looks like, ErrNotFound if it doesn't exist.
+// Transactions take the write lock when they start, so nobody changes it in between.
+func sqliteJobSnapshot(ctx context.Context, tx *sql.Tx, jobId uuid.UUID) (*job.Snapshot, error) {
+	s := &job.Snapshot{}
+	r := snapshotRow{}
+	var successStatuses intArray
+	var locations textArray
+	err := tx.QueryRowContext(ctx, `SELECT `+snapshotColumns+` FROM ruok.jobs WHERE id = $1;`, jobId).Scan(
+		&s.Name,
+		&s.CronExpString,
+		&s.Timezone,
+		&r.maxRetries,
+		&s.Endpoint,
+		&s.HttpMethod,
+		&successStatuses,
+		&r.alertStrategy,
+		&r.alertMethod,
+		&r.alertEndpoint,
+		&r.alertPayload,
+		&r.alertHeaders,
+		&r.labels,
+		&locations,
+		&r.alertQuorum,
+		&r.resultsRetentionDays,
+		&r.status,
+		&r.deletedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.SuccessStatuses = successStatuses
+	s.Locations = locations
+	return r.fill(s), nil
+}
+
+// Adds a revision to the history of the job with what it looks like now
+func sqliteRecordRevision(ctx context.Context, tx *sql.Tx, jobId uuid.UUID, action string, by string, before *job.Snapshot) error {
+	after, err := sqliteJobSnapshot(ctx, tx, jobId)
+	if err != nil {
+		return err
+	}
+	id, err := uuid.NewV7()
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
+INSERT INTO ruok.job_audit (id, job_id, revision, action, actor, created_at, before, after)
+SELECT $1, $2, coalesce(max(revision), 0) + 1, $3, $4, $5, $6, $7
+FROM ruok.job_audit
+WHERE job_id = $2;
+`,
+		id,
+		jobId,
+		action,
+		nullString(by),
+		sqliteNow(),
+		nullBytes(snapshotJSON(before)),
+		string(snapshotJSON(after)),
+	)
+	return err
+}
+
+func nullBytes(b []byte) sql.NullString {
+	return sql.NullString{String: string(b), Valid: b != nil}
+}
+
+// Changes made to a job, the newest first
+func (s *SQLiteStorage) GetJobHistory(jobId uuid.UUID, limit int, offset int) []*job.Revision {
+	if err := s.checkTenant(context.Background(), s.Db, jobId); errors.Is(err, ErrNotFound) {
+		return []*job.Revision{}
+	} else if err != nil {
+		return nil
+	}
+	rows, err := s.Db.QueryContext(context.Background(), jobHistoryQuery, jobId, limit, offset)
+	if err != nil {
+		log.Error().Err(err).Msgf("could not query history of job %v", jobId)
+		return nil
+	}
+	defer rows.Close()
+
+	revisions := []*job.Revision{}
+	for rows.Next() {
+		r := &job.Revision{}
+		var id string
+		var actor, before sql.NullString
+		var after string
+		if err := rows.Scan(&id, &r.Revision, &r.Action, &actor, &r.CreatedAt, &before, &after); err != nil {
+			log.Error().Err(err).Msg("could not scan job history row")
+			continue
+		}
+		r.JobId = uuid.FromStringOrNil(id)
+		r.Actor = actor.String
+		if before.Valid {
+			r.Before = parseSnapshot([]byte(before.String))
+		}
+		r.After = parseSnapshot([]byte(after))
+		r.Changes = job.Diff(r.Before, r.After)
+		revisions = append(revisions, r)
+	}
+	return revisions
+}
+
+// What the job looked like after "revision"
+func (s *SQLiteStorage) GetJobRevision(jobId uuid.UUID, revision int) (*job.Snapshot, error) {
+	ctx := context.Background()
+	if err := s.checkTenant(ctx, s.Db, jobId); err != nil {
+		return nil, err
+	}
+	var raw string
+	err := s.Db.QueryRowContext(ctx, jobRevisionQuery, jobId, revision).Scan(&raw)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		log.Error().Err(err).Msgf("could not get revision %d of job %v", revision, jobId)
+		return nil, errors.New("could not get the revision of the job")
+	}
+	target := parseSnapshot([]byte(raw))
+	if target == nil {
+		return nil, ErrNotFound
+	}
+	return target, nil
+}
+
+// Makes the job look like it did after "revision", see SQLStorage.RestoreJob
+func (s *SQLiteStorage) RestoreJob(jobId uuid.UUID, revision int, by string) error {
+	ctx := context.Background()
+	tx, err := s.Db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Error().Err(err).Msg("could not start transaction to restore job")
+		return errors.New("could not restore job")
+	}
+	defer tx.Rollback()
+
+	if err := s.checkTenant(ctx, tx, jobId); err != nil {
+		return err
+	}
+
+	before, err := sqliteJobSnapshot(ctx, tx, jobId)
+	if errors.Is(err, ErrNotFound) {
+		return ErrNotFound
+	}
+	if err != nil {
+		log.Error().Err(err).Msgf("could not get job %v to restore it", jobId)
+		return errors.New("could not restore job")
+	}
+
+	var raw string
+	err = tx.QueryRowContext(ctx, jobRevisionQuery, jobId, revision).Scan(&raw)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		log.Error().Err(err).Msgf("could not get revision %d of job %v", revision, jobId)
+		return errors.New("could not restore job")
+	}
+	target := parseSnapshot([]byte(raw))
+	if target == nil {
+		return ErrNotFound
+	}
+	if target.Deleted {
+		return ErrDeletedRevision
+	}
+	if before.Deleted {
+		if err := s.checkMaxJobs(ctx, tx); err != nil {
+			if errors.Is(err, ErrQuotaExceeded) {
+				return err
+			}
+			log.Error().Err(err).Msg("could not check the quota of the tenant")
+			return errors.New("could not restore job")
+		}
+	}
+
+	var owner sql.NullString
+	if err := tx.QueryRowContext(ctx, "SELECT claimed_by FROM ruok.jobs WHERE id = $1", jobId).Scan(&owner); err != nil {
+		log.Error().Err(err).Msgf("could not get owner of job %v", jobId)
+		return errors.New("could not restore job")
+	}
+
+	status := "pending to be claimed"
+	if target.Paused {
+		status = "paused"
+	}
+	j := restoreInput(jobId, target, by)
+	_, err = tx.ExecContext(ctx, `
+UPDATE ruok.jobs SET
+	job_name = $1,
+	cron_exp_string = $2,
+	endpoint = $3,
+	httpmethod = $4,
+	max_retries = $5,
+	success_statuses = $6,
+	status = $7,
+	alert_strategy = $8,
+	alert_endpoint = $9,
+	alert_method = $10,
+	alert_headers_string = $11,
+	alert_payload = $12,
+	timezone = $13,
+	results_retention_days = $14,
+	labels = $15,
+	locations = $16,
+	alert_quorum = $17,
+	updated_by = $18,
+	updated_at = $19,
+	claimed_by = NULL,
+	deleted_at = NULL
+WHERE id = $20;
+`,
+		j.Name,
+		j.CronExpString,
+		j.Endpoint,
+		j.HttpMethod,
+		j.MaxRetries,
+		intArray(j.SuccessStatuses),
+		status,
+		nullString(j.AlertStrategy),
+		nullString(j.AlertEndpoint),
+		nullString(j.AlertMethod),
+		nullJSON(j.AlertHeaders),
+		nullString(j.AlertPayload),
+		TimezoneOrDefault(j.Timezone),
+		j.ResultsRetentionDays,
+		labelsJSON(j.Labels),
+		textArray(locationsOrEmpty(j.Locations)),
+		nullQuorum(j.AlertQuorum),
+		nullString(by),
+		sqliteNow(),
+		jobId,
+	)
+	if err != nil {
+		log.Error().Err(err).Msgf("could not restore job %v", jobId)
+		return errors.New("could not restore job")
+	}
+
+	if err := sqliteRecordRevision(ctx, tx, jobId, job.ActionRestore, by, before); err != nil {
+		log.Error().Err(err).Msgf("could not record restore of job %v", jobId)
+		return errors.New("could not restore job")
+	}
+
+	owners, err := sqliteReleaseLocations(ctx, tx, jobId)
+	if err != nil {
+		log.Error().Err(err).Msgf("could not release locations to restore job %v", jobId)
+		return errors.New("could not restore job")
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Error().Err(err).Msg("could not commit transaction to restore job")
+		return errors.New("could not commit transaction to restore job")
+	}
+
+	channel := config.AppName()
+	if owner.Valid {
+		channel = owner.String
+	}
+	if err := s.notifyAll(append([]string{channel}, owners...), NewNotification(EventUpdated, jobId)); err != nil {
+		log.Error().Err(err).Msgf("could not notify restored job %v", jobId)
+		return errors.New("could not notify restored job")
+	}
+	return nil
+}
@@ -0,0 +1,933 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/rs/zerolog/log"
+
+	"github.com/back-end-labs/ruok/pkg/config"
+	"github.com/back-end-labs/ruok/pkg/job"
+	"github.com/back-end-labs/ruok/pkg/labels"
+)
+
+// Same unit as ruok.micro_unix_now(), used for created_at, updated_at and deleted_at
+func sqliteNow() int64 {
+	return time.Now().UnixMilli()
+}
+
+func nullJSON(m map[string]string) sql.NullString {
+	if len(m) == 0 {
+		return sql.NullString{}
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		log.Error().Err(err).Msgf("could not convert headers to json string")
+		return sql.NullString{}
+	}
+	return sql.NullString{String: string(b), Valid: true}
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// Gets pending to be claimed jobs matching the selector and claims them.
+// Jobs with locations are claimed per location, see SQLStorage.GetAvailableJobs.
+func (s *SQLiteStorage) GetAvailableJobs(limit int, selector labels.Selector) []*job.Job {
+	ctx := context.Background()
+	tx, err := s.Db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Error().Err(err).Msg("could not start transaction to get available jobs")
+		return nil
+	}
+	defer tx.Rollback()
+
+	matches, args := sqliteSelector(selector, 3)
+	rows, err := tx.QueryContext(ctx, `
+SELECT
+	id,
+	job_name,
+	cron_exp_string,
+	endpoint,
+	httpmethod,
+	max_retries,
+	last_execution,
+	should_execute_at,
+	last_response_at,
+	last_message,
+	last_status_code,
+	headers_string,
+	success_statuses,
+	tls_client_cert,
+	created_at,
+	alert_strategy,
+	alert_endpoint,
+	alert_method,
+	alert_headers_string,
+	alert_payload,
+	timezone,
+	updated_at,
+	labels,
+	locations,
+	alert_quorum,
+	CASE WHEN locations = '{}' THEN '' ELSE $2 END AS location
+ FROM ruok.jobs
+ WHERE status = 'pending to be claimed' AND `+matches+`
+ AND (
+	locations = '{}'
+	OR ($2 <> '' AND `+sqliteHasLocation+` AND NOT EXISTS (
+		SELECT 1 FROM ruok.job_locations l
+		WHERE l.job_id = jobs.id AND l.location = $2 AND l.claimed_by IS NOT NULL
+	))
+ )
+ LIMIT $1;`, append([]any{limit, config.Location()}, args...)...)
+	if err != nil {
+		log.Error().Err(err).Msg("could not query rows to get available jobs")
+		return nil
+	}
+
+	jobsList := []*job.Job{}
+	for rows.Next() {
+		var Id uuid.UUID
+		var LastExecution, ShouldExecuteAt, LastResponseAt, UpdatedAt sql.NullInt64
+		var LastMessage, HeadersString, TLSClientCert sql.NullString
+		var AlertStrategy, AlertEndpoint, AlertMethod, AlertHeadersString, AlertPayload sql.NullString
+		var LastStatusCode sql.NullInt32
+		var SuccessStatuses intArray
+		var Labels string
+		var Locations textArray
+		var AlertQuorum sql.NullInt32
+		j := &job.Job{
+			ClaimedBy: config.AppName(),
+			Status:    "claimed",
+			Handlers:  job.Handlers{},
+		}
+		err = rows.Scan(
+			&Id,
+			&j.Name,
+			&j.CronExpString,
+			&j.Endpoint,
+			&j.HttpMethod,
+			&j.MaxRetries,
+			&LastExecution,
+			&ShouldExecuteAt,
+			&LastResponseAt,
+			&LastMessage,
+			&LastStatusCode,
+			&HeadersString,
+			&SuccessStatuses,
+			&TLSClientCert,
+			&j.CreatedAt,
+			&AlertStrategy,
+			&AlertEndpoint,
+			&AlertMethod,
+			&AlertHeadersString,
+			&AlertPayload,
+			&j.Timezone,
+			&UpdatedAt,
+			&Labels,
+			&Locations,
+			&AlertQuorum,
+			&j.Location,
+		)
+		if err != nil {
+			log.Error().Err(err).Msg("could not scan available jobs row")
+			continue
+		}
+
+		j.Headers = map[string]string{}
+		if HeadersString.Valid && HeadersString.String != "" {
+			if err := json.Unmarshal([]byte(HeadersString.String), &j.Headers); err != nil {
+				log.Error().Err(err).Msg("could not unmarshal headers of available job")
+				jobsList = append(jobsList, &job.Job{Status: "bad headers", Id: Id})
+				continue
+			}
+		}
+		j.AlertHeaders = map[string]string{}
+		if AlertHeadersString.Valid && AlertHeadersString.String != "" {
+			if err := json.Unmarshal([]byte(AlertHeadersString.String), &j.AlertHeaders); err != nil {
+				log.Error().Err(err).Msg("could not unmarshal alerting headers of available job")
+				jobsList = append(jobsList, &job.Job{Status: "bad alerting headers", Id: Id})
+				continue
+			}
+		}
+
+		j.Id = Id
+		j.LastExecution = time.UnixMicro(LastExecution.Int64)
+		j.ShouldExecuteAt = time.UnixMicro(ShouldExecuteAt.Int64)
+		j.LastResponseAt = time.UnixMicro(LastResponseAt.Int64)
+		j.LastMessage = LastMessage.String
+		j.LastStatusCode = int(LastStatusCode.Int32)
+		j.SuccessStatuses = SuccessStatuses
+		j.TLSClientCert = TLSClientCert.String
+		j.UpdatedAt = UpdatedAt.Int64
+		j.AlertStrategy = AlertStrategy.String
+		j.AlertEndpoint = AlertEndpoint.String
+		j.AlertMethod = AlertMethod.String
+		j.AlertPayload = AlertPayload.String
+		j.Labels = parseLabels(Labels)
+		j.Locations = Locations
+		j.AlertQuorum = int(AlertQuorum.Int32)
+		jobsList = append(jobsList, j)
+	}
+	rows.Close()
+	if rows.Err() != nil {
+		log.Error().Err(rows.Err()).Msg("could not read available jobs rows")
+		return nil
+	}
+
+	for _, j := range jobsList {
+		if j.Status == "claimed" && j.Location != "" {
+			_, err = tx.ExecContext(ctx, `
+INSERT INTO ruok.job_locations (job_id, location, claimed_by) VALUES ($1, $2, $3)
+ON CONFLICT (job_id, location) DO UPDATE SET claimed_by = excluded.claimed_by;
+`, j.Id, j.Location, j.ClaimedBy)
+		} else if j.Status == "claimed" {
+			_, err = tx.ExecContext(ctx, "UPDATE ruok.jobs SET claimed_by = $1, status = 'claimed' WHERE id = $2", j.ClaimedBy, j.Id)
+		} else {
+			_, err = tx.ExecContext(ctx, "UPDATE ruok.jobs SET claimed_by = NULL, status = $1 WHERE id = $2", j.Status, j.Id)
+		}
+		if err != nil {
+			log.Error().Err(err).Msg("could not update status/claimed_by of available job row")
+			return nil
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Error().Err(err).Msg("could not commit 'get available jobs' transaction")
+		return nil
+	}
+	return jobsList
+}
+
+// Gets jobs claimed by this instance that match the selector, along with the ones we claimed a location of
+func (s *SQLiteStorage) GetClaimedJobs(limit int, offset int, selector labels.Selector) []*job.Job {
+	matches, args := sqliteSelector(selector, 5)
+	rows, err := s.Db.QueryContext(context.Background(), `
+SELECT
+	id,
+	job_name,
+	cron_exp_string,
+	endpoint,
+	httpmethod,
+	max_retries,
+	last_execution,
+	should_execute_at,
+	last_response_at,
+	last_message,
+	last_status_code,
+	headers_string,
+	success_statuses,
+	created_at,
+	succeeded,
+	timezone,
+	labels,
+	locations,
+	alert_quorum,
+	updated_by,
+	coalesce((
+		SELECT l.location FROM ruok.job_locations l
+		WHERE l.job_id = jobs.id AND l.claimed_by = $1
+		ORDER BY l.location LIMIT 1
+	), '') AS location
+ FROM ruok.jobs
+ WHERE (
+	claimed_by = $1
+	OR EXISTS (SELECT 1 FROM ruok.job_locations l WHERE l.job_id = jobs.id AND l.claimed_by = $1)
+ ) AND ($4 = '' OR tenant_id = $4) AND `+matches+`
+ ORDER BY id ASC
+ LIMIT $2
+ OFFSET $3;
+ `, append([]any{config.AppName(), limit, offset, s.tenant}, args...)...)
+	if err != nil {
+		log.Error().Err(err).Msg("could not query for claimed jobs")
+		return nil
+	}
+	defer rows.Close()
+
+	jobsList := []*job.Job{}
+	for rows.Next() {
+		var LastExecution, ShouldExecuteAt, LastResponseAt sql.NullInt64
+		var LastMessage, HeadersString, Succeeded sql.NullString
+		var Labels string
+		var Locations textArray
+		var AlertQuorum sql.NullInt32
+		var UpdatedBy sql.NullString
+		var LastStatusCode sql.NullInt32
+		var SuccessStatuses intArray
+		j := &job.Job{
+			ClaimedBy: config.AppName(),
+			Handlers:  job.Handlers{},
+			Headers:   map[string]string{},
+		}
+		err = rows.Scan(
+			&j.Id,
+			&j.Name,
+			&j.CronExpString,
+			&j.Endpoint,
+			&j.HttpMethod,
+			&j.MaxRetries,
+			&LastExecution,
+			&ShouldExecuteAt,
+			&LastResponseAt,
+			&LastMessage,
+			&LastStatusCode,
+			&HeadersString,
+			&SuccessStatuses,
+			&j.CreatedAt,
+			&Succeeded,
+			&j.Timezone,
+			&Labels,
+			&Locations,
+			&AlertQuorum,
+			&UpdatedBy,
+			&j.Location,
+		)
+		if err != nil {
+			log.Error().Err(err).Msg("could not scan claimed jobs row")
+			continue
+		}
+		if HeadersString.Valid && HeadersString.String != "" {
+			if err := json.Unmarshal([]byte(HeadersString.String), &j.Headers); err != nil {
+				log.Error().Err(err).Msg("could not unmarshal headers of claimed job")
+			}
+		}
+		j.LastExecution = time.UnixMicro(LastExecution.Int64)
+		j.ShouldExecuteAt = time.UnixMicro(ShouldExecuteAt.Int64)
+		j.LastResponseAt = time.UnixMicro(LastResponseAt.Int64)
+		j.LastMessage = LastMessage.String
+		j.LastStatusCode = int(LastStatusCode.Int32)
+		j.SuccessStatuses = SuccessStatuses
+		j.Succeeded = Succeeded.String
+		j.Labels = parseLabels(Labels)
+		j.Locations = Locations
+		j.AlertQuorum = int(AlertQuorum.Int32)
+		j.UpdatedBy = UpdatedBy.String
+		jobsList = append(jobsList, j)
+	}
+	return jobsList
+}
+
+func (s *SQLiteStorage) CreateJob(j CreateJobInput) (uuid.UUID, error) {
+	id, err := uuid.NewV7()
+	if err != nil {
+		log.Error().Err(err).Msg("could not create uuidv7 for new job")
+		return uuid.Nil, err
+	}
+
+	// alerts are only kept when they have the minimum fields, like the postgres storage does
+	var alertStrategy, alertEndpoint, alertMethod, alertHeadersString, alertPayload sql.NullString
+	if HasMinAlertFields(j.AlertStrategy, j.AlertEndpoint, j.AlertMethod) {
+		alertStrategy = nullString(j.AlertStrategy)
+		alertEndpoint = nullString(j.AlertEndpoint)
+		alertMethod = nullString(j.AlertMethod)
+		alertHeadersString = nullJSON(j.AlertHeaders)
+		alertPayload = nullString(j.AlertPayload)
+	}
+
+	ctx := context.Background()
+	tx, err := s.Db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Error().Err(err).Msg("could not start transaction to create job")
+		return uuid.Nil, errors.New("could not insert into jobs")
+	}
+	defer tx.Rollback()
+
+	if err := s.checkMaxJobs(ctx, tx); err != nil {
+		if errors.Is(err, ErrQuotaExceeded) {
+			return uuid.Nil, err
+		}
+		log.Error().Err(err).Msg("could not check the quota of the tenant")
+		return uuid.Nil, errors.New("could not insert into jobs")
+	}
+
+	_, err = tx.ExecContext(ctx, `
+INSERT INTO ruok.jobs (
+	id,
+	job_name,
+	cron_exp_string,
+	endpoint,
+	httpmethod,
+	max_retries,
+	success_statuses,
+	status,
+	alert_strategy,
+	alert_endpoint,
+	alert_method,
+	alert_headers_string,
+	alert_payload,
+	timezone,
+	results_retention_days,
+	labels,
+	locations,
+	alert_quorum,
+	updated_by,
+	tenant_id
+) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20);
+`,
+		id,
+		j.Name,
+		j.CronExpString,
+		j.Endpoint,
+		j.HttpMethod,
+		j.MaxRetries,
+		intArray(j.SuccessStatuses),
+		"pending to be claimed",
+		alertStrategy,
+		alertEndpoint,
+		alertMethod,
+		alertHeadersString,
+		alertPayload,
+		TimezoneOrDefault(j.Timezone),
+		j.ResultsRetentionDays,
+		labelsJSON(j.Labels),
+		textArray(locationsOrEmpty(j.Locations)),
+		nullQuorum(j.AlertQuorum),
+		nullString(j.UpdatedBy),
+		tenantOrDefault(s.tenant),
+	)
+	if err != nil {
+		log.Error().Err(err).Msg("could not insert into jobs")
+		return uuid.Nil, errors.New("could not insert into job")
+	}
+
+	if err := sqliteRecordRevision(ctx, tx, id, job.ActionCreate, j.UpdatedBy, nil); err != nil {
+		log.Error().Err(err).Msg("could not record created job")
+		return uuid.Nil, errors.New("could not insert into job")
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Error().Err(err).Msg("could not commit transaction to insert into job")
+		return uuid.Nil, errors.New("could not commit transaction into job")
+	}
+	return id, nil
+}
+
+func (s *SQLiteStorage) UpdateJob(j UpdateJobInput) error {
+	ctx := context.Background()
+	tx, err := s.Db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Error().Err(err).Msg("could not start transaction to update job")
+		return errors.New("could not update job")
+	}
+	defer tx.Rollback()
+
+	if err := s.checkTenant(ctx, tx, j.Id); err != nil {
+		return err
+	}
+
+	before, err := sqliteJobSnapshot(ctx, tx, j.Id)
+	if errors.Is(err, ErrNotFound) || (err == nil && before.Deleted) {
+		return ErrNotFound
+	}
+	if err != nil {
+		log.Error().Err(err).Msgf("could not get job %v to update it", j.Id)
+		return errors.New("could not update job")
+	}
+
+	_, err = tx.ExecContext(ctx, `
+UPDATE ruok.jobs SET
+	job_name = $1,
+	cron_exp_string = $2,
+	endpoint = $3,
+	httpmethod = $4,
+	max_retries = $5,
+	success_statuses = $6,
+	status = CASE WHEN status = 'paused' THEN status ELSE $7 END,
+	alert_strategy = $8,
+	alert_endpoint = $9,
+	alert_method = $10,
+	alert_headers_string = $11,
+	alert_payload = $12,
+	timezone = $13,
+	results_retention_days = $14,
+	labels = $15,
+	locations = $16,
+	alert_quorum = $17,
+	updated_at = $18,
+	updated_by = $19
+WHERE id = $20 AND deleted_at IS NULL;
+`,
+		j.Name,
+		j.CronExpString,
+		j.Endpoint,
+		j.HttpMethod,
+		j.MaxRetries,
+		intArray(j.SuccessStatuses),
+		"pending to be claimed",
+		j.AlertStrategy,
+		j.AlertEndpoint,
+		j.AlertMethod,
+		nullJSON(j.AlertHeaders),
+		nullString(j.AlertPayload),
+		TimezoneOrDefault(j.Timezone),
+		j.ResultsRetentionDays,
+		labelsJSON(j.Labels),
+		textArray(locationsOrEmpty(j.Locations)),
+		nullQuorum(j.AlertQuorum),
+		sqliteNow(),
+		nullString(j.UpdatedBy),
+		j.Id,
+	)
+	if err != nil {
+		log.Error().Err(err).Msg("could not update job")
+		return errors.New("could not update job")
+	}
+
+	if err := sqliteRecordRevision(ctx, tx, j.Id, job.ActionUpdate, j.UpdatedBy, before); err != nil {
+		log.Error().Err(err).Msgf("could not record update of job %v", j.Id)
+		return errors.New("could not update job")
+	}
+
+	// schedulers running a location of the job have to refresh it too
+	owners, err := sqliteLocationOwners(ctx, tx, j.Id)
+	if err != nil {
+		log.Error().Err(err).Msg("could not get locations of updated job")
+		return errors.New("could not update job")
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Error().Err(err).Msg("could not commit transaction to update job")
+		return errors.New("could not commit transaction update job")
+	}
+
+	if err := s.notifyAll(append([]string{config.AppName()}, owners...), NewNotification(EventUpdated, j.Id)); err != nil {
+		log.Error().Err(err).Msg("could not notify updated job")
+		return errors.New("could not notify updated job")
+	}
+	return nil
+}
+
+// Stops scheduling a job until it is resumed, "by" is who asked for it
+func (s *SQLiteStorage) PauseJob(jobId uuid.UUID, by string) error {
+	return s.changeJobState(jobId, by, "deleted_at IS NULL AND status <> 'completed'", "status = 'paused'", job.ActionPause, EventPaused)
+}
+
+// Makes a paused job available to be claimed again
+func (s *SQLiteStorage) ResumeJob(jobId uuid.UUID, by string) error {
+	return s.changeJobState(jobId, by, "deleted_at IS NULL AND status = 'paused'", "status = 'pending to be claimed'", job.ActionResume, EventUpdated)
+}
+
+// Soft deletes a job
+func (s *SQLiteStorage) DeleteJob(jobId uuid.UUID, by string) error {
+	return s.changeJobState(jobId, by, "deleted_at IS NULL", "status = 'deleted', deleted_at = $1", job.ActionDelete, EventDeleted)
+}
+
+// Releases the job while changing its state and notifies the scheduler that owned it.
+// Unclaimed jobs are notified to our own channel, so resumed jobs are claimed right away.
+// Jobs with locations are released from every location and their schedulers notified too.
+// "set" may use $1, which is the current time. "action" is recorded in the history of the job.
+func (s *SQLiteStorage) changeJobState(jobId uuid.UUID, by string, where string, set string, action string, event string) error {
+	ctx := context.Background()
+	tx, err := s.Db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Error().Err(err).Msgf("could not start transaction to %s job", action)
+		return errors.New("could not " + action + " job")
+	}
+	defer tx.Rollback()
+
+	if err := s.checkTenant(ctx, tx, jobId); err != nil {
+		return err
+	}
+
+	var owner sql.NullString
+	err = tx.QueryRowContext(ctx, "SELECT claimed_by FROM ruok.jobs WHERE id = $1 AND "+where, jobId).Scan(&owner)
+	if errors.Is(err, sql.ErrNoRows) {
+		// tell jobs that don't exist apart from the ones that can't take the change
+		if current, err := sqliteJobSnapshot(ctx, tx, jobId); err == nil && !current.Deleted {
+			return ErrWrongState
+		}
+		return ErrNotFound
+	}
+	if err != nil {
+		log.Error().Err(err).Msgf("could not %s job %v", action, jobId)
+		return errors.New("could not " + action + " job")
+	}
+
+	before, err := sqliteJobSnapshot(ctx, tx, jobId)
+	if err != nil {
+		log.Error().Err(err).Msgf("could not get job %v to %s it", jobId, action)
+		return errors.New("could not " + action + " job")
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"UPDATE ruok.jobs SET "+set+", claimed_by = NULL, updated_at = $1, updated_by = $3 WHERE id = $2",
+		sqliteNow(),
+		jobId,
+		nullString(by),
+	)
+	if err != nil {
+		log.Error().Err(err).Msgf("could not %s job %v", action, jobId)
+		return errors.New("could not " + action + " job")
+	}
+
+	if err := sqliteRecordRevision(ctx, tx, jobId, action, by, before); err != nil {
+		log.Error().Err(err).Msgf("could not record %s of job %v", action, jobId)
+		return errors.New("could not " + action + " job")
+	}
+
+	owners, err := sqliteReleaseLocations(ctx, tx, jobId)
+	if err != nil {
+		log.Error().Err(err).Msgf("could not release locations to %s job %v", action, jobId)
+		return errors.New("could not " + action + " job")
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Error().Err(err).Msgf("could not commit transaction to %s job", action)
+		return errors.New("could not commit transaction to " + action + " job")
+	}
+
+	channel := config.AppName()
+	if owner.Valid {
+		channel = owner.String
+	}
+	if err := s.notifyAll(append([]string{channel}, owners...), NewNotification(event, jobId)); err != nil {
+		log.Error().Err(err).Msgf("could not notify %s of job %v", action, jobId)
+		return errors.New("could not notify " + action + " of job")
+	}
+	return nil
+}
+
+// Marks a job that won't run again (like one-shot jobs) as completed, so nobody claims it again
+func (s *SQLiteStorage) CompleteJob(jobId uuid.UUID) error {
+	_, err := s.Db.ExecContext(context.Background(), "UPDATE ruok.jobs SET status = 'completed' WHERE id = $1", jobId)
+	if err != nil {
+		log.Error().Err(err).Msgf("could not mark job %v as completed", jobId)
+		return errors.New("could not update jobs")
+	}
+	return nil
+}
+
+// Makes the jobs available to be claimed again
+func (s *SQLiteStorage) ReleaseAll(j []*job.Job) error {
+	ctx := context.Background()
+	tx, err := s.Db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Error().Err(err).Msg("could not start transaction to release all jobs")
+		return errors.New("could not update jobs")
+	}
+	defer tx.Rollback()
+
+	for i := 0; i < len(j); i++ {
+		var err error
+		if j[i].Location != "" {
+			// only our location, the jobs row of located jobs is never claimed
+			_, err = tx.ExecContext(ctx, "UPDATE ruok.job_locations SET claimed_by = NULL WHERE job_id = $1 AND location = $2", j[i].Id, j[i].Location)
+		} else {
+			_, err = tx.ExecContext(ctx, "UPDATE ruok.jobs SET claimed_by = NULL, status = $1 WHERE id = $2", "pending to be claimed", j[i].Id)
+		}
+		if err != nil {
+			log.Error().Err(err).Msgf("There was a problem while trying to exec release of job with id of %v", j[i].Id)
+			return errors.New("could not update jobs")
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Error().Err(err).Msg("There was a problem while trying to commit 'release all jobs' transaction")
+		return errors.New("could not commit transaction")
+	}
+	return nil
+}
+
+// Jobs are only visible to the scheduler owning them or while unclaimed,
+// like the row level security policies of postgres do
+func (s *SQLiteStorage) GetJobUpdates(jobId uuid.UUID) *JobUpdates {
+	var u JobUpdates
+	var headers, tlsClientCert, alertStrategy, alertEndpoint, alertMethod, status sql.NullString
+	var updatedAt, deletedAt sql.NullInt64
+	var successStatuses intArray
+	var jobLabels string
+	var locations textArray
+	var alertQuorum sql.NullInt32
+
+	err := s.Db.QueryRowContext(context.Background(), `
+SELECT
+	job_name,
+	cron_exp_string,
+	endpoint,
+	httpmethod,
+	max_retries,
+	headers_string,
+	success_statuses,
+	tls_client_cert,
+	alert_strategy,
+	alert_endpoint,
+	alert_method,
+	timezone,
+	status,
+	updated_at,
+	deleted_at,
+	labels,
+	locations,
+	alert_quorum
+FROM ruok.jobs
+WHERE id = $1 AND (claimed_by IS NULL OR claimed_by = $2)
+`, jobId, config.AppName()).Scan(
+		&u.Job_name,
+		&u.Cron_exp_string,
+		&u.Endpoint,
+		&u.Httpmethod,
+		&u.Max_retries,
+		&headers,
+		&successStatuses,
+		&tlsClientCert,
+		&alertStrategy,
+		&alertEndpoint,
+		&alertMethod,
+		&u.Timezone,
+		&status,
+		&updatedAt,
+		&deletedAt,
+		&jobLabels,
+		&locations,
+		&alertQuorum,
+	)
+	if err != nil {
+		log.Error().Err(err).Msgf("could not scan row to get updates for job %v", jobId)
+		return nil
+	}
+	u.Headers_string = headers.String
+	u.Success_statuses = successStatuses
+	u.Tls_client_cert = tlsClientCert.String
+	u.Alert_strategy = alertStrategy.String
+	u.Alert_endpoint = alertEndpoint.String
+	u.Alert_method = alertMethod.String
+	u.Status = status.String
+	u.Updated_at = updatedAt.Int64
+	u.Deleted_at = deletedAt.Int64
+	u.Labels = parseLabels(jobLabels)
+	u.Locations = locations
+	u.Alert_quorum = int(alertQuorum.Int32)
+	return &u
+}
+
+// Gets the last update time of the given jobs, jobs we can't see anymore are not in the result.
+// Returns nil on errors.
+func (s *SQLiteStorage) GetJobsUpdatedAt(jobIds []uuid.UUID) map[uuid.UUID]int64 {
+	updatedAt := map[uuid.UUID]int64{}
+	if len(jobIds) == 0 {
+		return updatedAt
+	}
+	args := []any{config.AppName()}
+	params := make([]string, len(jobIds))
+	for i, id := range jobIds {
+		args = append(args, id)
+		params[i] = "$" + strconv.Itoa(i+2)
+	}
+
+	rows, err := s.Db.QueryContext(context.Background(),
+		"SELECT id, updated_at FROM ruok.jobs WHERE (claimed_by IS NULL OR claimed_by = $1) AND id IN ("+strings.Join(params, ", ")+")",
+		args...,
+	)
+	if err != nil {
+		log.Error().Err(err).Msg("could not query for jobs updated_at")
+		return nil
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id uuid.UUID
+		var updated sql.NullInt64
+		if err := rows.Scan(&id, &updated); err != nil {
+			log.Error().Err(err).Msg("could not scan jobs updated_at row")
+			return nil
+		}
+		updatedAt[id] = updated.Int64
+	}
+	if rows.Err() != nil {
+		log.Error().Err(rows.Err()).Msg("could not read jobs updated_at rows")
+		return nil
+	}
+	return updatedAt
+}
+
+// Notifies the scheduler owning the job so it runs it outside its schedule.
+// Jobs with locations run from the first claimed location, sorted by name.
+// Returns the id the execution result will have.
+func (s *SQLiteStorage) RequestRun(jobId uuid.UUID) (uuid.UUID, error) {
+	runId, err := uuid.NewV7()
+	if err != nil {
+		log.Error().Err(err).Msg("could not create uuidv7 for manual run")
+		return uuid.Nil, err
+	}
+
+	if err := s.checkTenant(context.Background(), s.Db, jobId); err != nil {
+		return uuid.Nil, err
+	}
+
+	var owner sql.NullString
+	err = s.Db.QueryRowContext(context.Background(),
+		"SELECT claimed_by FROM ruok.jobs WHERE id = $1 AND deleted_at IS NULL",
+		jobId,
+	).Scan(&owner)
+	if errors.Is(err, sql.ErrNoRows) {
+		return uuid.Nil, ErrNotFound
+	}
+	if err != nil {
+		log.Error().Err(err).Msgf("could not get owner of job %v", jobId)
+		return uuid.Nil, errors.New("could not request run")
+	}
+	if !owner.Valid {
+		owners, err := sqliteLocationOwners(context.Background(), s.Db, jobId)
+		if err != nil {
+			log.Error().Err(err).Msgf("could not get locations of job %v", jobId)
+			return uuid.Nil, errors.New("could not request run")
+		}
+		if len(owners) > 0 {
+			owner = sql.NullString{String: owners[0], Valid: true}
+		}
+	}
+	if !owner.Valid {
+		return uuid.Nil, ErrNotClaimed
+	}
+
+	n := NewNotification(EventRun, jobId)
+	n.RunId = runId
+	if err := s.notify(owner.String, n); err != nil {
+		log.Error().Err(err).Msgf("could not notify run of job %v", jobId)
+		return uuid.Nil, errors.New("could not notify run")
+	}
+	return runId, nil
+}
+
+// Gets the result of a manual run. Returns nil if it isn't there yet.
+func (s *SQLiteStorage) GetRunResult(runId uuid.UUID) *job.ExecutionResult {
+	var status sql.NullInt32
+	var message sql.NullString
+	var responseAt sql.NullInt64
+
+	err := s.Db.QueryRowContext(context.Background(),
+		`SELECT last_status_code, last_message, last_response_at FROM ruok.job_results
+		WHERE id = $1 AND trigger = $2 AND ($3 = '' OR job_id IN (SELECT id FROM ruok.jobs WHERE tenant_id = $3))`,
+		runId, job.TriggerManual, s.tenant,
+	).Scan(&status, &message, &responseAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		log.Error().Err(err).Msgf("could not get result of run %v", runId)
+		return nil
+	}
+	return &job.ExecutionResult{
+		Status:       int(status.Int32),
+		Message:      message.String,
+		ResponseTime: time.UnixMicro(responseAt.Int64),
+	}
+}
+
+// Gets the ids of the jobs we can see that match the selector, deleted jobs are left out.
+// Returns nil on errors.
+func (s *SQLiteStorage) GetJobIds(selector labels.Selector) []uuid.UUID {
+	matches, args := sqliteSelector(selector, 3)
+	rows, err := s.Db.QueryContext(context.Background(),
+		"SELECT id FROM ruok.jobs WHERE deleted_at IS NULL AND (claimed_by IS NULL OR claimed_by = $1) AND ($2 = '' OR tenant_id = $2) AND "+matches+" ORDER BY id ASC",
+		append([]any{config.AppName(), s.tenant}, args...)...,
+	)
+	if err != nil {
+		log.Error().Err(err).Msg("could not query for job ids")
+		return nil
+	}
+	defer rows.Close()
+
+	ids := []uuid.UUID{}
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			log.Error().Err(err).Msg("could not scan job id row")
+			return nil
+		}
+		ids = append(ids, id)
+	}
+	if rows.Err() != nil {
+		log.Error().Err(rows.Err()).Msg("could not read job id rows")
+		return nil
+	}
+	return ids
+}
+
+// Changes where the alerts of a job are sent and lets its owner know
+func (s *SQLiteStorage) UpdateJobAlerts(jobId uuid.UUID, a JobAlertsInput) error {
+	ctx := context.Background()
+	tx, err := s.Db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Error().Err(err).Msg("could not start transaction to update job alerts")
+		return errors.New("could not update job alerts")
+	}
+	defer tx.Rollback()
+
+	if err := s.checkTenant(ctx, tx, jobId); err != nil {
+		return err
+	}
+
+	before, err := sqliteJobSnapshot(ctx, tx, jobId)
+	if errors.Is(err, ErrNotFound) {
+		return ErrNotFound
+	}
+	if err != nil {
+		log.Error().Err(err).Msgf("could not get job %v to update its alerts", jobId)
+		return errors.New("could not update job alerts")
+	}
+
+	var owner sql.NullString
+	err = tx.QueryRowContext(ctx, `
+UPDATE ruok.jobs SET
+	alert_strategy = $1,
+	alert_endpoint = $2,
+	alert_method = $3,
+	alert_headers_string = $4,
+	alert_payload = $5,
+	updated_at = $6,
+	updated_by = $9
+WHERE id = $7 AND deleted_at IS NULL AND (claimed_by IS NULL OR claimed_by = $8)
+RETURNING claimed_by;
+`,
+		nullString(a.AlertStrategy),
+		nullString(a.AlertEndpoint),
+		nullString(a.AlertMethod),
+		nullJSON(a.AlertHeaders),
+		nullString(a.AlertPayload),
+		sqliteNow(),
+		jobId,
+		config.AppName(),
+		nullString(a.UpdatedBy),
+	).Scan(&owner)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		log.Error().Err(err).Msgf("could not update alerts of job %v", jobId)
+		return errors.New("could not update job alerts")
+	}
+
+	if err := sqliteRecordRevision(ctx, tx, jobId, job.ActionAlerts, a.UpdatedBy, before); err != nil {
+		log.Error().Err(err).Msgf("could not record alerts update of job %v", jobId)
+		return errors.New("could not update job alerts")
+	}
+
+	channel := config.AppName()
+	if owner.Valid {
+		channel = owner.String
+	}
+	owners, err := sqliteLocationOwners(ctx, tx, jobId)
+	if err != nil {
+		log.Error().Err(err).Msgf("could not get locations of job %v", jobId)
+		return errors.New("could not update job alerts")
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Error().Err(err).Msg("could not commit transaction to update job alerts")
+		return errors.New("could not commit transaction to update job alerts")
+	}
+	if err := s.notifyAll(append([]string{channel}, owners...), NewNotification(EventUpdated, jobId)); err != nil {
+		log.Error().Err(err).Msgf("could not notify updated alerts of job %v", jobId)
+		return errors.New("could not notify updated job alerts")
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/gofrs/uuid"
+	"github.com/rs/zerolog/log"
+
+	"github.com/back-end-labs/ruok/pkg/job"
+	"github.com/back-end-labs/ruok/pkg/labels"
+)
+
+type sqliteScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanSQLiteJob(row sqliteScanner) (*job.Job, error) {
+	var r jobRow
+	var successStatuses intArray
+	var locations textArray
+	j := &job.Job{}
+	if err := row.Scan(r.targets(j, &j.Id, &successStatuses, &locations)...); err != nil {
+		return nil, err
+	}
+	j.SuccessStatuses = successStatuses
+	j.Locations = locations
+	return r.fill(j), nil
+}
+
+// Gets the definition of a job, wherever it is claimed. Deleted jobs are not found.
+func (s *SQLiteStorage) GetJob(jobId uuid.UUID) (*job.Job, error) {
+	j, err := scanSQLiteJob(s.Db.QueryRowContext(context.Background(),
+		"SELECT "+jobColumns+" FROM ruok.jobs WHERE id = $1 AND deleted_at IS NULL AND ($2 = '' OR tenant_id = $2)",
+		jobId, s.tenant,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		log.Error().Err(err).Msgf("could not get job %v", jobId)
+		return nil, errors.New("could not get job")
+	}
+	return j, nil
+}
+
+// Lists the jobs of every scheduler that match the filters, a page at a time
+func (s *SQLiteStorage) ListJobs(in ListJobsInput) (*JobsPage, error) {
+	selector := func(selector labels.Selector, next int) (string, []any) {
+		matches, args := sqliteSelector(selector, next+1)
+		return fmt.Sprintf("($%[1]d = '' OR tenant_id = $%[1]d) AND %s", next, matches), append([]any{s.tenant}, args...)
+	}
+	where, args, err := listJobsQuery(in, 1, selector, "instr(lower(job_name), lower($%d)) > 0")
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.Db.QueryContext(context.Background(), "SELECT "+jobColumns+" FROM ruok.jobs"+where, args...)
+	if err != nil {
+		log.Error().Err(err).Msg("could not query for jobs")
+		return nil, errors.New("could not list jobs")
+	}
+	defer rows.Close()
+
+	jobs := []*job.Job{}
+	for rows.Next() {
+		j, err := scanSQLiteJob(rows)
+		if err != nil {
+			log.Error().Err(err).Msg("could not scan jobs row")
+			return nil, errors.New("could not list jobs")
+		}
+		jobs = append(jobs, j)
+	}
+	if rows.Err() != nil {
+		log.Error().Err(rows.Err()).Msg("could not read jobs rows")
+		return nil, errors.New("could not list jobs")
+	}
+	return newJobsPage(in, jobs), nil
+}
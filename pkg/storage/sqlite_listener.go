@@ -0,0 +1,35 @@
+package storage
+
+import (
+	"context"
+	"errors"
+
+	"github.com/back-end-labs/ruok/pkg/config"
+	"github.com/rs/zerolog/log"
+)
+
+var errSQLiteClosed = errors.New("sqlite storage is closed")
+
+// Publishes a notification to whoever listens on "channel", call it after committing the change
+func (s *SQLiteStorage) notify(channel string, n Notification) error {
+	payload, err := n.Payload()
+	if err != nil {
+		return err
+	}
+	s.bus.publish(channel, payload)
+	return nil
+}
+
+// Subscribes to our channel of the bus, see listenOnBus
+func (s *SQLiteStorage) ListenForChanges(notificationsCh chan Notification, ctx context.Context) {
+	listenOnBus(s.bus, s.listener, notificationsCh, ctx)
+}
+
+func (s *SQLiteStorage) StopListeningForChanges() error {
+	if s.closed.Load() {
+		log.Error().Err(errSQLiteClosed).Msg("could not stop listening")
+		return errSQLiteClosed
+	}
+	s.bus.unsubscribeAll(config.AppName())
+	return nil
+}
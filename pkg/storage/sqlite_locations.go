@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// TRUE when the locations of the job, kept as an array literal, include $2.
+// Names of locations can't have commas, so they are safe to look for between them.
+var sqliteHasLocation = `instr(',' || trim(locations, '{}') || ',', ',' || $2 || ',') > 0`
+
+type sqliteQuerier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// Schedulers that claimed a location of the job, sorted by location
+func sqliteLocationOwners(ctx context.Context, db sqliteQuerier, jobId uuid.UUID) ([]string, error) {
+	rows, err := db.QueryContext(ctx,
+		"SELECT claimed_by FROM ruok.job_locations WHERE job_id = $1 AND claimed_by IS NOT NULL ORDER BY location",
+		jobId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	owners := []string{}
+	for rows.Next() {
+		var owner string
+		if err := rows.Scan(&owner); err != nil {
+			return nil, err
+		}
+		owners = append(owners, owner)
+	}
+	return owners, rows.Err()
+}
+
+// Releases the locations of the job, returns the schedulers that had them
+func sqliteReleaseLocations(ctx context.Context, tx *sql.Tx, jobId uuid.UUID) ([]string, error) {
+	owners, err := sqliteLocationOwners(ctx, tx, jobId)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.ExecContext(ctx, "UPDATE ruok.job_locations SET claimed_by = NULL WHERE job_id = $1", jobId)
+	return owners, err
+}
+
+// Notifies every scheduler in "channels" once
+func (s *SQLiteStorage) notifyAll(channels []string, n Notification) error {
+	notified := map[string]bool{}
+	for _, channel := range channels {
+		if notified[channel] {
+			continue
+		}
+		notified[channel] = true
+		if err := s.notify(channel, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Keeps the result of a location and returns how many locations of the job failed their last execution since "since".
+// Transactions take the write lock right away, so results are counted one after the other.
+func (s *SQLiteStorage) RecordLocationResult(r LocationResult, since time.Time) (int, error) {
+	ctx := context.Background()
+	tx, err := s.Db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Error().Err(err).Msg("could not start transaction to record location result")
+		return 0, errors.New("could not record location result")
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+INSERT INTO ruok.job_locations (job_id, location, last_execution, failing)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (job_id, location) DO UPDATE SET
+	last_execution = excluded.last_execution,
+	failing = excluded.failing;
+`, r.JobId, r.Location, r.ExecutedAt.UnixMicro(), r.Failed)
+	if err != nil {
+		log.Error().Err(err).Msgf("could not record result of job %v from %q", r.JobId, r.Location)
+		return 0, errors.New("could not record location result")
+	}
+
+	// locations the job doesn't require anymore are left out
+	var failing int
+	err = tx.QueryRowContext(ctx, `
+SELECT count(*) FROM ruok.job_locations l
+JOIN ruok.jobs j ON j.id = l.job_id
+WHERE l.job_id = $1 AND l.failing AND l.last_execution >= $2
+AND instr(',' || trim(j.locations, '{}') || ',', ',' || l.location || ',') > 0;
+`, r.JobId, since.UnixMicro()).Scan(&failing)
+	if err != nil {
+		log.Error().Err(err).Msgf("could not count failing locations of job %v", r.JobId)
+		return 0, errors.New("could not count failing locations")
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Error().Err(err).Msg("could not commit transaction to record location result")
+		return 0, errors.New("could not commit transaction to record location result")
+	}
+	return failing, nil
+}
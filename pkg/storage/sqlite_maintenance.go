@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/rs/zerolog/log"
+
+	"github.com/back-end-labs/ruok/pkg/maintenance"
+)
+
+func (s *SQLiteStorage) CreateMaintenanceWindow(w CreateMaintenanceWindowInput) error {
+	id, err := uuid.NewV7()
+	if err != nil {
+		log.Error().Err(err).Msg("could not create uuidv7 for new maintenance window")
+		return err
+	}
+
+	var cronExpString sql.NullString
+	var durationSeconds sql.NullInt32
+	var startsAt sql.NullInt64
+	var endsAt sql.NullInt64
+
+	if w.CronExpString != "" {
+		cronExpString = sql.NullString{String: w.CronExpString, Valid: true}
+		durationSeconds = sql.NullInt32{Int32: int32(w.DurationSeconds), Valid: true}
+	} else {
+		startsAt = sql.NullInt64{Int64: w.StartsAt.UnixMicro(), Valid: true}
+		endsAt = sql.NullInt64{Int64: w.EndsAt.UnixMicro(), Valid: true}
+	}
+
+	_, err = s.Db.ExecContext(context.Background(), createMaintenanceWindowQuery,
+		id,
+		w.Name,
+		w.Mode,
+		cronExpString,
+		TimezoneOrDefault(w.Timezone),
+		durationSeconds,
+		startsAt,
+		endsAt,
+		uuidArray(w.JobIds),
+		w.Selector,
+	)
+	if err != nil {
+		log.Error().Err(err).Msg("could not insert into maintenance_windows")
+		return errors.New("could not insert into maintenance_windows")
+	}
+	return nil
+}
+
+// Lists the maintenance windows that are not deleted and could still be active
+func (s *SQLiteStorage) GetMaintenanceWindows() []*maintenance.Window {
+	rows, err := s.Db.QueryContext(context.Background(), `
+SELECT
+	id,
+	window_name,
+	mode,
+	cron_exp_string,
+	timezone,
+	duration_seconds,
+	starts_at,
+	ends_at,
+	job_ids,
+	selector,
+	created_at
+ FROM ruok.maintenance_windows
+ WHERE deleted_at IS NULL
+ AND (cron_exp_string IS NOT NULL OR ends_at > $1)
+ ORDER BY id ASC;
+ `, time.Now().UnixMicro())
+	if err != nil {
+		log.Error().Err(err).Msg("could not query for maintenance windows")
+		return nil
+	}
+	defer rows.Close()
+
+	windows := []*maintenance.Window{}
+	for rows.Next() {
+		var CronExpString sql.NullString
+		var DurationSeconds sql.NullInt32
+		var StartsAt sql.NullInt64
+		var EndsAt sql.NullInt64
+		var JobIds uuidArray
+		w := &maintenance.Window{}
+
+		err = rows.Scan(
+			&w.Id,
+			&w.Name,
+			&w.Mode,
+			&CronExpString,
+			&w.Timezone,
+			&DurationSeconds,
+			&StartsAt,
+			&EndsAt,
+			&JobIds,
+			&w.Selector,
+			&w.CreatedAt,
+		)
+		if err != nil {
+			log.Error().Err(err).Msg("could not scan maintenance windows row")
+			continue
+		}
+
+		w.CronExpString = CronExpString.String
+		w.DurationSeconds = int(DurationSeconds.Int32)
+		w.JobIds = append([]uuid.UUID{}, JobIds...)
+		if StartsAt.Valid {
+			w.StartsAt = time.UnixMicro(StartsAt.Int64)
+		}
+		if EndsAt.Valid {
+			w.EndsAt = time.UnixMicro(EndsAt.Int64)
+		}
+		windows = append(windows, w)
+	}
+	return windows
+}
+
+// Soft deletes a maintenance window
+func (s *SQLiteStorage) DeleteMaintenanceWindow(id uuid.UUID) error {
+	res, err := s.Db.ExecContext(
+		context.Background(),
+		"UPDATE ruok.maintenance_windows SET deleted_at = $1 WHERE id = $2 AND deleted_at IS NULL",
+		sqliteNow(),
+		id,
+	)
+	if err != nil {
+		log.Error().Err(err).Msgf("could not delete maintenance window %v", id)
+		return errors.New("could not delete maintenance window")
+	}
+	if affected, err := res.RowsAffected(); err == nil && affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
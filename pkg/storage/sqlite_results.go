@@ -0,0 +1,429 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/rs/zerolog/log"
+
+	"github.com/back-end-labs/ruok/pkg/config"
+	"github.com/back-end-labs/ruok/pkg/job"
+)
+
+// Writes an execution result in the db.
+// When results are buffered it only queues it, see BufferResults.
+func (s *SQLiteStorage) WriteDone(j *job.Job) error {
+	row, err := newResultRow(j)
+	if err != nil {
+		return err
+	}
+	if s.results != nil && s.results.add(row) {
+		return nil
+	}
+	return s.writeResults([]resultRow{row})
+}
+
+// Inserts the results and updates the last execution of their jobs in one transaction.
+// Results already written are ignored, so spooled ones can be retried safely.
+func (s *SQLiteStorage) writeResults(rows []resultRow) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	ctx := context.Background()
+	tx, err := s.Db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Error().Err(err).Msg("could not start transaction to write job execution results")
+		return errors.New("could not insert into jobs_results")
+	}
+	defer tx.Rollback()
+
+	insert, err := tx.PrepareContext(ctx, `
+	INSERT INTO ruok.job_results (
+		id,
+		job_name,
+		job_id,
+		cron_exp_string,
+		endpoint,
+		httpmethod,
+		max_retries,
+		execution_time,
+		should_execute_at,
+		last_response_at,
+		last_message,
+		last_status_code,
+		success_statuses,
+		status,
+		claimed_by,
+		succeeded,
+		trigger,
+		queue_wait,
+		location
+	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19)
+	ON CONFLICT (id) DO NOTHING;
+	`)
+	if err != nil {
+		log.Error().Err(err).Msg("could not prepare job results insert")
+		return errors.New("could not insert into job_results")
+	}
+	defer insert.Close()
+
+	latest := map[uuid.UUID]resultRow{}
+	for _, r := range rows {
+		_, err = insert.ExecContext(ctx,
+			r.Id, r.JobName, r.JobId, r.CronExpString, r.Endpoint, r.HttpMethod, r.MaxRetries, r.ExecutionTime,
+			r.ShouldExecuteAt, r.LastResponseAt, r.LastMessage, r.LastStatusCode,
+			intArray(r.SuccessStatuses), r.Status, r.ClaimedBy, r.Succeeded, r.Trigger, r.QueueWait, nullString(r.Location),
+		)
+		if err != nil {
+			// the file is local, so a failed insert is always the db refusing the row
+			log.Error().Err(err).Msgf("could not write %d job results", len(rows))
+			return fmt.Errorf("could not insert into job_results: %w", errResultsRefused)
+		}
+		if previous, ok := latest[r.JobId]; !ok || previous.ExecutionTime <= r.ExecutionTime {
+			latest[r.JobId] = r
+		}
+	}
+
+	// only the latest execution of each job matters for the jobs table
+	for _, r := range latest {
+		_, err = tx.ExecContext(ctx, `
+	UPDATE ruok.jobs SET
+		last_execution = $1,
+		should_execute_at = $2,
+		last_response_at = $3,
+		last_message = $4,
+		last_status_code = $5,
+		succeeded = $6
+	WHERE id = $7
+	`, r.ExecutionTime, r.ShouldExecuteAt, r.LastResponseAt, r.LastMessage, r.LastStatusCode, r.Succeeded, r.JobId)
+		if err != nil {
+			log.Error().Err(err).Msgf("could not update last execution of job %v", r.JobId)
+			return errors.New("could not insert into job_results")
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Error().Err(err).Msg("could not commit transaction to insert into job_results")
+		return errors.New("could not commit transaction into job_results")
+	}
+	return nil
+}
+
+// Starts buffering execution results, see config.Configs.ResultsBatchSize
+func (s *SQLiteStorage) BufferResults(size int, interval time.Duration, spool string) {
+	if s.results != nil {
+		return
+	}
+	s.results = newResultBuffer(size, interval, spool, s.writeResults)
+}
+
+// Writes buffered results and stops buffering, later results are written right away
+func (s *SQLiteStorage) FlushResults() error {
+	if s.results == nil {
+		return nil
+	}
+	return s.results.close()
+}
+
+// Gets the executions of a job claimed by this instance
+func (s *SQLiteStorage) GetClaimedJobsExecutions(jobId uuid.UUID, limit int, offset int) []*job.JobExecution {
+	if err := s.checkTenant(context.Background(), s.Db, jobId); errors.Is(err, ErrNotFound) {
+		return []*job.JobExecution{}
+	} else if err != nil {
+		return nil
+	}
+	rows, err := s.Db.QueryContext(context.Background(), `
+SELECT
+	id,
+	job_id,
+	cron_exp_string,
+	endpoint,
+	httpmethod,
+	execution_time,
+	should_execute_at,
+	last_response_at,
+	last_message,
+	last_status_code,
+	success_statuses,
+	created_at,
+	succeeded,
+	trigger,
+	queue_wait,
+	location,
+	claimed_by
+ FROM ruok.job_results
+ WHERE claimed_by = $1 AND job_id = $2
+ ORDER BY id DESC
+ LIMIT $3
+ OFFSET $4;
+ `, config.AppName(), jobId, limit, offset)
+	if err != nil {
+		log.Error().Err(err).Msg("could not query for claimed job executions")
+		return nil
+	}
+	defer rows.Close()
+
+	return scanSQLiteExecutions(rows)
+}
+
+// Rolls results older than their retention into aggregates, like ruok.compact_job_results() does.
+// Writers are serialized by the transaction, so nobody else can be compacting at the same time.
+func (s *SQLiteStorage) CompactResults(retentionDays int, hourlyRetentionDays int) (int64, error) {
+	ctx := context.Background()
+	tx, err := s.Db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Error().Err(err).Msg("could not start transaction to compact job results")
+		return 0, errors.New("could not compact job results")
+	}
+	defer tx.Rollback()
+
+	compacted, err := compactSQLiteResults(ctx, tx, retentionDays, hourlyRetentionDays, time.Now())
+	if err != nil {
+		log.Error().Err(err).Msg("could not compact job results")
+		return 0, errors.New("could not compact job results")
+	}
+	if err := tx.Commit(); err != nil {
+		log.Error().Err(err).Msg("could not commit transaction to compact job results")
+		return 0, errors.New("could not compact job results")
+	}
+	return compacted, nil
+}
+
+func compactSQLiteResults(ctx context.Context, tx *sql.Tx, retentionDays int, hourlyRetentionDays int, now time.Time) (int64, error) {
+	rows, err := tx.QueryContext(ctx, `
+SELECT r.id, r.job_id, r.execution_time, r.succeeded, r.last_response_at, coalesce(j.results_retention_days, $1)
+FROM ruok.job_results r
+LEFT JOIN ruok.jobs j ON j.id = r.job_id
+WHERE coalesce(j.results_retention_days, $1) > 0
+`, retentionDays)
+	if err != nil {
+		return 0, err
+	}
+
+	expired := []compactedResult{}
+	for rows.Next() {
+		var id, jobId string
+		var executionTime, responseAt int64
+		var succeeded sql.NullString
+		var retention int
+		if err := rows.Scan(&id, &jobId, &executionTime, &succeeded, &responseAt, &retention); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if executionTime >= compactionCutoff(now, retention) {
+			continue
+		}
+		expired = append(expired, newCompactedResult(id, jobId, executionTime, succeeded.String, responseAt))
+	}
+	rows.Close()
+	if rows.Err() != nil {
+		return 0, rows.Err()
+	}
+
+	// buckets may already exist when late results are compacted, percentiles of the bigger group win
+	for key, b := range bucketResults(expired) {
+		_, err = tx.ExecContext(ctx, `
+INSERT INTO ruok.job_results_aggregates (job_id, granularity, bucket_start, executions, failures, p50_latency, p95_latency)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+ON CONFLICT (job_id, granularity, bucket_start) DO UPDATE SET
+	executions = executions + excluded.executions,
+	failures = failures + excluded.failures,
+	p50_latency = CASE WHEN excluded.executions > executions THEN excluded.p50_latency ELSE p50_latency END,
+	p95_latency = CASE WHEN excluded.executions > executions THEN excluded.p95_latency ELSE p95_latency END
+`, key.jobId, key.granularity, key.bucketStart, b.executions, b.failures, percentile(b.latencies, 0.5), percentile(b.latencies, 0.95))
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	for _, r := range expired {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM ruok.job_results WHERE id = $1", r.id); err != nil {
+			return 0, err
+		}
+	}
+
+	if hourlyRetentionDays > 0 {
+		_, err = tx.ExecContext(ctx,
+			"DELETE FROM ruok.job_results_aggregates WHERE granularity = $1 AND bucket_start < $2",
+			GranularityHour,
+			now.AddDate(0, 0, -hourlyRetentionDays).UnixMicro(),
+		)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return int64(len(expired)), nil
+}
+
+// Lists the aggregated results of a job between two times, aligned to the granularity.
+// Compacted aggregates are merged with the ones computed from results not compacted yet.
+// Returns nil if something goes wrong.
+func (s *SQLiteStorage) GetResultAggregates(jobId uuid.UUID, granularity string, from time.Time, to time.Time) []*job.ResultAggregate {
+	size, ok := granularitySizes[granularity]
+	if !ok {
+		log.Error().Msgf("unknown granularity %q", granularity)
+		return nil
+	}
+	from = from.UTC().Truncate(size)
+	ctx := context.Background()
+	merge := newAggregatesMerge(granularity, size)
+
+	if err := s.checkTenant(ctx, s.Db, jobId); errors.Is(err, ErrNotFound) {
+		return merge.list()
+	} else if err != nil {
+		return nil
+	}
+
+	rows, err := s.Db.QueryContext(ctx, `
+SELECT bucket_start, executions, failures, p50_latency, p95_latency
+FROM ruok.job_results_aggregates
+WHERE job_id = $1 AND granularity = $2 AND bucket_start >= $3 AND bucket_start < $4
+`, jobId, granularity, from.UnixMicro(), to.UnixMicro())
+	if err != nil {
+		log.Error().Err(err).Msgf("could not get result aggregates of job %v", jobId)
+		return nil
+	}
+	for rows.Next() {
+		var start int64
+		var executions, failures int
+		var p50, p95 sql.NullInt64
+		if err := rows.Scan(&start, &executions, &failures, &p50, &p95); err != nil {
+			rows.Close()
+			log.Error().Err(err).Msg("could not scan result aggregates row")
+			return nil
+		}
+		merge.addCompacted(start, executions, failures, nullableInt64(p50), nullableInt64(p95))
+	}
+	rows.Close()
+	if rows.Err() != nil {
+		log.Error().Err(rows.Err()).Msgf("could not read result aggregates of job %v", jobId)
+		return nil
+	}
+
+	rows, err = s.Db.QueryContext(ctx, `
+SELECT execution_time, succeeded, last_response_at
+FROM ruok.job_results
+WHERE job_id = $1 AND execution_time >= $2 AND execution_time < $3
+`, jobId, from.UnixMicro(), to.UnixMicro())
+	if err != nil {
+		log.Error().Err(err).Msgf("could not get results of job %v", jobId)
+		return nil
+	}
+	for rows.Next() {
+		var executionTime, responseAt int64
+		var succeeded sql.NullString
+		if err := rows.Scan(&executionTime, &succeeded, &responseAt); err != nil {
+			rows.Close()
+			log.Error().Err(err).Msg("could not scan results row")
+			return nil
+		}
+		merge.addResult(newCompactedResult("", "", executionTime, succeeded.String, responseAt))
+	}
+	rows.Close()
+	if rows.Err() != nil {
+		log.Error().Err(rows.Err()).Msgf("could not read results of job %v", jobId)
+		return nil
+	}
+	return merge.list()
+}
+
+// Gets the executions of a job from every scheduler, newest first.
+// The page starts after the execution "before", or from the newest one when it is uuid.Nil.
+func (s *SQLiteStorage) ListJobExecutions(jobId uuid.UUID, limit int, before uuid.UUID) []*job.JobExecution {
+	if err := s.checkTenant(context.Background(), s.Db, jobId); errors.Is(err, ErrNotFound) {
+		return []*job.JobExecution{}
+	} else if err != nil {
+		return nil
+	}
+	after := ""
+	if before != uuid.Nil {
+		after = before.String()
+	}
+	rows, err := s.Db.QueryContext(context.Background(), `
+SELECT
+	id,
+	job_id,
+	cron_exp_string,
+	endpoint,
+	httpmethod,
+	execution_time,
+	should_execute_at,
+	last_response_at,
+	last_message,
+	last_status_code,
+	success_statuses,
+	created_at,
+	succeeded,
+	trigger,
+	queue_wait,
+	location,
+	claimed_by
+ FROM ruok.job_results
+ WHERE job_id = $1 AND ($3 = '' OR id < $3)
+ ORDER BY id DESC
+ LIMIT $2;
+ `, jobId, limit, after)
+	if err != nil {
+		log.Error().Err(err).Msg("could not query for job executions")
+		return nil
+	}
+	defer rows.Close()
+	return scanSQLiteExecutions(rows)
+}
+
+func scanSQLiteExecutions(rows *sql.Rows) []*job.JobExecution {
+	executions := []*job.JobExecution{}
+	for rows.Next() {
+		var LastExecution, ShouldExecuteAt, LastResponseAt sql.NullInt64
+		var LastMessage, Succeeded, Location sql.NullString
+		var LastStatusCode sql.NullInt32
+		var SuccessStatuses intArray
+		var ClaimedBy sql.NullString
+		e := &job.JobExecution{}
+		err := rows.Scan(
+			&e.Id,
+			&e.JobId,
+			&e.CronExpString,
+			&e.Endpoint,
+			&e.HttpMethod,
+			&LastExecution,
+			&ShouldExecuteAt,
+			&LastResponseAt,
+			&LastMessage,
+			&LastStatusCode,
+			&SuccessStatuses,
+			&e.CreatedAt,
+			&Succeeded,
+			&e.Trigger,
+			&e.QueueWaitMicro,
+			&Location,
+			&ClaimedBy,
+		)
+		if err != nil {
+			log.Error().Err(err).Msg("could not scan job executions row")
+			continue
+		}
+		e.LastExecution = time.UnixMicro(LastExecution.Int64)
+		e.ShouldExecuteAt = time.UnixMicro(ShouldExecuteAt.Int64)
+		e.LastResponseAt = time.UnixMicro(LastResponseAt.Int64)
+		e.LastMessage = LastMessage.String
+		e.LastStatusCode = int(LastStatusCode.Int32)
+		e.SuccessStatuses = SuccessStatuses
+		e.Succeeded = Succeeded.String
+		e.Location = Location.String
+		e.ClaimedBy = ClaimedBy.String
+		executions = append(executions, e)
+	}
+	return executions
+}
+
+func nullableInt64(n sql.NullInt64) *int64 {
+	if !n.Valid {
+		return nil
+	}
+	return &n.Int64
+}
@@ -0,0 +1,130 @@
+-- Same columns as the postgres tables. uuids are stored as text and int/uuid arrays
+-- as postgres array literals, like '{200,201}', so seeds work on both.
+-- created_at is unix milliseconds, like ruok.micro_unix_now()
+
+CREATE TABLE IF NOT EXISTS ruok.jobs (
+	id text PRIMARY KEY NOT NULL,
+	job_name text NOT NULL,
+	cron_exp_string text NOT NULL,
+	endpoint text NOT NULL,
+	httpmethod text NOT NULL,
+	max_retries integer DEFAULT 1,
+	last_execution integer,
+	should_execute_at integer,
+	last_response_at integer,
+	last_message text,
+	last_status_code integer,
+	headers_string text,
+	success_statuses text NOT NULL,
+	succeeded text,
+	tls_client_cert text,
+	alert_strategy text,
+	alert_endpoint text,
+	alert_method text,
+	alert_headers_string text,
+	alert_payload text,
+	status text,
+	claimed_by text,
+	created_at integer DEFAULT (CAST(unixepoch('subsec') * 1000 AS integer)) NOT NULL,
+	updated_at integer,
+	deleted_at integer,
+	timezone text DEFAULT 'UTC' NOT NULL,
+	results_retention_days integer,
+	-- json object like {"env": "prod"}
+	labels text DEFAULT '{}' NOT NULL,
+	-- array literal like '{eu-west,us-east}'
+	locations text DEFAULT '{}' NOT NULL,
+	alert_quorum integer,
+	updated_by text,
+	tenant_id text DEFAULT 'default' NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS ruok.jobs_status_idx ON jobs (status);
+CREATE INDEX IF NOT EXISTS ruok.jobs_claimed_by_idx ON jobs (claimed_by);
+
+CREATE TABLE IF NOT EXISTS ruok.job_results (
+	id text PRIMARY KEY NOT NULL,
+	job_name text NOT NULL,
+	job_id text NOT NULL,
+	cron_exp_string text NOT NULL,
+	endpoint text NOT NULL,
+	httpmethod text NOT NULL,
+	max_retries integer DEFAULT 1,
+	execution_time integer NOT NULL,
+	should_execute_at integer NOT NULL,
+	last_response_at integer NOT NULL,
+	last_message text,
+	last_status_code integer NOT NULL,
+	success_statuses text NOT NULL,
+	succeeded text NOT NULL,
+	tls_client_cert text,
+	status text NOT NULL,
+	claimed_by text NOT NULL,
+	created_at integer DEFAULT (CAST(unixepoch('subsec') * 1000 AS integer)) NOT NULL,
+	deleted_at integer,
+	trigger text DEFAULT 'schedule' NOT NULL,
+	queue_wait integer DEFAULT 0 NOT NULL,
+	location text
+);
+
+CREATE INDEX IF NOT EXISTS ruok.job_results_job_id_execution_time_idx ON job_results (job_id, execution_time);
+
+CREATE TABLE IF NOT EXISTS ruok.maintenance_windows (
+	id text PRIMARY KEY NOT NULL,
+	window_name text NOT NULL,
+	mode text NOT NULL,
+	cron_exp_string text,
+	timezone text DEFAULT 'UTC' NOT NULL,
+	duration_seconds integer,
+	starts_at integer,
+	ends_at integer,
+	job_ids text DEFAULT '{}' NOT NULL,
+	selector text DEFAULT '' NOT NULL,
+	created_at integer DEFAULT (CAST(unixepoch('subsec') * 1000 AS integer)) NOT NULL,
+	deleted_at integer
+);
+
+CREATE TABLE IF NOT EXISTS ruok.job_results_aggregates (
+	job_id text NOT NULL,
+	granularity text NOT NULL,
+	bucket_start integer NOT NULL,
+	executions integer NOT NULL,
+	failures integer NOT NULL,
+	p50_latency integer,
+	p95_latency integer,
+	created_at integer DEFAULT (CAST(unixepoch('subsec') * 1000 AS integer)) NOT NULL,
+	PRIMARY KEY (job_id, granularity, bucket_start)
+);
+
+CREATE TABLE IF NOT EXISTS ruok.job_locations (
+	job_id text NOT NULL,
+	location text NOT NULL,
+	claimed_by text,
+	last_execution integer,
+	failing integer DEFAULT 0 NOT NULL,
+	PRIMARY KEY (job_id, location)
+);
+
+CREATE TABLE IF NOT EXISTS ruok.api_keys (
+	id text PRIMARY KEY NOT NULL,
+	key_name text NOT NULL,
+	prefix text NOT NULL,
+	key_hash text NOT NULL UNIQUE,
+	scope text NOT NULL,
+	created_at integer DEFAULT (CAST(unixepoch('subsec') * 1000 AS integer)) NOT NULL,
+	revoked_at integer,
+	tenant_id text DEFAULT 'default' NOT NULL
+);
+
+-- before and after are json text
+CREATE TABLE IF NOT EXISTS ruok.job_audit (
+	id text PRIMARY KEY NOT NULL,
+	job_id text NOT NULL,
+	revision integer NOT NULL,
+	action text NOT NULL,
+	actor text,
+	created_at integer DEFAULT (CAST(unixepoch('subsec') * 1000 AS integer)) NOT NULL,
+	before text,
+	after text NOT NULL,
+	UNIQUE (job_id, revision)
+);
@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSQLiteUpgradesOldFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ruok.db")
+	s, closeStorage := NewSQLiteStorage(path)
+	// turn it into a file made before jobs had labels, locations, authors and tenants, and windows had selectors
+	ctx := context.Background()
+	for _, statement := range []string{
+		"ALTER TABLE ruok.jobs DROP COLUMN labels",
+		"ALTER TABLE ruok.jobs DROP COLUMN locations",
+		"ALTER TABLE ruok.jobs DROP COLUMN alert_quorum",
+		"ALTER TABLE ruok.job_results DROP COLUMN location",
+		"ALTER TABLE ruok.jobs DROP COLUMN updated_by",
+		"ALTER TABLE ruok.jobs DROP COLUMN tenant_id",
+		"DROP TABLE ruok.job_locations",
+		"DROP TABLE ruok.api_keys",
+		"DROP TABLE ruok.job_audit",
+		"ALTER TABLE ruok.maintenance_windows DROP COLUMN selector",
+		"PRAGMA ruok.user_version = 1",
+	} {
+		_, err := s.Db.ExecContext(ctx, statement)
+		assert.NoError(t, err)
+	}
+	closeStorage()
+
+	s, closeStorage = NewSQLiteStorage(path)
+	defer closeStorage()
+	var version int
+	assert.NoError(t, s.Db.QueryRowContext(ctx, "PRAGMA ruok.user_version").Scan(&version))
+	assert.Equal(t, sqliteSchemaVersion, version)
+
+	_, err := s.CreateJob(CreateJobInput{Name: "labeled", CronExpString: "*/1 * * * *", Labels: map[string]string{"env": "prod"}, AlertQuorum: 2})
+	assert.NoError(t, err)
+	jobs := s.GetAvailableJobs(1, nil)
+	if assert.Len(t, jobs, 1) {
+		assert.Equal(t, map[string]string{"env": "prod"}, jobs[0].Labels)
+		assert.Equal(t, 2, jobs[0].AlertQuorum)
+	}
+
+	assert.NoError(t, s.CreateMaintenanceWindow(CreateMaintenanceWindowInput{Name: "eu", Mode: "skip", CronExpString: "0 2 * * *", DurationSeconds: 60, Selector: "zone=eu"}))
+	if windows := s.GetMaintenanceWindows(); assert.Len(t, windows, 1) {
+		assert.Equal(t, "zone=eu", windows[0].Selector)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/gofrs/uuid"
 	pgxuuid "github.com/jackc/pgx-gofrs-uuid"
@@ -11,8 +12,11 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/rs/zerolog/log"
 
+	"github.com/back-end-labs/ruok/pkg/auth"
 	"github.com/back-end-labs/ruok/pkg/config"
 	"github.com/back-end-labs/ruok/pkg/job"
+	"github.com/back-end-labs/ruok/pkg/labels"
+	"github.com/back-end-labs/ruok/pkg/maintenance"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -23,32 +27,74 @@ type Storage interface {
 }
 
 type SchedulerStorage interface {
-	ListenForChanges(ch chan uuid.UUID, ctx context.Context)
+	ListenForChanges(ch chan Notification, ctx context.Context)
 	StopListeningForChanges() error
 	GetJobUpdates(jobId uuid.UUID) *JobUpdates
-	GetAvailableJobs(limit int) []*job.Job
+	GetAvailableJobs(limit int, selector labels.Selector) []*job.Job
 	WriteDone(*job.Job) error
+	BufferResults(size int, interval time.Duration, spool string)
+	FlushResults() error
+	CompactResults(retentionDays int, hourlyRetentionDays int) (int64, error)
 	RegisterSelf()
-	GetClient() *pgxpool.Pool
 	ReleaseAll(j []*job.Job) error
+	CompleteJob(jobId uuid.UUID) error
+	GetMaintenanceWindows() []*maintenance.Window
+	GetJobsUpdatedAt(jobIds []uuid.UUID) map[uuid.UUID]int64
+	RecordLocationResult(r LocationResult, since time.Time) (int, error)
 }
 
 type APIStorage interface {
-	GetClaimedJobs(limit int, offset int) []*job.Job
+	GetClaimedJobs(limit int, offset int, selector labels.Selector) []*job.Job
+	GetJobIds(selector labels.Selector) []uuid.UUID
 	GetClaimedJobsExecutions(jobId uuid.UUID, limit int, offset int) []*job.JobExecution
+	GetJob(jobId uuid.UUID) (*job.Job, error)
+	ListJobs(in ListJobsInput) (*JobsPage, error)
+	ListJobExecutions(jobId uuid.UUID, limit int, before uuid.UUID) []*job.JobExecution
 	Connected() bool
 	GetSSLVersion() (bool, string)
-	CreateJob(j CreateJobInput) error
+	// Returns the id of the new job
+	CreateJob(j CreateJobInput) (uuid.UUID, error)
 	UpdateJob(j UpdateJobInput) error
+	UpdateJobAlerts(jobId uuid.UUID, a JobAlertsInput) error
+	GetMaintenanceWindows() []*maintenance.Window
+	CreateMaintenanceWindow(w CreateMaintenanceWindowInput) error
+	DeleteMaintenanceWindow(id uuid.UUID) error
+	PauseJob(jobId uuid.UUID, by string) error
+	ResumeJob(jobId uuid.UUID, by string) error
+	DeleteJob(jobId uuid.UUID, by string) error
+	RequestRun(jobId uuid.UUID) (uuid.UUID, error)
+	GetRunResult(runId uuid.UUID) *job.ExecutionResult
+	ListenerHealth() ListenerHealth
+	GetResultAggregates(jobId uuid.UUID, granularity string, from time.Time, to time.Time) []*job.ResultAggregate
+	CreateAPIKey(k CreateAPIKeyInput) (*auth.APIKey, error)
+	GetAPIKey(hash string) (*auth.APIKey, error)
+	ListAPIKeys() []*auth.APIKey
+	RevokeAPIKey(id uuid.UUID) error
+	GetJobHistory(jobId uuid.UUID, limit int, offset int) []*job.Revision
+	GetJobRevision(jobId uuid.UUID, revision int) (*job.Snapshot, error)
+	RestoreJob(jobId uuid.UUID, revision int, by string) error
+	ForTenant(tenant string) APIStorage
+	WithMaxJobs(max int) APIStorage
+	CountJobs() (int, error)
+	SeesEveryJob() (bool, error)
 }
 
+// Returned when the resource we are trying to modify doesn't exist
+var ErrNotFound = errors.New("resource not found")
+
 type SQLStorage struct {
-	Db *pgxpool.Pool
+	Db       *pgxpool.Pool
+	listener *listenerState
+	results  *resultBuffer
+	// Only the jobs of this tenant are seen, every tenant when empty. See ForTenant
+	tenant string
+	// Jobs the tenant can have at most, 0 has no limit. See WithMaxJobs
+	maxJobs int
 }
 
 type Closer func()
 
-// Returns the raw client
+// Returns the raw client, only for callers that need postgres itself like the migrations
 func (sqls *SQLStorage) GetClient() *pgxpool.Pool {
 	return sqls.Db
 }
@@ -60,11 +106,31 @@ func (sqls *SQLStorage) Connected() bool {
 // Should register the url, name of the application and so on in the db
 func (sqls *SQLStorage) RegisterSelf() {}
 
-// It connects to a db
+func (sqls *SQLStorage) execRaw(ctx context.Context, query string, args ...any) error {
+	_, err := sqls.Db.Exec(ctx, query, args...)
+	return err
+}
+
+// It connects to the db of the configured kind
 func NewStorage(cfg *config.Configs) (Storage, Closer) {
+	switch cfg.Kind {
+	case config.POSTGRES_STORAGE:
+		return NewPostgresStorage(cfg)
+	case config.SQLITE_STORAGE:
+		return NewSQLiteStorage(cfg.SQLitePath)
+	case config.MEMORY_STORAGE:
+		return NewMemoryStorage()
+	default:
+		log.Fatal().Err(errors.New("unrecognized storage")).Msgf("Kind field must use one of [ %s %s %s ]", config.POSTGRES_STORAGE, config.SQLITE_STORAGE, config.MEMORY_STORAGE)
+	}
+	return nil, nil
+}
+
+// It connects to a postgres db
+func NewPostgresStorage(cfg *config.Configs) (*SQLStorage, Closer) {
 	connStr := fmt.Sprintf(
 		"%s://%s:%s@%s:%s/%s?sslmode=%s&application_name=%s",
-		cfg.Kind,
+		config.POSTGRES_STORAGE,
 		cfg.User,
 		cfg.Pass,
 		cfg.Host,
@@ -81,31 +147,24 @@ func NewStorage(cfg *config.Configs) (Storage, Closer) {
 			cfg.SSLConfigs.CACertPath,
 			cfg.SSLConfigs.SSLPassword)
 	}
-	// Connect to database
-	switch cfg.Kind {
 
-	case "postgres":
-		dbconfig, err := pgxpool.ParseConfig(connStr)
+	dbconfig, err := pgxpool.ParseConfig(connStr)
 
-		if err != nil {
-			log.Fatal().Err(err).Msg("could not parse url to configs")
-		}
-
-		dbconfig.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
-			pgxuuid.Register(conn.TypeMap())
-			return nil
-		}
+	if err != nil {
+		log.Fatal().Err(err).Msg("could not parse url to configs")
+	}
 
-		db, err := pgxpool.NewWithConfig(context.Background(), dbconfig)
+	dbconfig.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
+		pgxuuid.Register(conn.TypeMap())
+		return nil
+	}
+	dbconfig.BeforeAcquire = setConnTenant
+	dbconfig.BeforeClose = forgetConnTenant
 
-		if err != nil {
-			log.Fatal().Err(err).Msg("could no stablish a connection with the database, aborting.")
-		}
-		s := &SQLStorage{Db: db}
-		return s, db.Close
+	db, err := pgxpool.NewWithConfig(context.Background(), dbconfig)
 
-	default:
-		log.Fatal().Err(errors.New("unrecognized storage")).Msg("Kind field must use one of [ postgres ]")
+	if err != nil {
+		log.Fatal().Err(err).Msg("could no stablish a connection with the database, aborting.")
 	}
-	return nil, nil
+	return &SQLStorage{Db: db, listener: newListenerState()}, db.Close
 }
@@ -0,0 +1,183 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/back-end-labs/ruok/pkg/config"
+	"github.com/gofrs/uuid"
+)
+
+// Kinds of storage the tests run against.
+// TEST_STORAGE_KINDS=sqlite runs them without a postgres server.
+func testedStorageKinds() []string {
+	kinds := os.Getenv("TEST_STORAGE_KINDS")
+	if kinds == "" {
+		return []string{config.POSTGRES_STORAGE, config.SQLITE_STORAGE}
+	}
+	return strings.Split(kinds, ",")
+}
+
+// A storage under test, with raw access to its db to seed it and check what was written
+type testStorage struct {
+	Storage
+	// allowed to seed and drop, postgres uses a testing role for it
+	admin rawDB
+}
+
+// Runs the test once per kind of storage backed by a db, every run starts with an empty one.
+// The memory storage has no db to seed, it is checked by the conformance suite.
+func forEachStorage(t *testing.T, test func(t *testing.T, s *testStorage)) {
+	for _, kind := range testedStorageKinds() {
+		if kind == config.MEMORY_STORAGE {
+			continue
+		}
+		t.Run(kind, func(t *testing.T) {
+			s, close := newTestStorage(t, kind)
+			defer close()
+			s.drop()
+			defer s.drop()
+			test(t, s)
+		})
+	}
+}
+
+func newTestStorage(t *testing.T, kind string) (*testStorage, Closer) {
+	cfg := config.FromEnvs()
+	cfg.Kind = kind
+	cfg.SQLitePath = filepath.Join(t.TempDir(), "ruok.db")
+	s, close := NewStorage(&cfg)
+	if kind != config.POSTGRES_STORAGE {
+		admin, ok := s.(rawDB)
+		if !ok {
+			close()
+			t.Skipf("the %s storage has no database to test against", kind)
+		}
+		return &testStorage{Storage: s, admin: admin}, close
+	}
+
+	cfg.User = "testing_user"
+	admin, closeAdmin := NewPostgresStorage(&cfg)
+	return &testStorage{Storage: s, admin: admin}, func() {
+		closeAdmin()
+		close()
+	}
+}
+
+func (ts *testStorage) seed() {
+	seed(ts.admin)
+}
+
+func (ts *testStorage) drop() {
+	drop(ts.admin)
+}
+
+// Postgres arrays are stored as text in sqlite
+func sqliteArgs(args []any) []any {
+	converted := make([]any, len(args))
+	for i, arg := range args {
+		switch v := arg.(type) {
+		case []int:
+			converted[i] = intArray(v)
+		case []uuid.UUID:
+			converted[i] = uuidArray(v)
+		default:
+			converted[i] = arg
+		}
+	}
+	return converted
+}
+
+// Runs a query with the same role the storage uses
+func (ts *testStorage) exec(query string, args ...any) error {
+	ctx := context.Background()
+	switch s := ts.Storage.(type) {
+	case *SQLStorage:
+		_, err := s.Db.Exec(ctx, query, args...)
+		return err
+	case *SQLiteStorage:
+		_, err := s.Db.ExecContext(ctx, query, sqliteArgs(args)...)
+		return err
+	}
+	panic("unknown storage")
+}
+
+type testRow interface {
+	Scan(dest ...any) error
+}
+
+func (ts *testStorage) queryRow(query string, args ...any) testRow {
+	ctx := context.Background()
+	switch s := ts.Storage.(type) {
+	case *SQLStorage:
+		return s.Db.QueryRow(ctx, query, args...)
+	case *SQLiteStorage:
+		return sqliteRow{s.Db.QueryRowContext(ctx, query, sqliteArgs(args)...)}
+	}
+	panic("unknown storage")
+}
+
+// Scans every row with "scan"
+func (ts *testStorage) queryRows(query string, args []any, scan func(row testRow) error) error {
+	ctx := context.Background()
+	switch s := ts.Storage.(type) {
+	case *SQLStorage:
+		rows, err := s.Db.Query(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			if err := scan(rows); err != nil {
+				return err
+			}
+		}
+		return rows.Err()
+	case *SQLiteStorage:
+		rows, err := s.Db.QueryContext(ctx, query, sqliteArgs(args)...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			if err := scan(sqliteRow{rows}); err != nil {
+				return err
+			}
+		}
+		return rows.Err()
+	}
+	panic("unknown storage")
+}
+
+// Sends a payload as is to whoever listens on "channel"
+func (ts *testStorage) notifyRaw(channel string, payload string) error {
+	switch s := ts.Storage.(type) {
+	case *SQLStorage:
+		_, err := s.Db.Exec(context.Background(), "select pg_notify($1, $2)", channel, payload)
+		return err
+	case *SQLiteStorage:
+		s.bus.publish(channel, payload)
+		return nil
+	}
+	panic("unknown storage")
+}
+
+// Scans int arrays like postgres does
+type sqliteRow struct {
+	row testRow
+}
+
+func (r sqliteRow) Scan(dest ...any) error {
+	converted := make([]any, len(dest))
+	for i, d := range dest {
+		if ints, ok := d.(*[]int); ok {
+			converted[i] = (*intArray)(ints)
+		} else {
+			converted[i] = d
+		}
+	}
+	return r.row.Scan(converted...)
+}
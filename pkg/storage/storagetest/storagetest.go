@@ -0,0 +1,1121 @@
+package storagetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/back-end-labs/ruok/pkg/auth"
+	"github.com/back-end-labs/ruok/pkg/config"
+	"github.com/back-end-labs/ruok/pkg/job"
+	"github.com/back-end-labs/ruok/pkg/labels"
+	"github.com/back-end-labs/ruok/pkg/maintenance"
+	"github.com/back-end-labs/ruok/pkg/storage"
+)
+
+// Returns an empty storage for a single test, closing it is up to the factory (see t.Cleanup)
+type Factory func(t *testing.T) storage.Storage
+
+// Checks that a storage behaves like the others, every new kind of storage should pass it.
+// It only goes through the storage.Storage interface, so it can run against any of them.
+func Run(t *testing.T, newStorage Factory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, s storage.Storage)
+	}{
+		{"ClaimsAvailableJobs", testClaimsAvailableJobs},
+		{"ListsClaimedJobs", testListsClaimedJobs},
+		{"GetsJobs", testGetsJobs},
+		{"ListsJobs", testListsJobs},
+		{"KeepsAlertsWithMinFields", testKeepsAlertsWithMinFields},
+		{"UpdatesJobs", testUpdatesJobs},
+		{"SelectsJobsByLabels", testSelectsJobsByLabels},
+		{"UpdatesJobAlerts", testUpdatesJobAlerts},
+		{"PausesResumesAndDeletes", testPausesResumesAndDeletes},
+		{"CompletesJobs", testCompletesJobs},
+		{"ReleasesJobs", testReleasesJobs},
+		{"GetsJobsUpdatedAt", testGetsJobsUpdatedAt},
+		{"WritesExecutions", testWritesExecutions},
+		{"BuffersResults", testBuffersResults},
+		{"RunsJobsOnRequest", testRunsJobsOnRequest},
+		{"CompactsResults", testCompactsResults},
+		{"MaintenanceWindows", testMaintenanceWindows},
+		{"StopsListening", testStopsListening},
+		{"ClaimsJobsPerLocation", testClaimsJobsPerLocation},
+		{"CountsFailingLocations", testCountsFailingLocations},
+		{"ManagesAPIKeys", testManagesAPIKeys},
+		{"RecordsJobHistory", testRecordsJobHistory},
+		{"IsolatesTenants", testIsolatesTenants},
+		{"LimitsJobsPerTenant", testLimitsJobsPerTenant},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newStorage(t))
+		})
+	}
+}
+
+func createJobs(t *testing.T, s storage.Storage, n int) {
+	for i := 0; i < n; i++ {
+		_, err := s.CreateJob(storage.CreateJobInput{
+			Name:            "conformance job",
+			CronExpString:   "*/1 * * * *",
+			MaxRetries:      1,
+			Endpoint:        "http://localhost/",
+			HttpMethod:      "GET",
+			SuccessStatuses: []int{200},
+		})
+		if err != nil {
+			t.Fatalf("could not create job: %q", err.Error())
+		}
+	}
+}
+
+// Creates a job and claims it
+func claimOne(t *testing.T, s storage.Storage) *job.Job {
+	createJobs(t, s, 1)
+	jobs := s.GetAvailableJobs(1, nil)
+	if len(jobs) != 1 {
+		t.Fatalf("expected to claim 1 job, got %d", len(jobs))
+	}
+	return jobs[0]
+}
+
+// Listens for changes until the test ends
+func listen(t *testing.T, s storage.Storage) chan storage.Notification {
+	ch := make(chan storage.Notification, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	s.ListenForChanges(ch, ctx)
+	t.Cleanup(cancel)
+	return ch
+}
+
+func expectNotification(t *testing.T, ch chan storage.Notification, event string, jobId uuid.UUID) storage.Notification {
+	select {
+	case n := <-ch:
+		assert.Equal(t, event, n.Event)
+		assert.Equal(t, jobId, n.JobId)
+		return n
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no %q notification for job %v", event, jobId)
+	}
+	return storage.Notification{}
+}
+
+func testClaimsAvailableJobs(t *testing.T, s storage.Storage) {
+	createJobs(t, s, 3)
+
+	claimed := s.GetAvailableJobs(2, nil)
+	assert.Len(t, claimed, 2)
+	for _, j := range claimed {
+		assert.Equal(t, "claimed", j.Status)
+		assert.Equal(t, config.AppName(), j.ClaimedBy)
+		assert.Equal(t, "conformance job", j.Name)
+		assert.Equal(t, "*/1 * * * *", j.CronExpString)
+		assert.Equal(t, "http://localhost/", j.Endpoint)
+		assert.Equal(t, "GET", j.HttpMethod)
+		assert.Equal(t, 1, j.MaxRetries)
+		assert.Equal(t, []int{200}, j.SuccessStatuses)
+		assert.Equal(t, "UTC", j.Timezone)
+		assert.NotNil(t, j.Headers)
+	}
+
+	assert.Len(t, s.GetAvailableJobs(10, nil), 1)
+	assert.Len(t, s.GetAvailableJobs(10, nil), 0)
+}
+
+func testListsClaimedJobs(t *testing.T, s storage.Storage) {
+	createJobs(t, s, 5)
+	assert.Len(t, s.GetClaimedJobs(10, 0, nil), 0)
+
+	claimed := s.GetAvailableJobs(4, nil)
+	assert.Len(t, claimed, 4)
+
+	all := s.GetClaimedJobs(10, 0, nil)
+	assert.Len(t, all, 4)
+	for i := 1; i < len(all); i++ {
+		assert.Less(t, all[i-1].Id.String(), all[i].Id.String(), "claimed jobs are sorted by id")
+	}
+	page := s.GetClaimedJobs(2, 1, nil)
+	assert.Len(t, page, 2)
+	assert.Equal(t, all[1].Id, page[0].Id)
+	assert.Equal(t, all[2].Id, page[1].Id)
+	assert.Len(t, s.GetClaimedJobs(10, 4, nil), 0)
+}
+
+func testGetsJobs(t *testing.T, s storage.Storage) {
+	days := 7
+	id, err := s.CreateJob(storage.CreateJobInput{
+		Name:                 "definition",
+		CronExpString:        "*/5 * * * *",
+		Timezone:             "Europe/Madrid",
+		MaxRetries:           2,
+		Endpoint:             "http://localhost/",
+		HttpMethod:           "POST",
+		SuccessStatuses:      []int{200, 201},
+		AlertStrategy:        config.ALERT_HTTP,
+		AlertEndpoint:        "http://localhost/alerts",
+		AlertMethod:          "POST",
+		Labels:               map[string]string{"env": "prod"},
+		Locations:            []string{"eu-west"},
+		AlertQuorum:          1,
+		ResultsRetentionDays: &days,
+		UpdatedBy:            "tester",
+	})
+	assert.NoError(t, err)
+	page, err := s.ListJobs(storage.ListJobsInput{Limit: 10})
+	if !assert.NoError(t, err) || !assert.Len(t, page.Jobs, 1) {
+		t.FailNow()
+	}
+	assert.Equal(t, id, page.Jobs[0].Id, "creating a job returns its id")
+
+	j, err := s.GetJob(page.Jobs[0].Id)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, "definition", j.Name)
+	assert.Equal(t, "*/5 * * * *", j.CronExpString)
+	assert.Equal(t, "Europe/Madrid", j.Timezone)
+	assert.Equal(t, 2, j.MaxRetries)
+	assert.Equal(t, "POST", j.HttpMethod)
+	assert.Equal(t, []int{200, 201}, j.SuccessStatuses)
+	assert.Equal(t, config.ALERT_HTTP, j.AlertStrategy)
+	assert.Equal(t, "http://localhost/alerts", j.AlertEndpoint)
+	assert.Equal(t, map[string]string{"env": "prod"}, j.Labels)
+	assert.Equal(t, []string{"eu-west"}, j.Locations)
+	assert.Equal(t, 1, j.AlertQuorum)
+	assert.Equal(t, &days, j.ResultsRetentionDays)
+	assert.Equal(t, "pending to be claimed", j.Status)
+	assert.Equal(t, "tester", j.UpdatedBy)
+	assert.Equal(t, page.Jobs[0], j, "listed jobs have the whole definition")
+
+	assert.NoError(t, s.DeleteJob(j.Id, "tester"))
+	_, err = s.GetJob(j.Id)
+	assert.ErrorIs(t, err, storage.ErrNotFound, "deleted jobs are not found")
+	missing, _ := uuid.NewV7()
+	_, err = s.GetJob(missing)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func testListsJobs(t *testing.T, s storage.Storage) {
+	for _, name := range []string{"Charlie", "alpha", "Bravo", "delta"} {
+		_, err := s.CreateJob(storage.CreateJobInput{
+			Name:            name,
+			CronExpString:   "*/1 * * * *",
+			Endpoint:        "http://localhost/",
+			HttpMethod:      "GET",
+			SuccessStatuses: []int{200},
+			Labels:          map[string]string{"env": "prod"},
+		})
+		assert.NoError(t, err)
+	}
+	claimed := s.GetAvailableJobs(1, nil)[0]
+	finish(claimed, 500, time.Now(), time.Millisecond)
+	assert.NoError(t, s.WriteDone(claimed))
+
+	names := func(in storage.ListJobsInput) []string {
+		page, err := s.ListJobs(in)
+		if !assert.NoError(t, err) {
+			return nil
+		}
+		names := []string{}
+		for _, j := range page.Jobs {
+			names = append(names, j.Name)
+		}
+		return names
+	}
+	assert.Equal(t, []string{"alpha", "Bravo", "Charlie", "delta"}, names(storage.ListJobsInput{Limit: 10, SortBy: storage.SortByName}), "names sort ignoring case")
+	assert.Equal(t, []string{"delta", "Charlie", "Bravo", "alpha"}, names(storage.ListJobsInput{Limit: 10, SortBy: storage.SortByName, Descending: true}))
+	assert.Equal(t, []string{claimed.Name}, names(storage.ListJobsInput{Limit: 10, Status: "claimed"}))
+	assert.Equal(t, []string{claimed.Name}, names(storage.ListJobsInput{Limit: 10, Succeeded: "failed"}))
+	assert.Equal(t, []string{"Charlie"}, names(storage.ListJobsInput{Limit: 10, Name: "ARL"}))
+	assert.Len(t, names(storage.ListJobsInput{Limit: 10, Selector: selector(t, "env=prod")}), 4)
+	assert.Len(t, names(storage.ListJobsInput{Limit: 10, Selector: selector(t, "env=dev")}), 0)
+	last := names(storage.ListJobsInput{Limit: 1, SortBy: storage.SortByLastExecution, Descending: true})
+	assert.Equal(t, []string{claimed.Name}, last, "jobs that never ran go last")
+
+	for _, in := range []storage.ListJobsInput{
+		{Limit: 3},
+		{Limit: 1, SortBy: storage.SortByName},
+		{Limit: 2, SortBy: storage.SortByCreatedAt, Descending: true},
+		{Limit: 3, SortBy: storage.SortByLastExecution},
+	} {
+		all := names(storage.ListJobsInput{Limit: 10, SortBy: in.SortBy, Descending: in.Descending})
+		walked := []string{}
+		for pages := 0; pages < 10; pages++ {
+			page, err := s.ListJobs(in)
+			if !assert.NoError(t, err) {
+				break
+			}
+			for _, j := range page.Jobs {
+				walked = append(walked, j.Name)
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			in.Cursor = page.NextCursor
+		}
+		assert.Equal(t, all, walked, "pages sorted by %q walk every job once", in.SortBy)
+	}
+
+	first, err := s.ListJobs(storage.ListJobsInput{Limit: 1})
+	assert.NoError(t, err)
+	_, err = s.ListJobs(storage.ListJobsInput{Limit: 1, SortBy: storage.SortByName, Cursor: first.NextCursor})
+	assert.ErrorIs(t, err, storage.ErrInvalidCursor, "cursors only work with the sort they were made for")
+	_, err = s.ListJobs(storage.ListJobsInput{Limit: 1, Cursor: "nope"})
+	assert.ErrorIs(t, err, storage.ErrInvalidCursor)
+}
+
+func testKeepsAlertsWithMinFields(t *testing.T, s storage.Storage) {
+	inputs := []storage.CreateJobInput{
+		{
+			Name:            "with alerts",
+			CronExpString:   "*/1 * * * *",
+			Endpoint:        "http://localhost/",
+			HttpMethod:      "GET",
+			SuccessStatuses: []int{200},
+			AlertStrategy:   "http",
+			AlertEndpoint:   "http://localhost/alert",
+			AlertMethod:     "POST",
+			AlertPayload:    `{"down":true}`,
+			AlertHeaders:    map[string]string{"key": "value"},
+		},
+		{
+			Name:            "without alert method",
+			CronExpString:   "*/1 * * * *",
+			Endpoint:        "http://localhost/",
+			HttpMethod:      "GET",
+			SuccessStatuses: []int{200},
+			AlertStrategy:   "http",
+			AlertEndpoint:   "http://localhost/alert",
+		},
+	}
+	for _, input := range inputs {
+		_, err := s.CreateJob(input)
+		assert.NoError(t, err)
+	}
+
+	jobs := s.GetAvailableJobs(10, nil)
+	assert.Len(t, jobs, 2)
+	for _, j := range jobs {
+		if j.Name == "with alerts" {
+			assert.Equal(t, "http", j.AlertStrategy)
+			assert.Equal(t, "http://localhost/alert", j.AlertEndpoint)
+			assert.Equal(t, "POST", j.AlertMethod)
+			assert.Equal(t, `{"down":true}`, j.AlertPayload)
+			assert.Equal(t, map[string]string{"key": "value"}, j.AlertHeaders)
+		} else {
+			assert.Empty(t, j.AlertStrategy)
+			assert.Empty(t, j.AlertEndpoint)
+			assert.Empty(t, j.AlertHeaders)
+		}
+	}
+}
+
+func testUpdatesJobs(t *testing.T, s storage.Storage) {
+	j := claimOne(t, s)
+	ch := listen(t, s)
+
+	err := s.UpdateJob(storage.UpdateJobInput{
+		Id:              j.Id,
+		Name:            "updated",
+		CronExpString:   "*/5 * * * *",
+		Timezone:        "Europe/Madrid",
+		MaxRetries:      3,
+		Endpoint:        "http://localhost/updated",
+		HttpMethod:      "POST",
+		SuccessStatuses: []int{200, 201},
+	})
+	assert.NoError(t, err)
+	expectNotification(t, ch, storage.EventUpdated, j.Id)
+
+	updates := s.GetJobUpdates(j.Id)
+	if !assert.NotNil(t, updates) {
+		t.FailNow()
+	}
+	assert.Equal(t, "updated", updates.Job_name)
+	assert.Equal(t, "*/5 * * * *", updates.Cron_exp_string)
+	assert.Equal(t, "Europe/Madrid", updates.Timezone)
+	assert.Equal(t, 3, updates.Max_retries)
+	assert.Equal(t, "http://localhost/updated", updates.Endpoint)
+	assert.Equal(t, "POST", updates.Httpmethod)
+	assert.Equal(t, []int{200, 201}, updates.Success_statuses)
+	assert.Equal(t, "pending to be claimed", updates.Status)
+	assert.NotZero(t, updates.Updated_at)
+	assert.Equal(t, map[uuid.UUID]int64{j.Id: updates.Updated_at}, s.GetJobsUpdatedAt([]uuid.UUID{j.Id}))
+
+	// paused jobs stay paused after an update
+	assert.NoError(t, s.PauseJob(j.Id, "tester"))
+	expectNotification(t, ch, storage.EventPaused, j.Id)
+	assert.NoError(t, s.UpdateJob(storage.UpdateJobInput{Id: j.Id, Name: "still paused", CronExpString: "*/5 * * * *"}))
+	expectNotification(t, ch, storage.EventUpdated, j.Id)
+	assert.Equal(t, "paused", s.GetJobUpdates(j.Id).Status)
+}
+
+func createLabeledJob(t *testing.T, s storage.Storage, name string, l map[string]string) {
+	_, err := s.CreateJob(storage.CreateJobInput{
+		Name:            name,
+		CronExpString:   "*/1 * * * *",
+		Endpoint:        "http://localhost/",
+		HttpMethod:      "GET",
+		SuccessStatuses: []int{200},
+		Labels:          l,
+	})
+	if err != nil {
+		t.Fatalf("could not create job: %q", err.Error())
+	}
+}
+
+func selector(t *testing.T, s string) labels.Selector {
+	sel, err := labels.Parse(s)
+	if err != nil {
+		t.Fatalf("invalid selector %q: %q", s, err.Error())
+	}
+	return sel
+}
+
+func testSelectsJobsByLabels(t *testing.T, s storage.Storage) {
+	createLabeledJob(t, s, "eu payments", map[string]string{"zone": "eu", "team": "payments"})
+	createLabeledJob(t, s, "eu search", map[string]string{"zone": "eu", "team": "search"})
+	createLabeledJob(t, s, "us payments", map[string]string{"zone": "us", "team": "payments"})
+	createLabeledJob(t, s, "no labels", nil)
+
+	assert.Len(t, s.GetJobIds(nil), 4)
+	assert.Len(t, s.GetJobIds(selector(t, "zone=eu")), 2)
+	assert.Len(t, s.GetJobIds(selector(t, "zone=eu,team=payments")), 1)
+	assert.Len(t, s.GetJobIds(selector(t, "zone!=eu")), 2, "jobs without the label don't have the value")
+	assert.Len(t, s.GetJobIds(selector(t, "team")), 3)
+	assert.Len(t, s.GetJobIds(selector(t, "!team")), 1)
+	assert.Len(t, s.GetJobIds(selector(t, "zone=ap")), 0)
+
+	claimed := s.GetAvailableJobs(10, selector(t, "zone=eu"))
+	assert.Len(t, claimed, 2)
+	for _, j := range claimed {
+		assert.Equal(t, "eu", j.Labels["zone"])
+	}
+	assert.Len(t, s.GetClaimedJobs(10, 0, selector(t, "team=search")), 1)
+	assert.Len(t, s.GetAvailableJobs(10, selector(t, "zone=eu")), 0)
+
+	rest := s.GetAvailableJobs(10, nil)
+	assert.Len(t, rest, 2)
+	var unlabeled *job.Job
+	for _, j := range rest {
+		if j.Name == "no labels" {
+			unlabeled = j
+		}
+	}
+	if !assert.NotNil(t, unlabeled) {
+		t.FailNow()
+	}
+	assert.Equal(t, map[string]string{}, unlabeled.Labels)
+
+	// updates replace every label
+	err := s.UpdateJob(storage.UpdateJobInput{
+		Id:            unlabeled.Id,
+		Name:          "labeled",
+		CronExpString: "*/1 * * * *",
+		Labels:        map[string]string{"zone": "eu"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"zone": "eu"}, s.GetJobUpdates(unlabeled.Id).Labels)
+	assert.Len(t, s.GetJobIds(selector(t, "zone=eu")), 3)
+
+	// deleted jobs are left out
+	assert.NoError(t, s.DeleteJob(unlabeled.Id, "tester"))
+	assert.Len(t, s.GetJobIds(selector(t, "zone=eu")), 2)
+}
+
+func testUpdatesJobAlerts(t *testing.T, s storage.Storage) {
+	j := claimOne(t, s)
+	ch := listen(t, s)
+
+	err := s.UpdateJobAlerts(j.Id, storage.JobAlertsInput{
+		AlertStrategy: "default",
+		AlertMethod:   "POST",
+		AlertEndpoint: "http://localhost/alerts",
+	})
+	assert.NoError(t, err)
+	expectNotification(t, ch, storage.EventUpdated, j.Id)
+
+	updates := s.GetJobUpdates(j.Id)
+	assert.Equal(t, "default", updates.Alert_strategy)
+	assert.Equal(t, "POST", updates.Alert_method)
+	assert.Equal(t, "http://localhost/alerts", updates.Alert_endpoint)
+	assert.Equal(t, "claimed", updates.Status, "the job keeps its owner")
+	assert.Len(t, s.GetClaimedJobs(10, 0, nil), 1)
+
+	unknown, _ := uuid.NewV7()
+	assert.ErrorIs(t, s.UpdateJobAlerts(unknown, storage.JobAlertsInput{}), storage.ErrNotFound)
+	assert.NoError(t, s.DeleteJob(j.Id, "tester"))
+	assert.ErrorIs(t, s.UpdateJobAlerts(j.Id, storage.JobAlertsInput{}), storage.ErrNotFound)
+}
+
+func testPausesResumesAndDeletes(t *testing.T, s storage.Storage) {
+	j := claimOne(t, s)
+	ch := listen(t, s)
+
+	assert.NoError(t, s.PauseJob(j.Id, "tester"))
+	expectNotification(t, ch, storage.EventPaused, j.Id)
+	assert.Equal(t, "paused", s.GetJobUpdates(j.Id).Status)
+	assert.Len(t, s.GetClaimedJobs(10, 0, nil), 0, "paused jobs are released")
+	assert.Len(t, s.GetAvailableJobs(10, nil), 0)
+
+	assert.NoError(t, s.ResumeJob(j.Id, "tester"))
+	expectNotification(t, ch, storage.EventUpdated, j.Id)
+	assert.ErrorIs(t, s.ResumeJob(j.Id, "tester"), storage.ErrWrongState, "only paused jobs can be resumed")
+	assert.ErrorIs(t, s.ResumeJob(j.Id, "tester"), storage.ErrConflict)
+	assert.Len(t, s.GetAvailableJobs(10, nil), 1)
+	assert.Equal(t, "tester", s.GetClaimedJobs(10, 0, nil)[0].UpdatedBy)
+
+	assert.NoError(t, s.DeleteJob(j.Id, "tester"))
+	expectNotification(t, ch, storage.EventDeleted, j.Id)
+	updates := s.GetJobUpdates(j.Id)
+	assert.Equal(t, "deleted", updates.Status)
+	assert.NotZero(t, updates.Deleted_at)
+	assert.Len(t, s.GetAvailableJobs(10, nil), 0)
+
+	assert.ErrorIs(t, s.DeleteJob(j.Id, "tester"), storage.ErrNotFound)
+	assert.ErrorIs(t, s.PauseJob(j.Id, "tester"), storage.ErrNotFound)
+	unknown, _ := uuid.NewV7()
+	assert.ErrorIs(t, s.PauseJob(unknown, "tester"), storage.ErrNotFound)
+	assert.ErrorIs(t, s.ResumeJob(unknown, "tester"), storage.ErrNotFound)
+	assert.ErrorIs(t, s.DeleteJob(unknown, "tester"), storage.ErrNotFound)
+}
+
+func testCompletesJobs(t *testing.T, s storage.Storage) {
+	j := claimOne(t, s)
+	assert.NoError(t, s.CompleteJob(j.Id))
+	assert.Equal(t, "completed", s.GetJobUpdates(j.Id).Status)
+	assert.ErrorIs(t, s.PauseJob(j.Id, "tester"), storage.ErrWrongState, "completed jobs can't be paused")
+	assert.Len(t, s.GetAvailableJobs(10, nil), 0)
+}
+
+func testReleasesJobs(t *testing.T, s storage.Storage) {
+	createJobs(t, s, 3)
+	claimed := s.GetAvailableJobs(10, nil)
+	assert.Len(t, claimed, 3)
+
+	assert.NoError(t, s.ReleaseAll(claimed[:2]))
+	assert.Len(t, s.GetClaimedJobs(10, 0, nil), 1)
+	assert.Len(t, s.GetAvailableJobs(10, nil), 2)
+	assert.Len(t, s.GetClaimedJobs(10, 0, nil), 3)
+}
+
+func testGetsJobsUpdatedAt(t *testing.T, s storage.Storage) {
+	j := claimOne(t, s)
+	unclaimed := claimOneUnclaimed(t, s)
+	unknown, _ := uuid.NewV7()
+	assert.Nil(t, s.GetJobUpdates(unknown))
+
+	updatedAt := s.GetJobsUpdatedAt([]uuid.UUID{j.Id, unclaimed, unknown})
+	assert.Len(t, updatedAt, 2)
+	assert.Contains(t, updatedAt, j.Id)
+	assert.Contains(t, updatedAt, unclaimed)
+	assert.Empty(t, s.GetJobsUpdatedAt([]uuid.UUID{}))
+
+	assert.NoError(t, s.PauseJob(j.Id, "tester"))
+	assert.NotZero(t, s.GetJobsUpdatedAt([]uuid.UUID{j.Id})[j.Id])
+}
+
+// Creates a job nobody claims
+func claimOneUnclaimed(t *testing.T, s storage.Storage) uuid.UUID {
+	createJobs(t, s, 1)
+	j := s.GetAvailableJobs(1, nil)[0]
+	assert.NoError(t, s.ReleaseAll([]*job.Job{j}))
+	return j.Id
+}
+
+func finish(j *job.Job, status int, executedAt time.Time, latency time.Duration) {
+	j.LastExecution = executedAt
+	j.ShouldExecuteAt = executedAt.Add(time.Minute)
+	j.LastResponseAt = executedAt.Add(latency)
+	j.LastStatusCode = status
+	j.LastMessage = "message"
+	if j.IsSuccess(status) {
+		j.Succeeded = "ok"
+	} else {
+		j.Succeeded = "failed"
+	}
+}
+
+func testWritesExecutions(t *testing.T, s storage.Storage) {
+	j := claimOne(t, s)
+	start := time.Now().Add(-time.Minute).Truncate(time.Microsecond)
+	for i := 0; i < 3; i++ {
+		finish(j, 200+i, start.Add(time.Duration(i)*time.Second), 10*time.Millisecond)
+		assert.NoError(t, s.WriteDone(j))
+	}
+
+	executions := s.GetClaimedJobsExecutions(j.Id, 10, 0)
+	if !assert.Len(t, executions, 3) {
+		t.FailNow()
+	}
+	latest := executions[0]
+	assert.Equal(t, j.Id, latest.JobId)
+	assert.Equal(t, 202, latest.LastStatusCode, "newest executions come first")
+	assert.Equal(t, "failed", latest.Succeeded)
+	assert.Equal(t, "message", latest.LastMessage)
+	assert.Equal(t, start.Add(2*time.Second).UnixMicro(), latest.LastExecution.UnixMicro())
+	assert.Equal(t, start.Add(2*time.Second+10*time.Millisecond).UnixMicro(), latest.LastResponseAt.UnixMicro())
+	assert.Equal(t, []int{200}, latest.SuccessStatuses)
+	assert.Equal(t, config.AppName(), latest.ClaimedBy)
+	assert.Equal(t, 200, executions[2].LastStatusCode)
+	assert.Equal(t, "ok", executions[2].Succeeded)
+
+	page := s.GetClaimedJobsExecutions(j.Id, 1, 1)
+	assert.Len(t, page, 1)
+	assert.Equal(t, 201, page[0].LastStatusCode)
+
+	claimed := s.GetClaimedJobs(1, 0, nil)
+	assert.Len(t, claimed, 1)
+	assert.Equal(t, 202, claimed[0].LastStatusCode)
+	assert.Equal(t, "failed", claimed[0].Succeeded)
+	assert.Equal(t, start.Add(2*time.Second).UnixMicro(), claimed[0].LastExecution.UnixMicro())
+
+	other, _ := uuid.NewV7()
+	assert.Len(t, s.GetClaimedJobsExecutions(other, 10, 0), 0)
+
+	listed := s.ListJobExecutions(j.Id, 2, uuid.Nil)
+	if assert.Len(t, listed, 2) {
+		assert.Equal(t, latest.Id, listed[0].Id)
+		assert.Equal(t, config.AppName(), listed[0].ClaimedBy)
+		rest := s.ListJobExecutions(j.Id, 2, listed[1].Id)
+		if assert.Len(t, rest, 1, "the page starts after the cursor") {
+			assert.Equal(t, executions[2].Id, rest[0].Id)
+		}
+	}
+	assert.Len(t, s.ListJobExecutions(other, 10, uuid.Nil), 0)
+}
+
+func testBuffersResults(t *testing.T, s storage.Storage) {
+	j := claimOne(t, s)
+	s.BufferResults(10, time.Hour, "")
+
+	finish(j, 200, time.Now(), time.Millisecond)
+	assert.NoError(t, s.WriteDone(j))
+	assert.Len(t, s.GetClaimedJobsExecutions(j.Id, 10, 0), 0, "results wait in the buffer")
+
+	assert.NoError(t, s.FlushResults())
+	assert.Len(t, s.GetClaimedJobsExecutions(j.Id, 10, 0), 1)
+
+	finish(j, 200, time.Now(), time.Millisecond)
+	assert.NoError(t, s.WriteDone(j))
+	assert.Len(t, s.GetClaimedJobsExecutions(j.Id, 10, 0), 2, "results are written right away after flushing")
+}
+
+func testRunsJobsOnRequest(t *testing.T, s storage.Storage) {
+	unclaimed := claimOneUnclaimed(t, s)
+	_, err := s.RequestRun(unclaimed)
+	assert.ErrorIs(t, err, storage.ErrNotClaimed)
+	unknown, _ := uuid.NewV7()
+	_, err = s.RequestRun(unknown)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	j := s.GetAvailableJobs(1, nil)[0]
+	ch := listen(t, s)
+	runId, err := s.RequestRun(j.Id)
+	assert.NoError(t, err)
+	assert.NotEqual(t, uuid.Nil, runId)
+	n := expectNotification(t, ch, storage.EventRun, j.Id)
+	assert.Equal(t, runId, n.RunId)
+	assert.Nil(t, s.GetRunResult(runId))
+
+	j.RunId = runId
+	j.Trigger = job.TriggerManual
+	finish(j, 200, time.Now(), time.Millisecond)
+	assert.NoError(t, s.WriteDone(j))
+
+	result := s.GetRunResult(runId)
+	if !assert.NotNil(t, result) {
+		t.FailNow()
+	}
+	assert.Equal(t, 200, result.Status)
+	assert.Equal(t, "message", result.Message)
+	executions := s.GetClaimedJobsExecutions(j.Id, 10, 0)
+	assert.Len(t, executions, 1)
+	assert.Equal(t, job.TriggerManual, executions[0].Trigger)
+
+	assert.NoError(t, s.DeleteJob(j.Id, "tester"))
+	_, err = s.RequestRun(j.Id)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func testCompactsResults(t *testing.T, s storage.Storage) {
+	j := claimOne(t, s)
+	day := time.Now().UTC().AddDate(0, 0, -3).Truncate(24 * time.Hour)
+	latencies := []time.Duration{10, 20, 30, 40}
+	for i, latency := range latencies {
+		status := 200
+		if i == 0 {
+			status = 500
+		}
+		finish(j, status, day.Add(time.Hour+time.Duration(i)*time.Minute), latency*time.Millisecond)
+		assert.NoError(t, s.WriteDone(j))
+	}
+	finish(j, 200, time.Now().Add(-time.Minute), 50*time.Millisecond)
+	assert.NoError(t, s.WriteDone(j))
+
+	before := s.GetResultAggregates(j.Id, storage.GranularityDay, day, time.Now())
+	compacted, err := s.CompactResults(1, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(latencies)), compacted)
+	assert.Len(t, s.GetClaimedJobsExecutions(j.Id, 10, 0), 1, "recent results are kept")
+
+	after := s.GetResultAggregates(j.Id, storage.GranularityDay, day, time.Now())
+	assert.Equal(t, before, after, "aggregates don't change when results are compacted")
+	if !assert.GreaterOrEqual(t, len(after), 2) {
+		t.FailNow()
+	}
+	old := after[0]
+	assert.Equal(t, day, old.BucketStart)
+	assert.Equal(t, storage.GranularityDay, old.Granularity)
+	assert.Equal(t, 4, old.Executions)
+	assert.Equal(t, 1, old.Failures)
+	assert.Equal(t, int64(25000), *old.P50LatencyMicro)
+
+	hourly := s.GetResultAggregates(j.Id, storage.GranularityHour, day, day.Add(24*time.Hour))
+	assert.Len(t, hourly, 1)
+	assert.Equal(t, day.Add(time.Hour), hourly[0].BucketStart)
+	assert.Equal(t, 4, hourly[0].Executions)
+
+	compacted, err = s.CompactResults(1, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), compacted)
+
+	assert.Nil(t, s.GetResultAggregates(j.Id, "minute", day, time.Now()))
+}
+
+func testMaintenanceWindows(t *testing.T, s storage.Storage) {
+	j := claimOne(t, s)
+	now := time.Now().Truncate(time.Microsecond)
+	inputs := []storage.CreateMaintenanceWindowInput{
+		{Name: "nightly", Mode: maintenance.Skip, CronExpString: "0 3 * * *", Timezone: "Europe/Madrid", DurationSeconds: 3600, Selector: "zone=eu,!legacy"},
+		{Name: "upgrade", Mode: maintenance.Mute, StartsAt: now, EndsAt: now.Add(time.Hour), JobIds: []uuid.UUID{j.Id}},
+		{Name: "over", Mode: maintenance.Skip, StartsAt: now.Add(-2 * time.Hour), EndsAt: now.Add(-time.Hour)},
+	}
+	for _, input := range inputs {
+		assert.NoError(t, s.CreateMaintenanceWindow(input))
+	}
+
+	windows := s.GetMaintenanceWindows()
+	if !assert.Len(t, windows, 2, "windows that ended are not listed") {
+		t.FailNow()
+	}
+	nightly, upgrade := windows[0], windows[1]
+	assert.Equal(t, "nightly", nightly.Name)
+	assert.Equal(t, maintenance.Skip, nightly.Mode)
+	assert.Equal(t, "0 3 * * *", nightly.CronExpString)
+	assert.Equal(t, "Europe/Madrid", nightly.Timezone)
+	assert.Equal(t, 3600, nightly.DurationSeconds)
+	assert.Empty(t, nightly.JobIds)
+	assert.Equal(t, "zone=eu,!legacy", nightly.Selector)
+	assert.Equal(t, "upgrade", upgrade.Name)
+	assert.Equal(t, maintenance.Mute, upgrade.Mode)
+	assert.Equal(t, "UTC", upgrade.Timezone)
+	assert.Equal(t, now.UnixMicro(), upgrade.StartsAt.UnixMicro())
+	assert.Equal(t, now.Add(time.Hour).UnixMicro(), upgrade.EndsAt.UnixMicro())
+	assert.Equal(t, []uuid.UUID{j.Id}, upgrade.JobIds)
+	assert.Empty(t, upgrade.Selector)
+
+	assert.NoError(t, s.DeleteMaintenanceWindow(nightly.Id))
+	assert.ErrorIs(t, s.DeleteMaintenanceWindow(nightly.Id), storage.ErrNotFound)
+	unknown, _ := uuid.NewV7()
+	assert.ErrorIs(t, s.DeleteMaintenanceWindow(unknown), storage.ErrNotFound)
+	windows = s.GetMaintenanceWindows()
+	assert.Len(t, windows, 1)
+	assert.Equal(t, upgrade.Id, windows[0].Id)
+}
+
+func testStopsListening(t *testing.T, s storage.Storage) {
+	assert.False(t, s.ListenerHealth().Started)
+	ch := make(chan storage.Notification)
+	ctx, cancel := context.WithCancel(context.Background())
+	s.ListenForChanges(ch, ctx)
+	assert.Eventually(t, func() bool { return s.ListenerHealth().Connected }, 5*time.Second, 10*time.Millisecond)
+	assert.True(t, s.Connected())
+
+	assert.NoError(t, s.StopListeningForChanges())
+	cancel()
+	select {
+	case _, ok := <-ch:
+		assert.False(t, ok, "the channel is closed")
+	case <-time.After(5 * time.Second):
+		t.Fatal("the channel was not closed after we stopped listening")
+	}
+}
+
+func createLocatedJob(t *testing.T, s storage.Storage, name string, locations []string, quorum int) {
+	_, err := s.CreateJob(storage.CreateJobInput{
+		Name:            name,
+		CronExpString:   "*/1 * * * *",
+		Endpoint:        "http://localhost/",
+		HttpMethod:      "GET",
+		SuccessStatuses: []int{200},
+		Locations:       locations,
+		AlertQuorum:     quorum,
+	})
+	if err != nil {
+		t.Fatalf("could not create job: %q", err.Error())
+	}
+}
+
+func testClaimsJobsPerLocation(t *testing.T, s storage.Storage) {
+	here := config.Location()
+	if here == "" {
+		t.Skip("the scheduler running the suite has no location")
+	}
+	createLocatedJob(t, s, "here and there", []string{"there", here}, 2)
+	createLocatedJob(t, s, "only there", []string{"there"}, 0)
+
+	jobs := s.GetAvailableJobs(10, nil)
+	if !assert.Len(t, jobs, 1, "jobs of other locations are left to their schedulers") {
+		t.FailNow()
+	}
+	j := jobs[0]
+	assert.Equal(t, "here and there", j.Name)
+	assert.Equal(t, here, j.Location)
+	assert.Equal(t, []string{"there", here}, j.Locations)
+	assert.Equal(t, 2, j.AlertQuorum)
+	assert.Len(t, s.GetAvailableJobs(10, nil), 0, "a location is claimed once")
+
+	claimed := s.GetClaimedJobs(10, 0, nil)
+	if assert.Len(t, claimed, 1) {
+		assert.Equal(t, here, claimed[0].Location)
+	}
+	updates := s.GetJobUpdates(j.Id)
+	assert.Equal(t, []string{"there", here}, updates.Locations)
+	assert.Equal(t, 2, updates.Alert_quorum)
+
+	finish(j, 200, time.Now(), time.Millisecond)
+	assert.NoError(t, s.WriteDone(j))
+	executions := s.GetClaimedJobsExecutions(j.Id, 10, 0)
+	if assert.Len(t, executions, 1) {
+		assert.Equal(t, here, executions[0].Location)
+	}
+
+	assert.NoError(t, s.ReleaseAll([]*job.Job{j}))
+	assert.Len(t, s.GetClaimedJobs(10, 0, nil), 0)
+	assert.Len(t, s.GetAvailableJobs(10, nil), 1, "released locations can be claimed again")
+
+	ch := listen(t, s)
+	_, err := s.RequestRun(j.Id)
+	assert.NoError(t, err, "located jobs run from a scheduler that claimed them")
+	expectNotification(t, ch, storage.EventRun, j.Id)
+
+	assert.NoError(t, s.PauseJob(j.Id, "tester"))
+	expectNotification(t, ch, storage.EventPaused, j.Id)
+	assert.Len(t, s.GetClaimedJobs(10, 0, nil), 0, "paused jobs release their locations")
+	assert.Len(t, s.GetAvailableJobs(10, nil), 0)
+
+	assert.NoError(t, s.ResumeJob(j.Id, "tester"))
+	expectNotification(t, ch, storage.EventUpdated, j.Id)
+	assert.Len(t, s.GetAvailableJobs(10, nil), 1)
+}
+
+func testCountsFailingLocations(t *testing.T, s storage.Storage) {
+	createLocatedJob(t, s, "probed", []string{"madrid", "paris", "tokyo"}, 2)
+	ids := s.GetJobIds(nil)
+	if !assert.Len(t, ids, 1) {
+		t.FailNow()
+	}
+	round := time.Now().Add(-time.Minute).Truncate(time.Second)
+	record := func(location string, failed bool, executedAt time.Time) int {
+		failing, err := s.RecordLocationResult(storage.LocationResult{
+			JobId:      ids[0],
+			Location:   location,
+			Failed:     failed,
+			ExecutedAt: executedAt,
+		}, round)
+		assert.NoError(t, err)
+		return failing
+	}
+
+	assert.Equal(t, 1, record("madrid", true, round))
+	assert.Equal(t, 1, record("paris", false, round))
+	assert.Equal(t, 2, record("tokyo", true, round.Add(time.Second)))
+	assert.Equal(t, 1, record("madrid", false, round.Add(time.Second)), "only the last execution of a location counts")
+	assert.Equal(t, 1, record("paris", true, round.Add(-time.Second)), "executions of an older round are left out")
+	assert.Equal(t, 1, record("lisbon", true, round), "locations the job doesn't have are left out")
+}
+
+func testManagesAPIKeys(t *testing.T, s storage.Storage) {
+	_, prefix, hash, err := auth.GenerateKey()
+	assert.NoError(t, err)
+	created, err := s.CreateAPIKey(storage.CreateAPIKeyInput{Name: "deploys", Scope: auth.WriteScope, Prefix: prefix, Hash: hash})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.NotEqual(t, uuid.Nil, created.Id)
+	assert.NotZero(t, created.CreatedAt)
+
+	found, err := s.GetAPIKey(hash)
+	if assert.NoError(t, err) {
+		assert.Equal(t, created.Id, found.Id)
+		assert.Equal(t, "deploys", found.Name)
+		assert.Equal(t, prefix, found.Prefix)
+		assert.Equal(t, auth.WriteScope, found.Scope)
+	}
+	_, err = s.GetAPIKey(auth.HashKey("ruok_unknown"))
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	assert.NoError(t, s.RevokeAPIKey(created.Id))
+	_, err = s.GetAPIKey(hash)
+	assert.ErrorIs(t, err, storage.ErrNotFound, "revoked keys can't be used")
+	assert.ErrorIs(t, s.RevokeAPIKey(created.Id), storage.ErrNotFound)
+
+	keys := s.ListAPIKeys()
+	if assert.Len(t, keys, 1, "revoked keys are still listed") {
+		assert.NotZero(t, keys[0].RevokedAt)
+	}
+}
+
+func testRecordsJobHistory(t *testing.T, s storage.Storage) {
+	_, err := s.CreateJob(storage.CreateJobInput{
+		Name:            "audited",
+		CronExpString:   "*/1 * * * *",
+		MaxRetries:      1,
+		Endpoint:        "http://localhost/",
+		HttpMethod:      "GET",
+		SuccessStatuses: []int{200},
+		UpdatedBy:       "key:creator",
+	})
+	claimed := s.GetAvailableJobs(1, nil)
+	if !assert.NoError(t, err) || !assert.Len(t, claimed, 1) {
+		t.FailNow()
+	}
+	j := claimed[0]
+
+	assert.NoError(t, s.UpdateJob(storage.UpdateJobInput{
+		Id:              j.Id,
+		Name:            "renamed",
+		CronExpString:   "*/5 * * * *",
+		MaxRetries:      1,
+		Endpoint:        "http://localhost/",
+		HttpMethod:      "GET",
+		SuccessStatuses: []int{200},
+		UpdatedBy:       "key:editor",
+	}))
+	assert.NoError(t, s.UpdateJobAlerts(j.Id, storage.JobAlertsInput{
+		AlertStrategy: "default",
+		AlertMethod:   "POST",
+		AlertEndpoint: "http://localhost/alerts",
+	}))
+	assert.NoError(t, s.PauseJob(j.Id, "key:editor"))
+	assert.NoError(t, s.ResumeJob(j.Id, "key:editor"))
+	assert.NoError(t, s.DeleteJob(j.Id, "key:admin"))
+
+	history := s.GetJobHistory(j.Id, 10, 0)
+	if !assert.Len(t, history, 6) {
+		t.FailNow()
+	}
+	actions := []string{}
+	for i, r := range history {
+		assert.Equal(t, 6-i, r.Revision, "the newest revision comes first")
+		assert.Equal(t, j.Id, r.JobId)
+		assert.NotZero(t, r.CreatedAt)
+		actions = append(actions, r.Action)
+	}
+	assert.Equal(t, []string{
+		job.ActionDelete,
+		job.ActionResume,
+		job.ActionPause,
+		job.ActionAlerts,
+		job.ActionUpdate,
+		job.ActionCreate,
+	}, actions)
+
+	created := history[5]
+	assert.Equal(t, "key:creator", created.Actor)
+	assert.Nil(t, created.Before)
+	assert.Equal(t, "audited", created.After.Name)
+	assert.NotEmpty(t, created.Changes, "every field changes when a job is created")
+
+	updated := history[4]
+	assert.Equal(t, "key:editor", updated.Actor)
+	assert.Equal(t, []job.FieldChange{
+		{Field: "cronexp", Before: "*/1 * * * *", After: "*/5 * * * *"},
+		{Field: "name", Before: "audited", After: "renamed"},
+	}, updated.Changes)
+
+	assert.Equal(t, "key:admin", history[0].Actor)
+	assert.True(t, history[0].After.Deleted)
+
+	revision, err := s.GetJobRevision(j.Id, 2)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "renamed", revision.Name)
+		assert.Equal(t, "*/5 * * * *", revision.CronExpString)
+	}
+	_, err = s.GetJobRevision(j.Id, 42)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	page := s.GetJobHistory(j.Id, 2, 1)
+	if assert.Len(t, page, 2) {
+		assert.Equal(t, 5, page[0].Revision)
+		assert.Equal(t, 4, page[1].Revision)
+	}
+
+	// restoring brings deleted jobs back as they were after the revision
+	assert.NoError(t, s.RestoreJob(j.Id, 1, "key:admin"))
+	updates := s.GetJobUpdates(j.Id)
+	assert.Equal(t, "audited", updates.Job_name)
+	assert.Equal(t, "*/1 * * * *", updates.Cron_exp_string)
+	assert.Equal(t, "pending to be claimed", updates.Status)
+	assert.Zero(t, updates.Deleted_at)
+	assert.Empty(t, updates.Alert_endpoint)
+	assert.Len(t, s.GetAvailableJobs(10, nil), 1)
+
+	restored := s.GetJobHistory(j.Id, 1, 0)
+	if assert.Len(t, restored, 1) {
+		assert.Equal(t, 7, restored[0].Revision)
+		assert.Equal(t, job.ActionRestore, restored[0].Action)
+		assert.Equal(t, "key:admin", restored[0].Actor)
+		assert.True(t, restored[0].Before.Deleted)
+		assert.False(t, restored[0].After.Deleted)
+	}
+
+	// paused revisions come back paused
+	assert.NoError(t, s.RestoreJob(j.Id, 4, "key:admin"))
+	updates = s.GetJobUpdates(j.Id)
+	assert.Equal(t, "paused", updates.Status)
+	assert.Equal(t, "renamed", updates.Job_name)
+	assert.Equal(t, "http://localhost/alerts", updates.Alert_endpoint)
+
+	assert.ErrorIs(t, s.RestoreJob(j.Id, 6, "key:admin"), storage.ErrDeletedRevision)
+	assert.ErrorIs(t, s.RestoreJob(j.Id, 42, "key:admin"), storage.ErrNotFound)
+	unknown, _ := uuid.NewV7()
+	assert.ErrorIs(t, s.RestoreJob(unknown, 1, "key:admin"), storage.ErrNotFound)
+	assert.Empty(t, s.GetJobHistory(unknown, 10, 0))
+	assert.ErrorIs(t, s.UpdateJob(storage.UpdateJobInput{Id: unknown, Name: "missing"}), storage.ErrNotFound)
+}
+
+func testIsolatesTenants(t *testing.T, s storage.Storage) {
+	acme := s.ForTenant("acme")
+	globex := s.ForTenant("globex")
+	for name, tenant := range map[string]storage.APIStorage{"acme job": acme, "globex job": globex, "default job": s} {
+		_, err := tenant.CreateJob(storage.CreateJobInput{
+			Name:            name,
+			CronExpString:   "*/1 * * * *",
+			MaxRetries:      1,
+			Endpoint:        "http://localhost/",
+			HttpMethod:      "GET",
+			SuccessStatuses: []int{200},
+		})
+		assert.NoError(t, err)
+	}
+	// schedulers see every tenant
+	if !assert.Len(t, s.GetAvailableJobs(10, nil), 3) {
+		t.FailNow()
+	}
+	assert.Len(t, s.GetClaimedJobs(10, 0, nil), 3)
+
+	listed := acme.GetClaimedJobs(10, 0, nil)
+	if !assert.Len(t, listed, 1) || !assert.Equal(t, "acme job", listed[0].Name) {
+		t.FailNow()
+	}
+	acmeJob := listed[0].Id
+	globexListed := globex.GetClaimedJobs(10, 0, nil)[0]
+	globexJob := globexListed.Id
+	assert.Equal(t, []uuid.UUID{acmeJob}, acme.GetJobIds(nil))
+	assert.Len(t, s.ForTenant(auth.DefaultTenant).GetJobIds(nil), 1, "jobs created without a tenant belong to the default one")
+
+	count, err := acme.CountJobs()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+	count, err = s.CountJobs()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	// jobs of other tenants look like they don't exist
+	assert.ErrorIs(t, acme.UpdateJob(storage.UpdateJobInput{
+		Id:              globexJob,
+		Name:            "taken over",
+		CronExpString:   "*/1 * * * *",
+		MaxRetries:      1,
+		Endpoint:        "http://localhost/",
+		HttpMethod:      "GET",
+		SuccessStatuses: []int{200},
+	}), storage.ErrNotFound)
+	assert.ErrorIs(t, acme.UpdateJobAlerts(globexJob, storage.JobAlertsInput{}), storage.ErrNotFound)
+	assert.ErrorIs(t, acme.PauseJob(globexJob, ""), storage.ErrNotFound)
+	assert.ErrorIs(t, acme.DeleteJob(globexJob, ""), storage.ErrNotFound)
+	assert.ErrorIs(t, acme.RestoreJob(globexJob, 1, ""), storage.ErrNotFound)
+	_, err = acme.RequestRun(globexJob)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	assert.Empty(t, acme.GetJobHistory(globexJob, 10, 0))
+	assert.NotEmpty(t, globex.GetJobHistory(globexJob, 10, 0))
+
+	// compacted results keep the tenant of their job
+	day := time.Now().UTC().AddDate(0, 0, -3).Truncate(24 * time.Hour)
+	finish(globexListed, 200, day.Add(time.Hour), 10*time.Millisecond)
+	assert.NoError(t, s.WriteDone(globexListed))
+	_, err = s.CompactResults(1, 0)
+	assert.NoError(t, err)
+	assert.Empty(t, acme.GetResultAggregates(globexJob, storage.GranularityDay, day, time.Now()))
+	assert.Len(t, globex.GetResultAggregates(globexJob, storage.GranularityDay, day, time.Now()), 1)
+	assert.NoError(t, globex.PauseJob(globexJob, ""))
+	assert.NoError(t, acme.PauseJob(acmeJob, ""))
+
+	// keys only list and revoke within their tenant, but are found from every tenant to authenticate
+	_, prefix, hash, err := auth.GenerateKey()
+	assert.NoError(t, err)
+	key, err := acme.CreateAPIKey(storage.CreateAPIKeyInput{Name: "acme", Scope: auth.ReadScope, Prefix: prefix, Hash: hash, Tenant: "acme"})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, "acme", key.Tenant)
+	found, err := s.GetAPIKey(hash)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "acme", found.Tenant)
+	}
+	assert.Len(t, acme.ListAPIKeys(), 1)
+	assert.Empty(t, globex.ListAPIKeys())
+	assert.ErrorIs(t, globex.RevokeAPIKey(key.Id), storage.ErrNotFound)
+	assert.NoError(t, acme.RevokeAPIKey(key.Id))
+}
+
+func testLimitsJobsPerTenant(t *testing.T, s storage.Storage) {
+	acme := s.ForTenant("acme").WithMaxJobs(3)
+	create := func(s storage.APIStorage) error {
+		_, err := s.CreateJob(storage.CreateJobInput{
+			Name:            "limited",
+			CronExpString:   "*/1 * * * *",
+			MaxRetries:      1,
+			Endpoint:        "http://localhost/",
+			HttpMethod:      "GET",
+			SuccessStatuses: []int{200},
+		})
+		return err
+	}
+	assert.NoError(t, create(s.ForTenant("globex")), "jobs of other tenants don't count")
+
+	// the count and the insert can't interleave, so racing creates stop right at the limit
+	errs := make(chan error, 6)
+	for i := 0; i < 6; i++ {
+		go func() { errs <- create(acme) }()
+	}
+	refused := 0
+	for i := 0; i < 6; i++ {
+		if err := <-errs; err != nil {
+			assert.ErrorIs(t, err, storage.ErrQuotaExceeded)
+			assert.ErrorIs(t, err, storage.ErrForbidden)
+			refused++
+		}
+	}
+	assert.Equal(t, 3, refused)
+	count, err := acme.CountJobs()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+	assert.NoError(t, create(s.ForTenant("acme")), "storages without a limit don't check it")
+
+	// deleted jobs only come back while there is room for them
+	ids := acme.GetJobIds(nil)
+	assert.NoError(t, acme.DeleteJob(ids[0], ""))
+	assert.NoError(t, acme.DeleteJob(ids[1], ""))
+	assert.NoError(t, acme.RestoreJob(ids[0], 1, ""))
+	assert.ErrorIs(t, acme.RestoreJob(ids[1], 1, ""), storage.ErrQuotaExceeded)
+	assert.NoError(t, acme.RestoreJob(ids[0], 1, ""), "restoring a job that is not deleted doesn't add one")
+	assert.Equal(t, "deleted", s.GetJobUpdates(ids[1]).Status)
+}
@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"context"
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+
+	"github.com/back-end-labs/ruok/pkg/auth"
+)
+
+// Key of the context where the tenant of the requests is kept, see SQLStorage.tenantContext
+type tenantKey struct{}
+
+// Tenant each connection of the pool has in "ruok.tenant", so it's only set again when it changes
+var connTenants sync.Map
+
+// Returns a storage that only sees and changes the jobs of the tenant, the jobs it creates belong to it.
+// An empty tenant sees every one of them and creates jobs in auth.DefaultTenant.
+// With postgres, the RLS policies of the tenant keep the rows of the others out.
+func (sqls *SQLStorage) ForTenant(tenant string) APIStorage {
+	scoped := *sqls
+	scoped.tenant = tenant
+	return &scoped
+}
+
+// Context for the queries of the storage, with the tenant they must be limited to
+func (sqls *SQLStorage) tenantContext() context.Context {
+	return context.WithValue(context.Background(), tenantKey{}, sqls.tenant)
+}
+
+// Sets "ruok.tenant" on connections as they are taken from the pool, so RLS policies can read it.
+// Connections that can't be set are destroyed instead of used with the tenant of somebody else.
+func setConnTenant(ctx context.Context, conn *pgx.Conn) bool {
+	tenant, _ := ctx.Value(tenantKey{}).(string)
+	if current, ok := connTenants.Load(conn); ok && current == tenant {
+		return true
+	}
+	if _, err := conn.Exec(ctx, "SELECT set_config('ruok.tenant', $1, false)", tenant); err != nil {
+		log.Error().Err(err).Msg("could not set the tenant of a connection")
+		connTenants.Delete(conn)
+		return false
+	}
+	connTenants.Store(conn, tenant)
+	return true
+}
+
+func forgetConnTenant(conn *pgx.Conn) {
+	connTenants.Delete(conn)
+}
+
+// Tenant of the jobs created through the storage
+func tenantOrDefault(tenant string) string {
+	if tenant == "" {
+		return auth.DefaultTenant
+	}
+	return tenant
+}
+
+// Returns a storage that refuses to create or bring back jobs once its tenant has "max" of them, 0 has no limit.
+// The jobs are counted in the same transaction that adds the job, so concurrent requests can't go over it.
+func (sqls *SQLStorage) WithMaxJobs(max int) APIStorage {
+	limited := *sqls
+	limited.maxJobs = max
+	return &limited
+}
+
+// Returns ErrQuotaExceeded if adding a job goes over maxJobs. Until the transaction ends, other transactions
+// adding jobs to the tenant wait, so they count the job too.
+func (sqls *SQLStorage) checkMaxJobs(ctx context.Context, tx pgx.Tx) error {
+	if sqls.maxJobs == 0 {
+		return nil
+	}
+	if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock(hashtext('ruok.jobs_quota'), hashtext($1))", sqls.tenant); err != nil {
+		return err
+	}
+	var count int
+	if err := tx.QueryRow(ctx, "SELECT ruok.count_tenant_jobs()").Scan(&count); err != nil {
+		return err
+	}
+	if count >= sqls.maxJobs {
+		return ErrQuotaExceeded
+	}
+	return nil
+}
+
+// TRUE when row level security lets the database user see the jobs claimed by other schedulers too,
+// which RUOK_SCHEDULER_ROLE alone doesn't. Jobs of other tenants are still hidden from tenant storages.
+func (sqls *SQLStorage) SeesEveryJob() (bool, error) {
+	var sees bool
+	err := sqls.Db.QueryRow(sqls.tenantContext(), `
+SELECT r.rolsuper OR r.rolbypassrls
+	OR pg_has_role(c.relowner, 'USAGE')
+	OR pg_has_role('ruok_jobs_manager', 'USAGE')
+	OR pg_has_role('admin', 'USAGE')
+FROM pg_catalog.pg_roles r, pg_catalog.pg_class c
+WHERE r.rolname = current_user AND c.oid = 'ruok.jobs'::regclass;
+`).Scan(&sees)
+	if err != nil {
+		log.Error().Err(err).Msg("could not check the roles of the database user")
+		return false, err
+	}
+	return sees, nil
+}
+
+// Jobs of the tenant that are not deleted
+func (sqls *SQLStorage) CountJobs() (int, error) {
+	var count int
+	err := sqls.Db.QueryRow(sqls.tenantContext(), "SELECT ruok.count_tenant_jobs()").Scan(&count)
+	if err != nil {
+		log.Error().Err(err).Msg("could not count the jobs of the tenant")
+		return 0, err
+	}
+	return count, nil
+}
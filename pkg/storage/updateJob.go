@@ -1,12 +1,12 @@
 package storage
 
 import (
-	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
 
 	"github.com/back-end-labs/ruok/pkg/config"
+	"github.com/back-end-labs/ruok/pkg/job"
 	"github.com/gofrs/uuid"
 	"github.com/rs/zerolog/log"
 )
@@ -15,6 +15,7 @@ type UpdateJobInput struct {
 	Id              uuid.UUID         `json:"id"`
 	Name            string            `json:"name"`
 	CronExpString   string            `json:"cronexp"`
+	Timezone        string            `json:"timezone"`
 	MaxRetries      int               `json:"maxRetries"`
 	Endpoint        string            `json:"endpoint"`
 	HttpMethod      string            `json:"httpmethod"`
@@ -25,6 +26,16 @@ type UpdateJobInput struct {
 	AlertEndpoint   string            `json:"alertEndpoint"`
 	AlertPayload    string            `json:"alertPayload"`
 	AlertHeaders    map[string]string `json:"alertHeaders"`
+	// Replaces every label of the job
+	Labels map[string]string `json:"labels"`
+	// Replaces every location of the job, schedulers of the locations that are gone release it
+	Locations []string `json:"locations"`
+	// How many locations must fail the same round before alerting. 0 alerts on any failure
+	AlertQuorum int `json:"alertQuorum"`
+	// Days results are kept before being compacted. Empty uses the global setting, 0 keeps them forever
+	ResultsRetentionDays *int `json:"resultsRetentionDays"`
+	// Who is changing the job, taken from the caller and never from the body
+	UpdatedBy string `json:"-"`
 }
 
 var updateJobQuery = `
@@ -35,18 +46,24 @@ UPDATE ruok.jobs SET
 	httpmethod = $4,
 	max_retries = $5,
 	success_statuses = $6,
-	status = $7,
+	status = CASE WHEN status = 'paused' THEN status ELSE $7 END,
 	alert_strategy = $8,
 	alert_endpoint = $9,
 	alert_method = $10,
 	alert_headers_string = $11,
 	alert_payload = $12,
+	timezone = $13,
+	results_retention_days = $14,
+	labels = $15,
+	locations = $16,
+	alert_quorum = $17,
+	updated_by = $18,
 	updated_at = ruok.micro_unix_now()
-WHERE id = $13;
+WHERE id = $19 AND deleted_at IS NULL;
 `
 
 func (sqls *SQLStorage) UpdateJob(j UpdateJobInput) error {
-	ctx := context.Background()
+	ctx := sqls.tenantContext()
 	tx, err := sqls.Db.Begin(ctx)
 	defer tx.Rollback(ctx)
 
@@ -55,6 +72,15 @@ func (sqls *SQLStorage) UpdateJob(j UpdateJobInput) error {
 		return errors.New("could not update job")
 	}
 
+	before, err := jobSnapshot(ctx, tx, j.Id)
+	if errors.Is(err, ErrNotFound) || (err == nil && before.Deleted) {
+		return ErrNotFound
+	}
+	if err != nil {
+		log.Error().Err(err).Msgf("could not get job %v to update it", j.Id)
+		return errors.New("could not update job")
+	}
+
 	var alertPayload sql.NullString
 	if j.AlertPayload != "" {
 		alertPayload.String = j.AlertPayload
@@ -85,6 +111,12 @@ func (sqls *SQLStorage) UpdateJob(j UpdateJobInput) error {
 		j.AlertMethod,
 		alertHeadersString,
 		alertPayload,
+		TimezoneOrDefault(j.Timezone),
+		j.ResultsRetentionDays,
+		labelsJSON(j.Labels),
+		locationsOrEmpty(j.Locations),
+		nullQuorum(j.AlertQuorum),
+		nullString(j.UpdatedBy),
 		j.Id,
 	)
 
@@ -93,7 +125,20 @@ func (sqls *SQLStorage) UpdateJob(j UpdateJobInput) error {
 		return errors.New("could not update job")
 	}
 
-	_, err = tx.Exec(ctx, "select pg_notify($1, $2)", config.AppName(), j.Id.String())
+	if err := recordRevision(ctx, tx, j.Id, job.ActionUpdate, j.UpdatedBy, before); err != nil {
+		log.Error().Err(err).Msgf("could not record update of job %v", j.Id)
+		return errors.New("could not update job")
+	}
+
+	// schedulers running a location of the job have to refresh it too
+	owners, err := locationOwners(ctx, tx, j.Id)
+
+	if err != nil {
+		log.Error().Err(err).Msg("could not get locations of updated job")
+		return errors.New("could not update job")
+	}
+
+	err = notifyAll(ctx, tx, append([]string{config.AppName()}, owners...), NewNotification(EventUpdated, j.Id))
 
 	if err != nil {
 		log.Error().Err(err).Msg("could not notify updated job")
@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/gofrs/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+
+	"github.com/back-end-labs/ruok/pkg/config"
+	"github.com/back-end-labs/ruok/pkg/job"
+)
+
+// Where the alerts of a job are sent, the rest of the job is kept as it is
+type JobAlertsInput struct {
+	AlertStrategy string            `json:"alertStrategy"`
+	AlertMethod   string            `json:"alertMethod"`
+	AlertEndpoint string            `json:"alertEndpoint"`
+	AlertPayload  string            `json:"alertPayload"`
+	AlertHeaders  map[string]string `json:"alertHeaders"`
+	// Who is changing the alerts, taken from the caller and never from the body
+	UpdatedBy string `json:"-"`
+}
+
+var updateJobAlertsQuery = `
+UPDATE ruok.jobs SET
+	alert_strategy = $1,
+	alert_endpoint = $2,
+	alert_method = $3,
+	alert_headers_string = $4,
+	alert_payload = $5,
+	updated_by = $6,
+	updated_at = ruok.micro_unix_now()
+WHERE id = $7 AND deleted_at IS NULL
+RETURNING claimed_by;
+`
+
+// Changes where the alerts of a job are sent and lets its owner know
+func (sqls *SQLStorage) UpdateJobAlerts(jobId uuid.UUID, a JobAlertsInput) error {
+	ctx := sqls.tenantContext()
+	tx, err := sqls.Db.Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("could not start transaction to update job alerts")
+		return errors.New("could not update job alerts")
+	}
+	defer tx.Rollback(ctx)
+
+	before, err := jobSnapshot(ctx, tx, jobId)
+	if errors.Is(err, ErrNotFound) {
+		return ErrNotFound
+	}
+	if err != nil {
+		log.Error().Err(err).Msgf("could not get job %v to update its alerts", jobId)
+		return errors.New("could not update job alerts")
+	}
+
+	var owner sql.NullString
+	err = tx.QueryRow(ctx, updateJobAlertsQuery,
+		a.AlertStrategy,
+		a.AlertEndpoint,
+		a.AlertMethod,
+		nullJSON(a.AlertHeaders),
+		nullString(a.AlertPayload),
+		nullString(a.UpdatedBy),
+		jobId,
+	).Scan(&owner)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotFound
+	}
+
+	if err != nil {
+		log.Error().Err(err).Msgf("could not update alerts of job %v", jobId)
+		return errors.New("could not update job alerts")
+	}
+
+	if err := recordRevision(ctx, tx, jobId, job.ActionAlerts, a.UpdatedBy, before); err != nil {
+		log.Error().Err(err).Msgf("could not record alerts update of job %v", jobId)
+		return errors.New("could not update job alerts")
+	}
+
+	channel := config.AppName()
+	if owner.Valid {
+		channel = owner.String
+	}
+
+	owners, err := locationOwners(ctx, tx, jobId)
+
+	if err != nil {
+		log.Error().Err(err).Msgf("could not get locations of job %v", jobId)
+		return errors.New("could not update job alerts")
+	}
+
+	err = notifyAll(ctx, tx, append([]string{channel}, owners...), NewNotification(EventUpdated, jobId))
+
+	if err != nil {
+		log.Error().Err(err).Msgf("could not notify updated alerts of job %v", jobId)
+		return errors.New("could not notify updated job alerts")
+	}
+
+	err = tx.Commit(ctx)
+
+	if err != nil {
+		log.Error().Err(err).Msg("could not commit transaction to update job alerts")
+		return errors.New("could not commit transaction to update job alerts")
+	}
+	return nil
+}
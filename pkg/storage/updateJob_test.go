@@ -3,14 +3,12 @@ package storage
 import (
 	"testing"
 
-	"github.com/back-end-labs/ruok/pkg/config"
 	"github.com/back-end-labs/ruok/pkg/job"
 	"github.com/gofrs/uuid"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestUpdateJob(t *testing.T) {
-	defer Drop()
 	id, _ := uuid.NewV7()
 
 	tests := []struct {
@@ -75,10 +73,6 @@ func TestUpdateJob(t *testing.T) {
 		},
 	}
 
-	cfg := config.FromEnvs()
-	s, close := NewStorage(&cfg)
-	defer close()
-
 	// Create a job to update
 	initialJob := CreateJobInput{
 		CronExpString:   "*/2 * * * *",
@@ -88,32 +82,34 @@ func TestUpdateJob(t *testing.T) {
 		SuccessStatuses: []int{200},
 	}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			Drop()
+	forEachStorage(t, func(t *testing.T, s *testStorage) {
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				s.drop()
 
-			err := s.CreateJob(initialJob)
-			assert.NoError(t, err, "failed to create initial job")
+				_, err := s.CreateJob(initialJob)
+				assert.NoError(t, err, "failed to create initial job")
 
-			jobs := s.GetAvailableJobs(1)
-			assert.Len(t, jobs, 1)
+				jobs := s.GetAvailableJobs(1, nil)
+				assert.Len(t, jobs, 1)
 
-			tt.job.Id = jobs[0].Id
-			err = s.UpdateJob(tt.job)
+				tt.job.Id = jobs[0].Id
+				err = s.UpdateJob(tt.job)
 
-			if tt.expectErr {
-				assert.Error(t, err, "expected an error, but got none")
-			} else {
-				assert.NoError(t, err, "expected no error, but got one")
-			}
+				if tt.expectErr {
+					assert.Error(t, err, "expected an error, but got none")
+				} else {
+					assert.NoError(t, err, "expected no error, but got one")
+				}
 
-			updatedJobs := s.GetAvailableJobs(1)
+				updatedJobs := s.GetAvailableJobs(1, nil)
 
-			assert.Len(t, updatedJobs, 1)
+				assert.Len(t, updatedJobs, 1)
 
-			if tt.assertFunc != nil {
-				tt.assertFunc(t, updatedJobs[0])
-			}
-		})
-	}
+				if tt.assertFunc != nil {
+					tt.assertFunc(t, updatedJobs[0])
+				}
+			})
+		}
+	})
 }
@@ -3,9 +3,12 @@ package storage
 import (
 	"context"
 	"database/sql"
+	"sync"
+	"time"
 
 	"github.com/back-end-labs/ruok/pkg/config"
 	"github.com/gofrs/uuid"
+	pgxuuid "github.com/jackc/pgx-gofrs-uuid"
 	"github.com/rs/zerolog/log"
 )
 
@@ -19,63 +22,91 @@ func (s *SQLStorage) StopListeningForChanges() error {
 	return nil
 }
 
-// Creates a gorutine that waits for messages in a loop and sends them over "jobIDUpdatedCh".
+// Creates a gorutine that waits for notifications in a loop and sends them over "notificationsCh".
 //
-// It will block until the waiting loop starts
-func (s *SQLStorage) ListenForChanges(jobIDUpdatedCh chan uuid.UUID, ctx context.Context) {
+// Malformed notifications are counted and dropped.
+//
+// If the connection is lost we listen again with an exponential backoff, and send an EventResync
+// notification because we may have missed some of them. The channel is only closed when "ctx" is done.
+//
+// It will block until the first attempt to listen finishes
+func (s *SQLStorage) ListenForChanges(notificationsCh chan Notification, ctx context.Context) {
+	if s.listener == nil {
+		s.listener = newListenerState()
+	}
 	ready := make(chan struct{})
+	signalReady := sync.OnceFunc(func() { close(ready) })
 
-	go func(jobIDUpdatedCh chan uuid.UUID, ctx context.Context) {
-		ownChannel := config.AppName()
-		conn, err := s.Db.Acquire(context.Background())
-
-		if err != nil {
-			log.Error().Err(err).Msg("could not acquire connection to listen for notifications")
-			return
-		}
-		defer conn.Release()
+	go func(notificationsCh chan Notification, ctx context.Context) {
+		defer close(notificationsCh)
+		backoff := time.Duration(0)
+		reconnecting := false
 
-		_, err = conn.Exec(context.Background(), "listen "+config.AppName())
-
-		if err != nil {
-			log.Error().Err(err).Msgf("could not listen to %q channel", ownChannel)
-			return
-		}
-
-		ready <- struct{}{}
 		for {
-			notification, err := conn.Conn().WaitForNotification(ctx)
-			if err != nil {
-				if ctx.Err() != nil {
-					log.Info().Msgf("done listening for notifications. msg: %q", ctx.Err().Error())
-					close(jobIDUpdatedCh)
-					break
-				}
-				log.Error().Err(err).Msgf("an error occurred while listening into %q channel", ownChannel)
-				close(jobIDUpdatedCh)
-				break
-			}
-			if notification.Payload == "release" {
-				log.Info().Msg("Received release, done!")
+			release, err := s.listen(notificationsCh, ctx, reconnecting, signalReady)
+			signalReady()
+			if release || ctx.Err() != nil {
+				log.Info().Msg("done listening for notifications")
 				return
 			}
-			if notification == nil {
-				log.Info().Msg("empty notification")
-				continue
-			}
-			id, err := uuid.FromString(notification.Payload)
-			if err != nil {
-				log.Error().Err(err).Msgf("an error occurred while parsing payload into job id %q for job update", notification.Payload)
-				continue
+			s.listener.disconnected(err)
+			backoff = nextBackoff(backoff)
+			log.Error().Err(err).Msgf("lost connection used to listen for notifications, trying again in %s", backoff)
+			select {
+			case <-ctx.Done():
+				log.Info().Msgf("done listening for notifications. msg: %q", ctx.Err().Error())
+				return
+			case <-time.After(backoff):
 			}
-			jobIDUpdatedCh <- id
+			reconnecting = true
 		}
-		log.Debug().Msg("exiting from listening updates gorutine")
-	}(jobIDUpdatedCh, ctx)
+	}(notificationsCh, ctx)
 
 	<-ready
-	close(ready)
+}
+
+// Listens on our channel until the connection fails, "ctx" is done or we receive a release.
+// Returns TRUE if we received a release.
+func (s *SQLStorage) listen(notificationsCh chan Notification, ctx context.Context, reconnecting bool, onListening func()) (bool, error) {
+	ownChannel := config.AppName()
+	conn, err := s.Db.Acquire(ctx)
+
+	if err != nil {
+		return false, err
+	}
+	defer conn.Release()
+
+	_, err = conn.Exec(ctx, "listen "+ownChannel)
+
+	if err != nil {
+		log.Error().Err(err).Msgf("could not listen to %q channel", ownChannel)
+		return false, err
+	}
+
+	s.listener.connected(reconnecting)
+	onListening()
+	if reconnecting {
+		log.Info().Msgf("listening to %q channel again", ownChannel)
+		notificationsCh <- Notification{Version: NotificationVersion, Event: EventResync}
+	}
 
+	for {
+		notification, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				// the connection may be broken, don't give it back to the pool
+				conn.Conn().Close(context.Background())
+			}
+			return false, err
+		}
+		if notification == nil {
+			log.Info().Msg("empty notification")
+			continue
+		}
+		if deliverPayload(notification.Payload, notificationsCh) {
+			return true, nil
+		}
+	}
 }
 
 const getJobsUpdatesQuery = `
@@ -91,7 +122,13 @@ SELECT
 	alert_strategy,
 	alert_endpoint,
 	alert_method,
-	updated_at
+	timezone,
+	status,
+	updated_at,
+	deleted_at,
+	labels,
+	locations,
+	alert_quorum
 FROM ruok.jobs
 WHERE id = $1
 `
@@ -108,7 +145,13 @@ type JobUpdates struct {
 	Alert_strategy   string
 	Alert_endpoint   string
 	Alert_method     string
+	Timezone         string
+	Status           string
 	Updated_at       int64
+	Deleted_at       int64
+	Labels           map[string]string
+	Locations        []string
+	Alert_quorum     int
 }
 
 func (s *SQLStorage) GetJobUpdates(jobId uuid.UUID) *JobUpdates {
@@ -133,6 +176,12 @@ func (s *SQLStorage) GetJobUpdates(jobId uuid.UUID) *JobUpdates {
 	var alert_strategy sql.NullString
 	var alert_endpoint sql.NullString
 	var alert_method sql.NullString
+	var timezone string
+	var status sql.NullString
+	var deleted_at sql.NullInt64
+	var labels string
+	var locations []string
+	var alert_quorum sql.NullInt32
 
 	err = row.Scan(
 		&job_name,
@@ -146,7 +195,13 @@ func (s *SQLStorage) GetJobUpdates(jobId uuid.UUID) *JobUpdates {
 		&alert_strategy,
 		&alert_endpoint,
 		&alert_method,
+		&timezone,
+		&status,
 		&updated_at,
+		&deleted_at,
+		&labels,
+		&locations,
+		&alert_quorum,
 	)
 
 	if err != nil {
@@ -165,6 +220,41 @@ func (s *SQLStorage) GetJobUpdates(jobId uuid.UUID) *JobUpdates {
 		alert_strategy.String,
 		alert_endpoint.String,
 		alert_method.String,
+		timezone,
+		status.String,
 		updated_at.Int64,
+		deleted_at.Int64,
+		parseLabels(labels),
+		locations,
+		int(alert_quorum.Int32),
+	}
+}
+
+// Gets the last update time of the given jobs, so we can find the ones that changed while we
+// were not listening. Jobs we can't see anymore are not in the result. Returns nil on errors.
+func (s *SQLStorage) GetJobsUpdatedAt(jobIds []uuid.UUID) map[uuid.UUID]int64 {
+	ctx := context.Background()
+	rows, err := s.Db.Query(ctx, "SELECT id, updated_at FROM ruok.jobs WHERE id = ANY($1)", jobIds)
+	if err != nil {
+		log.Error().Err(err).Msg("could not query for jobs updated_at")
+		return nil
+	}
+	defer rows.Close()
+
+	updatedAt := map[uuid.UUID]int64{}
+	for rows.Next() {
+		var id pgxuuid.UUID
+		var updated sql.NullInt64
+		err = rows.Scan(&id, &updated)
+		if err != nil {
+			log.Error().Err(err).Msg("could not scan jobs updated_at row")
+			return nil
+		}
+		updatedAt[uuid.UUID(id)] = updated.Int64
+	}
+	if rows.Err() != nil {
+		log.Error().Err(rows.Err()).Msg("could not read jobs updated_at rows")
+		return nil
 	}
+	return updatedAt
 }
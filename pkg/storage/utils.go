@@ -44,24 +44,32 @@ func seedQuery() string {
 		`, id1, id2, id3, id4, id5, id6, id7, id8, id9, id10)
 }
 
-func Seed() {
+// Raw access to the db, only meant to seed and clean it while developing and testing
+type rawDB interface {
+	execRaw(ctx context.Context, query string, args ...any) error
+}
+
+// Connects to the configured db with a role allowed to seed and drop
+func rawStorage() (rawDB, Closer) {
 	cfg := config.FromEnvs()
 	// use a testing role with all privileges
 	cfg.User = "testing_user"
 	s, close := NewStorage(&cfg)
-	defer close()
-	ctx := context.Background()
-	tx, err := s.GetClient().Begin(ctx)
-	if err != nil {
-		log.Fatalf("couldn't seed. error=%q", err)
+	raw, ok := s.(rawDB)
+	if !ok {
+		log.Fatalf("there is no db to seed or drop with the %q storage", cfg.Kind)
 	}
+	return raw, close
+}
 
-	_, err = tx.Exec(ctx, seedQuery())
-	if err != nil {
-		log.Fatalf("couldn't seed. error=%q", err)
-	}
+func Seed() {
+	s, close := rawStorage()
+	defer close()
+	seed(s)
+}
 
-	err = tx.Commit(ctx)
+func seed(s rawDB) {
+	err := s.execRaw(context.Background(), seedQuery())
 	if err != nil {
 		log.Fatalf("couldn't seed. error=%q", err)
 	}
@@ -69,33 +77,54 @@ func Seed() {
 
 var dropJobsQuery string = "delete from ruok.jobs"
 var dropJobResultsQuery string = "delete from ruok.job_results"
+var dropMaintenanceWindowsQuery string = "delete from ruok.maintenance_windows"
+var dropJobResultsAggregatesQuery string = "delete from ruok.job_results_aggregates"
+var dropJobLocationsQuery string = "delete from ruok.job_locations"
+var dropAPIKeysQuery string = "delete from ruok.api_keys"
+var dropJobAuditQuery string = "delete from ruok.job_audit"
 
 func Drop() {
-	cfg := config.FromEnvs()
-	// use a testing role with all privileges
-	cfg.User = "testing_user"
-	s, close := NewStorage(&cfg)
+	s, close := rawStorage()
 	defer close()
+	drop(s)
+}
+
+func drop(s rawDB) {
 	ctx := context.Background()
-	tx, err := s.GetClient().Begin(ctx)
 
+	err := s.execRaw(ctx, dropJobsQuery)
 	if err != nil {
-		log.Fatalf("couldn't init transaction. error=%q", err)
+		log.Fatalf("couldn't delete jobs. error=%q", err)
 	}
 
-	_, err = tx.Exec(ctx, dropJobsQuery)
+	err = s.execRaw(ctx, dropJobResultsQuery)
 	if err != nil {
-		log.Fatalf("couldn't delete jobs. error=%q", err)
+		log.Fatalf("couldn't delete job results. error=%q", err)
 	}
 
-	_, err = tx.Exec(ctx, dropJobResultsQuery)
+	err = s.execRaw(ctx, dropMaintenanceWindowsQuery)
 	if err != nil {
-		log.Fatalf("couldn't delete job results. error=%q", err)
+		log.Fatalf("couldn't delete maintenance windows. error=%q", err)
 	}
 
-	err = tx.Commit(ctx)
+	err = s.execRaw(ctx, dropJobResultsAggregatesQuery)
 	if err != nil {
-		log.Fatalf("couldn't seed. error=%q", err)
+		log.Fatalf("couldn't delete job results aggregates. error=%q", err)
+	}
+
+	err = s.execRaw(ctx, dropJobLocationsQuery)
+	if err != nil {
+		log.Fatalf("couldn't delete job locations. error=%q", err)
+	}
+
+	err = s.execRaw(ctx, dropAPIKeysQuery)
+	if err != nil {
+		log.Fatalf("couldn't delete api keys. error=%q", err)
+	}
+
+	err = s.execRaw(ctx, dropJobAuditQuery)
+	if err != nil {
+		log.Fatalf("couldn't delete job audit. error=%q", err)
 	}
 }
 
@@ -112,6 +141,14 @@ func HasMinAlertFields(strategy string, endpoint string, method string) bool {
 	return true
 }
 
+// Jobs without a timezone have their schedule evaluated in UTC
+func TimezoneOrDefault(timezone string) string {
+	if timezone == "" {
+		return "UTC"
+	}
+	return timezone
+}
+
 func seedOneJobQuery(id uuid.UUID) string {
 	return fmt.Sprintf(`
 	INSERT INTO ruok.jobs (
@@ -3,29 +3,106 @@ package storage
 import (
 	"context"
 	"errors"
+	"fmt"
 
 	"github.com/gofrs/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/rs/zerolog/log"
 
 	"github.com/back-end-labs/ruok/pkg/job"
 )
 
-// Writes an execution result in the db
+// A row of ruok.job_results, copied from the job as soon as the execution ends
+// because the job keeps changing while the row waits to be written
+type resultRow struct {
+	Id              uuid.UUID `json:"id"`
+	JobName         string    `json:"jobName"`
+	JobId           uuid.UUID `json:"jobId"`
+	CronExpString   string    `json:"cronExpString"`
+	Endpoint        string    `json:"endpoint"`
+	HttpMethod      string    `json:"httpMethod"`
+	MaxRetries      int       `json:"maxRetries"`
+	ExecutionTime   int64     `json:"executionTime"`
+	ShouldExecuteAt int64     `json:"shouldExecuteAt"`
+	LastResponseAt  int64     `json:"lastResponseAt"`
+	LastMessage     string    `json:"lastMessage"`
+	LastStatusCode  int       `json:"lastStatusCode"`
+	SuccessStatuses []int     `json:"successStatuses"`
+	Status          string    `json:"status"`
+	ClaimedBy       string    `json:"claimedBy"`
+	Succeeded       string    `json:"succeeded"`
+	Trigger         string    `json:"trigger"`
+	QueueWait       int64     `json:"queueWait"`
+	Location        string    `json:"location,omitempty"`
+}
+
+func newResultRow(j *job.Job) (resultRow, error) {
+	// manual runs already have an id so callers can wait for their result
+	id := j.RunId
+	if id == uuid.Nil {
+		var err error
+		id, err = uuid.NewV7()
+		if err != nil {
+			log.Error().Err(err).Msg("could not create uuidv7 for new job")
+			return resultRow{}, err
+		}
+	}
+	return resultRow{
+		Id:              id,
+		JobName:         j.Name,
+		JobId:           j.Id,
+		CronExpString:   j.CronExpString,
+		Endpoint:        j.Endpoint,
+		HttpMethod:      j.HttpMethod,
+		MaxRetries:      j.MaxRetries,
+		ExecutionTime:   j.LastExecution.UnixMicro(),
+		ShouldExecuteAt: j.ShouldExecuteAt.UnixMicro(),
+		LastResponseAt:  j.LastResponseAt.UnixMicro(),
+		LastMessage:     j.LastMessage,
+		LastStatusCode:  j.LastStatusCode,
+		SuccessStatuses: j.SuccessStatuses,
+		Status:          j.ResultStatus(),
+		ClaimedBy:       j.ClaimedBy,
+		Succeeded:       j.Succeeded,
+		Trigger:         j.ResultTrigger(),
+		QueueWait:       j.LastQueueWait.Microseconds(),
+		Location:        j.Location,
+	}, nil
+}
+
+// Writes an execution result in the db.
+// When results are buffered it only queues it, see BufferResults.
 func (sqls *SQLStorage) WriteDone(j *job.Job) error {
-	id, err := uuid.NewV7()
+	row, err := newResultRow(j)
 	if err != nil {
-		log.Error().Err(err).Msg("could not create uuidv7 for new job")
 		return err
 	}
+	if sqls.results != nil && sqls.results.add(row) {
+		return nil
+	}
+	return sqls.writeResults([]resultRow{row})
+}
+
+// Inserts the results and updates the last execution of their jobs in a single round trip.
+// COPY would be faster but postgres doesn't allow it on tables with row level security.
+// Results already written are ignored, so spooled ones can be retried safely.
+func (sqls *SQLStorage) writeResults(rows []resultRow) error {
+	if len(rows) == 0 {
+		return nil
+	}
 	ctx := context.Background()
 	tx, err := sqls.Db.Begin(ctx)
-	defer tx.Rollback(ctx)
-
 	if err != nil {
-		log.Error().Err(err).Msg("could not start transaction to write job execution result")
+		log.Error().Err(err).Msg("could not start transaction to write job execution results")
 		return errors.New("could not insert into jobs_results")
 	}
-	_, err = tx.Exec(ctx, `
+	defer tx.Rollback(ctx)
+
+	batch := &pgx.Batch{}
+	latest := map[uuid.UUID]resultRow{}
+	for _, r := range rows {
+		batch.Queue(`
 	INSERT INTO ruok.job_results (
 		id,
 		job_name,
@@ -42,19 +119,24 @@ func (sqls *SQLStorage) WriteDone(j *job.Job) error {
 		success_statuses,
 		status,
 		claimed_by,
-		succeeded
-	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16);
-	`, id, j.Name, j.Id, j.CronExpString, j.Endpoint, j.HttpMethod, j.MaxRetries, j.LastExecution.UnixMicro(),
-		j.ShouldExecuteAt.UnixMicro(), j.LastResponseAt.UnixMicro(), j.LastMessage, j.LastStatusCode,
-		j.SuccessStatuses, j.Status, j.ClaimedBy, j.Succeeded,
-	)
-
-	if err != nil {
-		log.Error().Err(err).Msg("could not insert into job_results")
-		return errors.New("could not insert into job_results")
+		succeeded,
+		trigger,
+		queue_wait,
+		location
+	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19)
+	ON CONFLICT (id, execution_time) DO NOTHING;
+	`, r.Id, r.JobName, r.JobId, r.CronExpString, r.Endpoint, r.HttpMethod, r.MaxRetries, r.ExecutionTime,
+			r.ShouldExecuteAt, r.LastResponseAt, r.LastMessage, r.LastStatusCode,
+			r.SuccessStatuses, r.Status, r.ClaimedBy, r.Succeeded, r.Trigger, r.QueueWait, nullString(r.Location),
+		)
+		if previous, ok := latest[r.JobId]; !ok || previous.ExecutionTime <= r.ExecutionTime {
+			latest[r.JobId] = r
+		}
 	}
 
-	_, err = tx.Exec(ctx, `
+	// only the latest execution of each job matters for the jobs table
+	for _, r := range latest {
+		batch.Queue(`
 	UPDATE ruok.jobs SET
 		last_execution = $1,
 		should_execute_at = $2,
@@ -64,18 +146,26 @@ func (sqls *SQLStorage) WriteDone(j *job.Job) error {
 		succeeded = $6
 	WHERE id = $7
 	`,
-		j.LastExecution.UnixMicro(),
-		j.ShouldExecuteAt.UnixMicro(),
-		j.LastResponseAt.UnixMicro(),
-		j.LastMessage,
-		j.LastStatusCode,
-		j.Succeeded,
-		j.Id)
+			r.ExecutionTime,
+			r.ShouldExecuteAt,
+			r.LastResponseAt,
+			r.LastMessage,
+			r.LastStatusCode,
+			r.Succeeded,
+			r.JobId)
+	}
 
+	err = tx.SendBatch(ctx, batch).Close()
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		log.Error().Err(err).Msgf("the db refused %d job results", len(rows))
+		return fmt.Errorf("could not insert into job_results: %w", errResultsRefused)
+	}
 	if err != nil {
-		log.Error().Err(err).Msg("could not update last execution fields for job")
+		log.Error().Err(err).Msgf("could not write %d job results", len(rows))
 		return errors.New("could not insert into job_results")
 	}
+
 	err = tx.Commit(ctx)
 
 	if err != nil {
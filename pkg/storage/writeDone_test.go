@@ -1,15 +1,14 @@
 package storage
 
 import (
-	"context"
 	"database/sql"
+	"fmt"
 	"testing"
 	"time"
 
 	"github.com/back-end-labs/ruok/pkg/config"
 	"github.com/back-end-labs/ruok/pkg/job"
 	"github.com/gofrs/uuid"
-	pgxuuid "github.com/jackc/pgx-gofrs-uuid"
 )
 
 var makeJobStruct = func(id uuid.UUID, now time.Time) job.Job {
@@ -64,125 +63,167 @@ WHERE id = $1
 `
 
 func TestWriteDone(t *testing.T) {
-	Drop()
-	defer Drop()
-	t.Run("Done jobs are written as they should", func(t *testing.T) {
-		cfg := config.FromEnvs()
-		s, close := NewStorage(&cfg)
-		defer close()
+	forEachStorage(t, func(t *testing.T, s *testStorage) {
+		t.Run("Done jobs are written as they should", func(t *testing.T) {
 
-		id, _ := uuid.NewV7()
+			id, _ := uuid.NewV7()
 
-		ctx := context.Background()
+			err := s.exec(seedOneJobQuery(id))
 
-		_, err := s.GetClient().Exec(ctx, seedOneJobQuery(id))
+			if err != nil {
+				t.Errorf("couldn't seed due to the following error: %q", err.Error())
+			}
 
-		if err != nil {
-			t.Errorf("couldn't seed due to the following error: %q", err.Error())
-		}
+			now := time.Now()
 
-		now := time.Now()
+			j := makeJobStruct(id, now)
 
-		j := makeJobStruct(id, now)
+			err = s.WriteDone(&j)
 
-		err = s.WriteDone(&j)
+			if err != nil {
+				t.Errorf("writing a job result shouldn't error. error=%q\n", err.Error())
+			}
 
-		if err != nil {
-			t.Errorf("writing a job result shouldn't error. error=%q\n", err.Error())
-		}
+			// Clousure for job_execution asserts
+			{
+				var (
+					id              string
+					jobID           string
+					name            string
+					cronExpString   string
+					endpoint        string
+					httpMethod      string
+					maxRetries      int
+					executionTime   int64
+					shouldExecuteAt int64
+					lastResponseAt  int64
+					lastMessage     sql.NullString
+					lastStatusCode  int
+					successStatuses []int
+					tlsClientCert   sql.NullString
+					claimedBy       string
+				)
+				row := s.queryRow(selectJobExecutionQuery, j.Id)
+				err = row.Scan(
+					&id,
+					&jobID,
+					&name,
+					&cronExpString,
+					&endpoint,
+					&httpMethod,
+					&maxRetries,
+					&executionTime,
+					&shouldExecuteAt,
+					&lastResponseAt,
+					&lastMessage,
+					&lastStatusCode,
+					&successStatuses,
+					&claimedBy,
+				)
+				if err != nil {
+					t.Errorf("querying a done job should not produce an error. error=%q\n", err.Error())
+				}
+
+				checkJobExecutionFields(
+					uuid.FromStringOrNil(jobID),
+					j,
+					t,
+					name,
+					cronExpString,
+					endpoint,
+					httpMethod,
+					maxRetries,
+					executionTime,
+					shouldExecuteAt,
+					lastResponseAt,
+					lastMessage,
+					lastStatusCode,
+					tlsClientCert,
+					claimedBy)
 
-		// Clousure for job_execution asserts
-		{
-			ctx = context.Background()
-
-			var (
-				id              pgxuuid.UUID
-				jobID           pgxuuid.UUID
-				name            string
-				cronExpString   string
-				endpoint        string
-				httpMethod      string
-				maxRetries      int
-				executionTime   int64
-				shouldExecuteAt int64
-				lastResponseAt  int64
-				lastMessage     sql.NullString
-				lastStatusCode  int
-				successStatuses []int
-				tlsClientCert   sql.NullString
-				claimedBy       string
-			)
-			row := s.GetClient().QueryRow(ctx, selectJobExecutionQuery, j.Id)
-			err = row.Scan(
-				&id,
-				&jobID,
-				&name,
-				&cronExpString,
-				&endpoint,
-				&httpMethod,
-				&maxRetries,
-				&executionTime,
-				&shouldExecuteAt,
-				&lastResponseAt,
-				&lastMessage,
-				&lastStatusCode,
-				&successStatuses,
-				&claimedBy,
-			)
-			if err != nil {
-				t.Errorf("querying a done job should not produce an error. error=%q\n", err.Error())
 			}
 
-			checkJobExecutionFields(
-				uuid.UUID(jobID),
-				j,
-				t,
-				name,
-				cronExpString,
-				endpoint,
-				httpMethod,
-				maxRetries,
-				executionTime,
-				shouldExecuteAt,
-				lastResponseAt,
-				lastMessage,
-				lastStatusCode,
-				tlsClientCert,
-				claimedBy)
+			// clousure for jobs asserts
+			{
 
-		}
+				var executionTime int64
+				var shouldExecuteAt int64
+				var lastResponseAt int64
+				var lastMessage sql.NullString
+				var lastStatusCode int
+
+				row := s.queryRow(queryDoneJob, j.Id)
 
-		// clousure for jobs asserts
-		{
+				err := row.Scan(
+					&executionTime,
+					&shouldExecuteAt,
+					&lastResponseAt,
+					&lastMessage,
+					&lastStatusCode,
+				)
 
-			var executionTime int64
-			var shouldExecuteAt int64
-			var lastResponseAt int64
-			var lastMessage sql.NullString
-			var lastStatusCode int
+				if err != nil {
+					t.Errorf("couldn't get job after updating it: %q", err.Error())
+				}
 
-			ctx := context.Background()
+				checkDoneJobFields(executionTime, j, t, shouldExecuteAt, lastResponseAt, lastMessage, lastStatusCode)
 
-			row := s.GetClient().QueryRow(ctx, queryDoneJob, j.Id)
+			}
 
-			err := row.Scan(
-				&executionTime,
-				&shouldExecuteAt,
-				&lastResponseAt,
-				&lastMessage,
-				&lastStatusCode,
-			)
+		})
 
-			if err != nil {
-				t.Errorf("couldn't get job after updating it: %q", err.Error())
+	})
+}
+
+func TestWriteDoneBuffered(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, s *testStorage) {
+		id, _ := uuid.NewV7()
+		err := s.exec(seedOneJobQuery(id))
+		if err != nil {
+			t.Errorf("couldn't seed due to the following error: %q", err.Error())
+		}
+
+		s.BufferResults(100, time.Hour, t.TempDir()+"/results.spool")
+		now := time.Now()
+		var last job.Job
+		for i := 0; i < 3; i++ {
+			last = makeJobStruct(id, now.Add(time.Duration(i)*time.Second))
+			last.LastMessage = fmt.Sprintf("execution %d", i)
+			if err := s.WriteDone(&last); err != nil {
+				t.Errorf("buffering a job result shouldn't error. error=%q\n", err.Error())
 			}
+		}
 
-			checkDoneJobFields(executionTime, j, t, shouldExecuteAt, lastResponseAt, lastMessage, lastStatusCode)
+		var count int
+		s.queryRow("SELECT count(*) FROM ruok.job_results WHERE job_id = $1", id).Scan(&count)
+		if count != 0 {
+			t.Errorf("results should wait in the buffer, found %d", count)
+		}
 
+		if err := s.FlushResults(); err != nil {
+			t.Errorf("flushing results shouldn't error. error=%q\n", err.Error())
 		}
 
-	})
+		s.queryRow("SELECT count(*) FROM ruok.job_results WHERE job_id = $1", id).Scan(&count)
+		if count != 3 {
+			t.Errorf("expected 3 results after flushing, found %d", count)
+		}
 
+		var executionTime, shouldExecuteAt, lastResponseAt int64
+		var lastMessage sql.NullString
+		var lastStatusCode int
+		err = s.queryRow(queryDoneJob, id).Scan(
+			&executionTime,
+			&shouldExecuteAt,
+			&lastResponseAt,
+			&lastMessage,
+			&lastStatusCode,
+		)
+		if err != nil {
+			t.Errorf("couldn't get job after updating it: %q", err.Error())
+		}
+		checkDoneJobFields(executionTime, last, t, shouldExecuteAt, lastResponseAt, lastMessage, lastStatusCode)
+	})
 }
 
 func checkDoneJobFields(
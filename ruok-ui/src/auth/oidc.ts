@@ -0,0 +1,151 @@
+// Logs in with the identity provider configured in the API using the authorization code flow with PKCE,
+// so the UI never holds a client secret. Tokens are kept in the session storage of the tab.
+
+export const apiBase = 'http://localhost:8080';
+
+type AuthSettings = {
+  mode: 'none' | 'keys' | 'oidc';
+  issuer?: string;
+  clientId?: string;
+  audience?: string;
+};
+
+type Discovery = {
+  authorization_endpoint: string;
+  token_endpoint: string;
+};
+
+const tokenKey = 'ruok.token';
+const verifierKey = 'ruok.pkce.verifier';
+const stateKey = 'ruok.pkce.state';
+
+const redirectUri = () => `${window.location.origin}/`;
+
+const base64url = (bytes: Uint8Array) =>
+  btoa(String.fromCharCode(...bytes))
+    .replace(/\+/g, '-')
+    .replace(/\//g, '_')
+    .replace(/=+$/, '');
+
+const randomString = () => base64url(crypto.getRandomValues(new Uint8Array(32)));
+
+const challengeFor = async (verifier: string) =>
+  base64url(new Uint8Array(await crypto.subtle.digest('SHA-256', new TextEncoder().encode(verifier))));
+
+let settings: Promise<AuthSettings> | undefined;
+
+export const getAuthSettings = () => {
+  if (!settings) {
+    settings = fetch(`${apiBase}/v1/auth/config`).then((res) => res.json() as Promise<AuthSettings>);
+  }
+  return settings;
+};
+
+const discover = (issuer: string) =>
+  fetch(`${issuer}/.well-known/openid-configuration`).then((res) => {
+    if (!res.ok) {
+      throw new Error('could not fetch the identity provider configuration');
+    }
+    return res.json() as Promise<Discovery>;
+  });
+
+export const getToken = () => {
+  const raw = sessionStorage.getItem(tokenKey);
+  if (!raw) {
+    return undefined;
+  }
+  const token = JSON.parse(raw) as { accessToken: string; expiresAt: number };
+  if (token.expiresAt <= Date.now()) {
+    sessionStorage.removeItem(tokenKey);
+    return undefined;
+  }
+  return token.accessToken;
+};
+
+// Sends the browser to the identity provider, it comes back with a code that completeLogin exchanges
+export const login = async () => {
+  const { issuer, clientId } = await getAuthSettings();
+  if (!issuer || !clientId) {
+    return;
+  }
+  const discovery = await discover(issuer);
+  const verifier = randomString();
+  const state = randomString();
+  sessionStorage.setItem(verifierKey, verifier);
+  sessionStorage.setItem(stateKey, state);
+
+  const params = new URLSearchParams({
+    response_type: 'code',
+    client_id: clientId,
+    redirect_uri: redirectUri(),
+    scope: 'openid profile',
+    state,
+    code_challenge: await challengeFor(verifier),
+    code_challenge_method: 'S256',
+  });
+  window.location.assign(`${discovery.authorization_endpoint}?${params.toString()}`);
+};
+
+export const logout = () => {
+  sessionStorage.removeItem(tokenKey);
+};
+
+// Exchanges the code the identity provider redirected back with, does nothing on any other page load
+export const completeLogin = async () => {
+  const params = new URLSearchParams(window.location.search);
+  const code = params.get('code');
+  const state = params.get('state');
+  if (!code || !state) {
+    return;
+  }
+  const verifier = sessionStorage.getItem(verifierKey);
+  const expectedState = sessionStorage.getItem(stateKey);
+  sessionStorage.removeItem(verifierKey);
+  sessionStorage.removeItem(stateKey);
+  // drop the code from the address bar either way
+  window.history.replaceState(null, '', `${window.location.pathname}${window.location.hash}`);
+
+  if (!verifier || state !== expectedState) {
+    throw new Error('the login was not started by this tab');
+  }
+
+  const { issuer, clientId } = await getAuthSettings();
+  if (!issuer || !clientId) {
+    return;
+  }
+  const discovery = await discover(issuer);
+  const res = await fetch(discovery.token_endpoint, {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/x-www-form-urlencoded' },
+    body: new URLSearchParams({
+      grant_type: 'authorization_code',
+      code,
+      redirect_uri: redirectUri(),
+      client_id: clientId,
+      code_verifier: verifier,
+    }),
+  });
+  if (!res.ok) {
+    throw new Error('could not exchange the login code');
+  }
+  const token = (await res.json()) as { access_token: string; expires_in?: number };
+  sessionStorage.setItem(
+    tokenKey,
+    JSON.stringify({ accessToken: token.access_token, expiresAt: Date.now() + (token.expires_in ?? 300) * 1000 }),
+  );
+};
+
+// Calls the API with the token of the user, sending them to log in when it is missing or no longer valid
+export const apiFetch = async (path: string, init: RequestInit = {}) => {
+  const headers = new Headers(init.headers);
+  const token = getToken();
+  if (token) {
+    headers.set('Authorization', `Bearer ${token}`);
+  }
+  const res = await fetch(`${apiBase}${path}`, { ...init, headers });
+  if (res.status === 401 && (await getAuthSettings()).mode === 'oidc') {
+    logout();
+    await login();
+  }
+  return res;
+};
@@ -4,6 +4,7 @@ import Button from '@mui/joy/Button';
 import { useMutation } from 'react-query';
 import FormControl from '@mui/joy/FormControl';
 import FormLabel from '@mui/joy/FormLabel';
+import FormHelperText from '@mui/joy/FormHelperText';
 import Input from '@mui/joy/Input';
 import ModalDialog from '@mui/joy/ModalDialog';
 import DialogTitle from '@mui/joy/DialogTitle';
@@ -15,6 +16,33 @@ import HelpOutlineSharpIcon from '@mui/icons-material/HelpOutlineSharp';
 import { ModalClose, Tooltip } from '@mui/joy';
 import DebounceInput from './DebounceInput';
 import { createJob } from '../queries/createJob';
+import { useNextExecutions } from '../queries/nextExecutions';
+
+const scheduleHelp = `
+Use a cron expression ("*/5 * * * *"), an interval ("every 30s"),
+a one-shot timestamp ("at 2024-06-10T09:00:00") or a calendar rule ("RRULE:FREQ=DAILY;BYHOUR=9").
+`;
+
+const SchedulePreview = ({ cron, timezone }: { cron: string; timezone: string }) => {
+  const { data, isError } = useNextExecutions(cron, timezone, 5);
+  if (!cron) {
+    return null;
+  }
+  if (isError) {
+    return <FormHelperText>Invalid schedule</FormHelperText>;
+  }
+  if (!data) {
+    return null;
+  }
+  if (data.nextExecutions.length === 0) {
+    return <FormHelperText>This schedule has no future executions</FormHelperText>;
+  }
+  return (
+    <FormHelperText>
+      Next executions: {data.nextExecutions.map((e) => new Date(e).toLocaleString()).join(', ')}
+    </FormHelperText>
+  );
+};
 
 const successStatusesHelp = `
 Use a comma separated list of success statuses that will signal an OK backend.
@@ -37,6 +65,7 @@ export const CreateJobForm = React.forwardRef(() => {
   const mutation = useMutation(createJob);
   const [name, setName] = useState('');
   const [cron, setCron] = useState('');
+  const [timezone, setTimezone] = useState(Intl.DateTimeFormat().resolvedOptions().timeZone);
   const [endpoint, setEndpoint] = useState('');
   const [method, setMethod] = useState('');
   const [success, setSuccess] = useState([] as number[]);
@@ -66,8 +95,22 @@ export const CreateJobForm = React.forwardRef(() => {
             <Input onChange={(event: React.ChangeEvent<HTMLInputElement>) => setName(event.target.value)} required />
           </FormControl>
           <FormControl>
-            <FormLabel>Cron expression</FormLabel>
-            <Input onChange={(event: React.ChangeEvent<HTMLInputElement>) => setCron(event.target.value)} required />
+            <FormLabel>Schedule</FormLabel>
+            <Stack spacing={1} direction="row" alignItems="center" justifyContent="space-between">
+              <DebounceInput fullWidth required handleDebounce={(v) => setCron(v.trim())} debounceTimeout={300} />
+              <Tooltip title={scheduleHelp}>
+                <HelpOutlineSharpIcon />
+              </Tooltip>
+            </Stack>
+            <SchedulePreview cron={cron} timezone={timezone} />
+          </FormControl>
+          <FormControl>
+            <FormLabel>Timezone</FormLabel>
+            <DebounceInput
+              defaultValue={timezone}
+              handleDebounce={(v) => setTimezone(v.trim())}
+              debounceTimeout={300}
+            />
           </FormControl>
           <FormControl>
             <FormLabel>Endpoint</FormLabel>
@@ -123,6 +166,7 @@ export const CreateJobForm = React.forwardRef(() => {
                 const body = JSON.stringify({
                   name,
                   cronexp: cron,
+                  timezone,
                   maxRetries: 1,
                   endpoint,
                   httpmethod: method,
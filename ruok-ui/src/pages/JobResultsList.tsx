@@ -95,7 +95,7 @@ const Headers = () => {
 };
 
 const Foot = (props: {
-  currentPageLength: number;
+  hasNextPage: boolean;
   page: number;
   rowsPerPage: number;
   handleChangeRowsPerPage: (event: unknown, newValue: number | null) => void;
@@ -148,7 +148,7 @@ const Foot = (props: {
                 size="sm"
                 color="neutral"
                 variant="outlined"
-                disabled={props.currentPageLength < props.rowsPerPage}
+                disabled={!props.hasNextPage}
                 onClick={() => props.handleChangePage(props.page + 1)}
                 sx={{ bgcolor: 'background.surface' }}
               >
@@ -173,7 +173,9 @@ const JobResultsList = () => {
 
   const [pageSize, setPageSize] = useState(10);
   const [pageNumber, setPageNumber] = useState(0);
-  const { data, error, isLoading } = useListJobResults(id, pageSize, pageNumber * pageSize);
+  // cursor of every page we went through, the first one has none
+  const [cursors, setCursors] = useState(['']);
+  const { data, error, isLoading } = useListJobResults(id, pageSize, cursors[pageNumber]);
   return (
     <>
       <Stack spacing={4}>
@@ -227,12 +229,17 @@ const JobResultsList = () => {
                 page={pageNumber}
                 rowsPerPage={pageSize}
                 handleChangePage={(n: number) => {
+                  if (n > pageNumber) {
+                    setCursors([...cursors.slice(0, n), data.nextCursor]);
+                  }
                   setPageNumber(n);
                 }}
                 handleChangeRowsPerPage={(_event: unknown, newValue: number | null) => {
                   setPageSize(parseInt(newValue!.toString(), 10));
+                  setCursors(['']);
+                  setPageNumber(0);
                 }}
-                currentPageLength={data?.jobResults || 0}
+                hasNextPage={!!data?.nextCursor}
               />
             }
           />
@@ -116,7 +116,8 @@ const Headers = () => {
 };
 
 const Foot = (props: {
-  totalRows: number;
+  currentPageLength: number;
+  hasNextPage: boolean;
   page: number;
   rowsPerPage: number;
   handleChangeRowsPerPage: (event: unknown, newValue: number | null) => void;
@@ -146,7 +147,7 @@ const Foot = (props: {
             </FormControl>
             <Typography textAlign="center" sx={{ minWidth: 80 }}>
               {props.page * props.rowsPerPage + 1} to{' '}
-              {Math.min(props.page * props.rowsPerPage + props.rowsPerPage, props.totalRows)} of {props.totalRows}
+              {props.page * props.rowsPerPage + props.currentPageLength}
             </Typography>
             <Box sx={{ display: 'flex', gap: 1 }}>
               <IconButton
@@ -173,7 +174,7 @@ const Foot = (props: {
                 size="sm"
                 color="neutral"
                 variant="outlined"
-                disabled={props.totalRows !== -1 ? props.totalRows <= (props.page + 1) * props.rowsPerPage : false}
+                disabled={!props.hasNextPage}
                 onClick={() => props.handleChangePage(props.page + 1)}
                 sx={{ bgcolor: 'background.surface' }}
               >
@@ -197,7 +198,9 @@ const JobList = () => {
 
   const [pageSize, setPageSize] = useState(10);
   const [pageNumber, setPageNumber] = useState(0);
-  const { data, error, isLoading } = useListJobs(pageSize, pageNumber * pageSize);
+  // cursor of every page we went through, the first one has none
+  const [cursors, setCursors] = useState(['']);
+  const { data, error, isLoading } = useListJobs(pageSize, cursors[pageNumber]);
   return (
     <>
       <Stack spacing={4}>
@@ -250,12 +253,18 @@ const JobList = () => {
                 page={pageNumber}
                 rowsPerPage={pageSize}
                 handleChangePage={(n: number) => {
+                  if (n > pageNumber) {
+                    setCursors([...cursors.slice(0, n), data.nextCursor]);
+                  }
                   setPageNumber(n);
                 }}
                 handleChangeRowsPerPage={(_event: unknown, newValue: number | null) => {
                   setPageSize(parseInt(newValue!.toString(), 10));
+                  setCursors(['']);
+                  setPageNumber(0);
                 }}
-                totalRows={data.claimedJobs}
+                currentPageLength={data.jobs?.length || 0}
+                hasNextPage={!!data.nextCursor}
               />
             }
           />
@@ -1,3 +1,4 @@
+import { apiFetch } from '../auth/oidc';
 import { useQuery } from 'react-query';
 
 export const key = '[getInstanceInfo]';
@@ -19,5 +20,5 @@ export default () =>
   useQuery({
     queryKey: [key],
     cacheTime: 0,
-    queryFn: () => fetch(`http://localhost:8080/v1/instance`).then((res) => res.json()),
+    queryFn: () => apiFetch(`/v1/instance`).then((res) => res.json()),
   });
@@ -1,14 +1,18 @@
+import { apiFetch } from '../auth/oidc';
 import { useQuery } from 'react-query';
 
 export const key = '[listJobResultsQueryKey]';
 
-export const useListJobResults = (id: string | number, limit: number, offset: number) => {
+// Lists a page of executions, "cursor" is the nextCursor of the previous page and empty for the first one
+export const useListJobResults = (id: string | number, limit: number, cursor: string) => {
   const _limit = limit || 10;
-  const _offset = offset || 0;
+  const _cursor = cursor || '';
 
   return useQuery({
-    queryKey: [key, _limit, _offset],
+    queryKey: [key, id, _limit, _cursor],
     queryFn: () =>
-      fetch(`http://localhost:8080/v1/jobs/${id}?limit=${_limit}&offset=${_offset}`).then((res) => res.json()),
+      apiFetch(`/v1/jobs/${id}/executions?limit=${_limit}&cursor=${encodeURIComponent(_cursor)}`).then((res) =>
+        res.json(),
+      ),
   });
 };
@@ -0,0 +1,20 @@
+import { apiFetch } from '../auth/oidc';
+import { useQuery } from 'react-query';
+
+export const key = '[nextExecutions]';
+
+export const useNextExecutions = (cronexp: string, timezone: string, n: number) => {
+  const params = new URLSearchParams({ cronexp, timezone, n: `${n || 5}` });
+  return useQuery({
+    queryKey: [key, cronexp, timezone, n],
+    enabled: !!cronexp,
+    retry: false,
+    queryFn: () =>
+      apiFetch(`/v1/schedules/next?${params.toString()}`).then((res) => {
+        if (!res.ok) {
+          throw new Error('invalid schedule');
+        }
+        return res.json() as Promise<{ cronexp: string; timezone: string; nextExecutions: string[] }>;
+      }),
+  });
+};